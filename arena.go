@@ -0,0 +1,111 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+// arenaChunk is the number of digests that a digestArena makes room for at a
+// time, if the number of the digests of the build is not known in advance
+// (e.g. for a Builder).
+const arenaChunk = 256
+
+// digestArena hands out room for the digests of a build (e.g. of the leaves
+// of a merkle tree upon its construction) out of a single buffer, one after
+// the other, so that they take a single allocation rather than one each. Once
+// the buffer runs out, another one is allocated in its place.
+//
+// A nil *digestArena hands out no room at all, so the digests are allocated
+// one by one.
+type digestArena struct {
+	size  int
+	chunk int
+	buf   []byte
+}
+
+// newDigestArena returns a digestArena for n digests of the given size, which
+// makes room for another arenaChunk of them at a time once they are all
+// handed out.
+func newDigestArena(size, n int) *digestArena {
+	return &digestArena{size: size, chunk: arenaChunk, buf: make([]byte, size*n)}
+}
+
+// next returns an empty slice with room for a digest; appending more than a
+// digest to it reallocates it, as usual.
+func (a *digestArena) next() []byte {
+	if a == nil {
+		return nil
+	}
+	if len(a.buf) < a.size {
+		a.buf = make([]byte, a.size*a.chunk)
+	}
+	digest := a.buf[:0:a.size]
+	a.buf = a.buf[a.size:]
+	return digest
+}
+
+// RecycleNodes configures the merkle tree to recycle the memory of its merkle
+// nodes across (re)constructions; i.e. each one lays them out in the memory
+// that the merkle nodes of the one before the last occupied, rather than in
+// newly allocated memory, as long as it fits them. Hence, a merkle tree that
+// is mutated over and over again (e.g. by a long-running server, through
+// AppendAndReconstruct) settles into two buffers of merkle nodes, rather than
+// leaving one behind for the garbage collector upon every mutation.
+//
+// The memory of merkle nodes that are shared with a snapshot of the merkle
+// tree (see Snapshot and WithHistory) is never recycled. Note, though, that
+// the merkle root returned by UnsafeMerkleRoot before a mutation may be
+// overwritten by the mutation after it, so it must be copied to be retained. It has no
+// effect if the merkle nodes are stored through WithNodeStore.
+func RecycleNodes() Option {
+	return func(t *Tree) {
+		t.recycle = true
+	}
+}
+
+// newHeapNodes is like the function of the same name, but it lays the merkle
+// nodes out in the memory that the merkle tree has set aside for recycling,
+// if any and if they fit in it, taking it over. Otherwise, it leaves room for
+// a quarter more of them if the merkle tree recycles its nodes, so that the
+// buffer can be recycled as the tree grows.
+//
+// It must only be called while the merkle tree is being mutated, and every
+// digest of the returned heapNodes must be written over.
+func (t *Tree) newHeapNodes(rows []int, size int) *heapNodes {
+	cur, ok := t.store.(*heapNodes)
+	if !t.recycle || t.userStore || !ok || cur.owner != t {
+		return newHeapNodes(rows, size)
+	}
+	numNodes := 0
+	for _, row := range rows {
+		numNodes += row
+	}
+	buf := cur.spare
+	if cap(buf) < numNodes*size {
+		buf = make([]byte, 0, (numNodes+numNodes/4)*size)
+	}
+	cur.spare = nil
+	return newHeapNodesIn(buf, rows, size)
+}
+
+// retireNodes sets the memory of the given old merkle nodes, which the given
+// new ones have replaced, aside for recycling (see RecycleNodes), unless it
+// may be shared with a snapshot of the merkle tree.
+func (t *Tree) retireNodes(old, n *heapNodes) {
+	if !t.recycle {
+		return
+	}
+	n.owner = t
+	if old != nil && old.owner == t && !old.pinned.Load() && n.spare == nil {
+		n.spare = old.digests
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+	"unsafe"
+)
+
+func TestDigestArena00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, InsertionOrder(), DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The digests of the leaves are laid out one after the other.
+	size := len(tree.tls[0].digest)
+	for i := 1; i < len(tree.tls); i++ {
+		prev, cur := unsafe.Pointer(&tree.tls[i-1].digest[0]), unsafe.Pointer(&tree.tls[i].digest[0])
+		if uintptr(cur)-uintptr(prev) != uintptr(size) || cap(tree.tls[i].digest) != size {
+			t.Fatalf("leaf %d: want (contiguous digests of capacity %d); got %d", i, size, cap(tree.tls[i].digest))
+		}
+	}
+
+	var a *digestArena
+	if digest := a.next(); digest != nil {
+		t.Fatalf("want (<nil>); got %v", digest)
+	}
+	a = newDigestArena(4, 1)
+	first, second := a.next(), a.next()
+	if cap(first) != 4 || cap(second) != 4 || len(a.buf) != 4*(arenaChunk-1) {
+		t.Fatalf("want (4, 4, %d); got (%d, %d, %d)", 4*(arenaChunk-1), cap(first), cap(second), len(a.buf))
+	}
+}
+
+func TestRecycleNodes00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:4], InsertionOrder(), RecycleNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffers := make(map[*byte]bool)
+	for i := 4; i < len(grAlphabet); i++ {
+		tree.AppendAndReconstruct(grAlphabet[i])
+		want, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:i+1], InsertionOrder())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("%d leaves: want (%x); got %x", i+1, want.MerkleRoot(), tree.MerkleRoot())
+		}
+		buffers[&tree.store.(*heapNodes).digests[0]] = true
+	}
+	// The merkle nodes settle into a couple of buffers, each of which is
+	// reallocated only as the tree outgrows it.
+	if len(buffers) > 8 {
+		t.Fatalf("want (at most 8) buffers; got %d", len(buffers))
+	}
+	t.Logf("%d buffers for %d reconstructions", len(buffers), len(grAlphabet)-4)
+}
+
+func TestRecycleNodes01(t *testing.T) {
+	// The merkle nodes of snapshots are left intact.
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:8], RecycleNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(grAlphabet[8])
+	snapshot := tree.Snapshot()
+	root := copyBytes(snapshot.MerkleRoot())
+	for _, datum := range grAlphabet[9:] {
+		tree.AppendAndReconstruct(datum)
+	}
+	if !bytes.Equal(snapshot.MerkleRoot(), root) {
+		t.Fatalf("want (%x); got %x", root, snapshot.MerkleRoot())
+	}
+	if v, err := snapshot.VerifyDatum(grAlphabet[3]); !v || err != nil {
+		t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+	}
+
+	// So are the ones of persistent mutations, and of the trees they are
+	// derived from.
+	next, err := tree.Appended(A)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.AppendAndReconstruct(B, C)
+	tree.AppendAndReconstruct(B, C)
+	for _, c := range []struct {
+		tree *Tree
+		data []Datum
+	}{
+		{tree, append(append([]Datum(nil), grAlphabet...), B, C)},
+		{next, append(append([]Datum(nil), grAlphabet...), A, B, C)},
+	} {
+		want, err := NewTree(crypto.SHA256, c.data...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(c.tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", want.MerkleRoot(), c.tree.MerkleRoot())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type (
+	// AuditReport is the outcome of auditing every leaf of a merkle tree
+	// (see AuditAll).
+	AuditReport struct {
+		// NumLeaves is the number of leaves audited.
+		NumLeaves int
+		// Failed holds the leaves that failed to be verified, in
+		// ascending order of their indices.
+		Failed []AuditFailure
+		// Start is the time the audit started at, and Duration is how
+		// long it took.
+		Start    time.Time
+		Duration time.Duration
+	}
+
+	// AuditFailure describes a leaf that failed to be verified against the
+	// merkle root.
+	AuditFailure struct {
+		// Index is the index of the leaf among the (sorted) leaves of
+		// the merkle tree, and OrderedID its ordered ID.
+		Index     int
+		OrderedID uint64
+		// Digest is the digest of the leaf, as stored in the merkle
+		// tree.
+		Digest []byte
+		// Err is the error that the verification returned (e.g. of a
+		// NodeStore given through WithNodeStore), if any; it is nil if
+		// the leaf or its merkle path do not match.
+		Err error
+	}
+)
+
+// OK reports whether every leaf was verified successfully.
+func (r *AuditReport) OK() bool {
+	return len(r.Failed) == 0
+}
+
+// AuditAll verifies every leaf of the merkle tree against its merkle root,
+// rehashing its serialized datum (unless in digest-only mode) and its merkle
+// path, and reports the ones that fail; e.g. as a periodic scrub of merkle
+// trees whose nodes live on disk (see WithNodeStore). The leaves are verified
+// concurrently, by as many goroutines as GOMAXPROCS.
+//
+// It requires O(L*log2(L)) hash calculations. The merkle tree must not be
+// modified in the meantime.
+func (t *Tree) AuditAll() *AuditReport {
+	r := &AuditReport{NumLeaves: len(t.tls), Start: time.Now()}
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(t.tls) {
+		workers = len(t.tls)
+	}
+	var (
+		next int64 = -1
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			var failed []AuditFailure
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(t.tls) {
+					break
+				}
+				if v, err := t.verify(i); !v || err != nil {
+					failed = append(failed, AuditFailure{
+						Index:     i,
+						OrderedID: t.tls[i].orderedID,
+						Digest:    copyBytes(t.tls[i].digest),
+						Err:       err,
+					})
+				}
+			}
+			mu.Lock()
+			r.Failed = append(r.Failed, failed...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	sort.Slice(r.Failed, func(i, j int) bool {
+		return r.Failed[i].Index < r.Failed[j].Index
+	})
+	r.Duration = time.Since(r.Start)
+	return r
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestAuditAll00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := tree.AuditAll(); !r.OK() || r.NumLeaves != len(grAlphabet) {
+		t.Fatalf("want successful audit of %d leaves; got %+v", len(grAlphabet), r)
+	}
+
+	// Corrupting a datum fails its leaf, and a merkle node the leaves
+	// below it and below its sibling.
+	tree.tls[5].datum = []byte("corrupted")
+	tree.store.(*heapNodes).at(1, 6)[0] ^= 0xff // over leaves 12 and 13
+	r := tree.AuditAll()
+	t.Logf("report: %d failed in %v", len(r.Failed), r.Duration)
+	if len(r.Failed) != 5 {
+		t.Fatalf("want 5 failures; got %d", len(r.Failed))
+	}
+	for i, want := range []int{5, 12, 13, 14, 15} {
+		if f := r.Failed[i]; f.Index != want || f.Err != nil || f.OrderedID != tree.tls[want].orderedID {
+			t.Fatalf("want failure of leaf %d; got %+v", want, f)
+		}
+	}
+}
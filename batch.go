@@ -0,0 +1,324 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"hash"
+)
+
+// Batch stages mutations of a merkle tree (see Begin), so that its merkle
+// nodes are reconstructed only once, upon Commit, rather than upon each of
+// them. The leaves of the new data are hashed as soon as they are staged.
+//
+// The merkle tree is left intact until Commit; its merkle root, its proofs
+// etc. reflect none of the staged mutations in the meantime. A Batch is not
+// safe for concurrent use, and it is stale (see ErrStale) once the merkle
+// tree is modified otherwise, or once it has been committed or rolled back.
+type Batch struct {
+	t       *Tree
+	h       hash.Hash
+	version int
+
+	// tls are the leaves of the merkle tree upon Begin, of which the
+	// ones at the indices in deleted are staged for deletion, and added
+	// are the new leaves that are staged for appending.
+	tls     []treeLeaf
+	deleted map[int]bool
+	added   []treeLeaf
+	nextID  uint64
+}
+
+// undoEntry journals the state of a merkle tree before a Batch was committed
+// to it, so that it can be restored by Undo.
+type undoEntry struct {
+	// version is the version of the merkle tree that the Batch resulted
+	// in, i.e. the one that can be undone.
+	version int
+	tls     []treeLeaf
+	// appended and deleted are the numbers of the leaves that the Batch
+	// appended and removed, respectively.
+	appended, deleted int
+}
+
+// Begin returns a new Batch that stages mutations of the merkle tree, to be
+// applied at once upon Commit.
+func (t *Tree) Begin() *Batch {
+	return &Batch{
+		t:       t,
+		h:       t.newHasher(),
+		version: t.version,
+		tls:     t.tls,
+		deleted: make(map[int]bool),
+		nextID:  t.nextID,
+	}
+}
+
+// Len returns the number of leaves that the merkle tree will have once the
+// Batch is committed.
+func (b *Batch) Len() int {
+	return len(b.tls) - len(b.deleted) + len(b.added)
+}
+
+// Append stages the given data to be appended as new leaves of the merkle
+// tree, and returns the ordered IDs that they will be assigned (in the order
+// that they were given in) once the Batch is committed. As with Tree.Append,
+// the data that are already present (among the leaves of the merkle tree that
+// are not staged for deletion, or the ones staged for appending) are skipped
+// if duplicates are ignored (see IgnoreDuplicates), and the ordered IDs of
+// their leaves are returned for them instead.
+//
+// It returns a non-nil error, staging none of them, if no data are given, if
+// any of them is nil, fails to be serialized (see ErrSerializer and
+// StreamSerializer) or is already present while duplicates are rejected (see
+// RejectDuplicates), if the merkle tree cannot hold them (see
+// ErrTooManyLeaves), or if the Batch is stale.
+func (b *Batch) Append(data ...Datum) ([]uint64, error) {
+	if b.version != b.t.version {
+		return nil, ErrStale
+	}
+	if len(data) == 0 {
+		return nil, ErrNoData
+	}
+	if err := checkCapacity(len(b.t.tls)+len(b.added), len(data), b.nextID); err != nil {
+		return nil, dataError("Append", err)
+	}
+	tls := make([]treeLeaf, 0, len(data))
+	ids := make([]uint64, len(data))
+	a := newDigestArena(b.h.Size(), len(data))
+	for i := range data {
+		if data[i] == nil {
+			return nil, ErrNoData
+		}
+		tl, err := b.t.newDatumLeaf(b.h, a, data[i], b.nextID+uint64(len(tls)))
+		if err != nil {
+			return nil, &DataError{Op: "Append", Err: err}
+		}
+		var ok bool
+		if ids[i], ok, err = b.addDuplicate(tls, &tl); err != nil {
+			return nil, dataError("Append", err)
+		} else if ok {
+			tls = append(tls, tl)
+		}
+	}
+	b.added = append(b.added, tls...)
+	b.nextID += uint64(len(tls))
+	return ids, nil
+}
+
+// addDuplicate checks the given leaf against the ones staged so far, as well
+// as the given ones that are being staged along with it, as per the
+// DuplicatePolicy of the merkle tree (see duplicateSet.add).
+func (b *Batch) addDuplicate(staging []treeLeaf, tl *treeLeaf) (uint64, bool, error) {
+	t := b.t
+	if t.duplicates == AllowDuplicates {
+		return tl.orderedID, true, nil
+	}
+	key := t.key(tl)
+	i, added, ok := b.find(key)
+	if !ok {
+		for j := range staging {
+			if bytes.Equal(t.key(&staging[j]), key) {
+				i, added, ok = j, staging, true
+				break
+			}
+		}
+	}
+	switch {
+	case !ok:
+		return tl.orderedID, true, nil
+	case t.duplicates == RejectDuplicates:
+		return 0, false, t.duplicateError(key)
+	}
+	if added == nil {
+		return b.tls[i].orderedID, false, nil
+	}
+	return added[i].orderedID, false, nil
+}
+
+// Delete stages the given data to be deleted from the leaves of the merkle
+// tree (or from the ones staged for appending), and returns the ordered IDs
+// of the leaves that will be removed, in the order that their data were given
+// in.
+//
+// As with Tree.Delete, the data that are not present are skipped, and a
+// non-nil error (wrapping ErrNoData) is returned for the first of them, along
+// with the ordered IDs of the leaves to be removed, if any. It also returns a
+// non-nil error, staging none of them, if no data are given, if any of them is
+// nil or fails to be serialized, or if the Batch is stale.
+func (b *Batch) Delete(data ...Datum) ([]uint64, error) {
+	if b.version != b.t.version {
+		return nil, ErrStale
+	}
+	if len(data) == 0 {
+		return nil, ErrNoData
+	}
+	keys := make([][]byte, len(data))
+	for i := range data {
+		if data[i] == nil {
+			return nil, ErrNoData
+		}
+		var err error
+		if keys[i], err = b.t.datumKey(b.h, data[i]); err != nil {
+			return nil, &DataError{Op: "Delete", Err: err}
+		}
+	}
+	var removed []uint64
+	var missingErr error
+	for _, key := range keys {
+		if id, ok := b.remove(key); ok {
+			removed = append(removed, id)
+		} else if missingErr == nil {
+			missingErr = b.t.keyError("Delete", key)
+		}
+	}
+	return removed, missingErr
+}
+
+// remove stages the leaf of the given key (see leafKey) for deletion, and
+// returns its ordered ID; it reports whether such a leaf is present.
+func (b *Batch) remove(key []byte) (uint64, bool) {
+	i, added, ok := b.find(key)
+	switch {
+	case !ok:
+		return 0, false
+	case added == nil:
+		b.deleted[i] = true
+		return b.tls[i].orderedID, true
+	}
+	id := added[i].orderedID
+	b.added = append(b.added[:i], b.added[i+1:]...)
+	return id, true
+}
+
+// find looks the leaf of the given key (see leafKey) up among the leaves of
+// the merkle tree that are not staged for deletion, and then among the ones
+// staged for appending, in which case it returns the latter along with its
+// index; it reports whether such a leaf is present.
+func (b *Batch) find(key []byte) (int, []treeLeaf, bool) {
+	t := b.t
+	start := 0
+	if !t.insertionOrder {
+		start, _ = t.find(b.tls, key)
+	}
+	for i := start; i < len(b.tls); i++ {
+		k := t.key(&b.tls[i])
+		if !t.insertionOrder && t.lessKeys(key, k) {
+			break
+		}
+		if !b.deleted[i] && bytes.Equal(k, key) {
+			return i, nil, true
+		}
+	}
+	for i := range b.added {
+		if bytes.Equal(t.key(&b.added[i]), key) {
+			return i, b.added, true
+		}
+	}
+	return 0, nil, false
+}
+
+// Commit applies the staged mutations to the merkle tree, reconstructing its
+// merkle nodes once, and returns its new merkle root. The merkle nodes over
+// the leaves that precede the first mutated one are reused. The Batch is
+// stale afterwards.
+//
+// The state of the merkle tree before the Batch was committed is journaled, so
+// that it can be restored by Undo, for as long as the tree is not otherwise
+// modified.
+//
+// It returns a non-nil error, leaving the tree intact, if the Batch is stale,
+// if no leaves would be left, or if the fixed depth of the merkle tree (see
+// FixedDepth) does not allow for them. It also returns the error of a
+// NodeStore given through WithNodeStore, if any, in which case the mutations
+// have been applied but the merkle nodes may not have been stored.
+func (b *Batch) Commit() ([]byte, error) {
+	t := b.t
+	if b.version != t.version {
+		return nil, ErrStale
+	}
+	if len(b.deleted) == 0 && len(b.added) == 0 {
+		b.version = -1
+		return t.MerkleRoot(), nil
+	}
+	if b.Len() == 0 {
+		return nil, ErrNoData
+	}
+	if err := t.checkPadding(b.Len()); err != nil {
+		return nil, err
+	}
+	tls := make([]treeLeaf, 0, b.Len())
+	for i := range b.tls {
+		if !b.deleted[i] {
+			tls = append(tls, b.tls[i])
+		}
+	}
+	tls = append(tls, b.added...)
+	t.sortTreeLeaves(tls)
+
+	t.pushVersion()
+	t.undo = &undoEntry{version: t.version, tls: t.tls, appended: len(b.added), deleted: len(b.deleted)}
+	unchanged := t.unchangedLeaves(tls)
+	t.beginMutation("Commit", len(b.added), len(b.deleted))
+	t.tls, t.nextID = tls, b.nextID
+	b.version = -1
+	t.beginProgress(0, len(tls))
+	defer t.endProgress()
+	if err := t.setNodes(t.reconstructMerkleNodes(b.h, tls, unchanged)); err != nil {
+		return nil, err
+	}
+	return t.MerkleRoot(), nil
+}
+
+// Rollback discards the staged mutations, leaving the merkle tree intact. The
+// Batch is stale afterwards.
+func (b *Batch) Rollback() {
+	b.deleted, b.added, b.nextID = nil, nil, b.t.nextID
+	b.version = -1
+}
+
+// Undo reverts the merkle tree to its state before the last Batch committed
+// to it, provided that it has not been modified otherwise since, and returns
+// its merkle root; e.g. when a downstream write that depended on the Batch
+// fails. Undoing is a mutation of the merkle tree too (see Version); it does
+// not restore the NextID, so that the ordered IDs of the undone leaves are
+// not reassigned.
+//
+// It returns a non-nil error, leaving the tree intact, if no Batch has been
+// committed to it, if it has already been undone, or if the tree has been
+// modified since. It also returns the error of a NodeStore given through
+// WithNodeStore, if any, in which case the merkle tree has been reverted but
+// its merkle nodes may not have been stored.
+func (t *Tree) Undo() ([]byte, error) {
+	if t.undo == nil {
+		return nil, ErrNoData
+	}
+	if t.undo.version != t.version {
+		return nil, ErrStale
+	}
+	tls, undo := t.undo.tls, t.undo
+	t.undo = nil
+	h := t.newHasher()
+	t.pushVersion()
+	unchanged := t.unchangedLeaves(tls)
+	t.beginMutation("Undo", undo.deleted, undo.appended)
+	t.tls = tls
+	t.beginProgress(0, len(tls))
+	defer t.endProgress()
+	if err := t.setNodes(t.reconstructMerkleNodes(h, tls, unchanged)); err != nil {
+		return nil, err
+	}
+	return t.MerkleRoot(), nil
+}
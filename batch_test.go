@@ -0,0 +1,141 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestBatch00(t *testing.T) {
+	for _, opts := range [][]Option{nil, {InsertionOrder()}, {DigestOnly()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := tree.Clone()
+		root := tree.MerkleRoot()
+
+		b := tree.Begin()
+		ids, err := b.Append(grAlphabet[10], grAlphabet[11], grAlphabet[12])
+		if err != nil || len(ids) != 3 || ids[0] != 10 {
+			t.Fatalf("want ([10 11 12], <nil>); got (%v, %v)", ids, err)
+		}
+		removed, err := b.Delete(grAlphabet[3], grAlphabet[11], grAlphabet[20])
+		if !errors.Is(err, ErrNoData) || len(removed) != 2 || removed[0] != 3 || removed[1] != 11 {
+			t.Fatalf("want ([3 11], %v); got (%v, %v)", ErrNoData, removed, err)
+		}
+		if !bytes.Equal(root, tree.MerkleRoot()) || b.Len() != 11 {
+			t.Fatalf("want intact tree and 11 staged leaves; got %x and %d", tree.MerkleRoot(), b.Len())
+		}
+		newRoot, err := b.Commit()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want.AppendAndReconstruct(grAlphabet[10], grAlphabet[11], grAlphabet[12])
+		want.DeleteAndReconstruct(grAlphabet[3], grAlphabet[11])
+		t.Logf("newRoot: %x", newRoot)
+		if !bytes.Equal(newRoot, want.MerkleRoot()) || !bytes.Equal(newRoot, tree.MerkleRoot()) {
+			t.Fatalf("want root (%x); got %x", want.MerkleRoot(), newRoot)
+		}
+		if tree.Version() != 1 || tree.NextID() != 13 {
+			t.Fatalf("want (1, 13); got (%d, %d)", tree.Version(), tree.NextID())
+		}
+		if v, err := tree.VerifyDatum(grAlphabet[12]); err != nil || !v {
+			t.Fatalf("ERROR while verifying %v: (%v, %v)", grAlphabet[12], v, err)
+		}
+		if _, err := b.Commit(); !errors.Is(err, ErrStale) {
+			t.Fatalf("want (%v); got %v", ErrStale, err)
+		}
+	}
+}
+
+func TestBatch01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:4]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := tree.Begin()
+	if _, err := b.Delete(grAlphabet[:4]...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Commit(); !errors.Is(err, ErrNoData) || tree.NumLeaves() != 4 {
+		t.Fatalf("want (%v) and 4 leaves; got %v and %d", ErrNoData, err, tree.NumLeaves())
+	}
+	tree.AppendAndReconstruct(grAlphabet[4])
+	if _, err := b.Append(grAlphabet[5]); !errors.Is(err, ErrStale) {
+		t.Fatalf("want (%v); got %v", ErrStale, err)
+	}
+}
+
+func TestBatch02(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:8]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+	if _, err := tree.Undo(); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+
+	b := tree.Begin()
+	if _, err := b.Append(grAlphabet[8]); err != nil {
+		t.Fatal(err)
+	}
+	b.Rollback()
+	if _, err := b.Commit(); !errors.Is(err, ErrStale) || !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want (%v) and intact tree; got %v", ErrStale, err)
+	}
+
+	b = tree.Begin()
+	if _, err := b.Append(grAlphabet[8], grAlphabet[9]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Delete(grAlphabet[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	undone, err := tree.Undo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("undone: %x", undone)
+	if !bytes.Equal(root, undone) || !bytes.Equal(root, tree.MerkleRoot()) || tree.NumLeaves() != 8 {
+		t.Fatalf("want root (%x); got %x", root, undone)
+	}
+	if tree.NextID() != 10 || tree.Version() != 2 {
+		t.Fatalf("want (10, 2); got (%d, %d)", tree.NextID(), tree.Version())
+	}
+	if _, err := tree.Undo(); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+
+	b = tree.Begin()
+	if _, err := b.Append(grAlphabet[10]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(grAlphabet[11])
+	if _, err := tree.Undo(); !errors.Is(err, ErrStale) {
+		t.Fatalf("want (%v); got %v", ErrStale, err)
+	}
+}
@@ -0,0 +1,496 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// binaryVersion is the version of the binary encoding of the merkle tree,
+// which is written right after binaryMagic. The encodings of binaryVersionExt
+// follow the flags with a byte of extended flags, and are only produced for
+// merkle trees that call for the latter.
+const (
+	binaryVersion    byte = 1
+	binaryVersionExt byte = 2
+)
+
+const (
+	binaryFlagDigestOnly byte = 1 << iota
+	binaryFlagNodes
+	binaryFlagInsertionOrder
+	binaryFlagRFC6962
+	binaryFlagArity
+	binaryFlagPadding
+	binaryFlagPadded
+	binaryFlagSortedPairs
+)
+
+const (
+	binaryFlagExtLengthPrefixed byte = 1 << iota
+	binaryFlagExtNextID
+	binaryFlagExtDigestSize
+	binaryFlagExtCompressed
+)
+
+// binaryMagic prefixes every binary encoding of a merkle tree.
+var binaryMagic = []byte("MRKL")
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The binary encoding is versioned and deterministic; it comprises the hash
+// function, the leaves (digests, ordered IDs and, unless in digest-only mode,
+// serialized data) and the merkle nodes above them, so that decoding it does
+// not require any hash calculations. The NextID of the merkle tree is only
+// included if it does not follow the greatest ordered ID of its leaves. The
+// data are kept compressed if the merkle tree keeps them compressed with an
+// AlgorithmCompressor (see WithCompressor).
+//
+// It returns a non-nil error if the hash function of the merkle tree was
+// given through WithHashFunc, or if its leaves are keyed (see WithLeafKey).
+func (t *Tree) MarshalBinary() ([]byte, error) {
+	return t.appendBinary(nil, true)
+}
+
+// MarshalBinaryCompact is like MarshalBinary, but it omits the merkle nodes,
+// which are then reconstructed from the leaves when the tree is decoded.
+func (t *Tree) MarshalBinaryCompact() ([]byte, error) {
+	return t.appendBinary(nil, false)
+}
+
+func (t *Tree) appendBinary(b []byte, withNodes bool) ([]byte, error) {
+	if !t.scheme.encodable() {
+		return nil, ErrUnsupported
+	}
+	var flags byte
+	if t.digestOnly {
+		flags |= binaryFlagDigestOnly
+	}
+	if withNodes {
+		flags |= binaryFlagNodes
+	}
+	if t.encodedInsertionOrder() {
+		flags |= binaryFlagInsertionOrder
+	}
+	flags |= t.scheme.binaryFlags()
+	var ext byte
+	if t.nextID != impliedNextID(t.tls) {
+		ext |= binaryFlagExtNextID
+	}
+	compressor := t.encodedCompressor()
+	if compressor != nil {
+		ext |= binaryFlagExtCompressed
+	}
+	b = append(b, binaryMagic...)
+	b = t.scheme.appendBinaryFlags(b, flags, ext)
+	b = binary.AppendUvarint(b, uint64(t.hash))
+	b = t.scheme.appendBinary(b)
+	if ext&binaryFlagExtNextID != 0 {
+		b = binary.AppendUvarint(b, t.nextID)
+	}
+	if compressor != nil {
+		algorithm, level := compressor.Algorithm()
+		b = binary.AppendUvarint(b, uint64(algorithm))
+		b = binary.AppendVarint(b, int64(level))
+	}
+	b = binary.AppendUvarint(b, uint64(len(t.tls)))
+	for i := range t.tls {
+		b = binary.AppendUvarint(b, t.tls[i].orderedID)
+		b = append(b, t.tls[i].digest...)
+		if !t.digestOnly {
+			datum := t.tls[i].datum
+			if compressor == nil {
+				datum = t.leafDatum(&t.tls[i])
+			}
+			b = binary.AppendUvarint(b, uint64(len(datum)))
+			b = append(b, datum...)
+		}
+	}
+	if withNodes {
+		for height := len(t.rows); height > 0; height-- {
+			for index := 0; index < t.rows[height-1]; index++ {
+				digest, err := t.node(height, index)
+				if err != nil {
+					return nil, err
+				}
+				b = append(b, digest...)
+			}
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+//
+// It accepts the encodings produced by both MarshalBinary and
+// MarshalBinaryCompact, overwriting the receiver's contents.
+//
+// It returns a non-nil error if the given data are not a valid encoding of a
+// merkle tree, if the hash function they were produced with has not been
+// linked into the binary, or if the CompressionAlgorithm that their data are
+// compressed with has not been registered (see RegisterCompressor).
+func (t *Tree) UnmarshalBinary(data []byte) error {
+	d := binaryDecoder{buf: data}
+	if string(d.next(len(binaryMagic))) != string(binaryMagic) {
+		return ErrInvalidEncoding
+	}
+	flags, ext := d.flags()
+	hash := crypto.Hash(d.uvarint())
+	if d.err || hash == 0 || hash >= maxHash {
+		return ErrInvalidEncoding
+	}
+	if !hash.Available() {
+		return &HashError{Hash: hash}
+	}
+	t2 := &Tree{
+		hash:           hash,
+		digestOnly:     flags&binaryFlagDigestOnly != 0,
+		insertionOrder: flags&binaryFlagInsertionOrder != 0,
+	}
+	if !d.scheme(&t2.scheme, flags, ext, len(data)) {
+		return ErrInvalidEncoding
+	}
+	var nextID uint64
+	if ext&binaryFlagExtNextID != 0 {
+		nextID = d.uvarint()
+	}
+	if ext&binaryFlagExtCompressed != 0 {
+		algorithm := CompressionAlgorithm(d.uvarint())
+		level := d.varint()
+		if d.err || t2.digestOnly || algorithm == 0 || algorithm >= maxCompressionAlgorithm {
+			return ErrInvalidEncoding
+		}
+		newCompressor := algorithm.constructor()
+		if newCompressor == nil {
+			return ErrCompressorUnavailable
+		}
+		t2.compressor = newCompressor(int(level))
+	}
+	h := t2.newHasher()
+	numLeaves := d.uvarint()
+	if d.err || numLeaves == 0 || numLeaves > uint64(len(d.buf)) || t2.checkPadding(int(numLeaves)) != nil {
+		return ErrInvalidEncoding
+	}
+
+	tls := make([]treeLeaf, numLeaves)
+	for i := range tls {
+		tls[i].orderedID = d.uvarint()
+		tls[i].digest = d.next(h.Size())
+		if flags&binaryFlagDigestOnly == 0 {
+			tls[i].datum = d.next(d.int())
+		}
+	}
+	if d.err || !validIDs(tls) {
+		return ErrInvalidEncoding
+	}
+	t2.nextID = impliedNextID(tls)
+	if ext&binaryFlagExtNextID != 0 {
+		if nextID < t2.nextID {
+			return ErrInvalidEncoding
+		}
+		t2.nextID = nextID
+	}
+
+	var n *heapNodes
+	if flags&binaryFlagNodes != 0 {
+		// The merkle nodes are encoded in the layout of heapNodes.
+		_, rowSizes := t2.merkleNumbers(len(tls))
+		n = newHeapNodes(rowSizes, h.Size())
+		n.digests = d.next(len(n.digests))
+		if d.err {
+			return ErrInvalidEncoding
+		}
+	} else {
+		n = t2.constructMerkleNodes(h, tls)
+	}
+	if len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+
+	t2.tls = tls
+	t2.setNodes(n)
+	*t = *t2
+	return nil
+}
+
+// WriteTo implements the io.WriterTo interface, writing the binary encoding
+// of the merkle tree (as returned by MarshalBinary) to w; e.g. to back it up
+// or to transfer it in a single call. It can be read back by ReadTreeFrom.
+//
+// It returns the number of bytes written, and a non-nil error if the merkle
+// tree cannot be encoded (see MarshalBinary) or if writing to w fails.
+func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// WriteCompressedTo is like WriteTo, but the binary encoding is compressed
+// with gzip at the given level (see compress/gzip); ReadTreeFrom detects the
+// compression by itself.
+//
+// It returns the number of (compressed) bytes written, and a non-nil error if
+// the level is invalid as well.
+func (t *Tree) WriteCompressedTo(w io.Writer, level int) (int64, error) {
+	b, err := t.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	cw := &countingWriter{w: w}
+	zw, err := gzip.NewWriterLevel(cw, level)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := zw.Write(b); err != nil {
+		return cw.n, err
+	}
+	err = zw.Close()
+	return cw.n, err
+}
+
+// ReadTreeFrom reads the binary encoding of a merkle tree (as written by
+// WriteTo, or by WriteCompressedTo) from r until EOF, and decodes it (see
+// UnmarshalBinary).
+//
+// It returns a non-nil error if reading from r fails, if the data read are
+// not a valid (optionally compressed) encoding of a merkle tree, or if the
+// hash function they were produced with has not been linked into the binary.
+func ReadTreeFrom(r io.Reader) (*Tree, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && bytes.Equal(magic, gzipMagic) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tree{}
+	if err := t.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// gzipMagic prefixes every gzip stream (see RFC 1952).
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// countingWriter counts the bytes written to the underlying io.Writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// binaryFlags returns the flags of the binary encodings that describe the
+// scheme.
+func (s *scheme) binaryFlags() byte {
+	var flags byte
+	if s.isRFC6962() {
+		flags |= binaryFlagRFC6962
+	}
+	if s.arity > 2 {
+		flags |= binaryFlagArity
+	}
+	if s.padding != s.impliedPadding() {
+		flags |= binaryFlagPadding
+	}
+	if s.padded {
+		flags |= binaryFlagPadded
+	}
+	if s.sortedPairs {
+		flags |= binaryFlagSortedPairs
+	}
+	return flags
+}
+
+// appendBinaryFlags appends the version of the binary encoding and the given
+// flags to b, along with the given extended flags and the ones of the scheme,
+// if any.
+func (s *scheme) appendBinaryFlags(b []byte, flags, ext byte) []byte {
+	if s.lengthPrefixed {
+		ext |= binaryFlagExtLengthPrefixed
+	}
+	if s.digestSize != 0 {
+		ext |= binaryFlagExtDigestSize
+	}
+	if ext == 0 {
+		return append(b, binaryVersion, flags)
+	}
+	return append(b, binaryVersionExt, flags, ext)
+}
+
+// appendBinary appends the parameters of the scheme that its binaryFlags
+// call for to b.
+func (s *scheme) appendBinary(b []byte) []byte {
+	if s.arity > 2 {
+		b = binary.AppendUvarint(b, uint64(s.arity))
+	}
+	if s.padding != s.impliedPadding() {
+		b = binary.AppendUvarint(b, uint64(s.padding))
+	}
+	if s.padded {
+		b = binary.AppendUvarint(b, uint64(s.depth))
+		b = binary.AppendUvarint(b, uint64(len(s.emptyLeaf)))
+		b = append(b, s.emptyLeaf...)
+	}
+	if s.digestSize != 0 {
+		b = binary.AppendUvarint(b, uint64(s.digestSize))
+	}
+	return b
+}
+
+// maxHash is the upper bound of the crypto.Hash values known to the standard
+// library.
+const maxHash = crypto.BLAKE2b_512 + 1
+
+// binaryDecoder consumes a byte slice, copying out of it whatever it reads so
+// that the decoded tree does not alias the caller's buffer. Once it fails, it
+// sets err and keeps returning zero values.
+type binaryDecoder struct {
+	buf []byte
+	err bool
+}
+
+// flags decodes the version of the binary encoding and its flags (as appended
+// by scheme.appendBinaryFlags); an unknown version fails the binaryDecoder.
+func (d *binaryDecoder) flags() (flags, ext byte) {
+	switch d.byte() {
+	case binaryVersion:
+		return d.byte(), 0
+	case binaryVersionExt:
+		return d.byte(), d.byte()
+	}
+	d.err = true
+	return 0, 0
+}
+
+// scheme decodes the scheme that the given flags describe (as appended by
+// scheme.appendBinary) into s, given the size of the whole encoding; it
+// reports whether it is valid.
+func (d *binaryDecoder) scheme(s *scheme, flags, ext byte, size int) bool {
+	if flags&binaryFlagRFC6962 != 0 {
+		*s = rfc6962Scheme
+	}
+	if flags&binaryFlagArity != 0 {
+		arity := d.uvarint()
+		if d.err || arity <= 2 || arity > uint64(size) {
+			return false
+		}
+		s.arity = int(arity)
+	}
+	if flags&binaryFlagPadding != 0 {
+		padding := d.uvarint()
+		if d.err || padding >= uint64(numPaddingPolicies) {
+			return false
+		}
+		s.padding = PaddingPolicy(padding)
+	}
+	if flags&binaryFlagPadded != 0 {
+		depth := d.uvarint()
+		if d.err || depth >= 64 {
+			return false
+		}
+		s.padded, s.depth = true, int(depth)
+		if emptyLeaf := d.next(d.int()); len(emptyLeaf) != 0 {
+			s.emptyLeaf = emptyLeaf
+		}
+	}
+	s.sortedPairs = flags&binaryFlagSortedPairs != 0
+	s.lengthPrefixed = ext&binaryFlagExtLengthPrefixed != 0
+	if ext&binaryFlagExtDigestSize != 0 {
+		digestSize := d.uvarint()
+		if d.err || digestSize == 0 || digestSize > uint64(size) {
+			return false
+		}
+		s.digestSize = int(digestSize)
+	}
+	return !d.err
+}
+
+func (d *binaryDecoder) next(n int) []byte {
+	if d.err || n < 0 || n > len(d.buf) {
+		d.err = true
+		return nil
+	}
+	ret := make([]byte, n)
+	copy(ret, d.buf[:n])
+	d.buf = d.buf[n:]
+	return ret
+}
+
+func (d *binaryDecoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// int is like uvarint, but for lengths, counts and indices, which must fit in
+// an int on the platform at hand, rather than silently overflow it.
+func (d *binaryDecoder) int() int {
+	v := d.uvarint()
+	if v > math.MaxInt {
+		d.err = true
+		return 0
+	}
+	return int(v)
+}
+
+func (d *binaryDecoder) varint() int64 {
+	if d.err {
+		return 0
+	}
+	v, n := binary.Varint(d.buf)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *binaryDecoder) uvarint() uint64 {
+	if d.err {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
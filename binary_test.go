@@ -0,0 +1,147 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestBinary00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.DeleteAndReconstruct(grAlphabet[20:]...)
+
+	full, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := tree.MarshalBinaryCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("len(full) = %d, len(compact) = %d", len(full), len(compact))
+
+	for _, data := range [][]byte{full, compact} {
+		var tree2 Tree
+		if err = tree2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+		}
+		if tree.Size() != tree2.Size() {
+			t.Fatalf("want size %d; got %d", tree.Size(), tree2.Size())
+		}
+		leaves, leaves2 := tree.Leaves(), tree2.Leaves()
+		for i := range leaves {
+			if !bytes.Equal(leaves[i], leaves2[i]) {
+				t.Fatalf("leaf %d: want %q; got %q", i, leaves[i], leaves2[i])
+			}
+		}
+		if data2, _ := tree2.MarshalBinary(); !bytes.Equal(full, data2) {
+			t.Fatalf("re-encoding is not deterministic")
+		}
+	}
+}
+func TestBinary01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := tree.MarshalBinaryCompact()
+	var tree2 Tree
+	if err = tree2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	if v, err := tree2.VerifyDatum(Q); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", Q, v, err)
+	}
+
+	for _, bad := range [][]byte{nil, data[:len(data)-1], append(data, 0), []byte("MRKL\x02")} {
+		if err = tree2.UnmarshalBinary(bad); err == nil {
+			t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+		}
+		t.Logf("got (%v), as expected", err)
+	}
+}
+func TestBinary02(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	encode := []func() ([]byte, error){tree.MarshalBinaryCompact, tree.MarshalJSON, tree.MarshalCBOR}
+	decode := []func(*Tree, []byte) error{(*Tree).UnmarshalBinary, (*Tree).UnmarshalJSON, (*Tree).UnmarshalCBOR}
+	for i := range encode {
+		data, err := encode[i]()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tree2 Tree
+		if err = decode[i](&tree2, data); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+		}
+		if !tree2.insertionOrder || !tree2.scheme.isRFC6962() {
+			t.Fatalf("want an RFC 6962 tree; got %+v", tree2.scheme)
+		}
+	}
+}
+
+func TestWriteTo00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithArity(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, compressed := range []bool{false, true} {
+		var buf bytes.Buffer
+		var n int64
+		if compressed {
+			n, err = tree.WriteCompressedTo(&buf, gzip.BestCompression)
+		} else {
+			n, err = tree.WriteTo(&buf)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("compressed: %t, %d bytes", compressed, n)
+		if n != int64(buf.Len()) {
+			t.Fatalf("want (%d); got %d", buf.Len(), n)
+		}
+		tree2, err := ReadTreeFrom(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) || tree2.scheme.arity != 3 {
+			t.Fatalf("want root (%x); got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+		}
+	}
+	if _, err := ReadTreeFrom(bytes.NewReader([]byte{0x1f, 0x8b, 0})); err == nil {
+		t.Fatal("want non-nil error for a truncated gzip stream; got <nil>")
+	}
+	if _, err := ReadTreeFrom(bytes.NewReader(nil)); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+}
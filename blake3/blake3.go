@@ -0,0 +1,123 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package blake3 configures merkle trees to hash with BLAKE3, and verifies
+// streams of bytes against their BLAKE3 digests incrementally, the way Bao
+// does.
+//
+// BLAKE3 is itself a merkle tree, over chunks of 1 KiB; Bao encodes the
+// digests of its inner nodes alongside the data ("outboard"), so that a
+// receiver holding only the (32-byte) digest of a file can verify its bytes
+// as they are read, rather than after reading all of them. Verification
+// stops at the first corrupted chunk, and no unverified byte is ever passed
+// on.
+package blake3
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+
+	lblake3 "lukechampine.com/blake3"
+	"lukechampine.com/blake3/bao"
+
+	"github.com/ckatsak/merkle"
+)
+
+// ErrCorrupted signifies that the data (or their outboard encoding) do not
+// match the given BLAKE3 digest.
+var ErrCorrupted = errors.New("blake3: corrupted data")
+
+// Size is the size of the BLAKE3 digests that merkle trees hash with.
+const Size = 32
+
+// New returns a new hash.Hash computing BLAKE3 digests of Size bytes.
+func New() hash.Hash {
+	return lblake3.New(Size, nil)
+}
+
+// Option configures the merkle tree to hash with BLAKE3 (see
+// merkle.WithHashFunc).
+func Option() merkle.Option {
+	return merkle.WithHashFunc("BLAKE3", New)
+}
+
+// Sum256 returns the BLAKE3 digest of the given data, i.e. the root that
+// they are verified against.
+func Sum256(data []byte) [Size]byte {
+	return lblake3.Sum256(data)
+}
+
+// Encode reads size bytes from r and returns their outboard Bao encoding,
+// i.e. the digests of the inner nodes of their BLAKE3 tree, along with their
+// BLAKE3 digest.
+//
+// It returns a non-nil error if reading from r fails.
+func Encode(r io.Reader, size int64) (outboard []byte, root [Size]byte, err error) {
+	buf := &bufferAt{buf: make([]byte, bao.EncodedSize(int(size), 0, true))}
+	if root, err = bao.Encode(buf, r, size, 0, true); err != nil {
+		return nil, root, err
+	}
+	return buf.buf, root, nil
+}
+
+// Verify streams the bytes read from data to dst, as long as they are
+// verified against the given BLAKE3 digest through the given outboard
+// encoding (as returned by Encode), chunk by chunk.
+//
+// It returns ErrCorrupted upon the first chunk that fails verification,
+// having written to dst only the ones preceding it, or a non-nil error if
+// reading from data or outboard or writing to dst fails.
+func Verify(dst io.Writer, data, outboard io.Reader, root [Size]byte) error {
+	ok, err := bao.Decode(dst, data, outboard, 0, root)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCorrupted
+	}
+	return nil
+}
+
+// NewReader returns an io.ReadCloser that reads the bytes of data as long as
+// they are verified against the given BLAKE3 digest through the given
+// outboard encoding (as returned by Encode); reading fails with ErrCorrupted
+// upon the first chunk that fails verification. It must be closed if not
+// read until EOF.
+func NewReader(data, outboard io.Reader, root [Size]byte) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(Verify(pw, data, outboard, root))
+	}()
+	return pr
+}
+
+// VerifyBytes reports whether the given data match the given BLAKE3 digest,
+// given their outboard encoding (as returned by Encode).
+func VerifyBytes(data, outboard []byte, root [Size]byte) bool {
+	return Verify(io.Discard, bytes.NewReader(data), bytes.NewReader(outboard), root) == nil
+}
+
+// bufferAt is an io.WriterAt over a preallocated buffer.
+type bufferAt struct {
+	buf []byte
+}
+
+func (b *bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	if off < 0 || off+int64(len(p)) > int64(len(b.buf)) {
+		return 0, io.ErrShortWrite
+	}
+	return copy(b.buf[off:], p), nil
+}
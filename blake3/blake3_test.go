@@ -0,0 +1,92 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package blake3
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+func TestSum25600(t *testing.T) {
+	const want = "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"
+	if got := Sum256(nil); hex.EncodeToString(got[:]) != want {
+		t.Fatalf("want (%s); got %x", want, got)
+	}
+}
+
+func TestOption00(t *testing.T) {
+	data := []merkle.Datum{
+		merkle.StringDatum("alpha"),
+		merkle.StringDatum("beta"),
+		merkle.StringDatum("gamma"),
+	}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, Option())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+	for i := range data {
+		p, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(tree.MerkleRoot()) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+}
+
+func TestVerify00(t *testing.T) {
+	data := make([]byte, 10<<10)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	outboard, root, err := Encode(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != Sum256(data) {
+		t.Fatalf("want root (%x); got %x", Sum256(data), root)
+	}
+	if !VerifyBytes(data, outboard, root) {
+		t.Fatal("data do not verify")
+	}
+	got, err := io.ReadAll(NewReader(bytes.NewReader(data), bytes.NewReader(outboard), root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("read data differ")
+	}
+
+	// Corrupting the fifth chunk lets only the first four through.
+	data[5000] ^= 1
+	r := NewReader(bytes.NewReader(data), bytes.NewReader(outboard), root)
+	got, err = io.ReadAll(r)
+	if err != ErrCorrupted {
+		t.Fatalf("want (%v); got %v", ErrCorrupted, err)
+	}
+	if len(got) != 4<<10 {
+		t.Fatalf("want (%d) bytes; got %d", 4<<10, len(got))
+	}
+	if VerifyBytes(data, outboard, root) {
+		t.Fatal("corrupted data verify")
+	}
+}
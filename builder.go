@@ -0,0 +1,153 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"hash"
+	"os"
+)
+
+// Builder constructs a merkle tree incrementally, hashing each piece of data
+// as soon as it is added, so that the data need not be materialized in a
+// slice first.
+//
+// Combined with the DigestOnly Option, a Builder retains nothing but a single
+// digest per piece of data added.
+type Builder struct {
+	t     *Tree
+	h     hash.Hash
+	arena *digestArena
+	tls   []treeLeaf
+	dups  *duplicateSet
+	// mem is the approximate memory that tls take, and runs hold the
+	// spilled leaves, if any (see SpillToDisk).
+	mem     int
+	spilled int
+	runs    []*os.File
+}
+
+// NewBuilder creates a new Builder given one of the available (i.e. linked
+// into the binary) hash functions and any number of Options to configure the
+// merkle tree to be built.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary.
+func NewBuilder(hash crypto.Hash, opts ...Option) (*Builder, error) {
+	t := &Tree{hash: hash}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if !t.hashAvailable() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	if err := t.checkPadding(0); err != nil {
+		return nil, err
+	}
+	h := t.newHasher()
+	return &Builder{t: t, h: h, arena: newDigestArena(h.Size(), 0), dups: t.newDuplicateSet(nil)}, nil
+}
+
+// Add hashes the given Datum and adds it as a new leaf of the merkle tree to
+// be built, unless it has already been added while duplicates are ignored
+// (see IgnoreDuplicates).
+//
+// It returns a non-nil error if the given Datum is nil, fails to be
+// serialized (see ErrSerializer and StreamSerializer), or has already been
+// added while duplicates are rejected (see RejectDuplicates). It also returns
+// a non-nil error, though the Datum has been added, if the leaves fail to be
+// spilled to disk (see SpillToDisk).
+func (b *Builder) Add(datum Datum) error {
+	if datum == nil {
+		return ErrNoData
+	}
+	tl, err := b.t.newDatumLeaf(b.h, b.arena, datum, uint64(b.Len()))
+	if err != nil {
+		return &DataError{Op: "Add", Err: err}
+	}
+	return b.add("Add", tl)
+}
+
+// AddBytes hashes the given serialized datum and adds it as a new leaf of the
+// merkle tree to be built.
+//
+// Unless in digest-only mode, the Builder retains the given slice, which must
+// therefore not be modified afterwards. As with Add, it returns a non-nil
+// error if it has already been added while duplicates are rejected, or if the
+// leaves fail to be spilled to disk.
+func (b *Builder) AddBytes(serializedDatum []byte) error {
+	return b.add("AddBytes", b.t.newTreeLeaf(b.h, b.arena, serializedDatum, uint64(b.Len())))
+}
+
+// add adds the given leaf to the merkle tree to be built, as per its
+// DuplicatePolicy.
+func (b *Builder) add(op string, tl treeLeaf) error {
+	if err := checkCapacity(b.Len(), 1, tl.orderedID); err != nil {
+		return dataError(op, err)
+	}
+	if _, ok, err := b.dups.add(&tl); err != nil {
+		return dataError(op, err)
+	} else if ok {
+		b.tls = append(b.tls, tl)
+		b.mem += leafFootprint(&tl)
+	}
+	return b.spill()
+}
+
+// Len returns the number of leaves added to the Builder so far, including the
+// ones spilled to disk (see SpillToDisk).
+func (b *Builder) Len() int {
+	return b.spilled + len(b.tls)
+}
+
+// Build constructs the merkle tree on top of the leaves added so far, and
+// resets the Builder so that it can be reused.
+//
+// Since the leaves are hashed as soon as they are added, a callback set by
+// WithProgress is only informed of the construction of the merkle nodes.
+//
+// If leaves have been spilled to disk (see SpillToDisk), they are merged back
+// into memory; see BuildFlat to avoid that.
+//
+// It returns a non-nil error if no data have been added at all, if more data
+// have been added than the fixed depth of the merkle tree (see FixedDepth)
+// allows for, or if the leaves spilled to disk fail to be read.
+func (b *Builder) Build() (*Tree, error) {
+	if b.Len() == 0 {
+		return nil, ErrNoData
+	}
+	if err := b.t.checkPadding(b.Len()); err != nil {
+		return nil, err
+	}
+	t := *b.t
+	if len(b.runs) == 0 {
+		t.tls = b.tls
+		t.sortTreeLeaves(t.tls)
+	} else if tls, err := b.mergeRuns(); err != nil {
+		b.reset()
+		return nil, err
+	} else {
+		t.tls = tls
+	}
+	b.reset()
+	t.nextID = uint64(len(t.tls))
+	t.beginProgress(0, len(t.tls))
+	err := t.setNodes(t.constructMerkleNodes(b.h, t.tls))
+	t.endProgress()
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
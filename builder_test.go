@@ -0,0 +1,74 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func TestBuilder00(t *testing.T) {
+	if _, err := NewBuilder(crypto.SHA512); err == nil {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+	b, err := NewBuilder(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.Build(); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if err = b.Add(nil); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+func TestBuilder01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := NewBuilder(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, datum := range grAlphabet {
+		if i%2 == 0 {
+			err = b.Add(datum)
+		} else {
+			err = b.AddBytes(datum.Serialize())
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree2, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Fatalf("want an empty Builder after Build; got %d leaves", b.Len())
+	}
+	if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	leaves, leaves2 := tree.Leaves(), tree2.Leaves()
+	for i := range leaves {
+		if !bytes.Equal(leaves[i], leaves2[i]) {
+			t.Fatalf("leaf %d: want %q; got %q", i, leaves[i], leaves2[i])
+		}
+	}
+}
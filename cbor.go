@@ -0,0 +1,547 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"encoding/binary"
+	"math"
+)
+
+// CBOR major types and simple values used by the CBOR encodings of trees and
+// proofs.
+const (
+	cborUint  byte = 0 << 5
+	cborBytes byte = 2 << 5
+	cborArray byte = 4 << 5
+	cborMap   byte = 5 << 5
+	cborFalse byte = 7<<5 | 20
+	cborTrue  byte = 7<<5 | 21
+)
+
+// Keys of the CBOR maps that trees and proofs are encoded as. Small integer
+// keys keep the encodings compact for constrained devices.
+const (
+	cborKeyHash = 1 + iota
+	cborKeyDigestOnly
+	cborKeyLeaves
+	cborKeyInsertionOrder
+	cborKeyRFC6962
+	cborKeyArity
+	cborKeyPadding
+	cborKeyPadded
+	cborKeySortedPairs
+	cborKeyLengthPrefixed
+	cborKeyNextID
+	cborKeyDigestSize
+)
+
+const (
+	cborKeyProofHash = 1 + iota
+	cborKeyProofLeafIndex
+	cborKeyProofNumLeaves
+	cborKeyProofLeafDigest
+	cborKeyProofSiblings
+	cborKeyProofDigestSize
+	cborKeyProofRFC6962
+	cborKeyProofArity
+	cborKeyProofPadding
+	cborKeyProofSortedPairs
+)
+
+// MarshalCBOR returns the CBOR (RFC 8949) encoding of the merkle tree.
+//
+// The tree is encoded as a map with integer keys: 1 holds the crypto.Hash
+// value of the hash function, 2 whether the tree is in digest-only mode, and
+// 3 an array of leaves, each of which is an array of its ordered ID, its
+// digest and (unless in digest-only mode) its serialized datum. Keys 4 and 5,
+// which are only present when true, hold whether the leaves are kept in
+// insertion order and whether the tree hashes as per RFC 6962, respectively;
+// key 6, which is only present for arities greater than 2, holds the arity,
+// and key 7, which is only present for PaddingPolicies other than the one
+// implied by key 5, holds the PaddingPolicy. Key 8, which is only present
+// for padded trees (see PadToPowerOfTwo), holds an array of their fixed depth
+// (or 0) and the digest of their empty leaves (or an empty byte string).
+// Keys 9 and 10, which are only present when true, hold whether the tree
+// hashes sorted pairs (see SortedPairs) and whether it prefixes the data of
+// its leaves with their length (see LengthPrefixed). Key 11, which is only
+// present if it does not follow the greatest ordered ID of the leaves, holds
+// the NextID of the tree, and key 12, which is only present if the digests
+// are truncated (see TruncateDigests), their size. The merkle nodes are not
+// included; they are reconstructed upon decoding.
+//
+// It returns a non-nil error if the hash function of the merkle tree was
+// given through WithHashFunc, or if its leaves are keyed (see WithLeafKey).
+func (t *Tree) MarshalCBOR() ([]byte, error) {
+	if !t.scheme.encodable() {
+		return nil, ErrUnsupported
+	}
+	numKeys := uint64(3)
+	if t.encodedInsertionOrder() {
+		numKeys++
+	}
+	if t.scheme.isRFC6962() {
+		numKeys++
+	}
+	if t.scheme.arity > 2 {
+		numKeys++
+	}
+	if t.scheme.padding != t.scheme.impliedPadding() {
+		numKeys++
+	}
+	if t.scheme.padded {
+		numKeys++
+	}
+	if t.scheme.sortedPairs {
+		numKeys++
+	}
+	if t.scheme.lengthPrefixed {
+		numKeys++
+	}
+	encodeNextID := t.nextID != impliedNextID(t.tls)
+	if encodeNextID {
+		numKeys++
+	}
+	if t.scheme.digestSize != 0 {
+		numKeys++
+	}
+	b := appendCBORHead(nil, cborMap, numKeys)
+	b = appendCBORHead(b, cborUint, cborKeyHash)
+	b = appendCBORHead(b, cborUint, uint64(t.hash))
+	b = appendCBORHead(b, cborUint, cborKeyDigestOnly)
+	if t.digestOnly {
+		b = append(b, cborTrue)
+	} else {
+		b = append(b, cborFalse)
+	}
+	b = appendCBORHead(b, cborUint, cborKeyLeaves)
+	b = appendCBORHead(b, cborArray, uint64(len(t.tls)))
+	for i := range t.tls {
+		if t.digestOnly {
+			b = appendCBORHead(b, cborArray, 2)
+		} else {
+			b = appendCBORHead(b, cborArray, 3)
+		}
+		b = appendCBORHead(b, cborUint, t.tls[i].orderedID)
+		b = appendCBORBytes(b, t.tls[i].digest)
+		if !t.digestOnly {
+			b = appendCBORBytes(b, t.leafDatum(&t.tls[i]))
+		}
+	}
+	if t.encodedInsertionOrder() {
+		b = appendCBORHead(b, cborUint, cborKeyInsertionOrder)
+		b = append(b, cborTrue)
+	}
+	if t.scheme.isRFC6962() {
+		b = appendCBORHead(b, cborUint, cborKeyRFC6962)
+		b = append(b, cborTrue)
+	}
+	if t.scheme.arity > 2 {
+		b = appendCBORHead(b, cborUint, cborKeyArity)
+		b = appendCBORHead(b, cborUint, uint64(t.scheme.arity))
+	}
+	if t.scheme.padding != t.scheme.impliedPadding() {
+		b = appendCBORHead(b, cborUint, cborKeyPadding)
+		b = appendCBORHead(b, cborUint, uint64(t.scheme.padding))
+	}
+	if t.scheme.padded {
+		b = appendCBORHead(b, cborUint, cborKeyPadded)
+		b = appendCBORHead(b, cborArray, 2)
+		b = appendCBORHead(b, cborUint, uint64(t.scheme.depth))
+		b = appendCBORBytes(b, t.scheme.emptyLeaf)
+	}
+	if t.scheme.sortedPairs {
+		b = appendCBORHead(b, cborUint, cborKeySortedPairs)
+		b = append(b, cborTrue)
+	}
+	if t.scheme.lengthPrefixed {
+		b = appendCBORHead(b, cborUint, cborKeyLengthPrefixed)
+		b = append(b, cborTrue)
+	}
+	if encodeNextID {
+		b = appendCBORHead(b, cborUint, cborKeyNextID)
+		b = appendCBORHead(b, cborUint, t.nextID)
+	}
+	if t.scheme.digestSize != 0 {
+		b = appendCBORHead(b, cborUint, cborKeyDigestSize)
+		b = appendCBORHead(b, cborUint, uint64(t.scheme.digestSize))
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR decodes the given CBOR encoding (as produced by MarshalCBOR)
+// into the merkle tree, reconstructing its merkle nodes.
+func (t *Tree) UnmarshalCBOR(data []byte) error {
+	d := cborDecoder{buf: data}
+	t2 := &Tree{}
+	var tls []treeLeaf
+	var rfc6962 bool
+	var arity uint64
+	padding := uint64(numPaddingPolicies)
+	var pad scheme
+	var nextID uint64
+	for n := d.head(cborMap); n > 0 && !d.err; n-- {
+		switch d.head(cborUint) {
+		case cborKeyHash:
+			t2.hash = crypto.Hash(d.head(cborUint))
+		case cborKeyDigestOnly:
+			t2.digestOnly = d.bool()
+		case cborKeyInsertionOrder:
+			t2.insertionOrder = d.bool()
+		case cborKeyRFC6962:
+			rfc6962 = d.bool()
+		case cborKeyArity:
+			arity = d.head(cborUint)
+		case cborKeyPadded:
+			if d.head(cborArray) != 2 {
+				return ErrInvalidEncoding
+			}
+			depth := d.head(cborUint)
+			if depth >= 64 {
+				return ErrInvalidEncoding
+			}
+			pad = scheme{padded: true, depth: int(depth)}
+			if emptyLeaf := d.bytes(); len(emptyLeaf) != 0 {
+				pad.emptyLeaf = emptyLeaf
+			}
+		case cborKeySortedPairs:
+			pad.sortedPairs = d.bool()
+		case cborKeyLengthPrefixed:
+			pad.lengthPrefixed = d.bool()
+		case cborKeyNextID:
+			nextID = d.head(cborUint)
+		case cborKeyDigestSize:
+			digestSize := d.head(cborUint)
+			if digestSize == 0 || digestSize > uint64(len(data)) {
+				return ErrInvalidEncoding
+			}
+			pad.digestSize = int(digestSize)
+		case cborKeyPadding:
+			if padding = d.head(cborUint); padding >= uint64(numPaddingPolicies) {
+				return ErrInvalidEncoding
+			}
+		case cborKeyLeaves:
+			numLeaves := d.head(cborArray)
+			if numLeaves > uint64(len(d.buf)) {
+				return ErrInvalidEncoding
+			}
+			tls = make([]treeLeaf, numLeaves)
+			for i := range tls {
+				fields := d.head(cborArray)
+				tls[i].orderedID = d.head(cborUint)
+				tls[i].digest = d.bytes()
+				if fields == 3 {
+					tls[i].datum = d.bytes()
+				} else if fields != 2 {
+					return ErrInvalidEncoding
+				}
+			}
+		default:
+			return ErrInvalidEncoding
+		}
+	}
+	if d.err || len(d.buf) != 0 || t2.hash == 0 || t2.hash >= maxHash || arity > uint64(len(data)) {
+		return ErrInvalidEncoding
+	}
+	if rfc6962 {
+		t2.scheme = rfc6962Scheme
+	}
+	if arity > 2 {
+		t2.scheme.arity = int(arity)
+	}
+	if padding < uint64(numPaddingPolicies) {
+		t2.scheme.padding = PaddingPolicy(padding)
+	}
+	t2.scheme.padded, t2.scheme.depth, t2.scheme.emptyLeaf = pad.padded, pad.depth, pad.emptyLeaf
+	t2.scheme.sortedPairs, t2.scheme.lengthPrefixed = pad.sortedPairs, pad.lengthPrefixed
+	t2.scheme.digestSize = pad.digestSize
+	if !t2.hash.Available() {
+		return &HashError{Hash: t2.hash}
+	}
+	if len(tls) == 0 {
+		return ErrNoData
+	}
+	if t2.checkPadding(len(tls)) != nil {
+		return ErrInvalidEncoding
+	}
+
+	h := t2.newHasher()
+	for i := range tls {
+		if t2.digestOnly {
+			tls[i].datum = nil
+			if len(tls[i].digest) != h.Size() {
+				return ErrInvalidEncoding
+			}
+			continue
+		}
+		tls[i].digest = t2.scheme.hashLeaf(h, tls[i].datum)
+	}
+	if !validIDs(tls) {
+		return ErrInvalidEncoding
+	}
+	if t2.nextID = impliedNextID(tls); nextID != 0 {
+		if nextID < t2.nextID {
+			return ErrInvalidEncoding
+		}
+		t2.nextID = nextID
+	}
+	t2.sortTreeLeaves(tls)
+	t2.tls = tls
+	t2.setNodes(t2.constructMerkleNodes(h, tls))
+	*t = *t2
+	return nil
+}
+
+// MarshalCBOR returns the CBOR (RFC 8949) encoding of the Proof.
+//
+// The Proof is encoded as a map with integer keys: 1 holds the crypto.Hash
+// value of the hash function, 2 the leaf index, 3 the number of leaves, 4 the
+// leaf digest and 5 the array of the siblings' digests; key 6, which is only
+// present if the digests are truncated (see TruncateDigests), holds their
+// size. The rest of the hashing scheme is encoded the same way as the one of
+// trees: keys 7 and 10, which are only present when true, hold whether the
+// tree hashes as per RFC 6962 and whether it hashes sorted pairs, key 8,
+// which is only present for arities greater than 2, holds the arity, and key
+// 9, which is only present for PaddingPolicies other than the one implied by
+// key 7, holds the PaddingPolicy.
+//
+// It returns a non-nil error if the hash function of the Proof was given
+// through WithHashFunc or WithHasher.
+func (p *Proof) MarshalCBOR() ([]byte, error) {
+	s := schemeOrDefault(p.scheme)
+	if s.newHash != nil {
+		return nil, ErrUnsupported
+	}
+	numKeys := uint64(5)
+	if s.digestSize != 0 {
+		numKeys++
+	}
+	if s.isRFC6962() {
+		numKeys++
+	}
+	if s.arity > 2 {
+		numKeys++
+	}
+	if s.padding != s.impliedPadding() {
+		numKeys++
+	}
+	if s.sortedPairs {
+		numKeys++
+	}
+	b := appendCBORHead(nil, cborMap, numKeys)
+	b = appendCBORHead(b, cborUint, cborKeyProofHash)
+	b = appendCBORHead(b, cborUint, uint64(p.Hash))
+	b = appendCBORHead(b, cborUint, cborKeyProofLeafIndex)
+	b = appendCBORHead(b, cborUint, uint64(p.LeafIndex))
+	b = appendCBORHead(b, cborUint, cborKeyProofNumLeaves)
+	b = appendCBORHead(b, cborUint, uint64(p.NumLeaves))
+	b = appendCBORHead(b, cborUint, cborKeyProofLeafDigest)
+	b = appendCBORBytes(b, p.LeafDigest)
+	b = appendCBORHead(b, cborUint, cborKeyProofSiblings)
+	b = appendCBORHead(b, cborArray, uint64(len(p.Siblings)))
+	for _, sibling := range p.Siblings {
+		b = appendCBORBytes(b, sibling)
+	}
+	if s.digestSize != 0 {
+		b = appendCBORHead(b, cborUint, cborKeyProofDigestSize)
+		b = appendCBORHead(b, cborUint, uint64(s.digestSize))
+	}
+	if s.isRFC6962() {
+		b = appendCBORHead(b, cborUint, cborKeyProofRFC6962)
+		b = append(b, cborTrue)
+	}
+	if s.arity > 2 {
+		b = appendCBORHead(b, cborUint, cborKeyProofArity)
+		b = appendCBORHead(b, cborUint, uint64(s.arity))
+	}
+	if s.padding != s.impliedPadding() {
+		b = appendCBORHead(b, cborUint, cborKeyProofPadding)
+		b = appendCBORHead(b, cborUint, uint64(s.padding))
+	}
+	if s.sortedPairs {
+		b = appendCBORHead(b, cborUint, cborKeyProofSortedPairs)
+		b = append(b, cborTrue)
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR decodes the given CBOR encoding (as produced by MarshalCBOR)
+// into the Proof.
+func (p *Proof) UnmarshalCBOR(data []byte) error {
+	d := cborDecoder{buf: data}
+	var p2 Proof
+	var s scheme
+	var rfc6962 bool
+	padding := uint64(numPaddingPolicies)
+	for n := d.head(cborMap); n > 0 && !d.err; n-- {
+		switch d.head(cborUint) {
+		case cborKeyProofHash:
+			p2.Hash = crypto.Hash(d.head(cborUint))
+		case cborKeyProofLeafIndex:
+			p2.LeafIndex = d.int()
+		case cborKeyProofNumLeaves:
+			p2.NumLeaves = d.int()
+		case cborKeyProofLeafDigest:
+			p2.LeafDigest = d.bytes()
+		case cborKeyProofSiblings:
+			numSiblings := d.head(cborArray)
+			if numSiblings > uint64(len(d.buf)) {
+				return ErrInvalidEncoding
+			}
+			p2.Siblings = make([][]byte, numSiblings)
+			for i := range p2.Siblings {
+				p2.Siblings[i] = d.bytes()
+			}
+		case cborKeyProofDigestSize:
+			digestSize := d.head(cborUint)
+			if digestSize == 0 || digestSize > uint64(len(data)) {
+				return ErrInvalidEncoding
+			}
+			s.digestSize = int(digestSize)
+		case cborKeyProofRFC6962:
+			rfc6962 = d.bool()
+		case cborKeyProofArity:
+			arity := d.head(cborUint)
+			if arity > uint64(len(data)) {
+				return ErrInvalidEncoding
+			}
+			s.arity = int(arity)
+		case cborKeyProofPadding:
+			if padding = d.head(cborUint); padding >= uint64(numPaddingPolicies) {
+				return ErrInvalidEncoding
+			}
+		case cborKeyProofSortedPairs:
+			s.sortedPairs = d.bool()
+		default:
+			return ErrInvalidEncoding
+		}
+	}
+	if d.err || len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	if rfc6962 {
+		s.leafPrefix, s.nodePrefix, s.padding = rfc6962Scheme.leafPrefix, rfc6962Scheme.nodePrefix, rfc6962Scheme.padding
+	}
+	if s.arity <= 2 {
+		s.arity = 0
+	}
+	if padding < uint64(numPaddingPolicies) {
+		s.padding = PaddingPolicy(padding)
+	}
+	if !s.isDefault() {
+		p2.scheme = &s
+	}
+	*p = p2
+	return nil
+}
+
+// appendCBORHead appends the head of a CBOR data item of the given major type
+// and argument, using the shortest form possible, as deterministic CBOR
+// requires.
+func appendCBORHead(b []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= 0xff:
+		return append(b, major|24, byte(arg))
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), arg)
+	}
+}
+
+func appendCBORBytes(b, v []byte) []byte {
+	return append(appendCBORHead(b, cborBytes, uint64(len(v))), v...)
+}
+
+// cborDecoder decodes the small subset of CBOR that trees and proofs are
+// encoded with. Once it fails, it sets err and keeps returning zero values.
+type cborDecoder struct {
+	buf []byte
+	err bool
+}
+
+// head decodes the head of a data item, which must be of the given major type,
+// and returns its argument.
+func (d *cborDecoder) head(major byte) uint64 {
+	if d.err || len(d.buf) == 0 || d.buf[0]&0xe0 != major {
+		d.err = true
+		return 0
+	}
+	info := d.buf[0] & 0x1f
+	d.buf = d.buf[1:]
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info)
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		d.err = true
+		return 0
+	}
+	if len(d.buf) < size {
+		d.err = true
+		return 0
+	}
+	var arg uint64
+	for _, c := range d.buf[:size] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.buf = d.buf[size:]
+	return arg
+}
+
+// int is like head, for an unsigned integer that must fit in an int on the
+// platform at hand (e.g. an index), rather than silently overflow it.
+func (d *cborDecoder) int() int {
+	v := d.head(cborUint)
+	if v > math.MaxInt {
+		d.err = true
+		return 0
+	}
+	return int(v)
+}
+
+func (d *cborDecoder) bytes() []byte {
+	n := d.head(cborBytes)
+	if d.err || n > uint64(len(d.buf)) {
+		d.err = true
+		return nil
+	}
+	ret := make([]byte, n)
+	copy(ret, d.buf[:n])
+	d.buf = d.buf[n:]
+	return ret
+}
+
+func (d *cborDecoder) bool() bool {
+	if d.err || len(d.buf) == 0 || (d.buf[0] != cborFalse && d.buf[0] != cborTrue) {
+		d.err = true
+		return false
+	}
+	v := d.buf[0] == cborTrue
+	d.buf = d.buf[1:]
+	return v
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func TestCBOR00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tree.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("len(data) = %d", len(data))
+
+	var tree2 Tree
+	if err = tree2.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	if err = tree2.UnmarshalCBOR(data[:len(data)-1]); err == nil {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+}
+func TestCBOR01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.ProveDatum(M)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%x", data)
+
+	var p2 Proof
+	if err = p2.UnmarshalCBOR(data); err != nil {
+		t.Fatal(err)
+	}
+	if !p2.Verify(tree.MerkleRoot()) {
+		t.Fatalf("decoded proof failed to verify")
+	}
+
+	treeData, _ := tree.MarshalCBOR()
+	var tree2 Tree
+	if err = tree2.UnmarshalCBOR(treeData); err != nil {
+		t.Fatal(err)
+	}
+	if !p2.Verify(tree2.MerkleRoot()) {
+		t.Fatalf("decoded proof failed to verify against decoded tree")
+	}
+}
+func TestCBOR02(t *testing.T) {
+	for _, opts := range [][]Option{
+		{RFC6962()},
+		{WithArity(4)},
+		{SortedPairs()},
+		{RFC6962(), WithPaddingPolicy(DuplicateLast)},
+		{WithArity(3), WithPaddingPolicy(PairWithZero), TruncateDigests(16)},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := tree.Proof(tree.NumLeaves() - 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := p.MarshalCBOR()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p2 Proof
+		if err = p2.UnmarshalCBOR(data); err != nil {
+			t.Fatal(err)
+		}
+		if p2.Scheme() != p.Scheme() {
+			t.Fatalf("want (%+v); got %+v", p.Scheme(), p2.Scheme())
+		}
+		if !p2.Verify(tree.MerkleRoot()) {
+			t.Fatalf("decoded proof failed to verify")
+		}
+	}
+}
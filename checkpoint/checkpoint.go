@@ -0,0 +1,93 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package checkpoint implements the checkpoint format of transparency logs
+// (as used by transparency-dev tooling and witnesses), along with the signed
+// note format of the Go checksum database that checkpoints are wrapped in, so
+// that merkle roots can be co-signed and gossiped by existing witnesses.
+//
+// A checkpoint is a note whose text consists of the origin of the log, the
+// size of its tree in decimal, the base64-encoded merkle root, and any number
+// of extension lines, each terminated by a newline.
+package checkpoint
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformedCheckpoint signifies that the given text is not a valid
+// checkpoint.
+var ErrMalformedCheckpoint = errors.New("checkpoint: malformed checkpoint")
+
+// Checkpoint is a commitment to the state of a log's merkle tree.
+type Checkpoint struct {
+	// Origin uniquely identifies the log.
+	Origin string
+	// Size is the number of leaves in the tree.
+	Size uint64
+	// Hash is the merkle root of the tree.
+	Hash []byte
+	// Extensions are any additional (non-empty) lines of data.
+	Extensions []string
+}
+
+// Marshal returns the text of the Checkpoint, suitable for signing as a note.
+func (c *Checkpoint) Marshal() []byte {
+	var b bytes.Buffer
+	b.WriteString(c.Origin)
+	b.WriteByte('\n')
+	b.WriteString(strconv.FormatUint(c.Size, 10))
+	b.WriteByte('\n')
+	b.WriteString(base64.StdEncoding.EncodeToString(c.Hash))
+	b.WriteByte('\n')
+	for _, ext := range c.Extensions {
+		b.WriteString(ext)
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// Unmarshal parses the given text (e.g. the Text of an opened Note) into the
+// Checkpoint.
+func (c *Checkpoint) Unmarshal(text []byte) error {
+	if len(text) == 0 || text[len(text)-1] != '\n' {
+		return ErrMalformedCheckpoint
+	}
+	lines := strings.Split(string(text[:len(text)-1]), "\n")
+	if len(lines) < 3 || lines[0] == "" {
+		return ErrMalformedCheckpoint
+	}
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil || strconv.FormatUint(size, 10) != lines[1] {
+		return ErrMalformedCheckpoint
+	}
+	hash, err := base64.StdEncoding.Strict().DecodeString(lines[2])
+	if err != nil || len(hash) == 0 {
+		return ErrMalformedCheckpoint
+	}
+	for _, ext := range lines[3:] {
+		if ext == "" {
+			return ErrMalformedCheckpoint
+		}
+	}
+	c.Origin, c.Size, c.Hash, c.Extensions = lines[0], size, hash, lines[3:]
+	if len(c.Extensions) == 0 {
+		c.Extensions = nil
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package checkpoint
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	_ "crypto/sha256"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+const (
+	testSkey = "PRIVATE+KEY+PeterNeumann+c74f20a3+AYEKFALVFGyNhPJEMzD1QIDr+Y7hfZx09iUvxdXHKDFz"
+	testVkey = "PeterNeumann+c74f20a3+ARpc2QcUPDhMQegwxbzhKqiBfsVkmqq/LDE4izWy10TW"
+	testText = "If you think cryptography is the answer to your problem,\n" +
+		"then you don't know what your problem is.\n"
+	testNote = testText + "\n" +
+		"— PeterNeumann x08go/ZJkuBS9UG/SffcvIAQxVBtiFupLLr8pAcElZInNIuGUgYN1FFYC2pZSNXgKvqfqdngotpRZb6KE6RyyBwJnAM=\n"
+)
+
+func TestNote00(t *testing.T) {
+	signer, err := NewSigner(testSkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Sign([]byte(testText), nil, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg) != testNote {
+		t.Fatalf("want:\n%s\ngot:\n%s", testNote, msg)
+	}
+
+	verifier, err := NewVerifier(testVkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := Open(msg, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(n.Text) != testText || len(n.Sigs) != 1 || n.Sigs[0].Name != "PeterNeumann" {
+		t.Fatalf("unexpected note: %+v", n)
+	}
+
+	tampered := bytes.Replace(msg, []byte("answer"), []byte("ANSWER"), 1)
+	if _, err = Open(tampered, verifier); err != ErrInvalidSignature {
+		t.Fatalf("want (%v); got %v", ErrInvalidSignature, err)
+	}
+	if _, err = NewVerifier(testVkey[:len(testVkey)-2]); err != ErrMalformedKey {
+		t.Fatalf("want (%v); got %v", ErrMalformedKey, err)
+	}
+}
+
+type Word string
+
+func (w Word) Serialize() []byte {
+	return []byte(w)
+}
+
+func TestCheckpoint00(t *testing.T) {
+	tree, err := merkle.NewTree(crypto.SHA256, Word("alpha"), Word("beta"), Word("gamma"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Checkpoint{
+		Origin:     "example.com/log",
+		Size:       uint64(tree.NumLeaves()),
+		Hash:       tree.MerkleRoot(),
+		Extensions: []string{"Timestamp: 1234"},
+	}
+
+	skey, vkey, err := GenerateKey(rand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := Sign(c.Marshal(), nil, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s", msg)
+
+	// A witness co-signs the checkpoint, preserving the log's signature.
+	wskey, wvkey, _ := GenerateKey(rand.Reader, "witness")
+	wsigner, _ := NewSigner(wskey)
+	wverifier, _ := NewVerifier(wvkey)
+	n, err := Open(msg, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosigned, err := Sign(n.Text, n.Sigs, wsigner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err = Open(cosigned, verifier, wverifier); err != nil || len(n.Sigs) != 2 {
+		t.Fatalf("want 2 verified signatures; got (%+v, %v)", n, err)
+	}
+
+	var c2 Checkpoint
+	if err = c2.Unmarshal(n.Text); err != nil {
+		t.Fatal(err)
+	}
+	if c2.Origin != c.Origin || c2.Size != c.Size || !bytes.Equal(c2.Hash, c.Hash) || len(c2.Extensions) != 1 {
+		t.Fatalf("want %+v; got %+v", c, c2)
+	}
+	for _, bad := range []string{"", "origin\n3\n", "origin\n03\nAAAA\n", "origin\n3\n!!!!\n", "origin\n3\nAAAA\n\n"} {
+		if err = c2.Unmarshal([]byte(bad)); err != ErrMalformedCheckpoint {
+			t.Fatalf("%q: want (%v); got %v", bad, ErrMalformedCheckpoint, err)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ckatsak/merkle/internal/notekey"
+)
+
+var (
+	// ErrMalformedKey signifies that the given key is not a valid signer or
+	// verifier key.
+	ErrMalformedKey = errors.New("checkpoint: malformed key")
+
+	// ErrMalformedNote signifies that the given signed note is not in the
+	// expected format.
+	ErrMalformedNote = errors.New("checkpoint: malformed note")
+
+	// ErrUnverifiedNote signifies that none of the signatures of the given
+	// signed note could be verified by the known verifiers.
+	ErrUnverifiedNote = errors.New("checkpoint: note has no verifiable signatures")
+
+	// ErrInvalidSignature signifies that a signature by a known verifier
+	// failed to verify.
+	ErrInvalidSignature = errors.New("checkpoint: invalid signature")
+)
+
+// algEd25519 identifies Ed25519 keys in the note format.
+const algEd25519 = 1
+
+// Signer signs notes on behalf of a named key.
+type Signer struct {
+	name string
+	hash uint32
+	key  ed25519.PrivateKey
+}
+
+// Verifier verifies the signatures of a named key on notes.
+type Verifier struct {
+	name string
+	hash uint32
+	key  ed25519.PublicKey
+}
+
+// GenerateKey generates a new Ed25519 key pair with the given name, and
+// returns the encoded signer (private) and verifier (public) keys.
+func GenerateKey(rand io.Reader, name string) (skey, vkey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return "", "", err
+	}
+	pubKey := append([]byte{algEd25519}, pub...)
+	privKey := append([]byte{algEd25519}, priv.Seed()...)
+	hash := notekey.Hash(name, pubKey)
+	skey = "PRIVATE+KEY+" + name + "+" + notekey.EncodeHash(hash) + "+" + base64.StdEncoding.EncodeToString(privKey)
+	vkey = name + "+" + notekey.EncodeHash(hash) + "+" + base64.StdEncoding.EncodeToString(pubKey)
+	return skey, vkey, nil
+}
+
+// NewSigner parses the given encoded signer key, i.e.
+// "PRIVATE+KEY+<name>+<hash>+<key>".
+func NewSigner(skey string) (*Signer, error) {
+	name, hash, key, ok := notekey.Parse(strings.TrimPrefix(skey, "PRIVATE+KEY+"))
+	if !ok || !strings.HasPrefix(skey, "PRIVATE+KEY+") || len(key) != 1+ed25519.SeedSize || key[0] != algEd25519 {
+		return nil, ErrMalformedKey
+	}
+	priv := ed25519.NewKeyFromSeed(key[1:])
+	pubKey := append([]byte{algEd25519}, priv.Public().(ed25519.PublicKey)...)
+	if notekey.Hash(name, pubKey) != hash {
+		return nil, ErrMalformedKey
+	}
+	return &Signer{name: name, hash: hash, key: priv}, nil
+}
+
+// NewVerifier parses the given encoded verifier key, i.e.
+// "<name>+<hash>+<key>".
+func NewVerifier(vkey string) (*Verifier, error) {
+	name, hash, key, ok := notekey.Parse(vkey)
+	if !ok || len(key) != 1+ed25519.PublicKeySize || key[0] != algEd25519 || notekey.Hash(name, key) != hash {
+		return nil, ErrMalformedKey
+	}
+	return &Verifier{name: name, hash: hash, key: ed25519.PublicKey(key[1:])}, nil
+}
+
+// Name returns the name of the Signer's key.
+func (s *Signer) Name() string {
+	return s.name
+}
+
+// Name returns the name of the Verifier's key.
+func (v *Verifier) Name() string {
+	return v.name
+}
+
+// Signature is a signature on a note.
+type Signature struct {
+	// Name is the name of the signing key.
+	Name string
+	// Hash is the hash of the signing key.
+	Hash uint32
+	// Base64 is the base64-encoded key hash and signature, exactly as it
+	// appears in the signed note.
+	Base64 string
+}
+
+// Note is an opened signed note.
+type Note struct {
+	// Text is the signed text of the note.
+	Text []byte
+	// Sigs are the verified signatures.
+	Sigs []Signature
+	// UnverifiedSigs are the signatures by unknown keys.
+	UnverifiedSigs []Signature
+}
+
+// Sign signs the given text (which must end with a newline and contain no
+// blank lines) with the given signers, appending any existing signatures
+// given, and returns the signed note.
+func Sign(text []byte, sigs []Signature, signers ...*Signer) ([]byte, error) {
+	if !validText(text) {
+		return nil, ErrMalformedNote
+	}
+	var b bytes.Buffer
+	b.Write(text)
+	b.WriteByte('\n')
+	for _, s := range signers {
+		sig := ed25519.Sign(s.key, text)
+		blob := binary.BigEndian.AppendUint32(nil, s.hash)
+		blob = append(blob, sig...)
+		b.WriteString("— " + s.name + " " + base64.StdEncoding.EncodeToString(blob) + "\n")
+	}
+	for _, sig := range sigs {
+		b.WriteString("— " + sig.Name + " " + sig.Base64 + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+// Open parses the given signed note and verifies its signatures by the given
+// verifiers.
+//
+// It returns ErrUnverifiedNote if no signature by the known verifiers is
+// found, or ErrInvalidSignature if any signature by them fails to verify.
+func Open(msg []byte, verifiers ...*Verifier) (*Note, error) {
+	sep := bytes.LastIndex(msg, []byte("\n\n"))
+	if sep < 0 || !utf8.Valid(msg) {
+		return nil, ErrMalformedNote
+	}
+	text, sigLines := msg[:sep+1], msg[sep+2:]
+	if !validText(text) || len(sigLines) == 0 || sigLines[len(sigLines)-1] != '\n' {
+		return nil, ErrMalformedNote
+	}
+
+	n := &Note{Text: text}
+	for _, line := range strings.Split(string(sigLines[:len(sigLines)-1]), "\n") {
+		fields := strings.Split(strings.TrimPrefix(line, "— "), " ")
+		if !strings.HasPrefix(line, "— ") || len(fields) != 2 || fields[0] == "" {
+			return nil, ErrMalformedNote
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(blob) < 4 {
+			return nil, ErrMalformedNote
+		}
+		sig := Signature{Name: fields[0], Hash: binary.BigEndian.Uint32(blob), Base64: fields[1]}
+
+		var v *Verifier
+		for _, vv := range verifiers {
+			if vv.name == sig.Name && vv.hash == sig.Hash {
+				v = vv
+				break
+			}
+		}
+		if v == nil {
+			n.UnverifiedSigs = append(n.UnverifiedSigs, sig)
+			continue
+		}
+		if !ed25519.Verify(v.key, text, blob[4:]) {
+			return nil, ErrInvalidSignature
+		}
+		n.Sigs = append(n.Sigs, sig)
+	}
+	if len(n.Sigs) == 0 {
+		return nil, ErrUnverifiedNote
+	}
+	return n, nil
+}
+
+// validText reports whether the given text can be signed as a note, i.e.
+// whether it is valid UTF-8 ending with a newline and has no blank lines.
+func validText(text []byte) bool {
+	return len(text) > 0 && text[len(text)-1] == '\n' && utf8.Valid(text) &&
+		!bytes.Contains(text, []byte("\n\n")) && text[0] != '\n'
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package cid encodes the merkle roots and nodes of merkle trees as
+// multihashes and CIDv1 values (see https://multiformats.io), and decodes them
+// back, so that the trees integrate with IPFS/IPLD tooling and with
+// content-addressed stores.
+//
+// The content that a node is addressed by is the input of its hash function,
+// i.e. the concatenation of its children's digests (or the serialized datum
+// of a leaf), hence its CID is of the raw codec by default. The digests of
+// merkle trees that truncate them (see merkle.TruncateDigests) are encoded as
+// truncated multihashes. Only CIDv1 values are supported, and their textual
+// form is the lowercase base32 multibase one (i.e. "b..."); the base16 one
+// (i.e. "f...") is accepted on input as well.
+package cid
+
+import (
+	"crypto"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/ckatsak/merkle"
+)
+
+var (
+	// ErrInvalidMultihash signifies that the given data are not a valid
+	// multihash.
+	ErrInvalidMultihash = errors.New("cid: invalid multihash")
+
+	// ErrInvalidCID signifies that the given data are not a valid CIDv1.
+	ErrInvalidCID = errors.New("cid: invalid CID")
+
+	// ErrUnsupportedHash signifies that the hash function has no multihash
+	// code known to the package.
+	ErrUnsupportedHash = errors.New("cid: unsupported hash function")
+)
+
+// Multicodec codes of the content that CIDs address.
+const (
+	Raw     uint64 = 0x55
+	DagPB   uint64 = 0x70
+	DagCBOR uint64 = 0x71
+)
+
+// version is the version of the CIDs that the package supports.
+const version = 1
+
+// multihashCodes maps the hash functions of the standard library to their
+// multihash codes.
+var multihashCodes = map[crypto.Hash]uint64{
+	crypto.MD5:         0xd5,
+	crypto.SHA1:        0x11,
+	crypto.SHA224:      0x1013,
+	crypto.SHA256:      0x12,
+	crypto.SHA384:      0x20,
+	crypto.SHA512:      0x13,
+	crypto.RIPEMD160:   0x1053,
+	crypto.SHA3_224:    0x17,
+	crypto.SHA3_256:    0x16,
+	crypto.SHA3_384:    0x15,
+	crypto.SHA3_512:    0x14,
+	crypto.SHA512_224:  0x1014,
+	crypto.SHA512_256:  0x1015,
+	crypto.BLAKE2s_256: 0xb260,
+	crypto.BLAKE2b_256: 0xb220,
+	crypto.BLAKE2b_384: 0xb230,
+	crypto.BLAKE2b_512: 0xb240,
+}
+
+// MultihashCode returns the multihash code of the given hash function, and
+// reports whether there is one.
+func MultihashCode(hash crypto.Hash) (uint64, bool) {
+	code, ok := multihashCodes[hash]
+	return code, ok
+}
+
+// hashOf returns the hash function of the given multihash code, or 0 if
+// there is none.
+func hashOf(code uint64) crypto.Hash {
+	for hash, c := range multihashCodes {
+		if c == code {
+			return hash
+		}
+	}
+	return 0
+}
+
+// EncodeMultihash returns the multihash of the given digest of the given hash
+// function; i.e. the code of the hash function and the size of the digest,
+// as unsigned varints, followed by the digest. The digest may be truncated.
+//
+// It returns a non-nil error if the hash function has no multihash code, or
+// if the digest is empty or larger than the ones it produces.
+func EncodeMultihash(hash crypto.Hash, digest []byte) ([]byte, error) {
+	return appendMultihash(nil, hash, digest)
+}
+
+func appendMultihash(b []byte, hash crypto.Hash, digest []byte) ([]byte, error) {
+	code, ok := MultihashCode(hash)
+	if !ok {
+		return nil, ErrUnsupportedHash
+	}
+	if len(digest) == 0 || len(digest) > hash.Size() {
+		return nil, ErrInvalidMultihash
+	}
+	b = binary.AppendUvarint(b, code)
+	b = binary.AppendUvarint(b, uint64(len(digest)))
+	return append(b, digest...), nil
+}
+
+// DecodeMultihash decodes the given multihash (as returned by
+// EncodeMultihash) into the hash function and the digest that it comprises.
+//
+// It returns a non-nil error if mh is not a valid multihash, or if its hash
+// function is not one of the standard library.
+func DecodeMultihash(mh []byte) (crypto.Hash, []byte, error) {
+	d := decoder{buf: mh}
+	code := d.uvarint()
+	size := d.uvarint()
+	if d.err || size == 0 || size != uint64(len(d.buf)) {
+		return 0, nil, ErrInvalidMultihash
+	}
+	hash := hashOf(code)
+	if hash == 0 {
+		return 0, nil, ErrUnsupportedHash
+	}
+	if size > uint64(hash.Size()) {
+		return 0, nil, ErrInvalidMultihash
+	}
+	return hash, append([]byte{}, d.buf...), nil
+}
+
+// CID is a CIDv1; i.e. the multicodec code of the content that it addresses,
+// along with the multihash of the latter.
+type CID struct {
+	// Codec is the multicodec code of the content (e.g. Raw).
+	Codec uint64
+	// Hash is the hash function of Digest, which may be truncated.
+	Hash   crypto.Hash
+	Digest []byte
+}
+
+// Root returns the CID of the merkle root of the given merkle tree, of the
+// Raw codec.
+//
+// It returns a non-nil error if the hash function of the merkle tree has no
+// multihash code (e.g. one given through merkle.WithHashFunc, which the
+// merkle tree reports as the crypto.Hash given to its constructor).
+func Root(t *merkle.Tree) (CID, error) {
+	return newCID(t.Hash(), t.MerkleRoot())
+}
+
+// Node returns the CID of the node at the given level and index of the given
+// merkle tree (see merkle.Tree.Node), of the Raw codec.
+//
+// It returns a non-nil error if there is no such node, or if the hash
+// function of the merkle tree has no multihash code.
+func Node(t *merkle.Tree, level, index int) (CID, error) {
+	digest, err := t.Node(level, index)
+	if err != nil {
+		return CID{}, err
+	}
+	return newCID(t.Hash(), digest)
+}
+
+func newCID(hash crypto.Hash, digest []byte) (CID, error) {
+	if _, ok := MultihashCode(hash); !ok {
+		return CID{}, ErrUnsupportedHash
+	}
+	return CID{Codec: Raw, Hash: hash, Digest: append([]byte{}, digest...)}, nil
+}
+
+// VerifyProof reports whether the given inclusion proof leads to the merkle
+// root of the given CID, under the same hash function.
+func VerifyProof(p *merkle.Proof, root CID) bool {
+	return p.Hash == root.Hash && p.Verify(root.Digest)
+}
+
+// Multihash returns the multihash of the CID.
+//
+// It returns a non-nil error if its hash function has no multihash code, or
+// if its digest is empty or larger than the ones it produces.
+func (c CID) Multihash() ([]byte, error) {
+	return EncodeMultihash(c.Hash, c.Digest)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface; i.e. it
+// returns the binary form of the CID: its version, its codec and its
+// multihash.
+func (c CID) MarshalBinary() ([]byte, error) {
+	b := binary.AppendUvarint(nil, version)
+	b = binary.AppendUvarint(b, c.Codec)
+	return appendMultihash(b, c.Hash, c.Digest)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (c *CID) UnmarshalBinary(data []byte) error {
+	d := decoder{buf: data}
+	v := d.uvarint()
+	codec := d.uvarint()
+	if d.err || v != version {
+		return ErrInvalidCID
+	}
+	hash, digest, err := DecodeMultihash(d.buf)
+	if err != nil {
+		return err
+	}
+	*c = CID{Codec: codec, Hash: hash, Digest: digest}
+	return nil
+}
+
+// base32Encoding is the lowercase, unpadded alphabet of the "b" multibase.
+var base32Encoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// MarshalText implements the encoding.TextMarshaler interface; i.e. it returns
+// the textual form of the CID, in the lowercase base32 multibase.
+func (c CID) MarshalText() ([]byte, error) {
+	b, err := c.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, 1+base32Encoding.EncodedLen(len(b)))
+	text[0] = 'b'
+	base32Encoding.Encode(text[1:], b)
+	return text, nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface, accepting
+// the base32 (either lowercase or uppercase) and base16 multibases.
+func (c *CID) UnmarshalText(text []byte) error {
+	if len(text) < 2 {
+		return ErrInvalidCID
+	}
+	var b []byte
+	var err error
+	switch s := string(text[1:]); text[0] {
+	case 'b':
+		b, err = base32Encoding.DecodeString(s)
+	case 'B':
+		b, err = base32Encoding.DecodeString(strings.ToLower(s))
+	case 'f':
+		b, err = hex.DecodeString(s)
+	case 'F':
+		b, err = hex.DecodeString(strings.ToLower(s))
+	default:
+		return ErrInvalidCID
+	}
+	if err != nil {
+		return ErrInvalidCID
+	}
+	return c.UnmarshalBinary(b)
+}
+
+// String returns the textual form of the CID (see MarshalText), or an empty
+// string if it cannot be encoded.
+func (c CID) String() string {
+	text, err := c.MarshalText()
+	if err != nil {
+		return ""
+	}
+	return string(text)
+}
+
+// Parse parses the given textual form of a CID (see UnmarshalText).
+func Parse(s string) (CID, error) {
+	var c CID
+	err := c.UnmarshalText([]byte(s))
+	return c, err
+}
+
+// decoder consumes the unsigned varints of multiformats from a byte slice.
+// Once it fails, it sets err and keeps returning zero values.
+type decoder struct {
+	buf []byte
+	err bool
+}
+
+// uvarint decodes an unsigned varint, which must be minimally encoded and
+// at most 9 bytes long.
+func (d *decoder) uvarint() uint64 {
+	if d.err {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 || n > 9 || n != len(binary.AppendUvarint(nil, v)) {
+		d.err = true
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
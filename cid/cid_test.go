@@ -0,0 +1,151 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cid
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+func TestCID00(t *testing.T) {
+	// The CID of "hello world" as a raw IPFS block.
+	digest := sha256.Sum256([]byte("hello world"))
+	c := CID{Codec: Raw, Hash: crypto.SHA256, Digest: digest[:]}
+	const want = "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e"
+	if c.String() != want {
+		t.Fatalf("want (%s); got %s", want, c)
+	}
+	for _, s := range []string{want, "BAFKREIFZJUT3TE2NHYEKKLSS27NH3K72YSCO7Y32KOAO5EEI66WOF36N5E",
+		"f01551220b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"} {
+		c2, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c2.Codec != Raw || c2.Hash != crypto.SHA256 || !bytes.Equal(c2.Digest, digest[:]) {
+			t.Fatalf("want (%v); got %v", c, c2)
+		}
+	}
+	for _, s := range []string{"", "b", "zQmSomething", "bafkrei", want[:len(want)-2]} {
+		if _, err := Parse(s); err == nil {
+			t.Fatalf("want (an error); got <nil> for %q", s)
+		}
+	}
+}
+
+func TestMultihash00(t *testing.T) {
+	digest := sha256.Sum256([]byte("hello world"))
+	mh, err := EncodeMultihash(crypto.SHA256, digest[:16])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mh[0] != 0x12 || mh[1] != 16 || len(mh) != 18 {
+		t.Fatalf("want (12 10 ...); got %x", mh)
+	}
+	hash, got, err := DecodeMultihash(mh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != crypto.SHA256 || !bytes.Equal(got, digest[:16]) {
+		t.Fatalf("want (%v, %x); got (%v, %x)", crypto.SHA256, digest[:16], hash, got)
+	}
+
+	if _, err := EncodeMultihash(crypto.MD4, digest[:16]); err != ErrUnsupportedHash {
+		t.Fatalf("want (%v); got %v", ErrUnsupportedHash, err)
+	}
+	if _, err := EncodeMultihash(crypto.SHA1, digest[:]); err != ErrInvalidMultihash {
+		t.Fatalf("want (%v); got %v", ErrInvalidMultihash, err)
+	}
+	for _, mh := range [][]byte{{}, {0x12}, {0x12, 0x21}, {0x12, 0x01}, {0x92, 0x00, 0x01, 0x00}, {0x11, 0x21}} {
+		if _, _, err := DecodeMultihash(mh); err != ErrInvalidMultihash {
+			t.Fatalf("want (%v); got %v for %x", ErrInvalidMultihash, err, mh)
+		}
+	}
+	if _, _, err := DecodeMultihash([]byte{0x00, 0x01, 0x00}); err != ErrUnsupportedHash {
+		t.Fatalf("want (%v); got %v", ErrUnsupportedHash, err)
+	}
+}
+
+var words = []merkle.Datum{
+	merkle.StringDatum("alpha"), merkle.StringDatum("beta"), merkle.StringDatum("gamma"),
+	merkle.StringDatum("delta"), merkle.StringDatum("epsilon"),
+}
+
+func TestRoot00(t *testing.T) {
+	tree, err := merkle.NewTree(crypto.SHA256, words...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := Root(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root.Digest, tree.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), root.Digest)
+	}
+	t.Logf("root: %s", root)
+	parsed, err := Parse(root.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.Proof(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyProof(p, parsed) {
+		t.Fatalf("want (true); got false")
+	}
+	other, err := Node(tree, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyProof(p, other) {
+		t.Fatalf("want (false); got true")
+	}
+	if _, err := Node(tree, 5, 0); err == nil {
+		t.Fatalf("want (an error); got <nil>")
+	}
+
+	// The merkle root of the leaves' digests is the digest of their
+	// concatenation, i.e. of a raw block.
+	leaves, err := merkle.NewTree(crypto.SHA256, words[:2]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l0, _ := leaves.Node(0, 0)
+	l1, _ := leaves.Node(0, 1)
+	digest := sha256.Sum256(append(l0, l1...))
+	if root, _ := Root(leaves); !bytes.Equal(root.Digest, digest[:]) {
+		t.Fatalf("want (%x); got %x", digest, root.Digest)
+	}
+
+	truncated, err := merkle.NewTreeWithOptions(crypto.SHA256, words, merkle.TruncateDigests(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err = Root(truncated); err != nil {
+		t.Fatal(err)
+	}
+	mh, err := root.Multihash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mh[1] != 16 {
+		t.Fatalf("want (16); got %d", mh[1])
+	}
+}
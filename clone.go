@@ -0,0 +1,77 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+// Clone returns a deep copy of the merkle tree, which shares no memory with
+// the original; hence, either of them can be modified independently of the
+// other.
+//
+// The merkle nodes of the copy are kept in memory, even if the original reads
+// them through a NodeStore; any node that the NodeStore fails to provide is
+// missing from the copy. The earlier versions of the merkle tree kept through
+// WithHistory are immutable, hence shared rather than copied.
+func (t *Tree) Clone() *Tree {
+	t2 := *t
+
+	// Copy the leaves' digests, serialized data and salts into a single
+	// sequence (each distinct datum only once, if they are deduplicated)...
+	var shared map[string][]byte
+	if t.dedup {
+		shared = make(map[string][]byte)
+	}
+	seqLen := 0
+	for i := range t.tls {
+		seqLen += len(t.tls[i].digest) + len(t.tls[i].datum) + len(t.tls[i].salt)
+	}
+	seq := make([]byte, 0, seqLen)
+	t2.tls = make([]treeLeaf, len(t.tls))
+	for i := range t.tls {
+		t2.tls[i].orderedID = t.tls[i].orderedID
+		seq = append(seq, t.tls[i].digest...)
+		t2.tls[i].digest = seq[len(seq)-len(t.tls[i].digest):]
+		if t.tls[i].salt != nil {
+			seq = append(seq, t.tls[i].salt...)
+			t2.tls[i].salt = seq[len(seq)-len(t.tls[i].salt):]
+		}
+		if t.tls[i].datum == nil {
+			continue
+		}
+		if datum, ok := shared[string(t.tls[i].datum)]; ok {
+			t2.tls[i].datum = datum
+			continue
+		}
+		seq = append(seq, t.tls[i].datum...)
+		t2.tls[i].datum = seq[len(seq)-len(t.tls[i].datum):]
+		if shared != nil {
+			shared[string(t.tls[i].datum)] = t2.tls[i].datum
+		}
+	}
+
+	// ...and the merkle nodes into another one.
+	size := 0
+	if len(t.tls) > 0 {
+		size = len(t.tls[0].digest)
+	}
+	n := newHeapNodes(t.rows, size)
+	for height := 1; height <= n.height(); height++ {
+		for index := 0; index < n.rows[height-1]; index++ {
+			copy(n.at(height, index), t.nodeAt(height, index))
+		}
+	}
+	t2.store, t2.userStore, t2.storeErr = nil, false, nil
+	t2.history = t.history[:len(t.history):len(t.history)]
+	t2.setNodes(n)
+	return &t2
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func TestClone00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := copyBytes(tree.MerkleRoot())
+	clone := tree.Clone()
+	if !bytes.Equal(root, clone.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", root, clone.MerkleRoot())
+	}
+
+	// Modifying the clone must leave the original intact...
+	clone.AppendAndReconstruct(enAlphabetCap...)
+	clone.MerkleRoot()[0] ^= 0xff
+	clone.tls[0].datum[0] ^= 0xff
+	clone.tls[0].digest[0] ^= 0xff
+	if !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", root, tree.MerkleRoot())
+	}
+	var v bool
+	for _, word := range grAlphabet {
+		if v, err = tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+
+	// ...and vice versa.
+	clone = tree.Clone()
+	tree.DeleteAndReconstruct(grAlphabet[:10]...)
+	tree.tls[0].datum[0] ^= 0xff
+	if !bytes.Equal(root, clone.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", root, clone.MerkleRoot())
+	}
+	for _, word := range grAlphabet {
+		if v, err = clone.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+}
+func TestClone01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, DigestOnly(), RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := tree.Clone()
+	if !bytes.Equal(tree.MerkleRoot(), clone.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), clone.MerkleRoot())
+	}
+	if !clone.digestOnly || !clone.insertionOrder || !clone.scheme.isRFC6962() {
+		t.Fatal("clone does not retain the options of the original")
+	}
+	if v, err := clone.VerifyDatum(Q); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", Q, v, err)
+	}
+}
+
+func TestClone02(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithSalts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := tree.Clone()
+	for i := 0; i < clone.NumLeaves(); i++ {
+		d, err := clone.Disclose(i)
+		if err != nil || !d.Verify(tree.MerkleRoot()) {
+			t.Fatalf("want (true, <nil>); got (false, %v)", err)
+		}
+	}
+	if ok, err := clone.VerifyDatum(grAlphabet[3]); !ok || err != nil {
+		t.Fatalf("want (true, <nil>); got (%t, %v)", ok, err)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package cometbft makes merkle trees interoperable with the simple merkle
+// trees of CometBFT (formerly Tendermint), which the Cosmos SDK commits to
+// blocks, transactions and validator sets with.
+//
+// CometBFT hashes as per RFC 6962 with SHA-256; i.e. its merkle roots are the
+// ones of merkle trees that are configured with the merkle.RFC6962 Option, and
+// its inclusion proofs list the same digests as the merkle.Proof of such
+// trees, minus the empty siblings of the nodes that are promoted to the next
+// level on their own.
+//
+// The codecs of Proof are written against the protobuf wire format of the
+// tendermint.crypto.Proof message with the protowire package, rather than
+// generated from its definition, so that using them does not drag the
+// reflection-based protobuf runtime into the binary.
+package cometbft
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"math/bits"
+
+	"github.com/ckatsak/merkle"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	// ErrInvalidProof signifies that a Proof is malformed, or that it does
+	// not lead to the given merkle root.
+	ErrInvalidProof = errors.New("cometbft: invalid proof")
+	// ErrMalformed signifies that the given bytes are not a valid encoding
+	// of a Proof.
+	ErrMalformed = errors.New("cometbft: malformed message")
+	// ErrUnsupported signifies that the given merkle.Proof cannot be
+	// expressed as a Proof, because of its hash function.
+	ErrUnsupported = errors.New("cometbft: unsupported proof")
+)
+
+// maxAunts is the maximum number of aunts that CometBFT accepts in a Proof.
+const maxAunts = 100
+
+// NewTree creates a new merkle tree out of the given items, which hashes the
+// way CometBFT does; i.e. with SHA-256 and as per RFC 6962, keeping the items
+// in the order they are given in.
+func NewTree(items ...[]byte) (*merkle.Tree, error) {
+	data := make([]merkle.Datum, len(items))
+	for i := range items {
+		data[i] = merkle.ByteDatum(items[i])
+	}
+	return merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.RFC6962())
+}
+
+// HashFromByteSlices returns the merkle root of the given items, as CometBFT
+// calculates it; unlike a merkle.Tree, it accepts no items, in which case it
+// returns the SHA-256 digest of the empty string.
+func HashFromByteSlices(items [][]byte) []byte {
+	if len(items) == 0 {
+		ret := sha256.Sum256(nil)
+		return ret[:]
+	}
+	t, err := NewTree(items...)
+	if err != nil {
+		return nil
+	}
+	return t.MerkleRoot()
+}
+
+// Proof corresponds to the Proof of CometBFT (and to the tendermint.crypto.Proof
+// message); i.e. an inclusion proof whose Aunts are the digests of the
+// siblings along the path from the leaf to the merkle root, starting from
+// the leaf's sibling.
+type Proof struct {
+	Total    int64
+	Index    int64
+	LeafHash []byte
+	Aunts    [][]byte
+}
+
+// FromProof converts the given merkle.Proof (of a merkle tree created through
+// NewTree, or otherwise configured with the merkle.RFC6962 Option) to its
+// CometBFT counterpart.
+//
+// It returns a non-nil error if the hash function of the given merkle.Proof
+// is not SHA-256.
+func FromProof(p *merkle.Proof) (*Proof, error) {
+	if p.Hash != crypto.SHA256 {
+		return nil, ErrUnsupported
+	}
+	cp := &Proof{
+		Total:    int64(p.NumLeaves),
+		Index:    int64(p.LeafIndex),
+		LeafHash: p.LeafDigest,
+		Aunts:    make([][]byte, 0, len(p.Siblings)),
+	}
+	for _, sibling := range p.Siblings {
+		if len(sibling) != 0 {
+			cp.Aunts = append(cp.Aunts, sibling)
+		}
+	}
+	return cp, nil
+}
+
+// Verify verifies that the Proof leads to the given merkle root, and that it
+// is a proof of the given (unhashed) leaf, the way CometBFT does.
+func (p *Proof) Verify(rootHash, leaf []byte) error {
+	if p.Total < 0 || p.Index < 0 || len(p.Aunts) > maxAunts {
+		return ErrInvalidProof
+	}
+	if !bytes.Equal(leafHash(leaf), p.LeafHash) {
+		return ErrInvalidProof
+	}
+	if root := p.ComputeRootHash(); root == nil || !bytes.Equal(root, rootHash) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// ComputeRootHash returns the merkle root that the Proof leads to, or nil if
+// the Proof is malformed.
+func (p *Proof) ComputeRootHash() []byte {
+	return hashFromAunts(p.Index, p.Total, p.LeafHash, p.Aunts)
+}
+
+// hashFromAunts recalculates the digest of the subtree of the given number of
+// leaves, given the digest of its leaf at the given index and the aunts of
+// that leaf, up to the root of the subtree. Subtrees are split as per RFC
+// 6962, at the largest power of two that is smaller than their size.
+func hashFromAunts(index, total int64, leafHash []byte, aunts [][]byte) []byte {
+	switch {
+	case index < 0 || index >= total:
+		return nil
+	case total == 1:
+		if len(aunts) != 0 {
+			return nil
+		}
+		return leafHash
+	case len(aunts) == 0:
+		return nil
+	}
+	numLeft := int64(1) << (bits.Len64(uint64(total-1)) - 1)
+	last := aunts[len(aunts)-1]
+	if index < numLeft {
+		if left := hashFromAunts(index, numLeft, leafHash, aunts[:len(aunts)-1]); left != nil {
+			return innerHash(left, last)
+		}
+		return nil
+	}
+	if right := hashFromAunts(index-numLeft, total-numLeft, leafHash, aunts[:len(aunts)-1]); right != nil {
+		return innerHash(last, right)
+	}
+	return nil
+}
+
+// leafHash returns SHA-256(0x00 || leaf).
+func leafHash(leaf []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x00})
+	h.Write(leaf)
+	return h.Sum(nil)
+}
+
+// innerHash returns SHA-256(0x01 || left || right).
+func innerHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// Marshal returns the protobuf wire encoding of the Proof, as a
+// tendermint.crypto.Proof message.
+func (p *Proof) Marshal() ([]byte, error) {
+	var b []byte
+	if p.Total != 0 {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(p.Total))
+	}
+	if p.Index != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(p.Index))
+	}
+	if len(p.LeafHash) != 0 {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, p.LeafHash)
+	}
+	for _, aunt := range p.Aunts {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, aunt)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the given protobuf wire encoding of a
+// tendermint.crypto.Proof message into the Proof. Unknown fields are
+// skipped.
+func (p *Proof) Unmarshal(b []byte) error {
+	var p2 Proof
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+		switch {
+		case (num == 1 || num == 2) && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(b)
+			if num == 1 {
+				p2.Total = int64(v)
+			} else {
+				p2.Index = int64(v)
+			}
+		case (num == 3 || num == 4) && typ == protowire.BytesType:
+			var v []byte
+			v, n = protowire.ConsumeBytes(b)
+			if num == 3 {
+				p2.LeafHash = append([]byte{}, v...)
+			} else {
+				p2.Aunts = append(p2.Aunts, append([]byte{}, v...))
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return ErrMalformed
+		}
+		b = b[n:]
+	}
+	*p = p2
+	return nil
+}
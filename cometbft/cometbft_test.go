@@ -0,0 +1,130 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cometbft
+
+import (
+	"crypto"
+	_ "crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+// testItems and testRoots are the test vectors of the reference
+// implementation of Certificate Transparency, which CometBFT agrees with.
+var (
+	testItems = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	testRoots = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func decodeItems(t *testing.T) [][]byte {
+	items := make([][]byte, len(testItems))
+	for i := range testItems {
+		b, err := hex.DecodeString(testItems[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		items[i] = b
+	}
+	return items
+}
+
+func TestHashFromByteSlices00(t *testing.T) {
+	items := decodeItems(t)
+	if root := hex.EncodeToString(HashFromByteSlices(nil)); root != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("want (e3b0c442...); got %s", root)
+	}
+	for n := 1; n <= len(items); n++ {
+		if root := hex.EncodeToString(HashFromByteSlices(items[:n])); root != testRoots[n-1] {
+			t.Fatalf("want (%s); got %s", testRoots[n-1], root)
+		}
+	}
+}
+
+func TestProof00(t *testing.T) {
+	items := decodeItems(t)
+	for n := 1; n <= len(items); n++ {
+		tree, err := NewTree(items[:n]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < n; i++ {
+			p, err := tree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cp, err := FromProof(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cp.Verify(tree.MerkleRoot(), items[i]); err != nil {
+				t.Fatalf("proof of item %d of %d: %v", i, n, err)
+			}
+			b, err := cp.Marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cp2 Proof
+			if err := cp2.Unmarshal(b); err != nil {
+				t.Fatal(err)
+			}
+			if len(cp.Aunts) == 0 {
+				cp.Aunts = nil
+			}
+			if !reflect.DeepEqual(cp, &cp2) {
+				t.Fatalf("want (%+v); got %+v", cp, &cp2)
+			}
+			if err := cp2.Verify(tree.MerkleRoot(), []byte("kk")); !errors.Is(err, ErrInvalidProof) {
+				t.Fatalf("want (%v); got %v", ErrInvalidProof, err)
+			}
+			if n > 1 {
+				cp2.Aunts[0][0] ^= 0xff
+				if err := cp2.Verify(tree.MerkleRoot(), items[i]); !errors.Is(err, ErrInvalidProof) {
+					t.Fatalf("want (%v) for a tampered aunt; got %v", ErrInvalidProof, err)
+				}
+			}
+		}
+	}
+}
+
+func TestProof01(t *testing.T) {
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA1, []merkle.Datum{merkle.ByteDatum("a")}, merkle.RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.Proof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromProof(p); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	var cp Proof
+	if err := cp.Unmarshal([]byte{0x1a, 0x05, 0x00}); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+)
+
+// The compact encoding of a Proof is meant for links where every byte
+// counts; it omits everything that can be inferred from the rest of it:
+//
+//	+---------+-------+--------+-----------+------------+-------------+----------+
+//	| version | flags |  hash  | numLeaves | directions | leaf digest | siblings |
+//	|   (1)   |  (1)  | varint |  varint   |  (bitmap)  |             |          |
+//	+---------+-------+--------+-----------+------------+-------------+----------+
+//
+// The directions are a bitmap of one bit per level of the merkle tree, from
+// the leaf's up to the root's children, packed least significant bit first; a
+// set bit stands for a node that is the right child of its parent. They are
+// followed by the leaf digest and the digests of the siblings, concatenated,
+// without any lengths. The siblings of the nodes that are the last ones in
+// odd-sized levels are implied by the number of leaves, hence omitted. The
+// high nibble of the flags holds the PaddingPolicy, if compactFlagPadding is
+// set. If compactFlagDigestSize is set, the hash is followed by the size of
+// the (truncated) digests, as a varint (see TruncateDigests).
+const compactVersion byte = 1
+
+const (
+	compactFlagRFC6962 byte = 1 << iota
+	compactFlagPadding
+	compactFlagSortedPairs
+	compactFlagDigestSize
+
+	compactPaddingShift = 4
+)
+
+// MarshalCompact returns the compact binary encoding of the Proof; i.e. a
+// bitmap of the directions of the path from the leaf to the merkle root,
+// followed by the concatenated digests along it. For deep merkle trees, it is
+// considerably smaller than any of the rest of its encodings.
+//
+// It returns a non-nil error if the Proof is malformed, or if it comes from a
+// merkle tree of arity greater than 2 (see WithArity) or of a hash function
+// given through WithHashFunc.
+func (p *Proof) MarshalCompact() ([]byte, error) {
+	s := schemeOrDefault(p.scheme)
+	if s.width() > 2 || s.newHash != nil {
+		return nil, ErrUnsupported
+	}
+	if p.Hash == 0 || p.Hash >= maxHash {
+		return nil, &HashError{Hash: p.Hash}
+	}
+	size := s.newHasher(p.Hash).Size()
+	if p.NumLeaves <= 0 || p.LeafIndex < 0 || p.LeafIndex >= p.NumLeaves || len(p.LeafDigest) != size {
+		return nil, ErrInvalidEncoding
+	}
+
+	var flags byte
+	if s.isRFC6962() {
+		flags |= compactFlagRFC6962
+	}
+	if s.padding != s.impliedPadding() {
+		flags |= compactFlagPadding | byte(s.padding)<<compactPaddingShift
+	}
+	if s.sortedPairs {
+		flags |= compactFlagSortedPairs
+	}
+	if s.digestSize != 0 {
+		flags |= compactFlagDigestSize
+	}
+	b := []byte{compactVersion, flags}
+	b = binary.AppendUvarint(b, uint64(p.Hash))
+	if s.digestSize != 0 {
+		b = binary.AppendUvarint(b, uint64(s.digestSize))
+	}
+	b = binary.AppendUvarint(b, uint64(p.NumLeaves))
+	numLevels := 0
+	for width := p.NumLeaves; width > 1; width = (width + 1) / 2 {
+		numLevels++
+	}
+	if len(p.Siblings) != numLevels {
+		return nil, ErrInvalidEncoding
+	}
+	directions := make([]byte, (numLevels+7)/8)
+	for level := 0; level < numLevels; level++ {
+		if p.LeafIndex>>level&1 == 1 {
+			directions[level/8] |= 1 << (level % 8)
+		}
+	}
+	b = append(b, directions...)
+	b = append(b, p.LeafDigest...)
+
+	index := p.LeafIndex
+	for width, level := p.NumLeaves, 0; width > 1; width, level = (width+1)/2, level+1 {
+		sibling := p.Siblings[level]
+		if isLoneNode(width, index) {
+			if len(sibling) != 0 {
+				return nil, ErrInvalidEncoding
+			}
+		} else if len(sibling) != size {
+			return nil, ErrInvalidEncoding
+		} else {
+			b = append(b, sibling...)
+		}
+		index /= 2
+	}
+	return b, nil
+}
+
+// UnmarshalCompact decodes the given compact encoding (as produced by
+// MarshalCompact) into the Proof.
+//
+// It returns a non-nil error if the given data are not a valid compact
+// encoding of a Proof, or if the hash function it was produced with has not
+// been linked into the binary.
+func (p *Proof) UnmarshalCompact(data []byte) error {
+	d := binaryDecoder{buf: data}
+	if d.byte() != compactVersion {
+		return ErrInvalidEncoding
+	}
+	flags := d.byte()
+	hash := crypto.Hash(d.uvarint())
+	var digestSize uint64
+	if flags&compactFlagDigestSize != 0 {
+		if digestSize = d.uvarint(); digestSize == 0 || digestSize > uint64(len(data)) {
+			return ErrInvalidEncoding
+		}
+	}
+	numLeaves := d.uvarint()
+	if d.err || hash == 0 || hash >= maxHash || numLeaves == 0 || numLeaves > math.MaxInt {
+		return ErrInvalidEncoding
+	}
+	if !hash.Available() {
+		return &HashError{Hash: hash}
+	}
+
+	var s scheme
+	if flags&compactFlagRFC6962 != 0 {
+		s = rfc6962Scheme
+	}
+	if flags&compactFlagPadding != 0 {
+		padding := PaddingPolicy(flags >> compactPaddingShift)
+		if !padding.valid() {
+			return ErrInvalidEncoding
+		}
+		s.padding = padding
+	}
+	s.sortedPairs = flags&compactFlagSortedPairs != 0
+	s.digestSize = int(digestSize)
+	p2 := Proof{Hash: hash, NumLeaves: int(numLeaves)}
+	if !s.isDefault() {
+		p2.scheme = &s
+	}
+
+	numLevels := 0
+	for width := p2.NumLeaves; width > 1; width = (width + 1) / 2 {
+		numLevels++
+	}
+	directions := d.next((numLevels + 7) / 8)
+	if d.err {
+		return ErrInvalidEncoding
+	}
+	for level := 0; level < numLevels; level++ {
+		if directions[level/8]>>(level%8)&1 == 1 {
+			p2.LeafIndex |= 1 << level
+		}
+	}
+	// Bits past the levels of the merkle tree must not be set, so that the
+	// encoding remains unique.
+	if (numLevels%8 != 0 && directions[len(directions)-1]>>(numLevels%8) != 0) || p2.LeafIndex >= p2.NumLeaves {
+		return ErrInvalidEncoding
+	}
+
+	size := s.newHasher(hash).Size()
+	p2.LeafDigest = d.next(size)
+	p2.Siblings = make([][]byte, 0, numLevels)
+	index := p2.LeafIndex
+	for width := p2.NumLeaves; width > 1; width = (width + 1) / 2 {
+		if isLoneNode(width, index) {
+			p2.Siblings = append(p2.Siblings, []byte{})
+		} else {
+			p2.Siblings = append(p2.Siblings, d.next(size))
+		}
+		index /= 2
+	}
+	if d.err || len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	*p = p2
+	return nil
+}
+
+// MarshalCompactText is like MarshalCompact, but it returns the hexadecimal
+// encoding of the result, for text-based protocols.
+func (p *Proof) MarshalCompactText() ([]byte, error) {
+	b, err := p.MarshalCompact()
+	if err != nil {
+		return nil, err
+	}
+	return hexBytes(b).MarshalText()
+}
+
+// UnmarshalCompactText decodes the given hexadecimal compact encoding (as
+// produced by MarshalCompactText) into the Proof.
+func (p *Proof) UnmarshalCompactText(text []byte) error {
+	b := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(b, text); err != nil {
+		return ErrInvalidEncoding
+	}
+	return p.UnmarshalCompact(b)
+}
+
+// isLoneNode reports whether the node at the given index of a level of the
+// given width of a binary merkle tree is the last one in an odd-sized level,
+// hence has no sibling.
+func isLoneNode(width, index int) bool {
+	return width%2 == 1 && index == width-1
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCompact00(t *testing.T) {
+	for _, opts := range [][]Option{nil, {RFC6962()}, {WithPaddingPolicy(DuplicateLast)}} {
+		for numLeaves := 1; numLeaves <= len(grAlphabet); numLeaves++ {
+			tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:numLeaves], opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < numLeaves; i++ {
+				p, err := tree.Proof(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := p.MarshalCompact()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var p2 Proof
+				if err := p2.UnmarshalCompact(b); err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(p, &p2) {
+					t.Fatalf("want (%+v); got %+v", p, &p2)
+				}
+				if !p2.Verify(tree.MerkleRoot()) {
+					t.Fatalf("decoded proof of leaf %d of %d failed", i, numLeaves)
+				}
+			}
+		}
+	}
+}
+
+func TestCompact01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.Proof(13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := p.MarshalCompact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := p.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("compact: %d bytes; JSON: %d bytes", len(b), len(j))
+	if want := 2 + 1 + 1 + 1 + 32*(1+len(p.Siblings)); len(b) != want {
+		t.Fatalf("want (%d); got %d", want, len(b))
+	}
+
+	text, err := p.MarshalCompactText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", text)
+	var p2 Proof
+	if err := p2.UnmarshalCompactText(text); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, &p2) {
+		t.Fatalf("want (%+v); got %+v", p, &p2)
+	}
+
+	// Truncated, extended and tampered encodings must all be rejected.
+	for _, bad := range [][]byte{b[:len(b)-1], append(b[:len(b):len(b)], 0), nil} {
+		if err := p2.UnmarshalCompact(bad); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+		}
+	}
+	bad := append([]byte{}, b...)
+	bad[4] |= 0xf0
+	if err := p2.UnmarshalCompact(bad); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+	if err := p2.UnmarshalCompactText([]byte("zz")); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+
+	p.Siblings = p.Siblings[1:]
+	if _, err := p.MarshalCompact(); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+	wide, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithArity(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err = wide.Proof(0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.MarshalCompact(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "sync"
+
+// Compressor compresses the serialized data that the leaves of a merkle tree
+// retain in memory (see WithCompressor); e.g. with Snappy or Zstandard (see
+// the compress package). A Compressor is shared by the merkle tree and its
+// copies, so it must be safe for concurrent use.
+type Compressor interface {
+	// Compress returns the compressed form of the given serialized datum.
+	// It must not retain the given slice.
+	Compress(serializedDatum []byte) []byte
+	// Decompress returns the serialized datum of the given compressed
+	// form, as returned by Compress, or a non-nil error if it is corrupt.
+	Decompress(compressed []byte) ([]byte, error)
+}
+
+// WithCompressor configures the merkle tree to keep the serialized data of
+// its leaves compressed in memory with the given Compressor, decompressing
+// them on demand; e.g. when they dominate its memory footprint. It has no
+// effect in digest-only mode, where no data are kept at all.
+//
+// The data are decompressed whenever they are looked up or compared with each
+// other; hence, unless InsertionOrder is given too, (re)sorting the leaves
+// requires O(L*log2(L)) decompressions.
+//
+// If the Compressor is an AlgorithmCompressor, the binary encodings of the
+// merkle tree (hence, those of WriteTo and gob too) comprise its data as they
+// are kept, i.e. compressed, along with the CompressionAlgorithm, and decode
+// to merkle trees that keep them compressed alike. Otherwise, and in the rest
+// of the encodings, the data are comprised uncompressed, and decode to merkle
+// trees that keep them uncompressed; see WriteCompressedTo for compressing
+// the binary encodings as a whole.
+func WithCompressor(c Compressor) Option {
+	return func(t *Tree) {
+		t.compressor = c
+	}
+}
+
+// leafDatum returns the serialized datum of the given leaf, decompressing it
+// if the merkle tree keeps it compressed (or nil, if it is corrupt).
+func (t *Tree) leafDatum(tl *treeLeaf) []byte {
+	if t.compressor == nil || tl.datum == nil {
+		return tl.datum
+	}
+	datum, err := t.compressor.Decompress(tl.datum)
+	if err != nil {
+		return nil
+	}
+	if datum == nil {
+		datum = []byte{}
+	}
+	return datum
+}
+
+// storedDatum returns the form of the given serialized datum that the leaves
+// of the merkle tree keep; i.e. compressed, if it has a Compressor.
+func (t *Tree) storedDatum(serializedDatum []byte) []byte {
+	if t.compressor == nil {
+		return serializedDatum
+	}
+	return t.compressor.Compress(serializedDatum)
+}
+
+// CompressionAlgorithm identifies the algorithm of an AlgorithmCompressor in
+// the binary encodings of the merkle trees that keep their data compressed
+// with it, so that they can be decoded to merkle trees that keep them
+// compressed alike.
+type CompressionAlgorithm uint
+
+const (
+	// Snappy is implemented by package compress.
+	Snappy CompressionAlgorithm = 1 + iota
+	// Zstd (Zstandard) is implemented by package compress.
+	Zstd
+	maxCompressionAlgorithm
+)
+
+// AlgorithmCompressor is a Compressor that reports the CompressionAlgorithm
+// that it compresses with, along with its level (which is 0 for algorithms
+// without levels).
+type AlgorithmCompressor interface {
+	Compressor
+	Algorithm() (CompressionAlgorithm, int)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   [maxCompressionAlgorithm]func(level int) Compressor
+)
+
+// RegisterCompressor registers a function that returns a new Compressor of
+// the given CompressionAlgorithm at the given level, so that binary encodings
+// of merkle trees that keep their data compressed with it can be decoded.
+// It is meant to be called from the init function of the package that
+// implements the algorithm (e.g. package compress).
+func RegisterCompressor(a CompressionAlgorithm, f func(level int) Compressor) {
+	if a == 0 || a >= maxCompressionAlgorithm {
+		panic("merkle: RegisterCompressor of unknown compression algorithm")
+	}
+	compressorsMu.Lock()
+	compressors[a] = f
+	compressorsMu.Unlock()
+}
+
+// Available reports whether the given CompressionAlgorithm has been
+// registered (see RegisterCompressor).
+func (a CompressionAlgorithm) Available() bool {
+	return a.constructor() != nil
+}
+
+// constructor returns the function registered for the CompressionAlgorithm,
+// or nil if it has not been registered.
+func (a CompressionAlgorithm) constructor() func(level int) Compressor {
+	if a == 0 || a >= maxCompressionAlgorithm {
+		return nil
+	}
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	return compressors[a]
+}
+
+// encodedCompressor returns the AlgorithmCompressor whose compressed data
+// the binary encodings of the merkle tree comprise, or nil if they comprise
+// its data uncompressed.
+func (t *Tree) encodedCompressor() AlgorithmCompressor {
+	if t.digestOnly {
+		return nil
+	}
+	if c, ok := t.compressor.(AlgorithmCompressor); ok {
+		if a, _ := c.Algorithm(); a != 0 && a < maxCompressionAlgorithm {
+			return c
+		}
+	}
+	return nil
+}
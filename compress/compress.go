@@ -0,0 +1,93 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package compress provides merkle.Compressors for keeping the serialized
+// data of the leaves of merkle trees compressed in memory (see
+// merkle.WithCompressor); i.e. Snappy, which favors speed, as implemented by
+// github.com/golang/snappy, and Zstandard, which favors compression ratio, as
+// implemented by github.com/klauspost/compress/zstd.
+//
+// Importing the package registers both of them (see
+// merkle.RegisterCompressor), so that the binary encodings of merkle trees
+// that keep their data compressed with them decode to merkle trees that keep
+// them compressed alike.
+package compress
+
+import (
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ckatsak/merkle"
+)
+
+func init() {
+	merkle.RegisterCompressor(merkle.Snappy, func(int) merkle.Compressor { return snappyCompressor{} })
+	merkle.RegisterCompressor(merkle.Zstd, NewZstd)
+}
+
+// Snappy configures the merkle tree to keep the serialized data of its leaves
+// compressed with Snappy.
+func Snappy() merkle.Option {
+	return merkle.WithCompressor(snappyCompressor{})
+}
+
+// Zstd configures the merkle tree to keep the serialized data of its leaves
+// compressed with Zstandard, at the given level (from 1, the fastest, to 22,
+// the strongest; it is mapped to the closest level that is implemented).
+func Zstd(level int) merkle.Option {
+	return merkle.WithCompressor(NewZstd(level))
+}
+
+// NewZstd returns a new merkle.Compressor that compresses with Zstandard at
+// the given level (see Zstd).
+func NewZstd(level int) merkle.Compressor {
+	// Neither fails with valid options and no io.Writer or io.Reader.
+	enc, _ := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+	dec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	return &zstdCompressor{enc: enc, dec: dec, level: level}
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) Algorithm() (merkle.CompressionAlgorithm, int) {
+	return merkle.Snappy, 0
+}
+
+func (snappyCompressor) Compress(serializedDatum []byte) []byte {
+	return snappy.Encode(nil, serializedDatum)
+}
+
+func (snappyCompressor) Decompress(compressed []byte) ([]byte, error) {
+	return snappy.Decode(nil, compressed)
+}
+
+// zstdCompressor compresses with Zstandard; both the Encoder and the Decoder
+// are safe for concurrent use of EncodeAll and DecodeAll, respectively.
+type zstdCompressor struct {
+	enc   *zstd.Encoder
+	dec   *zstd.Decoder
+	level int
+}
+
+func (c *zstdCompressor) Algorithm() (merkle.CompressionAlgorithm, int) {
+	return merkle.Zstd, c.level
+}
+
+func (c *zstdCompressor) Compress(serializedDatum []byte) []byte {
+	return c.enc.EncodeAll(serializedDatum, nil)
+}
+
+func (c *zstdCompressor) Decompress(compressed []byte) ([]byte, error) {
+	return c.dec.DecodeAll(compressed, nil)
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package compress
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/json"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+var docs = []merkle.Datum{
+	merkle.StringDatum(`{"id": 1, "name": "alpha", "tags": ["a", "b", "c"], "body": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}`),
+	merkle.StringDatum(`{"id": 2, "name": "beta", "tags": ["a", "b", "c"], "body": "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"}`),
+	merkle.StringDatum(`{"id": 3, "name": "gamma", "tags": ["a", "b", "c"], "body": "cccccccccccccccccccccccccccccccc"}`),
+	merkle.StringDatum(`{"id": 4, "name": "delta", "tags": ["a", "b", "c"], "body": "dddddddddddddddddddddddddddddddd"}`),
+	merkle.StringDatum(`{"id": 5, "name": "epsilon", "tags": ["a", "b", "c"], "body": "eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"}`),
+}
+
+func TestCompressors00(t *testing.T) {
+	want, err := merkle.NewTree(crypto.SHA256, docs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, opt := range map[string]merkle.Option{"snappy": Snappy(), "zstd": Zstd(3)} {
+		tree, err := merkle.NewTreeWithOptions(crypto.SHA256, docs[:4], opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.AppendAndReconstruct(docs[4])
+		if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("%s: want root (%x); got %x", name, want.MerkleRoot(), tree.MerkleRoot())
+		}
+		for _, d := range docs {
+			if v, err := tree.VerifyDatum(d); err != nil || !v {
+				t.Fatalf("%s: ERROR while verifying %s: (%v, %v)", name, d.Serialize(), v, err)
+			}
+		}
+		leaves := tree.Leaves()
+		for i := range docs {
+			if !bytes.Equal(leaves[i], docs[i].Serialize()) {
+				t.Fatalf("%s: want leaf %d (%s); got %s", name, i, docs[i].Serialize(), leaves[i])
+			}
+		}
+
+		// The encodings comprise the data uncompressed.
+		b, err := json.Marshal(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantB, err := json.Marshal(want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, wantB) {
+			t.Fatalf("%s: want (%s); got %s", name, wantB, b)
+		}
+	}
+}
+
+func TestCompressors01(t *testing.T) {
+	want, err := merkle.NewTree(crypto.SHA256, docs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantB, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, opt := range map[string]merkle.Option{"snappy": Snappy(), "zstd": Zstd(19)} {
+		tree, err := merkle.NewTreeWithOptions(crypto.SHA256, docs, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := tree.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		b := bytes.Clone(buf.Bytes())
+		if len(b) >= len(wantB) {
+			t.Fatalf("%s: want fewer than %d bytes; got %d", name, len(wantB), len(b))
+		}
+
+		// The snapshot restores to a merkle tree that keeps its data
+		// compressed alike, hence re-encodes to the same bytes.
+		restored, err := merkle.ReadTreeFrom(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restored.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("%s: want root (%x); got %x", name, want.MerkleRoot(), restored.MerkleRoot())
+		}
+		restoredB, err := restored.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(restoredB, b) {
+			t.Fatalf("%s: want (%x); got %x", name, b, restoredB)
+		}
+		leaves := restored.Leaves()
+		for i := range docs {
+			if !bytes.Equal(leaves[i], docs[i].Serialize()) {
+				t.Fatalf("%s: want leaf %d (%s); got %s", name, i, docs[i].Serialize(), leaves[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"errors"
+	"io"
+	"testing"
+)
+
+// flateCompressor is a Compressor that counts the data it compresses.
+type flateCompressor struct {
+	compressed int
+}
+
+func (c *flateCompressor) Compress(serializedDatum []byte) []byte {
+	c.compressed++
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.BestCompression)
+	w.Write(serializedDatum)
+	w.Close()
+	return buf.Bytes()
+}
+
+func (c *flateCompressor) Decompress(compressed []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+}
+
+func TestWithCompressor00(t *testing.T) {
+	want, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &flateCompressor{}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:20], WithCompressor(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(grAlphabet[20:]...)
+	if c.compressed != len(grAlphabet) {
+		t.Fatalf("want (%d) compressions; got %d", len(grAlphabet), c.compressed)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	if !bytes.Equal(tree.tls[0].datum, c.Compress(tree.Leaves()[0])) {
+		t.Fatalf("want datum (%x); got %x", c.Compress(tree.Leaves()[0]), tree.tls[0].datum)
+	}
+	for _, d := range grAlphabet {
+		if v, err := tree.VerifyDatum(d); err != nil || !v {
+			t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+		}
+	}
+	if v, err := tree.VerifyDatum(Word("Ω")); !errors.Is(err, ErrNoData) || v {
+		t.Fatalf("want (false, %v); got (%v, %v)", ErrNoData, v, err)
+	}
+
+	// The encodings comprise the data uncompressed.
+	wantB, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, wantB) {
+		t.Fatalf("want (%x); got %x", wantB, b)
+	}
+
+	tree.DeleteAndReconstruct(grAlphabet[3])
+	want.DeleteAndReconstruct(grAlphabet[3])
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	t.Logf("root: %x", tree.MerkleRoot())
+}
+
+// zstdFlateCompressor is a flateCompressor that claims to be Zstd, which has
+// not been registered in the tests of the package.
+type zstdFlateCompressor struct {
+	flateCompressor
+}
+
+func (*zstdFlateCompressor) Algorithm() (CompressionAlgorithm, int) {
+	return Zstd, 7
+}
+
+func TestWithCompressor01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithCompressor(&zstdFlateCompressor{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The encoding comprises the data compressed, along with the algorithm.
+	if !bytes.Contains(b, tree.tls[0].datum) || bytes.Contains(b, tree.Leaves()[0]) {
+		t.Fatalf("want the compressed datum (%x) in %x", tree.tls[0].datum, b)
+	}
+	if Zstd.Available() {
+		t.Fatalf("want (false); got %v", Zstd.Available())
+	}
+	if err := new(Tree).UnmarshalBinary(b); !errors.Is(err, ErrCompressorUnavailable) {
+		t.Fatalf("want (%v); got %v", ErrCompressorUnavailable, err)
+	}
+
+	// In digest-only mode, there are no data to compress.
+	tree, err = NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly(), WithCompressor(&zstdFlateCompressor{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = tree.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Tree).UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "crypto"
+
+// HashLeaf returns the digest of the leaf of the given serialized datum, as
+// hashed by the merkle trees of the given hash function and Options; e.g.
+// H(datum), or H(0x00 || datum) along with RFC6962. It lets external code
+// reproduce the hashing of this package without a Tree.
+//
+// The salts of WithSalts are not applied; the salted datum is to be given
+// instead (see Disclosure).
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary.
+func HashLeaf(hash crypto.Hash, serializedDatum []byte, opts ...Option) ([]byte, error) {
+	t, err := newHashingTree(hash, opts)
+	if err != nil {
+		return nil, err
+	}
+	return t.scheme.hashLeaf(t.newHasher(), serializedDatum), nil
+}
+
+// HashNode returns the digest of the merkle node of the given left and right
+// children, as hashed by the merkle trees of the given hash function and
+// Options; e.g. H(left || right), or H(0x01 || left || right) along with
+// RFC6962. For trees of arity greater than 2 (see WithArity), the two
+// children are padded as per the PaddingPolicy.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary.
+func HashNode(hash crypto.Hash, left, right []byte, opts ...Option) ([]byte, error) {
+	t, err := newHashingTree(hash, opts)
+	if err != nil {
+		return nil, err
+	}
+	return t.scheme.hashChildren(t.newHasher(), [][]byte{left, right}), nil
+}
+
+// ComputeRoot returns the merkle root of the leaves of the given digests, in
+// the order they are given in, as calculated by the merkle trees of the given
+// hash function and Options; i.e. the merkle root of the tree that
+// NewTreeFromDigests would create out of them along with InsertionOrder. To
+// reproduce the merkle root of a tree whose leaves are sorted, their digests
+// are to be given in the order of the leaves (see Level).
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if no digests are given, if any of them is not of
+// the size that the hash function produces, or if the fixed depth of the
+// Options (see FixedDepth) does not allow for them.
+func ComputeRoot(hash crypto.Hash, leafDigests [][]byte, opts ...Option) ([]byte, error) {
+	t, err := NewTreeFromDigests(hash, leafDigests, append(opts[:len(opts):len(opts)], InsertionOrder())...)
+	if err != nil {
+		return nil, err
+	}
+	return t.MerkleRoot(), nil
+}
+
+// newHashingTree returns a merkle tree of no leaves that is configured with
+// the given hash function and Options, so that it can only hash.
+func newHashingTree(hash crypto.Hash, opts []Option) (*Tree, error) {
+	t := &Tree{hash: hash}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if !t.hashAvailable() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	return t, nil
+}
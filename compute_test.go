@@ -0,0 +1,64 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"testing"
+)
+
+func TestComputeRoot00(t *testing.T) {
+	for _, opts := range [][]Option{nil, {RFC6962()}, {SortedPairs()}, {WithArity(3)}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tree.tls {
+			digest, err := HashLeaf(crypto.SHA256, tree.tls[i].datum, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(digest, tree.tls[i].digest) {
+				t.Fatalf("want leaf %d digest (%x); got %x", i, tree.tls[i].digest, digest)
+			}
+		}
+		root, err := ComputeRoot(crypto.SHA256, tree.Level(0), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("root: %x", root)
+		if !bytes.Equal(root, tree.MerkleRoot()) {
+			t.Fatalf("want root (%x); got %x", tree.MerkleRoot(), root)
+		}
+	}
+
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:2], RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := HashNode(crypto.SHA256, tree.tls[0].digest, tree.tls[1].digest, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(append(append([]byte{1}, tree.tls[0].digest...), tree.tls[1].digest...))
+	if !bytes.Equal(node, want[:]) || !bytes.Equal(node, tree.MerkleRoot()) {
+		t.Fatalf("want node (%x); got %x", want, node)
+	}
+	if _, err := ComputeRoot(crypto.SHA256, nil); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package ct builds the leaves of Certificate Transparency logs; i.e. the
+// MerkleTreeLeaf structure of RFC 6962 (section 3.4), in its TLS encoding,
+// so that merkle trees configured with the merkle.RFC6962 Option (or a
+// log.Log) can back a minimal CT-style log, or verify the entries that a CT
+// monitor fetches from one.
+//
+// A MerkleTreeLeaf is a merkle.Datum that serializes to its TLS encoding, so
+// it can be given to a merkle tree as is; its LeafHash is the digest of the
+// corresponding leaf of the log.
+package ct
+
+import (
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ckatsak/merkle/log"
+)
+
+// ErrMalformed signifies that the given bytes are not a valid TLS encoding
+// of a MerkleTreeLeaf, or that a MerkleTreeLeaf cannot be encoded.
+var ErrMalformed = errors.New("ct: malformed leaf")
+
+// Limits of the variable-length fields of a TimestampedEntry.
+const (
+	maxCert       = 1<<24 - 1
+	maxExtensions = 1<<16 - 1
+)
+
+// Version is the version of the MerkleTreeLeaf structure; V1 is the only one
+// defined by RFC 6962.
+type Version uint8
+
+// V1 is the version of RFC 6962.
+const V1 Version = 0
+
+// MerkleLeafType is the type of a MerkleTreeLeaf; TimestampedEntryLeafType
+// is the only one defined by RFC 6962.
+type MerkleLeafType uint8
+
+// TimestampedEntryLeafType is the type of the leaves that hold a
+// TimestampedEntry.
+const TimestampedEntryLeafType MerkleLeafType = 0
+
+// LogEntryType is the type of the entry of a TimestampedEntry.
+type LogEntryType uint16
+
+// The types of the entries of RFC 6962.
+const (
+	X509LogEntryType    LogEntryType = 0
+	PrecertLogEntryType LogEntryType = 1
+)
+
+// PreCert is the entry of a precertificate; i.e. the SHA-256 digest of the
+// public key of its issuer, and its DER-encoded TBSCertificate.
+type PreCert struct {
+	IssuerKeyHash  [32]byte
+	TBSCertificate []byte
+}
+
+// TimestampedEntry is an entry of a CT log, along with the time it was
+// accepted at, in milliseconds since the Unix epoch. Depending on its
+// EntryType, either X509Entry (a DER-encoded certificate) or PrecertEntry is
+// set.
+type TimestampedEntry struct {
+	Timestamp    uint64
+	EntryType    LogEntryType
+	X509Entry    []byte
+	PrecertEntry *PreCert
+	Extensions   []byte
+}
+
+// MerkleTreeLeaf is a leaf of a CT log.
+type MerkleTreeLeaf struct {
+	Version          Version
+	LeafType         MerkleLeafType
+	TimestampedEntry TimestampedEntry
+}
+
+// NewX509Leaf returns the MerkleTreeLeaf of the given DER-encoded
+// certificate, accepted at the given time.
+func NewX509Leaf(timestamp time.Time, cert []byte) *MerkleTreeLeaf {
+	return &MerkleTreeLeaf{
+		TimestampedEntry: TimestampedEntry{
+			Timestamp: uint64(timestamp.UnixMilli()),
+			EntryType: X509LogEntryType,
+			X509Entry: cert,
+		},
+	}
+}
+
+// NewPrecertLeaf returns the MerkleTreeLeaf of the precertificate of the
+// given DER-encoded TBSCertificate, whose issuer's public key has the given
+// SHA-256 digest, accepted at the given time.
+func NewPrecertLeaf(timestamp time.Time, issuerKeyHash [32]byte, tbs []byte) *MerkleTreeLeaf {
+	return &MerkleTreeLeaf{
+		TimestampedEntry: TimestampedEntry{
+			Timestamp: uint64(timestamp.UnixMilli()),
+			EntryType: PrecertLogEntryType,
+			PrecertEntry: &PreCert{
+				IssuerKeyHash:  issuerKeyHash,
+				TBSCertificate: tbs,
+			},
+		},
+	}
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface; it
+// returns the TLS encoding of the MerkleTreeLeaf.
+//
+// It returns a non-nil error if the MerkleTreeLeaf is of an unknown version,
+// type or entry type, or if its fields do not fit in their encodings.
+func (l *MerkleTreeLeaf) MarshalBinary() ([]byte, error) {
+	e := &l.TimestampedEntry
+	if l.Version != V1 || l.LeafType != TimestampedEntryLeafType || len(e.Extensions) > maxExtensions {
+		return nil, ErrMalformed
+	}
+	b := []byte{byte(l.Version), byte(l.LeafType)}
+	b = binary.BigEndian.AppendUint64(b, e.Timestamp)
+	b = binary.BigEndian.AppendUint16(b, uint16(e.EntryType))
+	switch e.EntryType {
+	case X509LogEntryType:
+		if len(e.X509Entry) == 0 || len(e.X509Entry) > maxCert {
+			return nil, ErrMalformed
+		}
+		b = appendUint24Bytes(b, e.X509Entry)
+	case PrecertLogEntryType:
+		if e.PrecertEntry == nil || len(e.PrecertEntry.TBSCertificate) == 0 || len(e.PrecertEntry.TBSCertificate) > maxCert {
+			return nil, ErrMalformed
+		}
+		b = append(b, e.PrecertEntry.IssuerKeyHash[:]...)
+		b = appendUint24Bytes(b, e.PrecertEntry.TBSCertificate)
+	default:
+		return nil, ErrMalformed
+	}
+	b = binary.BigEndian.AppendUint16(b, uint16(len(e.Extensions)))
+	return append(b, e.Extensions...), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (l *MerkleTreeLeaf) UnmarshalBinary(data []byte) error {
+	if len(data) < 12 || Version(data[0]) != V1 || MerkleLeafType(data[1]) != TimestampedEntryLeafType {
+		return ErrMalformed
+	}
+	var l2 MerkleTreeLeaf
+	e := &l2.TimestampedEntry
+	e.Timestamp = binary.BigEndian.Uint64(data[2:])
+	e.EntryType = LogEntryType(binary.BigEndian.Uint16(data[10:]))
+	data = data[12:]
+	var ok bool
+	switch e.EntryType {
+	case X509LogEntryType:
+		if e.X509Entry, data, ok = readUint24Bytes(data); !ok {
+			return ErrMalformed
+		}
+	case PrecertLogEntryType:
+		if len(data) < 32 {
+			return ErrMalformed
+		}
+		e.PrecertEntry = &PreCert{}
+		copy(e.PrecertEntry.IssuerKeyHash[:], data)
+		if e.PrecertEntry.TBSCertificate, data, ok = readUint24Bytes(data[32:]); !ok {
+			return ErrMalformed
+		}
+	default:
+		return ErrMalformed
+	}
+	if len(data) < 2 || len(data)-2 != int(binary.BigEndian.Uint16(data)) {
+		return ErrMalformed
+	}
+	e.Extensions = append([]byte{}, data[2:]...)
+	*l = l2
+	return nil
+}
+
+// Serialize implements the merkle.Datum interface; it returns the TLS
+// encoding of the MerkleTreeLeaf, or nil if it cannot be encoded.
+func (l *MerkleTreeLeaf) Serialize() []byte {
+	b, err := l.MarshalBinary()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// LeafHash returns the digest of the leaf of a CT log that holds the
+// MerkleTreeLeaf; i.e. SHA-256(0x00 || MerkleTreeLeaf), as per RFC 6962.
+//
+// It returns a non-nil error if the MerkleTreeLeaf cannot be encoded.
+func (l *MerkleTreeLeaf) LeafHash() ([]byte, error) {
+	b, err := l.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return log.LeafHash(crypto.SHA256, b), nil
+}
+
+// appendUint24Bytes appends the given bytes to b, prefixed with their length
+// in 3 bytes.
+func appendUint24Bytes(b, v []byte) []byte {
+	b = append(b, byte(len(v)>>16), byte(len(v)>>8), byte(len(v)))
+	return append(b, v...)
+}
+
+// readUint24Bytes reads a copy of the bytes prefixed with their length in 3
+// bytes, off the start of data, returning the rest of them as well; it
+// reports whether they are well-formed and non-empty.
+func readUint24Bytes(data []byte) (v, rest []byte, ok bool) {
+	if len(data) < 3 {
+		return nil, nil, false
+	}
+	n := int(data[0])<<16 | int(data[1])<<8 | int(data[2])
+	if n == 0 || len(data) < 3+n {
+		return nil, nil, false
+	}
+	return append([]byte{}, data[3:3+n]...), data[3+n:], true
+}
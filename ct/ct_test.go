@@ -0,0 +1,88 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ct
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/log"
+)
+
+func TestMerkleTreeLeaf00(t *testing.T) {
+	l := NewX509Leaf(time.UnixMilli(0x0102030405), []byte{0xaa, 0xbb})
+	l.TimestampedEntry.Extensions = []byte{0xcc}
+	b, err := l.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "0000" + "0000000102030405" + "0000" + "000002aabb" + "0001cc"
+	if got := hex.EncodeToString(b); got != want {
+		t.Fatalf("want (%s); got %s", want, got)
+	}
+	var l2 MerkleTreeLeaf
+	if err := l2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(l2.Serialize(), b) {
+		t.Fatalf("want (%x); got %x", b, l2.Serialize())
+	}
+	if err := l2.UnmarshalBinary(b[:len(b)-1]); err != ErrMalformed {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+
+	p := NewPrecertLeaf(time.UnixMilli(7), [32]byte{1, 2, 3}, []byte("tbs"))
+	if b, err = p.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if pe := l2.TimestampedEntry.PrecertEntry; pe == nil || pe.IssuerKeyHash != p.TimestampedEntry.PrecertEntry.IssuerKeyHash || string(pe.TBSCertificate) != "tbs" {
+		t.Fatalf("want (%+v); got %+v", p.TimestampedEntry.PrecertEntry, pe)
+	}
+
+	if _, err := NewX509Leaf(time.Now(), nil).MarshalBinary(); err != ErrMalformed {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+}
+
+func TestMerkleTreeLeaf01(t *testing.T) {
+	data := make([]merkle.Datum, 5)
+	for i := range data {
+		data[i] = NewX509Leaf(time.UnixMilli(int64(i)), []byte{byte(i) + 1})
+	}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		leafHash, err := data[i].(*MerkleTreeLeaf).LeafHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := tree.InclusionProof(i, len(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !log.VerifyInclusion(crypto.SHA256, uint64(i), uint64(len(data)), leafHash, proof, tree.MerkleRoot()) {
+			t.Fatalf("leaf %d does not verify", i)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// ErrSerializer may optionally be implemented by a Datum whose serialization
+// may fail; the merkle tree prefers SerializeErr over Serialize, so that such
+// a failure is returned as an error rather than e.g. a panic.
+type ErrSerializer interface {
+	// SerializeErr must return the serialized representation of the
+	// Datum, or a non-nil error if it cannot be serialized.
+	SerializeErr() ([]byte, error)
+}
+
+// StreamSerializer may optionally be implemented by a Datum that is too large
+// to be serialized into memory as a whole. In digest-only mode, the merkle tree
+// prefers SerializeTo over both SerializeErr and Serialize, and streams the
+// serialized Datum straight into the hash function, unless the hashing scheme
+// calls for the serialized Datum up front (e.g. LengthPrefixed, WithLeafKey or
+// WithHasher); otherwise, it prefers SerializeErr over SerializeTo, and the
+// latter over Serialize.
+type StreamSerializer interface {
+	// SerializeTo must write the serialized representation of the Datum
+	// to the given io.Writer, or return a non-nil error if it cannot be
+	// serialized.
+	SerializeTo(w io.Writer) error
+}
+
+type (
+	// ByteDatum adapts a byte slice to the Datum interface; it serializes to
+	// itself.
+	ByteDatum []byte
+
+	// StringDatum adapts a string to the Datum interface; it serializes to
+	// its bytes.
+	StringDatum string
+
+	// FieldsDatum adapts a sequence of fields to the Datum interface; it
+	// serializes to their concatenation, each prefixed with its length (as
+	// a big-endian uint64), so that no two different sequences of fields
+	// serialize the same way (e.g. ["ab", "c"] and ["a", "bc"]).
+	FieldsDatum [][]byte
+)
+
+// Serialize implements the Datum interface.
+func (d ByteDatum) Serialize() []byte {
+	return d
+}
+
+// Serialize implements the Datum interface.
+func (d StringDatum) Serialize() []byte {
+	return []byte(d)
+}
+
+// Serialize implements the Datum interface.
+func (d FieldsDatum) Serialize() []byte {
+	size := 0
+	for _, field := range d {
+		size += 8 + len(field)
+	}
+	b := make([]byte, 0, size)
+	for _, field := range d {
+		b = appendFramed(b, field)
+	}
+	return b
+}
+
+// appendFramed appends the given bytes to b, prefixed with their length as a
+// big-endian uint64.
+func appendFramed(b, v []byte) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// serializeDatum returns the serialized representation of the given Datum, as
+// returned by SerializeErr or written by SerializeTo, if it implements either
+// of them, or as returned by Serialize otherwise.
+func serializeDatum(d Datum) ([]byte, error) {
+	switch d := d.(type) {
+	case ErrSerializer:
+		return d.SerializeErr()
+	case StreamSerializer:
+		var buf bytes.Buffer
+		if err := d.SerializeTo(&buf); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return d.Serialize(), nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+)
+
+var errSerialize = errors.New("serialize")
+
+// failingDatum fails to be serialized through SerializeErr.
+type failingDatum struct{}
+
+func (failingDatum) Serialize() []byte { panic("Serialize called") }
+
+func (failingDatum) SerializeErr() ([]byte, error) { return nil, errSerialize }
+
+// streamedDatum serializes to the same bytes as the StringDatum, but it is
+// only written out through SerializeTo.
+type streamedDatum string
+
+func (streamedDatum) Serialize() []byte { panic("Serialize called") }
+
+func (d streamedDatum) SerializeTo(w io.Writer) error {
+	if d == "" {
+		return errSerialize
+	}
+	_, err := io.WriteString(w, string(d))
+	return err
+}
+
+func TestNewTreeFromBytes00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([][]byte, len(grAlphabet))
+	for i := range grAlphabet {
+		data[i] = grAlphabet[i].Serialize()
+	}
+	tree2, err := NewTreeFromBytes(crypto.SHA256, data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+
+	for _, datum := range []Datum{ByteDatum("kappa"), StringDatum("kappa")} {
+		if v, err := tree2.VerifyDatum(datum); err != nil || !v {
+			t.Fatalf("ERROR while verifying %#v: (%v, %v)", datum, v, err)
+		}
+	}
+	if _, err = NewTreeFromBytes(crypto.SHA256); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+
+func TestFieldsDatum00(t *testing.T) {
+	a := FieldsDatum{[]byte("ab"), []byte("c")}.Serialize()
+	b := FieldsDatum{[]byte("a"), []byte("bc")}.Serialize()
+	t.Logf("a: %x", a)
+	t.Logf("b: %x", b)
+	if bytes.Equal(a, b) {
+		t.Fatalf("want different serializations; got %x", a)
+	}
+	if want := "00000000000000026162000000000000000163"; hex.EncodeToString(a) != want {
+		t.Fatalf("want (%s); got %x", want, a)
+	}
+	if len(FieldsDatum{}.Serialize()) != 0 {
+		t.Fatalf("want empty serialization; got %x", FieldsDatum{}.Serialize())
+	}
+}
+
+func TestErrSerializer00(t *testing.T) {
+	if _, err := NewTree(crypto.SHA256, grAlphabet[0], failingDatum{}); !errors.Is(err, errSerialize) {
+		t.Fatalf("want (%v); got %v", errSerialize, err)
+	}
+
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+	tree.AppendAndReconstruct(StringDatum("omega2"), failingDatum{})
+	tree.DeleteAndReconstruct(grAlphabet[0], failingDatum{})
+	t.Logf("DatumErr: %v", tree.DatumErr())
+	if !errors.Is(tree.DatumErr(), errSerialize) {
+		t.Fatalf("want (%v); got %v", errSerialize, tree.DatumErr())
+	}
+	if !bytes.Equal(root, tree.MerkleRoot()) || tree.NumLeaves() != len(grAlphabet) {
+		t.Fatalf("want intact tree (%x); got %x", root, tree.MerkleRoot())
+	}
+	if _, err := tree.VerifyDatum(failingDatum{}); !errors.Is(err, errSerialize) {
+		t.Fatalf("want (%v); got %v", errSerialize, err)
+	}
+	if _, err := tree.ProveDatum(failingDatum{}); !errors.Is(err, errSerialize) {
+		t.Fatalf("want (%v); got %v", errSerialize, err)
+	}
+	b, err := NewBuilder(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(failingDatum{}); !errors.Is(err, errSerialize) {
+		t.Fatalf("want (%v); got %v", errSerialize, err)
+	}
+}
+
+func TestStreamSerializer00(t *testing.T) {
+	for _, opts := range [][]Option{nil, {DigestOnly()}, {DigestOnly(), LengthPrefixed()}} {
+		data := []Datum{StringDatum("alpha"), StringDatum("beta"), StringDatum("gamma")}
+		streamed := []Datum{streamedDatum("alpha"), streamedDatum("beta"), streamedDatum("gamma")}
+		tree, err := NewTreeWithOptions(crypto.SHA256, data, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree2, err := NewTreeWithOptions(crypto.SHA256, streamed, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+		}
+		if v, err := tree.VerifyDatum(streamedDatum("beta")); err != nil || !v {
+			t.Fatalf("ERROR while verifying %q: (%v, %v)", "beta", v, err)
+		}
+		tree2.DeleteAndReconstruct(streamedDatum("gamma"))
+		tree2.AppendAndReconstruct(StringDatum("gamma"))
+		if tree2.DatumErr() != nil || !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x (%v)", tree.MerkleRoot(), tree2.MerkleRoot(), tree2.DatumErr())
+		}
+		if _, err := tree.IndexOf(streamedDatum("")); !errors.Is(err, errSerialize) {
+			t.Fatalf("want (%v); got %v", errSerialize, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+// DeduplicateData configures the merkle tree to keep a single copy of each
+// distinct serialized datum in memory, shared by all of its leaves that
+// retain it, rather than one copy per leaf; e.g. for datasets with heavy
+// repetition, where duplicate data are permitted. It has no effect in
+// digest-only mode, where no data are kept at all.
+//
+// The distinct data are tracked in a map, which is pruned of the ones that no
+// leaf retains anymore (e.g. after DeleteAndReconstruct) once it has doubled
+// in size; a merkle tree derived from another one (e.g. through Appended or
+// Snapshot) tracks its own, which it builds upon its first mutation, in time
+// linear to the number of its leaves.
+func DeduplicateData() Option {
+	return func(t *Tree) {
+		t.dedup = true
+	}
+}
+
+// minPayloadPool is the number of distinct data that a payloadPool tracks
+// before it is ever pruned.
+const minPayloadPool = 1024
+
+// payloadPool tracks the distinct serialized data that the leaves of the
+// merkle tree that owns it retain, as stored by them (see storedDatum).
+type payloadPool struct {
+	owner    *Tree
+	payloads map[string][]byte
+	limit    int
+}
+
+// internDatum returns the copy of the given stored datum that the leaves of
+// the merkle tree share, if it deduplicates its data; the given one becomes
+// that copy, if it is the first of its kind.
+func (t *Tree) internDatum(datum []byte) []byte {
+	if !t.dedup {
+		return datum
+	}
+	if t.pool == nil || t.pool.owner != t {
+		t.pool = t.newPayloadPool()
+	}
+	if shared, ok := t.pool.payloads[string(datum)]; ok {
+		return shared
+	}
+	t.pool.payloads[string(datum)] = datum
+	return datum
+}
+
+// prunePayloads drops the data that no leaf retains anymore from the
+// payloadPool of the merkle tree, if it has grown beyond its limit.
+func (t *Tree) prunePayloads() {
+	if t.pool != nil && t.pool.owner == t && len(t.pool.payloads) > t.pool.limit {
+		t.pool = t.newPayloadPool()
+	}
+}
+
+// newPayloadPool returns a new payloadPool owned by the merkle tree, which
+// tracks the data that its leaves retain.
+func (t *Tree) newPayloadPool() *payloadPool {
+	p := &payloadPool{owner: t, payloads: make(map[string][]byte)}
+	for i := range t.tls {
+		if datum := t.tls[i].datum; datum != nil {
+			if _, ok := p.payloads[string(datum)]; !ok {
+				p.payloads[string(datum)] = datum
+			}
+		}
+	}
+	p.limit = 2*len(p.payloads) + minPayloadPool
+	return p
+}
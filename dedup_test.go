@@ -0,0 +1,95 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+// sameBacking reports whether the given non-empty slices share their first
+// element.
+func sameBacking(a, b []byte) bool {
+	return &a[0] == &b[0]
+}
+
+func TestDeduplicateData00(t *testing.T) {
+	data := make([]Datum, 0, 3*len(grAlphabet))
+	for i := 0; i < 3; i++ {
+		data = append(data, grAlphabet...)
+	}
+	want, err := NewTreeWithOptions(crypto.SHA256, data, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := NewTreeWithOptions(crypto.SHA256, data[:len(grAlphabet)+5], InsertionOrder(), DeduplicateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(data[len(grAlphabet)+5:]...)
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	if len(tree.pool.payloads) != len(grAlphabet) {
+		t.Fatalf("want (%d) distinct data; got %d", len(grAlphabet), len(tree.pool.payloads))
+	}
+	for i := range grAlphabet {
+		for j := 1; j < 3; j++ {
+			a, b := tree.tls[i].datum, tree.tls[i+j*len(grAlphabet)].datum
+			if !sameBacking(a, b) {
+				t.Fatalf("want leaves %d and %d to share %q", i, i+j*len(grAlphabet), a)
+			}
+		}
+	}
+
+	// The copies of the merkle tree keep deduplicating their data.
+	clone := tree.Clone()
+	if !sameBacking(clone.tls[0].datum, clone.tls[len(grAlphabet)].datum) || sameBacking(clone.tls[0].datum, tree.tls[0].datum) {
+		t.Fatalf("want the clone to share %q among its own leaves only", clone.tls[0].datum)
+	}
+	appended, err := tree.Appended(grAlphabet[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := appended.tls[len(appended.tls)-1].datum; !sameBacking(last, tree.tls[0].datum) {
+		t.Fatalf("want the appended leaf to share %q", last)
+	}
+	if tree.pool.owner != tree || appended.pool.owner != appended {
+		t.Fatal("want each merkle tree to own its payloadPool")
+	}
+}
+
+func TestDeduplicateData01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DeduplicateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.pool.limit = len(grAlphabet) + 2
+
+	// The data of the deleted leaves are dropped once the pool outgrows
+	// its limit.
+	tree.DeleteAndReconstruct(grAlphabet[:10]...)
+	if len(tree.pool.payloads) != len(grAlphabet) {
+		t.Fatalf("want (%d) distinct data; got %d", len(grAlphabet), len(tree.pool.payloads))
+	}
+	tree.AppendAndReconstruct(Word("Ϙ"), Word("Ϛ"), Word("Ϝ"))
+	if want := len(grAlphabet) - 10 + 3; len(tree.pool.payloads) != want {
+		t.Fatalf("want (%d) distinct data; got %d", want, len(tree.pool.payloads))
+	}
+	if _, ok := tree.pool.payloads[string(grAlphabet[0].Serialize())]; ok {
+		t.Fatalf("want %q pruned", grAlphabet[0].Serialize())
+	}
+}
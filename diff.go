@@ -0,0 +1,92 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "bytes"
+
+// Diff compares the given merkle trees and returns the leaves (i.e. their
+// serialized data or, in digest-only mode, their digests) that are present
+// only in a and only in b, respectively, in the order of each tree's leaves.
+//
+// Diff descends both trees in parallel from their roots, and skips every
+// subtree whose digest is the same in both; hence, it only visits the leaves
+// under the subtrees that differ, which is what makes it suitable for
+// anti-entropy repair between replicas.
+//
+// It returns a non-nil error if the two trees do not share the same hash
+// function and hashing scheme, in which case their digests are not
+// comparable.
+func Diff(a, b *Tree) (onlyA, onlyB [][]byte, err error) {
+	if a.hash != b.hash || !a.scheme.equal(&b.scheme) {
+		return nil, nil, ErrUnsupported
+	}
+	height := len(a.rows)
+	if len(b.rows) > height {
+		height = len(b.rows)
+	}
+	var candA, candB []int
+	a.diff(b, height, 0, &candA, &candB)
+
+	inA := make(map[string]struct{}, len(candA))
+	for _, i := range candA {
+		inA[string(a.tls[i].digest)] = struct{}{}
+	}
+	inB := make(map[string]struct{}, len(candB))
+	for _, i := range candB {
+		inB[string(b.tls[i].digest)] = struct{}{}
+	}
+	for _, i := range candA {
+		if _, ok := inB[string(a.tls[i].digest)]; !ok {
+			onlyA = append(onlyA, copyBytes(a.key(&a.tls[i])))
+		}
+	}
+	for _, i := range candB {
+		if _, ok := inA[string(b.tls[i].digest)]; !ok {
+			onlyB = append(onlyB, copyBytes(b.key(&b.tls[i])))
+		}
+	}
+	return onlyA, onlyB, nil
+}
+
+// diff descends the subtrees of t and u at the given height and index, and
+// collects the indices of the leaves under the ones that differ.
+func (t *Tree) diff(u *Tree, height, index int, candT, candU *[]int) {
+	nt, nu := t.nodeOrNil(height, index), u.nodeOrNil(height, index)
+	if nt == nil && nu == nil || bytes.Equal(nt, nu) {
+		return
+	}
+	if height == 0 {
+		if nt != nil {
+			*candT = append(*candT, index)
+		}
+		if nu != nil {
+			*candU = append(*candU, index)
+		}
+		return
+	}
+	arity := t.scheme.width()
+	for child := arity * index; child < arity*(index+1); child++ {
+		t.diff(u, height-1, child, candT, candU)
+	}
+}
+
+// nodeOrNil is like nodeAt, but it returns nil if the merkle tree has no node
+// at the given height and index.
+func (t *Tree) nodeOrNil(height, index int) []byte {
+	if height > len(t.rows) || index >= t.levelWidth(height) {
+		return nil
+	}
+	return t.nodeAt(height, index)
+}
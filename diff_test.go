@@ -0,0 +1,80 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestDiff00(t *testing.T) {
+	a, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyA, onlyB, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onlyA) != 0 || len(onlyB) != 0 {
+		t.Fatalf("want no differences; got %q, %q", onlyA, onlyB)
+	}
+
+	// Replace M in b with a word of the Greek alphabet, and add another.
+	b.DeleteAndReconstruct(M)
+	b.AppendAndReconstruct(grAlphabet[0], grAlphabet[1])
+	if onlyA, onlyB, err = Diff(a, b); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("onlyA: %q", onlyA)
+	t.Logf("onlyB: %q", onlyB)
+	if len(onlyA) != 1 || !bytes.Equal(onlyA[0], M.Serialize()) {
+		t.Fatalf("want (%q); got %q", M.Serialize(), onlyA)
+	}
+	if len(onlyB) != 2 || !bytes.Equal(onlyB[0], grAlphabet[0].Serialize()) || !bytes.Equal(onlyB[1], grAlphabet[1].Serialize()) {
+		t.Fatalf("want (%q, %q); got %q", grAlphabet[0].Serialize(), grAlphabet[1].Serialize(), onlyB)
+	}
+}
+func TestDiff01(t *testing.T) {
+	a, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap[:25], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyA, onlyB, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onlyA) != 1 || !bytes.Equal(onlyA[0], Z.Serialize()) || len(onlyB) != 0 {
+		t.Fatalf("want (%q, []); got %q, %q", Z.Serialize(), onlyA, onlyB)
+	}
+
+	c, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = Diff(a, c); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
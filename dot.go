@@ -0,0 +1,77 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// DOT writes a Graphviz (DOT language) representation of the merkle tree to
+// the given io.Writer, with the merkle nodes labeled by their height, index
+// and truncated digest, and the leaves by their index, ordered ID, truncated
+// digest and (unless in digest-only mode) truncated serialized datum.
+//
+// Render it with e.g. `dot -Tsvg`.
+func (t *Tree) DOT(w io.Writer) error {
+	var b bytes.Buffer
+	b.WriteString("digraph merkle {\n")
+	b.WriteString("\tnode [shape=box, fontname=monospace];\n")
+
+	// The merkle nodes, from the root down to the parents of the leaves...
+	for height := len(t.rows); height > 0; height-- {
+		for i := 0; i < t.levelWidth(height); i++ {
+			fmt.Fprintf(&b, "\t%s [label=\"(%d, %d)\\n%s\"];\n", dotName(height, i), height, i, shortHex(t.nodeAt(height, i)))
+			for child := t.scheme.width() * i; child < t.scheme.width()*(i+1); child++ {
+				if child < t.levelWidth(height-1) {
+					fmt.Fprintf(&b, "\t%s -> %s;\n", dotName(height, i), dotName(height-1, child))
+				}
+			}
+		}
+	}
+	// ...and the leaves, kept on the same rank.
+	b.WriteString("\t{\n\t\trank=same;\n")
+	for i := range t.tls {
+		label := fmt.Sprintf("leaf %d (#%d)\\n%s", i, t.tls[i].orderedID, shortHex(t.tls[i].digest))
+		if !t.digestOnly {
+			quoted := strconv.Quote(shortDatum(t.leafDatum(&t.tls[i])))
+			label += "\\n" + quoted[1:len(quoted)-1]
+		}
+		fmt.Fprintf(&b, "\t\t%s [label=\"%s\", shape=ellipse];\n", dotName(0, i), label)
+	}
+	b.WriteString("\t}\n}\n")
+
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func dotName(height, index int) string {
+	return fmt.Sprintf("n%d_%d", height, index)
+}
+
+// shortHex returns the first few hexadecimal digits of the given digest.
+func shortHex(digest []byte) string {
+	return truncatedHex(digest, 4)
+}
+
+// shortDatum returns the first few bytes of the given serialized datum.
+func shortDatum(datum []byte) string {
+	if len(datum) > 16 {
+		return string(datum[:16]) + "..."
+	}
+	return string(datum)
+}
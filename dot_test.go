@@ -0,0 +1,49 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"strings"
+	"testing"
+)
+
+func TestDOT00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err = tree.DOT(&b); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s", b.String())
+
+	dot := b.String()
+	if !strings.HasPrefix(dot, "digraph merkle {") || !strings.HasSuffix(dot, "}\n") {
+		t.Fatalf("malformed DOT output:\n%s", dot)
+	}
+	// 5 leaves, 3 + 2 + 1 merkle nodes, and 5 + 3 + 2 edges.
+	if n := strings.Count(dot, "[label="); n != 11 {
+		t.Fatalf("want (11) nodes; got %d", n)
+	}
+	if n := strings.Count(dot, " -> "); n != 10 {
+		t.Fatalf("want (10) edges; got %d", n)
+	}
+	if !strings.Contains(dot, shortHex(tree.MerkleRoot())) || !strings.Contains(dot, `\nE"`) {
+		t.Fatalf("missing labels in DOT output:\n%s", dot)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "strconv"
+
+// DuplicatePolicy determines how a merkle tree treats the data that are added
+// to it (e.g. through AppendAndReconstruct) while they are already present;
+// i.e. whose serialized data (or, in digest-only mode, leaf digests) are
+// equal to the ones of one of its leaves, or of another one of the data added
+// along with them.
+//
+// Whatever the DuplicatePolicy, the leaves that a merkle tree already holds
+// are not deduplicated when it changes; e.g. the ones of a merkle tree that
+// was decoded, or merged with another one through Merge.
+type DuplicatePolicy int
+
+const (
+	// AllowDuplicates adds every piece of data as a new leaf, with an
+	// ordered ID of its own. A datum that is present in more than one
+	// leaf is looked up (e.g. by VerifyDatum, IndexOf and ProveDatum) in the
+	// first of them, in the order of the leaves, and deleting it (e.g.
+	// through DeleteAndReconstruct) removes that one leaf alone; i.e. it
+	// must be given as many times as it is present, to be removed from
+	// all of them. It is the default.
+	AllowDuplicates DuplicatePolicy = iota
+	// RejectDuplicates fails the addition of data with an error wrapping
+	// ErrDuplicate, leaving the merkle tree intact, if any of them is
+	// already present.
+	RejectDuplicates
+	// IgnoreDuplicates skips the data that are already present; the
+	// ordered ID that is reported for each of them (e.g. by Append) is
+	// the one of the leaf that it is present in.
+	IgnoreDuplicates
+
+	numDuplicatePolicies
+)
+
+// String returns the name of the DuplicatePolicy.
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case AllowDuplicates:
+		return "AllowDuplicates"
+	case RejectDuplicates:
+		return "RejectDuplicates"
+	case IgnoreDuplicates:
+		return "IgnoreDuplicates"
+	}
+	return "DuplicatePolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// WithDuplicates configures the merkle tree to treat the duplicate data that
+// are added to it as per the given DuplicatePolicy; an unknown one stands for
+// AllowDuplicates. It is not included in the encodings of the merkle tree.
+//
+// Detecting duplicates takes an O(log2(L)) search among the leaves for each
+// piece of data added, or O(L) in insertion order (see InsertionOrder).
+func WithDuplicates(policy DuplicatePolicy) Option {
+	return func(t *Tree) {
+		t.duplicates = AllowDuplicates
+		if policy >= 0 && policy < numDuplicatePolicies {
+			t.duplicates = policy
+		}
+	}
+}
+
+// duplicateSet detects the data that are being added to a merkle tree while
+// they are already present, as per its DuplicatePolicy. A nil *duplicateSet
+// detects none.
+type duplicateSet struct {
+	t *Tree
+	// tls are the leaves of the merkle tree, and added maps the keys (see
+	// leafKey) of the ones being added to their ordered IDs.
+	tls   []treeLeaf
+	added map[string]uint64
+}
+
+// newDuplicateSet returns a new duplicateSet for the data being added to the
+// given leaves of the merkle tree, or nil if it allows for duplicates.
+func (t *Tree) newDuplicateSet(tls []treeLeaf) *duplicateSet {
+	if t.duplicates == AllowDuplicates {
+		return nil
+	}
+	return &duplicateSet{t: t, tls: tls, added: make(map[string]uint64)}
+}
+
+// add records the given leaf as being added, unless its key is already
+// present, in which case it returns the ordered ID of the leaf that holds it
+// and, if the merkle tree rejects duplicates, an error wrapping ErrDuplicate.
+// It reports whether the leaf is to be added.
+func (s *duplicateSet) add(tl *treeLeaf) (uint64, bool, error) {
+	if s == nil {
+		return tl.orderedID, true, nil
+	}
+	key := s.t.key(tl)
+	id, ok := s.added[string(key)]
+	if !ok {
+		var i int
+		if i, ok = s.t.find(s.tls, key); ok {
+			id = s.tls[i].orderedID
+		}
+	}
+	if !ok {
+		s.added[string(key)] = tl.orderedID
+		return tl.orderedID, true, nil
+	}
+	if s.t.duplicates == RejectDuplicates {
+		return 0, false, s.t.duplicateError(key)
+	}
+	return id, false, nil
+}
+
+// duplicateError returns the error for the given key (see leafKey) that is
+// already present in the merkle tree; the operation is to be filled in by
+// dataError.
+func (t *Tree) duplicateError(key []byte) error {
+	if t.digestOnly {
+		return &DataError{Digest: key, Err: ErrDuplicate}
+	}
+	return &DataError{Datum: key, Err: ErrDuplicate}
+}
+
+// dataError returns the error of the given operation for the given error of
+// adding a piece of data to the merkle tree; i.e. the latter itself, if it is
+// a DataError that is yet to be attributed to an operation, or a DataError
+// that wraps it otherwise.
+func dataError(op string, err error) error {
+	if de, ok := err.(*DataError); ok && de.Op == "" {
+		de.Op = op
+		return de
+	}
+	return &DataError{Op: op, Err: err}
+}
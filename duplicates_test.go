@@ -0,0 +1,178 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWithDuplicates00(t *testing.T) {
+	// Duplicates are allowed by default, and deleted one at a time.
+	tree, err := NewTree(crypto.SHA256, alpha, beta, alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids, _, err := tree.Append(beta); err != nil || len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("want ([3], <nil>); got (%v, %v)", ids, err)
+	}
+	if removed, err := tree.Delete(alpha); err != nil || len(removed) != 1 || removed[0] != 0 {
+		t.Fatalf("want ([0], <nil>); got (%v, %v)", removed, err)
+	}
+	if v, err := tree.VerifyDatum(alpha); err != nil || !v {
+		t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+	}
+	if removed, err := tree.Delete(alpha); err != nil || len(removed) != 1 || removed[0] != 2 {
+		t.Fatalf("want ([2], <nil>); got (%v, %v)", removed, err)
+	}
+	if tree.NumLeaves() != 2 {
+		t.Fatalf("want (2) leaves; got %d", tree.NumLeaves())
+	}
+}
+
+func TestWithDuplicates01(t *testing.T) {
+	if _, err := NewTreeWithOptions(crypto.SHA256, []Datum{alpha, beta, alpha}, WithDuplicates(RejectDuplicates)); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("want (%v); got %v", ErrDuplicate, err)
+	}
+	for _, opts := range [][]Option{nil, {InsertionOrder()}, {DigestOnly()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], append(opts, WithDuplicates(RejectDuplicates))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := copyBytes(tree.MerkleRoot())
+		for _, data := range [][]Datum{{grAlphabet[3]}, {grAlphabet[10], grAlphabet[10]}} {
+			_, _, err := tree.Append(data...)
+			var de *DataError
+			if !errors.Is(err, ErrDuplicate) || !errors.As(err, &de) || de.Op != "Append" {
+				t.Fatalf("want (%v); got %v", ErrDuplicate, err)
+			}
+			t.Log(err)
+		}
+		tree.AppendAndReconstruct(grAlphabet[11], grAlphabet[4])
+		if !errors.Is(tree.DatumErr(), ErrDuplicate) {
+			t.Fatalf("want (%v); got %v", ErrDuplicate, tree.DatumErr())
+		}
+		if _, err := tree.Appended(grAlphabet[5]); !errors.Is(err, ErrDuplicate) {
+			t.Fatalf("want (%v); got %v", ErrDuplicate, err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), root) || tree.NumLeaves() != 10 || tree.NextID() != 10 {
+			t.Fatalf("want intact tree; got %d leaves, next ID %d", tree.NumLeaves(), tree.NextID())
+		}
+	}
+}
+
+func TestWithDuplicates02(t *testing.T) {
+	for _, opts := range [][]Option{nil, {InsertionOrder()}, {DigestOnly()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, []Datum{alpha, beta, alpha, gamma}, append(opts, WithDuplicates(IgnoreDuplicates))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.NumLeaves() != 3 || tree.NextID() != 3 {
+			t.Fatalf("want (3) leaves; got %d, next ID %d", tree.NumLeaves(), tree.NextID())
+		}
+		want, err := NewTreeWithOptions(crypto.SHA256, []Datum{alpha, beta, gamma, delta, epsilon}, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ids, root, err := tree.Append(beta, delta, delta, epsilon)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("ids: %v, root: %x", ids, root)
+		if wantIDs := []uint64{1, 3, 3, 4}; !reflect.DeepEqual(ids, wantIDs) {
+			t.Fatalf("want (%v); got %v", wantIDs, ids)
+		}
+		if !bytes.Equal(root, want.MerkleRoot()) {
+			t.Fatalf("want root (%x); got %x", want.MerkleRoot(), root)
+		}
+	}
+}
+
+func TestWithDuplicates03(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:5], WithDuplicates(IgnoreDuplicates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := tree.Begin()
+	if _, err := b.Delete(grAlphabet[1]); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := b.Append(grAlphabet[0], grAlphabet[1], grAlphabet[5], grAlphabet[5])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wantIDs := []uint64{0, 5, 6, 6}; !reflect.DeepEqual(ids, wantIDs) {
+		t.Fatalf("want (%v); got %v", wantIDs, ids)
+	}
+	if ids, err := b.Append(grAlphabet[5]); err != nil || !reflect.DeepEqual(ids, []uint64{6}) {
+		t.Fatalf("want ([6], <nil>); got (%v, %v)", ids, err)
+	}
+	if _, err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if tree.NumLeaves() != 6 || tree.NextID() != 7 {
+		t.Fatalf("want (6) leaves; got %d, next ID %d", tree.NumLeaves(), tree.NextID())
+	}
+
+	tree, err = NewTreeWithOptions(crypto.SHA256, grAlphabet[:5], WithDuplicates(RejectDuplicates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Begin().Append(grAlphabet[5], grAlphabet[2]); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("want (%v); got %v", ErrDuplicate, err)
+	}
+}
+
+func TestWithDuplicates04(t *testing.T) {
+	b, err := NewBuilder(crypto.SHA256, WithDuplicates(RejectDuplicates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(alpha); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.AddBytes(alpha.Serialize()); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("want (%v); got %v", ErrDuplicate, err)
+	}
+	if _, err := b.Build(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add(alpha); err != nil {
+		t.Fatalf("want (<nil>) after Build; got %v", err)
+	}
+
+	var digests [][]byte
+	for _, d := range []Datum{alpha, beta, alpha} {
+		digest, err := HashLeaf(crypto.SHA256, d.Serialize())
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, digest)
+	}
+	tree, err := NewTreeFromDigests(crypto.SHA256, digests, WithDuplicates(IgnoreDuplicates))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.NumLeaves() != 2 {
+		t.Fatalf("want (2) leaves; got %d", tree.NumLeaves())
+	}
+	if _, err := NewTreeFromDigests(crypto.SHA256, digests, WithDuplicates(RejectDuplicates)); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("want (%v); got %v", ErrDuplicate, err)
+	}
+}
@@ -0,0 +1,388 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package envelope
+
+import (
+	"crypto"
+	"encoding/binary"
+	"time"
+
+	"github.com/ckatsak/merkle"
+)
+
+// The major types of CBOR data items that COSE_Sign1 messages consist of.
+const (
+	cborUint   byte = 0x00
+	cborNint   byte = 0x20
+	cborBytes  byte = 0x40
+	cborText   byte = 0x60
+	cborArray  byte = 0x80
+	cborMap    byte = 0xa0
+	cborTag    byte = 0xc0
+	cborSimple byte = 0xe0
+)
+
+// COSE header labels and the tag of COSE_Sign1.
+const (
+	coseHeaderAlg         = 1
+	coseHeaderContentType = 3
+	coseHeaderKid         = 4
+	coseSign1Tag          = 18
+)
+
+// Content types of the COSE_Sign1 messages of proofs and signed roots.
+const (
+	proofContentType = "application/merkle-proof+cbor"
+	rootContentType  = "application/merkle-root+cbor"
+)
+
+// SignCOSE returns the tagged COSE_Sign1 message of the given payload, whose
+// protected header holds the algorithm, the given content type (if not empty)
+// and the key ID (if any) of the Signer.
+func (s *Signer) SignCOSE(payload []byte, contentType string) ([]byte, error) {
+	numLabels := uint64(1)
+	if contentType != "" {
+		numLabels++
+	}
+	if s.keyID != "" {
+		numLabels++
+	}
+	protected := appendCBORHead(nil, cborMap, numLabels)
+	protected = appendCBORHead(protected, cborUint, coseHeaderAlg)
+	protected = appendCBORInt(protected, s.alg.coseAlgorithm())
+	if contentType != "" {
+		protected = appendCBORHead(protected, cborUint, coseHeaderContentType)
+		protected = appendCBORString(protected, cborText, []byte(contentType))
+	}
+	if s.keyID != "" {
+		protected = appendCBORHead(protected, cborUint, coseHeaderKid)
+		protected = appendCBORString(protected, cborBytes, []byte(s.keyID))
+	}
+	sig, err := s.sign(sigStructure(protected, payload))
+	if err != nil {
+		return nil, err
+	}
+	b := appendCBORHead(nil, cborTag, coseSign1Tag)
+	b = appendCBORHead(b, cborArray, 4)
+	b = appendCBORString(b, cborBytes, protected)
+	b = appendCBORHead(b, cborMap, 0)
+	b = appendCBORString(b, cborBytes, payload)
+	return appendCBORString(b, cborBytes, sig), nil
+}
+
+// VerifyCOSE verifies the given COSE_Sign1 message (tagged or not), and
+// returns its payload along with its content type, if any.
+func (v *Verifier) VerifyCOSE(msg []byte) (payload []byte, contentType string, err error) {
+	d := cborDecoder{buf: msg}
+	if len(msg) > 0 && msg[0]&0xe0 == cborTag && d.head(cborTag) != coseSign1Tag {
+		return nil, "", ErrMalformed
+	}
+	if d.head(cborArray) != 4 {
+		return nil, "", ErrMalformed
+	}
+	protected := d.bytes()
+	var (
+		alg int64
+		kid string
+	)
+	if len(protected) > 0 {
+		hdr := cborDecoder{buf: protected}
+		for n := hdr.head(cborMap); n > 0 && !hdr.err; n-- {
+			switch hdr.int() {
+			case coseHeaderAlg:
+				alg = hdr.int()
+			case coseHeaderContentType:
+				contentType = string(hdr.string(cborText))
+			case coseHeaderKid:
+				kid = string(hdr.string(cborBytes))
+			default:
+				hdr.skip()
+			}
+		}
+		if hdr.err || len(hdr.buf) != 0 {
+			return nil, "", ErrMalformed
+		}
+	}
+	for n := d.head(cborMap); n > 0 && !d.err; n-- {
+		if label := d.int(); label == coseHeaderKid && kid == "" {
+			kid = string(d.string(cborBytes))
+		} else if label == coseHeaderAlg || label == coseHeaderContentType {
+			// Security-relevant labels are only honoured if protected.
+			d.err = true
+		} else {
+			d.skip()
+		}
+	}
+	payload = d.bytes()
+	sig := d.bytes()
+	if d.err || len(d.buf) != 0 {
+		return nil, "", ErrMalformed
+	}
+	if alg != v.alg.coseAlgorithm() || !v.checkKeyID(kid) || !v.verify(sigStructure(protected, payload), sig) {
+		return nil, "", ErrInvalidSignature
+	}
+	return payload, contentType, nil
+}
+
+// sigStructure returns the Sig_structure of a COSE_Sign1 message of the given
+// protected header and payload, without external additional data; i.e. the
+// message that is signed.
+func sigStructure(protected, payload []byte) []byte {
+	b := appendCBORHead(nil, cborArray, 4)
+	b = appendCBORString(b, cborText, []byte("Signature1"))
+	b = appendCBORString(b, cborBytes, protected)
+	b = appendCBORString(b, cborBytes, nil)
+	return appendCBORString(b, cborBytes, payload)
+}
+
+// ProofCOSE returns the COSE_Sign1 message of the given inclusion proof, whose
+// payload is its CBOR encoding (see merkle.Proof.MarshalCBOR).
+func ProofCOSE(s *Signer, p *merkle.Proof) ([]byte, error) {
+	payload, err := p.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	return s.SignCOSE(payload, proofContentType)
+}
+
+// OpenProofCOSE verifies the given COSE_Sign1 message of an inclusion proof,
+// and returns the latter.
+func OpenProofCOSE(v *Verifier, msg []byte) (*merkle.Proof, error) {
+	payload, contentType, err := v.VerifyCOSE(msg)
+	if err != nil {
+		return nil, err
+	}
+	p := new(merkle.Proof)
+	if contentType != proofContentType || p.UnmarshalCBOR(payload) != nil {
+		return nil, ErrMalformed
+	}
+	return p, nil
+}
+
+// MarshalCBOR returns the CBOR encoding of the Root; i.e. a map with the same
+// (text) keys as its JSON encoding, where the hash function is identified by
+// its crypto.Hash value, and the merkle root is a byte string.
+func (r *Root) MarshalCBOR() ([]byte, error) {
+	numKeys := uint64(3)
+	if !r.IssuedAt.IsZero() {
+		numKeys++
+	}
+	b := appendCBORHead(nil, cborMap, numKeys)
+	b = appendCBORString(b, cborText, []byte("hash"))
+	b = appendCBORHead(b, cborUint, uint64(r.Hash))
+	b = appendCBORString(b, cborText, []byte("treeSize"))
+	b = appendCBORHead(b, cborUint, uint64(r.TreeSize))
+	b = appendCBORString(b, cborText, []byte("root"))
+	b = appendCBORString(b, cborBytes, r.Root)
+	if numKeys > 3 {
+		b = appendCBORString(b, cborText, []byte("iat"))
+		b = appendCBORInt(b, r.IssuedAt.Unix())
+	}
+	return b, nil
+}
+
+// UnmarshalCBOR decodes the given CBOR encoding (as produced by MarshalCBOR)
+// into the Root.
+func (r *Root) UnmarshalCBOR(data []byte) error {
+	d := cborDecoder{buf: data}
+	var r2 Root
+	for n := d.head(cborMap); n > 0 && !d.err; n-- {
+		switch string(d.string(cborText)) {
+		case "hash":
+			r2.Hash = crypto.Hash(d.head(cborUint))
+		case "treeSize":
+			if r2.TreeSize = int(d.head(cborUint)); r2.TreeSize < 0 {
+				return ErrMalformed
+			}
+		case "root":
+			r2.Root = append([]byte(nil), d.string(cborBytes)...)
+		case "iat":
+			r2.IssuedAt = time.Unix(d.int(), 0)
+		default:
+			return ErrMalformed
+		}
+	}
+	if d.err || len(d.buf) != 0 || r2.Hash == 0 || len(r2.Root) == 0 {
+		return ErrMalformed
+	}
+	*r = r2
+	return nil
+}
+
+// RootCOSE returns the COSE_Sign1 message of the given signed root, whose
+// payload is its CBOR encoding.
+func RootCOSE(s *Signer, r *Root) ([]byte, error) {
+	payload, err := r.MarshalCBOR()
+	if err != nil {
+		return nil, err
+	}
+	return s.SignCOSE(payload, rootContentType)
+}
+
+// OpenRootCOSE verifies the given COSE_Sign1 message of a signed root, and
+// returns the latter.
+func OpenRootCOSE(v *Verifier, msg []byte) (*Root, error) {
+	payload, contentType, err := v.VerifyCOSE(msg)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Root)
+	if contentType != rootContentType || r.UnmarshalCBOR(payload) != nil {
+		return nil, ErrMalformed
+	}
+	return r, nil
+}
+
+// appendCBORHead appends the head of a CBOR data item of the given major type
+// and argument, using the shortest form possible.
+func appendCBORHead(b []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(b, major|byte(arg))
+	case arg <= 0xff:
+		return append(b, major|24, byte(arg))
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(arg))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, major|27), arg)
+	}
+}
+
+func appendCBORInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendCBORHead(b, cborNint, uint64(-1-v))
+	}
+	return appendCBORHead(b, cborUint, uint64(v))
+}
+
+func appendCBORString(b []byte, major byte, v []byte) []byte {
+	return append(appendCBORHead(b, major, uint64(len(v))), v...)
+}
+
+// cborDecoder decodes the subset of CBOR that COSE_Sign1 messages are encoded
+// with. Once it fails, it sets err and keeps returning zero values.
+type cborDecoder struct {
+	buf []byte
+	err bool
+}
+
+// head decodes the head of a data item, which must be of the given major type,
+// and returns its argument.
+func (d *cborDecoder) head(major byte) uint64 {
+	if d.err || len(d.buf) == 0 || d.buf[0]&0xe0 != major {
+		d.err = true
+		return 0
+	}
+	info := d.buf[0] & 0x1f
+	d.buf = d.buf[1:]
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info)
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		d.err = true
+		return 0
+	}
+	if len(d.buf) < size {
+		d.err = true
+		return 0
+	}
+	var arg uint64
+	for _, c := range d.buf[:size] {
+		arg = arg<<8 | uint64(c)
+	}
+	d.buf = d.buf[size:]
+	return arg
+}
+
+// int decodes an integer, either unsigned or negative.
+func (d *cborDecoder) int() int64 {
+	if d.err || len(d.buf) == 0 {
+		d.err = true
+		return 0
+	}
+	major := d.buf[0] & 0xe0
+	if major != cborNint {
+		major = cborUint
+	}
+	arg := d.head(major)
+	if arg > 1<<63-1 {
+		d.err = true
+		return 0
+	}
+	if major == cborNint {
+		return -1 - int64(arg)
+	}
+	return int64(arg)
+}
+
+// string decodes a byte or text string, as per the given major type.
+func (d *cborDecoder) string(major byte) []byte {
+	n := d.head(major)
+	if d.err || n > uint64(len(d.buf)) {
+		d.err = true
+		return nil
+	}
+	ret := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return ret
+}
+
+func (d *cborDecoder) bytes() []byte {
+	return d.string(cborBytes)
+}
+
+// skip skips a data item of any type, up to a nesting depth.
+func (d *cborDecoder) skip() {
+	d.skipDepth(0)
+}
+
+func (d *cborDecoder) skipDepth(depth int) {
+	if d.err || len(d.buf) == 0 || depth > 16 {
+		d.err = true
+		return
+	}
+	switch major := d.buf[0] & 0xe0; major {
+	case cborUint, cborNint, cborSimple:
+		d.head(major)
+	case cborBytes, cborText:
+		d.string(major)
+	case cborArray, cborMap:
+		n := d.head(major)
+		if major == cborMap {
+			n *= 2
+		}
+		if n > uint64(len(d.buf)) {
+			d.err = true
+			return
+		}
+		for ; n > 0 && !d.err; n-- {
+			d.skipDepth(depth + 1)
+		}
+	case cborTag:
+		d.head(major)
+		d.skipDepth(depth + 1)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package envelope
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestCOSE00(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSigner(key, "11")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := s.SignCOSE([]byte("This is the content."), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tag 18, array of 4, protected header {1: -7, 4: h'3131'}, empty
+	// unprotected header, then the payload.
+	prefix := []byte{0xd2, 0x84, 0x47, 0xa2, 0x01, 0x26, 0x04, 0x42, 0x31, 0x31, 0xa0, 0x54}
+	if !bytes.HasPrefix(msg, prefix) {
+		t.Fatalf("want (%x...); got %x", prefix, msg)
+	}
+	v, err := NewVerifier(key.Public(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, contentType, err := v.VerifyCOSE(msg)
+	if err != nil || string(payload) != "This is the content." || contentType != "" {
+		t.Fatalf("want (This is the content., , <nil>); got (%s, %s, %v)", payload, contentType, err)
+	}
+	// Untagged messages are accepted as well.
+	if _, _, err := v.VerifyCOSE(msg[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := append([]byte(nil), msg...)
+	tampered[len(prefix)] ^= 1
+	if _, _, err := v.VerifyCOSE(tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("want (%v); got %v", ErrInvalidSignature, err)
+	}
+	// An algorithm in the unprotected header is rejected.
+	unprotected := append([]byte(nil), msg[:10]...)
+	unprotected = append(unprotected, 0xa1, 0x01, 0x27)
+	unprotected = append(unprotected, msg[11:]...)
+	if _, _, err := v.VerifyCOSE(unprotected); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+	if _, _, err := v.VerifyCOSE(msg[:len(msg)-1]); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+}
+
+func TestCOSE01(t *testing.T) {
+	tree := testTree(t)
+	for alg, key := range testKeys(t) {
+		s, err := NewSigner(key, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := NewVerifier(key.Public(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := tree.Proof(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := ProofCOSE(s, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof2, err := OpenProofCOSE(v, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof2.Verify(tree.MerkleRoot()) {
+			t.Fatalf("want (true) for %v", alg)
+		}
+
+		root := NewRoot(tree)
+		if msg, err = RootCOSE(s, root); err != nil {
+			t.Fatal(err)
+		}
+		root2, err := OpenRootCOSE(v, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root2.Hash != root.Hash || root2.TreeSize != 5 || !bytes.Equal(root2.Root, root.Root) || !root2.IssuedAt.Equal(root.IssuedAt) {
+			t.Fatalf("want (%+v); got %+v", root, root2)
+		}
+		// A signed root is not a proof.
+		if _, err := OpenProofCOSE(v, msg); !errors.Is(err, ErrMalformed) {
+			t.Fatalf("want (%v); got %v", ErrMalformed, err)
+		}
+	}
+}
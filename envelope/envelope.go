@@ -0,0 +1,250 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package envelope wraps inclusion proofs and signed merkle roots in standard
+// signature envelopes, i.e. JWS in the compact serialization (RFC 7515) and
+// COSE_Sign1 (RFC 9052), so that they can be embedded in JWTs and in
+// CBOR-based attestation flows (e.g. CWTs and EAT) without any custom signing
+// glue.
+//
+// Keys are given as a crypto.Signer (for signing) or a crypto.PublicKey (for
+// verifying), and the algorithm follows from them: ES256, ES384 and ES512 for
+// ECDSA keys on the P-256, P-384 and P-521 curves respectively, EdDSA for
+// Ed25519 keys, and RS256 for RSA keys. Verifiers only accept envelopes of
+// their own algorithm (and key ID, if any).
+//
+// Proofs are wrapped in their JSON encoding in JWS (so that they are a valid
+// JWT claims set) and in their CBOR encoding in COSE_Sign1; signed roots are
+// wrapped in the encodings of Root.
+package envelope
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // for ES256 and RS256
+	_ "crypto/sha512" // for ES384 and ES512
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/ckatsak/merkle"
+)
+
+var (
+	// ErrUnsupportedKey signifies that the given key is not of a type (or
+	// curve) that the package supports.
+	ErrUnsupportedKey = errors.New("envelope: unsupported key")
+
+	// ErrMalformed signifies that the given envelope (or its payload) is not
+	// in the expected format.
+	ErrMalformed = errors.New("envelope: malformed envelope")
+
+	// ErrInvalidSignature signifies that the signature of the given envelope
+	// failed to verify, or that the envelope is of a different algorithm or
+	// key ID than the ones of the Verifier.
+	ErrInvalidSignature = errors.New("envelope: invalid signature")
+)
+
+// Algorithm is a signature algorithm, named as in the JOSE registry.
+type Algorithm string
+
+// The supported signature algorithms.
+const (
+	ES256 Algorithm = "ES256"
+	ES384 Algorithm = "ES384"
+	ES512 Algorithm = "ES512"
+	EdDSA Algorithm = "EdDSA"
+	RS256 Algorithm = "RS256"
+)
+
+// coseAlgorithm returns the identifier of the Algorithm in the COSE registry.
+func (alg Algorithm) coseAlgorithm() int64 {
+	switch alg {
+	case ES256:
+		return -7
+	case ES384:
+		return -35
+	case ES512:
+		return -36
+	case EdDSA:
+		return -8
+	case RS256:
+		return -257
+	}
+	return 0
+}
+
+// hash returns the hash function that the Algorithm signs the digest of, or 0
+// if it signs the message itself.
+func (alg Algorithm) hash() crypto.Hash {
+	switch alg {
+	case ES256, RS256:
+		return crypto.SHA256
+	case ES384:
+		return crypto.SHA384
+	case ES512:
+		return crypto.SHA512
+	}
+	return 0
+}
+
+// algorithmOf returns the Algorithm of the given public key.
+func algorithmOf(key crypto.PublicKey) (Algorithm, error) {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return ES256, nil
+		case elliptic.P384():
+			return ES384, nil
+		case elliptic.P521():
+			return ES512, nil
+		}
+	case ed25519.PublicKey:
+		if len(key) == ed25519.PublicKeySize {
+			return EdDSA, nil
+		}
+	case *rsa.PublicKey:
+		return RS256, nil
+	}
+	return "", ErrUnsupportedKey
+}
+
+// Signer signs envelopes through a private key.
+type Signer struct {
+	alg   Algorithm
+	key   crypto.Signer
+	keyID string
+}
+
+// NewSigner returns a Signer of the given private key, which identifies it
+// in its envelopes by the given key ID, if not empty.
+func NewSigner(key crypto.Signer, keyID string) (*Signer, error) {
+	alg, err := algorithmOf(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{alg: alg, key: key, keyID: keyID}, nil
+}
+
+// Algorithm returns the Algorithm of the Signer.
+func (s *Signer) Algorithm() Algorithm {
+	return s.alg
+}
+
+// sign returns the signature of the given message, in the form that both JWS
+// and COSE specify; i.e. the concatenation of the fixed-size r and s values
+// for ECDSA.
+func (s *Signer) sign(msg []byte) ([]byte, error) {
+	if s.alg == EdDSA {
+		return s.key.Sign(rand.Reader, msg, crypto.Hash(0))
+	}
+	h := s.alg.hash().New()
+	h.Write(msg)
+	sig, err := s.key.Sign(rand.Reader, h.Sum(nil), s.alg.hash())
+	if err != nil || s.alg == RS256 {
+		return sig, err
+	}
+	var es struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &es); err != nil {
+		return nil, err
+	}
+	size := (s.key.Public().(*ecdsa.PublicKey).Curve.Params().BitSize + 7) / 8
+	ret := make([]byte, 2*size)
+	es.R.FillBytes(ret[:size])
+	es.S.FillBytes(ret[size:])
+	return ret, nil
+}
+
+// Verifier verifies envelopes through a public key.
+type Verifier struct {
+	alg   Algorithm
+	key   crypto.PublicKey
+	keyID string
+}
+
+// NewVerifier returns a Verifier of the given public key, which only accepts
+// envelopes of the given key ID, if not empty.
+func NewVerifier(key crypto.PublicKey, keyID string) (*Verifier, error) {
+	alg, err := algorithmOf(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Verifier{alg: alg, key: key, keyID: keyID}, nil
+}
+
+// verify reports whether sig is a valid signature of msg.
+func (v *Verifier) verify(msg, sig []byte) bool {
+	if v.alg == EdDSA {
+		return ed25519.Verify(v.key.(ed25519.PublicKey), msg, sig)
+	}
+	h := v.alg.hash().New()
+	h.Write(msg)
+	digest := h.Sum(nil)
+	if v.alg == RS256 {
+		return rsa.VerifyPKCS1v15(v.key.(*rsa.PublicKey), crypto.SHA256, digest, sig) == nil
+	}
+	key := v.key.(*ecdsa.PublicKey)
+	size := (key.Curve.Params().BitSize + 7) / 8
+	if len(sig) != 2*size {
+		return false
+	}
+	r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+	return ecdsa.Verify(key, digest, r, s)
+}
+
+// checkKeyID reports whether an envelope of the given key ID is acceptable to
+// the Verifier.
+func (v *Verifier) checkKeyID(keyID string) bool {
+	return v.keyID == "" || v.keyID == keyID
+}
+
+// Root is a signed merkle root; i.e. the statement that the merkle tree of
+// the given hash function and size had the given merkle root at the given
+// time.
+type Root struct {
+	Hash     crypto.Hash
+	TreeSize int
+	Root     []byte
+	// IssuedAt is the time of the statement, in seconds; it is omitted if
+	// zero.
+	IssuedAt time.Time
+}
+
+// NewRoot returns the Root of the current state of the given merkle tree,
+// issued now.
+func NewRoot(t *merkle.Tree) *Root {
+	return &Root{
+		Hash:     t.Hash(),
+		TreeSize: t.NumLeaves(),
+		Root:     append([]byte(nil), t.MerkleRoot()...),
+		IssuedAt: time.Now().Truncate(time.Second),
+	}
+}
+
+// hashByName returns the hash function that goes by the given name (as
+// returned by crypto.Hash.String).
+func hashByName(name string) (crypto.Hash, error) {
+	for hash := crypto.MD4; hash <= crypto.BLAKE2b_512; hash++ {
+		if hash.String() == name {
+			return hash, nil
+		}
+	}
+	return 0, ErrMalformed
+}
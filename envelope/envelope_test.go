@@ -0,0 +1,100 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package envelope
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+type Word string
+
+func (w Word) Serialize() []byte {
+	return []byte(w)
+}
+
+func testTree(t *testing.T) *merkle.Tree {
+	tree, err := merkle.NewTree(crypto.SHA256, Word("alpha"), Word("beta"), Word("gamma"), Word("delta"), Word("epsilon"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+// testKeys returns a private key of each supported type, along with the
+// expected Algorithm.
+func testKeys(t *testing.T) map[Algorithm]crypto.Signer {
+	keys := make(map[Algorithm]crypto.Signer)
+	for alg, curve := range map[Algorithm]elliptic.Curve{ES256: elliptic.P256(), ES384: elliptic.P384(), ES512: elliptic.P521()} {
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[alg] = key
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys[EdDSA] = edKey
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys[RS256] = rsaKey
+	return keys
+}
+
+func TestSigner00(t *testing.T) {
+	for alg, key := range testKeys(t) {
+		s, err := NewSigner(key, "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Algorithm() != alg {
+			t.Fatalf("want (%v); got %v", alg, s.Algorithm())
+		}
+		v, err := NewVerifier(key.Public(), "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig, err := s.sign([]byte("message"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.verify([]byte("message"), sig) || v.verify([]byte("massage"), sig) {
+			t.Fatalf("want (true, false) for %v", alg)
+		}
+	}
+
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner(p224, ""); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("want (%v); got %v", ErrUnsupportedKey, err)
+	}
+	if _, err := NewVerifier(ed25519.PublicKey{1, 2, 3}, ""); !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("want (%v); got %v", ErrUnsupportedKey, err)
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package envelope
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ckatsak/merkle"
+)
+
+// jwsHeader is the JOSE header of the JWS that the package produces.
+type jwsHeader struct {
+	Alg Algorithm `json:"alg"`
+	Typ string    `json:"typ,omitempty"`
+	Kid string    `json:"kid,omitempty"`
+}
+
+// jwtType is the type of JWS whose payload is a JWT claims set.
+const jwtType = "JWT"
+
+// SignJWS returns the JWS compact serialization of the given payload, with a
+// JOSE header of the given type (e.g. "JWT"), if not empty.
+func (s *Signer) SignJWS(payload []byte, typ string) (string, error) {
+	header, err := json.Marshal(&jwsHeader{Alg: s.alg, Typ: typ, Kid: s.keyID})
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sig, err := s.sign([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyJWS verifies the given JWS compact serialization, and returns its
+// payload.
+func (v *Verifier) VerifyJWS(jws string) ([]byte, error) {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+	rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var header struct {
+		jwsHeader
+		Crit []string `json:"crit"`
+	}
+	if err := json.Unmarshal(rawHeader, &header); err != nil || header.Crit != nil {
+		return nil, ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	if header.Alg != v.alg || !v.checkKeyID(header.Kid) || !v.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrInvalidSignature
+	}
+	return payload, nil
+}
+
+// ProofJWS returns the JWS of the given inclusion proof, whose payload is its
+// JSON encoding (see merkle.Proof.MarshalJSON), typed as a JWT.
+func ProofJWS(s *Signer, p *merkle.Proof) (string, error) {
+	payload, err := p.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return s.SignJWS(payload, jwtType)
+}
+
+// OpenProofJWS verifies the given JWS of an inclusion proof, and returns the
+// latter.
+func OpenProofJWS(v *Verifier, jws string) (*merkle.Proof, error) {
+	payload, err := v.VerifyJWS(jws)
+	if err != nil {
+		return nil, err
+	}
+	p := new(merkle.Proof)
+	if err := p.UnmarshalJSON(payload); err != nil {
+		return nil, ErrMalformed
+	}
+	return p, nil
+}
+
+// jsonRoot is the JSON representation of a Root, i.e. a JWT claims set.
+type jsonRoot struct {
+	Hash     string `json:"hash"`
+	TreeSize int    `json:"treeSize"`
+	Root     string `json:"root"`
+	IssuedAt int64  `json:"iat,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// The Root is encoded as a JWT claims set; the hash function is identified by
+// its name (e.g. "SHA-256"), the merkle root is encoded as a hexadecimal
+// string, and the time as the "iat" claim.
+func (r *Root) MarshalJSON() ([]byte, error) {
+	jr := jsonRoot{Hash: r.Hash.String(), TreeSize: r.TreeSize, Root: hex.EncodeToString(r.Root)}
+	if !r.IssuedAt.IsZero() {
+		jr.IssuedAt = r.IssuedAt.Unix()
+	}
+	return json.Marshal(&jr)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (r *Root) UnmarshalJSON(data []byte) error {
+	var jr jsonRoot
+	if err := json.Unmarshal(data, &jr); err != nil {
+		return ErrMalformed
+	}
+	hash, err := hashByName(jr.Hash)
+	if err != nil {
+		return err
+	}
+	root, err := hex.DecodeString(jr.Root)
+	if err != nil || len(root) == 0 || jr.TreeSize < 0 {
+		return ErrMalformed
+	}
+	*r = Root{Hash: hash, TreeSize: jr.TreeSize, Root: root}
+	if jr.IssuedAt != 0 {
+		r.IssuedAt = time.Unix(jr.IssuedAt, 0)
+	}
+	return nil
+}
+
+// RootJWS returns the JWS of the given signed root, whose payload is its JSON
+// encoding, typed as a JWT.
+func RootJWS(s *Signer, r *Root) (string, error) {
+	payload, err := r.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	return s.SignJWS(payload, jwtType)
+}
+
+// OpenRootJWS verifies the given JWS of a signed root, and returns the latter.
+func OpenRootJWS(v *Verifier, jws string) (*Root, error) {
+	payload, err := v.VerifyJWS(jws)
+	if err != nil {
+		return nil, err
+	}
+	r := new(Root)
+	if err := r.UnmarshalJSON(payload); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package envelope
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJWS00(t *testing.T) {
+	// The Ed25519 example of RFC 8037 (appendix A.4).
+	seed, _ := base64.RawURLEncoding.DecodeString("nWGxne_9WmC6hEr0kuwsxERJxWl7MmkZcDusAxyuf2A")
+	const want = "eyJhbGciOiJFZERTQSJ9.RXhhbXBsZSBvZiBFZDI1NTE5IHNpZ25pbmc." +
+		"hgyY0il_MGCjP0JzlnLWG1PPOt7-09PGcvMg3AIbQR6dWbhijcNR4ki4iylGjg5BhVsPt9g7sVvpAr_MuM0KAg"
+	key := ed25519.NewKeyFromSeed(seed)
+	s, err := NewSigner(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := s.SignJWS([]byte("Example of Ed25519 signing"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jws != want {
+		t.Fatalf("want (%s); got %s", want, jws)
+	}
+	v, err := NewVerifier(key.Public(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := v.VerifyJWS(jws)
+	if err != nil || string(payload) != "Example of Ed25519 signing" {
+		t.Fatalf("want (Example of Ed25519 signing, <nil>); got (%s, %v)", payload, err)
+	}
+	for _, bad := range []string{jws[:len(jws)-2] + "AA", "e" + jws, jws + ".", strings.Replace(jws, ".RX", ".Rx", 1)} {
+		if _, err := v.VerifyJWS(bad); err == nil {
+			t.Fatalf("want (an error); got <nil> for %s", bad)
+		}
+	}
+}
+
+func TestJWS01(t *testing.T) {
+	tree := testTree(t)
+	for alg, key := range testKeys(t) {
+		s, err := NewSigner(key, "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := NewVerifier(key.Public(), "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := tree.Proof(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := ProofJWS(s, proof)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof2, err := OpenProofJWS(v, jws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proof2.Verify(tree.MerkleRoot()) {
+			t.Fatalf("want (true) for %v", alg)
+		}
+
+		root := NewRoot(tree)
+		if jws, err = RootJWS(s, root); err != nil {
+			t.Fatal(err)
+		}
+		root2, err := OpenRootJWS(v, jws)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root2.Hash != root.Hash || root2.TreeSize != 5 || !bytes.Equal(root2.Root, root.Root) || !root2.IssuedAt.Equal(root.IssuedAt) {
+			t.Fatalf("want (%+v); got %+v", root, root2)
+		}
+		if alg == ES256 {
+			t.Log(jws)
+		}
+
+		// Envelopes of other keys are rejected.
+		other, err := NewVerifier(key.Public(), "key-2")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := OpenRootJWS(other, jws); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("want (%v); got %v", ErrInvalidSignature, err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// The sentinel errors of the package. The errors returned by the package
+// either are one of them or wrap one of them, along with the context in
+// which it occurred (see HashError, DataError, IndexError and
+// IDError); hence, they should be tested for with errors.Is.
+//
+// Note that a piece of data that is present in the merkle tree but fails to
+// be verified (e.g. due to corruption) is not an error; the verification
+// methods of Tree report it by returning false and a nil error.
+var (
+	// ErrHashUnavailable signifies that the requested hash function has
+	// not been linked into the binary.
+	ErrHashUnavailable = errors.New("Hash Algorithm Unavailable")
+
+	// ErrCompressorUnavailable signifies that the CompressionAlgorithm
+	// that the data of an encoded merkle tree are compressed with has not
+	// been registered (see RegisterCompressor).
+	ErrCompressorUnavailable = errors.New("Compression Algorithm Unavailable")
+
+	// ErrNoData signifies that the piece of data requested is either nil
+	// or not present in the merkle tree.
+	ErrNoData = errors.New("Nonexistent Data")
+
+	// ErrInvalidDigest signifies that the given digest is not of the size
+	// that the hash function of the merkle tree produces.
+	ErrInvalidDigest = errors.New("Invalid Digest")
+
+	// ErrInvalidPieceLength signifies that the requested piece length is
+	// not a power-of-two multiple of the chunk size of a FileTree.
+	ErrInvalidPieceLength = errors.New("Invalid Piece Length")
+
+	// ErrKeyExists signifies that the given key is already present; e.g.
+	// the key whose absence was requested to be proven in a Map, or the
+	// name of a merkle tree that was requested to be created in a Forest.
+	ErrKeyExists = errors.New("Key Exists")
+
+	// ErrInvalidRange signifies that the requested range of leaves is
+	// either empty or out of bounds.
+	ErrInvalidRange = errors.New("Invalid Range")
+
+	// ErrUnsupported signifies that the requested operation is not
+	// supported by the hashing scheme or the leaf ordering of the merkle
+	// tree.
+	ErrUnsupported = errors.New("Unsupported Operation")
+
+	// ErrInvalidEncoding signifies that the given encoding of a merkle
+	// tree is malformed.
+	ErrInvalidEncoding = errors.New("Invalid Encoding")
+
+	// ErrStale signifies that the merkle tree has been modified since the
+	// Batch of mutations to be committed to it was begun.
+	ErrStale = errors.New("Stale Batch")
+
+	// ErrDuplicate signifies that the given piece of data is already
+	// present in a merkle tree that rejects duplicates (see
+	// RejectDuplicates).
+	ErrDuplicate = errors.New("Duplicate Data")
+
+	// ErrTooManyLeaves signifies that the merkle tree cannot hold the
+	// given data, as it would exceed MaxLeaves or run out of ordered IDs
+	// (see MaxOrderedID).
+	ErrTooManyLeaves = errors.New("Too Many Leaves")
+
+	// ErrInvalidProof signifies that the given proof does not lead to the
+	// given merkle root.
+	ErrInvalidProof = errors.New("Invalid Proof")
+)
+
+// HashError records the hash function that was requested but has not been
+// linked into the binary. It wraps ErrHashUnavailable.
+type HashError struct {
+	Hash crypto.Hash
+}
+
+func (e *HashError) Error() string {
+	return "merkle: " + ErrHashUnavailable.Error() + ": " + e.Hash.String()
+}
+
+// Unwrap returns ErrHashUnavailable.
+func (e *HashError) Unwrap() error {
+	return ErrHashUnavailable
+}
+
+// DataError records the operation that failed and the piece of data (either
+// a serialized datum or a digest) that it failed on. It typically wraps
+// ErrNoData.
+type DataError struct {
+	// Op is the name of the operation that failed, e.g. "VerifyDatum".
+	Op string
+	// Datum is the serialized datum that the operation failed on, if any.
+	Datum []byte
+	// Digest is the digest that the operation failed on, if any.
+	Digest []byte
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *DataError) Error() string {
+	switch {
+	case e.Datum != nil:
+		return fmt.Sprintf("merkle: %s: datum %q: %v", e.Op, shortDatum(e.Datum), e.Err)
+	case e.Digest != nil:
+		return fmt.Sprintf("merkle: %s: digest %x: %v", e.Op, e.Digest, e.Err)
+	}
+	return fmt.Sprintf("merkle: %s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DataError) Unwrap() error {
+	return e.Err
+}
+
+// IndexError records the operation that failed and the index (of a leaf or a
+// node) that it failed on. It typically wraps ErrNoData.
+type IndexError struct {
+	// Op is the name of the operation that failed, e.g. "Proof".
+	Op string
+	// Index is the index that the operation failed on.
+	Index int
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *IndexError) Error() string {
+	return "merkle: " + e.Op + ": index " + strconv.Itoa(e.Index) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *IndexError) Unwrap() error {
+	return e.Err
+}
+
+// IDError records the operation that failed and the ordered ID of the leaf
+// that it failed on. It typically wraps ErrNoData.
+type IDError struct {
+	// Op is the name of the operation that failed, e.g. "LeafByID".
+	Op string
+	// ID is the ordered ID that the operation failed on.
+	ID uint64
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *IDError) Error() string {
+	return "merkle: " + e.Op + ": ordered ID " + strconv.FormatUint(e.ID, 10) + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *IDError) Unwrap() error {
+	return e.Err
+}
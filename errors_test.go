@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestErrors00(t *testing.T) {
+	_, err := NewTree(crypto.Hash(0), grAlphabet...)
+	t.Logf("got (%v), as expected", err)
+	var he *HashError
+	if !errors.Is(err, ErrHashUnavailable) || !errors.As(err, &he) || he.Hash != crypto.Hash(0) {
+		t.Fatalf("want (%v); got %v", &HashError{}, err)
+	}
+
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = tree.VerifyDatum(kk)
+	t.Logf("got (%v), as expected", err)
+	var de *DataError
+	if !errors.Is(err, ErrNoData) || !errors.As(err, &de) || string(de.Datum) != string(kk.Serialize()) {
+		t.Fatalf("want (%v); got %v", &DataError{Datum: kk.Serialize(), Err: ErrNoData}, err)
+	}
+	if !strings.Contains(err.Error(), string(kk)) {
+		t.Fatalf("want the datum in %q", err)
+	}
+
+	_, err = tree.Proof(len(grAlphabet))
+	t.Logf("got (%v), as expected", err)
+	var ie *IndexError
+	if !errors.Is(err, ErrNoData) || !errors.As(err, &ie) || ie.Index != len(grAlphabet) || ie.Op != "Proof" {
+		t.Fatalf("want (%v); got %v", &IndexError{Op: "Proof", Index: len(grAlphabet), Err: ErrNoData}, err)
+	}
+
+	_, err = tree.LeafByID(MaxOrderedID)
+	t.Logf("got (%v), as expected", err)
+	var ide *IDError
+	if !errors.Is(err, ErrNoData) || !errors.As(err, &ide) || ide.ID != MaxOrderedID || ide.Op != "LeafByID" {
+		t.Fatalf("want (%v); got %v", &IDError{Op: "LeafByID", ID: MaxOrderedID, Err: ErrNoData}, err)
+	}
+	if !strings.Contains(err.Error(), strconv.FormatUint(MaxOrderedID, 10)) {
+		t.Fatalf("want the ordered ID in %q", err)
+	}
+}
@@ -0,0 +1,263 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package evm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrDuplicateAccount signifies that an account is given more than one
+	// Balance.
+	ErrDuplicateAccount = errors.New("evm: duplicate account")
+	// ErrInvalidAmount signifies that an amount is not positive, or that it
+	// does not fit in a uint256.
+	ErrInvalidAmount = errors.New("evm: invalid amount")
+	// ErrInvalidAddress signifies that the given text is not a valid
+	// hexadecimal encoding of an Address.
+	ErrInvalidAddress = errors.New("evm: invalid address")
+)
+
+// Address is the address of an account of the EVM.
+type Address [20]byte
+
+// String returns the hexadecimal encoding of the Address, prefixed with "0x"
+// and checksummed as per EIP-55 (i.e. in mixed case).
+func (a Address) String() string {
+	b := []byte(hex.EncodeToString(a[:]))
+	h := Keccak256(b)
+	for i := range b {
+		if b[i] >= 'a' && h[i/2]>>(4*uint(1-i%2))&0xf >= 8 {
+			b[i] -= 'a' - 'A'
+		}
+	}
+	return "0x" + string(b)
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (a Address) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface; the "0x"
+// prefix is optional, and the checksum of EIP-55 is not enforced.
+func (a *Address) UnmarshalText(text []byte) error {
+	s := strings.TrimPrefix(strings.TrimPrefix(string(text), "0x"), "0X")
+	if hex.DecodedLen(len(s)) != len(a) {
+		return ErrInvalidAddress
+	}
+	if _, err := hex.Decode(a[:], []byte(s)); err != nil {
+		return ErrInvalidAddress
+	}
+	return nil
+}
+
+// Balance is the amount of tokens that an account is entitled to claim from
+// a merkle distributor.
+type Balance struct {
+	Account Address
+	Amount  *big.Int
+}
+
+// Claim is the entry of a Distribution for a single account; i.e. what the
+// account submits to the claim function of the merkle distributor.
+type Claim struct {
+	Index  uint64
+	Amount *big.Int
+	Proof  []Hash
+}
+
+// Distribution is a merkle distribution of tokens, in the layout of the
+// claims JSON of Uniswap's merkle-distributor, which maps each account to
+// its Claim. Its Root is what the merkle distributor is deployed with.
+type Distribution struct {
+	Root       Hash
+	TokenTotal *big.Int
+	Claims     map[Address]Claim
+}
+
+// maxUint256 is the greatest value that a uint256 holds.
+var maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+
+// BalanceLeaf returns the leaf of the merkle tree of a Distribution for the
+// given Claim of the given account; i.e. the Keccak-256 digest of
+// abi.encodePacked(uint256 index, address account, uint256 amount).
+func BalanceLeaf(index uint64, account Address, amount *big.Int) Hash {
+	var idx, amt [32]byte
+	new(big.Int).SetUint64(index).FillBytes(idx[:])
+	amount.FillBytes(amt[:])
+	return Keccak256(idx[:], account[:], amt[:])
+}
+
+// NewDistribution builds the Distribution of the given balances the way
+// Uniswap's merkle-distributor scripts do: the accounts are indexed in the
+// ascending order of their checksummed addresses, and the leaves of the
+// merkle tree (see BalanceLeaf) are sorted before it is built, so that the
+// resulting merkle root is the same.
+//
+// It returns a non-nil error if no balances are given, if an account is
+// given more than once, or if an amount is not positive or does not fit in a
+// uint256.
+func NewDistribution(balances []Balance) (*Distribution, error) {
+	accounts := make([]string, len(balances))
+	byAccount := make(map[string]*Balance, len(balances))
+	for i := range balances {
+		if balances[i].Amount == nil || balances[i].Amount.Sign() <= 0 || balances[i].Amount.Cmp(maxUint256) > 0 {
+			return nil, ErrInvalidAmount
+		}
+		accounts[i] = balances[i].Account.String()
+		if _, ok := byAccount[accounts[i]]; ok {
+			return nil, ErrDuplicateAccount
+		}
+		byAccount[accounts[i]] = &balances[i]
+	}
+	sort.Strings(accounts)
+
+	d := &Distribution{
+		TokenTotal: new(big.Int),
+		Claims:     make(map[Address]Claim, len(balances)),
+	}
+	leaves := make([]Hash, len(accounts))
+	for i, account := range accounts {
+		b := byAccount[account]
+		leaves[i] = BalanceLeaf(uint64(i), b.Account, b.Amount)
+		d.TokenTotal.Add(d.TokenTotal, b.Amount)
+	}
+	sorted := make([]Hash, len(leaves))
+	copy(sorted, leaves)
+	sort.Slice(sorted, func(i, j int) bool {
+		return string(sorted[i][:]) < string(sorted[j][:])
+	})
+	tree, err := NewTree(sorted...)
+	if err != nil {
+		return nil, err
+	}
+	d.Root = Root(tree)
+
+	positions := make(map[Hash]int, len(sorted))
+	for i := range sorted {
+		positions[sorted[i]] = i
+	}
+	for i, account := range accounts {
+		proof, err := Prove(tree, positions[leaves[i]])
+		if err != nil {
+			return nil, err
+		}
+		b := byAccount[account]
+		d.Claims[b.Account] = Claim{
+			Index:  uint64(i),
+			Amount: new(big.Int).Set(b.Amount),
+			Proof:  proof,
+		}
+	}
+	return d, nil
+}
+
+// VerifyClaim reports whether the given Claim of the given account is part
+// of the Distribution of the given merkle root, the way the claim function
+// of the merkle distributor checks it.
+func VerifyClaim(root Hash, account Address, c Claim) bool {
+	if c.Amount == nil || c.Amount.Sign() < 0 || c.Amount.Cmp(maxUint256) > 0 {
+		return false
+	}
+	return Verify(c.Proof, root, BalanceLeaf(c.Index, account, c.Amount))
+}
+
+// jsonClaim is the JSON representation of a Claim.
+type jsonClaim struct {
+	Index  uint64 `json:"index"`
+	Amount string `json:"amount"`
+	Proof  []Hash `json:"proof"`
+}
+
+// jsonDistribution is the JSON representation of a Distribution.
+type jsonDistribution struct {
+	MerkleRoot Hash                  `json:"merkleRoot"`
+	TokenTotal string                `json:"tokenTotal"`
+	Claims     map[Address]jsonClaim `json:"claims"`
+}
+
+// MarshalJSON implements the json.Marshaler interface, in the layout of the
+// claims JSON of Uniswap's merkle-distributor; i.e. amounts are encoded as
+// hexadecimal strings (prefixed with "0x" and of an even length), and the
+// accounts are checksummed.
+func (d *Distribution) MarshalJSON() ([]byte, error) {
+	jd := jsonDistribution{
+		MerkleRoot: d.Root,
+		TokenTotal: hexAmount(d.TokenTotal),
+		Claims:     make(map[Address]jsonClaim, len(d.Claims)),
+	}
+	for account, c := range d.Claims {
+		jd.Claims[account] = jsonClaim{
+			Index:  c.Index,
+			Amount: hexAmount(c.Amount),
+			Proof:  c.Proof,
+		}
+	}
+	return json.Marshal(&jd)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Distribution) UnmarshalJSON(data []byte) error {
+	var jd jsonDistribution
+	if err := json.Unmarshal(data, &jd); err != nil {
+		return err
+	}
+	total, err := parseHexAmount(jd.TokenTotal)
+	if err != nil {
+		return err
+	}
+	d2 := Distribution{
+		Root:       jd.MerkleRoot,
+		TokenTotal: total,
+		Claims:     make(map[Address]Claim, len(jd.Claims)),
+	}
+	for account, jc := range jd.Claims {
+		amount, err := parseHexAmount(jc.Amount)
+		if err != nil {
+			return err
+		}
+		d2.Claims[account] = Claim{Index: jc.Index, Amount: amount, Proof: jc.Proof}
+	}
+	*d = d2
+	return nil
+}
+
+// hexAmount returns the hexadecimal encoding of the given amount, prefixed
+// with "0x" and padded to an even length, the way ethers.js encodes them.
+func hexAmount(amount *big.Int) string {
+	s := amount.Text(16)
+	if len(s)%2 != 0 {
+		s = "0" + s
+	}
+	return "0x" + s
+}
+
+// parseHexAmount parses an amount encoded by hexAmount.
+func parseHexAmount(s string) (*big.Int, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return nil, ErrInvalidAmount
+	}
+	amount, ok := new(big.Int).SetString(s[2:], 16)
+	if !ok || amount.Sign() < 0 || amount.Cmp(maxUint256) > 0 {
+		return nil, ErrInvalidAmount
+	}
+	return amount, nil
+}
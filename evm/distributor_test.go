@@ -0,0 +1,111 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package evm
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+)
+
+func TestAddress00(t *testing.T) {
+	for _, want := range []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	} {
+		var a Address
+		if err := a.UnmarshalText([]byte(want)); err != nil {
+			t.Fatal(err)
+		}
+		if got := a.String(); got != want {
+			t.Fatalf("want (%s); got %s", want, got)
+		}
+	}
+	var a Address
+	if err := a.UnmarshalText([]byte("0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeA")); err != ErrInvalidAddress {
+		t.Fatalf("want (%v); got %v", ErrInvalidAddress, err)
+	}
+}
+
+func TestNewDistribution00(t *testing.T) {
+	balances := make([]Balance, 7)
+	for i := range balances {
+		balances[i].Account[0], balances[i].Account[19] = byte(0x30*i), byte(i)
+		balances[i].Amount = big.NewInt(int64(100 * (i + 1)))
+	}
+	d, err := NewDistribution(balances)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("d.Root: %v", d.Root)
+	if d.TokenTotal.Int64() != 2800 {
+		t.Fatalf("want token total (2800); got %v", d.TokenTotal)
+	}
+	seen := make(map[uint64]bool)
+	for _, b := range balances {
+		c, ok := d.Claims[b.Account]
+		if !ok {
+			t.Fatalf("no claim for account %v", b.Account)
+		}
+		seen[c.Index] = true
+		if c.Amount.Cmp(b.Amount) != 0 || !VerifyClaim(d.Root, b.Account, c) {
+			t.Fatalf("claim of account %v does not verify", b.Account)
+		}
+		c.Amount = new(big.Int).Add(c.Amount, big.NewInt(1))
+		if VerifyClaim(d.Root, b.Account, c) {
+			t.Fatalf("inflated claim of account %v verifies", b.Account)
+		}
+	}
+	if len(seen) != len(balances) {
+		t.Fatalf("want (%d) distinct indices; got %d", len(balances), len(seen))
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", b)
+	var d2 Distribution
+	if err := json.Unmarshal(b, &d2); err != nil {
+		t.Fatal(err)
+	}
+	if d2.Root != d.Root || d2.TokenTotal.Cmp(d.TokenTotal) != 0 || len(d2.Claims) != len(d.Claims) {
+		t.Fatalf("want (%v); got %v", d, &d2)
+	}
+	for account, c := range d2.Claims {
+		if !VerifyClaim(d2.Root, account, c) {
+			t.Fatalf("decoded claim of account %v does not verify", account)
+		}
+	}
+}
+
+func TestNewDistribution01(t *testing.T) {
+	balances := []Balance{
+		{Account: Address{1}, Amount: big.NewInt(1)},
+		{Account: Address{1}, Amount: big.NewInt(2)},
+	}
+	if _, err := NewDistribution(balances); err != ErrDuplicateAccount {
+		t.Fatalf("want (%v); got %v", ErrDuplicateAccount, err)
+	}
+	balances[1] = Balance{Account: Address{2}, Amount: big.NewInt(0)}
+	if _, err := NewDistribution(balances); err != ErrInvalidAmount {
+		t.Fatalf("want (%v); got %v", ErrInvalidAmount, err)
+	}
+	if got := hexAmount(big.NewInt(10)); got != "0x0a" {
+		t.Fatalf("want (0x0a); got %s", got)
+	}
+}
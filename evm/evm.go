@@ -0,0 +1,200 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package evm makes merkle trees interoperable with the merkle proofs that
+// smart contracts on the Ethereum Virtual Machine verify, such as the ones of
+// OpenZeppelin's MerkleProof library (and hence the airdrop allowlists and
+// merkle distributors that build on it).
+//
+// Such trees hash with Keccak-256 (the original submission to the SHA-3
+// competition, rather than the standardized SHA3-256), in sorted pairs (see
+// merkle.SortedPairs), promoting lone nodes to the next level as they are
+// (see merkle.PromoteLone); their inclusion proofs are mere arrays of
+// bytes32, which the verifiers fold over the leaf without knowing which side
+// each of them is on.
+//
+// The trees of NewTree are laid out bottom-up, like the ones of Uniswap's
+// merkle-distributor and of merkletreejs (with sortPairs). OpenZeppelin's
+// StandardMerkleTree lays its leaves out differently, hence, unless their
+// number is a power of two, its roots are only matched by the ones of
+// StandardTree.
+package evm
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/sha3"
+
+	"github.com/ckatsak/merkle"
+	msha3 "github.com/ckatsak/merkle/sha3"
+)
+
+// ErrInvalidHash signifies that the given text is not a valid hexadecimal
+// encoding of a Hash.
+var ErrInvalidHash = errors.New("evm: invalid hash")
+
+// Hash is a Keccak-256 digest, i.e. a bytes32 of the EVM.
+type Hash [32]byte
+
+// BytesToHash returns the Hash whose value is the given bytes, which are
+// cropped from the left, or padded with zeros on the left, to 32 bytes.
+func BytesToHash(b []byte) (h Hash) {
+	if len(b) > len(h) {
+		b = b[len(b)-len(h):]
+	}
+	copy(h[len(h)-len(b):], b)
+	return h
+}
+
+// Bytes returns the bytes of the Hash.
+func (h Hash) Bytes() []byte {
+	return h[:]
+}
+
+// String returns the hexadecimal encoding of the Hash, prefixed with "0x".
+func (h Hash) String() string {
+	return "0x" + hex.EncodeToString(h[:])
+}
+
+// MarshalText implements the encoding.TextMarshaler interface, so that
+// Hashes are encoded the way Ethereum tooling expects them (e.g. in JSON).
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface; the "0x"
+// prefix is optional.
+func (h *Hash) UnmarshalText(text []byte) error {
+	text = bytes.TrimPrefix(bytes.TrimPrefix(text, []byte("0x")), []byte("0X"))
+	if hex.DecodedLen(len(text)) != len(h) {
+		return ErrInvalidHash
+	}
+	if _, err := hex.Decode(h[:], text); err != nil {
+		return ErrInvalidHash
+	}
+	return nil
+}
+
+// Keccak256 returns the Keccak-256 digest of the concatenation of the given
+// data.
+func Keccak256(data ...[]byte) (h Hash) {
+	d := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		d.Write(b)
+	}
+	d.Sum(h[:0])
+	return h
+}
+
+// StandardLeaf returns the leaf that OpenZeppelin's StandardMerkleTree
+// derives from the given ABI-encoded values; i.e. the Keccak-256 digest of
+// their Keccak-256 digest, which keeps leaves from being passed off as
+// merkle nodes of 64 bytes. The root of StandardMerkleTree.of is that of the
+// StandardTree of such leaves (see NewStandardTree).
+func StandardLeaf(encoded []byte) Hash {
+	h := Keccak256(encoded)
+	return Keccak256(h[:])
+}
+
+// Options returns the merkle.Options that configure a merkle tree to hash the
+// way EVM verifiers do. They can be combined with further ones (e.g. a
+// merkle.NodeStore), as long as these do not alter the way it hashes.
+func Options() []merkle.Option {
+	return []merkle.Option{
+		msha3.Keccak256(),
+		merkle.SortedPairs(),
+		merkle.WithPaddingPolicy(merkle.PromoteLone),
+		merkle.InsertionOrder(),
+	}
+}
+
+// NewTree creates a new merkle tree whose leaves are the given ones (as they
+// are, i.e. without hashing them any further), in the order they are given
+// in, which hashes the way EVM verifiers do.
+//
+// Lone nodes are promoted to the next level, so, unless the number of leaves
+// is a power of two, its root differs from the one of OpenZeppelin's
+// StandardMerkleTree of the same leaves (see NewStandardTree), even though
+// its proofs pass MerkleProof.verify all the same.
+//
+// It returns a non-nil error if no leaves are given.
+func NewTree(leaves ...Hash) (*merkle.Tree, error) {
+	digests := make([][]byte, len(leaves))
+	for i := range leaves {
+		digests[i] = leaves[i][:]
+	}
+	// The crypto.Hash is only reported by the tree; Keccak-256 takes its
+	// place.
+	return merkle.NewTreeFromDigests(crypto.SHA3_256, digests, Options()...)
+}
+
+// Root returns the merkle root of the given merkle tree as a Hash.
+func Root(t *merkle.Tree) Hash {
+	return BytesToHash(t.MerkleRoot())
+}
+
+// Prove returns the inclusion proof of the leaf at the given index of the
+// given merkle tree (which must hash the way EVM verifiers do; see NewTree)
+// as an array of bytes32, suitable for MerkleProof.verify.
+//
+// It returns a non-nil error if the given index is out of range.
+func Prove(t *merkle.Tree, index int) ([]Hash, error) {
+	p, err := t.Proof(index)
+	if err != nil {
+		return nil, err
+	}
+	return ProofOf(p), nil
+}
+
+// ProofOf converts the given merkle.Proof (which must come from a merkle tree
+// that hashes the way EVM verifiers do; see NewTree) to an array of bytes32,
+// dropping the empty siblings of the nodes that are promoted to the next
+// level on their own.
+func ProofOf(p *merkle.Proof) []Hash {
+	proof := make([]Hash, 0, len(p.Siblings))
+	for _, sibling := range p.Siblings {
+		if len(sibling) != 0 {
+			proof = append(proof, BytesToHash(sibling))
+		}
+	}
+	return proof
+}
+
+// ProcessProof returns the merkle root that the given proof leads to from
+// the given leaf, the way MerkleProof.processProof does.
+func ProcessProof(proof []Hash, leaf Hash) Hash {
+	computed := leaf
+	for _, sibling := range proof {
+		computed = hashPair(computed, sibling)
+	}
+	return computed
+}
+
+// Verify reports whether the given proof leads to the given merkle root from
+// the given leaf, the way MerkleProof.verify does.
+func Verify(proof []Hash, root, leaf Hash) bool {
+	return ProcessProof(proof, leaf) == root
+}
+
+// hashPair returns the Keccak-256 digest of the given pair of digests, in
+// ascending order.
+func hashPair(a, b Hash) Hash {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return Keccak256(a[:], b[:])
+}
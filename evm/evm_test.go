@@ -0,0 +1,83 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package evm
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+)
+
+func TestKeccak25600(t *testing.T) {
+	const want = "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"
+	if got := Keccak256(); hex.EncodeToString(got[:]) != want {
+		t.Fatalf("want (%s); got %x", want, got)
+	}
+}
+
+func TestHash00(t *testing.T) {
+	h := Keccak256([]byte("merkle"))
+	b, err := json.Marshal([]Hash{h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", b)
+	var hs []Hash
+	if err := json.Unmarshal(b, &hs); err != nil {
+		t.Fatal(err)
+	}
+	if len(hs) != 1 || hs[0] != h {
+		t.Fatalf("want (%v); got %v", []Hash{h}, hs)
+	}
+	if err := hs[0].UnmarshalText([]byte("0x1234")); err != ErrInvalidHash {
+		t.Fatalf("want (%v); got %v", ErrInvalidHash, err)
+	}
+}
+
+func TestNewTree00(t *testing.T) {
+	leaves := make([]Hash, 5)
+	for i := range leaves {
+		leaves[i] = StandardLeaf([]byte{byte(i)})
+	}
+	tree, err := NewTree(leaves...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := Root(tree)
+	t.Logf("root: %v", root)
+
+	want := hashPair(hashPair(hashPair(leaves[0], leaves[1]), hashPair(leaves[2], leaves[3])), leaves[4])
+	if root != want {
+		t.Fatalf("want root (%v); got %v", want, root)
+	}
+	for i := range leaves {
+		proof, err := Prove(tree, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(proof, root, leaves[i]) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+		if Verify(proof, root, leaves[(i+1)%len(leaves)]) {
+			t.Fatalf("proof of leaf %d verifies leaf %d", i, (i+1)%len(leaves))
+		}
+	}
+	if proof, _ := Prove(tree, 4); len(proof) != 1 {
+		t.Fatalf("want (1) sibling; got %d", len(proof))
+	}
+	if _, err := Prove(tree, len(leaves)); err == nil {
+		t.Fatalf("want error; got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package evm
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ckatsak/merkle"
+)
+
+// StandardTree is a merkle tree in the layout of OpenZeppelin's
+// StandardMerkleTree: its leaves are sorted, and laid out in the last slots
+// of a complete binary tree, in reverse order, with every other node being
+// the hashPair of its children. Unlike the trees of NewTree, its root thus
+// matches the one of StandardMerkleTree.of for any number of leaves (and not
+// only for powers of two), given the same leaves (see StandardLeaf).
+type StandardTree struct {
+	// nodes holds the nodes of the tree in heap order, i.e. the children
+	// of nodes[i] are nodes[2*i+1] and nodes[2*i+2]; the last of them are
+	// the leaves.
+	nodes []Hash
+}
+
+// NewStandardTree creates a new StandardTree whose leaves are the given ones
+// (as they are, i.e. without hashing them any further), sorted the way
+// StandardMerkleTree.of sorts them.
+//
+// It returns a non-nil error (merkle.ErrNoData) if no leaves are given.
+func NewStandardTree(leaves ...Hash) (*StandardTree, error) {
+	if len(leaves) == 0 {
+		return nil, merkle.ErrNoData
+	}
+	sorted := make([]Hash, len(leaves))
+	copy(sorted, leaves)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+
+	nodes := make([]Hash, 2*len(sorted)-1)
+	for i := range sorted {
+		nodes[len(nodes)-1-i] = sorted[i]
+	}
+	for i := len(nodes) - 1 - len(sorted); i >= 0; i-- {
+		nodes[i] = hashPair(nodes[2*i+1], nodes[2*i+2])
+	}
+	return &StandardTree{nodes: nodes}, nil
+}
+
+// Root returns the merkle root of the StandardTree.
+func (t *StandardTree) Root() Hash {
+	return t.nodes[0]
+}
+
+// Leaves returns the leaves of the StandardTree, in the order that they are
+// sorted in.
+func (t *StandardTree) Leaves() []Hash {
+	n := (len(t.nodes) + 1) / 2
+	leaves := make([]Hash, n)
+	for i := range leaves {
+		leaves[i] = t.nodes[len(t.nodes)-1-i]
+	}
+	return leaves
+}
+
+// Prove returns the inclusion proof of the given leaf as an array of bytes32,
+// the way StandardMerkleTree.getProof does; it is suitable for
+// MerkleProof.verify.
+//
+// It returns a non-nil error (merkle.ErrNoData) if the leaf is not present.
+func (t *StandardTree) Prove(leaf Hash) ([]Hash, error) {
+	i := -1
+	for j := len(t.nodes) / 2; j < len(t.nodes); j++ {
+		if t.nodes[j] == leaf {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return nil, merkle.ErrNoData
+	}
+	var proof []Hash
+	for i > 0 {
+		// The sibling of a left child (odd index) is to its right.
+		if i%2 == 1 {
+			proof = append(proof, t.nodes[i+1])
+		} else {
+			proof = append(proof, t.nodes[i-1])
+		}
+		i = (i - 1) / 2
+	}
+	return proof, nil
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package evm
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+// TestNewStandardTree00 checks against the example of the README of
+// OpenZeppelin's merkle-tree library, i.e. the StandardMerkleTree of
+// ["address", "uint256"] values.
+func TestNewStandardTree00(t *testing.T) {
+	leaf := func(account byte, amount string) Hash {
+		var encoded [64]byte
+		for i := 12; i < 32; i++ {
+			encoded[i] = account
+		}
+		a, _ := new(big.Int).SetString(amount, 10)
+		a.FillBytes(encoded[32:])
+		return StandardLeaf(encoded[:])
+	}
+	tree, err := NewStandardTree(leaf(0x11, "5000000000000000000"), leaf(0x22, "2500000000000000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "0xd4dee0beab2d53f2cc83e567171bd2820e49898130a22622b10ead383e90bd77"
+	if got := tree.Root().String(); got != want {
+		t.Fatalf("want root (%s); got %s", want, got)
+	}
+}
+
+func TestNewStandardTree01(t *testing.T) {
+	leaves := make([]Hash, 5)
+	for i := range leaves {
+		leaves[i] = StandardLeaf([]byte{byte(i)})
+	}
+	tree, err := NewStandardTree(leaves...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := tree.Leaves()
+	if !sort.SliceIsSorted(s, func(i, j int) bool { return string(s[i][:]) < string(s[j][:]) }) {
+		t.Fatalf("want sorted leaves; got %v", s)
+	}
+
+	// The leaves are laid out in reverse order in the last slots of the
+	// complete binary tree, so that the first two are paired below the
+	// fifth, rather than promoting the fifth on its own.
+	want := hashPair(hashPair(hashPair(s[0], s[1]), s[4]), hashPair(s[2], s[3]))
+	if tree.Root() != want {
+		t.Fatalf("want root (%v); got %v", want, tree.Root())
+	}
+	bottomUp, err := NewTree(s...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Root(bottomUp) == tree.Root() {
+		t.Fatalf("want different roots; got %v", tree.Root())
+	}
+
+	for _, l := range leaves {
+		proof, err := tree.Prove(l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !Verify(proof, tree.Root(), l) {
+			t.Fatalf("proof of leaf %v does not verify", l)
+		}
+	}
+	if _, err := tree.Prove(Keccak256()); !errors.Is(err, merkle.ErrNoData) {
+		t.Fatalf("want (%v); got %v", merkle.ErrNoData, err)
+	}
+	if _, err := NewStandardTree(); !errors.Is(err, merkle.ErrNoData) {
+		t.Fatalf("want (%v); got %v", merkle.ErrNoData, err)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"io"
+)
+
+const (
+	// DefaultChunkSize is the chunk size that a FileTree uses when none is
+	// given.
+	DefaultChunkSize = 1 << 16
+
+	// BEP52BlockSize is the size of the blocks that BitTorrent v2 (BEP 52)
+	// merkleizes files by.
+	BEP52BlockSize = 16 << 10
+)
+
+// FileTree is a merkle tree whose leaves are the digests of consecutive,
+// fixed-size chunks of a file (or any other stream of bytes), in the order
+// they appear in it; only the last chunk may be shorter.
+//
+// Along with the per-chunk inclusion proofs it produces, a FileTree enables
+// verified partial downloads and integrity checking of large files.
+type FileTree struct {
+	chunkSize int
+	numChunks int
+	size      int64
+	tree      *Tree
+}
+
+// NewFileTree creates a new FileTree given one of the available (i.e. linked
+// into the binary) hash functions, by reading r until EOF and hashing it in
+// chunks of chunkSize bytes; if chunkSize is not positive, DefaultChunkSize
+// is used.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if r is empty, or if reading from r fails.
+func NewFileTree(hash crypto.Hash, r io.Reader, chunkSize int) (*FileTree, error) {
+	return newFileTree(hash, r, chunkSize, false)
+}
+
+// NewBEP52FileTree creates a new FileTree by reading r until EOF, according to
+// the semantics of BitTorrent v2 (BEP 52): the chunks are 16 KiB blocks, the
+// hash function is SHA-256 (which must have been linked into the binary), and
+// the leaves are padded with zero hashes up to the next power of two, so that
+// the merkle root matches the "pieces root" of the file in a v2 torrent.
+//
+// It returns a non-nil error if r is empty, or if reading from r fails.
+func NewBEP52FileTree(r io.Reader) (*FileTree, error) {
+	return newFileTree(crypto.SHA256, r, BEP52BlockSize, true)
+}
+
+func newFileTree(hash crypto.Hash, r io.Reader, chunkSize int, padPowerOfTwo bool) (*FileTree, error) {
+	if !hash.Available() {
+		return nil, &HashError{Hash: hash}
+	}
+	h := hash.New()
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	ft := &FileTree{chunkSize: chunkSize}
+	var tls []treeLeaf
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			h.Reset()
+			h.Write(chunk[:n])
+			tls = append(tls, treeLeaf{digest: h.Sum(nil), orderedID: uint64(len(tls))})
+			ft.size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	if len(tls) == 0 {
+		return nil, ErrNoData
+	}
+	ft.numChunks = len(tls)
+	if padPowerOfTwo {
+		zeroDigest := make([]byte, h.Size())
+		for len(tls)&(len(tls)-1) != 0 {
+			tls = append(tls, treeLeaf{digest: zeroDigest, orderedID: uint64(len(tls))})
+		}
+	}
+
+	// The chunks' leaves are deliberately left in their positional order.
+	ft.tree = &Tree{
+		hash:           hash,
+		tls:            tls,
+		digestOnly:     true,
+		insertionOrder: true,
+		nextID:         uint64(len(tls)),
+	}
+	ft.tree.setNodes(ft.tree.constructMerkleNodes(h, tls))
+	return ft, nil
+}
+
+// NewFileTreeAt is like NewFileTree, but reads the first size bytes of r.
+func NewFileTreeAt(hash crypto.Hash, r io.ReaderAt, size int64, chunkSize int) (*FileTree, error) {
+	return NewFileTree(hash, io.NewSectionReader(r, 0, size), chunkSize)
+}
+
+// MerkleRoot returns the hash digest of the root of the FileTree.
+func (ft *FileTree) MerkleRoot() []byte {
+	return ft.tree.MerkleRoot()
+}
+
+// ChunkSize returns the size of the chunks of the FileTree.
+func (ft *FileTree) ChunkSize() int {
+	return ft.chunkSize
+}
+
+// Size returns the total number of bytes that the FileTree was built from.
+func (ft *FileTree) Size() int64 {
+	return ft.size
+}
+
+// NumChunks returns the number of chunks of the FileTree.
+func (ft *FileTree) NumChunks() int {
+	return ft.numChunks
+}
+
+// ChunkProof returns an inclusion proof for the chunk at the given index.
+//
+// It returns a non-nil error if the given index is out of range.
+func (ft *FileTree) ChunkProof(index int) (*Proof, error) {
+	if index >= ft.numChunks {
+		return nil, &IndexError{Op: "ChunkProof", Index: index, Err: ErrNoData}
+	}
+	return ft.tree.Proof(index)
+}
+
+// PieceLayer returns the digests of the nodes that cover pieceLength bytes of
+// the file each, i.e. the "piece layer" of a BitTorrent v2 torrent, excluding
+// any nodes that lie entirely in the padding. If the whole file fits in a
+// single piece, PieceLayer returns nil, as BEP 52 mandates.
+//
+// It returns a non-nil error if pieceLength is not a power-of-two multiple of
+// the chunk size.
+func (ft *FileTree) PieceLayer(pieceLength int) ([][]byte, error) {
+	height := 0
+	for ft.chunkSize<<height < pieceLength {
+		height++
+	}
+	if ft.chunkSize<<height != pieceLength {
+		return nil, ErrInvalidPieceLength
+	}
+	if ft.size <= int64(pieceLength) {
+		return nil, nil
+	}
+
+	numPieces := int((ft.size + int64(pieceLength) - 1) / int64(pieceLength))
+	layer := make([][]byte, numPieces)
+	for i := range layer {
+		layer[i] = copyBytes(ft.tree.nodeAt(height, i))
+	}
+	return layer, nil
+}
+
+// VerifyChunk verifies that the given chunk is the one at the given index of
+// the FileTree.
+//
+// It returns a non-nil error if the given index is out of range.
+func (ft *FileTree) VerifyChunk(index int, chunk []byte) (bool, error) {
+	p, err := ft.ChunkProof(index)
+	if err != nil {
+		return false, err
+	}
+	return VerifyChunk(ft.MerkleRoot(), chunk, p), nil
+}
+
+// VerifyChunk verifies that the given chunk is the one that the given Proof
+// (as produced by FileTree.ChunkProof) refers to, and that the Proof leads to
+// the given merkle root. It requires no FileTree, thus it is suitable for the
+// receiving end of a partial download.
+func VerifyChunk(root, chunk []byte, p *Proof) bool {
+	s := schemeOrDefault(p.scheme)
+	if !s.available(p.Hash) {
+		return false
+	}
+	h := s.newHasher(p.Hash)
+	h.Write(chunk)
+	return bytes.Equal(h.Sum(nil), p.LeafDigest) && p.Verify(root)
+}
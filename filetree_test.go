@@ -0,0 +1,148 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"strings"
+	"testing"
+)
+
+func TestFileTree00(t *testing.T) {
+	if _, err := NewFileTree(crypto.SHA256, strings.NewReader(""), 4); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := NewFileTree(crypto.SHA512, strings.NewReader("abc"), 4); err == nil {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+}
+func TestFileTree01(t *testing.T) {
+	data := []byte(strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100))
+	const chunkSize = 64
+
+	ft, err := NewFileTree(crypto.SHA256, bytes.NewReader(data), chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("ft.MerkleRoot(): %x", ft.MerkleRoot())
+	t.Log("ft.NumChunks():", ft.NumChunks())
+	t.Log("ft.Size():", ft.Size())
+	if ft.Size() != int64(len(data)) || ft.NumChunks() != (len(data)+chunkSize-1)/chunkSize {
+		t.Fatalf("wrong size (%d) or number of chunks (%d)", ft.Size(), ft.NumChunks())
+	}
+
+	ft2, err := NewFileTreeAt(crypto.SHA256, bytes.NewReader(data), int64(len(data)), chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ft.MerkleRoot(), ft2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", ft.MerkleRoot(), ft2.MerkleRoot())
+	}
+
+	for i := 0; i < ft.NumChunks(); i++ {
+		end := (i + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		chunk := data[i*chunkSize : end]
+		if v, err := ft.VerifyChunk(i, chunk); err != nil || !v {
+			t.Fatalf("ERROR while verifying chunk %d: (%v, %v)", i, v, err)
+		}
+	}
+	// Chunks are positional: identical chunks at different offsets must not
+	// be interchangeable, unless their contents are identical too.
+	p, _ := ft.ChunkProof(1)
+	if VerifyChunk(ft.MerkleRoot(), data[:chunkSize], p) && !bytes.Equal(data[:chunkSize], data[chunkSize:2*chunkSize]) {
+		t.Fatalf("chunk 0 verified as chunk 1")
+	}
+}
+
+// bep52Root calculates the BEP 52 merkle root of the given block digests
+// recursively, straight from the specification.
+func bep52Root(digests [][]byte) []byte {
+	if len(digests) == 1 {
+		return digests[0]
+	}
+	n := 1
+	for n < len(digests) {
+		n <<= 1
+	}
+	for len(digests) < n {
+		digests = append(digests, make([]byte, 32))
+	}
+	h := crypto.SHA256.New()
+	h.Write(bep52Root(digests[:n/2]))
+	h.Write(bep52Root(digests[n/2:]))
+	return h.Sum(nil)
+}
+
+func TestBEP52FileTree00(t *testing.T) {
+	for _, size := range []int{1, BEP52BlockSize, BEP52BlockSize + 1, 5*BEP52BlockSize + 7, 8 * BEP52BlockSize} {
+		data := bytes.Repeat([]byte{0x5a}, size)
+		ft, err := NewBEP52FileTree(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var digests [][]byte
+		for i := 0; i < size; i += BEP52BlockSize {
+			end := i + BEP52BlockSize
+			if end > size {
+				end = size
+			}
+			h := crypto.SHA256.New()
+			h.Write(data[i:end])
+			digests = append(digests, h.Sum(nil))
+		}
+		if want := bep52Root(digests); !bytes.Equal(ft.MerkleRoot(), want) {
+			t.Fatalf("size %d: want pieces root %x; got %x", size, want, ft.MerkleRoot())
+		}
+		t.Logf("size %7d: pieces root %x", size, ft.MerkleRoot())
+
+		for i := 0; i < ft.NumChunks(); i++ {
+			p, err := ft.ChunkProof(i)
+			if err != nil || !p.Verify(ft.MerkleRoot()) {
+				t.Fatalf("size %d: proof of block %d failed: %v", size, i, err)
+			}
+		}
+	}
+}
+func TestBEP52FileTree01(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 5*BEP52BlockSize/16+3)
+	ft, err := NewBEP52FileTree(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ft.PieceLayer(3 * BEP52BlockSize); err == nil {
+		t.Fatalf("want (%v); got %v", ErrInvalidPieceLength, err)
+	}
+	layer, err := ft.PieceLayer(2 * BEP52BlockSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layer) != 3 {
+		t.Fatalf("want 3 pieces; got %d", len(layer))
+	}
+	// The piece layer, padded up to the next power of two, must lead to
+	// the pieces root; the padding hashes are those of zeroed subtrees.
+	pad := bep52Root([][]byte{make([]byte, 32), make([]byte, 32)})
+	if root := bep52Root(append(layer, pad)); !bytes.Equal(root, ft.MerkleRoot()) {
+		t.Fatalf("want pieces root %x; got %x", ft.MerkleRoot(), root)
+	}
+	if layer, _ = ft.PieceLayer(8 * BEP52BlockSize); layer != nil {
+		t.Fatalf("want no piece layer for a single-piece file; got %d pieces", len(layer))
+	}
+}
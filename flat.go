@@ -0,0 +1,296 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/binary"
+	"io"
+)
+
+// The flat encoding of a merkle tree is laid out so that it can be memory
+// mapped and used in place:
+//
+//	+-------+---------+-------+----------+------+-----------+-----------+
+//	| magic | version | flags |  arity   | hash | numLevels | numLeaves |
+//	|  (4)  |   (1)   |  (1)  |   (2)    | (4)  |    (4)    |    (8)    |
+//	+-------+---------+-------+----------+------+-----------+-----------+
+//	| offsets of levels 0 (leaves) to numLevels (root), 8 bytes each    |
+//	+-------------------------------------------------------------------+
+//	| digests of level 0, then of level 1, ..., then of the root        |
+//	+-------------------------------------------------------------------+
+//
+// All integers are big-endian, and the digests of each level are stored from
+// left to right, at the offset recorded for that level. An arity of 0 stands
+// for binary trees. Bits 4 to 6 of the flags hold the PaddingPolicy, if
+// flatFlagPadding is set. If flatFlagPadded is set, the offsets are followed
+// by the fixed depth (or 0) of the padded tree (see PadToPowerOfTwo) and the
+// size of the digest of its empty leaves (or 0), 4 bytes each, and by that
+// digest. The size of the digests follows from the offsets, so digests that
+// are truncated (see TruncateDigests) need not be recorded otherwise.
+const (
+	flatVersion    byte = 1
+	flatHeaderSize      = 24
+)
+
+const (
+	flatFlagRFC6962 byte = 1 << iota
+	flatFlagPadding
+	flatFlagPadded
+	flatFlagSortedPairs
+
+	flatPaddingShift = 4
+	flatPaddingMask  = 0x7
+
+	flatFlagLengthPrefixed byte = 1 << 7
+)
+
+// flatMagic prefixes every flat encoding of a merkle tree.
+var flatMagic = []byte("MRKF")
+
+// WriteFlat writes the flat encoding of the merkle tree to the given
+// io.Writer; i.e. a header, followed by the digests of all of its nodes, level
+// by level, at fixed offsets. Unlike the rest of its encodings, it can be
+// memory mapped and opened in place through OpenFlat (see the store/mmap
+// package), without reading it as a whole.
+//
+// The serialized data of the leaves are not included.
+//
+// It returns a non-nil error if the arity of the merkle tree does not fit in
+// the header, if its hash function was given through WithHashFunc, if its
+// leaves are keyed (see WithLeafKey), or if writing to w fails.
+func (t *Tree) WriteFlat(w io.Writer) error {
+	if t.scheme.arity > 0xffff || !t.scheme.encodable() {
+		return ErrUnsupported
+	}
+	b := t.appendFlatHeader(nil, len(t.tls), t.rows, t.newHasher().Size())
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(b); err != nil {
+		return err
+	}
+	for height := 0; height <= len(t.rows); height++ {
+		for index := 0; index < t.levelWidth(height); index++ {
+			digest, err := t.node(height, index)
+			if err != nil {
+				return err
+			}
+			if _, err := bw.Write(digest); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// appendFlatHeader appends to b the header of the flat encoding of a merkle
+// tree of the scheme and hash function of this one, but of the given number of
+// leaves, of merkle nodes of the given number at each level (see
+// merkleNumbers), and of digests of the given size; i.e. everything up to the
+// digests of its leaves.
+func (t *Tree) appendFlatHeader(b []byte, numLeaves int, rows []int, size int) []byte {
+	offsets := make([]uint64, len(rows)+1)
+	offsets[0] = uint64(flatHeaderSize + 8*len(offsets))
+	if t.scheme.padded {
+		offsets[0] += uint64(8 + len(t.scheme.emptyLeaf))
+	}
+	for height := 1; height < len(offsets); height++ {
+		width := numLeaves
+		if height > 1 {
+			width = rows[height-2]
+		}
+		offsets[height] = offsets[height-1] + uint64(size*width)
+	}
+
+	var flags byte
+	if t.scheme.isRFC6962() {
+		flags |= flatFlagRFC6962
+	}
+	if t.scheme.padding != t.scheme.impliedPadding() {
+		flags |= flatFlagPadding | byte(t.scheme.padding)<<flatPaddingShift
+	}
+	if t.scheme.padded {
+		flags |= flatFlagPadded
+	}
+	if t.scheme.sortedPairs {
+		flags |= flatFlagSortedPairs
+	}
+	if t.scheme.lengthPrefixed {
+		flags |= flatFlagLengthPrefixed
+	}
+	b = append(b, flatMagic...)
+	b = append(b, flatVersion, flags)
+	b = binary.BigEndian.AppendUint16(b, uint16(t.scheme.arity))
+	b = binary.BigEndian.AppendUint32(b, uint32(t.hash))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(rows)))
+	b = binary.BigEndian.AppendUint64(b, uint64(numLeaves))
+	for _, offset := range offsets {
+		b = binary.BigEndian.AppendUint64(b, offset)
+	}
+	if t.scheme.padded {
+		b = binary.BigEndian.AppendUint32(b, uint32(t.scheme.depth))
+		b = binary.BigEndian.AppendUint32(b, uint32(len(t.scheme.emptyLeaf)))
+		b = append(b, t.scheme.emptyLeaf...)
+	}
+	return b
+}
+
+// OpenFlat opens the merkle tree whose flat encoding (as written by
+// WriteFlat) is given, in place; i.e. the tree reads the digests of its nodes
+// directly out of data, which hence must not be modified for as long as the
+// tree is in use. No hash calculations take place.
+//
+// The opened tree is digest-only, and its leaves are kept in the order they
+// are encoded in, as if InsertionOrder had been given. Modifying it (e.g.
+// through AppendAndReconstruct) moves its merkle nodes into memory, leaving
+// data intact.
+//
+// It returns a non-nil error if data are not a valid flat encoding of a merkle
+// tree, or if the hash function they were produced with has not been linked
+// into the binary.
+func OpenFlat(data []byte) (*Tree, error) {
+	if len(data) < flatHeaderSize || string(data[:4]) != string(flatMagic) || data[4] != flatVersion {
+		return nil, ErrInvalidEncoding
+	}
+	t := &Tree{
+		hash:           crypto.Hash(binary.BigEndian.Uint32(data[8:])),
+		digestOnly:     true,
+		insertionOrder: true,
+	}
+	if data[5]&flatFlagRFC6962 != 0 {
+		t.scheme = rfc6962Scheme
+	}
+	if arity := int(binary.BigEndian.Uint16(data[6:])); arity > 2 {
+		t.scheme.arity = arity
+	} else if arity != 0 {
+		return nil, ErrInvalidEncoding
+	}
+	if data[5]&flatFlagPadding != 0 {
+		padding := PaddingPolicy(data[5] >> flatPaddingShift & flatPaddingMask)
+		if !padding.valid() {
+			return nil, ErrInvalidEncoding
+		}
+		t.scheme.padding = padding
+	}
+	t.scheme.sortedPairs = data[5]&flatFlagSortedPairs != 0
+	t.scheme.lengthPrefixed = data[5]&flatFlagLengthPrefixed != 0
+	if t.hash == 0 || t.hash >= maxHash {
+		return nil, ErrInvalidEncoding
+	}
+	if !t.hash.Available() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	numLevels := uint64(binary.BigEndian.Uint32(data[12:]))
+	numLeaves := binary.BigEndian.Uint64(data[16:])
+	size := uint64(t.hash.Size())
+	if numLeaves == 0 || numLeaves > uint64(len(data)) {
+		return nil, ErrInvalidEncoding
+	}
+	expected := uint64(flatHeaderSize + 8*(numLevels+1))
+	if numLevels >= 64 || uint64(len(data)) < expected {
+		return nil, ErrInvalidEncoding
+	}
+	if data[5]&flatFlagPadded != 0 {
+		if uint64(len(data)) < expected+8 {
+			return nil, ErrInvalidEncoding
+		}
+		depth := binary.BigEndian.Uint32(data[expected:])
+		emptyLeafSize := uint64(binary.BigEndian.Uint32(data[expected+4:]))
+		if depth >= 64 || emptyLeafSize > size || uint64(len(data)) < expected+8+emptyLeafSize {
+			return nil, ErrInvalidEncoding
+		}
+		t.scheme.padded, t.scheme.depth = true, int(depth)
+		if emptyLeafSize != 0 {
+			t.scheme.emptyLeaf = copyBytes(data[expected+8 : expected+8+emptyLeafSize])
+		}
+		expected += 8 + emptyLeafSize
+	}
+	_, rowSizes := t.merkleNumbers(int(numLeaves))
+	if numLevels != uint64(len(rowSizes)) {
+		return nil, ErrInvalidEncoding
+	}
+
+	// The digests follow, all of the same size; if it is smaller than the
+	// one of the hash function, they are truncated.
+	numNodes := numLeaves
+	for _, rowSize := range rowSizes {
+		numNodes += uint64(rowSize)
+	}
+	if uint64(len(data)) <= expected || (uint64(len(data))-expected)%numNodes != 0 {
+		return nil, ErrInvalidEncoding
+	}
+	if digestSize := (uint64(len(data)) - expected) / numNodes; digestSize > size {
+		return nil, ErrInvalidEncoding
+	} else if digestSize < size {
+		t.scheme.digestSize, size = int(digestSize), digestSize
+	}
+	if t.checkPadding(int(numLeaves)) != nil {
+		return nil, ErrInvalidEncoding
+	}
+
+	// The offsets must be the ones of the tightly packed layout, and the
+	// data must end with the root.
+	offsets := make([]int, numLevels+1)
+	for height := range offsets {
+		if binary.BigEndian.Uint64(data[flatHeaderSize+8*height:]) != expected {
+			return nil, ErrInvalidEncoding
+		}
+		offsets[height] = int(expected)
+		if height == 0 {
+			expected += numLeaves * size
+		} else {
+			expected += uint64(rowSizes[height-1]) * size
+		}
+	}
+	if uint64(len(data)) != expected {
+		return nil, ErrInvalidEncoding
+	}
+
+	t.tls = make([]treeLeaf, numLeaves)
+	for i := range t.tls {
+		start := offsets[0] + i*int(size)
+		t.tls[i] = treeLeaf{
+			digest:    data[start : start+int(size) : start+int(size)],
+			orderedID: uint64(i),
+		}
+	}
+	t.rows, t.nextID = rowSizes, numLeaves
+	t.store = &flatStore{data: data, offsets: offsets, size: int(size), rows: rowSizes}
+	return t, nil
+}
+
+// flatStore is the read-only NodeStore of a tree opened through OpenFlat.
+type flatStore struct {
+	data    []byte
+	offsets []int
+	size    int
+	rows    []int
+}
+
+func (s *flatStore) Get(level, index int) ([]byte, error) {
+	if level < 1 || level > len(s.rows) || index < 0 || index >= s.rows[level-1] {
+		return nil, &IndexError{Op: "Get", Index: index, Err: ErrNoData}
+	}
+	start := s.offsets[level] + index*s.size
+	return s.data[start : start+s.size : start+s.size], nil
+}
+
+func (s *flatStore) Put(level, index int, digest []byte) error {
+	return ErrUnsupported
+}
+
+func (s *flatStore) Delete(level, index int) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestFlat00(t *testing.T) {
+	for _, opts := range [][]Option{nil, {RFC6962()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := tree.WriteFlat(&b); err != nil {
+			t.Fatal(err)
+		}
+		data := b.Bytes()
+		t.Log("len(data):", len(data))
+		if want := flatHeaderSize + 8*tree.Height() + 32*tree.Size(); len(data) != want {
+			t.Fatalf("want (%d); got %d", want, len(data))
+		}
+
+		ftree, err := OpenFlat(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), ftree.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", tree.MerkleRoot(), ftree.MerkleRoot())
+		}
+		for i := 0; i < ftree.NumLeaves(); i++ {
+			if v, err := ftree.VerifyOrderedID(uint64(i)); err != nil || !v {
+				t.Fatalf("ERROR while verifying leaf %d: (%v, %v)", i, v, err)
+			}
+			p, err := ftree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(tree.MerkleRoot()) {
+				t.Fatalf("proof of leaf %d does not verify", i)
+			}
+		}
+
+		// Modifying the opened tree must leave data intact.
+		orig := copyBytes(data)
+		ftree.AppendAndReconstruct(enAlphabetCap...)
+		if err := ftree.StoreErr(); err != nil {
+			t.Fatalf("want (<nil>); got %v", err)
+		}
+		if !bytes.Equal(orig, data) {
+			t.Fatal("data were modified")
+		}
+	}
+}
+
+func TestFlat01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := tree.WriteFlat(&b); err != nil {
+		t.Fatal(err)
+	}
+	data := b.Bytes()
+	for _, bad := range [][]byte{
+		nil,
+		data[:flatHeaderSize],
+		data[:len(data)-1],
+		append(copyBytes(data), 0),
+		append([]byte("XRKF"), data[4:]...),
+	} {
+		if _, err := OpenFlat(bad); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+		}
+	}
+}
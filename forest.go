@@ -0,0 +1,185 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"sort"
+)
+
+// Forest manages a set of named merkle trees (e.g. one per tenant of a
+// service) that share a hash function, a set of Options and, optionally, a
+// storage backend. Its super-root commits to the names and the merkle roots of
+// all of them, so that a single digest can be published for the whole Forest,
+// and the merkle root of each tree can be proven against it.
+//
+// The super-root is the root of a Map that associates the name of each tree
+// with its merkle root; hence, it is nil for an empty Forest.
+//
+// A Forest is not safe for concurrent use.
+type Forest struct {
+	hash   crypto.Hash
+	opts   []Option
+	stores func(name string) NodeStore
+	trees  map[string]*Tree
+}
+
+// NewForest creates a new, empty Forest given one of the available (i.e.
+// linked into the binary) hash functions and the Options that all of its
+// merkle trees are to be created with.
+//
+// If stores is not nil, each merkle tree keeps its merkle nodes in the
+// NodeStore that it returns for the name of the tree (see WithNodeStore);
+// e.g. a namespace of a storage backend that is shared among the whole Forest.
+// Otherwise, the merkle nodes are kept in memory.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary.
+func NewForest(hash crypto.Hash, stores func(name string) NodeStore, opts ...Option) (*Forest, error) {
+	if !hash.Available() {
+		return nil, &HashError{Hash: hash}
+	}
+	return &Forest{
+		hash:   hash,
+		opts:   opts,
+		stores: stores,
+		trees:  make(map[string]*Tree),
+	}, nil
+}
+
+// Len returns the number of merkle trees in the Forest.
+func (f *Forest) Len() int {
+	return len(f.trees)
+}
+
+// Names returns the names of the merkle trees in the Forest, sorted.
+func (f *Forest) Names() []string {
+	names := make([]string, 0, len(f.trees))
+	for name := range f.trees {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Create creates a new merkle tree with the given name in the Forest, out of
+// the given data.
+//
+// It returns a non-nil error if the Forest already holds a merkle tree with
+// the given name, or if the merkle tree cannot be created (see
+// NewTreeWithOptions).
+func (f *Forest) Create(name string, data ...Datum) (*Tree, error) {
+	if _, ok := f.trees[name]; ok {
+		return nil, ErrKeyExists
+	}
+	opts := f.opts
+	if f.stores != nil {
+		opts = append(opts[:len(opts):len(opts)], WithNodeStore(f.stores(name)))
+	}
+	t, err := NewTreeWithOptions(f.hash, data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	f.trees[name] = t
+	return t, nil
+}
+
+// Tree returns the merkle tree with the given name, if any. It can be modified
+// in place (e.g. through AppendAndReconstruct); the super-root follows.
+func (f *Forest) Tree(name string) (*Tree, bool) {
+	t, ok := f.trees[name]
+	return t, ok
+}
+
+// Remove removes the merkle tree with the given name from the Forest, and
+// deletes its merkle nodes from its NodeStore, if any.
+//
+// It returns a non-nil error if the Forest holds no merkle tree with the
+// given name, or if its NodeStore fails to delete its merkle nodes; the merkle
+// tree is removed from the Forest regardless.
+func (f *Forest) Remove(name string) error {
+	t, ok := f.trees[name]
+	if !ok {
+		return &DataError{Op: "Remove", Datum: []byte(name), Err: ErrNoData}
+	}
+	delete(f.trees, name)
+	if !t.userStore {
+		return nil
+	}
+	for height := len(t.rows); height > 0; height-- {
+		for index := 0; index < t.rows[height-1]; index++ {
+			if err := t.store.Delete(height, index); err != nil {
+				return err
+			}
+		}
+	}
+	if fl, ok := t.store.(Flusher); ok {
+		return fl.Flush()
+	}
+	return nil
+}
+
+// Root returns the merkle root of the merkle tree with the given name.
+//
+// It returns a non-nil error if the Forest holds no merkle tree with the
+// given name.
+func (f *Forest) Root(name string) ([]byte, error) {
+	t, ok := f.trees[name]
+	if !ok {
+		return nil, &DataError{Op: "Root", Datum: []byte(name), Err: ErrNoData}
+	}
+	return t.MerkleRoot(), nil
+}
+
+// Roots returns the merkle roots of all the merkle trees in the Forest, by
+// name.
+func (f *Forest) Roots() map[string][]byte {
+	roots := make(map[string][]byte, len(f.trees))
+	for name, t := range f.trees {
+		roots[name] = t.MerkleRoot()
+	}
+	return roots
+}
+
+// SuperRoot returns the digest that commits to the names and the merkle roots
+// of all the merkle trees in the Forest, or nil if the Forest is empty.
+func (f *Forest) SuperRoot() []byte {
+	return f.superMap().Root()
+}
+
+// ProveRoot returns an inclusion proof of the merkle root of the merkle tree
+// with the given name in the super-root of the Forest; i.e. a MapProof whose
+// Key is the name and whose Value is the merkle root.
+//
+// It returns a non-nil error if the Forest holds no merkle tree with the
+// given name.
+func (f *Forest) ProveRoot(name string) (*MapProof, error) {
+	if _, ok := f.trees[name]; !ok {
+		return nil, &DataError{Op: "ProveRoot", Datum: []byte(name), Err: ErrNoData}
+	}
+	return f.superMap().ProveKey([]byte(name))
+}
+
+// superMap returns the Map whose merkle root is the super-root of the Forest.
+// It is built anew every time, since the merkle trees may have been modified
+// in place.
+func (f *Forest) superMap() *Map {
+	m := &Map{hash: f.hash, entries: make(map[string][]byte, len(f.trees))}
+	for name, t := range f.trees {
+		m.entries[name] = t.MerkleRoot()
+	}
+	m.dirty = true
+	return m
+}
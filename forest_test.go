@@ -0,0 +1,113 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestForest00(t *testing.T) {
+	stores := make(map[string]NodeStore)
+	f, err := NewForest(crypto.SHA256, func(name string) NodeStore {
+		stores[name] = NewMemNodeStore()
+		return stores[name]
+	}, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.SuperRoot() != nil {
+		t.Fatalf("want (nil); got %x", f.SuperRoot())
+	}
+	gr, err := f.Create("gr", grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Create("en", enAlphabetCap...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Create("gr", alpha); !errors.Is(err, ErrKeyExists) {
+		t.Fatalf("want (%v); got %v", ErrKeyExists, err)
+	}
+	if names := f.Names(); !reflect.DeepEqual(names, []string{"en", "gr"}) {
+		t.Fatalf("want ([en gr]); got %v", names)
+	}
+	if len(stores) != 2 {
+		t.Fatalf("want (2) stores; got %d", len(stores))
+	}
+
+	superRoot := f.SuperRoot()
+	t.Logf("super-root: %x", superRoot)
+	for name, root := range f.Roots() {
+		mp, err := f.ProveRoot(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(mp.Value, root) || !mp.Verify(superRoot) {
+			t.Fatalf("proof of the root of %q failed", name)
+		}
+	}
+
+	// Modifying a tree in place is reflected in the super-root.
+	gr.AppendAndReconstruct(kk)
+	if bytes.Equal(f.SuperRoot(), superRoot) {
+		t.Fatal("want a different super-root after appending")
+	}
+	mp, err := f.ProveRoot("gr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mp.Value, gr.MerkleRoot()) || !mp.Verify(f.SuperRoot()) {
+		t.Fatal("proof of the root of \"gr\" failed after appending")
+	}
+}
+
+func TestForest01(t *testing.T) {
+	store := NewMemNodeStore()
+	f, err := NewForest(crypto.SHA256, func(string) NodeStore { return store })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Create("gr", grAlphabet...); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Remove("gr"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(1, 0); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if f.Len() != 0 || f.SuperRoot() != nil {
+		t.Fatalf("want an empty forest; got %d trees", f.Len())
+	}
+	for _, err := range []error{
+		f.Remove("gr"),
+		func() error { _, err := f.Root("gr"); return err }(),
+		func() error { _, err := f.ProveRoot("gr"); return err }(),
+	} {
+		if !errors.Is(err, ErrNoData) {
+			t.Fatalf("want (%v); got %v", ErrNoData, err)
+		}
+	}
+	if _, err := f.Create("empty"); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := NewForest(crypto.SHA512, nil); err == nil {
+		t.Fatal("want non-nil error")
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "crypto"
+
+// ReadTree is the read-only view of a merkle tree; i.e. its accessors, along
+// with its proofs and verifications, but none of its mutations. The one that
+// Freeze returns is immutable, so it can be shared among goroutines, and
+// handed to code that is not trusted to leave the merkle tree intact (e.g. a
+// plugin), as its methods return copies of whatever they hand out, and it
+// cannot be converted back into a *Tree. *Tree implements ReadTree too.
+type ReadTree interface {
+	Hash() crypto.Hash
+	Height() int
+	Arity() int
+	Size() int
+	MerkleSize() int
+	NumLeaves() int
+	NextID() uint64
+	IDs() []uint64
+	Stats() Stats
+	MerkleRoot() []byte
+
+	Leaves() [][]byte
+	LeafByID(orderedID uint64) ([]byte, error)
+	LeafDigest(index int) ([]byte, error)
+	IndexOf(datum Datum) (int, error)
+	Node(level, index int) ([]byte, error)
+	Level(level int) [][]byte
+
+	Proof(leafIndex int) (*Proof, error)
+	ProveDatum(datum Datum) (*Proof, error)
+	RootAt(size int) ([]byte, error)
+	InclusionProof(leafIndex, size int) ([][]byte, error)
+	ConsistencyProof(oldSize, newSize int) ([][]byte, error)
+
+	VerifyDatum(datum Datum) (bool, error)
+	VerifySerializedDatum(serializedDatum []byte) (bool, error)
+	VerifyDigest(digest []byte) (bool, error)
+	VerifyLeafDigest(digest []byte) (bool, error)
+	VerifyOrderedID(orderedID uint64) (bool, error)
+}
+
+var _ ReadTree = (*Tree)(nil)
+
+// Freeze returns an immutable ReadTree of the merkle tree as it is now, which
+// is left intact by any subsequent mutation of the latter, as a Snapshot of
+// it is.
+func (t *Tree) Freeze() ReadTree {
+	return &frozenTree{t: t.Snapshot()}
+}
+
+// frozenTree is the ReadTree that Freeze returns. It wraps the snapshot of the
+// merkle tree rather than embed it, so that it cannot be type-asserted to any
+// of its mutations.
+type frozenTree struct {
+	t *Tree
+}
+
+func (f *frozenTree) Hash() crypto.Hash  { return f.t.Hash() }
+func (f *frozenTree) Height() int        { return f.t.Height() }
+func (f *frozenTree) Arity() int         { return f.t.Arity() }
+func (f *frozenTree) Size() int          { return f.t.Size() }
+func (f *frozenTree) MerkleSize() int    { return f.t.MerkleSize() }
+func (f *frozenTree) NumLeaves() int     { return f.t.NumLeaves() }
+func (f *frozenTree) NextID() uint64     { return f.t.NextID() }
+func (f *frozenTree) IDs() []uint64      { return f.t.IDs() }
+func (f *frozenTree) Stats() Stats       { return f.t.Stats() }
+func (f *frozenTree) MerkleRoot() []byte { return f.t.MerkleRoot() }
+
+func (f *frozenTree) Leaves() [][]byte {
+	return f.t.Leaves()
+}
+
+func (f *frozenTree) LeafByID(orderedID uint64) ([]byte, error) {
+	return f.t.LeafByID(orderedID)
+}
+
+func (f *frozenTree) LeafDigest(index int) ([]byte, error) {
+	return f.t.LeafDigest(index)
+}
+
+func (f *frozenTree) IndexOf(datum Datum) (int, error) {
+	return f.t.IndexOf(datum)
+}
+
+func (f *frozenTree) Node(level, index int) ([]byte, error) {
+	return f.t.Node(level, index)
+}
+
+func (f *frozenTree) Level(level int) [][]byte {
+	return f.t.Level(level)
+}
+
+func (f *frozenTree) Proof(leafIndex int) (*Proof, error) {
+	return f.t.Proof(leafIndex)
+}
+
+func (f *frozenTree) ProveDatum(datum Datum) (*Proof, error) {
+	return f.t.ProveDatum(datum)
+}
+
+func (f *frozenTree) RootAt(size int) ([]byte, error) {
+	return f.t.RootAt(size)
+}
+
+func (f *frozenTree) InclusionProof(leafIndex, size int) ([][]byte, error) {
+	return f.t.InclusionProof(leafIndex, size)
+}
+
+func (f *frozenTree) ConsistencyProof(oldSize, newSize int) ([][]byte, error) {
+	return f.t.ConsistencyProof(oldSize, newSize)
+}
+
+func (f *frozenTree) VerifyDatum(datum Datum) (bool, error) {
+	return f.t.VerifyDatum(datum)
+}
+
+func (f *frozenTree) VerifySerializedDatum(serializedDatum []byte) (bool, error) {
+	return f.t.VerifySerializedDatum(serializedDatum)
+}
+
+func (f *frozenTree) VerifyDigest(digest []byte) (bool, error) {
+	return f.t.VerifyDigest(digest)
+}
+
+func (f *frozenTree) VerifyLeafDigest(digest []byte) (bool, error) {
+	return f.t.VerifyLeafDigest(digest)
+}
+
+func (f *frozenTree) VerifyOrderedID(orderedID uint64) (bool, error) {
+	return f.t.VerifyOrderedID(orderedID)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"sync"
+	"testing"
+)
+
+func TestFreeze00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], RecycleNodes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ro := tree.Freeze()
+	root := copyBytes(tree.MerkleRoot())
+	if _, ok := ro.(*Tree); ok {
+		t.Fatal("want (false); got true")
+	}
+	if _, ok := ro.(interface{ AppendAndReconstruct(...Datum) }); ok {
+		t.Fatal("want (false); got true")
+	}
+	ro.MerkleRoot()[0] ^= 0xff
+
+	// Readers of the frozen view are unaffected by mutations of the tree.
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				p, err := ro.Proof(i)
+				if err != nil || !p.Verify(root) {
+					t.Errorf("want (true, <nil>); got (false, %v)", err)
+				}
+				if ok, err := ro.VerifyDatum(grAlphabet[i]); !ok || err != nil {
+					t.Errorf("want (true, <nil>); got (%t, %v)", ok, err)
+				}
+			}
+		}()
+	}
+	for _, datum := range grAlphabet[10:] {
+		tree.AppendAndReconstruct(datum)
+	}
+	wg.Wait()
+
+	if !bytes.Equal(ro.MerkleRoot(), root) || ro.NumLeaves() != 10 || ro.NextID() != 10 {
+		t.Fatalf("want (%x, 10, 10); got (%x, %d, %d)", root, ro.MerkleRoot(), ro.NumLeaves(), ro.NextID())
+	}
+	if ok, _ := ro.VerifyDatum(grAlphabet[10]); ok || tree.NumLeaves() != len(grAlphabet) {
+		t.Fatalf("want (false, %d); got (%t, %d)", len(grAlphabet), ok, tree.NumLeaves())
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"io"
+	"io/fs"
+)
+
+// FSFile is the Datum that represents a regular file in the merkle trees
+// created by NewTreeFromFS; i.e. its path and the digest of its contents.
+type FSFile struct {
+	Path          string
+	ContentDigest []byte
+}
+
+// Serialize implements the Datum interface. The path is followed by a NUL
+// byte (which cannot appear in valid paths) and the content digest, so that
+// the leaves of the tree are ordered by path.
+func (f FSFile) Serialize() []byte {
+	ret := make([]byte, 0, len(f.Path)+1+len(f.ContentDigest))
+	ret = append(ret, f.Path...)
+	ret = append(ret, 0)
+	return append(ret, f.ContentDigest...)
+}
+
+// HashFSFile reads the regular file at the given path of fsys and returns the
+// corresponding FSFile, using the given hash function.
+func HashFSFile(hash crypto.Hash, fsys fs.FS, path string) (FSFile, error) {
+	if !hash.Available() {
+		return FSFile{}, &HashError{Hash: hash}
+	}
+	f, err := fsys.Open(path)
+	if err != nil {
+		return FSFile{}, err
+	}
+	defer f.Close()
+	h := hash.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return FSFile{}, err
+	}
+	return FSFile{Path: path, ContentDigest: h.Sum(nil)}, nil
+}
+
+// NewTreeFromFS creates a new merkle tree by walking fsys in lexical order and
+// hashing each regular file found, along with its path, into an FSFile leaf;
+// anything other than regular files (e.g. directories and symbolic links) is
+// skipped. The hash function is SHA-256, unless the WithHash Option is given.
+//
+// It returns a non-nil error if the hash function has not been linked into
+// the binary, if there are no regular files in fsys, or if walking fsys or
+// reading any of its files fails.
+func NewTreeFromFS(fsys fs.FS, opts ...Option) (*Tree, error) {
+	t := &Tree{hash: crypto.SHA256}
+	for _, opt := range opts {
+		opt(t)
+	}
+	hash := t.hash
+
+	var data []Datum
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		f, err := HashFSFile(hash, fsys, path)
+		if err != nil {
+			return err
+		}
+		data = append(data, f)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewTreeWithOptions(hash, data, opts...)
+}
+
+// ProveFile re-reads the regular file at the given path of fsys and returns
+// an inclusion proof of it (i.e. of its path and current contents) in the
+// merkle tree, as created by NewTreeFromFS.
+//
+// It returns a non-nil error if the file cannot be read, or if it is not
+// present in the merkle tree with its current contents.
+func (t *Tree) ProveFile(fsys fs.FS, path string) (*Proof, error) {
+	f, err := HashFSFile(t.hash, fsys, path)
+	if err != nil {
+		return nil, err
+	}
+	return t.ProveDatum(f)
+}
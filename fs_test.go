@@ -0,0 +1,71 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewTreeFromFS00(t *testing.T) {
+	fsys := fstest.MapFS{
+		"etc/hosts":         {Data: []byte("127.0.0.1 localhost\n")},
+		"etc/resolv.conf":   {Data: []byte("nameserver 1.1.1.1\n")},
+		"usr/bin/app":       {Data: []byte("\x7fELF")},
+		"usr/share/empty":   {Data: []byte{}},
+		"var/lib/app/state": {Data: []byte("state")},
+	}
+	tree, err := NewTreeFromFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+	if tree.NumLeaves() != len(fsys) {
+		t.Fatalf("want %d leaves; got %d", len(fsys), tree.NumLeaves())
+	}
+	for path := range fsys {
+		p, err := tree.ProveFile(fsys, path)
+		if err != nil {
+			t.Fatalf("ERROR while proving %q: %v", path, err)
+		}
+		if !p.Verify(tree.MerkleRoot()) {
+			t.Fatalf("proof of %q failed", path)
+		}
+	}
+
+	// The same contents must always lead to the same merkle root...
+	tree2, err := NewTreeFromFS(fsys, WithHash(crypto.SHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	// ...while tampering with any file must be detected.
+	fsys["etc/hosts"] = &fstest.MapFile{Data: []byte("6.6.6.6 localhost\n")}
+	if _, err = tree.ProveFile(fsys, "etc/hosts"); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+func TestNewTreeFromFS01(t *testing.T) {
+	if _, err := NewTreeFromFS(fstest.MapFS{}); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := NewTreeFromFS(fstest.MapFS{"a": {}}, WithHash(crypto.SHA512)); err == nil {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "hash"
+
+// Hasher hashes the leaves and the merkle nodes of a merkle tree by itself,
+// rather than by feeding their inputs to a hash.Hash as a single stream of
+// bytes; e.g. an arithmetization-friendly hash function, such as Poseidon or
+// Rescue, whose inputs are elements of a finite field, so that the merkle
+// roots it produces are cheap to verify inside SNARK circuits.
+//
+// Since the digests are field elements themselves, the children of each
+// merkle node are given to the Hasher as separate inputs, and no domain
+// separation prefixes are applied to them (see WithHasher). A Hasher is
+// shared by the merkle tree and its proofs, so it must be safe for
+// concurrent use.
+type Hasher interface {
+	// Size returns the size of the digests, i.e. of the canonical
+	// encoding of a field element.
+	Size() int
+	// HashLeaf returns the digest of the leaf of the given serialized
+	// datum (see SplitFieldElements).
+	HashLeaf(serializedDatum []byte) []byte
+	// HashNode returns the digest of the merkle node of the given
+	// children, from left to right; there are as many of them as the
+	// arity of the merkle tree, unless it promotes lone nodes (see
+	// PaddingPolicy).
+	HashNode(children [][]byte) []byte
+}
+
+// WithHasher configures the merkle tree to hash with the given Hasher,
+// identified by the given name, rather than with the crypto.Hash given to
+// the constructor (which Hash then merely reports). The leaf and node
+// prefixes (e.g. of RFC6962) are ignored, while the rest of the Options
+// (e.g. WithArity, SortedPairs and the PaddingPolicy) apply as usual; the
+// nodes that a PaddingPolicy pads are given to HashNode along with their
+// padding.
+//
+// Like the ones of WithHashFunc, such merkle trees (and their proofs) cannot
+// be encoded. Where a bare hash function is called for (e.g. for the chunks
+// of a FileTree), the Hasher's HashLeaf is used.
+func WithHasher(name string, h Hasher) Option {
+	return func(t *Tree) {
+		t.scheme.hashName, t.scheme.hasher = name, h
+		t.scheme.newHash = func() hash.Hash {
+			return &hasherHash{hasher: h}
+		}
+	}
+}
+
+// SplitFieldElements splits the given data into consecutive elements of
+// elementSize bytes each (only the last one may be shorter), e.g. 31 bytes
+// for the BN254 scalar field, so that each of them fits in a field element
+// when interpreted as a big-endian integer. If elementSize is not positive,
+// or data fit in a single element, the data are returned as the only one.
+func SplitFieldElements(data []byte, elementSize int) [][]byte {
+	if elementSize <= 0 || len(data) <= elementSize {
+		return [][]byte{data}
+	}
+	elements := make([][]byte, 0, (len(data)+elementSize-1)/elementSize)
+	for len(data) > elementSize {
+		elements = append(elements, data[:elementSize:elementSize])
+		data = data[elementSize:]
+	}
+	return append(elements, data)
+}
+
+// hasherHash adapts a Hasher to the hash.Hash interface, so that a merkle
+// tree can construct it like the rest of its hash functions; its digest is
+// the one of the leaf of the data written to it.
+type hasherHash struct {
+	hasher Hasher
+	buf    []byte
+}
+
+func (h *hasherHash) Write(p []byte) (int, error) {
+	h.buf = append(h.buf, p...)
+	return len(p), nil
+}
+
+func (h *hasherHash) Sum(b []byte) []byte {
+	return append(b, h.hasher.HashLeaf(h.buf)...)
+}
+
+func (h *hasherHash) Reset() {
+	h.buf = h.buf[:0]
+}
+
+func (h *hasherHash) Size() int {
+	return h.hasher.Size()
+}
+
+func (h *hasherHash) BlockSize() int {
+	return h.hasher.Size()
+}
+
+// padChildren returns the given children, followed by n padding nodes after
+// the last one, as per the PaddingPolicy.
+func (s *scheme) padChildren(children [][]byte, n int) [][]byte {
+	if n <= 0 || s.padding == HashLone || s.padding == PromoteLone {
+		return children
+	}
+	padded := make([][]byte, len(children), len(children)+n)
+	copy(padded, children)
+	last := children[len(children)-1]
+	for ; n > 0; n-- {
+		if s.padding == DuplicateLast {
+			padded = append(padded, last)
+		} else {
+			padded = append(padded, make([]byte, len(last)))
+		}
+	}
+	return padded
+}
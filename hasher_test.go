@@ -0,0 +1,132 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// toyHasher is a (cryptographically worthless) hash function over the field
+// of integers modulo 2^61-1, which mimics the way arithmetization-friendly
+// ones consume field elements.
+type toyHasher struct{}
+
+var toyPrime = new(big.Int).SetUint64(1<<61 - 1)
+
+func (toyHasher) Size() int { return 8 }
+
+func (toyHasher) absorb(elements [][]byte, domain int64) []byte {
+	acc := big.NewInt(domain)
+	for _, e := range elements {
+		acc.Mul(acc, big.NewInt(1_000_003))
+		acc.Add(acc, new(big.Int).SetBytes(e))
+		acc.Mul(acc, acc)
+		acc.Mod(acc, toyPrime)
+	}
+	return acc.FillBytes(make([]byte, 8))
+}
+
+func (th toyHasher) HashLeaf(serializedDatum []byte) []byte {
+	return th.absorb(SplitFieldElements(serializedDatum, 7), 1)
+}
+
+func (th toyHasher) HashNode(children [][]byte) []byte {
+	return th.absorb(children, 2)
+}
+
+func TestWithHasher00(t *testing.T) {
+	var th toyHasher
+	data := []Datum{StringDatum("alpha"), StringDatum("beta"), StringDatum("gamma")}
+	tree, err := NewTreeWithOptions(crypto.SHA256, data, WithHasher("toy", th), InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+
+	leaves := make([][]byte, len(data))
+	for i := range data {
+		leaves[i] = th.HashLeaf(data[i].Serialize())
+	}
+	want := th.HashNode([][]byte{th.HashNode(leaves[:2]), th.HashNode(leaves[2:])})
+	if !bytes.Equal(tree.MerkleRoot(), want) {
+		t.Fatalf("want root (%x); got %x", want, tree.MerkleRoot())
+	}
+	for i := range data {
+		p, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(want) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+	if _, err := tree.MarshalBinary(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestWithHasher01(t *testing.T) {
+	var th toyHasher
+	data := []Datum{StringDatum("alpha"), StringDatum("beta"), StringDatum("gamma"), StringDatum("delta")}
+	tree, err := NewTreeWithOptions(crypto.SHA256, data, WithHasher("toy", th), InsertionOrder(),
+		WithArity(3), WithPaddingPolicy(DuplicateLast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaves := make([][]byte, len(data))
+	for i := range data {
+		leaves[i] = th.HashLeaf(data[i].Serialize())
+	}
+	right := th.HashNode([][]byte{leaves[3], leaves[3], leaves[3]})
+	want := th.HashNode([][]byte{th.HashNode(leaves[:3]), right, right})
+	if !bytes.Equal(tree.MerkleRoot(), want) {
+		t.Fatalf("want root (%x); got %x", want, tree.MerkleRoot())
+	}
+	for i := range data {
+		p, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(want) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+}
+
+func TestSplitFieldElements00(t *testing.T) {
+	for _, tc := range []struct {
+		data []byte
+		size int
+		want int
+	}{
+		{nil, 31, 1},
+		{make([]byte, 31), 31, 1},
+		{make([]byte, 32), 31, 2},
+		{make([]byte, 93), 31, 3},
+		{make([]byte, 93), 0, 1},
+	} {
+		elements := SplitFieldElements(tc.data, tc.size)
+		if len(elements) != tc.want {
+			t.Fatalf("want (%d) elements; got %d", tc.want, len(elements))
+		}
+		if !bytes.Equal(bytes.Join(elements, nil), tc.data) {
+			t.Fatalf("want (%x); got %x", tc.data, bytes.Join(elements, nil))
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"hash"
+	"sync"
+)
+
+// scratch is a hash.Hash of a merkle tree, along with the scratch buffers that
+// climbing up a merkle path requires, so that they can be pooled together.
+type scratch struct {
+	h        hash.Hash
+	buf      []byte
+	children [][]byte
+}
+
+// scratchKey identifies the pool of the scratches of merkle trees of the same
+// hash function and digest size; any two of those can share them.
+type scratchKey struct {
+	hash       crypto.Hash
+	digestSize int
+}
+
+// scratchPools holds a *sync.Pool of scratches per scratchKey.
+var scratchPools sync.Map
+
+// WithHasherPool configures whether the merkle tree takes the hash.Hash
+// instances (and scratch buffers) of its verifications (e.g. VerifyDatum) out
+// of a pool that is shared by all merkle trees of the same hash function, so
+// that concurrent verifications do not allocate a new one each; it does by
+// default. Disabling it makes the allocations of each call deterministic,
+// e.g. for benchmarking.
+//
+// Hash functions given through WithHashFunc or WithHasher are never pooled.
+func WithHasherPool(enabled bool) Option {
+	return func(t *Tree) {
+		t.noHasherPool = !enabled
+	}
+}
+
+// scratchPool returns the pool of the scratches of the merkle tree, or nil if
+// they are not pooled.
+func (t *Tree) scratchPool() *sync.Pool {
+	if t.noHasherPool || t.scheme.newHash != nil || t.scheme.hasher != nil {
+		return nil
+	}
+	key := scratchKey{hash: t.hash, digestSize: t.scheme.digestSize}
+	if pool, ok := scratchPools.Load(key); ok {
+		return pool.(*sync.Pool)
+	}
+	pool, _ := scratchPools.LoadOrStore(key, new(sync.Pool))
+	return pool.(*sync.Pool)
+}
+
+// getScratch returns a scratch for the merkle tree, out of its pool if any;
+// it should be handed back through putScratch once no longer needed.
+func (t *Tree) getScratch() *scratch {
+	if pool := t.scratchPool(); pool != nil {
+		if sc, ok := pool.Get().(*scratch); ok {
+			return sc
+		}
+	}
+	h := t.newHasher()
+	return &scratch{
+		h:        h,
+		buf:      make([]byte, 0, h.Size()),
+		children: make([][]byte, 0, t.scheme.width()),
+	}
+}
+
+// putScratch hands the given scratch back to the pool of the merkle tree, if
+// any.
+func (t *Tree) putScratch(sc *scratch) {
+	pool := t.scratchPool()
+	if pool == nil {
+		return
+	}
+	// Do not keep the digests of the last merkle path reachable.
+	for i := range sc.children {
+		sc.children[i] = nil
+	}
+	sc.children = sc.children[:0]
+	pool.Put(sc)
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"sync"
+	"testing"
+)
+
+func TestWithHasherPool00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	utree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly(), WithHasherPool(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := append([]byte(nil), tree.tls[7].digest...)
+	allocs := func(tree *Tree) float64 {
+		return testing.AllocsPerRun(100, func() {
+			if v, err := tree.VerifyLeafDigest(digest); !v || err != nil {
+				t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+			}
+		})
+	}
+	pooled, unpooled := allocs(tree), allocs(utree)
+	if pooled >= unpooled {
+		t.Fatalf("want (fewer than %v) allocations; got %v", unpooled, pooled)
+	}
+	t.Logf("pooled: %v allocations; unpooled: %v", pooled, unpooled)
+
+	// Trees of the same hash function share the pool, even across arities.
+	wtree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithArity(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.scratchPool() == nil || wtree.scratchPool() != tree.scratchPool() || utree.scratchPool() != nil {
+		t.Fatal("want (a shared pool)")
+	}
+}
+
+func TestWithHasherPool01(t *testing.T) {
+	trees := make([]*Tree, 0, 3)
+	for _, opts := range [][]Option{nil, {WithArity(3)}, {TruncateDigests(20), SortedPairs()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				tree := trees[(g+i)%len(trees)]
+				if v, err := tree.VerifyDatum(grAlphabet[(g*7+i)%len(grAlphabet)]); !v || err != nil {
+					errs <- "verification failed"
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
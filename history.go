@@ -0,0 +1,104 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+// Version returns the version of the merkle tree; i.e. the number of
+// mutations (e.g. through AppendAndReconstruct or Appended) that it is the
+// result of, starting from 0 upon its creation.
+func (t *Tree) Version() int {
+	return t.version
+}
+
+// AtVersion returns a snapshot (see Snapshot) of the merkle tree as it was at the
+// given version, which is either the current one or one of those kept
+// through WithHistory.
+//
+// It returns a non-nil error if the given version is not available.
+func (t *Tree) AtVersion(version int) (*Tree, error) {
+	if version == t.version {
+		return t.Snapshot(), nil
+	}
+	if past := t.pastVersion(version); past != nil {
+		return past.Snapshot(), nil
+	}
+	return nil, &IndexError{Op: "AtVersion", Index: version, Err: ErrNoData}
+}
+
+// RootAtVersion returns the merkle root of the merkle tree as it was at the
+// given version (see AtVersion). Unlike RootAt, it is not confined to trees
+// of RFC 6962 or to earlier sizes of them.
+//
+// It returns a non-nil error if the given version is not available.
+func (t *Tree) RootAtVersion(version int) ([]byte, error) {
+	if version == t.version {
+		return t.MerkleRoot(), nil
+	}
+	if past := t.pastVersion(version); past != nil {
+		return past.MerkleRoot(), nil
+	}
+	return nil, &IndexError{Op: "RootAtVersion", Index: version, Err: ErrNoData}
+}
+
+// ProofAtVersion returns an inclusion proof for the leaf at the given index
+// among the leaves of the merkle tree as it was at the given version (see
+// AtVersion), which leads to the merkle root of that version.
+//
+// It returns a non-nil error if the given version is not available, or if
+// the given index is out of range.
+func (t *Tree) ProofAtVersion(version, leafIndex int) (*Proof, error) {
+	if version == t.version {
+		return t.Proof(leafIndex)
+	}
+	if past := t.pastVersion(version); past != nil {
+		return past.Proof(leafIndex)
+	}
+	return nil, &IndexError{Op: "ProofAtVersion", Index: version, Err: ErrNoData}
+}
+
+// Versions returns the range of the versions of the merkle tree that are
+// available through AtVersion; i.e. the oldest one kept and the current one.
+func (t *Tree) Versions() (oldest, current int) {
+	if len(t.history) == 0 {
+		return t.version, t.version
+	}
+	return t.history[0].version, t.version
+}
+
+// pastVersion returns the snapshot of the given earlier version of the merkle
+// tree, or nil if it is not kept.
+func (t *Tree) pastVersion(version int) *Tree {
+	if len(t.history) == 0 {
+		return nil
+	}
+	i := version - t.history[0].version
+	if i < 0 || i >= len(t.history) {
+		return nil
+	}
+	return t.history[i]
+}
+
+// pushVersion records the current version of the merkle tree in its history
+// (if kept) before it is mutated, and advances its version.
+func (t *Tree) pushVersion() {
+	if t.keepHistory {
+		past := t.Snapshot()
+		past.keepHistory, past.history = false, nil
+		t.history = append(t.history, past)
+		if t.historyLimit > 0 && len(t.history) > t.historyLimit {
+			t.history = t.history[len(t.history)-t.historyLimit:]
+		}
+	}
+	t.version++
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestHistory00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:4], WithHistory(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := [][]byte{copyBytes(tree.MerkleRoot())}
+	for _, datum := range grAlphabet[4:12] {
+		tree.AppendAndReconstruct(datum)
+		roots = append(roots, copyBytes(tree.MerkleRoot()))
+	}
+	tree.DeleteAndReconstruct(grAlphabet[0], grAlphabet[1])
+	roots = append(roots, copyBytes(tree.MerkleRoot()))
+	tree, err = tree.Appended(enAlphabetCap[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = append(roots, copyBytes(tree.MerkleRoot()))
+
+	if oldest, current := tree.Versions(); oldest != 0 || current != len(roots)-1 || tree.Version() != current {
+		t.Fatalf("want (0, %d, %d); got %d, %d, %d", len(roots)-1, len(roots)-1, oldest, current, tree.Version())
+	}
+	for version, want := range roots {
+		root, err := tree.RootAtVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root, want) {
+			t.Fatalf("version %d: want (%x); got %x", version, want, root)
+		}
+		past, err := tree.AtVersion(version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < past.NumLeaves(); i++ {
+			p, err := tree.ProofAtVersion(version, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(want) {
+				t.Fatalf("version %d: proof of leaf %d does not verify", version, i)
+			}
+		}
+		// Mutating an earlier version does not alter the history.
+		past.AppendAndReconstruct(kk)
+	}
+	for version, want := range roots {
+		if root, _ := tree.RootAtVersion(version); !bytes.Equal(root, want) {
+			t.Fatalf("version %d: want (%x); got %x", version, want, root)
+		}
+	}
+
+	if _, err := tree.RootAtVersion(len(roots)); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := tree.ProofAtVersion(0, 4); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+
+func TestHistory01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:1], WithHistory(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, datum := range grAlphabet[1:10] {
+		tree.AppendAndReconstruct(datum)
+	}
+	if oldest, current := tree.Versions(); oldest != 6 || current != 9 {
+		t.Fatalf("want (6, 9); got %d, %d", oldest, current)
+	}
+	if _, err := tree.AtVersion(5); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	want, err := NewTree(crypto.SHA256, grAlphabet[:7]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root, err := tree.RootAtVersion(6); err != nil || !bytes.Equal(root, want.MerkleRoot()) {
+		t.Fatalf("want (%x, <nil>); got %x, %v", want.MerkleRoot(), root, err)
+	}
+
+	// Diverging versions keep their own histories.
+	root := copyBytes(tree.MerkleRoot())
+	a, b := tree.Snapshot(), tree.Snapshot()
+	a.AppendAndReconstruct(enAlphabetCap[0])
+	b.AppendAndReconstruct(enAlphabetCap[1])
+	for _, tree := range []*Tree{a, b} {
+		root9, err := tree.RootAtVersion(9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root10, err := tree.RootAtVersion(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root9, root) || !bytes.Equal(root10, tree.MerkleRoot()) {
+			t.Fatalf("want (%x, %x); got %x, %x", root, tree.MerkleRoot(), root9, root10)
+		}
+	}
+	if bytes.Equal(a.MerkleRoot(), b.MerkleRoot()) {
+		t.Fatal("want diverging versions")
+	}
+
+	// Without WithHistory, only the current version is available.
+	tree, err = NewTree(crypto.SHA256, grAlphabet[:2]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(grAlphabet[2])
+	if _, err := tree.RootAtVersion(0); !errors.Is(err, ErrNoData) || tree.Version() != 1 {
+		t.Fatalf("want (%v, 1); got %v, %d", ErrNoData, err, tree.Version())
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package ics23 exports proofs of merkle trees in the CommitmentProof format
+// of ICS-23, which IBC (the Inter-Blockchain Communication protocol) uses to
+// verify the state of one chain on another, and verifies such proofs against
+// a ProofSpec, the way Cosmos chains do.
+//
+// Only existence and non-existence proofs are supported; batch proofs (and
+// their compressed variant) are not. The codecs are written by hand against
+// the protobuf wire format of the cosmos.ics23.v1 messages, rather than
+// generated from their definitions, so that using them does not drag the
+// reflection-based protobuf runtime into the binary.
+package ics23
+
+import (
+	"crypto"
+	"errors"
+
+	"github.com/ckatsak/merkle/internal/pbwire"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	// ErrInvalidProof signifies that a proof is malformed, that it does not
+	// conform to the given ProofSpec, or that it does not lead to the given
+	// root.
+	ErrInvalidProof = errors.New("ics23: invalid proof")
+	// ErrMalformed signifies that the given bytes are not a valid encoding
+	// of a CommitmentProof.
+	ErrMalformed = errors.New("ics23: malformed message")
+	// ErrUnsupported signifies that the requested hash or length operation
+	// is not supported, or that its hash function has not been linked into
+	// the binary.
+	ErrUnsupported = errors.New("ics23: unsupported operation")
+)
+
+// HashOp is the hash function that an operation of a proof applies.
+type HashOp int32
+
+// The HashOps of ICS-23.
+const (
+	NoHash HashOp = iota
+	SHA256
+	SHA512
+	Keccak256
+	RIPEMD160
+	Bitcoin // RIPEMD160(SHA256(data))
+	SHA512_256
+	Blake2b512
+	Blake2s256
+	Blake3
+)
+
+// hashOps maps the HashOps that are backed by the standard library (or its
+// extensions) to their crypto.Hash values.
+var hashOps = map[HashOp]crypto.Hash{
+	SHA256:     crypto.SHA256,
+	SHA512:     crypto.SHA512,
+	RIPEMD160:  crypto.RIPEMD160,
+	SHA512_256: crypto.SHA512_256,
+	Blake2b512: crypto.BLAKE2b_512,
+	Blake2s256: crypto.BLAKE2s_256,
+}
+
+// LengthOp is the way the length of the key and of the value of a leaf are
+// prepended to them.
+type LengthOp int32
+
+// The LengthOps of ICS-23.
+const (
+	NoPrefix LengthOp = iota
+	VarProto
+	VarRLP
+	Fixed32Big
+	Fixed32Little
+	Fixed64Big
+	Fixed64Little
+	Require32Bytes
+	Require64Bytes
+)
+
+type (
+	// CommitmentProof corresponds to the CommitmentProof message; exactly
+	// one of its fields is expected to be set.
+	CommitmentProof struct {
+		Exist    *ExistenceProof
+		Nonexist *NonExistenceProof
+	}
+
+	// ExistenceProof corresponds to the ExistenceProof message; i.e. a proof
+	// that Key is associated with Value. Path is ordered from the leaf up to
+	// the root.
+	ExistenceProof struct {
+		Key   []byte
+		Value []byte
+		Leaf  *LeafOp
+		Path  []*InnerOp
+	}
+
+	// NonExistenceProof corresponds to the NonExistenceProof message; i.e.
+	// a proof that Key is not present, by means of the existence proofs of
+	// its neighbours (either of which may be missing, at the edges).
+	NonExistenceProof struct {
+		Key   []byte
+		Left  *ExistenceProof
+		Right *ExistenceProof
+	}
+
+	// LeafOp corresponds to the LeafOp message; i.e. the way the digest of
+	// a leaf is calculated out of its key and its value:
+	//
+	//	Hash(Prefix || Length(PrehashKey(key)) || Length(PrehashValue(value)))
+	LeafOp struct {
+		Hash         HashOp
+		PrehashKey   HashOp
+		PrehashValue HashOp
+		Length       LengthOp
+		Prefix       []byte
+	}
+
+	// InnerOp corresponds to the InnerOp message; i.e. the way the digest
+	// of an inner node is calculated out of the digest of one of its
+	// children: Hash(Prefix || child || Suffix).
+	InnerOp struct {
+		Hash   HashOp
+		Prefix []byte
+		Suffix []byte
+	}
+)
+
+// Marshal returns the protobuf wire encoding of the CommitmentProof.
+func (p *CommitmentProof) Marshal() ([]byte, error) {
+	var b []byte
+	if p.Exist != nil {
+		b = pbwire.AppendMessage(b, 1, p.Exist.appendProto(nil))
+	}
+	if p.Nonexist != nil {
+		b = pbwire.AppendMessage(b, 2, p.Nonexist.appendProto(nil))
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the given protobuf wire encoding into the
+// CommitmentProof. Unknown fields (e.g. batch proofs) are skipped.
+func (p *CommitmentProof) Unmarshal(b []byte) error {
+	var p2 CommitmentProof
+	err := pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			p2.Exist = new(ExistenceProof)
+			return p2.Exist.unmarshal(bs)
+		case 2:
+			p2.Nonexist = new(NonExistenceProof)
+			return p2.Nonexist.unmarshal(bs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*p = p2
+	return nil
+}
+
+func (p *ExistenceProof) appendProto(b []byte) []byte {
+	b = pbwire.AppendBytes(b, 1, p.Key)
+	b = pbwire.AppendBytes(b, 2, p.Value)
+	if p.Leaf != nil {
+		b = pbwire.AppendMessage(b, 3, p.Leaf.appendProto(nil))
+	}
+	for _, inner := range p.Path {
+		b = pbwire.AppendMessage(b, 4, inner.appendProto(nil))
+	}
+	return b
+}
+
+func (p *ExistenceProof) unmarshal(b []byte) error {
+	return pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			p.Key = bs
+		case 2:
+			p.Value = bs
+		case 3:
+			p.Leaf = new(LeafOp)
+			return p.Leaf.unmarshal(bs)
+		case 4:
+			inner := new(InnerOp)
+			p.Path = append(p.Path, inner)
+			return inner.unmarshal(bs)
+		}
+		return nil
+	})
+}
+
+func (p *NonExistenceProof) appendProto(b []byte) []byte {
+	b = pbwire.AppendBytes(b, 1, p.Key)
+	if p.Left != nil {
+		b = pbwire.AppendMessage(b, 2, p.Left.appendProto(nil))
+	}
+	if p.Right != nil {
+		b = pbwire.AppendMessage(b, 3, p.Right.appendProto(nil))
+	}
+	return b
+}
+
+func (p *NonExistenceProof) unmarshal(b []byte) error {
+	return pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			p.Key = bs
+		case 2:
+			p.Left = new(ExistenceProof)
+			return p.Left.unmarshal(bs)
+		case 3:
+			p.Right = new(ExistenceProof)
+			return p.Right.unmarshal(bs)
+		}
+		return nil
+	})
+}
+
+func (op *LeafOp) appendProto(b []byte) []byte {
+	b = pbwire.AppendVarint(b, 1, uint64(op.Hash))
+	b = pbwire.AppendVarint(b, 2, uint64(op.PrehashKey))
+	b = pbwire.AppendVarint(b, 3, uint64(op.PrehashValue))
+	b = pbwire.AppendVarint(b, 4, uint64(op.Length))
+	return pbwire.AppendBytes(b, 5, op.Prefix)
+}
+
+func (op *LeafOp) unmarshal(b []byte) error {
+	return pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			op.Hash = HashOp(v)
+		case 2:
+			op.PrehashKey = HashOp(v)
+		case 3:
+			op.PrehashValue = HashOp(v)
+		case 4:
+			op.Length = LengthOp(v)
+		case 5:
+			op.Prefix = bs
+		}
+		return nil
+	})
+}
+
+func (op *InnerOp) appendProto(b []byte) []byte {
+	b = pbwire.AppendVarint(b, 1, uint64(op.Hash))
+	b = pbwire.AppendBytes(b, 2, op.Prefix)
+	return pbwire.AppendBytes(b, 3, op.Suffix)
+}
+
+func (op *InnerOp) unmarshal(b []byte) error {
+	return pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		switch num {
+		case 1:
+			op.Hash = HashOp(v)
+		case 2:
+			op.Prefix = bs
+		case 3:
+			op.Suffix = bs
+		}
+		return nil
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ics23
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCommitmentProof00(t *testing.T) {
+	tree, _ := newTestTree(t, 5)
+	for _, key := range []string{"key03", "key04", "key00", "key99"} {
+		proof, err := tree.Prove([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := proof.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var proof2 CommitmentProof
+		if err := proof2.Unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(proof, &proof2) {
+			t.Fatalf("want (%+v); got %+v", proof, &proof2)
+		}
+	}
+	var proof CommitmentProof
+	if err := proof.Unmarshal([]byte{0x0a, 0x05, 0x0a}); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+}
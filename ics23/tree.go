@@ -0,0 +1,146 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ics23
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	"github.com/ckatsak/merkle"
+)
+
+// Tree is an authenticated key-value map that hashes the way the simple
+// merkle maps of CometBFT and the Cosmos SDK do; i.e. a merkle tree that
+// hashes as per RFC 6962 with SHA-256, whose leaves are the entries sorted by
+// key, each one serialized as
+//
+//	uvarint(len(key)) || key || uvarint(32) || SHA-256(value)
+//
+// Its proofs conform to TendermintSpec. A Tree is immutable.
+type Tree struct {
+	keys   [][]byte
+	values [][]byte
+	tree   *merkle.Tree
+}
+
+// NewTree creates a new Tree out of the given entries.
+//
+// It returns a non-nil error if no entries are given, or if any of them has
+// an empty key or value, which ICS-23 does not allow for.
+func NewTree(entries map[string][]byte) (*Tree, error) {
+	if len(entries) == 0 {
+		return nil, merkle.ErrNoData
+	}
+	t := &Tree{
+		keys:   make([][]byte, 0, len(entries)),
+		values: make([][]byte, 0, len(entries)),
+	}
+	for key := range entries {
+		if len(key) == 0 || len(entries[key]) == 0 {
+			return nil, ErrInvalidProof
+		}
+		t.keys = append(t.keys, []byte(key))
+	}
+	sort.Slice(t.keys, func(i, j int) bool {
+		return bytes.Compare(t.keys[i], t.keys[j]) < 0
+	})
+	data := make([]merkle.Datum, len(t.keys))
+	for i, key := range t.keys {
+		value := append([]byte{}, entries[string(key)]...)
+		t.values = append(t.values, value)
+		data[i] = merkle.ByteDatum(leafData(key, value))
+	}
+	var err error
+	if t.tree, err = merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.RFC6962()); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// leafData returns the serialized datum of the leaf of the given entry.
+func leafData(key, value []byte) []byte {
+	digest := sha256.Sum256(value)
+	b := binary.AppendUvarint(nil, uint64(len(key)))
+	b = append(b, key...)
+	b = binary.AppendUvarint(b, uint64(len(digest)))
+	return append(b, digest[:]...)
+}
+
+// Root returns the merkle root of the Tree.
+func (t *Tree) Root() []byte {
+	return t.tree.MerkleRoot()
+}
+
+// Prove returns a CommitmentProof of the given key; i.e. an existence proof
+// if the key is present in the Tree, or a non-existence proof otherwise.
+func (t *Tree) Prove(key []byte) (*CommitmentProof, error) {
+	i := sort.Search(len(t.keys), func(i int) bool {
+		return bytes.Compare(t.keys[i], key) >= 0
+	})
+	if i < len(t.keys) && bytes.Equal(t.keys[i], key) {
+		ep, err := t.existenceProof(i)
+		if err != nil {
+			return nil, err
+		}
+		return &CommitmentProof{Exist: ep}, nil
+	}
+	nep := &NonExistenceProof{Key: append([]byte{}, key...)}
+	var err error
+	if i > 0 {
+		if nep.Left, err = t.existenceProof(i - 1); err != nil {
+			return nil, err
+		}
+	}
+	if i < len(t.keys) {
+		if nep.Right, err = t.existenceProof(i); err != nil {
+			return nil, err
+		}
+	}
+	return &CommitmentProof{Nonexist: nep}, nil
+}
+
+// existenceProof converts the merkle.Proof of the entry at the given index to
+// an ExistenceProof. The empty siblings of the nodes that RFC 6962 promotes
+// to the next level on their own take no step.
+func (t *Tree) existenceProof(index int) (*ExistenceProof, error) {
+	p, err := t.tree.Proof(index)
+	if err != nil {
+		return nil, err
+	}
+	leaf := *TendermintSpec.LeafSpec
+	leaf.Prefix = append([]byte{}, leaf.Prefix...)
+	ep := &ExistenceProof{
+		Key:   append([]byte{}, t.keys[index]...),
+		Value: append([]byte{}, t.values[index]...),
+		Leaf:  &leaf,
+		Path:  make([]*InnerOp, 0, len(p.Siblings)),
+	}
+	for _, sibling := range p.Siblings {
+		if len(sibling) != 0 {
+			op := &InnerOp{Hash: SHA256, Prefix: []byte{0x01}}
+			if index%2 == 0 {
+				op.Suffix = sibling
+			} else {
+				op.Prefix = append(op.Prefix, sibling...)
+			}
+			ep.Path = append(ep.Path, op)
+		}
+		index /= 2
+	}
+	return ep, nil
+}
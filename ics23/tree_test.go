@@ -0,0 +1,52 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ics23
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestTree00(t *testing.T) {
+	// Test vectors of the simple merkle maps of CometBFT.
+	tests := []struct {
+		entries map[string][]byte
+		root    string
+	}{
+		{map[string][]byte{"key1": []byte("value1")}, "a44d3cc7daba1a4600b00a2434b30f8b970652169810d6dfa9fb1793a2189324"},
+		{map[string][]byte{"key1": []byte("value2")}, "0638e99b3445caec9d95c05e1a3fc1487b4ddec6a952ff337080360b0dcc078c"},
+		{map[string][]byte{"key1": []byte("value1"), "key2": []byte("value2")}, "8fd19b19e7bb3f2b3ee0574027d8a5a4cec370464ea2db2fbfa5c7d35bb0cff3"},
+		{map[string][]byte{"key1": []byte("value1"), "key2": []byte("value2"), "key3": []byte("value3")}, "1dd674ec6782a0d586a903c9c63326a41cbe56b3bba33ed6ff5b527af6efb3dc"},
+	}
+	for _, test := range tests {
+		tree, err := NewTree(test.entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if root := hex.EncodeToString(tree.Root()); root != test.root {
+			t.Fatalf("want (%s); got %s", test.root, root)
+		}
+	}
+}
+
+func TestTree01(t *testing.T) {
+	if _, err := NewTree(nil); err == nil {
+		t.Fatal("want non-nil error")
+	}
+	if _, err := NewTree(map[string][]byte{"key": nil}); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("want (%v); got %v", ErrInvalidProof, err)
+	}
+}
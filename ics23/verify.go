@@ -0,0 +1,342 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ics23
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+)
+
+type (
+	// ProofSpec corresponds to the ProofSpec message; i.e. the structure
+	// that the proofs of a merkle tree are expected to conform to. Depths
+	// are only enforced if positive.
+	ProofSpec struct {
+		LeafSpec  *LeafOp
+		InnerSpec *InnerSpec
+		MaxDepth  int
+		MinDepth  int
+	}
+
+	// InnerSpec corresponds to the InnerSpec message; i.e. the layout of the
+	// preimages of the inner nodes: a prefix of MinPrefixLength to
+	// MaxPrefixLength bytes, followed by the digests of the children, of
+	// ChildSize bytes each, in ChildOrder. Empty children (EmptyChild) are
+	// not supported.
+	InnerSpec struct {
+		ChildOrder      []int
+		ChildSize       int
+		MinPrefixLength int
+		MaxPrefixLength int
+		Hash            HashOp
+	}
+)
+
+// TendermintSpec is the ProofSpec of the simple merkle maps of CometBFT and
+// the Cosmos SDK (e.g. of the commitment to its stores), which Tree produces
+// proofs for.
+var TendermintSpec = &ProofSpec{
+	LeafSpec: &LeafOp{
+		Hash:         SHA256,
+		PrehashKey:   NoHash,
+		PrehashValue: SHA256,
+		Length:       VarProto,
+		Prefix:       []byte{0x00},
+	},
+	InnerSpec: &InnerSpec{
+		ChildOrder:      []int{0, 1},
+		ChildSize:       32,
+		MinPrefixLength: 1,
+		MaxPrefixLength: 1,
+		Hash:            SHA256,
+	},
+}
+
+// VerifyMembership verifies that the given CommitmentProof proves that the
+// given key is associated with the given value in the merkle tree of the
+// given root, conforming to the given ProofSpec.
+func VerifyMembership(spec *ProofSpec, root []byte, proof *CommitmentProof, key, value []byte) bool {
+	return proof != nil && proof.Exist != nil && proof.Exist.Verify(spec, root, key, value) == nil
+}
+
+// VerifyNonMembership verifies that the given CommitmentProof proves that the
+// given key is not present in the merkle tree of the given root, conforming to
+// the given ProofSpec.
+func VerifyNonMembership(spec *ProofSpec, root []byte, proof *CommitmentProof, key []byte) bool {
+	return proof != nil && proof.Nonexist != nil && proof.Nonexist.Verify(spec, root, key) == nil
+}
+
+// Calculate returns the root that the ExistenceProof leads to.
+//
+// It returns a non-nil error if the ExistenceProof is malformed, or if any of
+// its operations is not supported.
+func (p *ExistenceProof) Calculate() ([]byte, error) {
+	if p.Leaf == nil {
+		return nil, ErrInvalidProof
+	}
+	digest, err := p.Leaf.Apply(p.Key, p.Value)
+	if err != nil {
+		return nil, err
+	}
+	for _, inner := range p.Path {
+		if digest, err = inner.Apply(digest); err != nil {
+			return nil, err
+		}
+	}
+	return digest, nil
+}
+
+// Verify verifies that the ExistenceProof conforms to the given ProofSpec,
+// that it is a proof of the given key and value, and that it leads to the
+// given root.
+func (p *ExistenceProof) Verify(spec *ProofSpec, root, key, value []byte) error {
+	if err := p.checkAgainstSpec(spec); err != nil {
+		return err
+	}
+	if !bytes.Equal(p.Key, key) || !bytes.Equal(p.Value, value) {
+		return ErrInvalidProof
+	}
+	calculated, err := p.Calculate()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(calculated, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// Verify verifies that the NonExistenceProof conforms to the given ProofSpec,
+// and that it proves that the given key is not present in the merkle tree of
+// the given root; i.e. that its existence proofs lead to the root, and that
+// they are of the neighbours of the key.
+func (p *NonExistenceProof) Verify(spec *ProofSpec, root, key []byte) error {
+	if !bytes.Equal(p.Key, key) || (p.Left == nil && p.Right == nil) {
+		return ErrInvalidProof
+	}
+	if p.Left != nil {
+		if err := p.Left.Verify(spec, root, p.Left.Key, p.Left.Value); err != nil {
+			return err
+		}
+		if bytes.Compare(key, p.Left.Key) <= 0 {
+			return ErrInvalidProof
+		}
+	}
+	if p.Right != nil {
+		if err := p.Right.Verify(spec, root, p.Right.Key, p.Right.Value); err != nil {
+			return err
+		}
+		if bytes.Compare(key, p.Right.Key) >= 0 {
+			return ErrInvalidProof
+		}
+	}
+	var ok bool
+	switch {
+	case p.Left == nil:
+		ok = isLeftMost(spec.InnerSpec, p.Right.Path)
+	case p.Right == nil:
+		ok = isRightMost(spec.InnerSpec, p.Left.Path)
+	default:
+		ok = isLeftNeighbor(spec.InnerSpec, p.Left.Path, p.Right.Path)
+	}
+	if !ok {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// Apply returns the digest of the leaf of the given key and value.
+func (op *LeafOp) Apply(key, value []byte) ([]byte, error) {
+	if len(key) == 0 || len(value) == 0 {
+		return nil, ErrInvalidProof
+	}
+	pkey, err := prepareLeafData(op.PrehashKey, op.Length, key)
+	if err != nil {
+		return nil, err
+	}
+	pvalue, err := prepareLeafData(op.PrehashValue, op.Length, value)
+	if err != nil {
+		return nil, err
+	}
+	data := append(append(append([]byte{}, op.Prefix...), pkey...), pvalue...)
+	return doHash(op.Hash, data)
+}
+
+// Apply returns the digest of the inner node of the given child.
+func (op *InnerOp) Apply(child []byte) ([]byte, error) {
+	if len(child) == 0 {
+		return nil, ErrInvalidProof
+	}
+	data := append(append(append([]byte{}, op.Prefix...), child...), op.Suffix...)
+	return doHash(op.Hash, data)
+}
+
+func (p *ExistenceProof) checkAgainstSpec(spec *ProofSpec) error {
+	if spec == nil || spec.LeafSpec == nil || spec.InnerSpec == nil || spec.InnerSpec.ChildSize <= 0 || p.Leaf == nil {
+		return ErrInvalidProof
+	}
+	leaf, ls := p.Leaf, spec.LeafSpec
+	if leaf.Hash != ls.Hash || leaf.PrehashKey != ls.PrehashKey || leaf.PrehashValue != ls.PrehashValue || leaf.Length != ls.Length || !bytes.HasPrefix(leaf.Prefix, ls.Prefix) {
+		return ErrInvalidProof
+	}
+	if (spec.MinDepth > 0 && len(p.Path) < spec.MinDepth) || (spec.MaxDepth > 0 && len(p.Path) > spec.MaxDepth) {
+		return ErrInvalidProof
+	}
+	is := spec.InnerSpec
+	maxLeftChildBytes := (len(is.ChildOrder) - 1) * is.ChildSize
+	for _, inner := range p.Path {
+		// The prefixes of the inner nodes must be told apart from the one
+		// of the leaves, lest a leaf passes for an inner node.
+		if inner.Hash != is.Hash || bytes.HasPrefix(inner.Prefix, ls.Prefix) {
+			return ErrInvalidProof
+		}
+		if len(inner.Prefix) < is.MinPrefixLength || len(inner.Prefix) > is.MaxPrefixLength+maxLeftChildBytes || len(inner.Suffix)%is.ChildSize != 0 {
+			return ErrInvalidProof
+		}
+	}
+	return nil
+}
+
+// padding returns the bounds of the length of the prefix, and the length of
+// the suffix, of the InnerOp of a child at the given branch.
+func (is *InnerSpec) padding(branch int) (minPrefix, maxPrefix, suffix int) {
+	position := -1
+	for i, b := range is.ChildOrder {
+		if b == branch {
+			position = i
+		}
+	}
+	prefix := position * is.ChildSize
+	return prefix + is.MinPrefixLength, prefix + is.MaxPrefixLength, (len(is.ChildOrder) - 1 - position) * is.ChildSize
+}
+
+func hasPadding(op *InnerOp, minPrefix, maxPrefix, suffix int) bool {
+	return len(op.Prefix) >= minPrefix && len(op.Prefix) <= maxPrefix && len(op.Suffix) == suffix
+}
+
+// branch returns the branch that the child of the given InnerOp is at, or -1
+// if it matches none.
+func (is *InnerSpec) branch(op *InnerOp) int {
+	for branch := range is.ChildOrder {
+		if minPrefix, maxPrefix, suffix := is.padding(branch); hasPadding(op, minPrefix, maxPrefix, suffix) {
+			return branch
+		}
+	}
+	return -1
+}
+
+// isLeftMost reports whether the given path is the one of the leftmost leaf.
+func isLeftMost(is *InnerSpec, path []*InnerOp) bool {
+	for _, step := range path {
+		if is.branch(step) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isRightMost reports whether the given path is the one of the rightmost
+// leaf.
+func isRightMost(is *InnerSpec, path []*InnerOp) bool {
+	for _, step := range path {
+		if is.branch(step) != len(is.ChildOrder)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// isLeftNeighbor reports whether the given paths are the ones of adjacent
+// leaves; i.e. whether they share their topmost steps, diverge at adjacent
+// branches, and lead from there to the rightmost and leftmost leaves of the
+// respective subtrees.
+func isLeftNeighbor(is *InnerSpec, left, right []*InnerOp) bool {
+	for len(left) > 0 && len(right) > 0 {
+		l, r := left[len(left)-1], right[len(right)-1]
+		if !bytes.Equal(l.Prefix, r.Prefix) || !bytes.Equal(l.Suffix, r.Suffix) {
+			break
+		}
+		left, right = left[:len(left)-1], right[:len(right)-1]
+	}
+	if len(left) == 0 || len(right) == 0 {
+		return false
+	}
+	lb, rb := is.branch(left[len(left)-1]), is.branch(right[len(right)-1])
+	if lb < 0 || rb != lb+1 {
+		return false
+	}
+	return isRightMost(is, left[:len(left)-1]) && isLeftMost(is, right[:len(right)-1])
+}
+
+func prepareLeafData(hashOp HashOp, lengthOp LengthOp, data []byte) ([]byte, error) {
+	hashed, err := doHash(hashOp, data)
+	if err != nil {
+		return nil, err
+	}
+	return doLength(lengthOp, hashed)
+}
+
+// doHash applies the given HashOp on the given data.
+func doHash(op HashOp, data []byte) ([]byte, error) {
+	switch op {
+	case NoHash:
+		return data, nil
+	case Bitcoin:
+		sha, err := doHash(SHA256, data)
+		if err != nil {
+			return nil, err
+		}
+		return doHash(RIPEMD160, sha)
+	}
+	hash, ok := hashOps[op]
+	if !ok || !hash.Available() {
+		return nil, ErrUnsupported
+	}
+	return digest(hash, data), nil
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// doLength applies the given LengthOp on the given data.
+func doLength(op LengthOp, data []byte) ([]byte, error) {
+	var b []byte
+	switch op {
+	case NoPrefix:
+		return data, nil
+	case VarProto:
+		b = binary.AppendUvarint(nil, uint64(len(data)))
+	case Fixed32Big:
+		b = binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	case Fixed32Little:
+		b = binary.LittleEndian.AppendUint32(nil, uint32(len(data)))
+	case Fixed64Big:
+		b = binary.BigEndian.AppendUint64(nil, uint64(len(data)))
+	case Fixed64Little:
+		b = binary.LittleEndian.AppendUint64(nil, uint64(len(data)))
+	case Require32Bytes, Require64Bytes:
+		if (op == Require32Bytes && len(data) != 32) || (op == Require64Bytes && len(data) != 64) {
+			return nil, ErrInvalidProof
+		}
+		return data, nil
+	default:
+		return nil, ErrUnsupported
+	}
+	return append(b, data...), nil
+}
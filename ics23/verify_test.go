@@ -0,0 +1,90 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ics23
+
+import (
+	"fmt"
+	"testing"
+)
+
+func newTestTree(t *testing.T, n int) (*Tree, map[string][]byte) {
+	entries := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		entries[fmt.Sprintf("key%02d", 2*i+1)] = []byte(fmt.Sprintf("value%d", i))
+	}
+	tree, err := NewTree(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, entries
+}
+
+func TestVerifyMembership00(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		tree, entries := newTestTree(t, n)
+		for key, value := range entries {
+			proof, err := tree.Prove([]byte(key))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyMembership(TendermintSpec, tree.Root(), proof, []byte(key), value) {
+				t.Fatalf("proof of %q among %d entries failed", key, n)
+			}
+			if VerifyMembership(TendermintSpec, tree.Root(), proof, []byte(key), []byte("kk")) {
+				t.Fatalf("proof of %q verified with the wrong value", key)
+			}
+			if VerifyNonMembership(TendermintSpec, tree.Root(), proof, []byte(key)) {
+				t.Fatalf("existence proof of %q verified as a non-existence one", key)
+			}
+			if len(proof.Exist.Path) > 0 {
+				proof.Exist.Path[0].Prefix = []byte{0x00}
+				if VerifyMembership(TendermintSpec, tree.Root(), proof, []byte(key), value) {
+					t.Fatalf("proof of %q verified with a leaf prefix on an inner node", key)
+				}
+			}
+		}
+	}
+}
+
+func TestVerifyNonMembership00(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		tree, _ := newTestTree(t, n)
+		// Absent keys fall before, between and after the present ones.
+		for i := 0; i <= n; i++ {
+			key := []byte(fmt.Sprintf("key%02d", 2*i))
+			proof, err := tree.Prove(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proof.Nonexist == nil {
+				t.Fatalf("want a non-existence proof of %q", key)
+			}
+			if !VerifyNonMembership(TendermintSpec, tree.Root(), proof, key) {
+				t.Fatalf("non-existence proof of %q among %d entries failed", key, n)
+			}
+			// A proof that skips an entry must fail.
+			if i > 0 && i < n {
+				wider, err := tree.Prove([]byte(fmt.Sprintf("key%02d", 2*i+2)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				proof.Nonexist.Right = wider.Nonexist.Right
+				if VerifyNonMembership(TendermintSpec, tree.Root(), proof, key) {
+					t.Fatalf("non-existence proof of %q with non-adjacent neighbours verified", key)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// MaxLeaves is the most leaves that a merkle tree can hold at a time,
+	// i.e. the most elements that a slice can hold on the platform at hand.
+	MaxLeaves = math.MaxInt
+	// MaxOrderedID is the greatest ordered ID that a leaf can be assigned,
+	// on every platform.
+	MaxOrderedID = math.MaxUint64 - 1
+)
+
+// NextID returns the ordered ID that the next leaf appended to the merkle
+// tree will be assigned.
+//
+// Ordered IDs are assigned in increasing order, starting from 0 in the order
+// that the data were initially given, and are never reassigned; i.e. the
+// leaves retain theirs across deletions of other leaves, and the ordered IDs
+// of deleted leaves are not reused.
+func (t *Tree) NextID() uint64 {
+	return t.nextID
+}
+
+// IDs returns the ordered IDs in use by the leaves of the merkle tree, in
+// ascending order.
+func (t *Tree) IDs() []uint64 {
+	ids := make([]uint64, len(t.tls))
+	for i := range t.tls {
+		ids[i] = t.tls[i].orderedID
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// FreeIDs returns the ordered IDs below NextID that are not in use by any
+// leaf of the merkle tree (i.e. the ones of deleted leaves), in ascending
+// order.
+func (t *Tree) FreeIDs() []uint64 {
+	var free []uint64
+	next := uint64(0)
+	for _, id := range t.IDs() {
+		for ; next < id; next++ {
+			free = append(free, next)
+		}
+		next = id + 1
+	}
+	for ; next < t.nextID; next++ {
+		free = append(free, next)
+	}
+	return free
+}
+
+// checkCapacity returns ErrTooManyLeaves if a merkle tree of the given number
+// of leaves, whose NextID is the given one, cannot hold n more of them.
+func checkCapacity(numLeaves, n int, nextID uint64) error {
+	if n > MaxLeaves-numLeaves || uint64(n) > MaxOrderedID+1-nextID {
+		return ErrTooManyLeaves
+	}
+	return nil
+}
+
+// validIDs reports whether the ordered IDs of the given leaves (e.g. upon
+// decoding) do not exceed MaxOrderedID.
+func validIDs(tls []treeLeaf) bool {
+	for i := range tls {
+		if tls[i].orderedID > MaxOrderedID {
+			return false
+		}
+	}
+	return true
+}
+
+// impliedNextID returns the ordered ID that follows the greatest one in use
+// by the given leaves; i.e. the NextID of a merkle tree of them, unless the
+// leaf of the greatest ordered ID assigned has been deleted.
+func impliedNextID(tls []treeLeaf) uint64 {
+	var next uint64
+	for i := range tls {
+		if tls[i].orderedID >= next {
+			next = tls[i].orderedID + 1
+		}
+	}
+	return next
+}
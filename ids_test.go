@@ -0,0 +1,176 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNextID00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.NextID() != 5 || len(tree.FreeIDs()) != 0 {
+		t.Fatalf("want (5, []); got (%d, %v)", tree.NextID(), tree.FreeIDs())
+	}
+
+	// Deleting leaves neither renumbers the rest nor frees their IDs for
+	// reuse.
+	ids := make(map[string]uint64)
+	for i := range tree.tls {
+		ids[string(tree.tls[i].datum)] = tree.tls[i].orderedID
+	}
+	tree.DeleteAndReconstruct(grAlphabet[1], grAlphabet[4])
+	tree.AppendAndReconstruct(grAlphabet[5])
+	for i := range tree.tls {
+		if id, ok := ids[string(tree.tls[i].datum)]; ok && id != tree.tls[i].orderedID {
+			t.Fatalf("want ordered ID (%d); got %d", id, tree.tls[i].orderedID)
+		}
+	}
+	t.Logf("IDs: %v, FreeIDs: %v", tree.IDs(), tree.FreeIDs())
+	if want := []uint64{0, 2, 3, 5}; !reflect.DeepEqual(tree.IDs(), want) {
+		t.Fatalf("want (%v); got %v", want, tree.IDs())
+	}
+	if want := []uint64{1, 4}; !reflect.DeepEqual(tree.FreeIDs(), want) {
+		t.Fatalf("want (%v); got %v", want, tree.FreeIDs())
+	}
+	if leaf, err := tree.LeafByID(5); err != nil || !bytes.Equal(leaf, grAlphabet[5].Serialize()) {
+		t.Fatalf("want (%s, <nil>); got (%s, %v)", grAlphabet[5].Serialize(), leaf, err)
+	}
+
+	tree.DeleteAndReconstruct(grAlphabet[5])
+	if tree.NextID() != 6 {
+		t.Fatalf("want (6); got %d", tree.NextID())
+	}
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree2 Tree
+	if err := tree2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = tree.MarshalCBOR(); err != nil {
+		t.Fatal(err)
+	}
+	var tree3 Tree
+	if err := tree3.UnmarshalCBOR(b); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = json.Marshal(tree); err != nil {
+		t.Fatal(err)
+	}
+	var tree4 Tree
+	if err := json.Unmarshal(b, &tree4); err != nil {
+		t.Fatal(err)
+	}
+	for i, tr := range []*Tree{&tree2, &tree3, &tree4} {
+		if tr.NextID() != 6 || !reflect.DeepEqual(tr.FreeIDs(), []uint64{1, 4, 5}) {
+			t.Fatalf("decoded tree %d: want (6, [1 4 5]); got (%d, %v)", i, tr.NextID(), tr.FreeIDs())
+		}
+	}
+}
+
+func TestMaxOrderedID00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A merkle tree that runs out of ordered IDs fails to grow, rather
+	// than wrap around.
+	tree.nextID = MaxOrderedID
+	ids, _, err := tree.Append(grAlphabet[5])
+	if err != nil || !reflect.DeepEqual(ids, []uint64{MaxOrderedID}) {
+		t.Fatalf("want ([%d], <nil>); got (%v, %v)", uint64(MaxOrderedID), ids, err)
+	}
+	root := copyBytes(tree.MerkleRoot())
+	if _, _, err := tree.Append(grAlphabet[6]); !errors.Is(err, ErrTooManyLeaves) {
+		t.Fatalf("want (%v); got %v", ErrTooManyLeaves, err)
+	}
+	if tree.AppendAndReconstruct(grAlphabet[6]); !errors.Is(tree.DatumErr(), ErrTooManyLeaves) || !bytes.Equal(tree.MerkleRoot(), root) {
+		t.Fatalf("want (%v); got %v", ErrTooManyLeaves, tree.DatumErr())
+	}
+	if _, err := tree.Appended(grAlphabet[6]); !errors.Is(err, ErrTooManyLeaves) {
+		t.Fatalf("want (%v); got %v", ErrTooManyLeaves, err)
+	}
+	b := tree.Begin()
+	if _, err := b.Append(grAlphabet[6]); !errors.Is(err, ErrTooManyLeaves) {
+		t.Fatalf("want (%v); got %v", ErrTooManyLeaves, err)
+	}
+
+	// It still round-trips through its encodings.
+	enc, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree2 Tree
+	if err := tree2.UnmarshalBinary(enc); err != nil || tree2.NextID() != MaxOrderedID+1 {
+		t.Fatalf("want (%d, <nil>); got (%d, %v)", uint64(MaxOrderedID+1), tree2.NextID(), err)
+	}
+
+	// Ordered IDs beyond MaxOrderedID are not.
+	tree.tls[0].orderedID = math.MaxUint64
+	if enc, err = tree.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree2.UnmarshalBinary(enc); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+	if enc, err = tree.MarshalCBOR(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tree2.UnmarshalCBOR(enc); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+	if enc, err = json.Marshal(tree); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(enc, &tree2); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+}
+
+func TestCheckCapacity00(t *testing.T) {
+	for _, c := range []struct {
+		numLeaves, n int
+		nextID       uint64
+		want         error
+	}{
+		{0, 10, 0, nil},
+		{MaxLeaves - 10, 10, 0, nil},
+		{MaxLeaves - 10, 11, 0, ErrTooManyLeaves},
+		{5, 1, MaxOrderedID, nil},
+		{5, 2, MaxOrderedID, ErrTooManyLeaves},
+		{5, 1, MaxOrderedID + 1, ErrTooManyLeaves},
+	} {
+		if err := checkCapacity(c.numLeaves, c.n, c.nextID); err != c.want {
+			t.Fatalf("checkCapacity(%d, %d, %d): want (%v); got %v", c.numLeaves, c.n, c.nextID, c.want, err)
+		}
+	}
+
+	// Counts that do not fit in an int are not decoded at all.
+	d := binaryDecoder{buf: binary.AppendUvarint(nil, math.MaxUint64)}
+	if n := d.int(); n != 0 || !d.err {
+		t.Fatalf("want (0, true); got (%d, %v)", n, d.err)
+	}
+}
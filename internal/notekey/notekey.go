@@ -0,0 +1,61 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package notekey holds the encoding of the named keys of signed notes (see
+// the note format of golang.org/x/mod/sumdb/note), which the checkpoint and
+// witness packages have in common.
+package notekey
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+)
+
+// Hash returns the hash of the given named key, i.e. the first four bytes of
+// SHA-256(name || "\n" || key).
+func Hash(name string, key []byte) uint32 {
+	h := sha256.New()
+	h.Write([]byte(name))
+	h.Write([]byte("\n"))
+	h.Write(key)
+	return binary.BigEndian.Uint32(h.Sum(nil))
+}
+
+// EncodeHash returns the hex encoding of the given key hash, as it appears in
+// encoded keys.
+func EncodeHash(hash uint32) string {
+	return hex.EncodeToString(binary.BigEndian.AppendUint32(nil, hash))
+}
+
+// Parse splits the given encoded key, i.e. "<name>+<hash>+<key>", where the
+// hash is hex-encoded and the key base64-encoded, into its fields. It reports
+// whether the key is well-formed; the hash is not checked against the key.
+func Parse(s string) (name string, hash uint32, key []byte, ok bool) {
+	fields := strings.SplitN(s, "+", 3)
+	if len(fields) != 3 || fields[0] == "" || strings.ContainsAny(fields[0], " \n") || len(fields[1]) != 8 {
+		return "", 0, nil, false
+	}
+	h, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return "", 0, nil, false
+	}
+	key, err = base64.StdEncoding.DecodeString(fields[2])
+	if err != nil {
+		return "", 0, nil, false
+	}
+	return fields[0], binary.BigEndian.Uint32(h), key, true
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package pbwire holds the helpers that the hand-written protobuf codecs of
+// the interoperability packages (e.g. ics23 and trillian) have in common, on
+// top of the protowire package, so that none of them needs generated code or
+// the reflection-based protobuf runtime.
+package pbwire
+
+import "google.golang.org/protobuf/encoding/protowire"
+
+// AppendVarint appends a varint field, omitting it if it holds the default
+// (zero) value, as proto3 mandates.
+func AppendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// AppendBytes appends a singular bytes field, omitting it if it is empty, as
+// proto3 mandates.
+func AppendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	return AppendMessage(b, num, v)
+}
+
+// AppendMessage appends a length-delimited field (e.g. an embedded message,
+// or an element of a repeated one), which is always present.
+func AppendMessage(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// DecodeFields walks the fields of the given protobuf wire encoding, calling
+// fn for each varint or length-delimited one, and returning the first error
+// that fn returns; the values of the latter are copied (so that the decoded
+// message does not alias b) and never nil. Any other fields are skipped.
+//
+// It returns malformed if b is not a valid protobuf wire encoding.
+func DecodeFields(b []byte, malformed error, fn func(num protowire.Number, v uint64, bs []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return malformed
+		}
+		b = b[n:]
+		var err error
+		switch typ {
+		case protowire.VarintType:
+			var v uint64
+			if v, n = protowire.ConsumeVarint(b); n >= 0 {
+				err = fn(num, v, nil)
+			}
+		case protowire.BytesType:
+			var bs []byte
+			if bs, n = protowire.ConsumeBytes(b); n >= 0 {
+				err = fn(num, 0, append([]byte{}, bs...))
+			}
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return malformed
+		}
+		if err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
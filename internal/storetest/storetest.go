@@ -0,0 +1,143 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package storetest holds what the tests of the persistent NodeStores (see
+// the store packages) and of the packages built on top of merkle trees have
+// in common.
+package storetest
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+// Store is a merkle.NodeStore that persists the leaves of a merkle tree along
+// with its merkle nodes, so that the tree can be reopened out of it.
+type Store interface {
+	merkle.NodeStore
+	SaveLeaves(t *merkle.Tree) error
+	OpenTree(hash crypto.Hash, opts ...merkle.Option) (*merkle.Tree, error)
+}
+
+// Data returns n distinct Datum values, in ascending order.
+func Data(n int) []merkle.Datum {
+	ret := make([]merkle.Datum, n)
+	for i := range ret {
+		ret[i] = merkle.ByteDatum(fmt.Sprintf("datum-%04d", i))
+	}
+	return ret
+}
+
+// RoundTrip builds a SHA-256 merkle tree of 100 leaves on top of the given
+// Store, appends 50 more to it and deletes the first 20, and saves its leaves.
+// It then reopens the tree out of the Store that reopen returns (e.g. after
+// committing or closing the given one), as if after a restart, and checks
+// that its merkle root, leaves, ordered IDs and proofs are the ones of the tree
+// that it should be, which it returns.
+func RoundTrip(t *testing.T, s Store, reopen func() Store) *merkle.Tree {
+	t.Helper()
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, Data(100), merkle.WithNodeStore(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(Data(150)[100:]...)
+	tree.DeleteAndReconstruct(Data(150)[:20]...)
+	if err := tree.StoreErr(); err != nil {
+		t.Fatal(err)
+	}
+	want, err := merkle.NewTree(crypto.SHA256, Data(150)[20:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+	if !bytes.Equal(want.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	if err := s.SaveLeaves(tree); err != nil {
+		t.Fatal(err)
+	}
+	ids := tree.IDs()
+
+	tree, err = reopen().OpenTree(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	if got := tree.IDs(); !slices.Equal(ids, got) {
+		t.Fatalf("want ordered IDs %v; got %v", ids, got)
+	}
+	for i, id := range ids {
+		if v, err := tree.VerifyOrderedID(id); err != nil || !v {
+			t.Fatalf("ERROR while verifying leaf %d: (%v, %v)", i, v, err)
+		}
+		p, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(want.MerkleRoot()) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+	return want
+}
+
+// StableIDs builds a SHA-256 merkle tree of 50 leaves, kept in insertion
+// order, on top of the given Store, deletes some of them and saves its leaves.
+// It then reopens the tree out of the Store that reopen returns, and checks
+// that its leaves retain their ordered IDs, and that its NextID is the one of
+// the tree before it was reopened.
+func StableIDs(t *testing.T, s Store, reopen func() Store) {
+	t.Helper()
+	data := Data(50)
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.InsertionOrder(), merkle.WithNodeStore(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.DeleteAndReconstruct(data[0], data[7], data[len(data)-1])
+	if err := tree.StoreErr(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveLeaves(tree); err != nil {
+		t.Fatal(err)
+	}
+	// The Store may be closed by reopen, hence the reads from the tree.
+	root, ids, nextID := tree.MerkleRoot(), tree.IDs(), tree.NextID()
+
+	otree, err := reopen().OpenTree(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root, otree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", root, otree.MerkleRoot())
+	}
+	if otree.NextID() != nextID {
+		t.Fatalf("want NextID (%d); got %d", nextID, otree.NextID())
+	}
+	for i, id := range ids {
+		if v, err := otree.VerifyOrderedID(id); err != nil || !v {
+			t.Fatalf("ERROR while verifying ordered ID %d: (%v, %v)", id, v, err)
+		}
+		want, _ := tree.LeafDigest(i)
+		if got, _ := otree.LeafDigest(i); !bytes.Equal(got, want) {
+			t.Fatalf("want leaf %d (%x); got %x", i, want, got)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package ipld exports merkle trees as IPLD merkle-DAGs of dag-pb (UnixFS)
+// blocks, which can be stored in a Blockstore or written to a CARv1 file,
+// bridging them to the IPFS ecosystem.
+//
+// The DAG mirrors the merkle tree: each leaf becomes a raw block of its
+// serialized datum (or of its chunk, for a FileTree), and each merkle node a
+// UnixFS file node that links to the blocks of its children, from left to
+// right; the last node of an odd-sized level is linked to directly by its
+// grandparent, rather than through a node of a single link. The DAG hence
+// represents the concatenation of the leaves' data as a single UnixFS file,
+// and its blocks are addressed by CIDv1 values of the hash function of the
+// merkle tree (see package cid).
+package ipld
+
+import (
+	"bufio"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/cid"
+	"github.com/ckatsak/merkle/internal/pbwire"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	// ErrDigestOnly signifies that the merkle tree retains no data to
+	// export, being in digest-only mode.
+	ErrDigestOnly = errors.New("ipld: merkle tree in digest-only mode")
+
+	// ErrMismatch signifies that the data read do not match the FileTree
+	// that they are exported along with.
+	ErrMismatch = errors.New("ipld: data do not match the file tree")
+)
+
+// Blockstore is the interface that block stores (e.g. the ones of IPFS nodes)
+// have to implement so as to receive the blocks of an exported DAG.
+type Blockstore interface {
+	// Put stores the given block under the given CID. It may be invoked
+	// more than once for the same block.
+	Put(c cid.CID, data []byte) error
+}
+
+// Block is a block of an IPLD DAG, along with its CID.
+type Block struct {
+	CID  cid.CID
+	Data []byte
+}
+
+// MemBlockstore is an in-memory Blockstore, which retains each distinct block
+// once, in the order they were first put in it.
+type MemBlockstore struct {
+	blocks []Block
+	index  map[string]int
+}
+
+// NewMemBlockstore returns a new, empty MemBlockstore.
+func NewMemBlockstore() *MemBlockstore {
+	return &MemBlockstore{index: make(map[string]int)}
+}
+
+// Put implements the Blockstore interface.
+func (bs *MemBlockstore) Put(c cid.CID, data []byte) error {
+	key, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if _, ok := bs.index[string(key)]; !ok {
+		bs.index[string(key)] = len(bs.blocks)
+		bs.blocks = append(bs.blocks, Block{CID: c, Data: data})
+	}
+	return nil
+}
+
+// Get returns the block of the given CID, and reports whether it is present.
+func (bs *MemBlockstore) Get(c cid.CID) ([]byte, bool) {
+	key, err := c.MarshalBinary()
+	if err != nil {
+		return nil, false
+	}
+	i, ok := bs.index[string(key)]
+	if !ok {
+		return nil, false
+	}
+	return bs.blocks[i].Data, true
+}
+
+// Blocks returns the blocks of the MemBlockstore, in the order they were first
+// put in it.
+func (bs *MemBlockstore) Blocks() []Block {
+	return append([]Block(nil), bs.blocks...)
+}
+
+// ExportTree exports the given merkle tree as a DAG into the given
+// Blockstore, and returns the CID of its root.
+//
+// It returns a non-nil error if the merkle tree is in digest-only mode, if
+// its hash function has no multihash code or has not been linked into the
+// binary, or if storing any block fails.
+func ExportTree(t *merkle.Tree, bs Blockstore) (cid.CID, error) {
+	d, err := newDAG(t.Hash(), t.Arity(), bs)
+	if err != nil {
+		return cid.CID{}, err
+	}
+	for it := t.LeafIter(); it.Next(); {
+		datum := it.Datum()
+		if datum == nil {
+			return cid.CID{}, ErrDigestOnly
+		}
+		if err := d.addLeaf(datum); err != nil {
+			return cid.CID{}, err
+		}
+	}
+	return d.root()
+}
+
+// ExportFile exports the given FileTree as a DAG into the given Blockstore,
+// reading the chunks of the file it was built from from r, and returns the
+// CID of its root. Each chunk is verified against the FileTree before it is
+// stored.
+//
+// It returns ErrMismatch if any chunk read fails verification, or if r ends
+// before or after the chunks of the FileTree, and a non-nil error if reading
+// from r fails, if the hash function of the FileTree has no multihash code,
+// or if storing any block fails.
+func ExportFile(ft *merkle.FileTree, r io.Reader, bs Blockstore) (cid.CID, error) {
+	p, err := ft.ChunkProof(0)
+	if err != nil {
+		return cid.CID{}, err
+	}
+	d, err := newDAG(p.Hash, 2, bs)
+	if err != nil {
+		return cid.CID{}, err
+	}
+	br := bufio.NewReader(r)
+	for i := 0; i < ft.NumChunks(); i++ {
+		chunk := make([]byte, ft.ChunkSize())
+		n, err := io.ReadFull(br, chunk)
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			err = nil
+		} else if err != nil {
+			return cid.CID{}, err
+		}
+		if ok, err := ft.VerifyChunk(i, chunk[:n]); err != nil {
+			return cid.CID{}, err
+		} else if !ok {
+			return cid.CID{}, ErrMismatch
+		}
+		if err := d.addLeaf(chunk[:n]); err != nil {
+			return cid.CID{}, err
+		}
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		if err == nil {
+			err = ErrMismatch
+		}
+		return cid.CID{}, err
+	}
+	return d.root()
+}
+
+// dag builds a DAG bottom-up, storing its blocks as they are constructed.
+type dag struct {
+	hash  crypto.Hash
+	arity int
+	bs    Blockstore
+	// leaves are the links to the raw blocks of the leaves.
+	leaves []link
+}
+
+// link is a link to a block; size is the size of the data of the file that
+// it represents, and tsize the total size of the blocks under it.
+type link struct {
+	cid         cid.CID
+	size, tsize uint64
+}
+
+func newDAG(hash crypto.Hash, arity int, bs Blockstore) (*dag, error) {
+	if _, ok := cid.MultihashCode(hash); !ok {
+		return nil, cid.ErrUnsupportedHash
+	}
+	if !hash.Available() {
+		return nil, &merkle.HashError{Hash: hash}
+	}
+	return &dag{hash: hash, arity: arity, bs: bs}, nil
+}
+
+// put stores the given block, returning its CID.
+func (d *dag) put(codec uint64, data []byte) (cid.CID, error) {
+	h := d.hash.New()
+	h.Write(data)
+	c := cid.CID{Codec: codec, Hash: d.hash, Digest: h.Sum(nil)}
+	return c, d.bs.Put(c, data)
+}
+
+func (d *dag) addLeaf(datum []byte) error {
+	c, err := d.put(cid.Raw, datum)
+	if err != nil {
+		return err
+	}
+	d.leaves = append(d.leaves, link{cid: c, size: uint64(len(datum)), tsize: uint64(len(datum))})
+	return nil
+}
+
+// root constructs the nodes of the DAG above its leaves, level by level, and
+// returns the CID of its root.
+func (d *dag) root() (cid.CID, error) {
+	level := d.leaves
+	for len(level) > 1 {
+		parents := make([]link, 0, (len(level)+d.arity-1)/d.arity)
+		for i := 0; i < len(level); i += d.arity {
+			end := i + d.arity
+			if end > len(level) {
+				end = len(level)
+			}
+			if end-i == 1 {
+				parents = append(parents, level[i])
+				continue
+			}
+			parent, err := d.addNode(level[i:end])
+			if err != nil {
+				return cid.CID{}, err
+			}
+			parents = append(parents, parent)
+		}
+		level = parents
+	}
+	return level[0].cid, nil
+}
+
+// addNode stores the UnixFS file node that links to the given children.
+func (d *dag) addNode(children []link) (link, error) {
+	var pb []byte
+	l := link{}
+	for _, child := range children {
+		c, err := child.cid.MarshalBinary()
+		if err != nil {
+			return link{}, err
+		}
+		var pbLink []byte
+		pbLink = pbwire.AppendMessage(pbLink, 1, c)
+		pbLink = appendVarintField(pbLink, 3, child.tsize)
+		pb = pbwire.AppendMessage(pb, 2, pbLink)
+		l.size += child.size
+		l.tsize += child.tsize
+	}
+	// UnixFS Data: Type (File), filesize and blocksizes.
+	unixfs := appendVarintField(nil, 1, unixfsFile)
+	unixfs = appendVarintField(unixfs, 3, l.size)
+	for _, child := range children {
+		unixfs = appendVarintField(unixfs, 4, child.size)
+	}
+	pb = pbwire.AppendMessage(pb, 1, unixfs)
+
+	c, err := d.put(cid.DagPB, pb)
+	if err != nil {
+		return link{}, err
+	}
+	l.cid, l.tsize = c, l.tsize+uint64(len(pb))
+	return l, nil
+}
+
+// unixfsFile is the UnixFS DataType of files.
+const unixfsFile = 2
+
+// appendVarintField appends the protobuf field of the given number and
+// (varint) value to b, even if the latter is zero.
+func appendVarintField(b []byte, field protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, field, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// WriteCAR writes a CARv1 file of the given root and blocks to w; e.g. the
+// ones of a MemBlockstore that a DAG was exported into.
+//
+// It returns a non-nil error if the CID of the root or of any block cannot
+// be encoded, or if writing to w fails.
+func WriteCAR(w io.Writer, root cid.CID, blocks []Block) error {
+	c, err := root.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	// The header is the DAG-CBOR map {"roots": [root], "version": 1}, the
+	// root being tagged (42) and prefixed with the identity multibase.
+	header := append([]byte{0xa2, 0x65}, "roots"...)
+	header = append(header, 0x81, 0xd8, 0x2a)
+	header = appendCBORBytesHead(header, uint64(len(c)+1))
+	header = append(append(header, 0x00), c...)
+	header = append(append(header, 0x67), "version"...)
+	header = append(header, 0x01)
+
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(binary.AppendUvarint(nil, uint64(len(header)))); err != nil {
+		return err
+	}
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	for _, block := range blocks {
+		c, err := block.CID.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(binary.AppendUvarint(nil, uint64(len(c)+len(block.Data)))); err != nil {
+			return err
+		}
+		if _, err := bw.Write(c); err != nil {
+			return err
+		}
+		if _, err := bw.Write(block.Data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// appendCBORBytesHead appends the head of a CBOR byte string of the given
+// length to b.
+func appendCBORBytesHead(b []byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return append(b, 0x40|byte(n))
+	case n <= 0xff:
+		return append(b, 0x58, byte(n))
+	default:
+		return binary.BigEndian.AppendUint16(append(b, 0x59), uint16(n))
+	}
+}
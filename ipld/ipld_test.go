@@ -0,0 +1,166 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package ipld
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/cid"
+)
+
+var words = []merkle.Datum{
+	merkle.StringDatum("alpha"), merkle.StringDatum("beta"), merkle.StringDatum("gamma"),
+	merkle.StringDatum("delta"), merkle.StringDatum("epsilon"),
+}
+
+// cat returns the data of the file that the DAG of the given root in the
+// given MemBlockstore represents, parsing the dag-pb nodes along the way.
+func cat(t *testing.T, bs *MemBlockstore, root cid.CID) []byte {
+	data, ok := bs.Get(root)
+	if !ok {
+		t.Fatalf("want (block %s); got none", root)
+	}
+	digest := sha256.Sum256(data)
+	if !bytes.Equal(root.Digest, digest[:]) {
+		t.Fatalf("want (%x); got %x", digest, root.Digest)
+	}
+	if root.Codec == cid.Raw {
+		return data
+	}
+	var ret []byte
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		size, m := binary.Uvarint(data[n:])
+		field := data[n+m : n+m+int(size)]
+		data = data[n+m+int(size):]
+		if key != 2<<3|2 {
+			continue
+		}
+		// The first field of each link is its CID.
+		_, n = binary.Uvarint(field)
+		size, m = binary.Uvarint(field[n:])
+		var child cid.CID
+		if err := child.UnmarshalBinary(field[n+m : n+m+int(size)]); err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, cat(t, bs, child)...)
+	}
+	return ret
+}
+
+func TestExportTree00(t *testing.T) {
+	for _, opts := range [][]merkle.Option{nil, {merkle.WithArity(3)}, {merkle.InsertionOrder()}} {
+		tree, err := merkle.NewTreeWithOptions(crypto.SHA256, words, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bs := NewMemBlockstore()
+		root, err := ExportTree(tree, bs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []byte
+		for it := tree.LeafIter(); it.Next(); {
+			want = append(want, it.Datum()...)
+		}
+		if got := cat(t, bs, root); !bytes.Equal(got, want) {
+			t.Fatalf("want (%s); got %s", want, got)
+		}
+		t.Logf("exported %d blocks under %s", len(bs.Blocks()), root)
+	}
+
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, words, merkle.DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExportTree(tree, NewMemBlockstore()); err != ErrDigestOnly {
+		t.Fatalf("want (%v); got %v", ErrDigestOnly, err)
+	}
+}
+
+func TestExportFile00(t *testing.T) {
+	file := strings.Repeat("0123456789", 100)
+	ft, err := merkle.NewFileTree(crypto.SHA256, strings.NewReader(file), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := NewMemBlockstore()
+	root, err := ExportFile(ft, strings.NewReader(file), bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cat(t, bs, root); string(got) != file {
+		t.Fatalf("want (%s); got %s", file, got)
+	}
+	// The raw blocks of the chunks are addressed by the leaves' digests.
+	leaf, err := ft.ChunkProof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bs.Get(cid.CID{Codec: cid.Raw, Hash: crypto.SHA256, Digest: leaf.LeafDigest}); !ok {
+		t.Fatalf("want (the block of chunk 0); got none")
+	}
+
+	for _, other := range []string{file[:len(file)-1], file + "!", "1" + file[1:]} {
+		if _, err := ExportFile(ft, strings.NewReader(other), NewMemBlockstore()); err != ErrMismatch {
+			t.Fatalf("want (%v); got %v", ErrMismatch, err)
+		}
+	}
+}
+
+func TestWriteCAR00(t *testing.T) {
+	tree, err := merkle.NewTree(crypto.SHA256, words...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := NewMemBlockstore()
+	root, err := ExportTree(tree, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteCAR(&buf, root, bs.Blocks()); err != nil {
+		t.Fatal(err)
+	}
+	car := buf.Bytes()
+
+	rootBytes, _ := root.MarshalBinary()
+	want := append([]byte{0xa2, 0x65}, "roots"...)
+	want = append(want, 0x81, 0xd8, 0x2a, 0x58, byte(len(rootBytes)+1), 0x00)
+	want = append(append(want, rootBytes...), 0x67)
+	want = append(append(want, "version"...), 0x01)
+	size, n := binary.Uvarint(car)
+	if header := car[n : n+int(size)]; !bytes.Equal(header, want) {
+		t.Fatalf("want (%x); got %x", want, header)
+	}
+	car = car[n+int(size):]
+	for _, block := range bs.Blocks() {
+		c, _ := block.CID.MarshalBinary()
+		size, n := binary.Uvarint(car)
+		if int(size) != len(c)+len(block.Data) || !bytes.Equal(car[n:n+len(c)], c) || !bytes.Equal(car[n+len(c):n+int(size)], block.Data) {
+			t.Fatalf("want (block %s); got %x", block.CID, car[:n+int(size)])
+		}
+		car = car[n+int(size):]
+	}
+	if len(car) != 0 {
+		t.Fatalf("want (0) trailing bytes; got %d", len(car))
+	}
+}
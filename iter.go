@@ -0,0 +1,104 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+// LeafIterator walks the leaves of a merkle tree lazily, in the order they
+// are stored in the tree (i.e. sorted, unless the tree keeps its leaves in
+// insertion order), without copying them.
+//
+// The slices it returns alias the memory of the merkle tree; they must not be
+// modified, and they are only valid until the tree is modified.
+type LeafIterator struct {
+	t *Tree
+	i int
+}
+
+// LeafIter returns a LeafIterator positioned before the first leaf of the
+// merkle tree.
+func (t *Tree) LeafIter() *LeafIterator {
+	return &LeafIterator{t: t, i: -1}
+}
+
+// Next advances the LeafIterator to the next leaf, and reports whether there
+// was one.
+func (it *LeafIterator) Next() bool {
+	if it.i < len(it.t.tls) {
+		it.i++
+	}
+	return it.i < len(it.t.tls)
+}
+
+// Index returns the index of the current leaf among the leaves of the tree.
+func (it *LeafIterator) Index() int {
+	return it.i
+}
+
+// OrderedID returns the ordered ID of the current leaf.
+func (it *LeafIterator) OrderedID() uint64 {
+	return it.t.tls[it.i].orderedID
+}
+
+// Digest returns the digest of the current leaf.
+func (it *LeafIterator) Digest() []byte {
+	return it.t.tls[it.i].digest
+}
+
+// Datum returns the serialized datum of the current leaf, or nil if the
+// merkle tree is in digest-only mode.
+func (it *LeafIterator) Datum() []byte {
+	return it.t.leafDatum(&it.t.tls[it.i])
+}
+
+// NodeIterator walks the digests of the nodes of a single level of a merkle
+// tree lazily, from left to right, without copying them.
+//
+// The slices it returns alias the memory of the merkle tree; they must not be
+// modified, and they are only valid until the tree is modified.
+type NodeIterator struct {
+	t         *Tree
+	height, i int
+	width     int
+}
+
+// NodeIter returns a NodeIterator positioned before the first node at the
+// given height of the merkle tree, where height 0 holds the leaves and
+// height Height()-1 holds the merkle root. For any other height, the
+// NodeIterator yields no nodes.
+func (t *Tree) NodeIter(height int) *NodeIterator {
+	it := &NodeIterator{t: t, height: height, i: -1}
+	if height >= 0 && height <= len(t.rows) {
+		it.width = t.levelWidth(height)
+	}
+	return it
+}
+
+// Next advances the NodeIterator to the next node, and reports whether there
+// was one.
+func (it *NodeIterator) Next() bool {
+	if it.i < it.width {
+		it.i++
+	}
+	return it.i < it.width
+}
+
+// Index returns the index of the current node among the nodes of its level.
+func (it *NodeIterator) Index() int {
+	return it.i
+}
+
+// Digest returns the digest of the current node.
+func (it *NodeIterator) Digest() []byte {
+	return it.t.nodeAt(it.height, it.i)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func TestLeafIter00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for it := tree.LeafIter(); it.Next(); n++ {
+		if it.Index() != n {
+			t.Fatalf("want (%d); got %d", n, it.Index())
+		}
+		if !bytes.Equal(it.Digest(), tree.tls[n].digest) || !bytes.Equal(it.Datum(), tree.tls[n].datum) || it.OrderedID() != tree.tls[n].orderedID {
+			t.Fatalf("leaf %d does not match", n)
+		}
+	}
+	if n != tree.NumLeaves() {
+		t.Fatalf("want (%d) leaves; got %d", tree.NumLeaves(), n)
+	}
+
+	dtree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for it := dtree.LeafIter(); it.Next(); {
+		if it.Datum() != nil {
+			t.Fatalf("want no datum; got %q", it.Datum())
+		}
+	}
+}
+
+func TestNodeIter00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for height := 0; height < tree.Height(); height++ {
+		n := 0
+		for it := tree.NodeIter(height); it.Next(); n++ {
+			if !bytes.Equal(it.Digest(), tree.nodeAt(height, it.Index())) {
+				t.Fatalf("node (%d, %d) does not match", height, n)
+			}
+		}
+		t.Logf("height %d: %d nodes", height, n)
+		total += n
+	}
+	if total != tree.Size() {
+		t.Fatalf("want (%d) nodes; got %d", tree.Size(), total)
+	}
+
+	it := tree.NodeIter(tree.Height() - 1)
+	if !it.Next() || !bytes.Equal(it.Digest(), tree.MerkleRoot()) || it.Next() {
+		t.Fatal("top level does not hold just the merkle root")
+	}
+	if tree.NodeIter(tree.Height()).Next() || tree.NodeIter(-1).Next() {
+		t.Fatal("want no nodes out of range")
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+)
+
+type (
+	// jsonTree is the JSON representation of a Tree. The merkle nodes are
+	// not included; they are reconstructed from the leaves upon decoding.
+	jsonTree struct {
+		Hash           string     `json:"hash"`
+		DigestOnly     bool       `json:"digestOnly,omitempty"`
+		InsertionOrder bool       `json:"insertionOrder,omitempty"`
+		RFC6962        bool       `json:"rfc6962,omitempty"`
+		Arity          int        `json:"arity,omitempty"`
+		Padding        *int       `json:"padding,omitempty"`
+		Padded         *jsonPad   `json:"padded,omitempty"`
+		SortedPairs    bool       `json:"sortedPairs,omitempty"`
+		LengthPrefixed bool       `json:"lengthPrefixed,omitempty"`
+		DigestSize     int        `json:"digestSize,omitempty"`
+		NextID         uint64     `json:"nextID,omitempty"`
+		Leaves         []jsonLeaf `json:"leaves"`
+	}
+
+	// jsonPad is the JSON representation of the padding of a padded tree
+	// (see PadToPowerOfTwo).
+	jsonPad struct {
+		Depth     int      `json:"depth,omitempty"`
+		EmptyLeaf hexBytes `json:"emptyLeaf,omitempty"`
+	}
+
+	jsonLeaf struct {
+		OrderedID uint64   `json:"orderedID"`
+		Digest    hexBytes `json:"digest"`
+		Datum     []byte   `json:"datum,omitempty"`
+	}
+
+	// jsonProof is the JSON representation of a Proof.
+	jsonProof struct {
+		Hash        string     `json:"hash"`
+		LeafIndex   int        `json:"leafIndex"`
+		NumLeaves   int        `json:"numLeaves"`
+		LeafDigest  hexBytes   `json:"leafDigest"`
+		Siblings    []hexBytes `json:"siblings"`
+		RFC6962     bool       `json:"rfc6962,omitempty"`
+		Arity       int        `json:"arity,omitempty"`
+		Padding     *int       `json:"padding,omitempty"`
+		SortedPairs bool       `json:"sortedPairs,omitempty"`
+		DigestSize  int        `json:"digestSize,omitempty"`
+	}
+
+	// hexBytes is a byte slice that is encoded as a hexadecimal string.
+	hexBytes []byte
+)
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// Digests are encoded as hexadecimal strings, serialized data as base64
+// strings, and the hash function is identified by its name (e.g. "SHA-256").
+func (t *Tree) MarshalJSON() ([]byte, error) {
+	if !t.scheme.encodable() {
+		return nil, ErrUnsupported
+	}
+	jt := jsonTree{
+		Hash:           t.hash.String(),
+		DigestOnly:     t.digestOnly,
+		InsertionOrder: t.encodedInsertionOrder(),
+		RFC6962:        t.scheme.isRFC6962(),
+		Arity:          t.scheme.arity,
+		Padding:        t.scheme.jsonPadding(),
+		SortedPairs:    t.scheme.sortedPairs,
+		LengthPrefixed: t.scheme.lengthPrefixed,
+		DigestSize:     t.scheme.digestSize,
+		Leaves:         make([]jsonLeaf, len(t.tls)),
+	}
+	if t.scheme.padded {
+		jt.Padded = &jsonPad{Depth: t.scheme.depth, EmptyLeaf: t.scheme.emptyLeaf}
+	}
+	if t.nextID != impliedNextID(t.tls) {
+		jt.NextID = t.nextID
+	}
+	for i := range t.tls {
+		jt.Leaves[i] = jsonLeaf{
+			OrderedID: t.tls[i].orderedID,
+			Digest:    t.tls[i].digest,
+			Datum:     t.leafDatum(&t.tls[i]),
+		}
+	}
+	return json.Marshal(&jt)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+//
+// The leaves are rehashed (unless in digest-only mode) and the merkle nodes
+// are reconstructed on top of them.
+func (t *Tree) UnmarshalJSON(data []byte) error {
+	var jt jsonTree
+	if err := json.Unmarshal(data, &jt); err != nil {
+		return err
+	}
+	hash, err := hashByName(jt.Hash)
+	if err != nil {
+		return err
+	}
+	if len(jt.Leaves) == 0 {
+		return ErrNoData
+	}
+
+	t2 := &Tree{hash: hash, digestOnly: jt.DigestOnly, insertionOrder: jt.InsertionOrder}
+	if jt.RFC6962 {
+		t2.scheme = rfc6962Scheme
+	}
+	if jt.Arity > 2 {
+		t2.scheme.arity = jt.Arity
+	}
+	if err := t2.scheme.setJSONPadding(jt.Padding); err != nil {
+		return err
+	}
+	t2.scheme.sortedPairs, t2.scheme.lengthPrefixed = jt.SortedPairs, jt.LengthPrefixed
+	if jt.DigestSize < 0 {
+		return ErrInvalidEncoding
+	}
+	t2.scheme.digestSize = jt.DigestSize
+	if jt.Padded != nil {
+		if jt.Padded.Depth < 0 {
+			return ErrInvalidEncoding
+		}
+		t2.scheme.padded, t2.scheme.depth = true, jt.Padded.Depth
+		if len(jt.Padded.EmptyLeaf) != 0 {
+			t2.scheme.emptyLeaf = jt.Padded.EmptyLeaf
+		}
+		if t2.checkPadding(len(jt.Leaves)) != nil {
+			return ErrInvalidEncoding
+		}
+	}
+	h := t2.newHasher()
+	tls := make([]treeLeaf, len(jt.Leaves))
+	for i, jl := range jt.Leaves {
+		tls[i] = treeLeaf{digest: jl.Digest, orderedID: jl.OrderedID}
+		if !jt.DigestOnly {
+			tls[i].datum = jl.Datum
+			if tls[i].datum == nil {
+				tls[i].datum = []byte{}
+			}
+			tls[i].digest = t2.scheme.hashLeaf(h, tls[i].datum)
+		} else if len(jl.Digest) != h.Size() {
+			return ErrInvalidEncoding
+		}
+	}
+	if !validIDs(tls) {
+		return ErrInvalidEncoding
+	}
+	if t2.nextID = impliedNextID(tls); jt.NextID != 0 {
+		if jt.NextID < t2.nextID {
+			return ErrInvalidEncoding
+		}
+		t2.nextID = jt.NextID
+	}
+	t2.sortTreeLeaves(tls)
+	t2.tls = tls
+	t2.setNodes(t2.constructMerkleNodes(h, tls))
+	*t = *t2
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// Digests are encoded as hexadecimal strings, and the hash function is
+// identified by its name (e.g. "SHA-256").
+func (p *Proof) MarshalJSON() ([]byte, error) {
+	if p.scheme != nil && p.scheme.newHash != nil {
+		return nil, ErrUnsupported
+	}
+	jp := jsonProof{
+		Hash:       p.Hash.String(),
+		LeafIndex:  p.LeafIndex,
+		NumLeaves:  p.NumLeaves,
+		LeafDigest: p.LeafDigest,
+		Siblings:   make([]hexBytes, len(p.Siblings)),
+	}
+	if p.scheme != nil {
+		jp.RFC6962, jp.Arity = p.scheme.isRFC6962(), p.scheme.arity
+		jp.Padding = p.scheme.jsonPadding()
+		jp.SortedPairs = p.scheme.sortedPairs
+		jp.DigestSize = p.scheme.digestSize
+	}
+	for i := range p.Siblings {
+		jp.Siblings[i] = p.Siblings[i]
+	}
+	return json.Marshal(&jp)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	var jp jsonProof
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+	hash, err := hashByName(jp.Hash)
+	if err != nil {
+		return err
+	}
+	p.Hash = hash
+	p.LeafIndex = jp.LeafIndex
+	p.NumLeaves = jp.NumLeaves
+	p.LeafDigest = jp.LeafDigest
+	p.Siblings = make([][]byte, len(jp.Siblings))
+	for i := range jp.Siblings {
+		p.Siblings[i] = jp.Siblings[i]
+		if p.Siblings[i] == nil {
+			p.Siblings[i] = []byte{}
+		}
+	}
+	var s scheme
+	if jp.RFC6962 {
+		s = rfc6962Scheme
+	}
+	if jp.Arity > 2 {
+		s.arity = jp.Arity
+	}
+	if err := s.setJSONPadding(jp.Padding); err != nil {
+		return err
+	}
+	s.sortedPairs = jp.SortedPairs
+	if jp.DigestSize < 0 {
+		return ErrInvalidEncoding
+	}
+	s.digestSize = jp.DigestSize
+	p.scheme = nil
+	if !s.isDefault() {
+		p.scheme = &s
+	}
+	return nil
+}
+
+// jsonPadding returns the PaddingPolicy of the scheme for its JSON
+// representation, or nil if it is the one implied by the rest of it.
+func (s *scheme) jsonPadding() *int {
+	if s.padding == s.impliedPadding() {
+		return nil
+	}
+	padding := int(s.padding)
+	return &padding
+}
+
+// setJSONPadding sets the PaddingPolicy of the scheme out of its JSON
+// representation, if any.
+func (s *scheme) setJSONPadding(padding *int) error {
+	if padding == nil {
+		return nil
+	}
+	if !PaddingPolicy(*padding).valid() {
+		return ErrInvalidEncoding
+	}
+	s.padding = PaddingPolicy(*padding)
+	return nil
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (b hexBytes) MarshalText() ([]byte, error) {
+	ret := make([]byte, hex.EncodedLen(len(b)))
+	hex.Encode(ret, b)
+	return ret, nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (b *hexBytes) UnmarshalText(text []byte) error {
+	ret := make([]byte, hex.DecodedLen(len(text)))
+	if _, err := hex.Decode(ret, text); err != nil {
+		return err
+	}
+	*b = ret
+	return nil
+}
+
+// hashByName returns the hash function that goes by the given name (as
+// returned by crypto.Hash.String), provided it has been linked into the
+// binary.
+func hashByName(name string) (crypto.Hash, error) {
+	for hash := crypto.Hash(1); hash < maxHash; hash++ {
+		if hash.String() == name {
+			if !hash.Available() {
+				return 0, &HashError{Hash: hash}
+			}
+			return hash, nil
+		}
+	}
+	return 0, ErrHashUnavailable
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"testing"
+)
+
+func TestJSON00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", data)
+
+	var tree2 Tree
+	if err = json.Unmarshal(data, &tree2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	if v, err := tree2.VerifyDatum(gamma); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", gamma, v, err)
+	}
+
+	if err = json.Unmarshal([]byte(`{"hash":"MD4","leaves":[{"datum":"YQ=="}]}`), &tree2); err == nil {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+}
+func TestJSON01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.ProveDatum(Z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", data)
+
+	var p2 Proof
+	if err = json.Unmarshal(data, &p2); err != nil {
+		t.Fatal(err)
+	}
+	if !p2.Verify(tree.MerkleRoot()) {
+		t.Fatalf("decoded proof failed to verify")
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package log implements a sequenced, append-only, verifiable log on top of
+// a merkle tree that hashes as per RFC 6962 (Certificate Transparency).
+//
+// Every entry appended to a Log is assigned the next index in sequence, and
+// the Log can prove both that an entry is included in it (inclusion proofs)
+// and that any earlier version of it is a prefix of a later one (consistency
+// proofs). Its state can be committed to by checkpoints, which can then be
+// signed and gossiped using the checkpoint package.
+//
+// Proofs are verified as per RFC 9162 (sections 2.1.3.2 and 2.1.4.2), so that
+// they are interoperable with other transparency log implementations.
+package log
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"hash"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/checkpoint"
+)
+
+var (
+	// ErrHashUnavailable signifies that the requested hash function has
+	// not been linked into the binary.
+	ErrHashUnavailable = errors.New("log: hash function unavailable")
+	// ErrIndexOutOfRange signifies that the requested entry index is not
+	// smaller than the size of the Log (or of the requested version).
+	ErrIndexOutOfRange = errors.New("log: index out of range")
+	// ErrSizeOutOfRange signifies that the requested size is greater than
+	// the size of the Log, or smaller than another one it is compared to.
+	ErrSizeOutOfRange = errors.New("log: size out of range")
+)
+
+// Log is a sequenced, append-only, verifiable log. It is not safe for
+// concurrent use.
+type Log struct {
+	hash    crypto.Hash
+	origin  string
+	tree    *merkle.Tree
+	entries [][]byte
+}
+
+// New creates an empty Log given one of the available (i.e. linked into the
+// binary) hash functions and the origin that identifies it in its
+// checkpoints.
+func New(hash crypto.Hash, origin string) (*Log, error) {
+	if !hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	return &Log{hash: hash, origin: origin}, nil
+}
+
+// Append appends the given entry to the Log, and returns its index along with
+// its inclusion proof in the resulting version of the Log.
+func (l *Log) Append(entry []byte) (index uint64, proof [][]byte, err error) {
+	entry = append([]byte(nil), entry...)
+	if l.tree == nil {
+		l.tree, err = merkle.NewTreeWithOptions(l.hash, []merkle.Datum{merkle.ByteDatum(entry)}, merkle.DigestOnly(), merkle.RFC6962())
+		if err != nil {
+			return 0, nil, err
+		}
+	} else {
+		l.tree.AppendAndReconstruct(merkle.ByteDatum(entry))
+	}
+	l.entries = append(l.entries, entry)
+
+	index = uint64(len(l.entries) - 1)
+	proof, err = l.InclusionProofByIndex(index, l.Size())
+	return index, proof, err
+}
+
+// Size returns the number of entries in the Log.
+func (l *Log) Size() uint64 {
+	return uint64(len(l.entries))
+}
+
+// Origin returns the origin that identifies the Log.
+func (l *Log) Origin() string {
+	return l.origin
+}
+
+// Root returns the merkle root of the Log; for an empty Log, that is the
+// digest of the empty string.
+func (l *Log) Root() []byte {
+	if l.tree == nil {
+		return l.hash.New().Sum(nil)
+	}
+	return l.tree.MerkleRoot()
+}
+
+// RootAt returns the merkle root that the Log had when it was of the given
+// size.
+func (l *Log) RootAt(size uint64) ([]byte, error) {
+	if size > l.Size() {
+		return nil, ErrSizeOutOfRange
+	}
+	if size == 0 {
+		return l.hash.New().Sum(nil), nil
+	}
+	return l.tree.RootAt(int(size))
+}
+
+// Entry returns the entry at the given index of the Log.
+func (l *Log) Entry(index uint64) ([]byte, error) {
+	if index >= l.Size() {
+		return nil, ErrIndexOutOfRange
+	}
+	return append([]byte(nil), l.entries[index]...), nil
+}
+
+// InclusionProofByIndex returns the inclusion proof (i.e. the audit path) of
+// the entry at the given index in the version of the Log of the given size.
+func (l *Log) InclusionProofByIndex(index, size uint64) ([][]byte, error) {
+	if size > l.Size() {
+		return nil, ErrSizeOutOfRange
+	}
+	if index >= size {
+		return nil, ErrIndexOutOfRange
+	}
+	return l.tree.InclusionProof(int(index), int(size))
+}
+
+// ConsistencyProof returns the proof that the version of the Log of size
+// oldSize is a prefix of the version of size newSize. The proof is empty if
+// oldSize is either zero or equal to newSize.
+func (l *Log) ConsistencyProof(oldSize, newSize uint64) ([][]byte, error) {
+	if oldSize > newSize || newSize > l.Size() {
+		return nil, ErrSizeOutOfRange
+	}
+	if oldSize == 0 || oldSize == newSize {
+		return [][]byte{}, nil
+	}
+	return l.tree.ConsistencyProof(int(oldSize), int(newSize))
+}
+
+// Checkpoint returns a checkpoint of the current version of the Log, which
+// can be signed using the checkpoint package.
+func (l *Log) Checkpoint() checkpoint.Checkpoint {
+	return checkpoint.Checkpoint{
+		Origin: l.origin,
+		Size:   l.Size(),
+		Hash:   l.Root(),
+	}
+}
+
+// SignCheckpoint returns a checkpoint of the current version of the Log,
+// signed by the given signers as a note, ready to be co-signed by witnesses
+// (see the witness package).
+func (l *Log) SignCheckpoint(signers ...*checkpoint.Signer) ([]byte, error) {
+	cp := l.Checkpoint()
+	return checkpoint.Sign(cp.Marshal(), nil, signers...)
+}
+
+// LeafHash returns the digest of the leaf of the given entry, i.e. H(0x00 ||
+// entry), as per RFC 6962.
+func LeafHash(hash crypto.Hash, entry []byte) []byte {
+	h := hash.New()
+	h.Write([]byte{0x00})
+	h.Write(entry)
+	return h.Sum(nil)
+}
+
+// hashChildren returns H(0x01 || left || right), as per RFC 6962.
+func hashChildren(h hash.Hash, left, right []byte) []byte {
+	h.Reset()
+	h.Write([]byte{0x01})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// VerifyInclusion verifies that the leaf with the given digest is at the
+// given index of the version of a log of the given size and merkle root, as
+// per RFC 9162 (section 2.1.3.2).
+func VerifyInclusion(hash crypto.Hash, index, size uint64, leafHash []byte, proof [][]byte, root []byte) bool {
+	if !hash.Available() || index >= size {
+		return false
+	}
+	h := hash.New()
+	fn, sn, r := index, size-1, leafHash
+	for _, p := range proof {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			r = hashChildren(h, p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			r = hashChildren(h, r, p)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+	return sn == 0 && bytes.Equal(r, root)
+}
+
+// VerifyConsistency verifies that the version of a log of size oldSize and
+// merkle root oldRoot is a prefix of the version of size newSize and merkle
+// root newRoot, as per RFC 9162 (section 2.1.4.2).
+//
+// It is equivalent to merkle.VerifyConsistency.
+func VerifyConsistency(hash crypto.Hash, oldSize, newSize uint64, oldRoot, newRoot []byte, proof [][]byte) bool {
+	return merkle.VerifyConsistency(hash, oldRoot, newRoot, oldSize, newSize, proof)
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package log
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ckatsak/merkle/checkpoint"
+)
+
+// testEntries and testRoots are the test vectors of the reference
+// implementation of Certificate Transparency.
+var (
+	testEntries = []string{"", "00", "10", "2021", "3031", "40414243", "5051525354555657", "606162636465666768696a6b6c6d6e6f"}
+	testRoots   = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func newTestLog(t *testing.T) *Log {
+	l, err := New(crypto.SHA256, "example.com/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range testEntries {
+		entry, _ := hex.DecodeString(e)
+		index, proof, err := l.Append(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index != uint64(i) {
+			t.Fatalf("want (%d); got %d", i, index)
+		}
+		if !VerifyInclusion(crypto.SHA256, index, l.Size(), LeafHash(crypto.SHA256, entry), proof, l.Root()) {
+			t.Fatalf("inclusion proof of entry %d does not verify", i)
+		}
+		if hex.EncodeToString(l.Root()) != testRoots[i] {
+			t.Fatalf("want (%s); got %x", testRoots[i], l.Root())
+		}
+	}
+	return l
+}
+
+func TestLog00(t *testing.T) {
+	l, err := New(crypto.SHA256, "example.com/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("empty root: %x", l.Root())
+	if hex.EncodeToString(l.Root()) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("want (e3b0c442...); got %x", l.Root())
+	}
+	if _, err = l.Entry(0); err != ErrIndexOutOfRange {
+		t.Fatalf("want (%v); got %v", ErrIndexOutOfRange, err)
+	}
+	if _, err = New(crypto.Hash(0), ""); err != ErrHashUnavailable {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+}
+
+func TestInclusion00(t *testing.T) {
+	l := newTestLog(t)
+	for size := uint64(1); size <= l.Size(); size++ {
+		root, err := l.RootAt(size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(root) != testRoots[size-1] {
+			t.Fatalf("want (%s); got %x", testRoots[size-1], root)
+		}
+		for index := uint64(0); index < size; index++ {
+			proof, err := l.InclusionProofByIndex(index, size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			entry, _ := l.Entry(index)
+			leafHash := LeafHash(crypto.SHA256, entry)
+			if !VerifyInclusion(crypto.SHA256, index, size, leafHash, proof, root) {
+				t.Fatalf("inclusion proof of entry %d at size %d does not verify", index, size)
+			}
+			if index^1 < size && VerifyInclusion(crypto.SHA256, index^1, size, leafHash, proof, root) {
+				t.Fatalf("inclusion proof of entry %d verifies at index %d", index, index^1)
+			}
+			if len(proof) > 0 {
+				proof[0][0] ^= 0xff
+				if VerifyInclusion(crypto.SHA256, index, size, leafHash, proof, root) {
+					t.Fatalf("tampered inclusion proof of entry %d at size %d verifies", index, size)
+				}
+			}
+		}
+	}
+	if _, err := l.InclusionProofByIndex(8, 8); err != ErrIndexOutOfRange {
+		t.Fatalf("want (%v); got %v", ErrIndexOutOfRange, err)
+	}
+	if _, err := l.InclusionProofByIndex(0, 9); err != ErrSizeOutOfRange {
+		t.Fatalf("want (%v); got %v", ErrSizeOutOfRange, err)
+	}
+}
+
+func TestConsistency00(t *testing.T) {
+	l := newTestLog(t)
+	for newSize := uint64(0); newSize <= l.Size(); newSize++ {
+		newRoot, _ := l.RootAt(newSize)
+		for oldSize := uint64(0); oldSize <= newSize; oldSize++ {
+			oldRoot, _ := l.RootAt(oldSize)
+			proof, err := l.ConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyConsistency(crypto.SHA256, oldSize, newSize, oldRoot, newRoot, proof) {
+				t.Fatalf("consistency proof %d -> %d does not verify", oldSize, newSize)
+			}
+			if oldSize > 0 && oldSize < newSize {
+				if VerifyConsistency(crypto.SHA256, oldSize, newSize, newRoot, newRoot, proof) {
+					t.Fatalf("consistency proof %d -> %d verifies against a wrong root", oldSize, newSize)
+				}
+				proof[len(proof)-1][0] ^= 0xff
+				if VerifyConsistency(crypto.SHA256, oldSize, newSize, oldRoot, newRoot, proof) {
+					t.Fatalf("tampered consistency proof %d -> %d verifies", oldSize, newSize)
+				}
+			}
+		}
+	}
+	if _, err := l.ConsistencyProof(5, 4); err != ErrSizeOutOfRange {
+		t.Fatalf("want (%v); got %v", ErrSizeOutOfRange, err)
+	}
+}
+
+func TestCheckpoint00(t *testing.T) {
+	l := newTestLog(t)
+	cp := l.Checkpoint()
+	text := cp.Marshal()
+	t.Logf("\n%s", text)
+
+	var cp2 checkpoint.Checkpoint
+	if err := cp2.Unmarshal(text); err != nil {
+		t.Fatal(err)
+	}
+	if cp2.Origin != "example.com/log" || cp2.Size != l.Size() || !bytes.Equal(cp2.Hash, l.Root()) {
+		t.Fatalf("want (%+v); got %+v", cp, cp2)
+	}
+}
+
+func TestSignCheckpoint00(t *testing.T) {
+	l := newTestLog(t)
+	skey, vkey, err := checkpoint.GenerateKey(rand.Reader, "example.com/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := checkpoint.NewSigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := checkpoint.NewVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := l.SignCheckpoint(signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := checkpoint.Open(msg, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cp checkpoint.Checkpoint
+	if err := cp.Unmarshal(n.Text); err != nil {
+		t.Fatal(err)
+	}
+	if cp.Size != l.Size() || !bytes.Equal(cp.Hash, l.Root()) {
+		t.Fatalf("want (%d, %x); got (%d, %x)", l.Size(), l.Root(), cp.Size, cp.Hash)
+	}
+}
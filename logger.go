@@ -0,0 +1,157 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"encoding/hex"
+	"time"
+)
+
+// Logger logs the events of a merkle tree (see WithLogger); i.e. its
+// mutations at the Info level, the (re)constructions of its merkle nodes at
+// the Debug level, and the verifications that fail at the Warn level. Its
+// methods take a message, followed by alternating keys and values, as the
+// ones of *slog.Logger do; hence, a *slog.Logger is a Logger. It is shared by
+// the merkle tree and the ones derived from it (e.g. through Snapshot or
+// Appended), and it is called concurrently by AuditAll, so it must be safe for
+// concurrent use.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// WithLogger configures the merkle tree to log its events to the given
+// Logger; e.g. to trace unexpected changes of its merkle root. It is not
+// included in the encodings of the merkle tree.
+//
+// The mutations are logged along with the name of the operation ("op"), the
+// numbers of the leaves it appended ("appended") and removed ("deleted"), the
+// resulting number of leaves ("leaves") and version ("version"), and the
+// merkle roots before ("oldRoot") and after ("newRoot") it, in hexadecimal.
+func WithLogger(l Logger) Option {
+	return func(t *Tree) {
+		t.logger = l
+	}
+}
+
+// mutation describes a mutation of the merkle tree that is underway (see
+// beginMutation), on behalf of its Metrics, Logger and subscribers (see
+// Subscribe).
+type mutation struct {
+	op                string
+	appended, deleted int
+	oldRoot           []byte
+}
+
+// beginMutation describes the given mutation of the merkle tree, which is
+// about to replace its leaves; it is reported once setNodes sets the merkle
+// nodes above the new ones (see endMutation).
+func (t *Tree) beginMutation(op string, appended, deleted int) {
+	subscribed := t.subscribed()
+	if t.metrics == nil && t.logger == nil && !subscribed {
+		return
+	}
+	m := &mutation{op: op, appended: appended, deleted: deleted}
+	if t.logger != nil || subscribed {
+		m.oldRoot = t.MerkleRoot()
+	}
+	t.mutation = m
+}
+
+// endMutation reports the mutation of the merkle tree that is underway, if
+// any, provided that setting its merkle nodes succeeded.
+func (t *Tree) endMutation(ok bool) {
+	m := t.mutation
+	t.mutation = nil
+	if m == nil || !ok {
+		return
+	}
+	if t.metrics != nil {
+		if m.appended > 0 {
+			t.metrics.LeavesAppended(m.appended)
+		}
+		if m.deleted > 0 {
+			t.metrics.LeavesDeleted(m.deleted)
+		}
+	}
+	if t.logger != nil {
+		t.logger.Info("merkle tree mutated",
+			"op", m.op,
+			"appended", m.appended,
+			"deleted", m.deleted,
+			"leaves", len(t.tls),
+			"version", t.version,
+			"oldRoot", hex.EncodeToString(m.oldRoot),
+			"newRoot", hex.EncodeToString(t.root()),
+		)
+	}
+	if t.subscribed() {
+		t.publish(RootUpdate{
+			Op:        m.op,
+			OldRoot:   m.oldRoot,
+			NewRoot:   t.MerkleRoot(),
+			Appended:  m.appended,
+			Deleted:   m.deleted,
+			NumLeaves: len(t.tls),
+			Version:   t.version,
+		})
+	}
+}
+
+// observeVerification reports the outcome of the verification of the merkle
+// path of the leaf at the given index, and returns it.
+func (t *Tree) observeVerification(leafIndex int, ok bool, err error) (bool, error) {
+	if t.metrics != nil && err == nil {
+		t.metrics.Verified(ok)
+	}
+	if t.logger != nil && (!ok || err != nil) {
+		args := []interface{}{
+			"index", leafIndex,
+			"orderedID", t.tls[leafIndex].orderedID,
+			"digest", hex.EncodeToString(t.tls[leafIndex].digest),
+		}
+		if err != nil {
+			args = append(args, "err", err)
+		}
+		t.logger.Warn("merkle path verification failed", args...)
+	}
+	return ok, err
+}
+
+// observeProof reports a generated proof.
+func (t *Tree) observeProof() {
+	if t.metrics != nil {
+		t.metrics.ProofGenerated()
+	}
+}
+
+// observeReconstruction reports the duration of a (re)construction of the
+// merkle nodes above the given number of leaves, of which the given number
+// of first ones were unchanged, that began at the given time; it is meant to
+// be deferred.
+func (t *Tree) observeReconstruction(numLeaves, unchanged int, start time.Time) {
+	d := time.Since(start)
+	if t.metrics != nil {
+		t.metrics.Reconstructed(d)
+	}
+	if t.logger != nil {
+		t.logger.Debug("merkle nodes reconstructed",
+			"leaves", numLeaves,
+			"unchanged", unchanged,
+			"duration", d,
+		)
+	}
+}
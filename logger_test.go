@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWithLogger00(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldRoot := hex.EncodeToString(tree.MerkleRoot())
+	if _, _, err := tree.Append(grAlphabet[10], grAlphabet[11]); err != nil {
+		t.Fatal(err)
+	}
+	newRoot := hex.EncodeToString(tree.MerkleRoot())
+	tree.DeleteAndReconstruct(grAlphabet[0])
+	tree.tls[3].digest = make([]byte, len(tree.tls[3].digest))
+	if v, err := tree.VerifySerializedDatum(tree.tls[2].datum); err != nil || v {
+		t.Fatalf("want (false, <nil>); got (%v, %v)", v, err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for _, line := range lines {
+		t.Log(line)
+	}
+	for i, want := range []string{
+		"level=DEBUG msg=\"merkle nodes reconstructed\" leaves=10 unchanged=0",
+		"level=DEBUG msg=\"merkle nodes reconstructed\" leaves=12 unchanged=",
+		"level=INFO msg=\"merkle tree mutated\" op=Append appended=2 deleted=0 leaves=12 version=1 oldRoot=" + oldRoot + " newRoot=" + newRoot,
+		"level=DEBUG msg=\"merkle nodes reconstructed\" leaves=11 unchanged=0",
+		"level=INFO msg=\"merkle tree mutated\" op=DeleteAndReconstruct appended=0 deleted=1 leaves=11 version=2 oldRoot=" + newRoot,
+		"level=WARN msg=\"merkle path verification failed\" index=2",
+	} {
+		if i >= len(lines) || !strings.Contains(lines[i], want) {
+			t.Fatalf("want line %d to contain (%s); got %d lines", i, want, len(lines))
+		}
+	}
+	if len(lines) != 6 {
+		t.Fatalf("want (6) lines; got %d", len(lines))
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+)
+
+// Map is an authenticated key-value map, built on top of a merkle tree whose
+// leaves are the map's entries, ordered by key. Its merkle root commits to
+// the whole map, so that both the presence and the absence of any key can be
+// proven against it.
+//
+// The merkle tree is reconstructed lazily, i.e. only when the root or a proof
+// is requested after the map has been modified.
+type Map struct {
+	hash    crypto.Hash
+	entries map[string][]byte
+	tree    *Tree
+	dirty   bool
+}
+
+// mapEntry is the Datum that represents an entry of a Map. Its key is
+// prefixed by its length in big-endian order, so that serialized entries sort
+// by key length first and key bytes second, regardless of their values.
+type mapEntry struct {
+	key, value []byte
+}
+
+// Serialize implements the Datum interface.
+func (e mapEntry) Serialize() []byte {
+	ret := make([]byte, 4, 4+len(e.key)+len(e.value))
+	binary.BigEndian.PutUint32(ret, uint32(len(e.key)))
+	ret = append(ret, e.key...)
+	return append(ret, e.value...)
+}
+
+// compareMapKeys compares two keys in the order that the entries of a Map are
+// sorted by.
+func compareMapKeys(a, b []byte) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return bytes.Compare(a, b)
+}
+
+// NewMap creates a new, empty Map given one of the available (i.e. linked into
+// the binary) hash functions.
+func NewMap(hash crypto.Hash) (*Map, error) {
+	if !hash.Available() {
+		return nil, &HashError{Hash: hash}
+	}
+	return &Map{hash: hash, entries: make(map[string][]byte)}, nil
+}
+
+// Len returns the number of entries in the Map.
+func (m *Map) Len() int {
+	return len(m.entries)
+}
+
+// Put associates the given value with the given key.
+func (m *Map) Put(key, value []byte) {
+	m.entries[string(key)] = copyBytes(value)
+	m.dirty = true
+}
+
+// Get returns the value associated with the given key, if any.
+func (m *Map) Get(key []byte) ([]byte, bool) {
+	value, ok := m.entries[string(key)]
+	if !ok {
+		return nil, false
+	}
+	return copyBytes(value), true
+}
+
+// Delete removes the given key (if present) from the Map.
+func (m *Map) Delete(key []byte) {
+	if _, ok := m.entries[string(key)]; ok {
+		delete(m.entries, string(key))
+		m.dirty = true
+	}
+}
+
+// Root returns the merkle root of the Map, or nil if the Map is empty.
+func (m *Map) Root() []byte {
+	if m.reconstruct(); m.tree == nil {
+		return nil
+	}
+	return m.tree.MerkleRoot()
+}
+
+func (m *Map) reconstruct() {
+	if !m.dirty {
+		return
+	}
+	m.dirty = false
+	if len(m.entries) == 0 {
+		m.tree = nil
+		return
+	}
+	data := make([]Datum, 0, len(m.entries))
+	for key, value := range m.entries {
+		data = append(data, mapEntry{key: []byte(key), value: value})
+	}
+	m.tree, _ = NewTreeWithOptions(m.hash, data)
+}
+
+// MapProof is an inclusion proof of an entry in a Map.
+type MapProof struct {
+	Key, Value []byte
+	Proof      *Proof
+}
+
+// ProveKey returns an inclusion proof of the given key (and the value that is
+// associated with it) in the Map.
+//
+// It returns a non-nil error if the key is not present in the Map.
+func (m *Map) ProveKey(key []byte) (*MapProof, error) {
+	value, ok := m.entries[string(key)]
+	if !ok {
+		return nil, &DataError{Op: "ProveKey", Datum: key, Err: ErrNoData}
+	}
+	m.reconstruct()
+	return m.proveEntry(mapEntry{key: key, value: value})
+}
+
+func (m *Map) proveEntry(e mapEntry) (*MapProof, error) {
+	p, err := m.tree.ProveDatum(e)
+	if err != nil {
+		return nil, err
+	}
+	return &MapProof{Key: copyBytes(e.key), Value: copyBytes(e.value), Proof: p}, nil
+}
+
+// Verify verifies that the MapProof leads to the given merkle root; i.e. that
+// Key is associated with Value in the Map.
+func (p *MapProof) Verify(root []byte) bool {
+	if p.Proof == nil || !p.Proof.Hash.Available() {
+		return false
+	}
+	h := p.Proof.Hash.New()
+	h.Write(mapEntry{key: p.Key, value: p.Value}.Serialize())
+	return bytes.Equal(h.Sum(nil), p.Proof.LeafDigest) && p.Proof.Verify(root)
+}
+
+// AbsenceProof is a non-inclusion proof of a key in a Map; i.e. inclusion
+// proofs of the two adjacent entries that the key would lie between, if it
+// were present. Left is nil if the key would be the first one, and Right is
+// nil if it would be the last one.
+type AbsenceProof struct {
+	Key         []byte
+	NumLeaves   int
+	Left, Right *MapProof
+}
+
+// ProveAbsent returns a non-inclusion proof of the given key in the Map.
+//
+// It returns a non-nil error if the key is present in the Map.
+func (m *Map) ProveAbsent(key []byte) (*AbsenceProof, error) {
+	if _, ok := m.entries[string(key)]; ok {
+		return nil, ErrKeyExists
+	}
+	ap := &AbsenceProof{Key: copyBytes(key), NumLeaves: len(m.entries)}
+	if m.reconstruct(); m.tree == nil {
+		return ap, nil
+	}
+
+	// Find the first entry that would sort after the given key...
+	h := m.hash.New()
+	index, _ := m.tree.search(h, mapEntry{key: key}.Serialize())
+	// ...and prove it along with the one before it.
+	var err error
+	for i, mp := range []**MapProof{&ap.Left, &ap.Right} {
+		if leafIndex := index - 1 + i; leafIndex >= 0 && leafIndex < m.tree.NumLeaves() {
+			e := m.tree.tls[leafIndex].datum
+			keyLen := binary.BigEndian.Uint32(e)
+			if *mp, err = m.proveEntry(mapEntry{key: e[4 : 4+keyLen], value: e[4+keyLen:]}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ap, nil
+}
+
+// Verify verifies that the AbsenceProof leads to the given merkle root; i.e.
+// that Key is not present in the Map. The merkle root of an empty Map is nil.
+func (p *AbsenceProof) Verify(root []byte) bool {
+	if p.Left == nil && p.Right == nil {
+		return p.NumLeaves == 0 && root == nil
+	}
+	if p.Left != nil {
+		if !p.Left.Verify(root) || p.Left.Proof.NumLeaves != p.NumLeaves || compareMapKeys(p.Left.Key, p.Key) >= 0 {
+			return false
+		}
+		if p.Right == nil && p.Left.Proof.LeafIndex != p.NumLeaves-1 {
+			return false
+		}
+	}
+	if p.Right != nil {
+		if !p.Right.Verify(root) || p.Right.Proof.NumLeaves != p.NumLeaves || compareMapKeys(p.Key, p.Right.Key) >= 0 {
+			return false
+		}
+		if p.Left == nil && p.Right.Proof.LeafIndex != 0 {
+			return false
+		}
+	}
+	return p.Left == nil || p.Right == nil || p.Right.Proof.LeafIndex == p.Left.Proof.LeafIndex+1
+}
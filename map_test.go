@@ -0,0 +1,111 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"fmt"
+	"testing"
+)
+
+func TestMap00(t *testing.T) {
+	m, err := NewMap(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Root() != nil {
+		t.Fatalf("want nil root for an empty Map; got %x", m.Root())
+	}
+	ap, err := m.ProveAbsent([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ap.Verify(m.Root()) {
+		t.Fatalf("absence proof in an empty Map failed")
+	}
+
+	m.Put([]byte("k"), []byte("v1"))
+	root1 := m.Root()
+	m.Put([]byte("k"), []byte("v2"))
+	if v, ok := m.Get([]byte("k")); !ok || string(v) != "v2" {
+		t.Fatalf("want v2; got (%q, %v)", v, ok)
+	}
+	if string(root1) == string(m.Root()) {
+		t.Fatalf("root did not change after updating a value")
+	}
+	m.Delete([]byte("k"))
+	if m.Len() != 0 || m.Root() != nil {
+		t.Fatalf("Map is not empty after deleting its only key")
+	}
+}
+func TestMap01(t *testing.T) {
+	m, err := NewMap(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i += 2 {
+		m.Put([]byte(fmt.Sprintf("key-%02d", i)), []byte(fmt.Sprint(i*i)))
+	}
+	root := m.Root()
+	t.Logf("m.Root(): %x", root)
+
+	for i := 0; i < 50; i++ {
+		key := []byte(fmt.Sprintf("key-%02d", i))
+		if i%2 == 0 {
+			mp, err := m.ProveKey(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !mp.Verify(root) {
+				t.Fatalf("proof of %q failed", key)
+			}
+			mp.Value = []byte("forged")
+			if mp.Verify(root) {
+				t.Fatalf("proof of %q verified a forged value", key)
+			}
+			if _, err = m.ProveAbsent(key); err == nil {
+				t.Fatalf("want (%v); got %v", ErrKeyExists, err)
+			}
+			continue
+		}
+		ap, err := m.ProveAbsent(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ap.Verify(root) {
+			t.Fatalf("absence proof of %q failed", key)
+		}
+		if _, err = m.ProveKey(key); err == nil {
+			t.Fatalf("want (%v); got %v", ErrNoData, err)
+		}
+	}
+
+	// Keys before the first and after the last one.
+	for _, key := range []string{"a", "zzzzzzzzzzzzzzzz"} {
+		ap, err := m.ProveAbsent([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ap.Verify(root) {
+			t.Fatalf("absence proof of %q failed", key)
+		}
+	}
+	// An absence proof must not be reusable for a present key.
+	ap, _ := m.ProveAbsent([]byte("key-03"))
+	ap.Key = []byte("key-02")
+	if ap.Verify(root) {
+		t.Fatalf("absence proof verified for a present key")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "sort"
+
+// Merge returns a new merkle tree whose leaves are the union of the leaves of
+// the given trees, which are left intact. The leaves of a retain their
+// ordered IDs, while the ones that only b holds are assigned the next ones of
+// a (see NextID), in the order of their ordered IDs in b. The leaves are sorted as per
+// the comparator of a, if given through WithLess.
+//
+// It returns a non-nil error if the two trees do not share the same hash
+// function and Options, if their fixed depth (see FixedDepth) does not allow
+// for the union of their leaves, or if a cannot hold it (see
+// ErrTooManyLeaves).
+func Merge(a, b *Tree) (*Tree, error) {
+	if a.hash != b.hash || a.digestOnly != b.digestOnly || a.insertionOrder != b.insertionOrder || !a.scheme.equal(&b.scheme) {
+		return nil, ErrUnsupported
+	}
+	keys := make(map[string]struct{}, len(a.tls))
+	for i := range a.tls {
+		keys[string(a.key(&a.tls[i]))] = struct{}{}
+	}
+	var extra []treeLeaf
+	for i := range b.tls {
+		if _, ok := keys[string(b.key(&b.tls[i]))]; !ok {
+			extra = append(extra, b.tls[i])
+		}
+	}
+	sort.Slice(extra, func(i, j int) bool {
+		return extra[i].orderedID < extra[j].orderedID
+	})
+
+	t := a.Clone()
+	if len(extra) == 0 {
+		return t, nil
+	}
+	if err := checkCapacity(len(t.tls), len(extra), a.nextID); err != nil {
+		return nil, err
+	}
+	tls := make([]treeLeaf, len(t.tls), len(t.tls)+len(extra))
+	copy(tls, t.tls)
+	for i := range extra {
+		tl := treeLeaf{
+			digest:    copyBytes(extra[i].digest),
+			orderedID: a.nextID + uint64(i),
+		}
+		if extra[i].datum != nil {
+			tl.datum = a.storedDatum(copyBytes(b.leafDatum(&extra[i])))
+		}
+		if extra[i].salt != nil {
+			tl.salt = copyBytes(extra[i].salt)
+		}
+		tls = append(tls, tl)
+	}
+	if err := t.checkPadding(len(tls)); err != nil {
+		return nil, err
+	}
+	// The merkle nodes over the leaves of a that precede the new ones are
+	// reused.
+	t.pushVersion()
+	t.sortTreeLeaves(tls)
+	unchanged := t.unchangedLeaves(tls)
+	t.beginMutation("Merge", len(extra), 0)
+	t.tls = tls
+	t.nextID += uint64(len(extra))
+	if err := t.setNodes(t.reconstructMerkleNodes(t.newHasher(), t.tls, unchanged)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
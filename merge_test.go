@@ -0,0 +1,85 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestMerge00(t *testing.T) {
+	a, err := NewTree(crypto.SHA256, enAlphabetCap[:16]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTree(crypto.SHA256, enAlphabetCap[10:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootA := copyBytes(a.MerkleRoot())
+
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("merged.MerkleRoot(): %x", merged.MerkleRoot())
+	if !bytes.Equal(merged.MerkleRoot(), whole.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", whole.MerkleRoot(), merged.MerkleRoot())
+	}
+	if !bytes.Equal(rootA, a.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", rootA, a.MerkleRoot())
+	}
+	leaves := merged.Leaves()
+	for i, word := range enAlphabetCap {
+		if !bytes.Equal(leaves[i], word.Serialize()) {
+			t.Fatalf("want leaf %d (%q); got %q", i, word.Serialize(), leaves[i])
+		}
+	}
+}
+func TestMerge01(t *testing.T) {
+	a, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap[:16], RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap[10:], RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(merged.MerkleRoot(), whole.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", whole.MerkleRoot(), merged.MerkleRoot())
+	}
+
+	c, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Merge(a, c); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
@@ -20,52 +20,135 @@ import (
 	"bytes"
 	"crypto"
 	"hash"
+	"math"
 	"sort"
+	"time"
 )
 
 // Datum is the interface that any piece of data has to implement so as to be
 // able to be contained in the leaves of the merkle tree.
+//
+// A Datum may optionally implement ErrSerializer or StreamSerializer too, in
+// which case the merkle tree prefers them over Serialize.
 type Datum interface {
 	// Serialize must return a serialized representation of the Datum.
 	Serialize() []byte
 }
 
-type (
-	// ErrHashUnavailable signifies that the requested hash function has
-	// not been linked into the binary.
-	ErrHashUnavailable struct{}
-
-	// ErrNoData signifies that the piece of data requested is either nil
-	// or not present in the merkle tree.
-	ErrNoData struct{}
-)
-
-func (ErrHashUnavailable) Error() string {
-	return "Hash Algorithm Unavailable"
-}
-func (ErrNoData) Error() string {
-	return "Nonexistent Data"
-}
-
 type (
 	// Tree is the exported struct to interact with the merkle tree.
 	Tree struct {
 		hash crypto.Hash
-		mns  [][][]byte
 		tls  []treeLeaf
+
+		// The merkle nodes are kept in store, and rows holds the number of
+		// them at each level, from the parents of the leaves to the root.
+		store     NodeStore
+		rows      []int
+		userStore bool
+		storeErr  error
+
+		// datumErr is the first error that serializing the data given to
+		// AppendAndReconstruct or DeleteAndReconstruct returned.
+		datumErr error
+
+		digestOnly     bool
+		insertionOrder bool
+		scheme         scheme
+		// less orders the keys of the leaves, if given through WithLess.
+		less func(a, b []byte) bool
+		// compressor compresses the serialized data of the leaves, if
+		// given through WithCompressor.
+		compressor Compressor
+		// dedup is set through DeduplicateData, and pool tracks the
+		// distinct data that the leaves retain.
+		dedup bool
+		pool  *payloadPool
+		// duplicates is set through WithDuplicates.
+		duplicates DuplicatePolicy
+		// metrics receives measurements, if given through WithMetrics,
+		// and logger logs events, if given through WithLogger; mutation
+		// is the mutation underway, on their behalf.
+		metrics  Metrics
+		logger   Logger
+		mutation *mutation
+		// noHasherPool is set through WithHasherPool.
+		noHasherPool bool
+		// multiHasher hashes the leaves in bulk, if given through
+		// WithMultiHasher.
+		multiHasher MultiHasher
+		// recycle is set through RecycleNodes.
+		recycle bool
+		// spillDir and spillBudget are set through SpillToDisk.
+		spillDir    string
+		spillBudget int
+		// proofCacheSize is set through WithProofCache, and proofs
+		// caches the siblings of the proofs of the leaves.
+		proofCacheSize int
+		proofs         *proofCache
+		// subs holds the callbacks registered through Subscribe.
+		subs *subscriptions
+		// secondary is the hash function given through WithSecondaryHash,
+		// and secondaryRoot the merkle root of the leaves as per it.
+		secondary     crypto.Hash
+		secondaryRoot []byte
+		// nextID is the ordered ID that the next appended leaf will be
+		// assigned.
+		nextID uint64
+		// undo journals the last committed Batch, if any.
+		undo *undoEntry
+
+		onProgress func(done, total int)
+		progress   *progress
+
+		// version counts the mutations of the merkle tree, and history
+		// holds snapshots of its earlier versions, if kept.
+		version      int
+		keepHistory  bool
+		historyLimit int
+		history      []*Tree
 	}
 
 	treeLeaf struct {
-		digest    []byte
-		datum     []byte
-		orderedID uint
+		digest []byte
+		datum  []byte
+		// orderedID is assigned to the leaf upon its insertion, and is
+		// never reassigned (see NextID).
+		orderedID uint64
+		// salt is hashed along with the datum, if the leaves are
+		// salted (see WithSalts).
+		salt []byte
 	}
 )
 
+// Hash returns the hash function that the merkle tree has been built with, or
+// whatever was given to its constructor if WithHashFunc was given too.
+func (t *Tree) Hash() crypto.Hash {
+	return t.hash
+}
+
+// newHasher returns a new instance of the hash function of the merkle tree.
+func (t *Tree) newHasher() hash.Hash {
+	return t.scheme.newHasher(t.hash)
+}
+
+// hashAvailable reports whether the hash function of the merkle tree has been
+// linked into the binary, or given through WithHashFunc.
+func (t *Tree) hashAvailable() bool {
+	return t.scheme.available(t.hash)
+}
+
 // Height returns the height of the merkle tree, including both its leaves and
 // the merkle nodes.
 func (t *Tree) Height() int {
-	return len(t.mns) + 1
+	return len(t.rows) + 1
+}
+
+// Arity returns the number of children of each merkle node of the merkle tree
+// (except for the last one of each level, which may have fewer); i.e. 2,
+// unless WithArity was given.
+func (t *Tree) Arity() int {
+	return t.scheme.width()
 }
 
 // Size returns the total number of nodes in the merkle tree, including both
@@ -77,8 +160,8 @@ func (t *Tree) Size() int {
 // MerkleSize returns the number of merkle nodes in the merkle trees, i.e. the
 // total number of nodes in the merkle tree, excluding its leaves.
 func (t *Tree) MerkleSize() (merkleSize int) {
-	for i := range t.mns {
-		merkleSize += len(t.mns[i])
+	for i := range t.rows {
+		merkleSize += t.rows[i]
 	}
 	return
 }
@@ -88,9 +171,29 @@ func (t *Tree) NumLeaves() (numLeaves int) {
 	return len(t.tls)
 }
 
-// MerkleRoot returns the hash digest of the root of the merkle tree.
+// MerkleRoot returns a copy of the hash digest of the root of the merkle tree.
+//
+// The merkle root of a tree with a single leaf is the digest of that leaf.
 func (t *Tree) MerkleRoot() []byte {
-	return t.mns[0][0]
+	return copyBytes(t.root())
+}
+
+// UnsafeMerkleRoot is like MerkleRoot, but returns the hash digest of the root
+// of the merkle tree without copying it; e.g. for comparing it on a hot path.
+//
+// The returned slice aliases the memory of the merkle tree; it must not be
+// modified, and it is only valid until the tree is modified (see
+// RecycleNodes).
+func (t *Tree) UnsafeMerkleRoot() []byte {
+	return t.root()
+}
+
+// root returns the hash digest of the root of the merkle tree, as kept by it.
+func (t *Tree) root() []byte {
+	if len(t.rows) == 0 {
+		return t.tls[0].digest
+	}
+	return t.nodeAt(len(t.rows), 0)
 }
 
 // NewTree creates a new merkle tree given one of the available (i.e. linked
@@ -99,70 +202,355 @@ func (t *Tree) MerkleRoot() []byte {
 // It returns a non-nil error either if the requested hash function has not
 // been linked into the binary, or if data are not given at all.
 func NewTree(hash crypto.Hash, data ...Datum) (*Tree, error) {
-	if !hash.Available() {
-		return nil, ErrHashUnavailable{}
+	return NewTreeWithOptions(hash, data)
+}
+
+// NewTreeWithOptions creates a new merkle tree given one of the available
+// (i.e. linked into the binary) hash functions, a bunch of data and any number
+// of Options to configure it.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if data are not given at all, if any of them fails
+// to be serialized (see ErrSerializer and StreamSerializer), if any of them is
+// given more than once while duplicates are rejected (see WithDuplicates), or
+// if they are more than MaxLeaves.
+func NewTreeWithOptions(hash crypto.Hash, data []Datum, opts ...Option) (*Tree, error) {
+	t := &Tree{hash: hash}
+	for _, opt := range opts {
+		opt(t)
 	}
-	h := hash.New()
+	if !t.hashAvailable() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	h := t.newHasher()
 
 	if len(data) == 0 {
-		return nil, ErrNoData{}
+		return nil, ErrNoData
+	}
+	if err := t.checkPadding(len(data)); err != nil {
+		return nil, err
 	}
+	t.beginProgress(len(data), len(data))
+	defer t.endProgress()
 	// Create the leaves...
-	tls := appendTreeLeaves(h, nil, data)
+	tls, _, err := t.appendTreeLeaves(h, nil, data)
+	if err != nil {
+		return nil, dataError("NewTreeWithOptions", err)
+	}
+	t.tls, t.nextID = tls, uint64(len(tls))
 	// ...and construct the merkle nodes above them.
-	mns := constructMerkleNodes(h, tls)
+	if err := t.setNodes(t.constructMerkleNodes(h, t.tls)); err != nil {
+		return nil, err
+	}
 
-	return &Tree{
-		hash: hash,
-		mns:  mns,
-		tls:  tls,
-	}, nil
+	return t, nil
+}
+
+// NewTreeFromBytes creates a new merkle tree given one of the available (i.e.
+// linked into the binary) hash functions and a bunch of already serialized
+// data, each of which becomes a ByteDatum.
+//
+// It returns a non-nil error either if the requested hash function has not
+// been linked into the binary, or if data are not given at all.
+func NewTreeFromBytes(hash crypto.Hash, data ...[]byte) (*Tree, error) {
+	ds := make([]Datum, len(data))
+	for i := range data {
+		ds[i] = ByteDatum(data[i])
+	}
+	return NewTreeWithOptions(hash, ds)
+}
+
+// NewTreeFromDigests creates a new merkle tree given one of the available
+// (i.e. linked into the binary) hash functions and the already calculated
+// digests of a bunch of data, which become the leaves of the tree as they are.
+//
+// Since the data themselves are unknown, the tree is in digest-only mode.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if digests are not given at all, if any of them is
+// not of the size that the hash function produces, or if any of them is given
+// more than once while duplicates are rejected (see WithDuplicates).
+func NewTreeFromDigests(hash crypto.Hash, digests [][]byte, opts ...Option) (*Tree, error) {
+	t := &Tree{hash: hash}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.digestOnly = true
+	if !t.hashAvailable() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	h := t.newHasher()
+
+	if len(digests) == 0 {
+		return nil, ErrNoData
+	}
+	if err := t.checkPadding(len(digests)); err != nil {
+		return nil, err
+	}
+
+	// Copy the digests into the leaves...
+	digestsSeq := make([]byte, 0, h.Size()*len(digests))
+	t.tls = make([]treeLeaf, 0, len(digests))
+	dups := t.newDuplicateSet(nil)
+	for i := range digests {
+		if len(digests[i]) != h.Size() {
+			return nil, ErrInvalidDigest
+		}
+		digestsSeq = append(digestsSeq, digests[i]...)
+		tl := treeLeaf{
+			digest:    digestsSeq[i*h.Size() : (i+1)*h.Size()],
+			orderedID: uint64(len(t.tls)),
+		}
+		if _, ok, err := dups.add(&tl); err != nil {
+			return nil, dataError("NewTreeFromDigests", err)
+		} else if ok {
+			t.tls = append(t.tls, tl)
+		}
+	}
+	t.sortTreeLeaves(t.tls)
+	t.nextID = uint64(len(t.tls))
+	// ...and construct the merkle nodes above them.
+	t.beginProgress(0, len(t.tls))
+	defer t.endProgress()
+	if err := t.setNodes(t.constructMerkleNodes(h, t.tls)); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Append appends the given data as new tree leaves, and reconstructs the
+// merkle tree to take them into account as well; i.e. it is like
+// AppendAndReconstruct, but it returns the ordered IDs assigned to the new
+// leaves (in the order that their data were given in) and the new merkle root.
+// The data that are already present are skipped if duplicates are ignored
+// (see IgnoreDuplicates), and the ordered IDs of their leaves are returned for
+// them instead.
+//
+// It returns a non-nil error, leaving the tree intact, if no data are given,
+// if any of them is nil, fails to be serialized (see ErrSerializer and
+// StreamSerializer) or is already present while duplicates are rejected (see
+// RejectDuplicates), if the fixed depth of the merkle tree (see FixedDepth)
+// does not allow for them, or if the merkle tree cannot hold them (see
+// ErrTooManyLeaves). It also returns the error of a NodeStore given through
+// WithNodeStore, if any, in which case the new leaves have been appended but
+// the merkle nodes above them may not have been stored.
+func (t *Tree) Append(data ...Datum) ([]uint64, []byte, error) {
+	if len(data) == 0 {
+		return nil, nil, ErrNoData
+	}
+	for i := range data {
+		if data[i] == nil {
+			return nil, nil, ErrNoData
+		}
+	}
+	if err := t.checkPadding(len(t.tls) + len(data)); err != nil {
+		return nil, nil, err
+	}
+	h := t.newHasher()
+	t.beginProgress(len(data), len(t.tls)+len(data))
+	defer t.endProgress()
+	ids, unchanged, err := t.appendData(h, "Append", data)
+	if err != nil {
+		return nil, nil, dataError("Append", err)
+	}
+	if err := t.setNodes(t.reconstructMerkleNodes(h, t.tls, unchanged)); err != nil {
+		return ids, nil, err
+	}
+	return ids, t.MerkleRoot(), nil
 }
 
 // AppendAndReconstruct appends the given data as new tree leaves, and
 // reconstructs the merkle tree to take them into account as well.
 //
-// This obviously modifies the merkle root of the tree.
+// This obviously modifies the merkle root of the tree, unless its fixed depth
+// (see FixedDepth) does not allow for the new leaves, in which case the tree
+// is left intact. So is it if any of the given data fails to be serialized
+// (see ErrSerializer and StreamSerializer), is already present while
+// duplicates are rejected (see RejectDuplicates), or cannot be held by the
+// tree (see ErrTooManyLeaves), which is reported by DatumErr.
+// Errors of a NodeStore given through WithNodeStore are reported by StoreErr.
 func (t *Tree) AppendAndReconstruct(data ...Datum) {
-	if len(data) == 0 {
+	if len(data) == 0 || t.checkPadding(len(t.tls)+len(data)) != nil {
+		return
+	}
+	h := t.newHasher()
+	t.beginProgress(len(data), len(t.tls)+len(data))
+	defer t.endProgress()
+	_, unchanged, err := t.appendData(h, "AppendAndReconstruct", data)
+	if err != nil {
+		t.recordDatumErr(dataError("AppendAndReconstruct", err))
 		return
 	}
-	h := t.hash.New()
-	// Append the new leaves...
-	t.tls = appendTreeLeaves(h, t.tls, data)
-	// ...and reconstruct the merkle nodes above them.
-	t.mns = constructMerkleNodes(h, t.tls)
+	t.setNodes(t.reconstructMerkleNodes(h, t.tls, unchanged))
+}
+
+// appendData appends the given data as new leaves of the merkle tree, and
+// returns the ordered IDs assigned to them along with the number of the leaves
+// that precede them, over which the merkle nodes are to be reused upon their
+// reconstruction. If any of the data fails to be serialized, the tree is left
+// intact and the error is returned.
+func (t *Tree) appendData(h hash.Hash, op string, data []Datum) (ids []uint64, unchanged int, err error) {
+	tls, ids, err := t.appendTreeLeaves(h, t.tls, data)
+	if err != nil {
+		return nil, 0, err
+	}
+	t.pushVersion()
+	unchanged = t.unchangedLeaves(tls)
+	t.nextID += uint64(len(tls) - len(t.tls))
+	t.beginMutation(op, len(tls)-len(t.tls), 0)
+	t.tls = tls
+	return ids, unchanged, nil
+}
+
+// Delete deletes the given data from the tree leaves, and reconstructs the
+// merkle tree on the remaining ones; i.e. it is like DeleteAndReconstruct,
+// but it returns the ordered IDs of the leaves that were actually removed, in
+// the order that their data were given in.
+//
+// The data that are not present in the merkle tree are skipped, and a non-nil
+// error (wrapping ErrNoData) is returned for the first of them, along with the
+// ordered IDs of the removed leaves, if any. It also returns a non-nil error,
+// leaving the tree intact, if no data are given, if any of them is nil or
+// fails to be serialized (see ErrSerializer and StreamSerializer), or if no
+// leaves would be left; as well as the error of a NodeStore given through
+// WithNodeStore, if any, in which case the leaves have been removed but the
+// merkle nodes above the remaining ones may not have been stored.
+func (t *Tree) Delete(data ...Datum) ([]uint64, error) {
+	if len(data) == 0 {
+		return nil, ErrNoData
+	}
+	for i := range data {
+		if data[i] == nil {
+			return nil, ErrNoData
+		}
+	}
+	h := t.newHasher()
+	tls, removed, missing, err := t.deleteTreeLeaves(h, t.tls, data)
+	if err != nil {
+		return nil, &DataError{Op: "Delete", Err: err}
+	}
+	if len(tls) == 0 {
+		return nil, ErrNoData
+	}
+	var missingErr error
+	if len(missing) != 0 {
+		missingErr = t.keyError("Delete", missing[0])
+	}
+	if len(removed) == 0 {
+		return nil, missingErr
+	}
+	t.pushVersion()
+	unchanged := t.unchangedLeaves(tls)
+	t.beginMutation("Delete", 0, len(removed))
+	t.tls = tls
+	t.beginProgress(0, len(t.tls))
+	defer t.endProgress()
+	if err := t.setNodes(t.reconstructMerkleNodes(h, t.tls, unchanged)); err != nil {
+		return removed, err
+	}
+	return removed, missingErr
 }
 
 // DeleteAndReconstruct deletes the given data from the tree leaves, and
-// reconstructs the merkle tree on the new (reduced) number of leaves.
+// reconstructs the merkle tree on the new (reduced) number of leaves. The
+// remaining leaves retain their ordered IDs.
 //
-// This obviously modifies the merkle root of the tree.
+// This obviously modifies the merkle root of the tree, unless any of the given
+// data fails to be serialized (see ErrSerializer and StreamSerializer), or no
+// leaves would be left, in which case the tree is left intact and the error
+// (wrapping ErrNoData in the latter case) is reported by DatumErr. Errors of a
+// NodeStore given through WithNodeStore are reported by StoreErr.
 func (t *Tree) DeleteAndReconstruct(data ...Datum) {
 	if len(data) == 0 {
 		return
 	}
+	h := t.newHasher()
 	// Delete the appropriate leaves...
-	t.tls = deleteTreeLeaves(t.tls, data)
+	tls, removed, _, err := t.deleteTreeLeaves(h, t.tls, data)
+	if err != nil {
+		t.recordDatumErr(&DataError{Op: "DeleteAndReconstruct", Err: err})
+		return
+	}
+	if len(tls) == 0 {
+		t.recordDatumErr(&DataError{Op: "DeleteAndReconstruct", Err: ErrNoData})
+		return
+	}
+	t.pushVersion()
+	unchanged := t.unchangedLeaves(tls)
+	t.beginMutation("DeleteAndReconstruct", 0, len(removed))
+	t.tls = tls
 	// ...and reconstruct the merkle nodes above the remaining ones.
-	t.mns = constructMerkleNodes(t.hash.New(), t.tls)
+	t.beginProgress(0, len(t.tls))
+	defer t.endProgress()
+	t.setNodes(t.reconstructMerkleNodes(h, t.tls, unchanged))
+}
+
+// DatumErr returns the first error that serializing (or, as per the
+// DuplicatePolicy of the merkle tree, adding) the data given to
+// AppendAndReconstruct or DeleteAndReconstruct returned, or that deleting all
+// of the leaves through the latter did, if any.
+func (t *Tree) DatumErr() error {
+	return t.datumErr
+}
+
+func (t *Tree) recordDatumErr(err error) {
+	if t.datumErr == nil {
+		t.datumErr = err
+	}
 }
 
 // VerifyDigest verifies that the given (leaf) hash digest is present in the
 // merkle tree, in which case it returns true and a nil error value.
 //
 // It requires O(L) search among the leaves and O(log2(L)) hash calculations.
+// Note that it looks the digest up among the serialized data of the leaves;
+// see VerifyLeafDigest for looking it up among their digests.
 //
 // If the given hash digest cannot be verified, VerifyDigest returns false.
 // If the given hash digest cannot be found in one of the merkle tree's leaves,
 // VerifyDigest returns false and a non-nil error value.
 func (t *Tree) VerifyDigest(digest []byte) (bool, error) {
 	for leafIndex := range t.tls {
-		if bytes.Compare(digest, t.tls[leafIndex].datum) == 0 {
+		if bytes.Compare(digest, t.leafDatum(&t.tls[leafIndex])) == 0 {
 			return t.verify(leafIndex)
 		}
 	}
-	return false, ErrNoData{}
+	return false, &DataError{Op: "VerifyDigest", Digest: digest, Err: ErrNoData}
+}
+
+// VerifyLeafDigest verifies that a leaf of the given digest is present in the
+// merkle tree, in which case it returns true and a nil error value; unlike
+// VerifyDigest, which looks the digest up among the serialized data, it looks
+// it up among the digests of the leaves, and only verifies their merkle path,
+// without rehashing their data. It is hence suitable for systems that only
+// retain the digests of their data (e.g. along with DigestOnly).
+//
+// It requires O(log2(L)) search among the leaves in digest-only mode (unless
+// they are kept in insertion order), or O(L) otherwise, and O(log2(L)) hash
+// calculations.
+//
+// If the merkle path of the leaf cannot be verified, VerifyLeafDigest returns
+// false. If the given digest cannot be found among the leaves of the merkle
+// tree, VerifyLeafDigest returns false and a non-nil error value.
+func (t *Tree) VerifyLeafDigest(digest []byte) (bool, error) {
+	leafIndex, ok := -1, false
+	if t.digestOnly {
+		leafIndex, ok = t.find(t.tls, digest)
+	} else {
+		for i := range t.tls {
+			if bytes.Equal(digest, t.tls[i].digest) {
+				leafIndex, ok = i, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return false, &DataError{Op: "VerifyLeafDigest", Digest: digest, Err: ErrNoData}
+	}
+	sc := t.getScratch()
+	defer t.putScratch(sc)
+	return t.verifyPath(sc, leafIndex, t.tls[leafIndex].digest)
 }
 
 // VerifyOrderedID verifies that the Datum with the given ordered ID (based on
@@ -174,13 +562,13 @@ func (t *Tree) VerifyDigest(digest []byte) (bool, error) {
 // If the given hash digest cannot be verified, VerifyOrderedID returns false.
 // If the given hash digest cannot be found in one of the merkle tree's leaves,
 // VerifyOrderedID returns false and a non-nil error value.
-func (t *Tree) VerifyOrderedID(orderedID uint) (bool, error) {
+func (t *Tree) VerifyOrderedID(orderedID uint64) (bool, error) {
 	for leafIndex := range t.tls {
 		if t.tls[leafIndex].orderedID == orderedID {
 			return t.verify(leafIndex)
 		}
 	}
-	return false, ErrNoData{}
+	return false, &IDError{Op: "VerifyOrderedID", ID: orderedID, Err: ErrNoData}
 }
 
 // VerifySerializedDatum verifies that the given Datum (given in its serialized
@@ -195,13 +583,100 @@ func (t *Tree) VerifyOrderedID(orderedID uint) (bool, error) {
 // If the given hash digest cannot be found in one of the merkle tree's leaves,
 // VerifySerializedDatum returns false and a non-nil error value.
 func (t *Tree) VerifySerializedDatum(serializedDatum []byte) (bool, error) {
-	leafIndex := sort.Search(len(t.tls), func(i int) bool {
-		return bytes.Compare(t.tls[i].datum, serializedDatum) >= 0
-	})
-	if leafIndex < len(t.tls) && bytes.Compare(t.tls[leafIndex].datum, serializedDatum) == 0 {
+	sc := t.getScratch()
+	leafIndex, ok := t.search(sc.h, serializedDatum)
+	t.putScratch(sc)
+	if ok {
 		return t.verify(leafIndex)
 	}
-	return false, ErrNoData{}
+	return false, &DataError{Op: "VerifySerializedDatum", Datum: serializedDatum, Err: ErrNoData}
+}
+
+// search looks for the leaf that corresponds to the given serialized datum
+// among the leaves of the merkle tree, and returns its index.
+func (t *Tree) search(h hash.Hash, serializedDatum []byte) (int, bool) {
+	return t.find(t.tls, t.leafKey(h, serializedDatum))
+}
+
+// find looks for the leaf with the given key among the given leaves, and
+// returns its index; if the leaves are sorted and the key is not found, the
+// returned index is the one that the key would be inserted at.
+//
+// It requires O(log2(L)) comparisons for sorted leaves, or O(L) otherwise.
+func (t *Tree) find(tls []treeLeaf, key []byte) (int, bool) {
+	if t.insertionOrder {
+		for i := range tls {
+			if bytes.Equal(t.key(&tls[i]), key) {
+				return i, true
+			}
+		}
+		return len(tls), false
+	}
+	leafIndex := sort.Search(len(tls), func(i int) bool {
+		return !t.lessKeys(t.key(&tls[i]), key)
+	})
+	// The keys that are equivalent to the given one (which may differ from
+	// it, as per the comparator given through WithLess) follow.
+	for i := leafIndex; i < len(tls) && !t.lessKeys(key, t.key(&tls[i])); i++ {
+		if bytes.Equal(t.key(&tls[i]), key) {
+			return i, true
+		}
+	}
+	return leafIndex, false
+}
+
+// leafKey returns the key that the leaf of the given serialized datum would be
+// sorted by; i.e. either the serialized datum itself or, in digest-only mode,
+// its digest.
+func (t *Tree) leafKey(h hash.Hash, serializedDatum []byte) []byte {
+	if !t.digestOnly {
+		return serializedDatum
+	}
+	return t.scheme.hashLeaf(h, serializedDatum)
+}
+
+// datumKey is like leafKey, but for the given Datum, which is streamed into
+// the hash function in digest-only mode if it is a StreamSerializer (and the
+// hashing scheme allows for it).
+func (t *Tree) datumKey(h hash.Hash, datum Datum) ([]byte, error) {
+	if sd, ok := datum.(StreamSerializer); ok && t.digestOnly {
+		if digest, ok, err := t.scheme.hashStreamedLeaf(h, nil, sd); ok {
+			return digest, err
+		}
+	}
+	serializedDatum, err := serializeDatum(datum)
+	if err != nil {
+		return nil, err
+	}
+	return t.leafKey(h, serializedDatum), nil
+}
+
+// searchDatum is like search, but for the given Datum; it returns a non-nil
+// error, on behalf of the given operation, if the Datum is either nil, not
+// present in the merkle tree, or fails to be serialized.
+func (t *Tree) searchDatum(op string, datum Datum) (int, error) {
+	if datum == nil {
+		return 0, ErrNoData
+	}
+	sc := t.getScratch()
+	key, err := t.datumKey(sc.h, datum)
+	t.putScratch(sc)
+	if err != nil {
+		return 0, &DataError{Op: op, Err: err}
+	}
+	if leafIndex, ok := t.find(t.tls, key); ok {
+		return leafIndex, nil
+	}
+	return 0, t.keyError(op, key)
+}
+
+// keyError returns the error of the given operation for the given key (see
+// leafKey) that is not present in the merkle tree.
+func (t *Tree) keyError(op string, key []byte) error {
+	if t.digestOnly {
+		return &DataError{Op: op, Digest: key, Err: ErrNoData}
+	}
+	return &DataError{Op: op, Datum: key, Err: ErrNoData}
 }
 
 // VerifyDatum verifies that the given Datum is present in the merkle tree, in
@@ -212,69 +687,70 @@ func (t *Tree) VerifySerializedDatum(serializedDatum []byte) (bool, error) {
 //
 // If the given hash digest cannot be verified, VerifyDatum returns false.
 // If the given hash digest cannot be found in one of the merkle tree's leaves,
-// VerifyDatum returns false and a non-nil error value.
+// or if the given Datum fails to be serialized, VerifyDatum returns false and
+// a non-nil error value.
 func (t *Tree) VerifyDatum(datum Datum) (bool, error) {
-	if datum == nil {
-		return false, ErrNoData{}
+	leafIndex, err := t.searchDatum("VerifyDatum", datum)
+	if err != nil {
+		return false, err
 	}
-	return t.VerifySerializedDatum(datum.Serialize())
+	return t.verify(leafIndex)
 }
 
 func (t *Tree) verify(currentIndex int) (bool, error) {
-	h := t.hash.New()
-	h.Write(t.tls[currentIndex].datum)
-	currentDigest := h.Sum(nil)
-
-	var (
-		siblingDigest, parentDigest []byte
-		parentIndex                 int
-		first, second               []byte
-	)
-	// Verify leaf.
-	if currentIndex%2 == 0 {
-		if currentIndex < len(t.tls)-1 {
-			siblingDigest = t.tls[currentIndex+1].digest
-		} else {
-			siblingDigest = []byte{}
-		}
-		parentIndex = currentIndex / 2
-		parentDigest = t.mns[len(t.mns)-1][parentIndex]
-		first, second = currentDigest, siblingDigest
-	} else {
-		siblingDigest = t.tls[currentIndex-1].digest
-		parentIndex = (currentIndex - 1) / 2
-		parentDigest = t.mns[len(t.mns)-1][parentIndex]
-		first, second = siblingDigest, currentDigest
-	}
-	h.Reset()
-	h.Write(first)
-	h.Write(second)
-	if bytes.Compare(parentDigest, h.Sum(nil)) != 0 {
-		return false, nil
-	}
-
-	// Verify merkle path.
-	for currentLevel := len(t.mns) - 1; currentLevel > 0; currentLevel-- {
-		currentIndex, currentDigest = parentIndex, parentDigest
-		if currentIndex%2 == 0 {
-			if currentIndex < len(t.mns[currentLevel])-1 {
-				siblingDigest = t.mns[currentLevel][currentIndex+1]
-			} else {
-				siblingDigest = []byte{}
+	sc := t.getScratch()
+	defer t.putScratch(sc)
+	currentDigest := t.tls[currentIndex].digest
+	if !t.digestOnly {
+		currentDigest = t.scheme.hashLeaf(sc.h, saltedDatum(t.tls[currentIndex].salt, t.leafDatum(&t.tls[currentIndex])))
+	}
+	return t.verifyPath(sc, currentIndex, currentDigest)
+}
+
+// verifyPath verifies the merkle path of the leaf at the given index, given
+// its (recalculated) digest.
+func (t *Tree) verifyPath(sc *scratch, currentIndex int, currentDigest []byte) (bool, error) {
+	ok, err := t.walkPath(sc, currentIndex, currentDigest)
+	return t.observeVerification(currentIndex, ok, err)
+}
+
+// walkPath recalculates the merkle path of the leaf at the given index, given
+// its (recalculated) digest, and reports whether it matches the merkle nodes
+// of the tree; it hashes through the given scratch, and uses its buffers.
+func (t *Tree) walkPath(sc *scratch, currentIndex int, currentDigest []byte) (bool, error) {
+	h := sc.h
+	if len(t.rows) == 0 {
+		// A single leaf is the merkle root itself.
+		return bytes.Equal(currentDigest, t.tls[currentIndex].digest), nil
+	}
+
+	// Verify the leaf and the merkle path, level by level.
+	var empty [][]byte
+	if t.scheme.padded {
+		empty = t.scheme.emptyRoots(h, len(t.rows))
+	}
+	children := sc.children
+	defer func() { sc.children = children }()
+	for height := 0; height < len(t.rows); height++ {
+		children = children[:0]
+		start, end := t.siblingRange(height, currentIndex)
+		for i := start; i < end; i++ {
+			switch {
+			case i == currentIndex:
+				children = append(children, currentDigest)
+			case i >= t.levelWidth(height):
+				children = append(children, empty[height])
+			default:
+				children = append(children, t.nodeAt(height, i))
 			}
-			parentIndex = currentIndex / 2
-			parentDigest = t.mns[currentLevel-1][parentIndex]
-			first, second = currentDigest, siblingDigest
-		} else {
-			siblingDigest = t.mns[currentLevel][currentIndex-1]
-			parentIndex = (currentIndex - 1) / 2
-			parentDigest = t.mns[currentLevel-1][parentIndex]
-			first, second = siblingDigest, currentDigest
 		}
-		h.Reset()
-		h.Write(first)
-		h.Write(second)
-		if bytes.Compare(parentDigest, h.Sum(nil)) != 0 {
+		parentDigest := t.scheme.hashChildrenTo(h, sc.buf[:0], children)
+		currentIndex /= t.scheme.width()
+		var err error
+		if currentDigest, err = t.node(height+1, currentIndex); err != nil {
+			return false, err
+		}
+		if !bytes.Equal(parentDigest, currentDigest) {
 			return false, nil
 		}
 	}
@@ -284,6 +760,8 @@ func (t *Tree) verify(currentIndex int) (bool, error) {
 
 // Leaves returns a slice of all pieces of Data stored in the merkle tree (in
 // their serialized format) in the order that they were inserted by the user.
+//
+// In digest-only mode, Leaves returns the leaf digests instead.
 func (t *Tree) Leaves() [][]byte {
 	tls2 := make([]treeLeaf, len(t.tls))
 	copy(tls2, t.tls)
@@ -295,114 +773,442 @@ func (t *Tree) Leaves() [][]byte {
 	retSeq := make([]byte, 0)
 	currentIndex := 0
 	for i := range tls2 {
-		retSeq = append(retSeq, tls2[i].datum...)
-		ret[i] = retSeq[currentIndex : currentIndex+len(tls2[i].datum)]
-		currentIndex += len(tls2[i].datum)
+		key := t.key(&tls2[i])
+		retSeq = append(retSeq, key...)
+		ret[i] = retSeq[currentIndex : currentIndex+len(key)]
+		currentIndex += len(key)
+	}
+	return ret
+}
+
+// LeafByID returns the serialized datum (or, in digest-only mode, the digest)
+// of the leaf with the given ordered ID.
+//
+// It requires O(L) search among the leaves, and returns a non-nil error if
+// there is no leaf with the given ordered ID.
+func (t *Tree) LeafByID(orderedID uint64) ([]byte, error) {
+	for i := range t.tls {
+		if t.tls[i].orderedID == orderedID {
+			return copyBytes(t.key(&t.tls[i])), nil
+		}
+	}
+	return nil, &IDError{Op: "LeafByID", ID: orderedID, Err: ErrNoData}
+}
+
+// LeafDigest returns the digest of the leaf at the given index among the
+// (sorted) leaves of the merkle tree.
+//
+// It returns a non-nil error if the given index is out of range.
+func (t *Tree) LeafDigest(index int) ([]byte, error) {
+	if index < 0 || index >= len(t.tls) {
+		return nil, &IndexError{Op: "LeafDigest", Index: index, Err: ErrNoData}
+	}
+	return copyBytes(t.tls[index].digest), nil
+}
+
+// IndexOf returns the index of the leaf of the given Datum among the (sorted)
+// leaves of the merkle tree.
+//
+// It requires O(log2(L)) search among the leaves (or O(L), if the leaves are
+// kept in insertion order), and returns a non-nil error if the given Datum is
+// either nil, not present in the merkle tree, or fails to be serialized.
+func (t *Tree) IndexOf(datum Datum) (int, error) {
+	return t.searchDatum("IndexOf", datum)
+}
+
+// Node returns a copy of the digest of the node at the given level and index
+// of the merkle tree, where level 0 holds the leaves and level Height()-1
+// holds the merkle root.
+//
+// It returns a non-nil error if there is no such node.
+func (t *Tree) Node(level, index int) ([]byte, error) {
+	if level < 0 || level > len(t.rows) || index < 0 || index >= t.levelWidth(level) {
+		return nil, &IndexError{Op: "Node", Index: index, Err: ErrNoData}
+	}
+	digest, err := t.node(level, index)
+	if err != nil {
+		return nil, err
+	}
+	return copyBytes(digest), nil
+}
+
+// Level returns a copy of the digests of the nodes at the given level of the
+// merkle tree, from left to right, where level 0 holds the leaves and level
+// Height()-1 holds the merkle root. It returns nil for any other level.
+func (t *Tree) Level(level int) [][]byte {
+	if level < 0 || level > len(t.rows) {
+		return nil
+	}
+	ret := make([][]byte, t.levelWidth(level))
+	for i := range ret {
+		ret[i] = copyBytes(t.nodeAt(level, i))
 	}
 	return ret
 }
 
-func appendTreeLeaves(h hash.Hash, oldTreeLeaves []treeLeaf, newData []Datum) (newTreeLeaves []treeLeaf) {
-	newTreeLeaves = make([]treeLeaf, len(oldTreeLeaves), len(oldTreeLeaves)+len(newData))
+// key returns the key that the leaves of the merkle tree are sorted by; i.e.
+// either the serialized datum or, in digest-only mode, the digest.
+func (t *Tree) key(tl *treeLeaf) []byte {
+	if t.digestOnly {
+		return tl.digest
+	}
+	return t.leafDatum(tl)
+}
+
+// appendTreeLeaves returns the given old leaves followed by the leaves of the
+// given new data, sorted, along with the ordered IDs of the latter (in the
+// order that their data were given in); the data that are already present are
+// skipped or rejected as per the DuplicatePolicy of the merkle tree, and the
+// new leaves are assigned the ordered IDs that follow NextID.
+func (t *Tree) appendTreeLeaves(h hash.Hash, oldTreeLeaves []treeLeaf, newData []Datum) ([]treeLeaf, []uint64, error) {
+	if err := checkCapacity(len(oldTreeLeaves), len(newData), t.nextID); err != nil {
+		return nil, nil, err
+	}
+	newTreeLeaves := make([]treeLeaf, len(oldTreeLeaves), len(oldTreeLeaves)+len(newData))
 	copy(newTreeLeaves, oldTreeLeaves)
-	for i := range newData {
-		serializedDatum := newData[i].Serialize()
-		h.Reset()
-		h.Write(serializedDatum)
-		newTreeLeaves = append(newTreeLeaves, treeLeaf{
-			digest:    h.Sum(nil),
-			datum:     serializedDatum,
-			orderedID: uint(len(oldTreeLeaves) + i),
+	ids := make([]uint64, len(newData))
+	dups := t.newDuplicateSet(oldTreeLeaves)
+	a := newDigestArena(h.Size(), len(newData))
+	lanes := 1
+	if m := t.usableMultiHasher(); m != nil && m.Lanes() > 1 {
+		lanes = m.Lanes()
+	}
+	for start := 0; start < len(newData); start += lanes {
+		end := start + lanes
+		if end > len(newData) {
+			end = len(newData)
+		}
+		tls, err := t.newDatumLeaves(h, a, newData[start:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range tls {
+			tls[i].orderedID = t.nextID + uint64(len(newTreeLeaves)-len(oldTreeLeaves))
+			var ok bool
+			if ids[start+i], ok, err = dups.add(&tls[i]); err != nil {
+				return nil, nil, err
+			} else if ok {
+				newTreeLeaves = append(newTreeLeaves, tls[i])
+			}
+			t.advanceProgress(1)
+		}
+	}
+	t.sortTreeLeaves(newTreeLeaves)
+	return newTreeLeaves, ids, nil
+}
+
+// newDatumLeaf is like newTreeLeaf, but for the given Datum, which is streamed
+// into the hash function in digest-only mode if it is a StreamSerializer (and
+// the hashing scheme allows for it).
+func (t *Tree) newDatumLeaf(h hash.Hash, a *digestArena, datum Datum, orderedID uint64) (treeLeaf, error) {
+	if sd, ok := datum.(StreamSerializer); ok && t.digestOnly {
+		if digest, ok, err := t.scheme.hashStreamedLeaf(h, a.next(), sd); ok {
+			return treeLeaf{digest: digest, orderedID: orderedID}, err
+		}
+	}
+	serializedDatum, err := serializeDatum(datum)
+	if err != nil {
+		return treeLeaf{}, err
+	}
+	return t.newTreeLeaf(h, a, serializedDatum, orderedID), nil
+}
+
+// newTreeLeaf hashes the given serialized datum to create a new leaf, which
+// retains the serialized datum too, unless in digest-only mode; a fresh salt
+// is hashed along with it, if the leaves are salted. The digest is laid out in
+// the given digestArena, if any.
+func (t *Tree) newTreeLeaf(h hash.Hash, a *digestArena, serializedDatum []byte, orderedID uint64) treeLeaf {
+	tl := treeLeaf{
+		datum:     serializedDatum,
+		orderedID: orderedID,
+	}
+	if t.scheme.salted && !t.digestOnly {
+		tl.salt = newSalt(h.Size())
+	}
+	tl.digest = t.scheme.hashLeafTo(h, a.next(), saltedDatum(tl.salt, serializedDatum))
+	t.retainDatum(&tl)
+	return tl
+}
+
+// retainDatum replaces the serialized datum of the given freshly hashed leaf
+// with the one that it retains; i.e. none in digest-only mode.
+func (t *Tree) retainDatum(tl *treeLeaf) {
+	if t.digestOnly {
+		tl.datum = nil
+	} else {
+		tl.datum = t.internDatum(t.storedDatum(tl.datum))
+	}
+}
+
+// sortTreeLeaves sorts the given leaves by their keys, unless the merkle tree
+// keeps its leaves in insertion order. Leaves whose keys are equivalent as per
+// the comparator given through WithLess retain their relative order.
+func (t *Tree) sortTreeLeaves(tls []treeLeaf) {
+	if t.insertionOrder {
+		return
+	}
+	if t.less != nil {
+		sort.SliceStable(tls, func(i, j int) bool {
+			return t.less(t.key(&tls[i]), t.key(&tls[j]))
 		})
+		return
 	}
-	sort.Slice(newTreeLeaves, func(i, j int) bool {
-		return bytes.Compare(newTreeLeaves[i].datum, newTreeLeaves[j].datum) == -1
+	sort.Slice(tls, func(i, j int) bool {
+		return bytes.Compare(t.key(&tls[i]), t.key(&tls[j])) == -1
 	})
-	return
 }
 
-func deleteTreeLeaves(oldTreeLeaves []treeLeaf, delData []Datum) (newTreeLeaves []treeLeaf) {
-	// Serialize all data to be deleted.
+// encodedInsertionOrder reports whether the encodings of the merkle tree keep
+// its leaves in the order they are encoded in; i.e. unless they are sorted in
+// lexicographic order.
+func (t *Tree) encodedInsertionOrder() bool {
+	return t.insertionOrder || t.less != nil
+}
+
+// lessKeys reports whether the leaf of key a is sorted before the one of key
+// b; i.e. as per the comparator given through WithLess, if any, or in
+// lexicographic order otherwise.
+func (t *Tree) lessKeys(a, b []byte) bool {
+	if t.less != nil {
+		return t.less(a, b)
+	}
+	return bytes.Compare(a, b) < 0
+}
+
+// deleteTreeLeaves returns the given leaves without the ones of the given
+// data, along with the ordered IDs of the removed leaves and the keys (see
+// leafKey) of the data that were not found among them, if any.
+func (t *Tree) deleteTreeLeaves(h hash.Hash, oldTreeLeaves []treeLeaf, delData []Datum) (newTreeLeaves []treeLeaf, removed []uint64, missing [][]byte, err error) {
+	// Serialize all data to be deleted (or hash them, in digest-only mode).
 	delSerializedData := make([][]byte, 0, len(delData))
 	for i := range delData {
-		delSerializedData = append(delSerializedData, delData[i].Serialize())
+		key, err := t.datumKey(h, delData[i])
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		delSerializedData = append(delSerializedData, key)
 	}
 	// Create a copy of oldTreeLeaves to process it.
 	oldTls := make([]treeLeaf, len(oldTreeLeaves))
 	copy(oldTls, oldTreeLeaves)
 	// Find each of the serializedData to be deleted and remove them from the copy.
 	for i := range delSerializedData {
-		j := sort.Search(len(oldTls), func(k int) bool {
-			return bytes.Compare(oldTls[k].datum, delSerializedData[i]) >= 0
-		})
-		if j < len(oldTls) && bytes.Compare(oldTls[j].datum, delSerializedData[i]) == 0 {
+		if j, ok := t.find(oldTls, delSerializedData[i]); ok {
+			removed = append(removed, oldTls[j].orderedID)
 			oldTls = append(oldTls[:j], oldTls[j+1:]...)
+		} else {
+			missing = append(missing, delSerializedData[i])
 		}
 	}
-	// Sort oldTls by orderedID, and reset the orderedIDs.
-	sort.Slice(oldTls, func(i, j int) bool {
-		return oldTls[i].orderedID < oldTls[j].orderedID
-	})
-	for i := range oldTls {
-		oldTls[i].orderedID = uint(i)
-	}
 	// Copy oldTls to a new slice to avoid wasting capacity.
-	newTreeLeaves = make([]treeLeaf, len(oldTreeLeaves)-len(delData))
+	newTreeLeaves = make([]treeLeaf, len(oldTls))
 	copy(newTreeLeaves, oldTls)
-	// Finally, sort newTreeLeaves by serializedDatum again.
-	sort.Slice(newTreeLeaves, func(i, j int) bool {
-		return bytes.Compare(newTreeLeaves[i].datum, newTreeLeaves[j].datum) == -1
-	})
-	return
+	// Finally, sort newTreeLeaves by serializedDatum (or digest) again.
+	t.sortTreeLeaves(newTreeLeaves)
+	return newTreeLeaves, removed, missing, nil
 }
 
-// mns[0][0] --> ROOT
-// mns[1][0] mns[1][1]
-// mns[2][0] mns[2][1] mns[2][2] mns[2][3]
-// mns[3][0] mns[3][1] mns[3][2] mns[3][3] mns[3][4] mns[3][5] mns[3][6] mns[3][7]
-//  . . .
-func constructMerkleNodes(h hash.Hash, tls []treeLeaf) (mns [][][]byte) {
-	numMerkleNodes, rowSizes := calculateMerkleNumbers(len(tls))
-	mnsSeq := make([]byte, 0, h.Size()*numMerkleNodes)
-	mns = make([][][]byte, len(rowSizes))
-	mnCount := 0
-	for i := 0; i < len(rowSizes); i++ {
-		mns[i] = make([][]byte, rowSizes[len(rowSizes)-1-i])
-		for j := 0; j < rowSizes[len(rowSizes)-1-i]; j++ {
-			mns[i][j] = mnsSeq[mnCount*h.Size() : (mnCount+1)*h.Size()]
-			if i == len(rowSizes)-1 {
-				h.Reset()
-				h.Write(tls[2*j].digest)
-				if 2*j+1 < len(tls) {
-					h.Write(tls[2*j+1].digest)
+// constructMerkleNodes constructs the merkle nodes over the given leaves, in
+// the layout of heapNodes:
+//
+//	n.at(3, 0) --> ROOT
+//	n.at(2, 0) n.at(2, 1)
+//	n.at(1, 0) n.at(1, 1) n.at(1, 2) n.at(1, 3)
+//	 . . .
+func (t *Tree) constructMerkleNodes(h hash.Hash, tls []treeLeaf) *heapNodes {
+	return t.reconstructMerkleNodes(h, tls, 0)
+}
+
+// reconstructMerkleNodes is like constructMerkleNodes, but the first
+// unchanged of the given leaves are known to be the same as the current ones
+// of the merkle tree; hence, the current merkle nodes over complete subtrees
+// of them are reused rather than rehashed, and only the ones to their right
+// (e.g. along the path to the appended leaves) are calculated.
+//
+// If the merkle tree has a NodeStore of its own, the reused merkle nodes are
+// left in it rather than copied, and the returned heapNodes only hold the
+// calculated ones (see newPartialHeapNodes).
+func (t *Tree) reconstructMerkleNodes(h hash.Hash, tls []treeLeaf, unchanged int) *heapNodes {
+	return t.rebuildMerkleNodes(h, tls, unchanged, t.userStore)
+}
+
+// rebuildMerkleNodes is like reconstructMerkleNodes, but whether the returned
+// heapNodes only hold the calculated merkle nodes is given explicitly.
+func (t *Tree) rebuildMerkleNodes(h hash.Hash, tls []treeLeaf, unchanged int, partial bool) *heapNodes {
+	if t.metrics != nil || t.logger != nil {
+		defer t.observeReconstruction(len(tls), unchanged, time.Now())
+	}
+	arity := t.scheme.width()
+	_, rowSizes := t.merkleNumbers(len(tls))
+	var empty [][]byte
+	if t.scheme.padded {
+		empty = t.scheme.emptyRoots(h, len(rowSizes))
+	}
+	var n *heapNodes
+	if partial {
+		n = newPartialHeapNodes(rowSizes, t.reusableNodes(rowSizes, unchanged), h.Size())
+	} else {
+		n = t.newHeapNodes(rowSizes, h.Size())
+	}
+	n.unchanged = unchanged
+	children := make([][]byte, 0, arity)
+	buf := make([]byte, 0, h.Size())
+	span := 1
+	for height := 1; height <= len(rowSizes); height++ {
+		if span <= unchanged {
+			span *= arity
+		}
+		for j := n.firstHeld(height); j < rowSizes[height-1]; j++ {
+			if !partial {
+				if digest := t.reusableNode(height, j, span, unchanged); digest != nil {
+					copy(n.at(height, j), digest)
+					continue
+				}
+			}
+			children = children[:0]
+			if height == 1 {
+				for k := arity * j; k < arity*(j+1) && k < len(tls); k++ {
+					children = append(children, tls[k].digest)
+				}
+			} else {
+				for k := arity * j; k < arity*(j+1) && k < rowSizes[height-2]; k++ {
+					if k < n.firstHeld(height-1) {
+						children = append(children, t.storedNode(h, tls, height-1, k))
+					} else {
+						children = append(children, n.at(height-1, k))
+					}
 				}
-				digest := h.Sum(nil)
-				copy(mns[i][j], digest)
 			}
-			mnCount += 1
+			for empty != nil && len(children) < arity {
+				children = append(children, empty[height-1])
+			}
+			copy(n.at(height, j), t.scheme.hashChildrenTo(h, buf[:0], children))
 		}
+		t.advanceProgress(rowSizes[height-1])
 	}
-	for i := len(rowSizes) - 2; i >= 0; i-- {
-		for j := 0; j < rowSizes[len(rowSizes)-1-i]; j++ {
-			h.Reset()
-			h.Write(mns[i+1][2*j])
-			if 2*j+1 < len(mns[i+1]) {
-				h.Write(mns[i+1][2*j+1])
-			}
-			digest := h.Sum(nil)
-			copy(mns[i][j], digest)
+	return n
+}
+
+// reusableNode returns the current digest of the node at the given height and
+// index of the merkle tree, which spans the given number of leaves, if they
+// are all among the first unchanged ones; otherwise (or if the NodeStore of
+// the tree fails to provide it), it returns nil.
+func (t *Tree) reusableNode(height, index, span, unchanged int) []byte {
+	if height > len(t.rows) || span > unchanged || (index+1)*span > unchanged {
+		return nil
+	}
+	return t.nodeAt(height, index)
+}
+
+// reusableNodes returns the number of the first nodes at each height of the
+// given sizes whose current digests can be reused (see reusableNode), given
+// the number of the first unchanged leaves.
+func (t *Tree) reusableNodes(rowSizes []int, unchanged int) []int {
+	arity := t.scheme.width()
+	first := make([]int, len(rowSizes))
+	span := 1
+	for height := 1; height <= len(rowSizes) && height <= len(t.rows); height++ {
+		if span > unchanged/arity {
+			break
+		}
+		span *= arity
+		first[height-1] = min(unchanged/span, rowSizes[height-1])
+	}
+	return first
+}
+
+// storedNode returns the current digest of the node at the given height and
+// index of the merkle tree, all of whose leaves are among the first unchanged
+// ones of the given leaves; if its NodeStore fails to provide it, it is
+// recalculated out of the ones below it.
+func (t *Tree) storedNode(h hash.Hash, tls []treeLeaf, height, index int) []byte {
+	if height == 0 {
+		return tls[index].digest
+	}
+	if digest, err := t.store.Get(height, index); err == nil {
+		return digest
+	}
+	arity := t.scheme.width()
+	children := make([][]byte, 0, arity)
+	for k := arity * index; k < arity*(index+1); k++ {
+		children = append(children, t.storedNode(h, tls, height-1, k))
+	}
+	return t.scheme.hashChildrenTo(h, nil, children)
+}
+
+// unchangedLeaves returns the number of the first of the given leaves that
+// are the same as the current ones of the merkle tree.
+func (t *Tree) unchangedLeaves(tls []treeLeaf) int {
+	if len(t.rows) == 0 {
+		return 0
+	}
+	n := 0
+	for n < len(tls) && n < len(t.tls) && bytes.Equal(tls[n].digest, t.tls[n].digest) {
+		n++
+	}
+	return n
+}
+
+// merkleNumbers is like calculateMerkleNumbers, but for the layout of the
+// merkle tree; i.e. padded trees (see PadToPowerOfTwo) have lone merkle nodes
+// above their leaves up to their depth.
+func (t *Tree) merkleNumbers(numLeaves int) (numMerkleNodes int, mns []int) {
+	numMerkleNodes, mns = calculateMerkleNumbers(numLeaves, t.scheme.width())
+	if t.scheme.padded {
+		for depth := t.scheme.paddedDepth(numLeaves); len(mns) < depth; numMerkleNodes++ {
+			mns = append(mns, 1)
 		}
 	}
 	return
 }
 
-func calculateMerkleNumbers(numLeaves int) (numMerkleNodes int, mns []int) {
-	for numLeaves > 1 {
-		if numLeaves%2 == 1 {
-			numLeaves = (numLeaves / 2) + 1
-		} else {
-			numLeaves = numLeaves / 2
+// checkPadding returns a non-nil error if the merkle tree is padded (see
+// PadToPowerOfTwo) and either its fixed depth does not allow for the given
+// number of leaves (or for a number of leaves that fits in an int at all), or
+// the digest of its empty leaves is not of the size that its hash function
+// produces.
+func (t *Tree) checkPadding(numLeaves int) error {
+	if !t.scheme.padded {
+		return nil
+	}
+	if t.scheme.emptyLeaf != nil && len(t.scheme.emptyLeaf) != t.newHasher().Size() {
+		return ErrInvalidDigest
+	}
+	if t.scheme.depth == 0 {
+		return nil
+	}
+	capacity := 1
+	for i := 0; i < t.scheme.depth; i++ {
+		if capacity > math.MaxInt/t.scheme.width() {
+			return ErrInvalidRange
 		}
+		capacity *= t.scheme.width()
+	}
+	if capacity < numLeaves {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// paddedNumLeaves returns the number of leaves of the merkle tree, including
+// the empty ones of a padded tree (see PadToPowerOfTwo).
+func (t *Tree) paddedNumLeaves() int {
+	if !t.scheme.padded {
+		return len(t.tls)
+	}
+	numLeaves := 1
+	for range t.rows {
+		numLeaves *= t.scheme.width()
+	}
+	return numLeaves
+}
+
+// calculateMerkleNumbers returns the total number of merkle nodes above the
+// given number of leaves, along with the number of them at each level, from
+// the parents of the leaves up to the root, given the arity of the tree.
+func calculateMerkleNumbers(numLeaves, arity int) (numMerkleNodes int, mns []int) {
+	for numLeaves > 1 {
+		numLeaves = (numLeaves + arity - 1) / arity
 		mns = append(mns, numLeaves)
 		numMerkleNodes += numLeaves
 	}
@@ -15,9 +15,11 @@
 package merkle
 
 import (
+	"bytes"
 	"crypto"
 	_ "crypto/sha1"
 	_ "crypto/sha256"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -53,7 +55,7 @@ func TestNewTree00(t *testing.T) {
 	if _, err := NewTree(crypto.SHA512, alpha); err != nil {
 		t.Logf("got (%v), as expected", err)
 	} else {
-		t.Fatalf("want (%v); got %v", ErrHashUnavailable{}, err)
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
 	}
 }
 func TestNewTree01(t *testing.T) {
@@ -61,11 +63,11 @@ func TestNewTree01(t *testing.T) {
 	if _, err := NewTree(crypto.SHA256, nilData...); err != nil {
 		t.Logf("got (%v), as expected", err)
 	} else {
-		t.Fatalf("want (%v); got %v", ErrNoData{}, err)
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
 	}
 }
 func TestNewTree02(t *testing.T) {
-	t.Log(calculateMerkleNumbers(24))
+	t.Log(calculateMerkleNumbers(24, 2))
 
 	tree, err := NewTree(crypto.SHA256,
 		alpha, beta, gamma, delta, epsilon, zeta, eta, theta, yota, kappa, lambda,
@@ -81,8 +83,9 @@ func TestNewTree02(t *testing.T) {
 	t.Log("tree.NumLeaves():", tree.NumLeaves())
 
 	for i := 0; i < tree.Height()-1; i++ {
-		for j := 0; j < len(tree.mns[i]); j++ {
-			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), tree.mns[i][j])
+		level := tree.Level(tree.Height() - 1 - i)
+		for j := 0; j < len(level); j++ {
+			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), level[j])
 		}
 	}
 	for i := 0; i < len(tree.tls); i++ {
@@ -116,6 +119,33 @@ func TestVerify00(t *testing.T) {
 	t.Logf("\t\t\t%v", v)
 }
 
+func TestVerifyLeafDigest00(t *testing.T) {
+	for _, opts := range [][]Option{nil, {DigestOnly()}, {DigestOnly(), InsertionOrder()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tree.tls {
+			digest, err := tree.LeafDigest(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v, err := tree.VerifyLeafDigest(digest); err != nil || !v {
+				t.Fatalf("ERROR while verifying %x: (%v, %v)", digest, v, err)
+			}
+		}
+		if v, err := tree.VerifyLeafDigest(tree.MerkleRoot()); !errors.Is(err, ErrNoData) || v {
+			t.Fatalf("want (false, %v); got (%v, %v)", ErrNoData, v, err)
+		}
+
+		// Tampering with a merkle node fails the verification.
+		tree.store.(*heapNodes).at(1, 0)[0] ^= 0xff
+		if v, err := tree.VerifyLeafDigest(tree.tls[0].digest); err != nil || v {
+			t.Fatalf("want (false, <nil>); got (%v, %v)", v, err)
+		}
+	}
+}
+
 func TestLeaves00(t *testing.T) {
 	tree, err := NewTree(crypto.SHA256, grAlphabet...)
 	if err != nil {
@@ -133,8 +163,9 @@ func TestLeaves00(t *testing.T) {
 
 	// Print the tree.
 	for i := 0; i < tree.Height()-1; i++ {
-		for j := 0; j < len(tree.mns[i]); j++ {
-			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), tree.mns[i][j])
+		level := tree.Level(tree.Height() - 1 - i)
+		for j := 0; j < len(level); j++ {
+			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), level[j])
 		}
 	}
 	for i := 0; i < len(tree.tls); i++ {
@@ -189,8 +220,9 @@ func TestAppendReconstruct01(t *testing.T) {
 
 	// Print the tree.
 	for i := 0; i < tree.Height()-1; i++ {
-		for j := 0; j < len(tree.mns[i]); j++ {
-			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), tree.mns[i][j])
+		level := tree.Level(tree.Height() - 1 - i)
+		for j := 0; j < len(level); j++ {
+			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), level[j])
 		}
 	}
 	for i := 0; i < len(tree.tls); i++ {
@@ -225,6 +257,114 @@ func TestAppendReconstruct01(t *testing.T) {
 	t.Logf("\t\t\t%v", v)
 }
 
+func TestAppendReconstruct02(t *testing.T) {
+	data := append(append([]Datum{}, grAlphabet...), enAlphabetCap...)
+	for _, opts := range [][]Option{
+		{InsertionOrder()},
+		{},
+		{RFC6962()},
+		{WithArity(3), InsertionOrder()},
+		{PadToPowerOfTwo(nil), InsertionOrder()},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, data[:1], opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for n := 2; n <= len(data); n++ {
+			tree.AppendAndReconstruct(data[n-1])
+			want, err := NewTreeWithOptions(crypto.SHA256, data[:n], opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+				t.Fatalf("%d leaves: want (%x); got %x", n, want.MerkleRoot(), tree.MerkleRoot())
+			}
+		}
+		for n := len(data) - 1; n > 0; n-- {
+			tree.DeleteAndReconstruct(data[n])
+			want, err := NewTreeWithOptions(crypto.SHA256, data[:n], opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+				t.Fatalf("%d leaves: want (%x); got %x", n, want.MerkleRoot(), tree.MerkleRoot())
+			}
+		}
+	}
+}
+
+func TestAppendReconstruct03(t *testing.T) {
+	// The merkle nodes over complete subtrees of the leaves that precede
+	// the appended ones are reused rather than rehashed; tampering with
+	// them shows which ones.
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:6], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := tree.store.(*heapNodes)
+	n.at(1, 0)[0] ^= 0xff // over leaves 0 and 1
+	n.at(2, 0)[0] ^= 0xff // over leaves 0 to 3
+	n.at(2, 1)[0] ^= 0xff // over leaves 4 to 7, incomplete
+	tree.AppendAndReconstruct(grAlphabet[6])
+
+	want, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:7], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		level, index int
+		tampered     bool
+	}{{1, 0, true}, {1, 1, false}, {1, 2, false}, {1, 3, false}, {2, 0, true}, {2, 1, false}, {3, 0, true}} {
+		node, err := tree.Node(tc.level, tc.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantNode, err := want.Node(tc.level, tc.index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tampered := !bytes.Equal(node, wantNode); tampered != tc.tampered {
+			t.Fatalf("node (%d, %d): want (%t); got %t", tc.level, tc.index, tc.tampered, tampered)
+		}
+	}
+}
+
+func TestAppend00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, root, err := tree.Append(grAlphabet[5], grAlphabet[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("ids: %v, root: %x", ids, root)
+	if len(ids) != 2 || ids[0] != 5 || ids[1] != 6 || !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want ([5 6], %x); got (%v, %x)", tree.MerkleRoot(), ids, root)
+	}
+	want, err := NewTree(crypto.SHA256, grAlphabet[:7]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(root, want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), root)
+	}
+	for i, id := range ids {
+		if leaf, err := tree.LeafByID(id); err != nil || !bytes.Equal(leaf, grAlphabet[5+i].Serialize()) {
+			t.Fatalf("want (%s, <nil>); got (%s, %v)", grAlphabet[5+i].Serialize(), leaf, err)
+		}
+	}
+
+	for _, data := range [][]Datum{nil, {grAlphabet[7], nil}} {
+		if _, _, err := tree.Append(data...); !errors.Is(err, ErrNoData) {
+			t.Fatalf("want (%v); got %v", ErrNoData, err)
+		}
+	}
+	if tree.NumLeaves() != 7 || tree.NextID() != 7 {
+		t.Fatalf("want intact tree; got %d leaves, next ID %d", tree.NumLeaves(), tree.NextID())
+	}
+}
+
 func TestDeleteAndReconstruct00(t *testing.T) {
 	tree, err := NewTree(crypto.SHA256, grAlphabet...)
 	if err != nil {
@@ -272,8 +412,9 @@ func TestDeleteAndReconstruct01(t *testing.T) {
 
 	// Print the tree.
 	for i := 0; i < tree.Height()-1; i++ {
-		for j := 0; j < len(tree.mns[i]); j++ {
-			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), tree.mns[i][j])
+		level := tree.Level(tree.Height() - 1 - i)
+		for j := 0; j < len(level); j++ {
+			t.Logf("(i=%2d,j=%2d)%s%x", i, j, strings.Repeat(" ", (i+1)*4), level[j])
 		}
 	}
 	for i := 0; i < len(tree.tls); i++ {
@@ -300,3 +441,215 @@ func TestDeleteAndReconstruct01(t *testing.T) {
 	}
 	t.Logf("\t\t\t%v", v)
 }
+func TestDeleteAndReconstruct02(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MerkleRoot()
+	tree.DeleteAndReconstruct(grAlphabet...)
+	if err = tree.DatumErr(); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if tree.NumLeaves() != len(grAlphabet) || !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want (%d, %x); got (%d, %x)", len(grAlphabet), root, tree.NumLeaves(), tree.MerkleRoot())
+	}
+}
+
+func TestDelete00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:7]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := tree.Delete(grAlphabet[5], grAlphabet[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 || removed[0] != 5 || removed[1] != 2 {
+		t.Fatalf("want ([5 2]); got %v", removed)
+	}
+	want, err := NewTree(crypto.SHA256, grAlphabet[0], grAlphabet[1], grAlphabet[3], grAlphabet[4], grAlphabet[6])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+
+	// Missing data are reported, without mis-sizing the remaining leaves.
+	removed, err = tree.Delete(grAlphabet[2], grAlphabet[6], grAlphabet[10])
+	t.Logf("removed: %v, err: %v", removed, err)
+	if !errors.Is(err, ErrNoData) || len(removed) != 1 || removed[0] != 6 {
+		t.Fatalf("want ([6], %v); got (%v, %v)", ErrNoData, removed, err)
+	}
+	if tree.NumLeaves() != 4 {
+		t.Fatalf("want 4 leaves; got %d", tree.NumLeaves())
+	}
+	version := tree.Version()
+	if removed, err = tree.Delete(grAlphabet[2]); !errors.Is(err, ErrNoData) || len(removed) != 0 || tree.Version() != version {
+		t.Fatalf("want ([], %v) and version %d; got (%v, %v) and %d", ErrNoData, version, removed, err, tree.Version())
+	}
+	if _, err = tree.Delete(grAlphabet[0], grAlphabet[1], grAlphabet[3], grAlphabet[4]); !errors.Is(err, ErrNoData) || tree.NumLeaves() != 4 {
+		t.Fatalf("want (%v) and 4 leaves; got %v and %d", ErrNoData, err, tree.NumLeaves())
+	}
+}
+
+func TestNewTreeFromDigests00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := crypto.SHA256.New()
+	digests := make([][]byte, len(grAlphabet))
+	for i, datum := range grAlphabet {
+		h.Reset()
+		h.Write(datum.Serialize())
+		digests[i] = h.Sum(nil)
+	}
+	tree2, err := NewTreeFromDigests(crypto.SHA256, digests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot():  %x", tree.MerkleRoot())
+	t.Logf("tree2.MerkleRoot(): %x", tree2.MerkleRoot())
+	if string(tree.MerkleRoot()) != string(tree2.MerkleRoot()) {
+		t.Fatalf("roots differ")
+	}
+	if v, err := tree2.VerifyDatum(psi); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", psi, v, err)
+	}
+}
+func TestNewTreeFromDigests01(t *testing.T) {
+	if _, err := NewTreeFromDigests(crypto.SHA256, nil); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := NewTreeFromDigests(crypto.SHA256, [][]byte{[]byte("short")}); err == nil {
+		t.Fatalf("want (%v); got %v", ErrInvalidDigest, err)
+	} else {
+		t.Logf("got (%v), as expected", err)
+	}
+}
+
+func TestSingleLeaf00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+	if v, err := tree.VerifyDatum(alpha); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", alpha, v, err)
+	}
+	p, err := tree.ProveDatum(alpha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Verify(tree.MerkleRoot()) {
+		t.Fatalf("proof of the single leaf failed")
+	}
+}
+
+func TestLeafAccessors00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, word := range grAlphabet {
+		leaf, err := tree.LeafByID(uint64(id))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(leaf, word.Serialize()) {
+			t.Fatalf("want (%q); got %q", word.Serialize(), leaf)
+		}
+		index, err := tree.IndexOf(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest, err := tree.LeafDigest(index)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := crypto.SHA256.New()
+		h.Write(word.Serialize())
+		if !bytes.Equal(digest, h.Sum(nil)) {
+			t.Fatalf("want (%x); got %x", h.Sum(nil), digest)
+		}
+	}
+	if _, err = tree.LeafByID(uint64(len(grAlphabet))); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err = tree.LeafDigest(-1); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err = tree.IndexOf(kk); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err = tree.IndexOf(nil); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+
+func TestNodeAccessors00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	widths := []int{5, 3, 2, 1}
+	for level, width := range widths {
+		nodes := tree.Level(level)
+		if len(nodes) != width {
+			t.Fatalf("want (%d) nodes at level %d; got %d", width, level, len(nodes))
+		}
+		for i := range nodes {
+			node, err := tree.Node(level, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(node, nodes[i]) {
+				t.Fatalf("want (%x); got %x", nodes[i], node)
+			}
+		}
+	}
+	root, _ := tree.Node(3, 0)
+	if !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), root)
+	}
+
+	// The digests returned must be copies.
+	root[0] ^= 0xff
+	tree.Level(1)[0][0] ^= 0xff
+	if v, err := tree.VerifyDatum(A); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", A, v, err)
+	}
+
+	if _, err = tree.Node(4, 0); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err = tree.Node(1, 3); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if tree.Level(-1) != nil || tree.Level(4) != nil {
+		t.Fatal("want no nodes out of range")
+	}
+}
+
+func TestMerkleRoot00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := tree.MerkleRoot()
+	if !bytes.Equal(want, tree.UnsafeMerkleRoot()) {
+		t.Fatalf("want (%x); got %x", want, tree.UnsafeMerkleRoot())
+	}
+
+	// The root returned must be a copy.
+	tree.MerkleRoot()[0] ^= 0xff
+	if !bytes.Equal(want, tree.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", want, tree.MerkleRoot())
+	}
+	if v, err := tree.VerifyDatum(A); err != nil || !v {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", A, v, err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package merklepb provides the Protocol Buffers messages defined in
+// merkle.proto, so that merkle artifacts can be exchanged over gRPC or any
+// other protobuf-based transport, along with conversions between them and
+// their counterparts of the merkle package.
+//
+// The messages are generated by protoc-gen-go; they are encoded and decoded
+// through the google.golang.org/protobuf/proto package.
+package merklepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative merkle.proto
+
+import (
+	"crypto"
+	"math"
+
+	"github.com/ckatsak/merkle"
+)
+
+// FromProof converts the given merkle.Proof to its protobuf counterpart.
+func FromProof(p *merkle.Proof) *Proof {
+	s := p.Scheme()
+	pbp := &Proof{
+		Hash:        uint32(p.Hash),
+		LeafIndex:   uint64(p.LeafIndex),
+		NumLeaves:   uint64(p.NumLeaves),
+		LeafDigest:  p.LeafDigest,
+		Siblings:    p.Siblings,
+		Rfc6962:     s.RFC6962,
+		Arity:       uint32(s.Arity),
+		SortedPairs: s.SortedPairs,
+		DigestSize:  uint32(s.DigestSize),
+	}
+	if s.Padding != impliedPadding(s.RFC6962) {
+		padding := uint32(s.Padding)
+		pbp.Padding = &padding
+	}
+	return pbp
+}
+
+// ToProof converts the Proof to a merkle.Proof.
+//
+// It returns a non-nil error if the leaf index or the number of leaves do not
+// fit in an int, or if the hashing scheme is invalid.
+func (x *Proof) ToProof() (*merkle.Proof, error) {
+	if x.GetLeafIndex() > math.MaxInt || x.GetNumLeaves() > math.MaxInt ||
+		x.GetArity() > math.MaxInt32 || x.GetDigestSize() > math.MaxInt32 {
+		return nil, merkle.ErrInvalidEncoding
+	}
+	siblings := make([][]byte, len(x.GetSiblings()))
+	for i, sibling := range x.GetSiblings() {
+		siblings[i] = sibling
+		if siblings[i] == nil {
+			siblings[i] = []byte{}
+		}
+	}
+	p := &merkle.Proof{
+		Hash:       crypto.Hash(x.GetHash()),
+		LeafIndex:  int(x.GetLeafIndex()),
+		NumLeaves:  int(x.GetNumLeaves()),
+		LeafDigest: x.GetLeafDigest(),
+		Siblings:   siblings,
+	}
+	s := merkle.ProofScheme{
+		RFC6962:     x.GetRfc6962(),
+		Arity:       int(x.GetArity()),
+		Padding:     impliedPadding(x.GetRfc6962()),
+		SortedPairs: x.GetSortedPairs(),
+		DigestSize:  int(x.GetDigestSize()),
+	}
+	if x.Padding != nil {
+		if x.GetPadding() > math.MaxInt32 {
+			return nil, merkle.ErrInvalidEncoding
+		}
+		s.Padding = merkle.PaddingPolicy(x.GetPadding())
+	}
+	if err := p.SetScheme(s); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// impliedPadding returns the PaddingPolicy that a Proof implies unless it
+// records another one explicitly, as the encodings of the merkle package do.
+func impliedPadding(rfc6962 bool) merkle.PaddingPolicy {
+	if rfc6962 {
+		return merkle.PromoteLone
+	}
+	return merkle.HashLone
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merklepb
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ckatsak/merkle"
+)
+
+type Word string
+
+func (w Word) Serialize() []byte {
+	return []byte(w)
+}
+
+var words = []merkle.Datum{Word("alpha"), Word("beta"), Word("gamma"), Word("delta"), Word("epsilon")}
+
+func TestProof00(t *testing.T) {
+	for _, opts := range [][]merkle.Option{
+		nil,
+		{merkle.RFC6962()},
+		{merkle.WithArity(4)},
+		{merkle.SortedPairs()},
+		{merkle.RFC6962(), merkle.WithPaddingPolicy(merkle.DuplicateLast)},
+		{merkle.TruncateDigests(16)},
+		{merkle.WithArity(3), merkle.TruncateDigests(20)},
+	} {
+		tree, err := merkle.NewTreeWithOptions(crypto.SHA256, words, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := tree.ProveDatum(Word("epsilon"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := proto.Marshal(FromProof(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%x", b)
+
+		var pbp Proof
+		if err = proto.Unmarshal(b, &pbp); err != nil {
+			t.Fatal(err)
+		}
+		p2, err := pbp.ToProof()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p2.Scheme() != p.Scheme() {
+			t.Fatalf("want (%+v); got %+v", p.Scheme(), p2.Scheme())
+		}
+		if !p2.Verify(tree.MerkleRoot()) {
+			t.Fatalf("decoded proof failed to verify")
+		}
+		if err = proto.Unmarshal(b[:len(b)-1], &pbp); err == nil {
+			t.Fatalf("want (non-nil error); got %v", err)
+		}
+	}
+}
+func TestProof01(t *testing.T) {
+	padding := uint32(42)
+	pbp := &Proof{Hash: uint32(crypto.SHA256), NumLeaves: 1, Padding: &padding}
+	if _, err := pbp.ToProof(); err != merkle.ErrInvalidEncoding {
+		t.Fatalf("want (%v); got %v", merkle.ErrInvalidEncoding, err)
+	}
+	pbp = &Proof{Hash: uint32(crypto.SHA256), LeafIndex: 1 << 63, NumLeaves: 1}
+	if _, err := pbp.ToProof(); err != merkle.ErrInvalidEncoding {
+		t.Fatalf("want (%v); got %v", merkle.ErrInvalidEncoding, err)
+	}
+}
+func TestSignedRoot00(t *testing.T) {
+	r := &SignedRoot{
+		Hash:           uint32(crypto.SHA256),
+		TreeSize:       42,
+		Root:           bytes.Repeat([]byte{0xab}, 32),
+		TimestampNanos: 1234567890,
+		KeyId:          "key",
+		Signature:      []byte("signature"),
+	}
+	b, err := proto.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r2 SignedRoot
+	if err = proto.Unmarshal(b, &r2); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(r, &r2) {
+		t.Fatalf("want %v; got %v", r, &r2)
+	}
+}
+func TestConsistencyProof00(t *testing.T) {
+	c := &ConsistencyProof{Hash: uint32(crypto.SHA256), OldSize: 3, NewSize: 7, Hashes: [][]byte{{1}, {2, 3}}}
+	b, err := proto.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c2 ConsistencyProof
+	if err = proto.Unmarshal(b, &c2); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(c, &c2) {
+		t.Fatalf("want %v; got %v", c, &c2)
+	}
+}
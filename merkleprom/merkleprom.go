@@ -0,0 +1,134 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package merkleprom exports the measurements of the operations of merkle
+// trees (see merkle.WithMetrics) as Prometheus metrics.
+//
+//	m := merkleprom.New("myservice", nil)
+//	prometheus.MustRegister(m)
+//	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.WithMetrics(m))
+package merkleprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ckatsak/merkle"
+)
+
+// Metrics is a merkle.Metrics that keeps the measurements of the operations
+// of merkle trees in Prometheus metrics; it is a prometheus.Collector of them,
+// so it has to be registered (e.g. through prometheus.MustRegister) for them
+// to be exported. It may be shared by any number of merkle trees.
+//
+// The metrics are, under the "merkle" subsystem of the given namespace:
+//
+//   - leaves_appended_total: the number of leaves appended (counter);
+//   - leaves_deleted_total: the number of leaves removed (counter);
+//   - verifications_total: the number of verifications of merkle paths,
+//     labeled by their "result", i.e. "ok" or "failed" (counter);
+//   - proofs_total: the number of proofs generated (counter);
+//   - reconstruction_duration_seconds: the durations of the (re)constructions
+//     of the merkle nodes (histogram).
+type Metrics struct {
+	appended        prometheus.Counter
+	deleted         prometheus.Counter
+	verified        prometheus.Counter
+	failed          prometheus.Counter
+	proofs          prometheus.Counter
+	reconstructions prometheus.Histogram
+	collectors      []prometheus.Collector
+}
+
+var _ merkle.Metrics = (*Metrics)(nil)
+
+// New returns a new Metrics, whose metrics are named under the given
+// namespace (which may be empty) and carry the given constant labels (e.g. to
+// tell apart the merkle trees of a process by their own Metrics).
+func New(namespace string, labels prometheus.Labels) *Metrics {
+	opts := func(name, help string) prometheus.CounterOpts {
+		return prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "merkle",
+			Name:        name,
+			Help:        help,
+			ConstLabels: labels,
+		}
+	}
+	verifications := prometheus.NewCounterVec(
+		opts("verifications_total", "Number of verifications of merkle paths, by result."),
+		[]string{"result"},
+	)
+	m := &Metrics{
+		appended: prometheus.NewCounter(opts("leaves_appended_total", "Number of leaves appended to merkle trees.")),
+		deleted:  prometheus.NewCounter(opts("leaves_deleted_total", "Number of leaves removed from merkle trees.")),
+		verified: verifications.WithLabelValues("ok"),
+		failed:   verifications.WithLabelValues("failed"),
+		proofs:   prometheus.NewCounter(opts("proofs_total", "Number of proofs generated by merkle trees.")),
+		reconstructions: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "merkle",
+			Name:        "reconstruction_duration_seconds",
+			Help:        "Durations of the (re)constructions of the merkle nodes of merkle trees.",
+			ConstLabels: labels,
+			Buckets:     prometheus.ExponentialBuckets(1e-5, 4, 10),
+		}),
+	}
+	m.collectors = []prometheus.Collector{m.appended, m.deleted, verifications, m.proofs, m.reconstructions}
+	return m
+}
+
+// Describe implements the prometheus.Collector interface.
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, c := range m.collectors {
+		c.Describe(ch)
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, c := range m.collectors {
+		c.Collect(ch)
+	}
+}
+
+// LeavesAppended implements the merkle.Metrics interface.
+func (m *Metrics) LeavesAppended(n int) {
+	m.appended.Add(float64(n))
+}
+
+// LeavesDeleted implements the merkle.Metrics interface.
+func (m *Metrics) LeavesDeleted(n int) {
+	m.deleted.Add(float64(n))
+}
+
+// Verified implements the merkle.Metrics interface.
+func (m *Metrics) Verified(ok bool) {
+	if ok {
+		m.verified.Inc()
+	} else {
+		m.failed.Inc()
+	}
+}
+
+// ProofGenerated implements the merkle.Metrics interface.
+func (m *Metrics) ProofGenerated() {
+	m.proofs.Inc()
+}
+
+// Reconstructed implements the merkle.Metrics interface.
+func (m *Metrics) Reconstructed(d time.Duration) {
+	m.reconstructions.Observe(d.Seconds())
+}
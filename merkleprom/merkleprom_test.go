@@ -0,0 +1,78 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkleprom
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/ckatsak/merkle"
+)
+
+func TestMetrics00(t *testing.T) {
+	m := New("test", prometheus.Labels{"tree": "gr"})
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(m); err != nil {
+		t.Fatal(err)
+	}
+
+	data := []merkle.Datum{
+		merkle.StringDatum("alpha"), merkle.StringDatum("beta"), merkle.StringDatum("gamma"),
+		merkle.StringDatum("delta"), merkle.StringDatum("epsilon"),
+	}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data[:3], merkle.WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(data[3:]...)
+	tree.DeleteAndReconstruct(data[0])
+	for _, d := range data[1:] {
+		if v, err := tree.VerifyDatum(d); err != nil || !v {
+			t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+		}
+	}
+	if _, err := tree.Proof(0); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `
+# HELP test_merkle_leaves_appended_total Number of leaves appended to merkle trees.
+# TYPE test_merkle_leaves_appended_total counter
+test_merkle_leaves_appended_total{tree="gr"} 2
+# HELP test_merkle_leaves_deleted_total Number of leaves removed from merkle trees.
+# TYPE test_merkle_leaves_deleted_total counter
+test_merkle_leaves_deleted_total{tree="gr"} 1
+# HELP test_merkle_proofs_total Number of proofs generated by merkle trees.
+# TYPE test_merkle_proofs_total counter
+test_merkle_proofs_total{tree="gr"} 1
+# HELP test_merkle_verifications_total Number of verifications of merkle paths, by result.
+# TYPE test_merkle_verifications_total counter
+test_merkle_verifications_total{result="failed",tree="gr"} 0
+test_merkle_verifications_total{result="ok",tree="gr"} 4
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want),
+		"test_merkle_leaves_appended_total", "test_merkle_leaves_deleted_total",
+		"test_merkle_proofs_total", "test_merkle_verifications_total"); err != nil {
+		t.Fatal(err)
+	}
+	if n := testutil.CollectAndCount(m, "test_merkle_reconstruction_duration_seconds"); n != 1 {
+		t.Fatalf("want (1) histogram; got %d", n)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "time"
+
+// Metrics receives measurements of the operations of a merkle tree (see
+// WithMetrics); e.g. to export them to a monitoring system (see the
+// merkleprom package for Prometheus). A Metrics is shared by the merkle tree
+// and the ones derived from it (e.g. through Snapshot or Appended), and it is
+// called concurrently by AuditAll, so it must be safe for concurrent use. Its
+// methods are called synchronously, hence they should return quickly.
+type Metrics interface {
+	// LeavesAppended is called with the number of the leaves appended to
+	// the merkle tree by each of its mutations that appends any; i.e. by
+	// Append, AppendAndReconstruct, Appended, Commit and Merge.
+	LeavesAppended(n int)
+	// LeavesDeleted is called with the number of the leaves removed from
+	// the merkle tree by each of its mutations that removes any; i.e. by
+	// Delete, DeleteAndReconstruct, Deleted and Commit.
+	LeavesDeleted(n int)
+	// Verified is called with the outcome of each verification of the
+	// merkle path of a leaf (e.g. by VerifyDatum or AuditAll) that does
+	// not fail with an error.
+	Verified(ok bool)
+	// ProofGenerated is called upon each proof that the merkle tree
+	// generates; i.e. by Proof (and hence ProveDatum), RangeProof,
+	// InclusionProof and ConsistencyProof.
+	ProofGenerated()
+	// Reconstructed is called with the duration of each (re)construction
+	// of the merkle nodes of the merkle tree, excluding the hashing of
+	// its leaves.
+	Reconstructed(d time.Duration)
+}
+
+// WithMetrics configures the merkle tree to report measurements of its
+// operations to the given Metrics. It is not included in the encodings of the
+// merkle tree.
+func WithMetrics(m Metrics) Option {
+	return func(t *Tree) {
+		t.metrics = m
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingMetrics is a Metrics that keeps count of its measurements.
+type countingMetrics struct {
+	mu                       sync.Mutex
+	appended, deleted        int
+	verified, failed, proofs int
+	reconstructions          int
+	reconstructionsDuration  time.Duration
+}
+
+func (m *countingMetrics) LeavesAppended(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.appended += n
+}
+
+func (m *countingMetrics) LeavesDeleted(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleted += n
+}
+
+func (m *countingMetrics) Verified(ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ok {
+		m.verified++
+	} else {
+		m.failed++
+	}
+}
+
+func (m *countingMetrics) ProofGenerated() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.proofs++
+}
+
+func (m *countingMetrics) Reconstructed(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconstructions++
+	m.reconstructionsDuration += d
+}
+
+func TestWithMetrics00(t *testing.T) {
+	m := &countingMetrics{}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], WithMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(grAlphabet[10:15]...)
+	if _, _, err := tree.Append(grAlphabet[15]); err != nil {
+		t.Fatal(err)
+	}
+	tree.DeleteAndReconstruct(grAlphabet[0], grAlphabet[1])
+	if _, err := tree.Delete(grAlphabet[2], grAlphabet[23]); err == nil {
+		t.Fatal("want non-nil error for the missing datum")
+	}
+	b := tree.Begin()
+	if _, err := b.Append(grAlphabet[16], grAlphabet[17]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Delete(grAlphabet[3]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if m.appended != 8 || m.deleted != 4 || m.reconstructions != 6 {
+		t.Fatalf("want (8, 4, 6); got (%d, %d, %d)", m.appended, m.deleted, m.reconstructions)
+	}
+
+	for _, d := range grAlphabet[4:8] {
+		if _, err := tree.VerifyDatum(d); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tree.ProveDatum(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tree.VerifyDatum(grAlphabet[0]); err == nil {
+		t.Fatal("want non-nil error for the deleted datum")
+	}
+	tree.tls[0].digest = make([]byte, len(tree.tls[0].digest))
+	if r := tree.AuditAll(); r.OK() {
+		t.Fatal("want failed audit")
+	}
+	if want := 4 + tree.NumLeaves(); m.verified+m.failed != want || m.failed == 0 || m.proofs != 4 {
+		t.Fatalf("want (%d, >0, 4); got (%d, %d, %d)", want, m.verified+m.failed, m.failed, m.proofs)
+	}
+	t.Logf("%+v", m)
+}
@@ -0,0 +1,248 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package mmr implements an in-memory, "hash function-agnostic" Merkle
+// Mountain Range; i.e. an append-only list of perfect merkle trees (the
+// "mountains") of strictly decreasing heights, whose roots (the "peaks") are
+// bagged together into a single root.
+//
+// Appending a leaf costs O(log n) hash calculations at most, since only the
+// mountains of equal height at the end of the range are merged, while the
+// rest of it is never touched again.
+//
+// A leaf's digest is H(0x00 || data) and an internal node's digest is
+// H(0x01 || left || right). The root is calculated by bagging the peaks from
+// right to left, using the internal node hashing rule.
+package mmr
+
+import (
+	"crypto"
+	"errors"
+	"hash"
+	"math/bits"
+)
+
+var (
+	// ErrHashUnavailable signifies that the requested hash function has
+	// not been linked into the binary.
+	ErrHashUnavailable = errors.New("mmr: hash algorithm unavailable")
+
+	// ErrNoLeaf signifies that the requested leaf does not exist.
+	ErrNoLeaf = errors.New("mmr: nonexistent leaf")
+)
+
+var (
+	leafPrefix = []byte{0x00}
+	nodePrefix = []byte{0x01}
+)
+
+// MMR is a Merkle Mountain Range.
+type MMR struct {
+	hash      crypto.Hash
+	h         hash.Hash
+	nodes     [][]byte
+	heights   []uint8
+	numLeaves uint64
+}
+
+// New creates a new, empty Merkle Mountain Range given one of the available
+// (i.e. linked into the binary) hash functions.
+func New(hash crypto.Hash) (*MMR, error) {
+	if !hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	return &MMR{hash: hash, h: hash.New()}, nil
+}
+
+// Size returns the total number of nodes in the Merkle Mountain Range,
+// including its leaves.
+func (m *MMR) Size() int {
+	return len(m.nodes)
+}
+
+// NumLeaves returns the number of leaves in the Merkle Mountain Range.
+func (m *MMR) NumLeaves() uint64 {
+	return m.numLeaves
+}
+
+// Append appends a new leaf for the given data, merging any mountains of equal
+// height that this results in, and returns the index of the new leaf.
+func (m *MMR) Append(data []byte) uint64 {
+	m.h.Reset()
+	m.h.Write(leafPrefix)
+	m.h.Write(data)
+	m.push(m.h.Sum(nil), 0)
+
+	// Merge the last two mountains for as long as they are equally high.
+	for height := uint8(0); ; height++ {
+		last := len(m.nodes) - 1
+		left := last - (2<<height - 1)
+		if left < 0 || m.heights[left] != height || m.heights[last] != height {
+			break
+		}
+		m.push(hashNode(m.h, m.nodes[left], m.nodes[last]), height+1)
+	}
+
+	m.numLeaves++
+	return m.numLeaves - 1
+}
+
+func (m *MMR) push(digest []byte, height uint8) {
+	m.nodes = append(m.nodes, digest)
+	m.heights = append(m.heights, height)
+}
+
+// peakPositions returns the positions of the peaks, from left to right.
+func (m *MMR) peakPositions() []int {
+	var ret []int
+	pos := -1
+	for height := bits.Len64(m.numLeaves); height >= 0; height-- {
+		if m.numLeaves&(1<<height) != 0 {
+			pos += 2<<height - 1
+			ret = append(ret, pos)
+		}
+	}
+	return ret
+}
+
+// Peaks returns the digests of the peaks, from left to right.
+func (m *MMR) Peaks() [][]byte {
+	positions := m.peakPositions()
+	ret := make([][]byte, len(positions))
+	for i, pos := range positions {
+		ret[i] = append([]byte(nil), m.nodes[pos]...)
+	}
+	return ret
+}
+
+// Root returns the bagged peaks of the Merkle Mountain Range, or nil if it is
+// empty.
+func (m *MMR) Root() []byte {
+	return bagPeaks(m.h, m.Peaks())
+}
+
+// Proof is a positional inclusion proof of a leaf in a Merkle Mountain Range.
+type Proof struct {
+	// Hash is the hash function of the Merkle Mountain Range.
+	Hash crypto.Hash
+	// LeafIndex is the index of the leaf.
+	LeafIndex uint64
+	// NumLeaves is the number of leaves in the Merkle Mountain Range.
+	NumLeaves uint64
+	// Path are the digests of the siblings of the nodes along the path from
+	// the leaf to the peak of its mountain.
+	Path [][]byte
+	// Peaks are the digests of all peaks, from left to right.
+	Peaks [][]byte
+}
+
+// Prove returns an inclusion proof of the leaf at the given index.
+func (m *MMR) Prove(leafIndex uint64) (*Proof, error) {
+	if leafIndex >= m.numLeaves {
+		return nil, ErrNoLeaf
+	}
+	p := &Proof{
+		Hash:      m.hash,
+		LeafIndex: leafIndex,
+		NumLeaves: m.numLeaves,
+		Peaks:     m.Peaks(),
+	}
+
+	// Find the mountain of the leaf...
+	start, offset := uint64(0), leafIndex
+	var pos, height int
+	for _, peak := range m.peakPositions() {
+		height = int(m.heights[peak])
+		if offset < 1<<height {
+			pos = peak
+			break
+		}
+		offset -= 1 << height
+		start += 1 << height
+	}
+	// ...and descend from its peak to the leaf.
+	for ; height > 0; height-- {
+		left, right := pos-(1<<height), pos-1
+		if offset&(1<<(height-1)) == 0 {
+			p.Path = append(p.Path, append([]byte(nil), m.nodes[right]...))
+			pos = left
+		} else {
+			p.Path = append(p.Path, append([]byte(nil), m.nodes[left]...))
+			pos = right
+		}
+	}
+	for i, j := 0, len(p.Path)-1; i < j; i, j = i+1, j-1 {
+		p.Path[i], p.Path[j] = p.Path[j], p.Path[i]
+	}
+	return p, nil
+}
+
+// Verify verifies that the given data are the leaf that the Proof refers to,
+// and that the Proof leads to the given root.
+func (p *Proof) Verify(root, data []byte) bool {
+	if !p.Hash.Available() || p.LeafIndex >= p.NumLeaves || len(p.Peaks) != bits.OnesCount64(p.NumLeaves) {
+		return false
+	}
+	h := p.Hash.New()
+
+	// Find the mountain of the leaf, which must be as high as the path.
+	peak, offset := 0, p.LeafIndex
+	for height := 63; height >= 0; height-- {
+		if p.NumLeaves&(1<<height) == 0 {
+			continue
+		}
+		if offset < 1<<height {
+			if height != len(p.Path) {
+				return false
+			}
+			break
+		}
+		offset -= 1 << height
+		peak++
+	}
+
+	h.Write(leafPrefix)
+	h.Write(data)
+	digest := h.Sum(nil)
+	for i, sibling := range p.Path {
+		if offset&(1<<i) == 0 {
+			digest = hashNode(h, digest, sibling)
+		} else {
+			digest = hashNode(h, sibling, digest)
+		}
+	}
+	if string(digest) != string(p.Peaks[peak]) {
+		return false
+	}
+	return string(bagPeaks(h, p.Peaks)) == string(root)
+}
+
+func hashNode(h hash.Hash, left, right []byte) []byte {
+	h.Reset()
+	h.Write(nodePrefix)
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+func bagPeaks(h hash.Hash, peaks [][]byte) []byte {
+	if len(peaks) == 0 {
+		return nil
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = hashNode(h, peaks[i], root)
+	}
+	return root
+}
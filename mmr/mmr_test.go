@@ -0,0 +1,69 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mmr
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestAppend00(t *testing.T) {
+	m, err := New(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Root() != nil {
+		t.Fatalf("want nil root for an empty MMR; got %x", m.Root())
+	}
+	// The number of nodes after n appends is 2n - popcount(n).
+	for n := uint64(1); n <= 100; n++ {
+		if i := m.Append([]byte(fmt.Sprint(n))); i != n-1 {
+			t.Fatalf("want leaf index %d; got %d", n-1, i)
+		}
+		want := 2*int(n) - len(m.peakPositions())
+		if m.Size() != want {
+			t.Fatalf("after %d appends: want %d nodes; got %d", n, want, m.Size())
+		}
+	}
+	t.Logf("m.Root(): %x (%d peaks)", m.Root(), len(m.Peaks()))
+}
+
+func TestProof00(t *testing.T) {
+	m, err := New(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := 1; n <= 40; n++ {
+		m.Append([]byte(fmt.Sprint(n - 1)))
+		root := m.Root()
+		for i := uint64(0); i < m.NumLeaves(); i++ {
+			p, err := m.Prove(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(root, []byte(fmt.Sprint(i))) {
+				t.Fatalf("n=%d: proof of leaf %d failed", n, i)
+			}
+			if p.Verify(root, []byte("forged")) {
+				t.Fatalf("n=%d: proof of leaf %d verified forged data", n, i)
+			}
+		}
+	}
+	if _, err = m.Prove(m.NumLeaves()); err != ErrNoLeaf {
+		t.Fatalf("want (%v); got %v", ErrNoLeaf, err)
+	}
+}
@@ -0,0 +1,432 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package mpt implements an in-memory, Ethereum-style, hexary Merkle Patricia
+// Trie, along with the generation and verification of proofs for it.
+//
+// The hash function is pluggable through any constructor of hash.Hash values;
+// to match Ethereum's state, storage, transaction and receipt tries, use
+// Keccak-256 (e.g. sha3.NewLegacyKeccak256 from golang.org/x/crypto/sha3).
+// Nodes are RLP-encoded exactly as in Ethereum, and are referenced by the hash
+// of their encoding, unless the latter is shorter than 32 bytes, in which case
+// they are embedded into their parent.
+package mpt
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+)
+
+// ErrMissingNode signifies that a proof lacks a node that is necessary to
+// verify it.
+var ErrMissingNode = errors.New("mpt: missing node in proof")
+
+type (
+	node interface{}
+
+	// fullNode is a branch node, with a child per nibble and a value.
+	fullNode struct {
+		children [17]node
+		ref      []byte
+	}
+
+	// shortNode is either an extension node or, if its key ends with the
+	// terminator nibble, a leaf node.
+	shortNode struct {
+		key []byte
+		val node
+		ref []byte
+	}
+
+	valueNode []byte
+
+	// hashNode is a reference to a node by hash, which only appears in
+	// nodes decoded from proofs.
+	hashNode []byte
+)
+
+// terminator is the nibble that marks the end of a key.
+const terminator = 16
+
+// Trie is a Merkle Patricia Trie.
+type Trie struct {
+	newHash func() hash.Hash
+	h       hash.Hash
+	root    node
+}
+
+// New creates a new, empty Merkle Patricia Trie that uses the given hash
+// function.
+func New(newHash func() hash.Hash) *Trie {
+	return &Trie{newHash: newHash, h: newHash()}
+}
+
+// Get returns the value associated with the given key, if any.
+func (t *Trie) Get(key []byte) ([]byte, bool) {
+	n, k := t.root, keyToNibbles(key)
+	for {
+		switch nn := n.(type) {
+		case nil:
+			return nil, false
+		case valueNode:
+			return append([]byte(nil), nn...), true
+		case *shortNode:
+			if !bytes.HasPrefix(k, nn.key) {
+				return nil, false
+			}
+			n, k = nn.val, k[len(nn.key):]
+		case *fullNode:
+			n, k = nn.children[k[0]], k[1:]
+		}
+	}
+}
+
+// Put associates the given value with the given key. Putting an empty value
+// is equivalent to deleting the key, as in Ethereum.
+func (t *Trie) Put(key, value []byte) {
+	if len(value) == 0 {
+		t.Delete(key)
+		return
+	}
+	t.root = insert(t.root, keyToNibbles(key), valueNode(append([]byte(nil), value...)))
+}
+
+// Delete removes the given key (if present) from the trie.
+func (t *Trie) Delete(key []byte) {
+	t.root = remove(t.root, keyToNibbles(key))
+}
+
+// Root returns the hash digest of the root of the trie. The root of an empty
+// trie is the digest of the RLP encoding of the empty string.
+func (t *Trie) Root() []byte {
+	if t.root == nil {
+		t.h.Reset()
+		t.h.Write([]byte{0x80})
+		return t.h.Sum(nil)
+	}
+	enc := t.encode(t.root)
+	t.h.Reset()
+	t.h.Write(enc)
+	return t.h.Sum(nil)
+}
+
+// Prove returns a proof of the given key, which comprises the encodings of
+// the nodes along its path that are referenced by hash, starting from the
+// root. If the key is absent, the proof proves its absence.
+func (t *Trie) Prove(key []byte) [][]byte {
+	var proof [][]byte
+	n, k := t.root, keyToNibbles(key)
+	for first := true; n != nil; first = false {
+		if _, ok := n.(valueNode); ok {
+			break
+		}
+		if enc := t.encode(n); first || len(enc) >= 32 {
+			proof = append(proof, enc)
+		}
+		switch nn := n.(type) {
+		case *shortNode:
+			if !bytes.HasPrefix(k, nn.key) {
+				return proof
+			}
+			n, k = nn.val, k[len(nn.key):]
+		case *fullNode:
+			n, k = nn.children[k[0]], k[1:]
+		}
+	}
+	return proof
+}
+
+// VerifyProof verifies the given proof of the given key against the given
+// root, using the given hash function, and returns the value associated with
+// the key, or nil if the proof proves its absence.
+//
+// It returns a non-nil error if the proof is invalid.
+func VerifyProof(newHash func() hash.Hash, root, key []byte, proof [][]byte) ([]byte, error) {
+	h := newHash()
+	db := make(map[string][]byte, len(proof))
+	for _, enc := range proof {
+		h.Reset()
+		h.Write(enc)
+		db[string(h.Sum(nil))] = enc
+	}
+
+	wantHash, k := root, keyToNibbles(key)
+	for {
+		enc, ok := db[string(wantHash)]
+		if !ok {
+			return nil, ErrMissingNode
+		}
+		n, err := decodeNode(enc)
+		if err != nil {
+			return nil, err
+		}
+		// Walk through the node, and any nodes embedded into it.
+		for {
+			switch nn := n.(type) {
+			case nil:
+				return nil, nil
+			case valueNode:
+				return []byte(nn), nil
+			case hashNode:
+				wantHash = nn
+			case *shortNode:
+				if !bytes.HasPrefix(k, nn.key) {
+					return nil, nil
+				}
+				n, k = nn.val, k[len(nn.key):]
+				continue
+			case *fullNode:
+				n, k = nn.children[k[0]], k[1:]
+				continue
+			}
+			break
+		}
+	}
+}
+
+func insert(n node, key []byte, value node) node {
+	if len(key) == 0 {
+		return value
+	}
+	switch n := n.(type) {
+	case nil:
+		return &shortNode{key: key, val: value}
+	case *shortNode:
+		matchLen := prefixLen(key, n.key)
+		if matchLen == len(n.key) {
+			return &shortNode{key: n.key, val: insert(n.val, key[matchLen:], value)}
+		}
+		// Branch out where the keys diverge.
+		branch := &fullNode{}
+		branch.children[n.key[matchLen]] = insert(nil, n.key[matchLen+1:], n.val)
+		branch.children[key[matchLen]] = insert(nil, key[matchLen+1:], value)
+		if matchLen == 0 {
+			return branch
+		}
+		return &shortNode{key: key[:matchLen], val: branch}
+	case *fullNode:
+		nn := &fullNode{children: n.children}
+		nn.children[key[0]] = insert(n.children[key[0]], key[1:], value)
+		return nn
+	}
+	panic("mpt: unexpected node type")
+}
+
+func remove(n node, key []byte) node {
+	switch n := n.(type) {
+	case nil, valueNode:
+		return nil
+	case *shortNode:
+		matchLen := prefixLen(key, n.key)
+		if matchLen < len(n.key) {
+			return n
+		}
+		if matchLen == len(key) {
+			return nil
+		}
+		child := remove(n.val, key[len(n.key):])
+		if child, ok := child.(*shortNode); ok {
+			return &shortNode{key: concat(n.key, child.key), val: child.val}
+		}
+		return &shortNode{key: n.key, val: child}
+	case *fullNode:
+		nn := &fullNode{children: n.children}
+		nn.children[key[0]] = remove(n.children[key[0]], key[1:])
+		pos := -1
+		for i, child := range nn.children {
+			if child != nil {
+				if pos >= 0 {
+					return nn
+				}
+				pos = i
+			}
+		}
+		// Only a single child is left; collapse the branch.
+		if pos == terminator {
+			return &shortNode{key: []byte{terminator}, val: nn.children[pos]}
+		}
+		if child, ok := nn.children[pos].(*shortNode); ok {
+			return &shortNode{key: concat([]byte{byte(pos)}, child.key), val: child.val}
+		}
+		return &shortNode{key: []byte{byte(pos)}, val: nn.children[pos]}
+	}
+	panic("mpt: unexpected node type")
+}
+
+// encode returns the RLP encoding of the given node, caching it in the node.
+func (t *Trie) encode(n node) []byte {
+	switch n := n.(type) {
+	case valueNode:
+		return appendRLPString(nil, n)
+	case *shortNode:
+		if n.ref == nil {
+			var items []byte
+			items = appendRLPString(items, nibblesToCompact(n.key))
+			items = append(items, t.ref(n.val)...)
+			n.ref = appendRLPList(nil, items)
+		}
+		return n.ref
+	case *fullNode:
+		if n.ref == nil {
+			var items []byte
+			for _, child := range n.children {
+				items = append(items, t.ref(child)...)
+			}
+			n.ref = appendRLPList(nil, items)
+		}
+		return n.ref
+	}
+	return appendRLPString(nil, nil)
+}
+
+// ref returns the RLP encoding of a reference to the given node from its
+// parent: either the node itself, if its encoding is shorter than 32 bytes,
+// or the hash of its encoding.
+func (t *Trie) ref(n node) []byte {
+	enc := t.encode(n)
+	if _, ok := n.(valueNode); ok || n == nil || len(enc) < 32 {
+		return enc
+	}
+	t.h.Reset()
+	t.h.Write(enc)
+	return appendRLPString(nil, t.h.Sum(nil))
+}
+
+func decodeNode(enc []byte) (node, error) {
+	isList, content, rest, err := splitRLP(enc)
+	if err != nil || !isList || len(rest) != 0 {
+		return nil, errRLP
+	}
+	items, err := splitRLPList(content)
+	if err != nil {
+		return nil, err
+	}
+	switch len(items) {
+	case 2:
+		isList, compact, _, err := splitRLP(items[0])
+		if err != nil || isList {
+			return nil, errRLP
+		}
+		key, err := compactToNibbles(compact)
+		if err != nil {
+			return nil, err
+		}
+		if key[len(key)-1] == terminator {
+			_, value, _, err := splitRLP(items[1])
+			return &shortNode{key: key, val: valueNode(value)}, err
+		}
+		val, err := decodeRef(items[1])
+		return &shortNode{key: key, val: val}, err
+	case 17:
+		n := &fullNode{}
+		for i := 0; i < terminator; i++ {
+			if n.children[i], err = decodeRef(items[i]); err != nil {
+				return nil, err
+			}
+		}
+		if _, value, _, err := splitRLP(items[terminator]); err != nil {
+			return nil, err
+		} else if len(value) > 0 {
+			n.children[terminator] = valueNode(value)
+		}
+		return n, nil
+	}
+	return nil, errRLP
+}
+
+func decodeRef(item []byte) (node, error) {
+	isList, content, _, err := splitRLP(item)
+	switch {
+	case err != nil:
+		return nil, err
+	case isList:
+		return decodeNode(item)
+	case len(content) == 0:
+		return nil, nil
+	}
+	return hashNode(content), nil
+}
+
+// keyToNibbles splits the given key into nibbles, appending the terminator.
+func keyToNibbles(key []byte) []byte {
+	nibbles := make([]byte, 2*len(key)+1)
+	for i, b := range key {
+		nibbles[2*i], nibbles[2*i+1] = b>>4, b&0x0f
+	}
+	nibbles[len(nibbles)-1] = terminator
+	return nibbles
+}
+
+// nibblesToCompact encodes the given nibbles in Ethereum's compact ("hex
+// prefix") encoding.
+func nibblesToCompact(nibbles []byte) []byte {
+	var flags byte
+	if len(nibbles) > 0 && nibbles[len(nibbles)-1] == terminator {
+		flags, nibbles = 2, nibbles[:len(nibbles)-1]
+	}
+	compact := make([]byte, len(nibbles)/2+1)
+	compact[0] = flags << 4
+	if len(nibbles)%2 == 1 {
+		compact[0] |= 1<<4 | nibbles[0]
+		nibbles = nibbles[1:]
+	}
+	for i := 0; i < len(nibbles); i += 2 {
+		compact[i/2+1] = nibbles[i]<<4 | nibbles[i+1]
+	}
+	return compact
+}
+
+// compactToNibbles decodes the given key of a shortNode from its compact
+// encoding (see nibblesToCompact); the key of an extension node must comprise
+// at least one nibble, and that of a leaf node at least the terminator.
+//
+// It returns errRLP if the encoding is malformed; i.e. if it is empty, if its
+// flags are unknown, or if the padding nibble of a key of even length is not
+// zero.
+func compactToNibbles(compact []byte) ([]byte, error) {
+	if len(compact) == 0 {
+		return nil, errRLP
+	}
+	flags := compact[0] >> 4
+	if flags > 3 || (flags&1 == 0 && compact[0]&0x0f != 0) {
+		return nil, errRLP
+	}
+	nibbles := make([]byte, 0, 2*len(compact)+1)
+	if flags&1 != 0 {
+		nibbles = append(nibbles, compact[0]&0x0f)
+	}
+	for _, b := range compact[1:] {
+		nibbles = append(nibbles, b>>4, b&0x0f)
+	}
+	if flags&2 != 0 {
+		nibbles = append(nibbles, terminator)
+	}
+	if len(nibbles) == 0 {
+		return nil, errRLP
+	}
+	return nibbles, nil
+}
+
+func prefixLen(a, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func concat(a, b []byte) []byte {
+	return append(append(make([]byte, 0, len(a)+len(b)), a...), b...)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mpt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/sha3"
+)
+
+func TestRoot00(t *testing.T) {
+	trie := New(sha3.NewLegacyKeccak256)
+	if want := "56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421"; hex.EncodeToString(trie.Root()) != want {
+		t.Fatalf("want empty root %s; got %x", want, trie.Root())
+	}
+
+	// Test vectors from go-ethereum's trie package.
+	trie.Put([]byte("doe"), []byte("reindeer"))
+	trie.Put([]byte("dog"), []byte("puppy"))
+	trie.Put([]byte("dogglesworth"), []byte("cat"))
+	if want := "8aad789dff2f538bca5d8ea56e8abe10f4c7ba3a5dea95fea4cd6e7c3a1168d3"; hex.EncodeToString(trie.Root()) != want {
+		t.Fatalf("want root %s; got %x", want, trie.Root())
+	}
+
+}
+
+func TestDelete00(t *testing.T) {
+	trie := New(sha3.NewLegacyKeccak256)
+	for _, kv := range [][2]string{
+		{"do", "verb"},
+		{"ether", "wookiedoo"},
+		{"horse", "stallion"},
+		{"shaman", "horse"},
+		{"doge", "coin"},
+		{"ether", ""},
+		{"dog", "puppy"},
+		{"shaman", ""},
+	} {
+		trie.Put([]byte(kv[0]), []byte(kv[1]))
+	}
+	if want := "5991bb8c6514148a29db676a14ac506cd2cd5775ace63c30a4fe457715e9ac84"; hex.EncodeToString(trie.Root()) != want {
+		t.Fatalf("want root %s; got %x", want, trie.Root())
+	}
+	if v, ok := trie.Get([]byte("doge")); !ok || string(v) != "coin" {
+		t.Fatalf("want coin; got (%q, %v)", v, ok)
+	}
+	if _, ok := trie.Get([]byte("ether")); ok {
+		t.Fatalf("deleted key is still present")
+	}
+}
+
+func TestProof00(t *testing.T) {
+	trie := New(sha3.NewLegacyKeccak256)
+	for i := 0; i < 200; i++ {
+		trie.Put([]byte(fmt.Sprintf("key-%d", i)), bytes.Repeat([]byte{byte(i)}, i%40+1))
+	}
+	root := trie.Root()
+
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("key-%d", i))
+		value, err := VerifyProof(sha3.NewLegacyKeccak256, root, key, trie.Prove(key))
+		if err != nil {
+			t.Fatalf("ERROR while verifying proof of %q: %v", key, err)
+		}
+		if want := bytes.Repeat([]byte{byte(i)}, i%40+1); !bytes.Equal(value, want) {
+			t.Fatalf("want %x; got %x", want, value)
+		}
+	}
+
+	// Proofs of absence.
+	for _, key := range []string{"key-", "key-1000", "nokey"} {
+		value, err := VerifyProof(sha3.NewLegacyKeccak256, root, []byte(key), trie.Prove([]byte(key)))
+		if err != nil || value != nil {
+			t.Fatalf("want proof of absence of %q; got (%x, %v)", key, value, err)
+		}
+	}
+
+	// Tampered proofs.
+	proof := trie.Prove([]byte("key-42"))
+	proof[len(proof)-1] = append([]byte(nil), proof[len(proof)-1]...)
+	proof[len(proof)-1][len(proof[len(proof)-1])-1] ^= 1
+	if _, err := VerifyProof(sha3.NewLegacyKeccak256, root, []byte("key-42"), proof); err == nil {
+		t.Fatalf("tampered proof verified successfully")
+	}
+}
+
+func TestProof01(t *testing.T) {
+	ref := bytes.Repeat([]byte{0}, 32)
+	for _, compact := range [][]byte{
+		{},     // empty key
+		{0x00}, // extension node without nibbles
+		{0x40}, // unknown flags
+		{0x05}, // non-zero padding of an even extension key
+		{0x2a}, // non-zero padding of an even leaf key
+	} {
+		// A shortNode of the given key that refers to a 32-byte child.
+		enc := append([]byte{0xc0 + byte(1+len(compact)+33)}, rlpString(compact)...)
+		enc = append(append(enc, 0xa0), ref...)
+		h := sha3.NewLegacyKeccak256()
+		h.Write(enc)
+		root := h.Sum(nil)
+		if _, err := VerifyProof(sha3.NewLegacyKeccak256, root, []byte("k"), [][]byte{enc}); err == nil {
+			t.Fatalf("proof with key %x verified successfully", compact)
+		}
+	}
+}
+
+// rlpString returns the RLP encoding of the given short string.
+func rlpString(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+	return append([]byte{0x80 + byte(len(b))}, b...)
+}
+
+func FuzzVerifyProof(f *testing.F) {
+	trie := New(sha3.NewLegacyKeccak256)
+	for i := 0; i < 20; i++ {
+		trie.Put([]byte(fmt.Sprintf("key-%d", i)), []byte(fmt.Sprintf("value-%d", i)))
+	}
+	for _, proof := range [][][]byte{trie.Prove([]byte("key-7")), trie.Prove([]byte("nokey"))} {
+		f.Add([]byte("key-7"), bytes.Join(proof, nil))
+	}
+	f.Add([]byte("k"), []byte{0xe2, 0x00, 0xa0})
+	f.Fuzz(func(t *testing.T, key, enc []byte) {
+		// The proof is the single node of the given encoding, whose
+		// digest is the merkle root, so that it is actually decoded.
+		h := sha3.NewLegacyKeccak256()
+		h.Write(enc)
+		VerifyProof(sha3.NewLegacyKeccak256, h.Sum(nil), key, [][]byte{enc})
+	})
+}
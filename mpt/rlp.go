@@ -0,0 +1,96 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mpt
+
+import "errors"
+
+// errRLP signifies a malformed RLP encoding.
+var errRLP = errors.New("mpt: malformed RLP encoding")
+
+// appendRLPString appends the RLP encoding of the given byte string.
+func appendRLPString(b, s []byte) []byte {
+	if len(s) == 1 && s[0] < 0x80 {
+		return append(b, s[0])
+	}
+	return append(appendRLPHead(b, 0x80, len(s)), s...)
+}
+
+// appendRLPList appends the RLP encoding of a list, given the concatenated
+// RLP encodings of its items.
+func appendRLPList(b, items []byte) []byte {
+	return append(appendRLPHead(b, 0xc0, len(items)), items...)
+}
+
+func appendRLPHead(b []byte, offset byte, size int) []byte {
+	if size < 56 {
+		return append(b, offset+byte(size))
+	}
+	var sizeBytes []byte
+	for s := size; s > 0; s >>= 8 {
+		sizeBytes = append([]byte{byte(s)}, sizeBytes...)
+	}
+	return append(append(b, offset+55+byte(len(sizeBytes))), sizeBytes...)
+}
+
+// splitRLP splits the first RLP item off b, returning whether it is a list,
+// its content, and whatever follows it.
+func splitRLP(b []byte) (isList bool, content, rest []byte, err error) {
+	if len(b) == 0 {
+		return false, nil, nil, errRLP
+	}
+	var offset, size int
+	switch prefix := b[0]; {
+	case prefix < 0x80:
+		return false, b[:1], b[1:], nil
+	case prefix < 0xb8:
+		offset, size = 1, int(prefix-0x80)
+	case prefix < 0xc0:
+		offset, size, err = longRLPSize(b, int(prefix-0xb7))
+	case prefix < 0xf8:
+		isList, offset, size = true, 1, int(prefix-0xc0)
+	default:
+		isList = true
+		offset, size, err = longRLPSize(b, int(prefix-0xf7))
+	}
+	if err != nil || size > len(b)-offset {
+		return false, nil, nil, errRLP
+	}
+	return isList, b[offset : offset+size], b[offset+size:], nil
+}
+
+func longRLPSize(b []byte, sizeOfSize int) (offset, size int, err error) {
+	if sizeOfSize > 4 || len(b) < 1+sizeOfSize {
+		return 0, 0, errRLP
+	}
+	for _, c := range b[1 : 1+sizeOfSize] {
+		size = size<<8 | int(c)
+	}
+	return 1 + sizeOfSize, size, nil
+}
+
+// splitRLPList splits the content of an RLP list into its (still encoded)
+// items.
+func splitRLPList(content []byte) ([][]byte, error) {
+	var items [][]byte
+	for len(content) > 0 {
+		_, _, rest, err := splitRLP(content)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, content[:len(content)-len(rest)])
+		content = rest
+	}
+	return items, nil
+}
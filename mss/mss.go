@@ -0,0 +1,273 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package mss implements a stateful, hash-based Merkle signature scheme in
+// the style of XMSS (see RFC 8391), on top of the merkle trees of package
+// merkle.
+//
+// A private key comprises 2^height WOTS+ one-time key pairs, whose public
+// keys are the leaves of a merkle tree (of SHA-256, in insertion order); the
+// public key is its merkle root. Each signature is made by the next unused
+// one-time key pair, and comprises its index, its WOTS+ signature of the
+// (randomized) digest of the message, and the inclusion proof of its public
+// key. The parameters are n = 32 and w = 16.
+//
+// The scheme is stateful: a one-time key pair must never sign twice, hence
+// the index of the next one (see PrivateKey.Index) must be persisted (e.g.
+// through PrivateKey.MarshalBinary) before each signature is released. Unlike
+// XMSS, the public keys of the one-time key pairs are not compressed by an
+// L-tree, and the encodings are not interoperable with RFC 8391.
+package mss
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/ckatsak/merkle"
+)
+
+var (
+	// ErrInvalidHeight signifies that the requested height of the merkle
+	// tree is out of the supported range, i.e. from 1 to MaxHeight.
+	ErrInvalidHeight = errors.New("mss: invalid height")
+
+	// ErrKeyExhausted signifies that all of the one-time key pairs of the
+	// private key have been used.
+	ErrKeyExhausted = errors.New("mss: private key exhausted")
+
+	// ErrInvalidKey signifies that the given encoding of a key is
+	// malformed.
+	ErrInvalidKey = errors.New("mss: invalid key")
+)
+
+// MaxHeight is the greatest supported height of the merkle tree of a private
+// key, i.e. of 2^MaxHeight one-time key pairs.
+const MaxHeight = 20
+
+// PublicKey is the public key of the Merkle signature scheme.
+type PublicKey struct {
+	// Height is the height of the merkle tree, and Root is its merkle
+	// root.
+	Height int
+	Root   []byte
+	// Seed is the public seed that randomizes the hashing of the
+	// one-time key pairs.
+	Seed []byte
+}
+
+// PrivateKey is the private key of the Merkle signature scheme, along with
+// its state; i.e. the index of the next one-time key pair to sign with. It
+// is not safe for concurrent use.
+type PrivateKey struct {
+	PublicKey
+	skSeed, skPRF []byte
+	index         uint64
+	tree          *merkle.Tree
+}
+
+// Signature is a signature of the Merkle signature scheme.
+type Signature struct {
+	// Index is the index of the one-time key pair that made the signature,
+	// and R randomizes the digest of the message.
+	Index uint64
+	R     []byte
+	// WOTS is the WOTS+ signature of the digest, one value per chain.
+	WOTS [][]byte
+	// Path holds the siblings along the merkle path of the public key of
+	// the one-time key pair, from the leaves up (see merkle.Proof).
+	Path [][]byte
+}
+
+// GenerateKey generates a new private key of 2^height one-time key pairs,
+// whose seeds are read from the given source of randomness (e.g.
+// crypto/rand.Reader). It takes 2^height * 67 * 15 hash chain steps.
+//
+// It returns a non-nil error if the height is out of range, or if reading
+// from rand fails.
+func GenerateKey(rand io.Reader, height int) (*PrivateKey, error) {
+	seed := make([]byte, 3*n)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, err
+	}
+	return newKey(height, seed[:n], seed[n:2*n], seed[2*n:], 0)
+}
+
+// newKey derives the private key of the given height, seeds and index,
+// constructing the merkle tree of its one-time public keys.
+func newKey(height int, skSeed, skPRF, pubSeed []byte, index uint64) (*PrivateKey, error) {
+	if height < 1 || height > MaxHeight {
+		return nil, ErrInvalidHeight
+	}
+	hs := newHasher()
+	data := make([]merkle.Datum, 1<<uint(height))
+	var adrs address
+	for i := range data {
+		adrs.setOTS(uint32(i))
+		data[i] = merkle.ByteDatum(hs.wotsPublicKey(skSeed, pubSeed, &adrs))
+	}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.InsertionOrder(), merkle.DigestOnly())
+	if err != nil {
+		return nil, err
+	}
+	return &PrivateKey{
+		PublicKey: PublicKey{Height: height, Root: tree.MerkleRoot(), Seed: pubSeed},
+		skSeed:    skSeed,
+		skPRF:     skPRF,
+		index:     index,
+		tree:      tree,
+	}, nil
+}
+
+// Public returns the public key of the private key.
+func (k *PrivateKey) Public() *PublicKey {
+	pk := k.PublicKey
+	return &pk
+}
+
+// Index returns the index of the next one-time key pair to sign with.
+func (k *PrivateKey) Index() uint64 {
+	return k.index
+}
+
+// Remaining returns the number of signatures that the private key can still
+// make.
+func (k *PrivateKey) Remaining() uint64 {
+	return 1<<uint(k.Height) - k.index
+}
+
+// Sign signs the given message with the next one-time key pair, advancing
+// the index of the private key first.
+//
+// It returns a non-nil error if the private key is exhausted.
+func (k *PrivateKey) Sign(message []byte) (*Signature, error) {
+	if k.Remaining() == 0 {
+		return nil, ErrKeyExhausted
+	}
+	index := k.index
+	k.index++
+
+	hs := newHasher()
+	var idx [32]byte
+	binary.BigEndian.PutUint64(idx[24:], index)
+	r := hs.sum(padPRF, k.skPRF, idx[:])
+	var adrs address
+	adrs.setOTS(uint32(index))
+	sig := &Signature{
+		Index: index,
+		R:     r,
+		WOTS:  hs.wotsSign(messageDigest(hs, r, k.Root, index, message), k.skSeed, k.Seed, &adrs),
+	}
+	proof, err := k.tree.Proof(int(index))
+	if err != nil {
+		return nil, err
+	}
+	sig.Path = proof.Siblings
+	return sig, nil
+}
+
+// Verify reports whether the given signature of the given message is valid
+// under the public key.
+func (pk *PublicKey) Verify(message []byte, sig *Signature) bool {
+	if sig == nil || pk.Height < 1 || pk.Height > MaxHeight || sig.Index >= 1<<uint(pk.Height) ||
+		len(sig.R) != n || len(sig.WOTS) != wotsLen || len(sig.Path) != pk.Height {
+		return false
+	}
+	for i := range sig.WOTS {
+		if len(sig.WOTS[i]) != n {
+			return false
+		}
+	}
+	hs := newHasher()
+	var adrs address
+	adrs.setOTS(uint32(sig.Index))
+	digest := messageDigest(hs, sig.R, pk.Root, sig.Index, message)
+	leafDigest, err := merkle.HashLeaf(crypto.SHA256, hs.wotsPublicKeyFromSig(sig.WOTS, digest, pk.Seed, &adrs))
+	if err != nil {
+		return false
+	}
+	proof := &merkle.Proof{
+		Hash:       crypto.SHA256,
+		LeafIndex:  int(sig.Index),
+		NumLeaves:  1 << uint(pk.Height),
+		LeafDigest: leafDigest,
+		Siblings:   sig.Path,
+	}
+	return proof.Verify(pk.Root)
+}
+
+// messageDigest returns the randomized digest of the given message, i.e.
+// H(toByte(2, 32) || r || root || toByte(index, 32) || message).
+func messageDigest(hs *hasher, r, root []byte, index uint64, message []byte) []byte {
+	var idx [32]byte
+	binary.BigEndian.PutUint64(idx[24:], index)
+	key := make([]byte, 0, 3*n)
+	key = append(append(append(key, r...), root...), idx[:]...)
+	return hs.sum(padMsg, key, message)
+}
+
+// privateKeyVersion is the version of the encoding of a PrivateKey.
+const privateKeyVersion = 1
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface; the
+// encoding comprises the height, the index and the seeds of the private key,
+// which hence must be kept secret.
+func (k *PrivateKey) MarshalBinary() ([]byte, error) {
+	b := []byte{privateKeyVersion, byte(k.Height)}
+	b = binary.BigEndian.AppendUint64(b, k.index)
+	b = append(append(append(b, k.skSeed...), k.skPRF...), k.Seed...)
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface,
+// rederiving the merkle tree of the private key (see GenerateKey).
+func (k *PrivateKey) UnmarshalBinary(data []byte) error {
+	if len(data) != 10+3*n || data[0] != privateKeyVersion {
+		return ErrInvalidKey
+	}
+	height, index := int(data[1]), binary.BigEndian.Uint64(data[2:])
+	if height < 1 || height > MaxHeight || index > 1<<uint(height) {
+		return ErrInvalidKey
+	}
+	seeds := bytes.Clone(data[10:])
+	k2, err := newKey(height, seeds[:n], seeds[n:2*n], seeds[2*n:], index)
+	if err != nil {
+		return err
+	}
+	*k = *k2
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (pk *PublicKey) MarshalBinary() ([]byte, error) {
+	if len(pk.Root) != sha256.Size || len(pk.Seed) != n {
+		return nil, ErrInvalidKey
+	}
+	b := []byte{byte(pk.Height)}
+	return append(append(b, pk.Root...), pk.Seed...), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (pk *PublicKey) UnmarshalBinary(data []byte) error {
+	if len(data) != 1+sha256.Size+n || data[0] < 1 || data[0] > MaxHeight {
+		return ErrInvalidKey
+	}
+	pk.Height = int(data[0])
+	pk.Root = bytes.Clone(data[1 : 1+sha256.Size])
+	pk.Seed = bytes.Clone(data[1+sha256.Size:])
+	return nil
+}
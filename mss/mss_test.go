@@ -0,0 +1,145 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSignVerify00(t *testing.T) {
+	key, err := GenerateKey(rand.Reader, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public()
+	msg := []byte("hello, world")
+	for i := uint64(0); i < 8; i++ {
+		if key.Index() != i || key.Remaining() != 8-i {
+			t.Fatalf("want (%d, %d); got (%d, %d)", i, 8-i, key.Index(), key.Remaining())
+		}
+		sig, err := key.Sign(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sig.Index != i || len(sig.Path) != 3 {
+			t.Fatalf("want (%d, 3); got (%d, %d)", i, sig.Index, len(sig.Path))
+		}
+		if !pub.Verify(msg, sig) {
+			t.Fatalf("want (true); got false at index %d", i)
+		}
+	}
+	if _, err := key.Sign(msg); err != ErrKeyExhausted {
+		t.Fatalf("want (%v); got %v", ErrKeyExhausted, err)
+	}
+	t.Log("all one-time key pairs signed once")
+}
+
+func TestVerify00(t *testing.T) {
+	key, err := GenerateKey(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public()
+	msg := []byte("hello, world")
+	sig, err := key.Sign(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pub.Verify([]byte("hello, world!"), sig) {
+		t.Fatalf("want (false); got true for a tampered message")
+	}
+	wrongIndex := *sig
+	wrongIndex.Index = 1
+	if pub.Verify(msg, &wrongIndex) {
+		t.Fatalf("want (false); got true for a wrong index")
+	}
+	wrongR := *sig
+	wrongR.R = bytes.Repeat([]byte{0}, n)
+	if pub.Verify(msg, &wrongR) {
+		t.Fatalf("want (false); got true for a wrong randomizer")
+	}
+	wrongPath := *sig
+	wrongPath.Path = [][]byte{sig.Path[1], sig.Path[0]}
+	if pub.Verify(msg, &wrongPath) {
+		t.Fatalf("want (false); got true for a wrong path")
+	}
+	shortWOTS := *sig
+	shortWOTS.WOTS = sig.WOTS[1:]
+	if pub.Verify(msg, &shortWOTS) || pub.Verify(msg, nil) {
+		t.Fatalf("want (false); got true for a malformed signature")
+	}
+
+	other, err := GenerateKey(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Public().Verify(msg, sig) {
+		t.Fatalf("want (false); got true under another public key")
+	}
+}
+
+func TestMarshalBinary00(t *testing.T) {
+	if _, err := GenerateKey(rand.Reader, 0); err != ErrInvalidHeight {
+		t.Fatalf("want (%v); got %v", ErrInvalidHeight, err)
+	}
+	key, err := GenerateKey(rand.Reader, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := key.Sign([]byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := key.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restored PrivateKey
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Index() != 1 || !bytes.Equal(restored.Root, key.Root) {
+		t.Fatalf("want (1, %x); got (%d, %x)", key.Root, restored.Index(), restored.Root)
+	}
+	sig, err := restored.Sign([]byte("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sig.Index != 1 {
+		t.Fatalf("want (1); got %d", sig.Index)
+	}
+
+	pubData, err := key.Public().MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pub PublicKey
+	if err := pub.UnmarshalBinary(pubData); err != nil {
+		t.Fatal(err)
+	}
+	if !pub.Verify([]byte("second"), sig) {
+		t.Fatalf("want (true); got false")
+	}
+
+	if err := restored.UnmarshalBinary(data[1:]); err != ErrInvalidKey {
+		t.Fatalf("want (%v); got %v", ErrInvalidKey, err)
+	}
+	if err := pub.UnmarshalBinary(pubData[1:]); err != ErrInvalidKey {
+		t.Fatalf("want (%v); got %v", ErrInvalidKey, err)
+	}
+}
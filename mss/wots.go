@@ -0,0 +1,175 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+)
+
+// The parameters of WOTS+ (see RFC 8391, section 3.1): n is the size of the
+// digests in bytes and w the Winternitz parameter, out of which the number of
+// the chains (wotsLen) follows.
+const (
+	n        = sha256.Size
+	w        = 16
+	logW     = 4
+	wotsLen1 = 8 * n / logW
+	wotsLen2 = 3
+	wotsLen  = wotsLen1 + wotsLen2
+)
+
+// The domain separators of the keyed hash functions (see RFC 8391, section
+// 5.1).
+const (
+	padF   = 0
+	padMsg = 2
+	padPRF = 3
+)
+
+// address is the 32-byte hash address (see RFC 8391, section 2.5) that
+// randomizes each call of the keyed hash functions; i.e. the index of the
+// one-time key pair, of the chain, of the step along it, and whether the key
+// or the bitmask is derived.
+type address [32]byte
+
+func (a *address) setOTS(i uint32)        { binary.BigEndian.PutUint32(a[16:], i) }
+func (a *address) setChain(i uint32)      { binary.BigEndian.PutUint32(a[20:], i) }
+func (a *address) setHash(i uint32)       { binary.BigEndian.PutUint32(a[24:], i) }
+func (a *address) setKeyAndMask(i uint32) { binary.BigEndian.PutUint32(a[28:], i) }
+
+// hasher computes the keyed hash functions of the scheme, reusing a single
+// hash.Hash.
+type hasher struct {
+	h   hash.Hash
+	pad [32]byte
+}
+
+func newHasher() *hasher {
+	return &hasher{h: sha256.New()}
+}
+
+// sum returns H(toByte(pad, 32) || key || m).
+func (hs *hasher) sum(pad byte, key, m []byte) []byte {
+	hs.pad[31] = pad
+	hs.h.Reset()
+	hs.h.Write(hs.pad[:])
+	hs.h.Write(key)
+	hs.h.Write(m)
+	return hs.h.Sum(nil)
+}
+
+// prf returns PRF(key, adrs).
+func (hs *hasher) prf(key []byte, adrs *address) []byte {
+	return hs.sum(padPRF, key, adrs[:])
+}
+
+// chain applies the given number of steps of the chaining function to x,
+// starting at the given one, under the given public seed and address (whose
+// OTS and chain indices are set).
+func (hs *hasher) chain(x []byte, start, steps int, pubSeed []byte, adrs *address) []byte {
+	tmp := append([]byte{}, x...)
+	for i := start; i < start+steps && i < w; i++ {
+		adrs.setHash(uint32(i))
+		adrs.setKeyAndMask(0)
+		key := hs.prf(pubSeed, adrs)
+		adrs.setKeyAndMask(1)
+		mask := hs.prf(pubSeed, adrs)
+		for j := range tmp {
+			tmp[j] ^= mask[j]
+		}
+		tmp = hs.sum(padF, key, tmp)
+	}
+	return tmp
+}
+
+// wotsSecret returns the secret key of the given chain of the one-time key
+// pair of the given address.
+func (hs *hasher) wotsSecret(skSeed []byte, adrs *address, chain int) []byte {
+	adrs.setChain(uint32(chain))
+	adrs.setHash(0)
+	adrs.setKeyAndMask(0)
+	sk := hs.prf(skSeed, adrs)
+	adrs.setChain(0)
+	return sk
+}
+
+// wotsPublicKey returns the public key of the one-time key pair of the given
+// address, i.e. the ends of its chains, concatenated.
+func (hs *hasher) wotsPublicKey(skSeed, pubSeed []byte, adrs *address) []byte {
+	pk := make([]byte, 0, wotsLen*n)
+	for i := 0; i < wotsLen; i++ {
+		sk := hs.wotsSecret(skSeed, adrs, i)
+		adrs.setChain(uint32(i))
+		pk = append(pk, hs.chain(sk, 0, w-1, pubSeed, adrs)...)
+	}
+	return pk
+}
+
+// wotsSign signs the given message digest with the one-time key pair of the
+// given address.
+func (hs *hasher) wotsSign(digest, skSeed, pubSeed []byte, adrs *address) [][]byte {
+	lengths := chainLengths(digest)
+	sig := make([][]byte, wotsLen)
+	for i := range sig {
+		sk := hs.wotsSecret(skSeed, adrs, i)
+		adrs.setChain(uint32(i))
+		sig[i] = hs.chain(sk, 0, lengths[i], pubSeed, adrs)
+	}
+	return sig
+}
+
+// wotsPublicKeyFromSig returns the public key of the one-time key pair that
+// produced the given signature of the given message digest, provided it is
+// valid; i.e. it completes the chains of the signature.
+func (hs *hasher) wotsPublicKeyFromSig(sig [][]byte, digest, pubSeed []byte, adrs *address) []byte {
+	lengths := chainLengths(digest)
+	pk := make([]byte, 0, wotsLen*n)
+	for i := range sig {
+		adrs.setChain(uint32(i))
+		pk = append(pk, hs.chain(sig[i], lengths[i], w-1-lengths[i], pubSeed, adrs)...)
+	}
+	return pk
+}
+
+// chainLengths returns the base-w digits of the given message digest followed
+// by the ones of its checksum (see RFC 8391, section 3.1.5).
+func chainLengths(digest []byte) []int {
+	lengths := baseW(digest, wotsLen1)
+	csum := 0
+	for _, l := range lengths {
+		csum += w - 1 - l
+	}
+	csum <<= 8 - (wotsLen2*logW)%8
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(csum))
+	return append(lengths, baseW(b[:], wotsLen2)...)
+}
+
+// baseW returns the given number of base-w digits of x, most significant
+// first.
+func baseW(x []byte, outLen int) []int {
+	ret := make([]int, outLen)
+	for i := range ret {
+		b := x[i/2]
+		if i%2 == 0 {
+			ret[i] = int(b >> logW)
+		} else {
+			ret[i] = int(b & (w - 1))
+		}
+	}
+	return ret
+}
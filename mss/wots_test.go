@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mss
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestChainLengths00(t *testing.T) {
+	// A digest of zeros maximizes the checksum: 64 * 15 = 960 = 0x3c0.
+	lengths := chainLengths(make([]byte, n))
+	if len(lengths) != wotsLen {
+		t.Fatalf("want (%d) lengths; got %d", wotsLen, len(lengths))
+	}
+	for i := 0; i < wotsLen1; i++ {
+		if lengths[i] != 0 {
+			t.Fatalf("want (0) at %d; got %d", i, lengths[i])
+		}
+	}
+	if csum := lengths[wotsLen1:]; csum[0] != 3 || csum[1] != 12 || csum[2] != 0 {
+		t.Fatalf("want ([3 12 0]); got %v", csum)
+	}
+}
+
+func TestWOTS00(t *testing.T) {
+	skSeed, pubSeed := bytes.Repeat([]byte{1}, n), bytes.Repeat([]byte{2}, n)
+	hs := newHasher()
+	var adrs address
+	adrs.setOTS(5)
+	pk := hs.wotsPublicKey(skSeed, pubSeed, &adrs)
+	if len(pk) != wotsLen*n {
+		t.Fatalf("want (%d) bytes; got %d", wotsLen*n, len(pk))
+	}
+
+	digest := hs.sum(padMsg, nil, []byte("message"))
+	sig := hs.wotsSign(digest, skSeed, pubSeed, &adrs)
+	if got := hs.wotsPublicKeyFromSig(sig, digest, pubSeed, &adrs); !bytes.Equal(got, pk) {
+		t.Fatalf("want (%x); got %x", pk[:n], got[:n])
+	}
+	t.Log("the signature leads to the public key")
+
+	other := hs.sum(padMsg, nil, []byte("other message"))
+	if got := hs.wotsPublicKeyFromSig(sig, other, pubSeed, &adrs); bytes.Equal(got, pk) {
+		t.Fatalf("want (a different public key); got %x", got[:n])
+	}
+	adrs.setOTS(6)
+	if got := hs.wotsPublicKeyFromSig(sig, digest, pubSeed, &adrs); bytes.Equal(got, pk) {
+		t.Fatalf("want (a different public key); got %x", got[:n])
+	}
+}
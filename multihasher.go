@@ -0,0 +1,115 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"hash"
+)
+
+// MultiHasher computes the digests of several messages at once, e.g. in the
+// lanes of SIMD registers, as multi-buffer implementations of SHA-256 do (see
+// the sha256simd package); hashing the leaves of a merkle tree in bulk
+// through it takes a fraction of the calls (and of the time) that hashing
+// them one by one does. A MultiHasher may be shared by many merkle trees, so
+// it must be safe for concurrent use.
+type MultiHasher interface {
+	// Hash returns the hash function whose digests it computes.
+	Hash() crypto.Hash
+	// Lanes returns the number of messages that it hashes at once, i.e.
+	// the most that SumAll is given.
+	Lanes() int
+	// SumAll appends the digests of the given messages to dst, in the
+	// order that they are given in, and returns the resulting slice.
+	SumAll(dst []byte, msgs [][]byte) []byte
+}
+
+// WithMultiHasher configures the merkle tree to hash its leaves in bulk
+// through the given MultiHasher, as many at a time as its lanes, whenever
+// data are given to it all at once; i.e. upon its construction (e.g. by
+// NewTreeWithOptions) and upon AppendAndReconstruct. The digests are the same
+// ones that hashing the leaves one by one produces.
+//
+// It is ignored if the MultiHasher does not compute the digests of the hash
+// function of the merkle tree, if the leaves are keyed (see WithLeafKey), or
+// if the hash function was given through WithHashFunc or WithHasher. Data
+// that are streamed into the hash function (see StreamSerializer) are still
+// hashed one by one.
+func WithMultiHasher(m MultiHasher) Option {
+	return func(t *Tree) {
+		t.multiHasher = m
+	}
+}
+
+// usableMultiHasher returns the MultiHasher of the merkle tree, or nil if it
+// has none or cannot use it (see WithMultiHasher).
+func (t *Tree) usableMultiHasher() MultiHasher {
+	m := t.multiHasher
+	if m == nil || m.Hash() != t.hash || t.scheme.newHash != nil || t.scheme.hasher != nil || t.scheme.leafKey != nil {
+		return nil
+	}
+	return m
+}
+
+// newDatumLeaves is like newDatumLeaf, but for all of the given data, which it
+// hashes through the MultiHasher of the merkle tree, if it has a usable one
+// (and not more than its lanes are given), or in the given digestArena
+// otherwise; the ordered IDs of the new leaves are left for the caller to
+// assign.
+func (t *Tree) newDatumLeaves(h hash.Hash, a *digestArena, data []Datum) ([]treeLeaf, error) {
+	tls := make([]treeLeaf, len(data))
+	m := t.usableMultiHasher()
+	if m == nil || len(data) < 2 {
+		for i := range data {
+			var err error
+			if tls[i], err = t.newDatumLeaf(h, a, data[i], 0); err != nil {
+				return nil, err
+			}
+		}
+		return tls, nil
+	}
+
+	indices := make([]int, 0, len(data))
+	msgs := make([][]byte, 0, len(data))
+	for i := range data {
+		if _, ok := data[i].(StreamSerializer); ok && t.digestOnly {
+			var err error
+			if tls[i], err = t.newDatumLeaf(h, a, data[i], 0); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		serializedDatum, err := serializeDatum(data[i])
+		if err != nil {
+			return nil, err
+		}
+		tls[i].datum = serializedDatum
+		if t.scheme.salted && !t.digestOnly {
+			tls[i].salt = newSalt(h.Size())
+		}
+		indices = append(indices, i)
+		msgs = append(msgs, t.scheme.leafMessage(saltedDatum(tls[i].salt, serializedDatum)))
+	}
+	if len(msgs) == 0 {
+		return tls, nil
+	}
+	size := m.Hash().Size()
+	digests := m.SumAll(make([]byte, 0, size*len(msgs)), msgs)
+	for j, i := range indices {
+		tls[i].digest = t.scheme.truncate(digests[j*size : (j+1)*size : (j+1)*size])
+		t.retainDatum(&tls[i])
+	}
+	return tls, nil
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"sync/atomic"
+	"testing"
+)
+
+// countingMultiHasher is a MultiHasher of SHA-256 that hashes its messages one
+// by one, counting the calls to SumAll.
+type countingMultiHasher struct {
+	lanes int
+	calls int64
+}
+
+func (m *countingMultiHasher) Hash() crypto.Hash { return crypto.SHA256 }
+
+func (m *countingMultiHasher) Lanes() int { return m.lanes }
+
+func (m *countingMultiHasher) SumAll(dst []byte, msgs [][]byte) []byte {
+	atomic.AddInt64(&m.calls, 1)
+	if len(msgs) > m.lanes {
+		panic("too many messages")
+	}
+	for _, msg := range msgs {
+		sum := sha256.Sum256(msg)
+		dst = append(dst, sum[:]...)
+	}
+	return dst
+}
+
+func TestWithMultiHasher00(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{RFC6962()},
+		{LengthPrefixed(), WithArity(3)},
+		{TruncateDigests(20), DigestOnly()},
+		{InsertionOrder(), WithDuplicates(IgnoreDuplicates)},
+	} {
+		data := append(append([]Datum(nil), grAlphabet...), grAlphabet[3])
+		want, err := NewTreeWithOptions(crypto.SHA256, data, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := &countingMultiHasher{lanes: 8}
+		tree, err := NewTreeWithOptions(crypto.SHA256, data, append(opts, WithMultiHasher(m))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+		}
+		// The last, lone leaf is hashed by itself.
+		if want := int64(len(data) / 8); m.calls != want {
+			t.Fatalf("want (%d) calls; got %d", want, m.calls)
+		}
+		if v, err := tree.VerifyDatum(grAlphabet[5]); !v || err != nil {
+			t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+		}
+		t.Logf("%d calls for %d leaves", m.calls, len(data))
+	}
+}
+
+func TestWithMultiHasher01(t *testing.T) {
+	want, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &countingMultiHasher{lanes: 16}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:12], DigestOnly(), WithMultiHasher(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Streamed data are hashed one by one, alongside the rest.
+	data := []Datum{streamedDatum(grAlphabet[12].(Word))}
+	data = append(data, grAlphabet[13:]...)
+	if tree.AppendAndReconstruct(data...); tree.DatumErr() != nil {
+		t.Fatal(tree.DatumErr())
+	}
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	if m.calls != 2 {
+		t.Fatalf("want (2) calls; got %d", m.calls)
+	}
+
+	// A MultiHasher of another hash function, or keyed leaves, are not.
+	m.calls = 0
+	for _, hash := range []crypto.Hash{crypto.SHA1, crypto.SHA256} {
+		opts := []Option{WithMultiHasher(m)}
+		if hash == crypto.SHA256 {
+			opts = append(opts, WithLeafKey([]byte("key")))
+		}
+		if _, err := NewTreeWithOptions(hash, grAlphabet, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if m.calls != 0 {
+		t.Fatalf("want (0) calls; got %d", m.calls)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "sync/atomic"
+
+// heapNodes is the NodeStore that keeps the merkle nodes of a Tree in memory,
+// in a single sequence of digests of the same size, laid out the way the
+// nodes of a heap are; i.e. level by level, from the merkle root down to the
+// parents of the leaves, and from left to right within each level. Hence,
+// for a binary tree whose levels are all full, the parent of the i-th node
+// (counting from 1, the root) is the (i/2)-th one, and in general, the parent
+// of the node at a given height and index is the one at the next height and
+// at that index divided by the arity of the tree.
+//
+// It is read-only once constructed, so that it can be shared with the
+// snapshots of the merkle tree.
+type heapNodes struct {
+	digests []byte
+	size    int
+	// rows holds the number of the nodes at each height (the first one
+	// being height 1), and offsets the index of the first of them in the
+	// sequence.
+	rows    []int
+	offsets []int
+	// owner is the merkle tree that may recycle the memory of the nodes
+	// (see RecycleNodes), unless they have been pinned by a snapshot of
+	// it; spare is the memory it has set aside for recycling.
+	owner  *Tree
+	pinned atomic.Bool
+	spare  []byte
+	// unchanged is the number of the first leaves that were the same as
+	// the ones of the merkle tree before the nodes were reconstructed.
+	unchanged int
+	// first holds, if not nil, the index of the first node that is held
+	// at each height (the first one being height 1); the ones to its left
+	// were left unchanged in the NodeStore of the merkle tree (see
+	// newPartialHeapNodes).
+	first []int
+}
+
+// newHeapNodes returns the heapNodes of the given number of nodes at each
+// height, all of whose digests are of the given size and zeroed.
+func newHeapNodes(rows []int, size int) *heapNodes {
+	return newHeapNodesIn(nil, rows, size)
+}
+
+// newHeapNodesIn is like newHeapNodes, but the digests are laid out in the
+// given buffer, if they fit in its capacity, without being zeroed.
+func newHeapNodesIn(buf []byte, rows []int, size int) *heapNodes {
+	n := &heapNodes{
+		size:    size,
+		rows:    append([]int(nil), rows...),
+		offsets: make([]int, len(rows)),
+	}
+	numNodes := 0
+	for height := len(rows); height > 0; height-- {
+		n.offsets[height-1] = numNodes
+		numNodes += rows[height-1]
+	}
+	if cap(buf) < numNodes*size {
+		buf = make([]byte, numNodes*size)
+	}
+	n.digests = buf[:numNodes*size]
+	return n
+}
+
+// newPartialHeapNodes is like newHeapNodes, but only the nodes from the given
+// index on are held at each height; i.e. the ones that a merkle tree with a
+// NodeStore of its own recalculates, leaving the rest in it untouched.
+func newPartialHeapNodes(rows, first []int, size int) *heapNodes {
+	held := make([]int, len(rows))
+	for i := range rows {
+		held[i] = rows[i] - first[i]
+	}
+	n := newHeapNodes(held, size)
+	n.rows = append(n.rows[:0], rows...)
+	n.first = append([]int(nil), first...)
+	return n
+}
+
+// firstHeld returns the index of the first node that is held at the given
+// height.
+func (n *heapNodes) firstHeld(height int) int {
+	if n.first == nil {
+		return 0
+	}
+	return n.first[height-1]
+}
+
+// height returns the height of the merkle root, or 0 if there are no merkle
+// nodes at all.
+func (n *heapNodes) height() int {
+	return len(n.rows)
+}
+
+// at returns the digest of the node at the given height and index, which must
+// be valid, as a slice of the sequence that cannot be appended to in place.
+func (n *heapNodes) at(height, index int) []byte {
+	if n.first != nil {
+		index -= n.first[height-1]
+	}
+	start := (n.offsets[height-1] + index) * n.size
+	return n.digests[start : start+n.size : start+n.size]
+}
+
+// root returns the digest of the merkle root.
+func (n *heapNodes) root() []byte {
+	return n.at(n.height(), 0)
+}
+
+func (n *heapNodes) Get(level, index int) ([]byte, error) {
+	if level < 1 || level > len(n.rows) || index < 0 || index >= n.rows[level-1] {
+		return nil, &IndexError{Op: "Get", Index: index, Err: ErrNoData}
+	}
+	return n.at(level, index), nil
+}
+
+func (n *heapNodes) Put(level, index int, digest []byte) error {
+	return ErrUnsupported
+}
+
+func (n *heapNodes) Delete(level, index int) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestHeapNodes00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:16], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, ok := tree.store.(*heapNodes)
+	if !ok {
+		t.Fatalf("want (*heapNodes); got %T", tree.store)
+	}
+	if len(n.digests) != n.size*tree.MerkleSize() || !bytes.Equal(n.digests[:n.size], tree.MerkleRoot()) {
+		t.Fatalf("want (%d bytes, beginning with the root); got %d", n.size*tree.MerkleSize(), len(n.digests))
+	}
+
+	// In a full binary tree, the parent of the i-th node is the (i/2)-th
+	// one, counting from 1.
+	h := tree.newHasher()
+	for i := 2; i <= len(n.digests)/n.size; i++ {
+		parent := n.digests[(i/2-1)*n.size : (i/2)*n.size]
+		left := i &^ 1
+		children := [][]byte{
+			n.digests[(left-1)*n.size : left*n.size],
+			n.digests[left*n.size : (left+1)*n.size],
+		}
+		if digest := tree.scheme.hashChildren(h, children); !bytes.Equal(digest, parent) {
+			t.Fatalf("node %d: want (%x); got %x", i/2, digest, parent)
+		}
+	}
+
+	if _, err := n.Get(n.height()+1, 0); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if err := n.Put(1, 0, tree.MerkleRoot()); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestHeapNodes01(t *testing.T) {
+	// Wider trees and incomplete levels are laid out level by level, too.
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:11], WithArity(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := tree.store.(*heapNodes)
+	want := []int{4, 2, 1}
+	if len(n.rows) != len(want) {
+		t.Fatalf("want (%v); got %v", want, n.rows)
+	}
+	offset := 0
+	for height := len(want); height > 0; height-- {
+		if n.rows[height-1] != want[height-1] || n.offsets[height-1] != offset {
+			t.Fatalf("height %d: want (%d, %d); got (%d, %d)", height, want[height-1], offset, n.rows[height-1], n.offsets[height-1])
+		}
+		offset += want[height-1]
+	}
+	if !bytes.Equal(n.root(), tree.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), n.root())
+	}
+}
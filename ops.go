@@ -0,0 +1,204 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"sort"
+)
+
+// OpKind is the kind of an operation in the linear form of an inclusion proof
+// (see Proof.Ops).
+type OpKind int
+
+const (
+	// OpAppend appends the data of the operation to the current message.
+	OpAppend OpKind = iota
+	// OpPrepend prepends the data of the operation to the current message.
+	OpPrepend
+	// OpHash replaces the current message with its digest, by the hash
+	// function of the Proof.
+	OpHash
+)
+
+// String returns the name of the OpKind.
+func (k OpKind) String() string {
+	switch k {
+	case OpAppend:
+		return "append"
+	case OpPrepend:
+		return "prepend"
+	case OpHash:
+		return "hash"
+	}
+	return "unknown"
+}
+
+// ProofOp is an operation in the linear form of an inclusion proof (see
+// Proof.Ops).
+type ProofOp struct {
+	Kind OpKind
+	// Data are the bytes to append or prepend; nil for OpHash.
+	Data []byte
+}
+
+// Ops returns the inclusion proof as a list of operations which, applied in
+// order to the digest of its leaf, yield the merkle root; the form in which
+// timestamping services (e.g. OpenTimestamps and Chainpoint) express their
+// proofs (see the timestamp package).
+//
+// Node prefixes, padding, and the order of sorted pairs (see SortedPairs) are
+// resolved into the appended and prepended data. Lone nodes that are promoted
+// (see PromoteLone) yield no operations at all.
+//
+// It returns a non-nil error if the hash function of the Proof is not
+// available or was given through WithHashFunc or WithHasher, if its digests
+// are truncated (see TruncateDigests), or if the Proof is malformed.
+func (p *Proof) Ops() ([]ProofOp, error) {
+	s := schemeOrDefault(p.scheme)
+	if s.newHash != nil || s.hasher != nil || s.digestSize != 0 {
+		return nil, ErrUnsupported
+	}
+	if !s.available(p.Hash) {
+		return nil, &HashError{Hash: p.Hash}
+	}
+	h := s.newHasher(p.Hash)
+
+	var ops []ProofOp
+	_, err := p.walkLevels(s, func(children [][]byte, i int) []byte {
+		currentDigest := children[i]
+		if len(children) == 1 && s.padding == PromoteLone {
+			return currentDigest
+		}
+		if s.sortedPairs && len(children) > 1 {
+			children = append([][]byte(nil), children...)
+			sort.SliceStable(children, func(i, j int) bool {
+				return bytes.Compare(children[i], children[j]) < 0
+			})
+			for i = range children {
+				if bytes.Equal(children[i], currentDigest) {
+					break
+				}
+			}
+		}
+		prefix := append(copyBytes(s.nodePrefix), bytes.Join(children[:i], nil)...)
+		suffix := bytes.Join(children[i+1:], nil)
+		for n := s.width() - len(children); n > 0; n-- {
+			switch s.padding {
+			case DuplicateLast:
+				suffix = append(suffix, children[len(children)-1]...)
+			case PairWithZero:
+				suffix = append(suffix, make([]byte, len(children[len(children)-1]))...)
+			}
+		}
+		if len(prefix) > 0 {
+			ops = append(ops, ProofOp{Kind: OpPrepend, Data: prefix})
+		}
+		if len(suffix) > 0 {
+			ops = append(ops, ProofOp{Kind: OpAppend, Data: suffix})
+		}
+		ops = append(ops, ProofOp{Kind: OpHash})
+
+		h.Reset()
+		h.Write(prefix)
+		h.Write(currentDigest)
+		h.Write(suffix)
+		return h.Sum(nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// walkLevels climbs the Proof from its leaf up, calling fn at each level with
+// the children of the parent of the current node, in the order of the tree,
+// and the position of the current node among them; fn returns the digest of
+// the parent, which becomes the current node. It returns the last one.
+func (p *Proof) walkLevels(s *scheme, fn func(children [][]byte, i int) []byte) ([]byte, error) {
+	currentDigest := p.LeafDigest
+	if s.width() == 2 {
+		index := p.LeafIndex
+		for _, sibling := range p.Siblings {
+			if len(sibling) == 0 {
+				currentDigest = fn([][]byte{currentDigest}, 0)
+			} else if index%2 == 0 {
+				currentDigest = fn([][]byte{currentDigest, sibling}, 0)
+			} else {
+				currentDigest = fn([][]byte{sibling, currentDigest}, 1)
+			}
+			index /= 2
+		}
+		return currentDigest, nil
+	}
+
+	if p.LeafIndex < 0 || p.LeafIndex >= p.NumLeaves {
+		return nil, ErrInvalidRange
+	}
+	arity := s.width()
+	index, siblings := p.LeafIndex, p.Siblings
+	for width := p.NumLeaves; width > 1; width = (width + arity - 1) / arity {
+		start, end := siblingRange(arity, width, index)
+		if end-start == 1 {
+			if len(siblings) == 0 || len(siblings[0]) != 0 {
+				return nil, ErrInvalidEncoding
+			}
+			siblings = siblings[1:]
+			currentDigest = fn([][]byte{currentDigest}, 0)
+		} else {
+			if len(siblings) < end-start-1 {
+				return nil, ErrInvalidEncoding
+			}
+			children := make([][]byte, 0, end-start)
+			children = append(children, siblings[:index-start]...)
+			children = append(children, currentDigest)
+			children = append(children, siblings[index-start:end-start-1]...)
+			siblings = siblings[end-start-1:]
+			currentDigest = fn(children, index-start)
+		}
+		index /= arity
+	}
+	if len(siblings) != 0 {
+		return nil, ErrInvalidEncoding
+	}
+	return currentDigest, nil
+}
+
+// ApplyOps applies the given operations to msg, in order, hashing by the given
+// hash function, and returns the resulting message; i.e. the merkle root, if
+// ops are the ones returned by Proof.Ops and msg is the digest of its leaf.
+func ApplyOps(hash crypto.Hash, msg []byte, ops []ProofOp) ([]byte, error) {
+	if !hash.Available() {
+		return nil, &HashError{Hash: hash}
+	}
+	h := hash.New()
+	msg = copyBytes(msg)
+	for _, op := range ops {
+		switch op.Kind {
+		case OpAppend:
+			msg = append(msg, op.Data...)
+		case OpPrepend:
+			msg = append(copyBytes(op.Data), msg...)
+		case OpHash:
+			h.Reset()
+			h.Write(msg)
+			msg = h.Sum(nil)
+		default:
+			return nil, ErrInvalidEncoding
+		}
+	}
+	return msg, nil
+}
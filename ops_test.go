@@ -0,0 +1,108 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestProofOps00(t *testing.T) {
+	for _, opts := range [][]Option{
+		nil,
+		{RFC6962()},
+		{SortedPairs()},
+		{WithArity(3)},
+		{WithArity(4), WithPaddingPolicy(DuplicateLast)},
+		{WithPaddingPolicy(PairWithZero)},
+		{WithPaddingPolicy(PromoteLone)},
+		{PadToPowerOfTwo(nil)},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < tree.NumLeaves(); i++ {
+			proof, err := tree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ops, err := proof.Ops()
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := ApplyOps(proof.Hash, proof.LeafDigest, ops)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(root, tree.MerkleRoot()) {
+				t.Fatalf("want (%x); got %x", tree.MerkleRoot(), root)
+			}
+		}
+	}
+}
+
+func TestProofOps01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:3], InsertionOrder(), RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := tree.Proof(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops, err := proof.Ops()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []OpKind{OpPrepend, OpHash, OpPrepend, OpAppend, OpHash}
+	if len(ops) != len(want) {
+		t.Fatalf("want (%d) ops; got %d", len(want), len(ops))
+	}
+	for i := range ops {
+		if ops[i].Kind != want[i] {
+			t.Fatalf("want (%v); got %v", want[i], ops[i].Kind)
+		}
+		t.Logf("%v %x", ops[i].Kind, ops[i].Data)
+	}
+	if ops[0].Data[0] != 0x01 || !bytes.Equal(ops[0].Data[1:], proof.Siblings[0]) {
+		t.Fatalf("want (01%x); got %x", proof.Siblings[0], ops[0].Data)
+	}
+	if !bytes.Equal(ops[2].Data, []byte{0x01}) || !bytes.Equal(ops[3].Data, proof.Siblings[1]) {
+		t.Fatalf("want (01, %x); got (%x, %x)", proof.Siblings[1], ops[2].Data, ops[3].Data)
+	}
+}
+
+func TestProofOps02(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, TruncateDigests(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := tree.Proof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proof.Ops(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := ApplyOps(crypto.Hash(0), nil, nil); !errors.Is(err, ErrHashUnavailable) {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+	if _, err := ApplyOps(crypto.SHA256, nil, []ProofOp{{Kind: OpKind(42)}}); !errors.Is(err, ErrInvalidEncoding) {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"hash"
+)
+
+// Option configures a merkle tree upon its construction.
+type Option func(*Tree)
+
+// WithHash configures the merkle tree to use the given hash function, which
+// must be available (i.e. linked into the binary). It takes precedence over
+// the hash function given to the constructor explicitly, if any.
+func WithHash(hash crypto.Hash) Option {
+	return func(t *Tree) {
+		t.hash = hash
+	}
+}
+
+// WithHashFunc configures the merkle tree to use the hash function that
+// newHash returns new instances of, identified by the given name, rather than
+// the crypto.Hash given to the constructor (which Hash then merely reports);
+// e.g. one that crypto.Hash does not enumerate, such as Keccak-256. Merkle
+// trees built with hash functions of the same name are deemed to hash the
+// same way (e.g. by Merge and Diff).
+//
+// Since their encodings identify the hash function by its crypto.Hash value,
+// such merkle trees (and their proofs) cannot be encoded; their proofs can
+// still be verified in place.
+func WithHashFunc(name string, newHash func() hash.Hash) Option {
+	return func(t *Tree) {
+		t.scheme.hashName, t.scheme.newHash = name, newHash
+	}
+}
+
+// DigestOnly configures the merkle tree to discard the serialized data right
+// after hashing them, keeping only the leaf digests in memory.
+//
+// This is useful when the actual data live elsewhere and duplicating them in
+// the tree is too expensive. In this mode, the leaves are ordered by their
+// digests rather than by their serialized data, and Leaves returns the leaf
+// digests instead of the serialized data.
+func DigestOnly() Option {
+	return func(t *Tree) {
+		t.digestOnly = true
+	}
+}
+
+// InsertionOrder configures the merkle tree to keep its leaves in the order
+// that their data were given in, rather than sorting them.
+//
+// This is useful for append-only structures, such as logs, where the position
+// of each leaf is meaningful. Looking a leaf up by its datum takes linear time
+// in this mode.
+func InsertionOrder() Option {
+	return func(t *Tree) {
+		t.insertionOrder = true
+	}
+}
+
+// WithLess configures the merkle tree to sort its leaves as per the given
+// comparator, which must define a strict weak ordering, rather than in
+// lexicographic order of their keys (i.e. their serialized data or, in
+// digest-only mode, their digests); e.g. so that numeric or big-endian keys
+// of different lengths are ordered the way an external system orders them.
+// Leaves whose keys are equivalent as per less retain their relative order.
+// To not sort the leaves at all, see InsertionOrder, which takes precedence.
+//
+// Since the comparator cannot be encoded, the encodings of such merkle trees
+// decode to trees that keep their leaves in the order they were encoded in,
+// as if InsertionOrder had been given.
+func WithLess(less func(a, b []byte) bool) Option {
+	return func(t *Tree) {
+		t.less = less
+	}
+}
+
+// RFC6962 configures the merkle tree to hash as per RFC 6962 (Certificate
+// Transparency); i.e. H(0x00 || datum) for the leaves, H(0x01 || left ||
+// right) for the merkle nodes, and the last node of an odd-sized level being
+// promoted to the next level as is. It implies InsertionOrder.
+//
+// The merkle roots of such trees are interoperable with other implementations
+// of RFC 6962 and RFC 9162, and they support consistency proofs between
+// their earlier and later versions.
+func RFC6962() Option {
+	return func(t *Tree) {
+		t.scheme.leafPrefix, t.scheme.nodePrefix = rfc6962Scheme.leafPrefix, rfc6962Scheme.nodePrefix
+		t.scheme.padding = rfc6962Scheme.padding
+		t.insertionOrder = true
+	}
+}
+
+// WithArity configures the merkle tree so that each of its merkle nodes has
+// the given number of children (except for the last one of each level, which
+// may have fewer), rather than 2; i.e. the digest of a merkle node is
+// H(child_0 || ... || child_n-1), while a lone last node is treated the same
+// way as in binary trees. Values below 2 are treated as 2.
+//
+// Wider trees are shorter, so their inclusion proofs comprise fewer levels
+// (although each of them carries all the siblings of its node), which pays
+// off for hash functions that process wide inputs cheaply. RangeProof, Prune,
+// the Reconciler, Stream and the proofs of RFC 6962 only support binary
+// trees.
+func WithArity(n int) Option {
+	return func(t *Tree) {
+		t.scheme.arity = 0
+		if n > 2 {
+			t.scheme.arity = n
+		}
+	}
+}
+
+// WithPaddingPolicy configures how the merkle tree calculates the merkle
+// nodes that have fewer children than its arity (e.g. the parent of the last
+// node of an odd-sized level); unknown values are treated as HashLone. It
+// overrides the policy of RFC6962, if given after it, in which case the merkle
+// tree no longer supports the proofs of RFC 6962.
+func WithPaddingPolicy(p PaddingPolicy) Option {
+	return func(t *Tree) {
+		t.scheme.padding = HashLone
+		if p.valid() {
+			t.scheme.padding = p
+		}
+	}
+}
+
+// SortedPairs configures the merkle tree to hash the children of each merkle
+// node in ascending order of their digests, rather than in their own order;
+// i.e. H(min(left, right) || max(left, right)) for binary trees, the way
+// OpenZeppelin's MerkleProof does. Its inclusion proofs can hence be verified
+// without knowing which side each sibling is on. It is incompatible with the
+// proofs of RFC 6962.
+func SortedPairs() Option {
+	return func(t *Tree) {
+		t.scheme.sortedPairs = true
+	}
+}
+
+// LengthPrefixed configures the merkle tree to prefix the serialized data of
+// its leaves with their length (as a big-endian uint64) before hashing them;
+// i.e. H(len(datum) || datum) (along with the leaf prefix, if any), rather
+// than H(datum). Each leaf is hence framed canonically, so that the input of
+// a leaf cannot be passed off as another one's (e.g. a datum of 64 bytes as
+// the concatenation of two digests). Data of several fields should still be
+// framed field by field (see FieldsDatum).
+func LengthPrefixed() Option {
+	return func(t *Tree) {
+		t.scheme.lengthPrefixed = true
+	}
+}
+
+// TruncateDigests configures the merkle tree to truncate the digests of its
+// leaves and merkle nodes to their first size bytes (e.g. 16 for SHA-256),
+// halving the memory that they occupy and the size of its proofs. Truncation
+// is applied to every hash calculation, so each merkle node is the truncated
+// digest of its (truncated) children. The collision resistance of the merkle
+// tree is reduced accordingly (to 2^(4*size) operations), so it should only
+// be given where the requirements permit it. A size that is not positive, or
+// not smaller than the size of the digests of the hash function, has no
+// effect.
+//
+// The size is recorded in the encodings of the merkle tree and of its proofs,
+// except for the ones of RFC 6962.
+func TruncateDigests(size int) Option {
+	return func(t *Tree) {
+		t.scheme.digestSize = 0
+		if size > 0 {
+			t.scheme.digestSize = size
+		}
+	}
+}
+
+// WithLeafKey configures the merkle tree to key the digests of its leaves
+// with the given key; i.e. HMAC(key, datum) (along with the leaf prefix, if
+// any) with its hash function, rather than H(datum). The merkle nodes are
+// hashed as usual. Its merkle root is hence a MAC-like commitment to the
+// data, which only the holders of the key can recompute (e.g. for private
+// deduplication indexes), while its proofs can still be verified by anyone,
+// given the digest of the leaf. The key is not ignored if empty, but it has
+// no effect along with WithHasher.
+//
+// The key is neither handed out along with the proofs of the merkle tree
+// (which hence cannot verify serialized data) nor encoded, so such merkle
+// trees cannot be encoded.
+func WithLeafKey(key []byte) Option {
+	return func(t *Tree) {
+		t.scheme.leafKey = append([]byte{}, key...)
+	}
+}
+
+// WithSalts configures the merkle tree to hash each of its leaves along with
+// a random salt of its own, as large as a digest; i.e. H(salt || datum)
+// (along with the leaf prefix, if any), rather than H(datum). The salts are
+// drawn from crypto/rand and kept alongside the leaves. It has no effect in
+// digest-only mode, where the leaves are looked up by their digests.
+//
+// The digests of the leaves hence leak nothing about their data, not even
+// whether two of them are equal, so the proofs of the merkle tree can be
+// handed out without disclosing anything but the leaves they are about;
+// the salt of a leaf is only revealed by Disclose, for selective disclosure.
+// The salts are not encoded, so such merkle trees cannot be encoded.
+func WithSalts() Option {
+	return func(t *Tree) {
+		t.scheme.salted = true
+	}
+}
+
+// PadToPowerOfTwo configures the merkle tree to be perfectly balanced, by
+// padding its leaves with empty ones, whose digest is the given one, up to the
+// next power of two (or, along with WithArity, of the arity); if emptyLeaf is
+// nil, an all-zero digest is used. The empty leaves are implied rather than
+// stored, and the digests of the empty subtrees above them are calculated
+// once per level.
+//
+// The inclusion proofs of such trees list all siblings explicitly (including
+// the empty ones), and their NumLeaves counts the empty leaves too, so they
+// are suitable for verifiers that expect perfectly balanced trees. RangeProof,
+// Prune, the Reconciler, Stream and the proofs of RFC 6962 do not support
+// them.
+func PadToPowerOfTwo(emptyLeaf []byte) Option {
+	return FixedDepth(0, emptyLeaf)
+}
+
+// FixedDepth is like PadToPowerOfTwo, but pads the leaves up to a fixed depth
+// of the given number of levels of merkle nodes (e.g. 32 for the leaves of
+// the Ethereum deposit contract), regardless of their number; a depth of 0 is
+// the same as PadToPowerOfTwo.
+//
+// Creating a merkle tree of more leaves than its depth allows fails, while
+// AppendAndReconstruct leaves it intact.
+func FixedDepth(depth int, emptyLeaf []byte) Option {
+	return func(t *Tree) {
+		t.scheme.padded, t.scheme.depth, t.scheme.emptyLeaf = true, 0, nil
+		if depth > 0 {
+			t.scheme.depth = depth
+		}
+		if emptyLeaf != nil {
+			t.scheme.emptyLeaf = copyBytes(emptyLeaf)
+		}
+	}
+}
+
+// WithHistory configures the merkle tree to retain its earlier versions (see
+// Tree.Version), up to the given number of the most recent ones (or all of
+// them, if it is not positive), so that they can be served through
+// AtVersion, RootAtVersion and ProofAtVersion; e.g. to prove claims made
+// against an earlier merkle root.
+//
+// Each version is a snapshot of the merkle tree (see Snapshot), hence it
+// shares its unchanged digests with the rest; the merkle nodes of a tree that
+// reads them through a NodeStore, though, are copied into memory for each
+// version. The history is not included in the encodings of the merkle tree.
+func WithHistory(limit int) Option {
+	return func(t *Tree) {
+		t.keepHistory, t.historyLimit = true, 0
+		if limit > 0 {
+			t.historyLimit = limit
+		}
+	}
+}
+
+// WithProgress configures the merkle tree to report the progress of its
+// construction (and reconstruction) through the given callback, which is
+// invoked after each leaf is hashed and after each level of merkle nodes is
+// constructed, with the number of hash calculations done so far and the total
+// number of them required.
+//
+// The callback is invoked synchronously, so it should return quickly.
+func WithProgress(fn func(done, total int)) Option {
+	return func(t *Tree) {
+		t.onProgress = fn
+	}
+}
@@ -0,0 +1,885 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDigestOnly00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dtree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot():  %x", tree.MerkleRoot())
+	t.Logf("dtree.MerkleRoot(): %x", dtree.MerkleRoot())
+
+	for i := range dtree.tls {
+		if dtree.tls[i].datum != nil {
+			t.Fatalf("leaf %d retains its datum (%q)", i, dtree.tls[i].datum)
+		}
+	}
+
+	var v bool
+	for _, word := range grAlphabet {
+		if v, err = dtree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+	if v, err = dtree.VerifyDatum(kk); err == nil {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", kk, v, err)
+	}
+}
+func TestDigestOnly01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.AppendAndReconstruct(enAlphabetCap...)
+	tree.DeleteAndReconstruct(grAlphabet[12:]...)
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+	t.Log("tree.NumLeaves():", tree.NumLeaves())
+
+	var v bool
+	for _, word := range grAlphabet[:12] {
+		if v, err = tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+	for _, word := range grAlphabet[12:] {
+		if v, err = tree.VerifyDatum(word); err == nil {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+
+	h := crypto.SHA256.New()
+	h.Write(grAlphabet[0].Serialize())
+	if leaves := tree.Leaves(); !bytes.Equal(leaves[0], h.Sum(nil)) {
+		t.Fatalf("want first leaf %x; got %x", h.Sum(nil), leaves[0])
+	}
+}
+
+func TestInsertionOrder00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+
+	for i, word := range grAlphabet {
+		if !bytes.Equal(tree.tls[i].datum, word.Serialize()) {
+			t.Fatalf("want leaf %d (%q); got %q", i, word.Serialize(), tree.tls[i].datum)
+		}
+	}
+	var v bool
+	for _, word := range grAlphabet {
+		if v, err = tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+	tree.DeleteAndReconstruct(grAlphabet[:3]...)
+	if v, err = tree.VerifyDatum(grAlphabet[0]); err == nil {
+		t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", grAlphabet[0], v, err)
+	}
+	if !bytes.Equal(tree.tls[0].datum, grAlphabet[3].Serialize()) {
+		t.Fatalf("want first leaf %q; got %q", grAlphabet[3].Serialize(), tree.tls[0].datum)
+	}
+}
+
+func TestRFC690600(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+
+	var v bool
+	for _, word := range enAlphabetCap {
+		if v, err = tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+	for i := range enAlphabetCap {
+		p, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(tree.MerkleRoot()) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+}
+
+func TestWithHashFunc00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ftree, err := NewTreeWithOptions(crypto.SHA512, enAlphabetCap, WithHashFunc("sha256", sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("ftree.MerkleRoot(): %x", ftree.MerkleRoot())
+	if !bytes.Equal(ftree.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", tree.MerkleRoot(), ftree.MerkleRoot())
+	}
+	for i := range enAlphabetCap {
+		p, err := ftree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(ftree.MerkleRoot()) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+
+	if _, err := ftree.MarshalBinary(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := json.Marshal(ftree); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := ftree.MarshalCBOR(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	p, err := ftree.Proof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.MarshalCompact(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestSortedPairs00(t *testing.T) {
+	digests := make([][]byte, 3)
+	for i, s := range []string{"a", "b", "c"} {
+		sum := sha256.Sum256([]byte(s))
+		digests[i] = sum[:]
+	}
+	tree, err := NewTreeFromDigests(crypto.SHA256, digests, InsertionOrder(), SortedPairs(), WithPaddingPolicy(PromoteLone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+
+	hashPair := func(a, b []byte) []byte {
+		if bytes.Compare(a, b) > 0 {
+			a, b = b, a
+		}
+		sum := sha256.Sum256(append(append([]byte{}, a...), b...))
+		return sum[:]
+	}
+	want := hashPair(hashPair(digests[0], digests[1]), digests[2])
+	if !bytes.Equal(tree.MerkleRoot(), want) {
+		t.Fatalf("want root (%x); got %x", want, tree.MerkleRoot())
+	}
+	for i := range digests {
+		p, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(want) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+		b, err := p.MarshalCompact()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p2 Proof
+		if err := p2.UnmarshalCompact(b); err != nil {
+			t.Fatal(err)
+		}
+		if !p2.Verify(want) {
+			t.Fatalf("decoded proof of leaf %d does not verify", i)
+		}
+	}
+
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree2 Tree
+	if err := tree2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !tree2.scheme.sortedPairs || !bytes.Equal(tree2.MerkleRoot(), want) {
+		t.Fatalf("want sorted pairs and root (%x); got %v and %x", want, tree2.scheme.sortedPairs, tree2.MerkleRoot())
+	}
+	if b, err = json.Marshal(tree); err != nil {
+		t.Fatal(err)
+	}
+	var tree3 Tree
+	if err := json.Unmarshal(b, &tree3); err != nil {
+		t.Fatal(err)
+	}
+	if !tree3.scheme.sortedPairs || !bytes.Equal(tree3.MerkleRoot(), want) {
+		t.Fatalf("want sorted pairs and root (%x); got %v and %x", want, tree3.scheme.sortedPairs, tree3.MerkleRoot())
+	}
+	if b, err = tree.MarshalCBOR(); err != nil {
+		t.Fatal(err)
+	}
+	var tree4 Tree
+	if err := tree4.UnmarshalCBOR(b); err != nil {
+		t.Fatal(err)
+	}
+	if !tree4.scheme.sortedPairs || !bytes.Equal(tree4.MerkleRoot(), want) {
+		t.Fatalf("want sorted pairs and root (%x); got %v and %x", want, tree4.scheme.sortedPairs, tree4.MerkleRoot())
+	}
+}
+
+func TestWithLeafKey00(t *testing.T) {
+	for _, key := range [][]byte{[]byte("key"), bytes.Repeat([]byte("long key"), 20)} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, InsertionOrder(), RFC6962(), WithLeafKey(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+		for i := range enAlphabetCap {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte{0x00})
+			mac.Write(enAlphabetCap[i].Serialize())
+			if want := mac.Sum(nil); !bytes.Equal(tree.tls[i].digest, want) {
+				t.Fatalf("want leaf %d digest (%x); got %x", i, want, tree.tls[i].digest)
+			}
+			if v, err := tree.VerifyDatum(enAlphabetCap[i]); err != nil || !v {
+				t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", enAlphabetCap[i], v, err)
+			}
+			p, err := tree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(tree.MerkleRoot()) {
+				t.Fatalf("proof of leaf %d does not verify", i)
+			}
+		}
+
+		utree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, InsertionOrder(), RFC6962())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(tree.MerkleRoot(), utree.MerkleRoot()) {
+			t.Fatalf("keyed root equals unkeyed one (%x)", utree.MerkleRoot())
+		}
+		if _, err := Merge(tree, utree); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+		}
+		if _, err := tree.MarshalBinary(); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+		}
+	}
+}
+
+func TestLengthPrefixed00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, InsertionOrder(), LengthPrefixed())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+	for i := range enAlphabetCap {
+		datum := enAlphabetCap[i].Serialize()
+		want := sha256.Sum256(append([]byte{0, 0, 0, 0, 0, 0, 0, byte(len(datum))}, datum...))
+		if !bytes.Equal(tree.tls[i].digest, want[:]) {
+			t.Fatalf("want leaf %d digest (%x); got %x", i, want, tree.tls[i].digest)
+		}
+	}
+
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b[len(binaryMagic)] != binaryVersionExt {
+		t.Fatalf("want version (%d); got %d", binaryVersionExt, b[len(binaryMagic)])
+	}
+	var tree2 Tree
+	if err := tree2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = json.Marshal(tree); err != nil {
+		t.Fatal(err)
+	}
+	var tree3 Tree
+	if err := json.Unmarshal(b, &tree3); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = tree.MarshalCBOR(); err != nil {
+		t.Fatal(err)
+	}
+	var tree4 Tree
+	if err := tree4.UnmarshalCBOR(b); err != nil {
+		t.Fatal(err)
+	}
+	var flat bytes.Buffer
+	if err := tree.WriteFlat(&flat); err != nil {
+		t.Fatal(err)
+	}
+	tree5, err := OpenFlat(flat.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tr := range []*Tree{&tree2, &tree3, &tree4, tree5} {
+		if !tr.scheme.lengthPrefixed || !bytes.Equal(tr.MerkleRoot(), tree.MerkleRoot()) {
+			t.Fatalf("decoded tree %d: want length prefixes and root (%x); got %v and %x",
+				i, tree.MerkleRoot(), tr.scheme.lengthPrefixed, tr.MerkleRoot())
+		}
+	}
+
+	pt, err := tree.Prune(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = pt.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var pt2 PartialTree
+	if err := pt2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !pt2.Verify() || !pt2.VerifySerializedDatum(enAlphabetCap[1].Serialize()) {
+		t.Fatal("decoded PartialTree does not verify")
+	}
+}
+
+func TestWithLess00(t *testing.T) {
+	// Order decimal numbers numerically, rather than lexicographically.
+	numeric := func(a, b []byte) bool {
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return bytes.Compare(a, b) < 0
+	}
+	data := []Datum{StringDatum("10"), StringDatum("9"), StringDatum("100"), StringDatum("2")}
+	tree, err := NewTreeWithOptions(crypto.SHA256, data, WithLess(numeric))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []string{"2", "9", "10", "100"} {
+		if got := string(tree.tls[i].datum); got != want {
+			t.Fatalf("want leaf %d (%s); got %s", i, want, got)
+		}
+		if index, err := tree.IndexOf(StringDatum(want)); err != nil || index != i {
+			t.Fatalf("want (%d, <nil>); got (%d, %v)", i, index, err)
+		}
+	}
+	tree.AppendAndReconstruct(StringDatum("11"))
+	tree.DeleteAndReconstruct(StringDatum("9"))
+	if got := string(tree.tls[2].datum); got != "11" {
+		t.Fatalf("want leaf 2 (11); got %s", got)
+	}
+	if v, err := tree.VerifyDatum(StringDatum("100")); err != nil || !v {
+		t.Fatalf("ERROR while verifying 100: (%v, %v)", v, err)
+	}
+
+	// The comparator is not encoded; the leaves are kept in their order.
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tree2 Tree
+	if err := tree2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = json.Marshal(tree); err != nil {
+		t.Fatal(err)
+	}
+	var tree3 Tree
+	if err := json.Unmarshal(b, &tree3); err != nil {
+		t.Fatal(err)
+	}
+	for i, tr := range []*Tree{&tree2, &tree3} {
+		if !tr.insertionOrder || !bytes.Equal(tr.MerkleRoot(), tree.MerkleRoot()) {
+			t.Fatalf("decoded tree %d: want insertion order and root (%x); got %v and %x",
+				i, tree.MerkleRoot(), tr.insertionOrder, tr.MerkleRoot())
+		}
+		if index, err := tr.IndexOf(StringDatum("100")); err != nil || index != 3 {
+			t.Fatalf("want (3, <nil>); got (%d, %v)", index, err)
+		}
+	}
+}
+
+func TestWithProgress00(t *testing.T) {
+	var calls, lastDone, lastTotal int
+	progress := func(done, total int) {
+		if done <= lastDone || done > total {
+			t.Fatalf("unexpected progress %d/%d after %d/%d", done, total, lastDone, lastTotal)
+		}
+		calls++
+		lastDone, lastTotal = done, total
+	}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithProgress(progress))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%d calls, %d/%d", calls, lastDone, lastTotal)
+	// 24 leaves and 12 + 6 + 3 + 2 + 1 merkle nodes, in 24 + 5 steps.
+	if lastDone != 48 || lastTotal != 48 || calls != 29 {
+		t.Fatalf("want (29 calls, 48/48); got %d calls, %d/%d", calls, lastDone, lastTotal)
+	}
+
+	calls, lastDone = 0, 0
+	tree.AppendAndReconstruct(enAlphabetCap[:2]...)
+	t.Logf("%d calls, %d/%d", calls, lastDone, lastTotal)
+	// 2 leaves and 13 + 7 + 4 + 2 + 1 merkle nodes, in 2 + 5 steps.
+	if lastDone != 29 || lastTotal != 29 || calls != 7 {
+		t.Fatalf("want (7 calls, 29/29); got %d calls, %d/%d", calls, lastDone, lastTotal)
+	}
+	if tree.progress != nil {
+		t.Fatal("progress is still being tracked")
+	}
+}
+
+func TestWithArity00(t *testing.T) {
+	data := append(append([]Datum{}, grAlphabet...), enAlphabetCap...)
+	for _, arity := range []int{3, 4, 16} {
+		for n := 1; n <= len(data); n += 7 {
+			tree, err := NewTreeWithOptions(crypto.SHA256, data[:n], WithArity(arity))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tree.Arity() != arity {
+				t.Fatalf("want (%d); got %d", arity, tree.Arity())
+			}
+			for _, word := range data[:n] {
+				if v, err := tree.VerifyDatum(word); err != nil || !v {
+					t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+				}
+			}
+			for i := 0; i < n; i++ {
+				p, err := tree.Proof(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				b, err := json.Marshal(p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var p2 Proof
+				if err := json.Unmarshal(b, &p2); err != nil {
+					t.Fatal(err)
+				}
+				if !p.Verify(tree.MerkleRoot()) || !p2.Verify(tree.MerkleRoot()) {
+					t.Fatalf("arity %d, %d leaves: proof of leaf %d does not verify", arity, n, i)
+				}
+				if len(p2.Siblings) == 0 {
+					continue
+				}
+				p2.Siblings = p2.Siblings[1:]
+				if p2.Verify(tree.MerkleRoot()) {
+					t.Fatalf("arity %d, %d leaves: truncated proof of leaf %d verifies", arity, n, i)
+				}
+			}
+
+			b, err := tree.MarshalBinaryCompact()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tree2 Tree
+			if err := tree2.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+				t.Fatalf("want root %x; got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+			}
+		}
+	}
+}
+
+func TestWithArity01(t *testing.T) {
+	// With 5 leaves and arity 4, the root is H(H(l0 || l1 || l2 || l3) || H(l4)).
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:5], WithArity(4), InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := crypto.SHA256.New()
+	hashOf := func(bs ...[]byte) []byte {
+		h.Reset()
+		for _, b := range bs {
+			h.Write(b)
+		}
+		return h.Sum(nil)
+	}
+	var l [5][]byte
+	for i := range l {
+		l[i] = hashOf(grAlphabet[i].Serialize())
+	}
+	want := hashOf(hashOf(l[0], l[1], l[2], l[3]), hashOf(l[4]))
+	if !bytes.Equal(want, tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", want, tree.MerkleRoot())
+	}
+	if tree.Height() != 3 || tree.MerkleSize() != 3 {
+		t.Fatalf("want (3, 3); got (%d, %d)", tree.Height(), tree.MerkleSize())
+	}
+	p, err := tree.Proof(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Siblings) != 2 || len(p.Siblings[0]) != 0 {
+		t.Fatalf("want ([] + 1 sibling); got %x", p.Siblings)
+	}
+
+	// Only binary trees support range proofs, pruning, streaming and the
+	// proofs of RFC 6962, regardless of the order of the Options.
+	if _, err := tree.RangeProof(0, 2); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := tree.Prune(0); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := NewStream(crypto.SHA256, WithArity(4)); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	for _, opts := range [][]Option{{WithArity(4), RFC6962()}, {RFC6962(), WithArity(4)}} {
+		rtree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := rtree.ConsistencyProof(3, 5); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+		}
+		var buf bytes.Buffer
+		if err := rtree.WriteFlat(&buf); err != nil {
+			t.Fatal(err)
+		}
+		ftree, err := OpenFlat(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rtree.MerkleRoot(), ftree.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", rtree.MerkleRoot(), ftree.MerkleRoot())
+		}
+	}
+}
+
+func TestPadToPowerOfTwo00(t *testing.T) {
+	h := crypto.SHA256.New()
+	var digests [][]byte
+	for _, word := range enAlphabetCap[:5] {
+		h.Reset()
+		h.Write(word.Serialize())
+		digests = append(digests, h.Sum(nil))
+	}
+	zero := make([]byte, h.Size())
+	for _, tc := range []struct {
+		arity, numLeaves int
+	}{{2, 8}, {3, 9}} {
+		tree, err := NewTreeFromDigests(crypto.SHA256, digests, InsertionOrder(), WithArity(tc.arity), PadToPowerOfTwo(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		padded := append([][]byte{}, digests...)
+		for len(padded) < tc.numLeaves {
+			padded = append(padded, zero)
+		}
+		want, err := NewTreeFromDigests(crypto.SHA256, padded, InsertionOrder(), WithArity(tc.arity))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("arity %d: %x", tc.arity, tree.MerkleRoot())
+		if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+		}
+		if tree.NumLeaves() != len(digests) || tree.Height() != want.Height() {
+			t.Fatalf("want (%d, %d); got %d, %d", len(digests), want.Height(), tree.NumLeaves(), tree.Height())
+		}
+		for i := range digests {
+			p, err := tree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wp, err := want.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.NumLeaves != tc.numLeaves || len(p.Siblings) != len(wp.Siblings) || !p.Verify(want.MerkleRoot()) {
+				t.Fatalf("proof of leaf %d: want (%d, %d, true); got %d, %d, %t", i, tc.numLeaves, len(wp.Siblings), p.NumLeaves, len(p.Siblings), p.Verify(want.MerkleRoot()))
+			}
+			if v, err := tree.VerifyOrderedID(uint64(i)); err != nil || !v {
+				t.Fatalf("ERROR while verifying leaf %d: (%v, %v)", i, v, err)
+			}
+		}
+	}
+}
+
+func TestFixedDepth00(t *testing.T) {
+	emptyLeaf := crypto.SHA256.New().Sum(nil)
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:3], FixedDepth(4, emptyLeaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Height() != 5 {
+		t.Fatalf("want (%d); got %d", 5, tree.Height())
+	}
+	digests := tree.Level(0)
+	for len(digests) < 16 {
+		digests = append(digests, emptyLeaf)
+	}
+	want, err := NewTreeFromDigests(crypto.SHA256, digests, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	for _, word := range grAlphabet[:3] {
+		if v, err := tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+
+	// Growing within the fixed depth keeps it; growing past it is refused.
+	tree.AppendAndReconstruct(grAlphabet[3:16]...)
+	if tree.NumLeaves() != 16 || tree.Height() != 5 {
+		t.Fatalf("want (16, 5); got %d, %d", tree.NumLeaves(), tree.Height())
+	}
+	root := copyBytes(tree.MerkleRoot())
+	tree.AppendAndReconstruct(grAlphabet[16])
+	if tree.NumLeaves() != 16 || !bytes.Equal(tree.MerkleRoot(), root) {
+		t.Fatalf("want (16, %x); got %d, %x", root, tree.NumLeaves(), tree.MerkleRoot())
+	}
+
+	if _, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:17], FixedDepth(4, nil)); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+	}
+	if _, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:1], FixedDepth(64, nil)); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+	}
+	if _, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:1], FixedDepth(4, []byte{0})); !errors.Is(err, ErrInvalidDigest) {
+		t.Fatalf("want (%v); got %v", ErrInvalidDigest, err)
+	}
+	if _, err := tree.RangeProof(0, 2); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := tree.Prune(0); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := NewStream(crypto.SHA256, PadToPowerOfTwo(nil)); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestFixedDepth01(t *testing.T) {
+	for _, opts := range [][]Option{
+		{PadToPowerOfTwo(nil)},
+		{FixedDepth(5, crypto.SHA256.New().Sum(nil)), RFC6962()},
+		{FixedDepth(3, nil), WithArity(3)},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:7], opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var trees []*Tree
+		for _, marshal := range []func() ([]byte, error){tree.MarshalBinary, tree.MarshalBinaryCompact} {
+			b, err := marshal()
+			if err != nil {
+				t.Fatal(err)
+			}
+			tree2 := new(Tree)
+			if err := tree2.UnmarshalBinary(b); err != nil {
+				t.Fatal(err)
+			}
+			trees = append(trees, tree2)
+		}
+		b, err := json.Marshal(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree2 := new(Tree)
+		if err := json.Unmarshal(b, tree2); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+		if b, err = tree.MarshalCBOR(); err != nil {
+			t.Fatal(err)
+		}
+		tree2 = new(Tree)
+		if err := tree2.UnmarshalCBOR(b); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+		var buf bytes.Buffer
+		if err := tree.WriteFlat(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if tree2, err = OpenFlat(buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+
+		for i, tree2 := range trees {
+			if !tree2.scheme.equal(&tree.scheme) {
+				t.Fatalf("encoding %d: want (%+v); got %+v", i, tree.scheme, tree2.scheme)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+				t.Fatalf("encoding %d: want (%x); got %x", i, tree.MerkleRoot(), tree2.MerkleRoot())
+			}
+			p, err := tree2.Proof(6)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(tree.MerkleRoot()) {
+				t.Fatalf("encoding %d: proof of leaf 6 does not verify", i)
+			}
+		}
+	}
+}
+
+func TestTruncateDigests00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:2], InsertionOrder(), TruncateDigests(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l0, l1 := sha256.Sum256(grAlphabet[0].Serialize()), sha256.Sum256(grAlphabet[1].Serialize())
+	want := sha256.Sum256(append(append([]byte{}, l0[:16]...), l1[:16]...))
+	if !bytes.Equal(tree.MerkleRoot(), want[:16]) {
+		t.Fatalf("want (%x); got %x", want[:16], tree.MerkleRoot())
+	}
+
+	tree, err = NewTreeWithOptions(crypto.SHA256, grAlphabet, TruncateDigests(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, word := range grAlphabet {
+		if v, err := tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+	tree.AppendAndReconstruct(StringDatum("omega2"))
+	p, err := tree.Proof(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.LeafDigest) != 16 || !p.Verify(tree.MerkleRoot()) {
+		t.Fatalf("want (16, true); got (%d, %v)", len(p.LeafDigest), p.Verify(tree.MerkleRoot()))
+	}
+	t.Logf("truncated root: %x", tree.MerkleRoot())
+
+	// Sizes that are not smaller than the digests have no effect.
+	full, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, -1, 32, 64} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, TruncateDigests(size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), full.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", full.MerkleRoot(), tree.MerkleRoot())
+		}
+	}
+}
+
+func TestTruncateDigests01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, TruncateDigests(16), WithArity(3), PadToPowerOfTwo(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tree.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree2 := &Tree{}
+	if err := tree2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree2.MerkleRoot(), tree.MerkleRoot()) || tree2.scheme.digestSize != 16 {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	if b, err = json.Marshal(tree); err != nil {
+		t.Fatal(err)
+	}
+	tree2 = &Tree{}
+	if err := json.Unmarshal(b, tree2); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree2.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	if b, err = tree.MarshalCBOR(); err != nil {
+		t.Fatal(err)
+	}
+	tree2 = &Tree{}
+	if err := tree2.UnmarshalCBOR(b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree2.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+	var buf bytes.Buffer
+	if err := tree.WriteFlat(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if tree2, err = OpenFlat(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree2.MerkleRoot(), tree.MerkleRoot()) || tree2.scheme.digestSize != 16 {
+		t.Fatalf("want (%x); got %x", tree.MerkleRoot(), tree2.MerkleRoot())
+	}
+
+	// The proofs of binary trees record the size as well.
+	tree, err = NewTreeWithOptions(crypto.SHA256, grAlphabet, TruncateDigests(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.Proof(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodings := []struct {
+		marshal   func() ([]byte, error)
+		unmarshal func(*Proof, []byte) error
+	}{
+		{p.MarshalCompact, (*Proof).UnmarshalCompact},
+		{p.MarshalCBOR, (*Proof).UnmarshalCBOR},
+		{p.MarshalJSON, (*Proof).UnmarshalJSON},
+	}
+	for _, e := range encodings {
+		b, err := e.marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p2 Proof
+		if err := e.unmarshal(&p2, b); err != nil {
+			t.Fatal(err)
+		}
+		if !p2.Verify(tree.MerkleRoot()) {
+			t.Fatalf("want (true); got false for %x", b)
+		}
+	}
+	pt, err := tree.Prune(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err = pt.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+	var pt2 PartialTree
+	if err := pt2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !pt2.Verify() {
+		t.Fatalf("want (true); got false")
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "strconv"
+
+// PaddingPolicy determines how a merkle node is calculated when it has fewer
+// children than the arity of the merkle tree; e.g. the parent of the last
+// node of an odd-sized level of a binary merkle tree.
+type PaddingPolicy int
+
+const (
+	// HashLone hashes the children that are present on their own; i.e. a
+	// lone node n is succeeded by its parent H(n). It is the default.
+	HashLone PaddingPolicy = iota
+	// PromoteLone promotes a lone node to the next level as is, rather
+	// than hash it, as RFC 6962 does; otherwise, it is the same as
+	// HashLone.
+	PromoteLone
+	// DuplicateLast pads the children with copies of the last one, as
+	// Bitcoin does; i.e. a lone node n is succeeded by its parent
+	// H(n || n). Beware that a level of an odd number of nodes then
+	// leads to the same merkle root as the one with its last node repeated.
+	DuplicateLast
+	// PairWithZero pads the children with all-zero digests, as the
+	// schemes of fixed-depth merkle trees do; i.e. a lone node n is
+	// succeeded by its parent H(n || 0).
+	PairWithZero
+
+	numPaddingPolicies
+)
+
+// String returns the name of the PaddingPolicy.
+func (p PaddingPolicy) String() string {
+	switch p {
+	case HashLone:
+		return "HashLone"
+	case PromoteLone:
+		return "PromoteLone"
+	case DuplicateLast:
+		return "DuplicateLast"
+	case PairWithZero:
+		return "PairWithZero"
+	}
+	return "PaddingPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// valid reports whether p is one of the known PaddingPolicy values.
+func (p PaddingPolicy) valid() bool {
+	return p >= 0 && p < numPaddingPolicies
+}
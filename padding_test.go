@@ -0,0 +1,185 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"testing"
+)
+
+func TestPaddingPolicy00(t *testing.T) {
+	// With 3 leaves, the root is H(H(l0 || l1) || pad(l2)).
+	h := crypto.SHA256.New()
+	hashOf := func(bs ...[]byte) []byte {
+		h.Reset()
+		for _, b := range bs {
+			h.Write(b)
+		}
+		return h.Sum(nil)
+	}
+	digests := [][]byte{hashOf([]byte("a")), hashOf([]byte("b")), hashOf([]byte("c"))}
+	left := hashOf(digests[0], digests[1])
+	zero := make([]byte, h.Size())
+	for _, tc := range []struct {
+		padding PaddingPolicy
+		want    []byte
+	}{
+		{HashLone, hashOf(left, hashOf(digests[2]))},
+		{PromoteLone, hashOf(left, digests[2])},
+		{DuplicateLast, hashOf(left, hashOf(digests[2], digests[2]))},
+		{PairWithZero, hashOf(left, hashOf(digests[2], zero))},
+	} {
+		tree, err := NewTreeFromDigests(crypto.SHA256, digests, InsertionOrder(), WithPaddingPolicy(tc.padding))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%v: %x", tc.padding, tree.MerkleRoot())
+		if !bytes.Equal(tree.MerkleRoot(), tc.want) {
+			t.Fatalf("%v: want (%x); got %x", tc.padding, tc.want, tree.MerkleRoot())
+		}
+	}
+}
+
+func TestPaddingPolicy01(t *testing.T) {
+	data := append(append([]Datum{}, grAlphabet...), enAlphabetCap...)
+	for _, padding := range []PaddingPolicy{HashLone, PromoteLone, DuplicateLast, PairWithZero} {
+		for _, arity := range []int{2, 3} {
+			for n := 1; n <= len(data); n += 5 {
+				tree, err := NewTreeWithOptions(crypto.SHA256, data[:n], WithArity(arity), WithPaddingPolicy(padding))
+				if err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < n; i++ {
+					p, err := tree.Proof(i)
+					if err != nil {
+						t.Fatal(err)
+					}
+					b, err := json.Marshal(p)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var p2 Proof
+					if err := json.Unmarshal(b, &p2); err != nil {
+						t.Fatal(err)
+					}
+					if !p.Verify(tree.MerkleRoot()) || !p2.Verify(tree.MerkleRoot()) {
+						t.Fatalf("%v, arity %d, %d leaves: proof of leaf %d does not verify", padding, arity, n, i)
+					}
+				}
+				for _, word := range data[:n] {
+					if v, err := tree.VerifyDatum(word); err != nil || !v {
+						t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPaddingPolicy02(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithPaddingPolicy(DuplicateLast)},
+		{WithPaddingPolicy(PairWithZero), WithArity(3)},
+		{RFC6962(), WithPaddingPolicy(HashLone)},
+		{RFC6962(), WithPaddingPolicy(DuplicateLast)},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:7], opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var trees []*Tree
+		b, err := tree.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree2 := new(Tree)
+		if err := tree2.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+		if b, err = tree.MarshalBinaryCompact(); err != nil {
+			t.Fatal(err)
+		}
+		tree2 = new(Tree)
+		if err := tree2.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+		if b, err = json.Marshal(tree); err != nil {
+			t.Fatal(err)
+		}
+		tree2 = new(Tree)
+		if err := json.Unmarshal(b, tree2); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+		if b, err = tree.MarshalCBOR(); err != nil {
+			t.Fatal(err)
+		}
+		tree2 = new(Tree)
+		if err := tree2.UnmarshalCBOR(b); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+		var buf bytes.Buffer
+		if err := tree.WriteFlat(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if tree2, err = OpenFlat(buf.Bytes()); err != nil {
+			t.Fatal(err)
+		}
+		trees = append(trees, tree2)
+
+		for i, tree2 := range trees {
+			if tree2.scheme.padding != tree.scheme.padding {
+				t.Fatalf("encoding %d: want (%v); got %v", i, tree.scheme.padding, tree2.scheme.padding)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+				t.Fatalf("encoding %d: want (%x); got %x", i, tree.MerkleRoot(), tree2.MerkleRoot())
+			}
+		}
+	}
+}
+
+func TestPaddingPolicy03(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:7], WithPaddingPolicy(PairWithZero))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := tree.Prune(2, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := pt.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pt2 PartialTree
+	if err := pt2.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !pt.Verify() || !pt2.Verify() {
+		t.Fatalf("want (true, true); got %t, %t", pt.Verify(), pt2.Verify())
+	}
+
+	if _, err := tree.ConsistencyProof(3, 7); err != ErrUnsupported {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if s := PaddingPolicy(42).String(); s != "PaddingPolicy(42)" {
+		t.Fatalf("want (%q); got %q", "PaddingPolicy(42)", s)
+	}
+}
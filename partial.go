@@ -0,0 +1,353 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"sort"
+)
+
+// PartialTree is a pruned merkle tree, comprising the merkle root, a subset
+// of the leaves, and just enough of the remaining nodes to recalculate the
+// merkle root out of them; i.e. a multi-leaf inclusion proof that can be
+// stored and verified offline.
+type PartialTree struct {
+	// Hash is the hash function that the merkle tree was built with.
+	Hash crypto.Hash
+	// NumLeaves is the number of leaves in the merkle tree.
+	NumLeaves int
+	// Root is the merkle root of the merkle tree.
+	Root []byte
+	// Leaves are the leaves retained, ordered by their indices.
+	Leaves []PartialLeaf
+	// Nodes are the digests of the nodes that are needed in addition to
+	// the retained leaves, ordered by height and index.
+	Nodes []PartialNode
+
+	// scheme is the hashing scheme of the merkle tree, if not the default.
+	scheme *scheme
+}
+
+// PartialLeaf is a leaf retained in a PartialTree.
+type PartialLeaf struct {
+	// Index is the index of the leaf among the leaves of the merkle tree.
+	Index int
+	// Digest is the hash digest of the leaf.
+	Digest []byte
+	// Datum is the serialized datum of the leaf, or nil if the merkle tree
+	// is in digest-only mode, or if its leaves are keyed or salted (see
+	// WithLeafKey and WithSalts), hence their data cannot be verified.
+	Datum []byte
+}
+
+// PartialNode is a node retained in a PartialTree.
+type PartialNode struct {
+	NodeID
+	// Digest is the hash digest of the node.
+	Digest []byte
+}
+
+// Prune returns a PartialTree that retains the leaves at the given indices.
+//
+// It returns a non-nil error if no indices are given, if any of them is out
+// of range, if the arity of the merkle tree is greater than 2, or if it is
+// padded (see PadToPowerOfTwo).
+func (t *Tree) Prune(leafIndices ...int) (*PartialTree, error) {
+	if len(leafIndices) == 0 {
+		return nil, ErrNoData
+	}
+	if t.scheme.width() != 2 || t.scheme.padded {
+		return nil, ErrUnsupported
+	}
+	pt := &PartialTree{
+		Hash:      t.hash,
+		NumLeaves: len(t.tls),
+		Root:      t.MerkleRoot(),
+		scheme:    t.proofScheme(),
+	}
+
+	known := make(map[int]bool, len(leafIndices))
+	for _, i := range leafIndices {
+		if i < 0 || i >= len(t.tls) {
+			return nil, ErrInvalidRange
+		}
+		if known[i] {
+			continue
+		}
+		known[i] = true
+		pl := PartialLeaf{Index: i, Digest: copyBytes(t.tls[i].digest)}
+		if t.tls[i].datum != nil && t.scheme.leafKey == nil && !t.scheme.salted {
+			pl.Datum = copyBytes(t.leafDatum(&t.tls[i]))
+		}
+		pt.Leaves = append(pt.Leaves, pl)
+	}
+	sort.Slice(pt.Leaves, func(i, j int) bool {
+		return pt.Leaves[i].Index < pt.Leaves[j].Index
+	})
+
+	// Climb up level by level, retaining the siblings that cannot be
+	// calculated out of what is already known.
+	for height := 0; height < len(t.rows); height++ {
+		indices := make([]int, 0, len(known))
+		for i := range known {
+			indices = append(indices, i)
+		}
+		sort.Ints(indices)
+		parents := make(map[int]bool, len(known))
+		for _, i := range indices {
+			if sibling := i ^ 1; sibling < t.levelWidth(height) && !known[sibling] {
+				pt.Nodes = append(pt.Nodes, PartialNode{
+					NodeID: NodeID{Height: height, Index: sibling},
+					Digest: copyBytes(t.nodeAt(height, sibling)),
+				})
+			}
+			parents[i/2] = true
+		}
+		known = parents
+	}
+	return pt, nil
+}
+
+// Verify verifies that the retained leaves and nodes of the PartialTree lead
+// to its merkle root, and that the retained serialized data (if any) match
+// the digests of their leaves.
+func (pt *PartialTree) Verify() bool {
+	s := schemeOrDefault(pt.scheme)
+	if !s.available(pt.Hash) || pt.NumLeaves <= 0 || len(pt.Leaves) == 0 {
+		return false
+	}
+	h := s.newHasher(pt.Hash)
+
+	known := make(map[int][]byte, len(pt.Leaves))
+	for _, pl := range pt.Leaves {
+		if pl.Index < 0 || pl.Index >= pt.NumLeaves || len(pl.Digest) == 0 || known[pl.Index] != nil {
+			return false
+		}
+		if pl.Datum != nil && !bytes.Equal(s.hashLeaf(h, pl.Datum), pl.Digest) {
+			return false
+		}
+		known[pl.Index] = pl.Digest
+	}
+	nodes := pt.Nodes
+	height := 0
+	for width := pt.NumLeaves; width > 1; height, width = height+1, (width+1)/2 {
+		for len(nodes) > 0 && nodes[0].Height == height {
+			if nodes[0].Index < 0 || nodes[0].Index >= width || len(nodes[0].Digest) == 0 || known[nodes[0].Index] != nil {
+				return false
+			}
+			known[nodes[0].Index] = nodes[0].Digest
+			nodes = nodes[1:]
+		}
+		parents := make(map[int][]byte, (len(known)+1)/2)
+		for i, digest := range known {
+			if i%2 == 1 {
+				if known[i-1] == nil {
+					return false
+				}
+				continue
+			}
+			if i+1 >= width {
+				parents[i/2] = s.hashLone(h, digest)
+			} else if sibling := known[i+1]; sibling != nil {
+				parents[i/2] = s.hashNode(h, digest, sibling)
+			} else {
+				return false
+			}
+		}
+		known = parents
+	}
+	return len(nodes) == 0 && len(known) == 1 && bytes.Equal(known[0], pt.Root)
+}
+
+// VerifySerializedDatum verifies that the PartialTree retains the leaf of the
+// given serialized datum, and that it leads to its merkle root.
+func (pt *PartialTree) VerifySerializedDatum(serializedDatum []byte) bool {
+	s := schemeOrDefault(pt.scheme)
+	if !s.available(pt.Hash) {
+		return false
+	}
+	digest := s.hashLeaf(s.newHasher(pt.Hash), serializedDatum)
+	for _, pl := range pt.Leaves {
+		if bytes.Equal(pl.Digest, digest) {
+			return pt.Verify()
+		}
+	}
+	return false
+}
+
+// partialMagic prefixes every binary encoding of a PartialTree.
+var partialMagic = []byte("MRKP")
+
+const (
+	partialFlagDigestOnly byte = 1 << iota
+	partialFlagRFC6962
+	partialFlagPadding
+	partialFlagSortedPairs
+	partialFlagLengthPrefixed
+	partialFlagDigestSize
+)
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The binary encoding is versioned (along with that of Tree) and
+// deterministic.
+//
+// It returns a non-nil error if the hash function of the merkle tree was
+// given through WithHashFunc, or if some of the retained leaves have data
+// while others do not.
+func (pt *PartialTree) MarshalBinary() ([]byte, error) {
+	if pt.scheme != nil && pt.scheme.newHash != nil {
+		return nil, ErrUnsupported
+	}
+	var flags byte
+	if len(pt.Leaves) > 0 && pt.Leaves[0].Datum == nil {
+		flags |= partialFlagDigestOnly
+	}
+	for _, pl := range pt.Leaves {
+		if (pl.Datum == nil) != (flags&partialFlagDigestOnly != 0) {
+			return nil, ErrUnsupported
+		}
+	}
+	if pt.scheme != nil && pt.scheme.isRFC6962() {
+		flags |= partialFlagRFC6962
+	}
+	if pt.scheme != nil && pt.scheme.padding != pt.scheme.impliedPadding() {
+		flags |= partialFlagPadding
+	}
+	if pt.scheme != nil && pt.scheme.sortedPairs {
+		flags |= partialFlagSortedPairs
+	}
+	if pt.scheme != nil && pt.scheme.lengthPrefixed {
+		flags |= partialFlagLengthPrefixed
+	}
+	if pt.scheme != nil && pt.scheme.digestSize != 0 {
+		flags |= partialFlagDigestSize
+	}
+	b := append([]byte(nil), partialMagic...)
+	b = append(b, binaryVersion, flags)
+	if flags&partialFlagPadding != 0 {
+		b = append(b, byte(pt.scheme.padding))
+	}
+	if flags&partialFlagDigestSize != 0 {
+		b = binary.AppendUvarint(b, uint64(pt.scheme.digestSize))
+	}
+	b = binary.AppendUvarint(b, uint64(pt.Hash))
+	b = binary.AppendUvarint(b, uint64(pt.NumLeaves))
+	b = binary.AppendUvarint(b, uint64(len(pt.Root)))
+	b = append(b, pt.Root...)
+	b = binary.AppendUvarint(b, uint64(len(pt.Leaves)))
+	for _, pl := range pt.Leaves {
+		b = binary.AppendUvarint(b, uint64(pl.Index))
+		b = binary.AppendUvarint(b, uint64(len(pl.Digest)))
+		b = append(b, pl.Digest...)
+		if flags&partialFlagDigestOnly == 0 {
+			b = binary.AppendUvarint(b, uint64(len(pl.Datum)))
+			b = append(b, pl.Datum...)
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(len(pt.Nodes)))
+	for _, pn := range pt.Nodes {
+		b = binary.AppendUvarint(b, uint64(pn.Height))
+		b = binary.AppendUvarint(b, uint64(pn.Index))
+		b = binary.AppendUvarint(b, uint64(len(pn.Digest)))
+		b = append(b, pn.Digest...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+//
+// It does not verify the decoded PartialTree; see Verify.
+func (pt *PartialTree) UnmarshalBinary(data []byte) error {
+	d := binaryDecoder{buf: data}
+	if string(d.next(len(partialMagic))) != string(partialMagic) || d.byte() != binaryVersion {
+		return ErrInvalidEncoding
+	}
+	flags := d.byte()
+	var padding PaddingPolicy
+	if flags&partialFlagPadding != 0 {
+		if padding = PaddingPolicy(d.byte()); !padding.valid() {
+			return ErrInvalidEncoding
+		}
+	}
+	var digestSize uint64
+	if flags&partialFlagDigestSize != 0 {
+		if digestSize = d.uvarint(); d.err || digestSize == 0 || digestSize > uint64(len(data)) {
+			return ErrInvalidEncoding
+		}
+	}
+	pt2 := &PartialTree{
+		Hash:      crypto.Hash(d.uvarint()),
+		NumLeaves: d.int(),
+		Root:      d.next(d.int()),
+	}
+	if flags&partialFlagRFC6962 != 0 {
+		s := rfc6962Scheme
+		pt2.scheme = &s
+	}
+	if flags&partialFlagPadding != 0 {
+		if pt2.scheme == nil {
+			pt2.scheme = &scheme{}
+		}
+		pt2.scheme.padding = padding
+	}
+	if flags&partialFlagSortedPairs != 0 {
+		if pt2.scheme == nil {
+			pt2.scheme = &scheme{}
+		}
+		pt2.scheme.sortedPairs = true
+	}
+	if flags&partialFlagLengthPrefixed != 0 {
+		if pt2.scheme == nil {
+			pt2.scheme = &scheme{}
+		}
+		pt2.scheme.lengthPrefixed = true
+	}
+	if digestSize != 0 {
+		if pt2.scheme == nil {
+			pt2.scheme = &scheme{}
+		}
+		pt2.scheme.digestSize = int(digestSize)
+	}
+	numLeaves := d.uvarint()
+	if d.err || numLeaves > uint64(len(d.buf)) {
+		return ErrInvalidEncoding
+	}
+	pt2.Leaves = make([]PartialLeaf, numLeaves)
+	for i := range pt2.Leaves {
+		pt2.Leaves[i].Index = d.int()
+		pt2.Leaves[i].Digest = d.next(d.int())
+		if flags&partialFlagDigestOnly == 0 {
+			pt2.Leaves[i].Datum = d.next(d.int())
+		}
+	}
+	numNodes := d.uvarint()
+	if d.err || numNodes > uint64(len(d.buf)) {
+		return ErrInvalidEncoding
+	}
+	pt2.Nodes = make([]PartialNode, numNodes)
+	for i := range pt2.Nodes {
+		pt2.Nodes[i].Height = d.int()
+		pt2.Nodes[i].Index = d.int()
+		pt2.Nodes[i].Digest = d.next(d.int())
+	}
+	if d.err || len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	*pt = *pt2
+	return nil
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestPrune00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, indices := range [][]int{{0}, {23}, {3, 4}, {0, 23}, {5, 6, 7, 8, 17}, {2, 2, 9}} {
+		pt, err := tree.Prune(indices...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%v: %d leaves, %d nodes", indices, len(pt.Leaves), len(pt.Nodes))
+		if !pt.Verify() {
+			t.Fatalf("partial tree of %v does not verify", indices)
+		}
+		for _, i := range indices {
+			if !pt.VerifySerializedDatum(tree.tls[i].datum) {
+				t.Fatalf("partial tree of %v does not verify leaf %d", indices, i)
+			}
+		}
+
+		data, err := pt.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pt2 PartialTree
+		if err = pt2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if !pt2.Verify() {
+			t.Fatalf("decoded partial tree of %v does not verify", indices)
+		}
+		if pt2.VerifySerializedDatum(kk.Serialize()) {
+			t.Fatalf("partial tree of %v verifies \"%s\"", indices, kk)
+		}
+
+		pt2.Leaves[0].Datum[0] ^= 0xff
+		if pt2.Verify() {
+			t.Fatalf("tampered partial tree of %v verifies", indices)
+		}
+	}
+
+	if _, err = tree.Prune(); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err = tree.Prune(24); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+	}
+}
+func TestPrune01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, DigestOnly(), RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pt, err := tree.Prune(1, 25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := pt.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pt2 PartialTree
+	if err = pt2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if !pt2.Verify() || !pt2.VerifySerializedDatum(Z.Serialize()) {
+		t.Fatal("decoded partial tree does not verify")
+	}
+	if pt2.Leaves[0].Datum != nil {
+		t.Fatalf("want no datum; got %q", pt2.Leaves[0].Datum)
+	}
+	pt2.Nodes = pt2.Nodes[1:]
+	if pt2.Verify() {
+		t.Fatal("partial tree with a missing node verifies")
+	}
+	for _, bad := range [][]byte{nil, data[:len(data)-1], append(data, 0)} {
+		if err = pt2.UnmarshalBinary(bad); err == nil {
+			t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+		}
+	}
+
+	// Leaves with and without data cannot be encoded together.
+	pt.Leaves[1].Datum = Z.Serialize()
+	if _, err = pt.MarshalBinary(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	pt.Leaves[0].Datum, pt.Leaves[1].Datum = pt.Leaves[1].Datum, nil
+	if _, err = pt.MarshalBinary(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
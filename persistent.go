@@ -0,0 +1,136 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "hash"
+
+// The leaves and the merkle nodes of a merkle tree are never modified in place;
+// every (re)construction lays out new slices of them, which may share the
+// digests of the unchanged leaves with their predecessors, and copies the
+// digests of the unchanged merkle nodes from theirs. Hence, a merkle tree
+// whose nodes are kept in memory can be snapshotted in O(1), and mutated
+// persistently (i.e. into a new tree, leaving the original intact) at the cost
+// of rehashing the changed parts alone.
+
+// Snapshot returns a merkle tree that is identical to the given one, and is
+// left intact by any subsequent mutation of it (e.g. through
+// AppendAndReconstruct), and vice versa. Both trees may be read concurrently.
+//
+// Snapshot takes O(1) time and space, as the two trees share their leaves and
+// merkle nodes; the merkle nodes of a merkle tree that reads them through a
+// NodeStore (see WithNodeStore), though, are copied into memory, as through
+// Clone.
+func (t *Tree) Snapshot() *Tree {
+	if t.userStore {
+		return t.Clone()
+	}
+	t2 := *t
+	t2.progress = nil
+	t2.history = t.history[:len(t.history):len(t.history)]
+	if n, ok := t.store.(*heapNodes); ok {
+		n.pinned.Store(true)
+	}
+	return &t2
+}
+
+// Appended returns a new merkle tree whose leaves are the ones of the given
+// tree followed by the given data, leaving the given tree intact; i.e. it is
+// the persistent counterpart of AppendAndReconstruct. The two trees share the
+// digests of the leaves that precede the new ones, and the merkle nodes over
+// complete subtrees of them are copied rather than rehashed, so only
+// O(len(data)+log(L)) of the merkle nodes are rehashed, and both trees may be
+// read concurrently.
+//
+// The merkle nodes of the new tree are kept in memory, even if the given tree
+// reads them through a NodeStore (see WithNodeStore).
+//
+// It returns a non-nil error if no data are given, if any of them is nil,
+// fails to be serialized or is already present while duplicates are rejected
+// (see RejectDuplicates), or if the fixed depth of the merkle tree (see
+// FixedDepth) does not allow for them.
+func (t *Tree) Appended(data ...Datum) (*Tree, error) {
+	if len(data) == 0 {
+		return nil, ErrNoData
+	}
+	for i := range data {
+		if data[i] == nil {
+			return nil, ErrNoData
+		}
+	}
+	if err := t.checkPadding(len(t.tls) + len(data)); err != nil {
+		return nil, err
+	}
+	h := t.newHasher()
+	t2 := *t
+	t2.history = t.history[:len(t.history):len(t.history)]
+	t2.pushVersion()
+	t2.beginProgress(len(data), len(t.tls)+len(data))
+	defer t2.endProgress()
+	tls, _, err := t2.appendTreeLeaves(h, t.tls, data)
+	if err != nil {
+		return nil, dataError("Appended", err)
+	}
+	t2.nextID += uint64(len(tls) - len(t.tls))
+	t2.beginMutation("Appended", len(tls)-len(t.tls), 0)
+	return t2.derive(h, tls)
+}
+
+// Deleted returns a new merkle tree whose leaves are the ones of the given
+// tree without the given data, leaving the given tree intact; i.e. it is the
+// persistent counterpart of DeleteAndReconstruct. As with Appended, the two
+// trees share the leaves that precede the first deleted one, and nothing over
+// them is rehashed.
+//
+// It returns a non-nil error if no data are given, if any of them is nil or
+// fails to be serialized, or if no leaves would be left.
+func (t *Tree) Deleted(data ...Datum) (*Tree, error) {
+	if len(data) == 0 {
+		return nil, ErrNoData
+	}
+	for i := range data {
+		if data[i] == nil {
+			return nil, ErrNoData
+		}
+	}
+	h := t.newHasher()
+	t2 := *t
+	tls, removed, _, err := t2.deleteTreeLeaves(h, t.tls, data)
+	if err != nil {
+		return nil, &DataError{Op: "Deleted", Err: err}
+	}
+	if len(tls) == 0 {
+		return nil, ErrNoData
+	}
+	t2.history = t.history[:len(t.history):len(t.history)]
+	t2.pushVersion()
+	t2.beginMutation("Deleted", 0, len(removed))
+	t2.beginProgress(0, len(tls))
+	defer t2.endProgress()
+	return t2.derive(h, tls)
+}
+
+// derive turns the given shallow copy of a merkle tree into a new one of the
+// given leaves, constructing its merkle nodes out of the ones of the copy.
+func (t *Tree) derive(h hash.Hash, tls []treeLeaf) (*Tree, error) {
+	// The merkle nodes of the new merkle tree are kept in memory, hence
+	// they are all copied out of the NodeStore of the copy, if any.
+	n := t.rebuildMerkleNodes(h, tls, t.unchangedLeaves(tls), false)
+	t.tls = tls
+	t.store, t.userStore, t.storeErr = nil, false, nil
+	if err := t.setNodes(n); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"sync"
+	"testing"
+)
+
+// summingHash is a hash.Hash that counts the digests it produces.
+type summingHash struct {
+	hash.Hash
+	sums *int
+}
+
+func (h *summingHash) Sum(b []byte) []byte {
+	*h.sums++
+	return h.Hash.Sum(b)
+}
+
+func TestSnapshot00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:10]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := tree.Snapshot()
+	root := copyBytes(tree.MerkleRoot())
+	tree.AppendAndReconstruct(grAlphabet[10:]...)
+	tree.DeleteAndReconstruct(grAlphabet[0])
+	t.Logf("snap.MerkleRoot(): %x", snap.MerkleRoot())
+	t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+
+	if !bytes.Equal(snap.MerkleRoot(), root) || snap.NumLeaves() != 10 {
+		t.Fatalf("want (%x, 10); got %x, %d", root, snap.MerkleRoot(), snap.NumLeaves())
+	}
+	for _, word := range grAlphabet[:10] {
+		if v, err := snap.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+	if v, _ := snap.VerifyDatum(grAlphabet[10]); v {
+		t.Fatalf("want (false); got %t", v)
+	}
+
+	store := &mapStore{nodes: map[NodeID][]byte{}}
+	stored, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap = stored.Snapshot()
+	stored.AppendAndReconstruct(grAlphabet[10:]...)
+	if !bytes.Equal(snap.MerkleRoot(), root) {
+		t.Fatalf("want (%x); got %x", root, snap.MerkleRoot())
+	}
+}
+
+func TestAppended00(t *testing.T) {
+	data := append(append([]Datum{}, grAlphabet...), enAlphabetCap...)
+	for _, opts := range [][]Option{{InsertionOrder()}, {}, {WithArity(3), RFC6962()}} {
+		versions := make([]*Tree, 1, len(data))
+		var err error
+		if versions[0], err = NewTreeWithOptions(crypto.SHA256, data[:1], opts...); err != nil {
+			t.Fatal(err)
+		}
+		for n := 2; n <= len(data); n++ {
+			tree, err := versions[len(versions)-1].Appended(data[n-1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			versions = append(versions, tree)
+		}
+		for i, tree := range versions {
+			want, err := NewTreeWithOptions(crypto.SHA256, data[:i+1], opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+				t.Fatalf("version %d: want (%x); got %x", i, want.MerkleRoot(), tree.MerkleRoot())
+			}
+			for _, word := range data[:i+1] {
+				if v, err := tree.VerifyDatum(word); err != nil || !v {
+					t.Fatalf("version %d: ERROR while verifying \"%s\": (%v, %v)", i, word, v, err)
+				}
+			}
+		}
+	}
+
+	// Complete subtrees are copied rather than rehashed; i.e. only the
+	// new leaf and the nodes along its path are hashed.
+	var sums int
+	newHash := func() hash.Hash { return &summingHash{Hash: sha256.New(), sums: &sums} }
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:8], InsertionOrder(), WithHashFunc("SHA-256", newHash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums = 0
+	tree2, err := tree.Appended(grAlphabet[8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sums > 1+tree2.Height() {
+		t.Fatalf("want (at most %d) digests; got %d", 1+tree2.Height(), sums)
+	}
+	for level := 1; level < tree.Height(); level++ {
+		if a, b := tree.nodeAt(level, 0), tree2.nodeAt(level, 0); !bytes.Equal(a, b) || &a[0] == &b[0] {
+			t.Fatalf("level %d: want the first node copied", level)
+		}
+	}
+
+	if _, err := tree.Appended(); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := tree.Appended(A, nil); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+
+func TestAppended01(t *testing.T) {
+	// Readers of earlier versions are not disturbed by later ones.
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:1], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for _, datum := range grAlphabet[1:] {
+		next, err := tree.Appended(datum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func(tree *Tree, root []byte) {
+			defer wg.Done()
+			for i := 0; i < tree.NumLeaves(); i++ {
+				p, err := tree.Proof(i)
+				if err != nil || !p.Verify(root) {
+					t.Errorf("proof of leaf %d does not verify: %v", i, err)
+				}
+			}
+		}(tree, copyBytes(tree.MerkleRoot()))
+		tree = next
+	}
+	wg.Wait()
+}
+
+func TestDeleted00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := copyBytes(tree.MerkleRoot())
+	tree2, err := tree.Deleted(grAlphabet[20:]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewTree(crypto.SHA256, grAlphabet[:20]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree2.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want (%x); got %x", want.MerkleRoot(), tree2.MerkleRoot())
+	}
+	if !bytes.Equal(tree.MerkleRoot(), root) || tree.NumLeaves() != len(grAlphabet) {
+		t.Fatalf("want (%x, %d); got %x, %d", root, len(grAlphabet), tree.MerkleRoot(), tree.NumLeaves())
+	}
+	if _, err := tree2.Deleted(grAlphabet[:20]...); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PrintOptions configures the output of Tree.Print.
+type PrintOptions struct {
+	// DigestBytes is the number of leading bytes of each digest to print;
+	// zero (or less) prints the whole digests.
+	DigestBytes int
+	// Data prints the (quoted) serialized datum of each leaf too, unless
+	// the merkle tree is in digest-only mode.
+	Data bool
+}
+
+// String returns a short description of the merkle tree, comprising its hash
+// function, its number of leaves, its height and its truncated merkle root.
+func (t *Tree) String() string {
+	return fmt.Sprintf("merkle.Tree{hash: %v, leaves: %d, height: %d, root: %s}",
+		t.hash, len(t.tls), t.Height(), shortHex(t.root()))
+}
+
+// Print writes a human-readable rendering of the merkle tree to the given
+// io.Writer, one node per line, starting from the root and indenting each
+// node under its parent. Merkle nodes are labeled by their height and index,
+// and leaves by their index and ordered ID; a node that has no siblings (e.g.
+// the last one of an odd-sized level of a binary tree) is marked as lone.
+func (t *Tree) Print(w io.Writer, opts PrintOptions) error {
+	var b bytes.Buffer
+	t.print(&b, &opts, len(t.rows), 0, 0)
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func (t *Tree) print(b *bytes.Buffer, opts *PrintOptions, height, index, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if height == 0 {
+		fmt.Fprintf(b, "[%d] #%d %s", index, t.tls[index].orderedID, opts.hex(t.tls[index].digest))
+		if opts.Data && !t.digestOnly {
+			fmt.Fprintf(b, " %q", t.leafDatum(&t.tls[index]))
+		}
+	} else {
+		fmt.Fprintf(b, "(%d, %d) %s", height, index, opts.hex(t.nodeAt(height, index)))
+	}
+	if height < len(t.rows) && index%t.scheme.width() == 0 && index+1 == t.levelWidth(height) {
+		b.WriteString(" (lone)")
+	}
+	b.WriteByte('\n')
+
+	if height == 0 {
+		return
+	}
+	for child := t.scheme.width() * index; child < t.scheme.width()*(index+1); child++ {
+		if child < t.levelWidth(height-1) {
+			t.print(b, opts, height-1, child, depth+1)
+		}
+	}
+}
+
+func (opts *PrintOptions) hex(digest []byte) string {
+	return truncatedHex(digest, opts.DigestBytes)
+}
+
+// truncatedHex returns the hexadecimal encoding of the first n bytes of the
+// given digest, or of the whole digest if n is not positive.
+func truncatedHex(digest []byte, n int) string {
+	if n > 0 && n < len(digest) {
+		return hex.EncodeToString(digest[:n]) + "..."
+	}
+	return hex.EncodeToString(digest)
+}
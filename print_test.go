@@ -0,0 +1,68 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"strings"
+	"testing"
+)
+
+func TestString00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(tree)
+	want := "merkle.Tree{hash: SHA-256, leaves: 5, height: 4, root: " + shortHex(tree.MerkleRoot()) + "}"
+	if tree.String() != want {
+		t.Fatalf("want (%s); got %s", want, tree.String())
+	}
+}
+
+func TestPrint00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap[:5]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err = tree.Print(&b, PrintOptions{DigestBytes: 4, Data: true}); err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s", b.String())
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != tree.Size() {
+		t.Fatalf("want (%d) lines; got %d", tree.Size(), len(lines))
+	}
+	if want := "(3, 0) " + shortHex(tree.MerkleRoot()); lines[0] != want {
+		t.Fatalf("want (%s); got %s", want, lines[0])
+	}
+	if want := `      [4] #4 ` + shortHex(tree.tls[4].digest) + ` "E" (lone)`; lines[len(lines)-1] != want {
+		t.Fatalf("want (%s); got %s", want, lines[len(lines)-1])
+	}
+	if n := strings.Count(b.String(), "(lone)"); n != 2 {
+		t.Fatalf("want (2) lone nodes; got %d", n)
+	}
+
+	b.Reset()
+	if err = tree.Print(&b, PrintOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "(3, 0) "+truncatedHex(tree.MerkleRoot(), 0)+"\n") {
+		t.Fatalf("want whole digests; got\n%s", b.String())
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+// progress tracks the hash calculations of a (re)construction of a merkle
+// tree, on behalf of the callback set by WithProgress.
+type progress struct {
+	done, total int
+}
+
+// beginProgress starts tracking a (re)construction of the merkle tree that
+// hashes the given number of leaves and then constructs the merkle nodes
+// above numLeaves leaves.
+func (t *Tree) beginProgress(numHashedLeaves, numLeaves int) {
+	if t.onProgress == nil {
+		return
+	}
+	numMerkleNodes, _ := t.merkleNumbers(numLeaves)
+	t.progress = &progress{total: numHashedLeaves + numMerkleNodes}
+}
+
+// advanceProgress records n more hash calculations and reports them.
+func (t *Tree) advanceProgress(n int) {
+	if t.progress == nil {
+		return
+	}
+	t.progress.done += n
+	t.onProgress(t.progress.done, t.progress.total)
+}
+
+// endProgress stops tracking the (re)construction of the merkle tree.
+func (t *Tree) endProgress() {
+	t.progress = nil
+}
@@ -0,0 +1,366 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"hash"
+)
+
+// Proof is an inclusion proof of a single leaf in a merkle tree; i.e. the
+// digest of the leaf, along with the digests of its siblings along the path
+// to the merkle root, which are enough to recalculate the latter.
+type Proof struct {
+	// Hash is the hash function that the merkle tree was built with.
+	Hash crypto.Hash
+	// LeafIndex is the index of the leaf among the (sorted) leaves of the
+	// merkle tree.
+	LeafIndex int
+	// NumLeaves is the number of leaves in the merkle tree.
+	NumLeaves int
+	// LeafDigest is the hash digest of the leaf.
+	LeafDigest []byte
+	// Siblings are the digests of the siblings of the nodes along the path
+	// from the leaf to the merkle root, starting from the leaf's sibling.
+	// An empty sibling signifies a node that was hashed on its own, due to
+	// being the last one in an odd-sized level of the merkle tree.
+	//
+	// In trees of arity greater than 2 (see WithArity), each node along the
+	// path has all of its siblings listed, from left to right; their number
+	// at each level follows from NumLeaves.
+	Siblings [][]byte
+
+	// scheme is the hashing scheme of the merkle tree, if not the default.
+	scheme *scheme
+}
+
+// Proof returns an inclusion proof for the leaf at the given index among the
+// (sorted) leaves of the merkle tree.
+//
+// It returns a non-nil error if the given index is out of range.
+func (t *Tree) Proof(leafIndex int) (*Proof, error) {
+	if leafIndex < 0 || leafIndex >= len(t.tls) {
+		return nil, &IndexError{Op: "Proof", Index: leafIndex, Err: ErrNoData}
+	}
+
+	p := &Proof{
+		Hash:       t.hash,
+		LeafIndex:  leafIndex,
+		NumLeaves:  t.paddedNumLeaves(),
+		LeafDigest: copyBytes(t.tls[leafIndex].digest),
+		Siblings:   make([][]byte, 0, len(t.rows)),
+		scheme:     t.proofScheme(),
+	}
+	if len(t.rows) == 0 {
+		// A single leaf is the merkle root itself.
+		t.observeProof()
+		return p, nil
+	}
+	// Siblings of the leaf and of the merkle nodes along the path, up to
+	// the root, level by level; the ones that are still cached (see
+	// WithProofCache) are not read again.
+	c := t.ownProofCache()
+	var levels [][][]byte
+	reused := 0
+	if c != nil {
+		levels, reused = c.get(leafIndex)
+	}
+	var empty [][]byte
+	if t.scheme.padded && reused < len(t.rows) {
+		empty = t.scheme.emptyRoots(t.newHasher(), len(t.rows))
+	}
+	index := leafIndex
+	for height := 0; height < len(t.rows); height++ {
+		if height < reused {
+			for _, sibling := range levels[height] {
+				p.Siblings = append(p.Siblings, copyBytes(sibling))
+			}
+			index /= t.scheme.width()
+			continue
+		}
+		mark := len(p.Siblings)
+		start, end := t.siblingRange(height, index)
+		if end-start == 1 {
+			p.Siblings = append(p.Siblings, []byte{})
+		}
+		for i := start; i < end; i++ {
+			if i == index {
+				continue
+			}
+			if i >= t.levelWidth(height) {
+				p.Siblings = append(p.Siblings, copyBytes(empty[height]))
+				continue
+			}
+			digest, err := t.node(height, i)
+			if err != nil {
+				return nil, err
+			}
+			p.Siblings = append(p.Siblings, copyBytes(digest))
+		}
+		if c != nil {
+			level := make([][]byte, 0, len(p.Siblings)-mark)
+			for _, sibling := range p.Siblings[mark:] {
+				level = append(level, copyBytes(sibling))
+			}
+			levels = append(levels, level)
+		}
+		index /= t.scheme.width()
+	}
+	if c != nil {
+		c.put(leafIndex, levels, reused)
+	}
+	t.observeProof()
+	return p, nil
+}
+
+// ProveDatum returns an inclusion proof for the given Datum.
+//
+// It returns a non-nil error if the given Datum is either nil, not present in
+// the merkle tree, or fails to be serialized.
+func (t *Tree) ProveDatum(datum Datum) (*Proof, error) {
+	leafIndex, err := t.searchDatum("ProveDatum", datum)
+	if err != nil {
+		return nil, err
+	}
+	return t.Proof(leafIndex)
+}
+
+// Root recalculates the merkle root that the Proof leads to.
+//
+// It returns a non-nil error if the hash function of the Proof has not been
+// linked into the binary, or if the Proof is malformed; i.e. if its leaf index
+// is not less than NumLeaves, if its digests are not of the size of the hash
+// function, or if its siblings do not follow from NumLeaves.
+func (p *Proof) Root() ([]byte, error) {
+	s := schemeOrDefault(p.scheme)
+	if !s.available(p.Hash) {
+		return nil, &HashError{Hash: p.Hash}
+	}
+	return p.rootTo(s.newHasher(p.Hash), s, nil, nil)
+}
+
+// rootTo is like Root, but hashes through h, and appends the digest of each
+// level to buf[:0] (see scheme.hashNodeTo), reusing children for trees of
+// arity greater than 2. Given a buffer of sufficient capacity, it does not
+// allocate, but the returned merkle root may then alias it.
+func (p *Proof) rootTo(h hash.Hash, s *scheme, buf []byte, children [][]byte) ([]byte, error) {
+	if s.width() > 2 {
+		return p.wideRoot(h, s, buf, children)
+	}
+	if err := p.checkPath(h.Size()); err != nil {
+		return nil, err
+	}
+
+	index, currentDigest := p.LeafIndex, p.LeafDigest
+	for _, sibling := range p.Siblings {
+		if len(sibling) == 0 {
+			currentDigest = s.hashLoneTo(h, buf[:0], currentDigest)
+		} else if index%2 == 0 {
+			currentDigest = s.hashNodeTo(h, buf[:0], currentDigest, sibling)
+		} else {
+			currentDigest = s.hashNodeTo(h, buf[:0], sibling, currentDigest)
+		}
+		index /= 2
+	}
+	return currentDigest, nil
+}
+
+// checkPath returns a non-nil error if the Proof cannot be the one of a leaf
+// of a binary merkle tree of NumLeaves leaves and of digests of the given
+// size; i.e. if its leaf index is out of range, if its digests are not of that
+// size, or if its siblings are not one per level of the tree, with an empty
+// one exactly where a node is hashed on its own.
+func (p *Proof) checkPath(size int) error {
+	if p.LeafIndex < 0 || p.LeafIndex >= p.NumLeaves {
+		return ErrInvalidRange
+	}
+	if len(p.LeafDigest) != size {
+		return ErrInvalidDigest
+	}
+	index, siblings := p.LeafIndex, p.Siblings
+	for width := p.NumLeaves; width > 1; width = (width + 1) / 2 {
+		if len(siblings) == 0 {
+			return ErrInvalidEncoding
+		}
+		if index == width-1 && width%2 == 1 {
+			if len(siblings[0]) != 0 {
+				return ErrInvalidEncoding
+			}
+		} else if len(siblings[0]) != size {
+			return ErrInvalidDigest
+		}
+		siblings = siblings[1:]
+		index /= 2
+	}
+	if len(siblings) != 0 {
+		return ErrInvalidEncoding
+	}
+	return nil
+}
+
+// wideRoot is like rootTo, but for trees of arity greater than 2.
+func (p *Proof) wideRoot(h hash.Hash, s *scheme, buf []byte, children [][]byte) ([]byte, error) {
+	if p.LeafIndex < 0 || p.LeafIndex >= p.NumLeaves {
+		return nil, ErrInvalidRange
+	}
+	if len(p.LeafDigest) != h.Size() {
+		return nil, ErrInvalidDigest
+	}
+	arity := s.width()
+	index, currentDigest := p.LeafIndex, p.LeafDigest
+	siblings := p.Siblings
+	for width := p.NumLeaves; width > 1; width = (width + arity - 1) / arity {
+		start, end := siblingRange(arity, width, index)
+		if end-start == 1 {
+			if len(siblings) == 0 || len(siblings[0]) != 0 {
+				return nil, ErrInvalidEncoding
+			}
+			siblings = siblings[1:]
+			currentDigest = s.hashLoneTo(h, buf[:0], currentDigest)
+		} else {
+			if len(siblings) < end-start-1 {
+				return nil, ErrInvalidEncoding
+			}
+			children = append(children[:0], siblings[:index-start]...)
+			children = append(children, currentDigest)
+			children = append(children, siblings[index-start:end-start-1]...)
+			siblings = siblings[end-start-1:]
+			currentDigest = s.hashChildrenTo(h, buf[:0], children)
+		}
+		index /= arity
+	}
+	if len(siblings) != 0 {
+		return nil, ErrInvalidEncoding
+	}
+	return currentDigest, nil
+}
+
+// Verify verifies that the Proof leads to the given merkle root.
+//
+// It allocates a digest per level of the merkle tree; see Verifier for
+// verifying many proofs without allocating.
+func (p *Proof) Verify(root []byte) bool {
+	calculatedRoot, err := p.Root()
+	return err == nil && bytes.Equal(calculatedRoot, root)
+}
+
+// ProofScheme describes the hashing scheme that a Proof is verified against,
+// as far as it is carried along with the Proof; it lets encodings other than
+// the ones of this package (e.g. the ones of the merklepb package) record it.
+type ProofScheme struct {
+	// RFC6962 is whether the inputs of the hash function are prefixed as
+	// per RFC 6962 (see RFC6962).
+	RFC6962 bool
+	// Arity is the number of children of each merkle node, if greater
+	// than 2 (see WithArity).
+	Arity int
+	// Padding is the PaddingPolicy of the merkle tree.
+	Padding PaddingPolicy
+	// SortedPairs is whether the children of each merkle node are hashed
+	// in ascending order of their digests (see SortedPairs).
+	SortedPairs bool
+	// DigestSize is the size that the digests are truncated to, or 0 if
+	// they are not (see TruncateDigests).
+	DigestSize int
+}
+
+// Scheme returns the hashing scheme that the Proof is verified against. The
+// hash functions given through WithHashFunc or WithHasher are not part of it.
+func (p *Proof) Scheme() ProofScheme {
+	s := schemeOrDefault(p.scheme)
+	return ProofScheme{
+		RFC6962:     s.isRFC6962(),
+		Arity:       s.arity,
+		Padding:     s.padding,
+		SortedPairs: s.sortedPairs,
+		DigestSize:  s.digestSize,
+	}
+}
+
+// SetScheme sets the hashing scheme that the Proof is verified against (e.g.
+// upon decoding it out of an encoding that records it separately).
+//
+// It returns a non-nil error if the PaddingPolicy is unknown, or if the arity
+// or the digest size is negative.
+func (p *Proof) SetScheme(ps ProofScheme) error {
+	if !ps.Padding.valid() || ps.Arity < 0 || ps.DigestSize < 0 {
+		return ErrInvalidEncoding
+	}
+	var s scheme
+	if ps.RFC6962 {
+		s = rfc6962Scheme
+	}
+	if ps.Arity > 2 {
+		s.arity = ps.Arity
+	}
+	s.padding, s.sortedPairs, s.digestSize = ps.Padding, ps.SortedPairs, ps.DigestSize
+	p.scheme = nil
+	if !s.isDefault() {
+		p.scheme = &s
+	}
+	return nil
+}
+
+func copyBytes(b []byte) []byte {
+	ret := make([]byte, len(b))
+	copy(ret, b)
+	return ret
+}
+
+// levelWidth returns the number of nodes at the given height of the merkle
+// tree, where height 0 holds the leaves.
+func (t *Tree) levelWidth(height int) int {
+	if height == 0 {
+		return len(t.tls)
+	}
+	return t.rows[height-1]
+}
+
+// siblingRange returns the range of the indices of the node at the given
+// height and index of the merkle tree and of its siblings; i.e. of all the
+// children of its parent. The start is inclusive and the end exclusive. In
+// padded trees (see PadToPowerOfTwo), the range may extend past the nodes
+// present, into the empty ones.
+func (t *Tree) siblingRange(height, index int) (start, end int) {
+	if t.scheme.padded {
+		start = index / t.scheme.width() * t.scheme.width()
+		return start, start + t.scheme.width()
+	}
+	return siblingRange(t.scheme.width(), t.levelWidth(height), index)
+}
+
+func siblingRange(arity, width, index int) (start, end int) {
+	start = index / arity * arity
+	end = start + arity
+	if end > width {
+		end = width
+	}
+	return
+}
+
+// nodeAt returns the digest of the node at the given height and index of the
+// merkle tree, where height 0 holds the leaves, or nil if the NodeStore of the
+// tree fails to provide it.
+func (t *Tree) nodeAt(height, index int) []byte {
+	if height == 0 {
+		return t.tls[index].digest
+	}
+	digest, err := t.store.Get(height, index)
+	if err != nil {
+		return nil
+	}
+	return digest
+}
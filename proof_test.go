@@ -0,0 +1,123 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestProof00(t *testing.T) {
+	for numLeaves := 2; numLeaves <= len(grAlphabet); numLeaves++ {
+		tree, err := NewTree(crypto.SHA256, grAlphabet[:numLeaves]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, word := range grAlphabet[:numLeaves] {
+			p, err := tree.ProveDatum(word)
+			if err != nil {
+				t.Fatalf("ERROR while proving \"%s\": %v", word, err)
+			}
+			if !p.Verify(tree.MerkleRoot()) {
+				t.Fatalf("proof of \"%s\" in a tree of %d leaves failed", word, numLeaves)
+			}
+		}
+	}
+}
+func TestProof01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = tree.ProveDatum(kk); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err = tree.Proof(tree.NumLeaves()); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+
+	p, err := tree.ProveDatum(K)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, sibling := range p.Siblings {
+		t.Logf("%2d. %x", i, sibling)
+	}
+	p.LeafDigest[0] ^= 0xff
+	if p.Verify(tree.MerkleRoot()) {
+		t.Fatalf("tampered proof verified successfully")
+	}
+}
+func TestProof02(t *testing.T) {
+	for _, opts := range [][]Option{nil, {RFC6962()}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:8], opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := tree.MerkleRoot()
+		forgeries := []func(p *Proof){
+			func(p *Proof) { p.LeafIndex += 8 },
+			func(p *Proof) { p.LeafIndex = -1 },
+			func(p *Proof) { p.NumLeaves = 1000 },
+			func(p *Proof) { p.LeafDigest, p.Siblings = root, nil },
+			func(p *Proof) { p.Siblings = append(p.Siblings, []byte{}) },
+			func(p *Proof) { p.Siblings[0] = []byte{} },
+			func(p *Proof) { p.LeafDigest = append(p.LeafDigest, 0) },
+		}
+		for i, forge := range forgeries {
+			p, err := tree.Proof(3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(root) {
+				t.Fatalf("proof of leaf 3 failed")
+			}
+			forge(p)
+			if p.Verify(root) {
+				t.Fatalf("forgery %d verified successfully", i)
+			}
+			if errs := VerifyProofs(root, []Proof{*p}); errs[0] == nil {
+				t.Fatalf("forgery %d verified successfully in a batch", i)
+			}
+		}
+	}
+}
+func TestProofScheme00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithArity(4), WithPaddingPolicy(PairWithZero), TruncateDigests(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := tree.Proof(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ProofScheme{Arity: 4, Padding: PairWithZero, DigestSize: 16}
+	if p.Scheme() != want {
+		t.Fatalf("want (%+v); got %+v", want, p.Scheme())
+	}
+	p2 := Proof{Hash: p.Hash, LeafIndex: p.LeafIndex, NumLeaves: p.NumLeaves, LeafDigest: p.LeafDigest, Siblings: p.Siblings}
+	if p2.Verify(tree.MerkleRoot()) {
+		t.Fatalf("proof verified without its scheme")
+	}
+	if err = p2.SetScheme(p.Scheme()); err != nil {
+		t.Fatal(err)
+	}
+	if !p2.Verify(tree.MerkleRoot()) {
+		t.Fatalf("proof failed to verify along with its scheme")
+	}
+	if err = p2.SetScheme(ProofScheme{Padding: numPaddingPolicies}); err != ErrInvalidEncoding {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+}
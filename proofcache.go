@@ -0,0 +1,179 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"container/list"
+	"sync"
+)
+
+// WithProofCache configures the merkle tree to cache the siblings of the
+// proofs it generates (see Proof) for up to the given number of leaves,
+// evicting the ones of the least recently proven leaf once it is full; hence,
+// the proofs of hot leaves (e.g. on an API that serves proofs) are generated
+// without reading any merkle nodes, be they kept in memory or in a NodeStore
+// given through WithNodeStore.
+//
+// The cache is invalidated precisely upon each mutation of the merkle tree:
+// the siblings that the mutation may have changed are dropped, while the ones
+// over leaves that precede all changed ones (e.g. the lower siblings of the
+// proofs of all leaves but the appended ones, upon AppendAndReconstruct) are
+// kept, so that only the rest of them are read anew. A merkle tree derived
+// from another one (e.g. through Appended or Snapshot) caches nothing until
+// its first mutation. It has no effect for a non-positive number of leaves.
+func WithProofCache(numLeaves int) Option {
+	return func(t *Tree) {
+		t.proofCacheSize = numLeaves
+	}
+}
+
+// ProofCacheStats are the counters of the proof cache of a merkle tree (see
+// WithProofCache), which can be used to tune its capacity.
+type ProofCacheStats struct {
+	// Hits is the number of proofs whose siblings were all cached, and
+	// Misses the number of the ones that some of them had to be read for.
+	Hits, Misses uint64
+	// Evictions is the number of leaves whose cached siblings were
+	// evicted to make room for others.
+	Evictions uint64
+	// Len is the number of leaves whose siblings are currently cached.
+	Len int
+}
+
+// ProofCacheStats returns the counters of the proof cache of the merkle tree,
+// which are all zero unless it caches proofs (see WithProofCache).
+func (t *Tree) ProofCacheStats() ProofCacheStats {
+	c := t.ownProofCache()
+	if c == nil {
+		return ProofCacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Len = c.lru.Len()
+	return s
+}
+
+// proofCache caches the siblings of the proofs of the leaves of the merkle
+// tree that owns it, level by level.
+type proofCache struct {
+	owner    *Tree
+	capacity int
+
+	mu      sync.Mutex
+	lru     *list.List // of *cachedProof, most recently used first
+	entries map[int]*list.Element
+	stats   ProofCacheStats
+}
+
+// cachedProof holds the siblings of the proof of a leaf at each height, of
+// which the first valid ones are still those of the merkle tree.
+type cachedProof struct {
+	leafIndex int
+	levels    [][][]byte
+	valid     int
+}
+
+// ownProofCache returns the proofCache of the merkle tree, or nil if it does
+// not cache proofs, or has not been mutated since it was derived from another
+// one.
+func (t *Tree) ownProofCache() *proofCache {
+	if t.proofs == nil || t.proofs.owner != t {
+		return nil
+	}
+	return t.proofs
+}
+
+// invalidateProofs invalidates the cached siblings that a mutation of the
+// merkle tree may have changed, given that its first unchanged leaves have
+// been left as they were (see reconstructMerkleNodes), or sets up the proof
+// cache of the merkle tree if it has none of its own yet.
+func (t *Tree) invalidateProofs(unchanged int) {
+	if t.proofCacheSize <= 0 {
+		return
+	}
+	c := t.ownProofCache()
+	if c == nil {
+		t.proofs = &proofCache{
+			owner:    t,
+			capacity: t.proofCacheSize,
+			lru:      list.New(),
+			entries:  make(map[int]*list.Element),
+		}
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	arity := t.scheme.width()
+	for e := c.lru.Front(); e != nil; {
+		next, cp := e.Next(), e.Value.(*cachedProof)
+		if cp.leafIndex >= unchanged {
+			c.lru.Remove(e)
+			delete(c.entries, cp.leafIndex)
+			e = next
+			continue
+		}
+		// The siblings at a height are unchanged if all of them, along
+		// with the node on the path, span unchanged leaves only.
+		index, span, valid := cp.leafIndex, 1, 0
+		for ; valid < cp.valid; valid++ {
+			if start := index - index%arity; (start+arity)*span > unchanged {
+				break
+			}
+			index, span = index/arity, span*arity
+		}
+		cp.valid = valid
+		e = next
+	}
+}
+
+// get returns the cached siblings of the proof of the given leaf, and the
+// number of heights whose siblings are still valid.
+func (c *proofCache) get(leafIndex int) ([][][]byte, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[leafIndex]
+	if !ok {
+		return nil, 0
+	}
+	c.lru.MoveToFront(e)
+	cp := e.Value.(*cachedProof)
+	return cp.levels[:cp.valid:cp.valid], cp.valid
+}
+
+// put caches the siblings of the proof of the given leaf, of which the first
+// reused heights were already cached, evicting the least recently proven leaf
+// if the cache is full.
+func (c *proofCache) put(leafIndex int, levels [][][]byte, reused int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if reused == len(levels) {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+	if e, ok := c.entries[leafIndex]; ok {
+		cp := e.Value.(*cachedProof)
+		cp.levels, cp.valid = levels, len(levels)
+		return
+	}
+	if c.lru.Len() >= c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedProof).leafIndex)
+		c.stats.Evictions++
+	}
+	c.entries[leafIndex] = c.lru.PushFront(&cachedProof{leafIndex: leafIndex, levels: levels, valid: len(levels)})
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"reflect"
+	"testing"
+)
+
+func TestProofCache00(t *testing.T) {
+	store := &countingStore{mapStore: mapStore{nodes: make(map[NodeID][]byte)}}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:16], InsertionOrder(), WithNodeStore(store), WithProofCache(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:16], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(leafIndex, wantGets int) {
+		t.Helper()
+		store.gets = 0
+		p, err := tree.Proof(leafIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := ref.Proof(leafIndex)
+		if !reflect.DeepEqual(p, want) || store.gets != wantGets {
+			t.Fatalf("leaf %d: want (%v, %d); got (%v, %d)", leafIndex, want, wantGets, p, store.gets)
+		}
+		// The cached siblings are not handed out.
+		for _, sibling := range p.Siblings {
+			if len(sibling) != 0 {
+				sibling[0] ^= 0xff
+			}
+		}
+	}
+
+	check(0, 3)
+	check(0, 0)
+	check(9, 3)
+	if s := tree.ProofCacheStats(); s != (ProofCacheStats{Hits: 1, Misses: 2, Len: 2}) {
+		t.Fatalf("want (%+v); got %+v", ProofCacheStats{Hits: 1, Misses: 2, Len: 2}, s)
+	}
+
+	// Appending a leaf only adds a sibling above the ones of the first 16
+	// leaves.
+	tree.AppendAndReconstruct(grAlphabet[16])
+	ref.AppendAndReconstruct(grAlphabet[16])
+	check(0, 1)
+	check(9, 1)
+	check(16, 1)
+	check(16, 0)
+
+	// Deleting a leaf invalidates the siblings over it, and the proofs of
+	// the leaves after it.
+	tree.DeleteAndReconstruct(grAlphabet[10])
+	ref.DeleteAndReconstruct(grAlphabet[10])
+	check(0, 1)
+	check(9, 3)
+	check(15, 3)
+
+	// The least recently proven leaves are evicted.
+	for _, leafIndex := range []int{1, 2, 3, 4} {
+		check(leafIndex, 3)
+	}
+	check(0, 3)
+	if s := tree.ProofCacheStats(); s.Len != 4 || s.Evictions != 4 {
+		t.Fatalf("want (4, 4); got (%d, %d)", s.Len, s.Evictions)
+	}
+
+	// Snapshots do not share the cache.
+	before := tree.ProofCacheStats()
+	snap := tree.Snapshot()
+	if _, err := snap.Proof(0); err != nil || tree.ProofCacheStats() != before {
+		t.Fatalf("want (<nil>, %+v); got (%v, %+v)", before, err, tree.ProofCacheStats())
+	}
+}
+
+func TestProofCache01(t *testing.T) {
+	for i, opts := range [][]Option{
+		{WithArity(3)},
+		{PadToPowerOfTwo(nil)},
+		{RFC6962()},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], append(opts, InsertionOrder(), WithProofCache(100))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:10], append(opts, InsertionOrder())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, datum := range grAlphabet[10:] {
+			for j := 0; j < tree.NumLeaves(); j++ {
+				p, err := tree.Proof(j)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want, _ := ref.Proof(j); !reflect.DeepEqual(p, want) {
+					t.Fatalf("%d: leaf %d: want (%v); got %v", i, j, want, p)
+				}
+			}
+			tree.AppendAndReconstruct(datum)
+			ref.AppendAndReconstruct(datum)
+		}
+		t.Logf("%d: %+v", i, tree.ProofCacheStats())
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+)
+
+// RangeProof is an inclusion proof of a contiguous run of leaves of a merkle
+// tree; i.e. the digests of the nodes that border the run at each level of
+// the tree, which are enough to recalculate the merkle root along with the
+// digests of the leaves in the run.
+type RangeProof struct {
+	// Hash is the hash function that the merkle tree was built with.
+	Hash crypto.Hash
+	// Start and End delimit the run of leaves, by index among the (sorted)
+	// leaves of the merkle tree; Start is inclusive and End is exclusive.
+	Start, End int
+	// NumLeaves is the number of leaves in the merkle tree.
+	NumLeaves int
+	// Nodes are the digests of the bordering nodes, from the leaves to the
+	// root, and from left to right within each level.
+	Nodes [][]byte
+
+	// scheme is the hashing scheme of the merkle tree, if not the default.
+	scheme *scheme
+}
+
+// RangeProof returns an inclusion proof of the leaves of the merkle tree with
+// indices in [start, end).
+//
+// It returns a non-nil error if the range is empty or out of bounds, if the
+// arity of the merkle tree is greater than 2, or if it is padded (see
+// PadToPowerOfTwo).
+func (t *Tree) RangeProof(start, end int) (*RangeProof, error) {
+	if t.scheme.width() != 2 || t.scheme.padded {
+		return nil, ErrUnsupported
+	}
+	if start < 0 || end > len(t.tls) || start >= end {
+		return nil, ErrInvalidRange
+	}
+	rp := &RangeProof{
+		Hash:      t.hash,
+		Start:     start,
+		End:       end,
+		NumLeaves: len(t.tls),
+		scheme:    t.proofScheme(),
+	}
+	lo, hi := start, end
+	for height, width := 0, len(t.tls); width > 1; height, width = height+1, (width+1)/2 {
+		if lo%2 == 1 {
+			rp.Nodes = append(rp.Nodes, copyBytes(t.nodeAt(height, lo-1)))
+		}
+		if hi%2 == 1 && hi < width {
+			rp.Nodes = append(rp.Nodes, copyBytes(t.nodeAt(height, hi)))
+		}
+		lo, hi = lo/2, (hi+1)/2
+	}
+	t.observeProof()
+	return rp, nil
+}
+
+// Verify verifies that the given leaf digests are the ones that the
+// RangeProof refers to, and that the RangeProof leads to the given root.
+func (rp *RangeProof) Verify(root []byte, leafDigests [][]byte) bool {
+	s := schemeOrDefault(rp.scheme)
+	if !s.available(rp.Hash) || rp.Start < 0 || rp.End > rp.NumLeaves || rp.Start >= rp.End || len(leafDigests) != rp.End-rp.Start {
+		return false
+	}
+	h := s.newHasher(rp.Hash)
+
+	level := leafDigests
+	nodes := rp.Nodes
+	lo, hi := rp.Start, rp.End
+	for width := rp.NumLeaves; width > 1; width = (width + 1) / 2 {
+		// Extend the run with its bordering nodes...
+		if lo%2 == 1 {
+			if len(nodes) == 0 {
+				return false
+			}
+			level = append([][]byte{nodes[0]}, level...)
+			nodes = nodes[1:]
+		}
+		if hi%2 == 1 && hi < width {
+			if len(nodes) == 0 {
+				return false
+			}
+			level = append(level, nodes[0])
+			nodes = nodes[1:]
+		}
+		// ...and hash it into the run of the parents.
+		parents := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				parents = append(parents, s.hashNode(h, level[i], level[i+1]))
+			} else {
+				parents = append(parents, s.hashLone(h, level[i]))
+			}
+		}
+		level = parents
+		lo, hi = lo/2, (hi+1)/2
+	}
+	return len(nodes) == 0 && len(level) == 1 && bytes.Equal(level[0], root)
+}
+
+// VerifySerializedData is like Verify, but it is given the serialized data of
+// the leaves, rather than their digests.
+func (rp *RangeProof) VerifySerializedData(root []byte, serializedData [][]byte) bool {
+	s := schemeOrDefault(rp.scheme)
+	if !s.available(rp.Hash) {
+		return false
+	}
+	h := s.newHasher(rp.Hash)
+	leafDigests := make([][]byte, len(serializedData))
+	for i := range serializedData {
+		leafDigests[i] = s.hashLeaf(h, serializedData[i])
+	}
+	return rp.Verify(root, leafDigests)
+}
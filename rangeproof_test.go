@@ -0,0 +1,70 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"testing"
+)
+
+func TestRangeProof00(t *testing.T) {
+	for numLeaves := 1; numLeaves <= len(grAlphabet); numLeaves++ {
+		tree, err := NewTree(crypto.SHA256, grAlphabet[:numLeaves]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for start := 0; start < numLeaves; start++ {
+			for end := start + 1; end <= numLeaves; end++ {
+				rp, err := tree.RangeProof(start, end)
+				if err != nil {
+					t.Fatal(err)
+				}
+				data := make([][]byte, 0, end-start)
+				for i := start; i < end; i++ {
+					data = append(data, tree.tls[i].datum)
+				}
+				if !rp.VerifySerializedData(tree.MerkleRoot(), data) {
+					t.Fatalf("range proof of [%d, %d) in a tree of %d leaves failed", start, end, numLeaves)
+				}
+				if end-start > 1 && rp.VerifySerializedData(tree.MerkleRoot(), append(data[1:], data[0])) {
+					t.Fatalf("range proof of [%d, %d) verified reordered leaves", start, end)
+				}
+			}
+		}
+	}
+}
+func TestRangeProof01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range [][2]int{{-1, 2}, {3, 3}, {4, 2}, {0, 27}} {
+		if _, err = tree.RangeProof(r[0], r[1]); err == nil {
+			t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+		}
+	}
+	rp, err := tree.RangeProof(5, 13)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("range proof of [5, 13) in a tree of 26 leaves: %d nodes", len(rp.Nodes))
+	data := make([][]byte, 0, 8)
+	for i := 5; i < 13; i++ {
+		data = append(data, tree.tls[i].datum)
+	}
+	if rp.VerifySerializedData(tree.MerkleRoot(), data[:7]) {
+		t.Fatalf("range proof verified a truncated run of leaves")
+	}
+}
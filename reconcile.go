@@ -0,0 +1,283 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/bits"
+	"sort"
+)
+
+// NodeID identifies a node of a merkle tree by its height (where height 0
+// holds the leaves) and its index among the nodes of that height.
+type NodeID struct {
+	Height, Index int
+}
+
+// Query is a message of the set reconciliation protocol, by which a
+// Reconciler requests the digests of some nodes of a remote merkle tree.
+type Query struct {
+	// Nodes are the nodes whose digests are requested.
+	Nodes []NodeID
+}
+
+// Reply is a message of the set reconciliation protocol, by which a merkle
+// tree answers a Query.
+type Reply struct {
+	// NumLeaves is the number of leaves in the merkle tree.
+	NumLeaves int
+	// Digests are the digests of the requested nodes, in the order they
+	// were requested; an empty digest signifies a nonexistent node.
+	Digests [][]byte
+	// Leaves are the serialized data (or, in digest-only mode, the
+	// digests) of the requested nodes that are leaves, and nil for the
+	// rest.
+	Leaves [][]byte
+}
+
+// Answer returns the Reply of the merkle tree to the given Query.
+func (t *Tree) Answer(q *Query) *Reply {
+	r := &Reply{
+		NumLeaves: len(t.tls),
+		Digests:   make([][]byte, len(q.Nodes)),
+		Leaves:    make([][]byte, len(q.Nodes)),
+	}
+	for i, id := range q.Nodes {
+		if id.Height < 0 || id.Index < 0 {
+			continue
+		}
+		if digest := t.nodeOrNil(id.Height, id.Index); digest != nil {
+			r.Digests[i] = copyBytes(digest)
+			if id.Height == 0 {
+				r.Leaves[i] = copyBytes(t.key(&t.tls[id.Index]))
+			}
+		}
+	}
+	return r
+}
+
+// Reconciler drives the set reconciliation protocol on behalf of a local
+// merkle tree, to find out how it differs from a remote one, given that both
+// share the same hash function and hashing scheme.
+//
+// The Reconciler produces Queries, which are to be delivered to the remote
+// peer over any transport, answered through Tree.Answer, and have their
+// Replies delivered back to the Reconciler. It descends both trees in
+// parallel, one level per round trip, skipping every subtree whose digest is
+// the same in both; hence, the peers exchange O(δ·log2(L)) digests to find δ
+// differing leaves, rather than their full lists of leaves.
+type Reconciler struct {
+	t            *Tree
+	remoteLeaves int
+	pending      []NodeID
+	started      bool
+	sized        bool
+
+	// The local and the remote leaves under the subtrees that differ.
+	candLocal  []int
+	candRemote []remoteLeaf
+}
+
+// remoteLeaf is a leaf of the remote merkle tree, as learnt by a Reconciler.
+type remoteLeaf struct {
+	index       int
+	digest, key []byte
+}
+
+// NewReconciler creates a Reconciler for the given local merkle tree.
+func NewReconciler(t *Tree) *Reconciler {
+	return &Reconciler{t: t}
+}
+
+// Start returns the first Query of the protocol, which requests no nodes, but
+// merely the number of leaves of the remote merkle tree.
+func (r *Reconciler) Start() *Query {
+	*r = Reconciler{t: r.t, started: true}
+	return &Query{}
+}
+
+// Next processes the Reply to the last Query, and returns the next Query of
+// the protocol, or nil if the reconciliation is complete.
+//
+// It returns a non-nil error if the Reply does not correspond to the last
+// Query, if the arity of the local merkle tree is greater than 2, or if it is
+// padded (see PadToPowerOfTwo).
+func (r *Reconciler) Next(reply *Reply) (*Query, error) {
+	if r.t.scheme.width() != 2 || r.t.scheme.padded {
+		return nil, ErrUnsupported
+	}
+	if !r.started || len(reply.Digests) != len(r.pending) || len(reply.Leaves) != len(r.pending) || reply.NumLeaves < 0 {
+		return nil, ErrInvalidEncoding
+	}
+
+	var next []NodeID
+	if !r.sized {
+		// Reply to Start; begin from the root of the taller tree.
+		r.remoteLeaves, r.sized = reply.NumLeaves, true
+		height := len(r.t.rows)
+		if h := treeHeight(reply.NumLeaves); h > height {
+			height = h
+		}
+		if r.remoteLeaves > 0 {
+			next = append(next, NodeID{Height: height})
+		} else {
+			r.candLocal = r.t.leafRange(NodeID{Height: height}, r.candLocal)
+		}
+	}
+	for i, id := range r.pending {
+		local, remote := r.t.nodeOrNil(id.Height, id.Index), reply.Digests[i]
+		switch {
+		case local != nil && bytes.Equal(local, remote):
+		case id.Index<<uint(id.Height) >= r.remoteLeaves:
+			// The remote tree lacks the whole subtree.
+			r.candLocal = r.t.leafRange(id, r.candLocal)
+		case id.Height == 0:
+			if local != nil {
+				r.candLocal = append(r.candLocal, id.Index)
+			}
+			r.candRemote = append(r.candRemote, remoteLeaf{index: id.Index, digest: remote, key: reply.Leaves[i]})
+		default:
+			for _, index := range []int{2 * id.Index, 2*id.Index + 1} {
+				if index<<uint(id.Height-1) < r.remoteLeaves {
+					next = append(next, NodeID{Height: id.Height - 1, Index: index})
+				} else {
+					r.candLocal = r.t.leafRange(NodeID{Height: id.Height - 1, Index: index}, r.candLocal)
+				}
+			}
+		}
+	}
+
+	r.pending = next
+	if len(next) == 0 {
+		r.started = false
+		return nil, nil
+	}
+	return &Query{Nodes: next}, nil
+}
+
+// Result returns the leaves (i.e. their serialized data or, in digest-only
+// mode, their digests) that are present only in the local merkle tree and
+// only in the remote one, respectively, as found by a complete run of the
+// protocol.
+func (r *Reconciler) Result() (onlyLocal, onlyRemote [][]byte) {
+	// The leaves were found level by level; restore the order of the trees.
+	sort.Ints(r.candLocal)
+	sort.Slice(r.candRemote, func(i, j int) bool {
+		return r.candRemote[i].index < r.candRemote[j].index
+	})
+
+	inLocal := make(map[string]struct{}, len(r.candLocal))
+	for _, i := range r.candLocal {
+		inLocal[string(r.t.tls[i].digest)] = struct{}{}
+	}
+	inRemote := make(map[string]struct{}, len(r.candRemote))
+	for i := range r.candRemote {
+		inRemote[string(r.candRemote[i].digest)] = struct{}{}
+	}
+	for _, i := range r.candLocal {
+		if _, ok := inRemote[string(r.t.tls[i].digest)]; !ok {
+			onlyLocal = append(onlyLocal, copyBytes(r.t.key(&r.t.tls[i])))
+		}
+	}
+	for i := range r.candRemote {
+		if _, ok := inLocal[string(r.candRemote[i].digest)]; !ok {
+			onlyRemote = append(onlyRemote, copyBytes(r.candRemote[i].key))
+		}
+	}
+	return onlyLocal, onlyRemote
+}
+
+// leafRange appends to indices the indices of the leaves under the given
+// node of the merkle tree, if any.
+func (t *Tree) leafRange(id NodeID, indices []int) []int {
+	for i := id.Index << uint(id.Height); i < (id.Index+1)<<uint(id.Height) && i < len(t.tls); i++ {
+		indices = append(indices, i)
+	}
+	return indices
+}
+
+// treeHeight returns the height of a merkle tree of the given number of
+// leaves.
+func treeHeight(numLeaves int) int {
+	if numLeaves <= 1 {
+		return 0
+	}
+	return bits.Len(uint(numLeaves - 1))
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (q *Query) MarshalBinary() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(q.Nodes)))
+	for _, id := range q.Nodes {
+		b = binary.AppendUvarint(b, uint64(id.Height))
+		b = binary.AppendUvarint(b, uint64(id.Index))
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (q *Query) UnmarshalBinary(data []byte) error {
+	d := binaryDecoder{buf: data}
+	n := d.uvarint()
+	if d.err || n > uint64(len(d.buf)) {
+		return ErrInvalidEncoding
+	}
+	nodes := make([]NodeID, n)
+	for i := range nodes {
+		nodes[i] = NodeID{Height: d.int(), Index: d.int()}
+	}
+	if d.err || len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	q.Nodes = nodes
+	return nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (r *Reply) MarshalBinary() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(r.NumLeaves))
+	b = binary.AppendUvarint(b, uint64(len(r.Digests)))
+	for i := range r.Digests {
+		b = binary.AppendUvarint(b, uint64(len(r.Digests[i])))
+		b = append(b, r.Digests[i]...)
+		if len(r.Digests[i]) != 0 {
+			b = binary.AppendUvarint(b, uint64(len(r.Leaves[i])))
+			b = append(b, r.Leaves[i]...)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (r *Reply) UnmarshalBinary(data []byte) error {
+	d := binaryDecoder{buf: data}
+	numLeaves, n := d.int(), d.uvarint()
+	if d.err || n > uint64(len(d.buf)) {
+		return ErrInvalidEncoding
+	}
+	digests, leaves := make([][]byte, n), make([][]byte, n)
+	for i := range digests {
+		digests[i] = d.next(d.int())
+		if len(digests[i]) != 0 {
+			leaves[i] = d.next(d.int())
+		}
+	}
+	if d.err || len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	r.NumLeaves, r.Digests, r.Leaves = numLeaves, digests, leaves
+	return nil
+}
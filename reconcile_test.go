@@ -0,0 +1,120 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+// reconcile runs the set reconciliation protocol between the given local and
+// remote merkle trees, passing every message through its binary encoding.
+func reconcile(t *testing.T, local, remote *Tree) (onlyLocal, onlyRemote [][]byte, digests int) {
+	r := NewReconciler(local)
+	q := r.Start()
+	for rounds := 0; q != nil; rounds++ {
+		if rounds > 2*(len(local.rows)+len(remote.rows))+2 {
+			t.Fatal("too many rounds")
+		}
+		data, err := q.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var q2 Query
+		if err = q2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if data, err = remote.Answer(&q2).MarshalBinary(); err != nil {
+			t.Fatal(err)
+		}
+		var reply Reply
+		if err = reply.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		digests += len(reply.Digests)
+		if q, err = r.Next(&reply); err != nil {
+			t.Fatal(err)
+		}
+	}
+	onlyLocal, onlyRemote = r.Result()
+	return
+}
+
+func TestReconcile00(t *testing.T) {
+	a, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTree(crypto.SHA256, enAlphabetCap...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyA, onlyB, digests := reconcile(t, a, b)
+	t.Logf("identical trees: %d digests exchanged", digests)
+	if len(onlyA) != 0 || len(onlyB) != 0 || digests != 1 {
+		t.Fatalf("want no differences after 1 digest; got %q, %q after %d", onlyA, onlyB, digests)
+	}
+
+	b.DeleteAndReconstruct(Z)
+	b.AppendAndReconstruct(grAlphabet[0])
+	onlyA, onlyB, digests = reconcile(t, a, b)
+	t.Logf("onlyA: %q, onlyB: %q, %d digests exchanged", onlyA, onlyB, digests)
+	if len(onlyA) != 1 || !bytes.Equal(onlyA[0], Z.Serialize()) {
+		t.Fatalf("want (%q); got %q", Z.Serialize(), onlyA)
+	}
+	if len(onlyB) != 1 || !bytes.Equal(onlyB[0], grAlphabet[0].Serialize()) {
+		t.Fatalf("want (%q); got %q", grAlphabet[0].Serialize(), onlyB)
+	}
+}
+func TestReconcile01(t *testing.T) {
+	// The results must agree with Diff, in either direction and for trees
+	// of different heights.
+	a, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:5], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.AppendAndReconstruct(enAlphabetCap[:14]...)
+	for _, pair := range [][2]*Tree{{a, b}, {b, a}} {
+		wantL, wantR, err := Diff(pair[0], pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		gotL, gotR, digests := reconcile(t, pair[0], pair[1])
+		t.Logf("%d digests exchanged", digests)
+		if len(gotL) != len(wantL) || len(gotR) != len(wantR) {
+			t.Fatalf("want (%q, %q); got %q, %q", wantL, wantR, gotL, gotR)
+		}
+		for i := range wantL {
+			if !bytes.Equal(gotL[i], wantL[i]) {
+				t.Fatalf("want (%q); got %q", wantL[i], gotL[i])
+			}
+		}
+		for i := range wantR {
+			if !bytes.Equal(gotR[i], wantR[i]) {
+				t.Fatalf("want (%q); got %q", wantR[i], gotR[i])
+			}
+		}
+	}
+
+	r := NewReconciler(a)
+	if _, err = r.Next(&Reply{Digests: [][]byte{nil}}); err == nil {
+		t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+	}
+}
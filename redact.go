@@ -0,0 +1,230 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+)
+
+// Redacted is a redacted copy of a merkle tree; i.e. all of its leaves, in
+// order, of which the revealed ones carry their serialized data (and salts,
+// if salted; see WithSalts) while the rest are replaced by their digests.
+// It verifies against the merkle root of the original merkle tree, without
+// disclosing anything about the redacted leaves but their digests (which,
+// if salted, disclose nothing about their data either).
+type Redacted struct {
+	// Hash is the hash function that the merkle tree was built with.
+	Hash crypto.Hash
+	// Leaves are the leaves of the merkle tree, in order.
+	Leaves []RedactedLeaf
+
+	// scheme is the hashing scheme of the merkle tree, if not the default.
+	scheme *scheme
+}
+
+// RedactedLeaf is a leaf of a Redacted merkle tree.
+type RedactedLeaf struct {
+	// Revealed reports whether the leaf is revealed, in which case Datum
+	// and Salt are set, rather than Digest.
+	Revealed bool
+	// Digest is the hash digest of a redacted leaf.
+	Digest []byte
+	// Datum is the serialized datum of a revealed leaf.
+	Datum []byte
+	// Salt is the salt of a revealed leaf, if the merkle tree is salted.
+	Salt []byte
+}
+
+// Redact returns a Redacted copy of the merkle tree, which reveals the leaves
+// at the given indices and redacts the rest.
+//
+// It returns a non-nil error if any of the given indices is out of range, or
+// if the merkle tree does not retain the serialized data of its leaves, or
+// keys them (see WithLeafKey).
+func (t *Tree) Redact(reveal ...int) (*Redacted, error) {
+	if t.digestOnly || t.scheme.leafKey != nil {
+		return nil, ErrUnsupported
+	}
+	revealed := make(map[int]bool, len(reveal))
+	for _, i := range reveal {
+		if i < 0 || i >= len(t.tls) {
+			return nil, ErrInvalidRange
+		}
+		revealed[i] = true
+	}
+	r := &Redacted{
+		Hash:   t.hash,
+		Leaves: make([]RedactedLeaf, len(t.tls)),
+	}
+	if s := t.redactedScheme(); !s.isDefault() {
+		r.scheme = &s
+	}
+	for i := range t.tls {
+		rl := &r.Leaves[i]
+		if !revealed[i] {
+			rl.Digest = copyBytes(t.tls[i].digest)
+			continue
+		}
+		rl.Revealed, rl.Datum = true, copyBytes(t.leafDatum(&t.tls[i]))
+		if t.tls[i].salt != nil {
+			rl.Salt = copyBytes(t.tls[i].salt)
+		}
+	}
+	return r, nil
+}
+
+// redactedScheme returns the scheme that the Redacted copies of the merkle
+// tree are verified against; the salts are carried by the revealed leaves.
+func (t *Tree) redactedScheme() scheme {
+	s := t.scheme
+	s.salted = false
+	return s
+}
+
+// Verify reports whether the Redacted merkle tree leads to the given merkle
+// root; i.e. whether its revealed leaves, hashed, along with its redacted
+// ones, make up the merkle tree of that root.
+func (r *Redacted) Verify(root []byte) bool {
+	t, ok := r.tree()
+	return ok && bytes.Equal(t.root(), root)
+}
+
+// Revealed returns the indices of the revealed leaves of the Redacted merkle
+// tree, in ascending order.
+func (r *Redacted) Revealed() []int {
+	var indices []int
+	for i := range r.Leaves {
+		if r.Leaves[i].Revealed {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// tree reconstructs the (digest-only) merkle tree of the Redacted one; it
+// reports whether that is possible.
+func (r *Redacted) tree() (*Tree, bool) {
+	t := &Tree{
+		hash:           r.Hash,
+		digestOnly:     true,
+		insertionOrder: true,
+	}
+	if r.scheme != nil {
+		t.scheme = *r.scheme
+	}
+	if !t.hashAvailable() || len(r.Leaves) == 0 || t.checkPadding(len(r.Leaves)) != nil {
+		return nil, false
+	}
+	h := t.newHasher()
+	t.tls, t.nextID = make([]treeLeaf, len(r.Leaves)), uint64(len(r.Leaves))
+	for i := range r.Leaves {
+		rl := &r.Leaves[i]
+		t.tls[i] = treeLeaf{digest: rl.Digest, orderedID: uint64(i)}
+		if rl.Revealed {
+			t.tls[i].digest = t.scheme.hashLeaf(h, saltedDatum(rl.Salt, rl.Datum))
+		} else if len(rl.Digest) != h.Size() {
+			return nil, false
+		}
+	}
+	if t.setNodes(t.constructMerkleNodes(h, t.tls)) != nil {
+		return nil, false
+	}
+	return t, true
+}
+
+// redactedMagic prefixes every binary encoding of a Redacted merkle tree.
+var redactedMagic = []byte("MRKD")
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+//
+// The binary encoding is versioned (along with that of Tree) and
+// deterministic. It returns a non-nil error if the hash function of the
+// merkle tree was given through WithHashFunc.
+func (r *Redacted) MarshalBinary() ([]byte, error) {
+	s := schemeOrDefault(r.scheme)
+	if !s.encodable() {
+		return nil, ErrUnsupported
+	}
+	b := append([]byte(nil), redactedMagic...)
+	b = s.appendBinaryFlags(b, s.binaryFlags(), 0)
+	b = binary.AppendUvarint(b, uint64(r.Hash))
+	b = s.appendBinary(b)
+	b = binary.AppendUvarint(b, uint64(len(r.Leaves)))
+	for i := range r.Leaves {
+		rl := &r.Leaves[i]
+		if !rl.Revealed {
+			b = append(b, 0)
+			b = binary.AppendUvarint(b, uint64(len(rl.Digest)))
+			b = append(b, rl.Digest...)
+			continue
+		}
+		b = append(b, 1)
+		b = binary.AppendUvarint(b, uint64(len(rl.Salt)))
+		b = append(b, rl.Salt...)
+		b = binary.AppendUvarint(b, uint64(len(rl.Datum)))
+		b = append(b, rl.Datum...)
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+//
+// It returns a non-nil error if the given data are not a valid encoding of a
+// Redacted merkle tree.
+func (r *Redacted) UnmarshalBinary(data []byte) error {
+	d := binaryDecoder{buf: data}
+	if string(d.next(len(redactedMagic))) != string(redactedMagic) {
+		return ErrInvalidEncoding
+	}
+	flags, ext := d.flags()
+	hash := crypto.Hash(d.uvarint())
+	if d.err || hash == 0 || hash >= maxHash {
+		return ErrInvalidEncoding
+	}
+	var s scheme
+	if !d.scheme(&s, flags, ext, len(data)) {
+		return ErrInvalidEncoding
+	}
+	numLeaves := d.uvarint()
+	if d.err || numLeaves == 0 || numLeaves > uint64(len(d.buf)) {
+		return ErrInvalidEncoding
+	}
+	r2 := Redacted{Hash: hash, Leaves: make([]RedactedLeaf, numLeaves)}
+	if !s.isDefault() {
+		r2.scheme = &s
+	}
+	for i := range r2.Leaves {
+		rl := &r2.Leaves[i]
+		switch d.byte() {
+		case 0:
+			rl.Digest = d.next(d.int())
+		case 1:
+			rl.Revealed = true
+			if salt := d.next(d.int()); len(salt) != 0 {
+				rl.Salt = salt
+			}
+			rl.Datum = d.next(d.int())
+		default:
+			return ErrInvalidEncoding
+		}
+	}
+	if d.err || len(d.buf) != 0 {
+		return ErrInvalidEncoding
+	}
+	*r = r2
+	return nil
+}
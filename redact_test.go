@@ -0,0 +1,91 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestRedact00(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithSalts()},
+		{RFC6962()},
+		{WithArity(3), PadToPowerOfTwo(nil)},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := tree.Redact(1, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.Revealed(); len(got) != 2 || got[0] != 1 || got[1] != 4 {
+			t.Fatalf("want revealed ([1 4]); got %v", got)
+		}
+		if r.Leaves[0].Datum != nil || !bytes.Equal(r.Leaves[1].Datum, tree.tls[1].datum) {
+			t.Fatalf("want redacted leaf 0 and revealed leaf 1; got %+v and %+v", r.Leaves[0], r.Leaves[1])
+		}
+		if !r.Verify(tree.MerkleRoot()) {
+			t.Fatal("redacted tree does not verify")
+		}
+
+		b, err := r.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r2 Redacted
+		if err := r2.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if !r2.Verify(tree.MerkleRoot()) {
+			t.Fatal("decoded redacted tree does not verify")
+		}
+		if err := r2.UnmarshalBinary(b[:len(b)-1]); !errors.Is(err, ErrInvalidEncoding) {
+			t.Fatalf("want (%v); got %v", ErrInvalidEncoding, err)
+		}
+
+		r2.Leaves[4].Datum = []byte("forged")
+		if r2.Verify(tree.MerkleRoot()) {
+			t.Fatal("forged redacted tree verifies")
+		}
+	}
+}
+
+func TestRedact01(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, WithLeafKey([]byte("key")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Redact(0); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if tree, err = NewTree(crypto.SHA256, enAlphabetCap...); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Redact(len(enAlphabetCap)); !errors.Is(err, ErrInvalidRange) {
+		t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+	}
+	r, err := tree.Redact()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Revealed()) != 0 || !r.Verify(tree.MerkleRoot()) {
+		t.Fatal("fully redacted tree does not verify")
+	}
+}
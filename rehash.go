@@ -0,0 +1,127 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "crypto"
+
+// Rehash returns a new merkle tree of the same leaves and configuration as
+// the given one, whose digests are calculated with the given hash function
+// instead; e.g. to migrate away from a hash function that is no longer deemed
+// secure. The given tree is left intact, and the two share the serialized data
+// of their leaves (and their salts, if any).
+//
+// The merkle nodes of the new tree are kept in memory, even if the given tree
+// reads them through a NodeStore, and its version starts at 0, without any
+// history; the ordered IDs of its leaves and its NextID are retained.
+//
+// It returns a non-nil error if the given hash function has not been linked
+// into the binary, if the merkle tree is in digest-only mode (hence there are
+// no data to rehash), if its hash function was given through WithHashFunc or
+// WithHasher, or if the digest of its empty leaves (see PadToPowerOfTwo) is not
+// of the size that the given hash function produces.
+func (t *Tree) Rehash(newHash crypto.Hash) (*Tree, error) {
+	t2, err := t.rehashed(newHash)
+	if err != nil {
+		return nil, err
+	}
+	t2.duplicates = t.duplicates
+	t2.metrics, t2.logger, t2.noHasherPool = t.metrics, t.logger, t.noHasherPool
+	t2.multiHasher, t2.recycle = t.multiHasher, t.recycle
+	t2.onProgress = t.onProgress
+	t2.keepHistory, t2.historyLimit = t.keepHistory, t.historyLimit
+	t2.secondary = t.secondary
+	if err := t2.setNodes(t2.constructMerkleNodes(t2.newHasher(), t2.tls)); err != nil {
+		return nil, err
+	}
+	return t2, nil
+}
+
+// rehashed returns a new merkle tree whose leaves are the ones of the given
+// tree, rehashed with the given hash function; only the configuration that
+// affects the digests and the order of the leaves is carried over, and no
+// merkle nodes are constructed.
+func (t *Tree) rehashed(newHash crypto.Hash) (*Tree, error) {
+	if t.digestOnly || t.scheme.newHash != nil {
+		return nil, ErrUnsupported
+	}
+	t2 := &Tree{
+		hash:           newHash,
+		insertionOrder: t.insertionOrder,
+		scheme:         t.scheme,
+		less:           t.less,
+		compressor:     t.compressor,
+		dedup:          t.dedup,
+		nextID:         t.nextID,
+	}
+	if !t2.hashAvailable() {
+		return nil, &HashError{Hash: newHash}
+	}
+	if err := t2.checkPadding(len(t.tls)); err != nil {
+		return nil, err
+	}
+	// The leaves are sorted by their data, so their order is retained.
+	h := t2.newHasher()
+	a := newDigestArena(h.Size(), len(t.tls))
+	t2.tls = make([]treeLeaf, len(t.tls))
+	for i := range t.tls {
+		t2.tls[i] = t.tls[i]
+		t2.tls[i].digest = t2.scheme.hashLeafTo(h, a.next(), saltedDatum(t.tls[i].salt, t.leafDatum(&t.tls[i])))
+	}
+	return t2, nil
+}
+
+// WithSecondaryHash configures the merkle tree to calculate a second merkle
+// root of its leaves, with the given hash function, along with its own one
+// upon every mutation (see SecondaryRoot); e.g. so that both the old and the
+// new merkle roots can be published while migrating to another hash function
+// (see Rehash). It doubles the hash calculations of every mutation at least,
+// since all the leaves are rehashed every time.
+//
+// It has no effect on merkle trees in digest-only mode, on ones whose hash
+// function was given through WithHashFunc or WithHasher, or if the given hash
+// function has not been linked into the binary.
+func WithSecondaryHash(hash crypto.Hash) Option {
+	return func(t *Tree) {
+		t.secondary = hash
+	}
+}
+
+// SecondaryRoot returns the merkle root of the leaves of the merkle tree as
+// calculated with the hash function given through WithSecondaryHash, or nil
+// if none was given (or if it has no effect on the merkle tree).
+func (t *Tree) SecondaryRoot() []byte {
+	if t.secondaryRoot == nil {
+		return nil
+	}
+	return copyBytes(t.secondaryRoot)
+}
+
+// updateSecondaryRoot recalculates the secondary merkle root of the merkle
+// tree (see WithSecondaryHash) out of its current leaves.
+func (t *Tree) updateSecondaryRoot() {
+	t.secondaryRoot = nil
+	if t.secondary == 0 || len(t.tls) == 0 {
+		return
+	}
+	t2, err := t.rehashed(t.secondary)
+	if err != nil {
+		return
+	}
+	if n := t2.constructMerkleNodes(t2.newHasher(), t2.tls); n.height() > 0 {
+		t.secondaryRoot = n.root()
+	} else {
+		t.secondaryRoot = t2.tls[0].digest
+	}
+}
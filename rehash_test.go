@@ -0,0 +1,139 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRehash00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA1, grAlphabet[:10], WithSalts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.DeleteAndReconstruct(grAlphabet[3])
+	oldRoot := copyBytes(tree.MerkleRoot())
+
+	tree2, err := tree.Rehash(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree2.Hash() != crypto.SHA256 || len(tree2.MerkleRoot()) != crypto.SHA256.Size() {
+		t.Fatalf("want (%v); got %v", crypto.SHA256, tree2.Hash())
+	}
+	if !bytes.Equal(tree.MerkleRoot(), oldRoot) {
+		t.Fatalf("want root (%x); got %x", oldRoot, tree.MerkleRoot())
+	}
+	if !reflect.DeepEqual(tree2.Leaves(), tree.Leaves()) || tree2.NextID() != tree.NextID() {
+		t.Fatalf("want (%d) leaves; got %d", tree.NumLeaves(), tree2.NumLeaves())
+	}
+	for i := range tree.tls {
+		if tree2.tls[i].orderedID != tree.tls[i].orderedID {
+			t.Fatalf("want ordered ID (%d); got %d", tree.tls[i].orderedID, tree2.tls[i].orderedID)
+		}
+	}
+	for _, d := range grAlphabet[4:10] {
+		if v, err := tree2.VerifyDatum(d); err != nil || !v {
+			t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+		}
+	}
+	t.Logf("rehashed %x into %x", oldRoot, tree2.MerkleRoot())
+
+	// Without salts, the rehashed tree is the one built with the new hash
+	// function to begin with.
+	tree, err = NewTree(crypto.SHA1, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree2, err = tree.Rehash(crypto.SHA256); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree2.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), tree2.MerkleRoot())
+	}
+}
+
+func TestRehash01(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Rehash(crypto.Hash(0)); !errors.Is(err, ErrHashUnavailable) {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+	tree, err = NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Rehash(crypto.SHA1); err != ErrUnsupported {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	tree, err = NewTreeWithOptions(crypto.SHA256, grAlphabet[:5], PadToPowerOfTwo(bytes.Repeat([]byte{1}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.Rehash(crypto.SHA1); err != ErrInvalidDigest {
+		t.Fatalf("want (%v); got %v", ErrInvalidDigest, err)
+	}
+}
+
+func TestWithSecondaryHash00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA1, grAlphabet[:1], WithSecondaryHash(crypto.SHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= len(grAlphabet); i++ {
+		want, err := NewTree(crypto.SHA256, grAlphabet[:i]...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.SecondaryRoot(), want.MerkleRoot()) {
+			t.Fatalf("want secondary root (%x); got %x", want.MerkleRoot(), tree.SecondaryRoot())
+		}
+		if i < len(grAlphabet) {
+			tree.AppendAndReconstruct(grAlphabet[i])
+		}
+	}
+	want, err := NewTree(crypto.SHA1, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	t.Logf("primary root %x, secondary root %x", tree.MerkleRoot(), tree.SecondaryRoot())
+
+	if tree, err = NewTree(crypto.SHA1, grAlphabet...); err != nil {
+		t.Fatal(err)
+	}
+	if root := tree.SecondaryRoot(); root != nil {
+		t.Fatalf("want (nil); got %x", root)
+	}
+	tree, err = NewTreeWithOptions(crypto.SHA1, grAlphabet, DigestOnly(), WithSecondaryHash(crypto.SHA256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root := tree.SecondaryRoot(); root != nil {
+		t.Fatalf("want (nil); got %x", root)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"hash"
+	"math/bits"
+)
+
+// RootAt returns the merkle root that the merkle tree had when it comprised
+// only its first size leaves, as defined by RFC 6962 (section 2.1).
+//
+// It returns a non-nil error if the merkle tree was not configured with the
+// RFC6962 Option, or if size is greater than the number of leaves.
+func (t *Tree) RootAt(size int) ([]byte, error) {
+	if !t.isAppendOnly() {
+		return nil, ErrUnsupported
+	}
+	if size < 0 || size > len(t.tls) {
+		return nil, ErrInvalidRange
+	}
+	h := t.newHasher()
+	if size == 0 {
+		return h.Sum(nil), nil
+	}
+	return t.subtreeHash(h, 0, size), nil
+}
+
+// InclusionProof returns the audit path of the leaf at the given index in the
+// merkle tree as it was when it comprised only its first size leaves, as
+// defined by RFC 6962 (section 2.1.1). The audit path is ordered from the
+// leaf's sibling up to the child of the root.
+//
+// It returns a non-nil error if the merkle tree was not configured with the
+// RFC6962 Option, or if the index and the size are out of range.
+func (t *Tree) InclusionProof(leafIndex, size int) ([][]byte, error) {
+	if !t.isAppendOnly() {
+		return nil, ErrUnsupported
+	}
+	if leafIndex < 0 || leafIndex >= size || size > len(t.tls) {
+		return nil, ErrInvalidRange
+	}
+	t.observeProof()
+	return t.inclusionPath(t.newHasher(), leafIndex, 0, size), nil
+}
+
+// ConsistencyProof returns the proof that the merkle tree as it was when it
+// comprised its first oldSize leaves is a prefix of the merkle tree as it was
+// when it comprised its first newSize leaves, as defined by RFC 6962 (section
+// 2.1.2).
+//
+// It returns a non-nil error if the merkle tree was not configured with the
+// RFC6962 Option, or if the sizes are out of range.
+func (t *Tree) ConsistencyProof(oldSize, newSize int) ([][]byte, error) {
+	if !t.isAppendOnly() {
+		return nil, ErrUnsupported
+	}
+	if oldSize <= 0 || oldSize > newSize || newSize > len(t.tls) {
+		return nil, ErrInvalidRange
+	}
+	t.observeProof()
+	return t.consistencyPath(t.newHasher(), oldSize, 0, newSize, true), nil
+}
+
+// VerifyConsistency reports whether proof (as returned by ConsistencyProof)
+// proves that the merkle tree of oldSize leaves and merkle root oldRoot is a
+// prefix of the merkle tree of newSize leaves and merkle root newRoot, as
+// defined by RFC 6962 (section 2.1.2) and verified as per RFC 9162 (section
+// 2.1.4.2). Both merkle trees are assumed to hash as per the RFC6962 Option;
+// neither of them is required, so that it can be used by monitors that only
+// ever see merkle roots.
+//
+// An empty merkle tree is a prefix of any other, and a merkle tree is a
+// prefix of itself, both proven by an empty proof.
+func VerifyConsistency(h crypto.Hash, oldRoot, newRoot []byte, oldSize, newSize uint64, proof [][]byte) bool {
+	switch {
+	case !h.Available() || oldSize > newSize:
+		return false
+	case oldSize == 0:
+		return len(proof) == 0
+	case oldSize == newSize:
+		return len(proof) == 0 && bytes.Equal(oldRoot, newRoot)
+	}
+	// If the old merkle tree is perfect, its root is the first node of the
+	// path, which RFC 6962 leaves implicit.
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([][]byte{oldRoot}, proof...)
+	}
+	if len(proof) == 0 {
+		return false
+	}
+	hh := h.New()
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn, sn = fn>>1, sn>>1
+	}
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = rfc6962Scheme.hashNode(hh, c, fr)
+			sr = rfc6962Scheme.hashNode(hh, c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn, sn = fn>>1, sn>>1
+			}
+		} else {
+			sr = rfc6962Scheme.hashNode(hh, sr, c)
+		}
+		fn, sn = fn>>1, sn>>1
+	}
+	return sn == 0 && bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot)
+}
+
+// isAppendOnly reports whether the merkle tree is laid out as per RFC 6962,
+// so that its earlier versions can be recovered from its nodes.
+func (t *Tree) isAppendOnly() bool {
+	return t.insertionOrder && t.scheme.padding == PromoteLone && t.scheme.width() == 2 && !t.scheme.padded && !t.scheme.sortedPairs
+}
+
+// subtreeHash returns the digest of the subtree over the leaves in [lo, hi).
+//
+// Subtrees that are perfect and aligned are already stored in the merkle
+// tree; any other is split, as per RFC 6962, at the largest power of two
+// that is smaller than its size.
+func (t *Tree) subtreeHash(h hash.Hash, lo, hi int) []byte {
+	n := hi - lo
+	if n&(n-1) == 0 && lo%n == 0 {
+		height := bits.TrailingZeros(uint(n))
+		return t.nodeAt(height, lo>>height)
+	}
+	k := splitPoint(n)
+	return t.scheme.hashNode(h, t.subtreeHash(h, lo, lo+k), t.subtreeHash(h, lo+k, hi))
+}
+
+// inclusionPath implements PATH(m, D[lo:hi]) of RFC 6962.
+func (t *Tree) inclusionPath(h hash.Hash, m, lo, hi int) [][]byte {
+	if hi-lo == 1 {
+		return nil
+	}
+	k := splitPoint(hi - lo)
+	if m < k {
+		return append(t.inclusionPath(h, m, lo, lo+k), copyBytes(t.subtreeHash(h, lo+k, hi)))
+	}
+	return append(t.inclusionPath(h, m-k, lo+k, hi), copyBytes(t.subtreeHash(h, lo, lo+k)))
+}
+
+// consistencyPath implements SUBPROOF(m, D[lo:hi], b) of RFC 6962.
+func (t *Tree) consistencyPath(h hash.Hash, m, lo, hi int, b bool) [][]byte {
+	if m == hi-lo {
+		if b {
+			return nil
+		}
+		return [][]byte{copyBytes(t.subtreeHash(h, lo, hi))}
+	}
+	k := splitPoint(hi - lo)
+	if m <= k {
+		return append(t.consistencyPath(h, m, lo, lo+k, b), copyBytes(t.subtreeHash(h, lo+k, hi)))
+	}
+	return append(t.consistencyPath(h, m-k, lo+k, hi, false), copyBytes(t.subtreeHash(h, lo, lo+k)))
+}
+
+// splitPoint returns the largest power of two that is smaller than n (n > 1).
+func splitPoint(n int) int {
+	return 1 << (bits.Len(uint(n-1)) - 1)
+}
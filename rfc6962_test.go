@@ -0,0 +1,232 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// rfc6962Leaves and rfc6962Roots are the test vectors of the reference
+// implementation of Certificate Transparency.
+var (
+	rfc6962Leaves = []string{
+		"",
+		"00",
+		"10",
+		"2021",
+		"3031",
+		"40414243",
+		"5051525354555657",
+		"606162636465666768696a6b6c6d6e6f",
+	}
+	rfc6962Roots = []string{
+		"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+		"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+		"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+		"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+		"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+		"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+		"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+	}
+)
+
+func newRFC6962Tree(t *testing.T, numLeaves int) *Tree {
+	data := make([]Datum, numLeaves)
+	for i := range data {
+		b, err := hex.DecodeString(rfc6962Leaves[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data[i] = ByteDatum(b)
+	}
+	tree, err := NewTreeWithOptions(crypto.SHA256, data, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestRootAt00(t *testing.T) {
+	for n := 1; n <= len(rfc6962Leaves); n++ {
+		tree := newRFC6962Tree(t, n)
+		t.Logf("size %d: %x", n, tree.MerkleRoot())
+		if hex.EncodeToString(tree.MerkleRoot()) != rfc6962Roots[n-1] {
+			t.Fatalf("want (%s); got %x", rfc6962Roots[n-1], tree.MerkleRoot())
+		}
+	}
+
+	tree := newRFC6962Tree(t, len(rfc6962Leaves))
+	for n := 1; n <= len(rfc6962Leaves); n++ {
+		root, err := tree.RootAt(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(root) != rfc6962Roots[n-1] {
+			t.Fatalf("want (%s); got %x", rfc6962Roots[n-1], root)
+		}
+	}
+	if root, err := tree.RootAt(0); err != nil || hex.EncodeToString(root) != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Fatalf("want (e3b0c442..., <nil>); got (%x, %v)", root, err)
+	}
+	if _, err := tree.RootAt(len(rfc6962Leaves) + 1); err == nil {
+		t.Fatal("want non-nil error")
+	}
+}
+
+func TestInclusionProof00(t *testing.T) {
+	tree := newRFC6962Tree(t, len(rfc6962Leaves))
+	for n := 1; n <= len(rfc6962Leaves); n++ {
+		root, _ := tree.RootAt(n)
+		for i := 0; i < n; i++ {
+			path, err := tree.InclusionProof(i, n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The audit path must lead from the leaf to the root, as
+			// does the one of a tree of exactly n leaves.
+			p, err := newRFC6962Tree(t, n).Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(root) {
+				t.Fatalf("proof of leaf %d at size %d does not verify", i, n)
+			}
+			var siblings [][]byte
+			for _, s := range p.Siblings {
+				if len(s) != 0 {
+					siblings = append(siblings, s)
+				}
+			}
+			if len(siblings) != len(path) {
+				t.Fatalf("want (%d) path nodes; got %d", len(siblings), len(path))
+			}
+			for j := range path {
+				if !bytes.Equal(path[j], siblings[j]) {
+					t.Fatalf("want (%x); got %x", siblings[j], path[j])
+				}
+			}
+		}
+	}
+	if _, err := tree.InclusionProof(3, 3); err == nil {
+		t.Fatal("want non-nil error")
+	}
+}
+
+func TestConsistencyProof00(t *testing.T) {
+	tree := newRFC6962Tree(t, len(rfc6962Leaves))
+	// Test vectors of the reference implementation of Certificate
+	// Transparency.
+	tests := []struct {
+		oldSize, newSize int
+		proof            []string
+	}{
+		{1, 1, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+	for _, test := range tests {
+		proof, err := tree.ConsistencyProof(test.oldSize, test.newSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("%d -> %d: %x", test.oldSize, test.newSize, proof)
+		if len(proof) != len(test.proof) {
+			t.Fatalf("want (%d) proof nodes; got %d", len(test.proof), len(proof))
+		}
+		for i := range proof {
+			if hex.EncodeToString(proof[i]) != test.proof[i] {
+				t.Fatalf("want (%s); got %x", test.proof[i], proof[i])
+			}
+		}
+	}
+	if _, err := tree.ConsistencyProof(5, 4); err == nil {
+		t.Fatal("want non-nil error")
+	}
+}
+
+func TestRFC6962Unsupported00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.RootAt(1); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := tree.InclusionProof(0, 1); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	if _, err := tree.ConsistencyProof(1, 2); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestVerifyConsistency00(t *testing.T) {
+	tree := newRFC6962Tree(t, len(rfc6962Leaves))
+	for newSize := 1; newSize <= len(rfc6962Leaves); newSize++ {
+		newRoot, err := tree.RootAt(newSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for oldSize := 1; oldSize <= newSize; oldSize++ {
+			oldRoot, err := tree.RootAt(oldSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof, err := tree.ConsistencyProof(oldSize, newSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !VerifyConsistency(crypto.SHA256, oldRoot, newRoot, uint64(oldSize), uint64(newSize), proof) {
+				t.Fatalf("want (true) for %d -> %d; got false", oldSize, newSize)
+			}
+			if oldSize == newSize {
+				continue
+			}
+			if VerifyConsistency(crypto.SHA256, newRoot, newRoot, uint64(oldSize), uint64(newSize), proof) {
+				t.Fatalf("want (false) for %d -> %d with the wrong old root; got true", oldSize, newSize)
+			}
+			if VerifyConsistency(crypto.SHA256, oldRoot, oldRoot, uint64(oldSize), uint64(newSize), proof) {
+				t.Fatalf("want (false) for %d -> %d with the wrong new root; got true", oldSize, newSize)
+			}
+			if len(proof) > 0 && VerifyConsistency(crypto.SHA256, oldRoot, newRoot, uint64(oldSize), uint64(newSize), proof[1:]) {
+				t.Fatalf("want (false) for %d -> %d with a truncated proof; got true", oldSize, newSize)
+			}
+		}
+	}
+	root, _ := tree.RootAt(3)
+	if !VerifyConsistency(crypto.SHA256, nil, root, 0, 3, nil) {
+		t.Fatal("want (true) for the empty tree; got false")
+	}
+	if VerifyConsistency(crypto.SHA256, root, root, 4, 3, nil) {
+		t.Fatal("want (false) for oldSize > newSize; got true")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto/rand"
+)
+
+// Disclosure discloses a single leaf of a merkle tree whose leaves are
+// salted (see WithSalts); i.e. its serialized datum and its salt, along with
+// its inclusion Proof.
+type Disclosure struct {
+	// Proof is the inclusion proof of the leaf.
+	Proof *Proof
+	// Datum is the serialized datum of the leaf.
+	Datum []byte
+	// Salt is the salt that the datum was hashed along with.
+	Salt []byte
+}
+
+// Disclose returns the Disclosure of the leaf at the given index among the
+// (sorted) leaves of the merkle tree, revealing its datum and its salt (if
+// the leaves are salted) but nothing about the rest of them.
+//
+// It returns a non-nil error if the given index is out of range, or if the
+// merkle tree does not retain the serialized data of its leaves.
+func (t *Tree) Disclose(leafIndex int) (*Disclosure, error) {
+	if t.digestOnly {
+		return nil, ErrUnsupported
+	}
+	p, err := t.Proof(leafIndex)
+	if err != nil {
+		return nil, err
+	}
+	d := &Disclosure{Proof: p, Datum: copyBytes(t.leafDatum(&t.tls[leafIndex]))}
+	if t.tls[leafIndex].salt != nil {
+		d.Salt = copyBytes(t.tls[leafIndex].salt)
+	}
+	return d, nil
+}
+
+// Verify reports whether the disclosed datum, hashed along with the
+// disclosed salt, is the leaf that the Proof refers to, and that the Proof
+// leads to the given merkle root.
+func (d *Disclosure) Verify(root []byte) bool {
+	if d.Proof == nil {
+		return false
+	}
+	s := schemeOrDefault(d.Proof.scheme)
+	if !s.available(d.Proof.Hash) {
+		return false
+	}
+	digest := s.hashLeaf(s.newHasher(d.Proof.Hash), saltedDatum(d.Salt, d.Datum))
+	return bytes.Equal(digest, d.Proof.LeafDigest) && d.Proof.Verify(root)
+}
+
+// newSalt returns a random salt of the given size.
+func newSalt(size int) []byte {
+	salt := make([]byte, size)
+	if _, err := rand.Read(salt); err != nil {
+		panic(err)
+	}
+	return salt
+}
+
+// saltedDatum returns the input of the hash function for a leaf of the given
+// salt and serialized datum; i.e. their concatenation, or the serialized
+// datum itself if there is no salt.
+func saltedDatum(salt, serializedDatum []byte) []byte {
+	if len(salt) == 0 {
+		return serializedDatum
+	}
+	b := make([]byte, 0, len(salt)+len(serializedDatum))
+	return append(append(b, salt...), serializedDatum...)
+}
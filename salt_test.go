@@ -0,0 +1,97 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestWithSalts00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, WithSalts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree2, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, WithSalts())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("tree.MerkleRoot():  %x", tree.MerkleRoot())
+	t.Logf("tree2.MerkleRoot(): %x", tree2.MerkleRoot())
+	if bytes.Equal(tree.MerkleRoot(), tree2.MerkleRoot()) {
+		t.Fatal("salted trees of the same data share their merkle root")
+	}
+
+	for i, word := range enAlphabetCap {
+		if v, err := tree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+		d, err := tree.Disclose(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(d.Salt) != crypto.SHA256.Size() {
+			t.Fatalf("want salt size (%d); got %d", crypto.SHA256.Size(), len(d.Salt))
+		}
+		if !d.Verify(tree.MerkleRoot()) {
+			t.Fatalf("disclosure of leaf %d does not verify", i)
+		}
+		if d.Verify(tree2.MerkleRoot()) {
+			t.Fatalf("disclosure of leaf %d verifies against another root", i)
+		}
+		d.Salt[0] ^= 1
+		if d.Verify(tree.MerkleRoot()) {
+			t.Fatalf("disclosure of leaf %d verifies with a wrong salt", i)
+		}
+	}
+
+	pt, err := tree.Prune(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Leaves[0].Datum != nil || !pt.Verify() {
+		t.Fatalf("want verifiable PartialTree without data; got %+v", pt.Leaves)
+	}
+	if _, err := tree.MarshalBinary(); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestDisclose00(t *testing.T) {
+	tree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := tree.Disclose(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Salt != nil || !bytes.Equal(d.Datum, enAlphabetCap[2].Serialize()) || !d.Verify(tree.MerkleRoot()) {
+		t.Fatalf("want verifiable disclosure of %q without salt; got %q and %x", enAlphabetCap[2], d.Datum, d.Salt)
+	}
+	if _, err := tree.Disclose(len(enAlphabetCap)); err == nil {
+		t.Fatalf("want error; got %v", err)
+	}
+
+	dtree, err := NewTreeWithOptions(crypto.SHA256, enAlphabetCap, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dtree.Disclose(0); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
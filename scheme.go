@@ -0,0 +1,403 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"hash"
+	"sort"
+)
+
+// scheme holds the rules by which the leaves and the merkle nodes of a merkle
+// tree are hashed. Its zero value describes the default rules, i.e. H(datum)
+// for the leaves, H(left || right) for the merkle nodes, and H(node) for the
+// last node of an odd-sized level.
+type scheme struct {
+	// arity is the number of children of each merkle node (except for the
+	// last one of each level, which may have fewer), if greater than 2.
+	arity int
+	// leafPrefix and nodePrefix are prepended to the input of the hash
+	// function, to separate the domains of leaves and merkle nodes.
+	leafPrefix, nodePrefix []byte
+	// padding is the policy for the nodes that have fewer siblings than
+	// the rest (e.g. the last node of an odd-sized level).
+	padding PaddingPolicy
+	// padded makes the merkle tree perfectly balanced, by padding its
+	// leaves with empty ones up to a power of its arity; i.e. up to depth
+	// levels of merkle nodes, or to the fewest that fit them if depth is 0.
+	padded bool
+	depth  int
+	// emptyLeaf is the digest of the empty leaves of a padded merkle tree,
+	// or nil for an all-zero one.
+	emptyLeaf []byte
+	// sortedPairs makes the children of each merkle node be hashed in
+	// ascending order of their digests, rather than in their own order.
+	sortedPairs bool
+	// hashName and newHash identify and construct the hash function of
+	// the merkle tree, if it is not one that crypto.Hash enumerates (see
+	// WithHashFunc).
+	hashName string
+	newHash  func() hash.Hash
+	// hasher hashes the leaves and the merkle nodes by itself, if given
+	// (see WithHasher); newHash then wraps it.
+	hasher Hasher
+	// leafKey keys the digests of the leaves, if given (see WithLeafKey).
+	leafKey []byte
+	// salted makes each leaf be hashed along with a random salt of its
+	// own (see WithSalts).
+	salted bool
+	// lengthPrefixed makes the serialized data be prefixed with their
+	// length before being hashed (see LengthPrefixed).
+	lengthPrefixed bool
+	// digestSize truncates all digests to its number of bytes, if it is
+	// positive and smaller than the size of the hash function (see
+	// TruncateDigests).
+	digestSize int
+}
+
+// rfc6962Scheme describes the hashing rules of RFC 6962 (Certificate
+// Transparency).
+var rfc6962Scheme = scheme{
+	leafPrefix: []byte{0x00},
+	nodePrefix: []byte{0x01},
+	padding:    PromoteLone,
+}
+
+// proofScheme returns the scheme that proofs produced by the merkle tree
+// should be verified against, or nil for the default one; keeping the latter
+// nil lets proofs be compared to their decoded counterparts.
+func (t *Tree) proofScheme() *scheme {
+	// The proofs of padded trees list the empty siblings explicitly, so
+	// they are verified the same way as those of unpadded ones; the key of
+	// the leaves is not handed out along with them.
+	s := t.scheme
+	s.padded, s.depth, s.emptyLeaf, s.leafKey, s.salted = false, 0, nil, nil, false
+	if s.isDefault() {
+		return nil
+	}
+	return &s
+}
+
+// schemeOrDefault returns the given scheme, or the default one if it is nil.
+func schemeOrDefault(s *scheme) *scheme {
+	if s == nil {
+		return &scheme{}
+	}
+	return s
+}
+
+// newHasher returns a new instance of the hash function of the scheme, or of
+// the given one if the scheme has none of its own.
+func (s *scheme) newHasher(hash crypto.Hash) hash.Hash {
+	if s.newHash != nil {
+		return s.truncateHasher(s.newHash())
+	}
+	return s.truncateHasher(hash.New())
+}
+
+// truncateHasher wraps the given hash.Hash so that its digests are truncated
+// as per TruncateDigests, if need be.
+func (s *scheme) truncateHasher(h hash.Hash) hash.Hash {
+	if s.digestSize > 0 && s.digestSize < h.Size() {
+		return &truncatedHash{Hash: h, size: s.digestSize}
+	}
+	return h
+}
+
+// truncatedHash is a hash.Hash whose digests are truncated to the given size
+// (see TruncateDigests).
+type truncatedHash struct {
+	hash.Hash
+	size int
+	// full holds the last full digest, so that Sum does not allocate.
+	full []byte
+}
+
+func (h *truncatedHash) Size() int {
+	return h.size
+}
+
+func (h *truncatedHash) Sum(b []byte) []byte {
+	h.full = h.Hash.Sum(h.full[:0])
+	return append(b, h.full[:h.size]...)
+}
+
+// truncate truncates the given digest, calculated by the Hasher of the
+// scheme, as per TruncateDigests.
+func (s *scheme) truncate(digest []byte) []byte {
+	if s.digestSize > 0 && s.digestSize < len(digest) {
+		return digest[:s.digestSize:s.digestSize]
+	}
+	return digest
+}
+
+// available reports whether newHasher can construct the hash function of the
+// scheme or, if it has none of its own, the given one.
+func (s *scheme) available(hash crypto.Hash) bool {
+	return s.newHash != nil || hash.Available()
+}
+
+func (s *scheme) hashLeaf(h hash.Hash, serializedDatum []byte) []byte {
+	return s.hashLeafTo(h, nil, serializedDatum)
+}
+
+// hashLeafTo is like hashLeaf, but it appends the digest to dst, unless the
+// leaves are keyed or hashed by a Hasher, in which case it returns a slice of
+// its own instead (like hashLoneTo does for lone nodes that are promoted).
+func (s *scheme) hashLeafTo(h hash.Hash, dst, serializedDatum []byte) []byte {
+	if s.lengthPrefixed {
+		serializedDatum = appendFramed(nil, serializedDatum)
+	}
+	if s.hasher != nil {
+		return s.truncate(s.hasher.HashLeaf(serializedDatum))
+	}
+	if s.leafKey != nil {
+		return s.hmacLeaf(h, serializedDatum)
+	}
+	h.Reset()
+	h.Write(s.leafPrefix)
+	h.Write(serializedDatum)
+	return h.Sum(dst)
+}
+
+// leafMessage returns the message that hashLeaf feeds to the hash function for
+// the given serialized datum, provided that the leaves are neither keyed nor
+// hashed by a Hasher.
+func (s *scheme) leafMessage(serializedDatum []byte) []byte {
+	msg := make([]byte, 0, len(s.leafPrefix)+8+len(serializedDatum))
+	msg = append(msg, s.leafPrefix...)
+	if s.lengthPrefixed {
+		return appendFramed(msg, serializedDatum)
+	}
+	return append(msg, serializedDatum...)
+}
+
+// hashStreamedLeaf is like hashLeafTo, but the serialized datum is streamed
+// into the hash function by the given StreamSerializer. It reports false,
+// without hashing anything, if the scheme calls for the whole serialized datum
+// up front; i.e. if it is length-prefixed, keyed or given through WithHasher.
+func (s *scheme) hashStreamedLeaf(h hash.Hash, dst []byte, d StreamSerializer) ([]byte, bool, error) {
+	if s.lengthPrefixed || s.hasher != nil || s.leafKey != nil {
+		return nil, false, nil
+	}
+	h.Reset()
+	h.Write(s.leafPrefix)
+	if err := d.SerializeTo(h); err != nil {
+		return nil, true, err
+	}
+	return h.Sum(dst), true, nil
+}
+
+// hmacLeaf returns HMAC(leafKey, leafPrefix || serializedDatum), as per RFC
+// 2104, with the given hash function.
+func (s *scheme) hmacLeaf(h hash.Hash, serializedDatum []byte) []byte {
+	if th, ok := h.(*truncatedHash); ok {
+		// HMAC is calculated in full, and truncated afterwards.
+		return s.truncate(s.hmacLeaf(th.Hash, serializedDatum))
+	}
+	key := s.leafKey
+	if len(key) > h.BlockSize() {
+		h.Reset()
+		h.Write(key)
+		key = h.Sum(nil)
+	}
+	pad := make([]byte, h.BlockSize())
+	copy(pad, key)
+	for i := range pad {
+		pad[i] ^= 0x36
+	}
+	h.Reset()
+	h.Write(pad)
+	h.Write(s.leafPrefix)
+	h.Write(serializedDatum)
+	inner := h.Sum(nil)
+	for i := range pad {
+		pad[i] ^= 0x36 ^ 0x5c
+	}
+	h.Reset()
+	h.Write(pad)
+	h.Write(inner)
+	return h.Sum(nil)
+}
+
+// width returns the number of children of each merkle node.
+func (s *scheme) width() int {
+	if s.arity > 2 {
+		return s.arity
+	}
+	return 2
+}
+
+// hashChildren returns the digest of the parent of the given (at least one
+// and at most width()) consecutive nodes; i.e. H(c[0] || ... || c[n-1]), or
+// that of hashLone if there is only one, padded as per the PaddingPolicy if
+// they are fewer than width().
+func (s *scheme) hashChildren(h hash.Hash, children [][]byte) []byte {
+	return s.hashChildrenTo(h, nil, children)
+}
+
+// hashChildrenTo is like hashChildren, but appends the digest to dst, which
+// may overlap with the children (see hashNodeTo).
+func (s *scheme) hashChildrenTo(h hash.Hash, dst []byte, children [][]byte) []byte {
+	if len(children) == 1 {
+		return s.hashLoneTo(h, dst, children[0])
+	}
+	if s.sortedPairs {
+		children = append([][]byte(nil), children...)
+		sort.Slice(children, func(i, j int) bool {
+			return bytes.Compare(children[i], children[j]) < 0
+		})
+	}
+	if s.hasher != nil {
+		return append(dst, s.truncate(s.hasher.HashNode(s.padChildren(children, s.width()-len(children))))...)
+	}
+	h.Reset()
+	h.Write(s.nodePrefix)
+	for _, child := range children {
+		h.Write(child)
+	}
+	s.writePadding(h, children[len(children)-1], s.width()-len(children))
+	return h.Sum(dst)
+}
+
+// writePadding writes n padding nodes after the given last node to h, as per
+// the PaddingPolicy.
+func (s *scheme) writePadding(h hash.Hash, last []byte, n int) {
+	for ; n > 0; n-- {
+		switch s.padding {
+		case DuplicateLast:
+			h.Write(last)
+		case PairWithZero:
+			h.Write(make([]byte, len(last)))
+		}
+	}
+}
+
+func (s *scheme) hashNode(h hash.Hash, left, right []byte) []byte {
+	return s.hashNodeTo(h, nil, left, right)
+}
+
+// hashNodeTo is like hashNode, but appends the digest to dst. Since the nodes
+// are hashed before the digest is appended, dst may overlap with them; hence
+// a single buffer suffices for climbing up a merkle path without allocating.
+func (s *scheme) hashNodeTo(h hash.Hash, dst, left, right []byte) []byte {
+	if s.sortedPairs && bytes.Compare(left, right) > 0 {
+		left, right = right, left
+	}
+	if s.hasher != nil {
+		return append(dst, s.truncate(s.hasher.HashNode([][]byte{left, right}))...)
+	}
+	h.Reset()
+	h.Write(s.nodePrefix)
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(dst)
+}
+
+// hashLone returns the digest of the parent of the given node, which is the
+// last one in an odd-sized level.
+func (s *scheme) hashLone(h hash.Hash, node []byte) []byte {
+	return s.hashLoneTo(h, nil, node)
+}
+
+// hashLoneTo is like hashLone, but appends the digest to dst (see hashNodeTo);
+// if the node is promoted (see PromoteLone), it is returned as is instead.
+func (s *scheme) hashLoneTo(h hash.Hash, dst, node []byte) []byte {
+	if s.padding == PromoteLone {
+		return node
+	}
+	if s.hasher != nil {
+		return append(dst, s.truncate(s.hasher.HashNode(s.padChildren([][]byte{node}, s.width()-1)))...)
+	}
+	h.Reset()
+	h.Write(s.nodePrefix)
+	h.Write(node)
+	s.writePadding(h, node, s.width()-1)
+	return h.Sum(dst)
+}
+
+// isDefault reports whether the scheme hashes the default way.
+func (s *scheme) isDefault() bool {
+	return s.padding == HashLone && len(s.leafPrefix) == 0 && len(s.nodePrefix) == 0 && s.width() == 2 && !s.padded &&
+		!s.sortedPairs && s.newHash == nil && s.leafKey == nil && !s.salted && !s.lengthPrefixed && s.digestSize == 0
+}
+
+// encodable reports whether merkle trees of the scheme can be encoded; i.e.
+// whether their hash function is identified by a crypto.Hash, and their
+// leaves are neither keyed (the key is never encoded along with them) nor
+// salted (the salts are only handed out upon disclosure).
+func (s *scheme) encodable() bool {
+	return s.newHash == nil && s.leafKey == nil && !s.salted
+}
+
+// isRFC6962 reports whether the scheme prefixes the inputs of the hash
+// function the way RFC 6962 does.
+func (s *scheme) isRFC6962() bool {
+	return string(s.leafPrefix) == "\x00" && string(s.nodePrefix) == "\x01"
+}
+
+// impliedPadding returns the PaddingPolicy that the encodings of the merkle
+// tree imply, unless they record another one explicitly; i.e. the one of RFC
+// 6962 if the scheme prefixes the inputs of the hash function as per RFC
+// 6962, or the default one otherwise.
+func (s *scheme) impliedPadding() PaddingPolicy {
+	if s.isRFC6962() {
+		return PromoteLone
+	}
+	return HashLone
+}
+
+// equal reports whether the two schemes hash the same way. Hash functions of
+// their own (see WithHashFunc) are told apart by their names only.
+func (s *scheme) equal(o *scheme) bool {
+	return s.padding == o.padding && s.width() == o.width() && bytes.Equal(s.leafPrefix, o.leafPrefix) && bytes.Equal(s.nodePrefix, o.nodePrefix) &&
+		s.padded == o.padded && s.depth == o.depth && bytes.Equal(s.emptyLeaf, o.emptyLeaf) &&
+		s.sortedPairs == o.sortedPairs && s.hashName == o.hashName && (s.newHash == nil) == (o.newHash == nil) &&
+		(s.leafKey == nil) == (o.leafKey == nil) && bytes.Equal(s.leafKey, o.leafKey) && s.salted == o.salted &&
+		s.lengthPrefixed == o.lengthPrefixed && s.digestSize == o.digestSize
+}
+
+// emptyRoots returns the digests of the roots of the empty subtrees of a
+// padded merkle tree, from height 0 (i.e. the empty leaf) up to the given
+// one.
+func (s *scheme) emptyRoots(h hash.Hash, height int) [][]byte {
+	roots := make([][]byte, height+1)
+	roots[0] = s.emptyLeaf
+	if roots[0] == nil {
+		roots[0] = make([]byte, h.Size())
+	}
+	children := make([][]byte, s.width())
+	for k := 1; k <= height; k++ {
+		for i := range children {
+			children[i] = roots[k-1]
+		}
+		roots[k] = s.hashChildren(h, children)
+	}
+	return roots
+}
+
+// paddedDepth returns the number of levels of merkle nodes of a padded merkle
+// tree of the given number of leaves.
+func (s *scheme) paddedDepth(numLeaves int) int {
+	if s.depth > 0 {
+		return s.depth
+	}
+	depth := 0
+	for capacity := 1; capacity < numLeaves; capacity *= s.width() {
+		depth++
+	}
+	return depth
+}
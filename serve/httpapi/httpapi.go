@@ -0,0 +1,222 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package httpapi serves a merkle tree over HTTP, as an http.Handler that can
+// be mounted into any existing mux (e.g. under http.StripPrefix). It serves
+// the following endpoints, all of which respond with JSON:
+//
+//	GET /root                         the merkle root and the number of leaves
+//	GET /proof?leaf=I                 the inclusion proof of the leaf at index I
+//	GET /proof?leaf=I&size=N          the RFC 6962 inclusion proof of the leaf
+//	                                  at index I in the version of size N
+//	GET /consistency?old=M[&new=N]    the RFC 6962 consistency proof between
+//	                                  the versions of sizes M and N (which
+//	                                  defaults to the current size)
+//
+// Digests are encoded as hexadecimal strings. Errors are reported by the
+// appropriate status code and a JSON object with a single "error" field.
+package httpapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/ckatsak/merkle"
+)
+
+type (
+	rootResponse struct {
+		Hash      string `json:"hash"`
+		NumLeaves int    `json:"numLeaves"`
+		Root      string `json:"root"`
+	}
+
+	inclusionResponse struct {
+		LeafIndex int      `json:"leafIndex"`
+		TreeSize  int      `json:"treeSize"`
+		Proof     []string `json:"proof"`
+	}
+
+	consistencyResponse struct {
+		OldSize int      `json:"oldSize"`
+		NewSize int      `json:"newSize"`
+		Proof   []string `json:"proof"`
+	}
+
+	errorResponse struct {
+		Error string `json:"error"`
+	}
+)
+
+// Handler is an http.Handler that serves a merkle tree. It is safe for
+// concurrent use, as long as the tree is only modified through Update.
+type Handler struct {
+	mu   sync.RWMutex
+	tree *merkle.Tree
+}
+
+// New returns a Handler that serves the given merkle tree.
+func New(t *merkle.Tree) *Handler {
+	return &Handler{tree: t}
+}
+
+// Update calls the given function with the merkle tree of the Handler, while
+// no requests are being served, so that it can safely modify it (e.g. through
+// AppendAndReconstruct).
+func (h *Handler) Update(fn func(t *merkle.Tree)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	fn(h.tree)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	switch r.URL.Path {
+	case "/root":
+		h.serveRoot(w)
+	case "/proof":
+		h.serveProof(w, r)
+	case "/consistency":
+		h.serveConsistency(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func (h *Handler) serveRoot(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, &rootResponse{
+		Hash:      h.tree.Hash().String(),
+		NumLeaves: h.tree.NumLeaves(),
+		Root:      hex.EncodeToString(h.tree.MerkleRoot()),
+	})
+}
+
+func (h *Handler) serveProof(w http.ResponseWriter, r *http.Request) {
+	leaf, err := intParam(r, "leaf", -1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if r.URL.Query().Get("size") == "" {
+		proof, err := h.tree.Proof(leaf)
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, proof)
+		return
+	}
+
+	size, err := intParam(r, "size", -1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	proof, err := h.tree.InclusionProof(leaf, size)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &inclusionResponse{
+		LeafIndex: leaf,
+		TreeSize:  size,
+		Proof:     hexStrings(proof),
+	})
+}
+
+func (h *Handler) serveConsistency(w http.ResponseWriter, r *http.Request) {
+	oldSize, err := intParam(r, "old", -1)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	newSize, err := intParam(r, "new", h.tree.NumLeaves())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	proof, err := h.tree.ConsistencyProof(oldSize, newSize)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, &consistencyResponse{
+		OldSize: oldSize,
+		NewSize: newSize,
+		Proof:   hexStrings(proof),
+	})
+}
+
+// intParam returns the value of the given query parameter as a non-negative
+// integer, or the given default if it is absent; a negative default makes the
+// parameter required.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		if def < 0 {
+			return 0, errors.New("missing parameter " + strconv.Quote(name))
+		}
+		return def, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, errors.New("invalid parameter " + strconv.Quote(name))
+	}
+	return v, nil
+}
+
+// statusOf maps the errors of the merkle tree to HTTP status codes.
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, merkle.ErrNoData), errors.Is(err, merkle.ErrInvalidRange):
+		return http.StatusNotFound
+	case errors.Is(err, merkle.ErrUnsupported):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func hexStrings(digests [][]byte) []string {
+	ret := make([]string, len(digests))
+	for i := range digests {
+		ret[i] = hex.EncodeToString(digests[i])
+	}
+	return ret
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		status, b = http.StatusInternalServerError, []byte(`{"error":"encoding failed"}`)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(b, '\n'))
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
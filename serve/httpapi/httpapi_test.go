@@ -0,0 +1,116 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package httpapi
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/internal/storetest"
+	"github.com/ckatsak/merkle/log"
+)
+
+func get(t *testing.T, h http.Handler, target string, wantStatus int, v interface{}) {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	t.Logf("GET %s: %d %s", target, rec.Code, bytes.TrimSpace(rec.Body.Bytes()))
+	if rec.Code != wantStatus {
+		t.Fatalf("want (%d); got %d", wantStatus, rec.Code)
+	}
+	if v != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func decodeHex(t *testing.T, ss []string) [][]byte {
+	ret := make([][]byte, len(ss))
+	for i := range ss {
+		var err error
+		if ret[i], err = hex.DecodeString(ss[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ret
+}
+
+func TestHandler00(t *testing.T) {
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, storetest.Data(10), merkle.RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(tree)
+	mux := http.NewServeMux()
+	mux.Handle("/tree/", http.StripPrefix("/tree", h))
+
+	var root rootResponse
+	get(t, mux, "/tree/root", http.StatusOK, &root)
+	if root.Hash != "SHA-256" || root.NumLeaves != 10 || root.Root != hex.EncodeToString(tree.MerkleRoot()) {
+		t.Fatalf("want (SHA-256, 10, %x); got %+v", tree.MerkleRoot(), root)
+	}
+
+	var proof merkle.Proof
+	get(t, mux, "/tree/proof?leaf=3", http.StatusOK, &proof)
+	if !proof.Verify(tree.MerkleRoot()) {
+		t.Fatal("proof does not verify")
+	}
+
+	// Grow the tree, and prove the old version consistent with the new one.
+	oldRoot := tree.MerkleRoot()
+	h.Update(func(t *merkle.Tree) { t.AppendAndReconstruct(storetest.Data(25)[10:]...) })
+	var cons consistencyResponse
+	get(t, mux, "/tree/consistency?old=10", http.StatusOK, &cons)
+	if cons.OldSize != 10 || cons.NewSize != 25 {
+		t.Fatalf("want (10, 25); got (%d, %d)", cons.OldSize, cons.NewSize)
+	}
+	if !log.VerifyConsistency(crypto.SHA256, 10, 25, oldRoot, tree.MerkleRoot(), decodeHex(t, cons.Proof)) {
+		t.Fatal("consistency proof does not verify")
+	}
+
+	var incl inclusionResponse
+	get(t, mux, "/tree/proof?leaf=7&size=10", http.StatusOK, &incl)
+	leafHash := log.LeafHash(crypto.SHA256, []byte("datum-0007"))
+	if !log.VerifyInclusion(crypto.SHA256, 7, 10, leafHash, decodeHex(t, incl.Proof), oldRoot) {
+		t.Fatal("inclusion proof does not verify")
+	}
+}
+
+func TestHandler01(t *testing.T) {
+	tree, err := merkle.NewTree(crypto.SHA256, storetest.Data(10)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(tree)
+	var e errorResponse
+	get(t, h, "/proof", http.StatusBadRequest, &e)
+	get(t, h, "/proof?leaf=x", http.StatusBadRequest, &e)
+	get(t, h, "/proof?leaf=10", http.StatusNotFound, &e)
+	get(t, h, "/consistency?old=3", http.StatusNotImplemented, &e)
+	get(t, h, "/nope", http.StatusNotFound, &e)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/root", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("want (%d); got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
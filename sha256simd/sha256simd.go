@@ -0,0 +1,78 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package sha256simd hashes the leaves of merkle trees with SHA-256 in bulk,
+// as implemented by github.com/minio/sha256-simd.
+//
+// On x86-64 CPUs with AVX-512, the leaves are hashed by the multi-buffer
+// implementation of SHA-256, Lanes of them at once, one in each lane of the
+// vector registers; bulk construction of merkle trees (e.g. through
+// merkle.NewTreeWithOptions) speeds up accordingly, especially for small
+// leaves. Elsewhere, they are hashed one by one, with the SHA extensions of
+// the CPU (SHA-NI or ARMv8 SHA2) if it supports them. Either way, the digests
+// are the ones of crypto.SHA256, so the merkle trees remain interchangeable
+// with the ones that are constructed without this package.
+package sha256simd
+
+import (
+	"crypto"
+	"crypto/sha256"
+
+	simd "github.com/minio/sha256-simd"
+
+	"github.com/ckatsak/merkle"
+)
+
+const (
+	// Lanes is the number of leaves that are hashed at once.
+	Lanes = 16
+	// Size is the size of the digests of SHA-256.
+	Size = sha256.Size
+)
+
+// MultiHasher returns a merkle.MultiHasher of SHA-256, which is shared by
+// all of the merkle trees that hash through it.
+func MultiHasher() merkle.MultiHasher {
+	if m := multiBuffer(); m != nil {
+		return m
+	}
+	return scalar{}
+}
+
+// Option configures a merkle tree of SHA-256 to hash its leaves in bulk
+// through MultiHasher (see merkle.WithMultiHasher).
+func Option() merkle.Option {
+	return merkle.WithMultiHasher(MultiHasher())
+}
+
+// Accelerated reports whether MultiHasher hashes multiple leaves at once on
+// the CPU at hand, rather than one by one.
+func Accelerated() bool {
+	return multiBuffer() != nil
+}
+
+// scalar is the merkle.MultiHasher that hashes its messages one by one.
+type scalar struct{}
+
+func (scalar) Hash() crypto.Hash { return crypto.SHA256 }
+
+func (scalar) Lanes() int { return Lanes }
+
+func (scalar) SumAll(dst []byte, msgs [][]byte) []byte {
+	for _, msg := range msgs {
+		sum := simd.Sum256(msg)
+		dst = append(dst, sum[:]...)
+	}
+	return dst
+}
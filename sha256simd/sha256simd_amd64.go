@@ -0,0 +1,101 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package sha256simd
+
+import (
+	"crypto"
+	"sync"
+
+	"github.com/klauspost/cpuid/v2"
+	simd "github.com/minio/sha256-simd"
+)
+
+var (
+	avx512Once sync.Once
+	avx512     *multiBuffered
+)
+
+// multiBuffer returns the multi-buffer merkle.MultiHasher, or nil if the CPU
+// does not support AVX-512. Its server and workers are started the first time
+// that it is called for.
+func multiBuffer() *multiBuffered {
+	avx512Once.Do(func() {
+		if cpuid.CPU.Supports(cpuid.AVX512F, cpuid.AVX512DQ, cpuid.AVX512BW, cpuid.AVX512VL) {
+			avx512 = &multiBuffered{
+				server: simd.NewAvx512Server(),
+				jobs:   make(chan sumJob, Lanes),
+			}
+			for i := 0; i < Lanes; i++ {
+				go avx512.work()
+			}
+		}
+	})
+	return avx512
+}
+
+// multiBuffered is the merkle.MultiHasher that hands its messages to an
+// Avx512Server all at once, so that it hashes them in the 16 lanes of the
+// AVX-512 registers, rather than one after the other.
+//
+// The server fills its lanes as the digests are summed, and each sum blocks
+// until all of them are, hence the messages are summed concurrently, by a
+// fixed pool of as many workers as the lanes.
+type multiBuffered struct {
+	server *simd.Avx512Server
+	jobs   chan sumJob
+}
+
+// sumJob is a message to be summed by a worker of multiBuffered, along with
+// where its digest goes.
+type sumJob struct {
+	msg []byte
+	dst []byte
+	wg  *sync.WaitGroup
+}
+
+func (*multiBuffered) Hash() crypto.Hash { return crypto.SHA256 }
+
+func (*multiBuffered) Lanes() int { return Lanes }
+
+func (m *multiBuffered) SumAll(dst []byte, msgs [][]byte) []byte {
+	if len(msgs) == 0 {
+		return dst
+	}
+
+	start := len(dst)
+	dst = append(dst, make([]byte, Size*len(msgs))...)
+	var wg sync.WaitGroup
+	wg.Add(len(msgs))
+	for i, msg := range msgs {
+		m.jobs <- sumJob{msg: msg, dst: dst[start+Size*i : start+Size*(i+1)], wg: &wg}
+	}
+	wg.Wait()
+	return dst
+}
+
+// work sums the messages of the jobs that it receives, forever.
+func (m *multiBuffered) work() {
+	for job := range m.jobs {
+		copy(job.dst, m.sum(job.msg))
+		job.wg.Done()
+	}
+}
+
+// sum returns the digest of the given message.
+func (m *multiBuffered) sum(msg []byte) []byte {
+	h := simd.NewAvx512(m.server)
+	h.Write(msg)
+	return h.Sum(nil)
+}
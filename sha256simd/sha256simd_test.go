@@ -0,0 +1,75 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package sha256simd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+func TestMultiHasher00(t *testing.T) {
+	t.Logf("accelerated: %v", Accelerated())
+	m := MultiHasher()
+	if m.Hash() != crypto.SHA256 || m.Lanes() != Lanes {
+		t.Fatalf("want (%v, %d); got (%v, %d)", crypto.SHA256, Lanes, m.Hash(), m.Lanes())
+	}
+	// Messages of all sorts of lengths, across block boundaries and more
+	// than the lanes.
+	msgs := make([][]byte, 0, 40)
+	for _, n := range []int{0, 1, 55, 56, 63, 64, 65, 119, 120, 128, 1000} {
+		for j := 0; j < 3; j++ {
+			msgs = append(msgs, bytes.Repeat([]byte{byte(n + j)}, n+j))
+		}
+	}
+	prefix := []byte("prefix")
+	sums := m.SumAll(append([]byte(nil), prefix...), msgs)
+	if !bytes.HasPrefix(sums, prefix) || len(sums) != len(prefix)+Size*len(msgs) {
+		t.Fatalf("want (%d bytes after the prefix); got %d", Size*len(msgs), len(sums))
+	}
+	sums = sums[len(prefix):]
+	for i, msg := range msgs {
+		if want := sha256.Sum256(msg); !bytes.Equal(sums[Size*i:Size*(i+1)], want[:]) {
+			t.Fatalf("want (%x); got %x", want, sums[Size*i:Size*(i+1)])
+		}
+	}
+	if sums := m.SumAll(nil, nil); len(sums) != 0 {
+		t.Fatalf("want (no digests); got %x", sums)
+	}
+}
+
+func TestOption00(t *testing.T) {
+	data := make([]merkle.Datum, 100)
+	for i := range data {
+		data[i] = merkle.ByteDatum(fmt.Sprintf("leaf %d", i))
+	}
+	for _, opts := range [][]merkle.Option{nil, {merkle.RFC6962()}, {merkle.DigestOnly(), merkle.WithArity(4)}} {
+		want, err := merkle.NewTreeWithOptions(crypto.SHA256, data, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, append(opts, Option())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", want.MerkleRoot(), tree.MerkleRoot())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package sha3 configures merkle trees to hash with the functions of the
+// SHA-3 family, as implemented by golang.org/x/crypto/sha3.
+//
+// The standardized fixed-size functions (SHA3-224 to SHA3-512) are the
+// crypto.Hash values that importing this package makes available, so the
+// merkle trees that hash with them can be encoded as usual. The legacy
+// Keccak functions (which Ethereum hashes with) and the SHAKE extendable
+// output functions (of a configurable digest size) are not enumerated by
+// crypto.Hash, hence they are plugged in through merkle.WithHashFunc, and
+// the merkle trees that hash with them cannot be encoded.
+package sha3
+
+import (
+	"crypto"
+	"fmt"
+	"hash"
+
+	xsha3 "golang.org/x/crypto/sha3"
+
+	"github.com/ckatsak/merkle"
+)
+
+// SHA3_224 configures the merkle tree to hash with SHA3-224.
+func SHA3_224() merkle.Option {
+	return merkle.WithHash(crypto.SHA3_224)
+}
+
+// SHA3_256 configures the merkle tree to hash with SHA3-256.
+func SHA3_256() merkle.Option {
+	return merkle.WithHash(crypto.SHA3_256)
+}
+
+// SHA3_384 configures the merkle tree to hash with SHA3-384.
+func SHA3_384() merkle.Option {
+	return merkle.WithHash(crypto.SHA3_384)
+}
+
+// SHA3_512 configures the merkle tree to hash with SHA3-512.
+func SHA3_512() merkle.Option {
+	return merkle.WithHash(crypto.SHA3_512)
+}
+
+// Keccak256 configures the merkle tree to hash with the legacy Keccak-256,
+// as Ethereum does.
+func Keccak256() merkle.Option {
+	return merkle.WithHashFunc("Keccak-256", xsha3.NewLegacyKeccak256)
+}
+
+// Keccak512 configures the merkle tree to hash with the legacy Keccak-512.
+func Keccak512() merkle.Option {
+	return merkle.WithHashFunc("Keccak-512", xsha3.NewLegacyKeccak512)
+}
+
+// SHAKE128 configures the merkle tree to hash with SHAKE128, producing
+// digests of the given size in bytes; sizes below 1 are treated as 32 (i.e.
+// twice the security level of SHAKE128, in bits, over 8).
+func SHAKE128(size int) merkle.Option {
+	if size < 1 {
+		size = 32
+	}
+	return merkle.WithHashFunc(fmt.Sprintf("SHAKE128-%d", 8*size), func() hash.Hash {
+		return &shake{ShakeHash: xsha3.NewShake128(), size: size, blockSize: 168}
+	})
+}
+
+// SHAKE256 configures the merkle tree to hash with SHAKE256, producing
+// digests of the given size in bytes; sizes below 1 are treated as 64.
+func SHAKE256(size int) merkle.Option {
+	if size < 1 {
+		size = 64
+	}
+	return merkle.WithHashFunc(fmt.Sprintf("SHAKE256-%d", 8*size), func() hash.Hash {
+		return &shake{ShakeHash: xsha3.NewShake256(), size: size, blockSize: 136}
+	})
+}
+
+// shake adapts an extendable output function to the hash.Hash interface, by
+// reading digests of a fixed size out of it.
+type shake struct {
+	xsha3.ShakeHash
+	size      int
+	blockSize int
+}
+
+// Sum appends the first size bytes of the output of the function, over the
+// data written so far, to b; further data can still be written.
+func (s *shake) Sum(b []byte) []byte {
+	digest := make([]byte, s.size)
+	s.ShakeHash.Clone().Read(digest)
+	return append(b, digest...)
+}
+
+func (s *shake) Size() int {
+	return s.size
+}
+
+func (s *shake) BlockSize() int {
+	return s.blockSize
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package sha3
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"testing"
+
+	xsha3 "golang.org/x/crypto/sha3"
+
+	"github.com/ckatsak/merkle"
+)
+
+var data = []merkle.Datum{
+	merkle.StringDatum("alpha"),
+	merkle.StringDatum("beta"),
+	merkle.StringDatum("gamma"),
+	merkle.StringDatum("delta"),
+	merkle.StringDatum("epsilon"),
+}
+
+func TestShake00(t *testing.T) {
+	for _, tc := range []struct {
+		s    *shake
+		want string
+	}{
+		{&shake{ShakeHash: xsha3.NewShake128(), size: 32}, "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26"},
+		{&shake{ShakeHash: xsha3.NewShake256(), size: 64}, "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762fd75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be"},
+	} {
+		if got := hex.EncodeToString(tc.s.Sum(nil)); got != tc.want {
+			t.Fatalf("want (%s); got %s", tc.want, got)
+		}
+		// Summing must not consume the output of the function.
+		if got := hex.EncodeToString(tc.s.Sum(nil)); got != tc.want {
+			t.Fatalf("want (%s); got %s", tc.want, got)
+		}
+	}
+}
+
+func TestSHA3_25600(t *testing.T) {
+	tree, err := merkle.NewTree(crypto.SHA3_256, data...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, SHA3_256())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), otree.MerkleRoot()) {
+		t.Fatalf("want root (%x); got %x", tree.MerkleRoot(), otree.MerkleRoot())
+	}
+	if _, err := otree.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithHashFunc00(t *testing.T) {
+	for _, tc := range []struct {
+		opt  merkle.Option
+		size int
+	}{
+		{Keccak256(), 32},
+		{Keccak512(), 64},
+		{SHAKE128(0), 32},
+		{SHAKE128(20), 20},
+		{SHAKE256(0), 64},
+		{SHAKE256(48), 48},
+	} {
+		tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, tc.opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("tree.MerkleRoot(): %x", tree.MerkleRoot())
+		if len(tree.MerkleRoot()) != tc.size {
+			t.Fatalf("want root size (%d); got %d", tc.size, len(tree.MerkleRoot()))
+		}
+		for i := range data {
+			p, err := tree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(tree.MerkleRoot()) {
+				t.Fatalf("proof of leaf %d does not verify", i)
+			}
+		}
+		if _, err := tree.MarshalBinary(); err != merkle.ErrUnsupported {
+			t.Fatalf("want (%v); got %v", merkle.ErrUnsupported, err)
+		}
+	}
+}
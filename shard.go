@@ -0,0 +1,202 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+)
+
+// Shard describes one of the shards of a merkle tree that is built piecemeal
+// (e.g. by the workers of a MapReduce-style pipeline), by its merkle root and
+// its number of leaves.
+type Shard struct {
+	Root      []byte
+	NumLeaves int
+}
+
+// Shard returns the Shard that the merkle tree makes up, to be combined with
+// the rest of them through CombineShards.
+func (t *Tree) Shard() Shard {
+	return Shard{Root: t.MerkleRoot(), NumLeaves: len(t.tls)}
+}
+
+// ShardSet combines the merkle roots of the shards of a merkle tree into the
+// merkle root of the whole of it, and composes the proofs of the leaves of
+// each shard into proofs against the latter.
+//
+// Each shard is a merkle tree of its own, which is built (e.g. on a machine of
+// its own) out of a range of consecutive leaves of the whole, along with the
+// same hash function and Options. All of them but the last one must be of the
+// same number of leaves, which must be a power of the arity of the merkle
+// tree (e.g. 1<<20 leaves per shard, for binary trees), so that each of them
+// makes up a complete subtree of the whole; the last one may be of fewer.
+// Then, the merkle root of the ShardSet is the one of the merkle tree that
+// would be built out of all the leaves at once, in the order of the shards,
+// and so are the proofs it composes; e.g. if the leaves are partitioned
+// among the shards by range, so that each shard holds the leaves that would be
+// sorted after the ones of the shards before it, the merkle root is the one
+// of the sorted merkle tree of all of them.
+type ShardSet struct {
+	hash   crypto.Hash
+	t      *Tree
+	shards []Shard
+	// size is the number of leaves of all shards but the last one, and
+	// height the one of the merkle roots of the complete ones.
+	size   int
+	height int
+	// top is the merkle tree whose leaves are the merkle roots of the
+	// shards, as merkle nodes of the given height.
+	top *Tree
+}
+
+// CombineShards returns the ShardSet of the given shards of a merkle tree, in
+// order, given the hash function and the Options that all of them have been
+// built with.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if no shards are given, if they are not sized as
+// described by ShardSet, if any of their merkle roots is not of the size that
+// the hash function produces, or if the Options pad the merkle tree (see
+// PadToPowerOfTwo), which its shards cannot add up to.
+func CombineShards(hash crypto.Hash, shards []Shard, opts ...Option) (*ShardSet, error) {
+	t, err := newHashingTree(hash, opts)
+	if err != nil {
+		return nil, err
+	}
+	if t.scheme.padded {
+		return nil, ErrUnsupported
+	}
+	if len(shards) == 0 {
+		return nil, ErrNoData
+	}
+	h := t.newHasher()
+	s := &ShardSet{hash: t.hash, t: t, shards: make([]Shard, len(shards))}
+	s.size = shards[0].NumLeaves
+	capacity := 1
+	for ; capacity < s.size; capacity *= t.scheme.width() {
+		if capacity > MaxLeaves/t.scheme.width() {
+			return nil, ErrInvalidRange
+		}
+		s.height++
+	}
+	if len(shards) > 1 && capacity != s.size {
+		return nil, ErrInvalidRange
+	}
+	total := 0
+	for i, shard := range shards {
+		if len(shard.Root) != h.Size() {
+			return nil, ErrInvalidDigest
+		}
+		if shard.NumLeaves < 1 || shard.NumLeaves > s.size || (i < len(shards)-1 && shard.NumLeaves != s.size) ||
+			total > MaxLeaves-shard.NumLeaves {
+			return nil, ErrInvalidRange
+		}
+		total += shard.NumLeaves
+		s.shards[i] = Shard{Root: copyBytes(shard.Root), NumLeaves: shard.NumLeaves}
+	}
+
+	// The merkle roots of the shards become the leaves of the top of the
+	// merkle tree, as they are; the one of the last shard, if incomplete,
+	// is first hashed on its own up to the height of the rest.
+	s.top = &Tree{hash: t.hash, scheme: t.scheme, digestOnly: true, insertionOrder: true}
+	s.top.tls = make([]treeLeaf, len(s.shards))
+	for i, shard := range s.shards {
+		s.top.tls[i] = treeLeaf{digest: shard.Root, orderedID: uint64(i)}
+	}
+	if last := &s.top.tls[len(s.shards)-1]; len(s.shards) > 1 {
+		for height := s.shardHeight(len(s.shards) - 1); height < s.height; height++ {
+			last.digest = t.scheme.hashLone(h, last.digest)
+		}
+	}
+	s.top.nextID = uint64(len(s.shards))
+	if err := s.top.setNodes(s.top.constructMerkleNodes(h, s.top.tls)); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// shardHeight returns the height of the merkle root of the given shard.
+func (s *ShardSet) shardHeight(shard int) int {
+	_, rows := calculateMerkleNumbers(s.shards[shard].NumLeaves, s.t.scheme.width())
+	return len(rows)
+}
+
+// Root returns the merkle root of the whole merkle tree.
+func (s *ShardSet) Root() []byte {
+	return s.top.MerkleRoot()
+}
+
+// NumLeaves returns the number of leaves of the whole merkle tree.
+func (s *ShardSet) NumLeaves() int {
+	return (len(s.shards)-1)*s.size + s.shards[len(s.shards)-1].NumLeaves
+}
+
+// Offset returns the index among the leaves of the whole merkle tree of the
+// first leaf of the given shard.
+//
+// It returns a non-nil error if the given shard is out of range.
+func (s *ShardSet) Offset(shard int) (int, error) {
+	if shard < 0 || shard >= len(s.shards) {
+		return 0, &IndexError{Op: "Offset", Index: shard, Err: ErrNoData}
+	}
+	return shard * s.size, nil
+}
+
+// ComposeProof composes the given inclusion proof of a leaf in the given shard
+// (as returned by the Proof method of its merkle tree) with the path from the
+// merkle root of the shard to the one of the whole merkle tree; i.e. it
+// returns the inclusion proof of the leaf in the whole merkle tree, which
+// verifies against Root.
+//
+// It returns a non-nil error if the given shard is out of range, or if the
+// given Proof is not one of a leaf of it; i.e. if it is not of its hash
+// function, hashing scheme and number of leaves, or does not lead to its
+// merkle root.
+func (s *ShardSet) ComposeProof(shard int, p *Proof) (*Proof, error) {
+	offset, err := s.Offset(shard)
+	if err != nil {
+		return nil, err
+	}
+	if p.Hash != s.hash || p.NumLeaves != s.shards[shard].NumLeaves || p.LeafIndex < 0 || p.LeafIndex >= p.NumLeaves ||
+		!schemeOrDefault(p.scheme).equal(schemeOrDefault(s.top.proofScheme())) {
+		return nil, ErrInvalidRange
+	}
+	if root, err := p.Root(); err != nil || !bytes.Equal(root, s.shards[shard].Root) {
+		return nil, ErrInvalidDigest
+	}
+	top, err := s.top.Proof(shard)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Proof{
+		Hash:       s.hash,
+		LeafIndex:  offset + p.LeafIndex,
+		NumLeaves:  s.NumLeaves(),
+		LeafDigest: copyBytes(p.LeafDigest),
+		Siblings:   make([][]byte, 0, len(p.Siblings)+s.height+len(top.Siblings)),
+		scheme:     top.scheme,
+	}
+	for _, sibling := range p.Siblings {
+		ret.Siblings = append(ret.Siblings, copyBytes(sibling))
+	}
+	if len(s.shards) > 1 {
+		for height := s.shardHeight(shard); height < s.height; height++ {
+			ret.Siblings = append(ret.Siblings, []byte{})
+		}
+	}
+	ret.Siblings = append(ret.Siblings, top.Siblings...)
+	return ret, nil
+}
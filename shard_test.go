@@ -0,0 +1,138 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"reflect"
+	"testing"
+)
+
+func TestCombineShards00(t *testing.T) {
+	for i, c := range []struct {
+		size int
+		opts []Option
+	}{
+		{8, []Option{InsertionOrder()}},
+		{4, nil},
+		{1, nil},
+		{32, nil},
+		{16, []Option{RFC6962()}},
+		{9, []Option{WithArity(3)}},
+		{8, []Option{WithPaddingPolicy(DuplicateLast), SortedPairs()}},
+		{4, []Option{WithArity(4), WithPaddingPolicy(PairWithZero)}},
+	} {
+		want, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The leaves are partitioned by range, as sorted in the whole.
+		leaves := make([][]byte, want.NumLeaves())
+		for j := range leaves {
+			leaves[j] = want.key(&want.tls[j])
+		}
+		var shards []Shard
+		var trees []*Tree
+		for start := 0; start < len(leaves); start += c.size {
+			end := start + c.size
+			if end > len(leaves) {
+				end = len(leaves)
+			}
+			data := make([]Datum, 0, end-start)
+			for _, leaf := range leaves[start:end] {
+				data = append(data, ByteDatum(leaf))
+			}
+			tree, err := NewTreeWithOptions(crypto.SHA256, data, c.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			shards, trees = append(shards, tree.Shard()), append(trees, tree)
+		}
+		s, err := CombineShards(crypto.SHA256, shards, c.opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(s.Root(), want.MerkleRoot()) || s.NumLeaves() != len(leaves) {
+			t.Fatalf("%d: want (%x, %d); got (%x, %d)", i, want.MerkleRoot(), len(leaves), s.Root(), s.NumLeaves())
+		}
+		for shard, tree := range trees {
+			offset, _ := s.Offset(shard)
+			for j := 0; j < tree.NumLeaves(); j++ {
+				p, err := tree.Proof(j)
+				if err != nil {
+					t.Fatal(err)
+				}
+				composed, err := s.ComposeProof(shard, p)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantProof, _ := want.Proof(offset + j)
+				if !reflect.DeepEqual(composed, wantProof) || !composed.Verify(s.Root()) {
+					t.Fatalf("%d: shard %d, leaf %d: want (%v); got %v", i, shard, j, wantProof, composed)
+				}
+			}
+		}
+	}
+}
+
+func TestCombineShards01(t *testing.T) {
+	var shards []Shard
+	for _, data := range [][]Datum{grAlphabet[:4], grAlphabet[4:8], grAlphabet[8:10]} {
+		tree, err := NewTree(crypto.SHA256, data...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shards = append(shards, tree.Shard())
+	}
+	if _, err := CombineShards(crypto.SHA256, nil); err != ErrNoData {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := CombineShards(crypto.SHA256, shards, PadToPowerOfTwo(nil)); err != ErrUnsupported {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	for _, bad := range [][]Shard{
+		{shards[2], shards[0]},
+		{shards[0], shards[2], shards[1]},
+		{{Root: shards[2].Root, NumLeaves: 3}, shards[2]},
+		{shards[0], {Root: shards[2].Root, NumLeaves: 5}},
+	} {
+		if _, err := CombineShards(crypto.SHA256, bad); err != ErrInvalidRange {
+			t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+		}
+	}
+	if _, err := CombineShards(crypto.SHA256, shards, WithHash(crypto.SHA1)); err != ErrInvalidDigest {
+		t.Fatalf("want (%v); got %v", ErrInvalidDigest, err)
+	}
+
+	s, err := CombineShards(crypto.SHA256, shards)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, _ := NewTree(crypto.SHA256, grAlphabet[:4]...)
+	p, _ := tree.Proof(1)
+	if _, err := s.ComposeProof(3, p); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	if _, err := s.ComposeProof(1, p); err != ErrInvalidDigest {
+		t.Fatalf("want (%v); got %v", ErrInvalidDigest, err)
+	}
+	if _, err := s.ComposeProof(2, p); err != ErrInvalidRange {
+		t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+	}
+	if p, err = s.ComposeProof(0, p); err != nil || p.LeafIndex != 1 || p.NumLeaves != 10 || !p.Verify(s.Root()) {
+		t.Fatalf("want (1, 10, true); got (%d, %d, %v)", p.LeafIndex, p.NumLeaves, err)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package smt
+
+import (
+	"bytes"
+	"crypto"
+)
+
+// Proof is an inclusion or non-inclusion proof of a key in a sparse merkle
+// tree. To keep it compact, the siblings that are default digests of empty
+// subtrees are omitted, and marked as such in Bitmap instead.
+type Proof struct {
+	// Hash is the hash function that the sparse merkle tree was built with.
+	Hash crypto.Hash
+	// Key is the key that the Proof refers to.
+	Key []byte
+	// Value is the value associated with Key, or nil if Key is absent.
+	Value []byte
+	// Bitmap has a bit set for each height (starting from the leaves, in
+	// least significant bit order) whose sibling is included in Siblings.
+	Bitmap []byte
+	// Siblings are the non-default digests of the siblings of the nodes
+	// along the path from the key's leaf to the root.
+	Siblings [][]byte
+}
+
+// Prove returns an inclusion proof of the given key, if present, or else a
+// non-inclusion proof of it.
+//
+// It returns a non-nil error if the key is not of the size that the hash
+// function produces.
+func (t *Tree) Prove(key []byte) (*Proof, error) {
+	if len(key) != t.depth/8 {
+		return nil, ErrInvalidKey
+	}
+	p := &Proof{
+		Hash:   t.hash,
+		Key:    append([]byte(nil), key...),
+		Bitmap: make([]byte, (t.depth+7)/8),
+	}
+	if value, ok := t.values[string(key)]; ok {
+		p.Value = append([]byte{}, value...)
+	}
+	for height := 0; height < t.depth; height++ {
+		sibling := t.node(height, flipBit(key, t.depth-1-height))
+		if !bytes.Equal(sibling, t.defaults[height]) {
+			p.Bitmap[height/8] |= 1 << (height % 8)
+			p.Siblings = append(p.Siblings, append([]byte(nil), sibling...))
+		}
+	}
+	return p, nil
+}
+
+// Included reports whether the Proof is an inclusion proof (as opposed to a
+// non-inclusion one).
+func (p *Proof) Included() bool {
+	return p.Value != nil
+}
+
+// Verify verifies that the Proof leads to the given root; i.e. that Key is
+// associated with Value or, if Value is nil, that Key is absent.
+func (p *Proof) Verify(root []byte) bool {
+	if !p.Hash.Available() {
+		return false
+	}
+	h := p.Hash.New()
+	depth := 8 * h.Size()
+	if len(p.Key) != depth/8 || len(p.Bitmap) != (depth+7)/8 {
+		return false
+	}
+
+	defaultDigest := make([]byte, h.Size())
+	digest := defaultDigest
+	if p.Value != nil {
+		digest = leafDigest(h, p.Key, p.Value)
+	}
+	siblings := p.Siblings
+	for height := 0; height < depth; height++ {
+		sibling := defaultDigest
+		if p.Bitmap[height/8]>>(height%8)&1 == 1 {
+			if len(siblings) == 0 {
+				return false
+			}
+			sibling, siblings = siblings[0], siblings[1:]
+		}
+		left, right := digest, sibling
+		if bit(p.Key, depth-1-height) == 1 {
+			left, right = sibling, digest
+		}
+		h.Reset()
+		h.Write(nodePrefix)
+		h.Write(defaultDigest)
+		h.Write(defaultDigest)
+		nextDefault := h.Sum(nil)
+		if !bytes.Equal(left, defaultDigest) || !bytes.Equal(right, defaultDigest) {
+			h.Reset()
+			h.Write(nodePrefix)
+			h.Write(left)
+			h.Write(right)
+			digest = h.Sum(nil)
+		} else {
+			digest = nextDefault
+		}
+		defaultDigest = nextDefault
+	}
+	return len(siblings) == 0 && bytes.Equal(digest, root)
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package smt implements a fixed-depth, in-memory, "hash function-agnostic"
+// sparse merkle tree, i.e. an authenticated map whose keys are hash digests.
+//
+// The tree has a leaf for every possible key, thus its depth equals the size
+// of the digests in bits. Only the nodes that differ from the (cached)
+// default digests of empty subtrees are stored, which keeps both memory and
+// updates proportional to the number of keys present.
+//
+// A present leaf's digest is H(0x00 || key || value), an empty leaf's digest
+// is all zeros, and an internal node's digest is H(0x01 || left || right),
+// unless both children are empty subtrees, in which case it is the cached
+// default digest of an empty subtree of its height.
+package smt
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"hash"
+)
+
+var (
+	// ErrHashUnavailable signifies that the requested hash function has
+	// not been linked into the binary.
+	ErrHashUnavailable = errors.New("smt: hash algorithm unavailable")
+
+	// ErrInvalidKey signifies that the given key is not of the size that
+	// the hash function of the tree produces.
+	ErrInvalidKey = errors.New("smt: invalid key")
+)
+
+var (
+	leafPrefix = []byte{0x00}
+	nodePrefix = []byte{0x01}
+)
+
+// Tree is a sparse merkle tree.
+type Tree struct {
+	hash     crypto.Hash
+	h        hash.Hash
+	depth    int
+	defaults [][]byte
+	nodes    map[string][]byte
+	values   map[string][]byte
+}
+
+// New creates a new, empty sparse merkle tree given one of the available (i.e.
+// linked into the binary) hash functions.
+func New(hash crypto.Hash) (*Tree, error) {
+	if !hash.Available() {
+		return nil, ErrHashUnavailable
+	}
+	h := hash.New()
+	depth := 8 * h.Size()
+
+	// Cache the digests of the empty subtrees of every height.
+	defaults := make([][]byte, depth+1)
+	defaults[0] = make([]byte, h.Size())
+	for i := 1; i <= depth; i++ {
+		h.Reset()
+		h.Write(nodePrefix)
+		h.Write(defaults[i-1])
+		h.Write(defaults[i-1])
+		defaults[i] = h.Sum(nil)
+	}
+
+	return &Tree{
+		hash:     hash,
+		h:        h,
+		depth:    depth,
+		defaults: defaults,
+		nodes:    make(map[string][]byte),
+		values:   make(map[string][]byte),
+	}, nil
+}
+
+// Key hashes the given data into a key suitable for the sparse merkle tree.
+func (t *Tree) Key(data []byte) []byte {
+	t.h.Reset()
+	t.h.Write(data)
+	return t.h.Sum(nil)
+}
+
+// Depth returns the depth of the sparse merkle tree, i.e. the size of its
+// keys in bits.
+func (t *Tree) Depth() int {
+	return t.depth
+}
+
+// Len returns the number of keys present in the sparse merkle tree.
+func (t *Tree) Len() int {
+	return len(t.values)
+}
+
+// Root returns the hash digest of the root of the sparse merkle tree.
+func (t *Tree) Root() []byte {
+	return t.node(t.depth, nil)
+}
+
+// Get returns the value associated with the given key, if any.
+func (t *Tree) Get(key []byte) ([]byte, bool) {
+	value, ok := t.values[string(key)]
+	return value, ok
+}
+
+// Update associates the given value with the given key, updating the
+// O(depth) nodes along the path from the key's leaf to the root.
+//
+// It returns a non-nil error if the key is not of the size that the hash
+// function produces.
+func (t *Tree) Update(key, value []byte) error {
+	if len(key) != t.depth/8 {
+		return ErrInvalidKey
+	}
+	valueCopy := make([]byte, len(value))
+	copy(valueCopy, value)
+	t.values[string(key)] = valueCopy
+	t.updatePath(key, leafDigest(t.h, key, valueCopy))
+	return nil
+}
+
+// Delete removes the given key (if present) from the sparse merkle tree,
+// turning its leaf back into an empty one.
+//
+// It returns a non-nil error if the key is not of the size that the hash
+// function produces.
+func (t *Tree) Delete(key []byte) error {
+	if len(key) != t.depth/8 {
+		return ErrInvalidKey
+	}
+	if _, ok := t.values[string(key)]; !ok {
+		return nil
+	}
+	delete(t.values, string(key))
+	t.updatePath(key, t.defaults[0])
+	return nil
+}
+
+func (t *Tree) updatePath(key, digest []byte) {
+	t.setNode(0, key, digest)
+	for height := 0; height < t.depth; height++ {
+		sibling := t.node(height, flipBit(key, t.depth-1-height))
+		left, right := digest, sibling
+		if bit(key, t.depth-1-height) == 1 {
+			left, right = sibling, digest
+		}
+		digest = t.parent(height, left, right)
+		t.setNode(height+1, key, digest)
+	}
+}
+
+// parent returns the digest of the parent (at height+1) of the given children.
+func (t *Tree) parent(height int, left, right []byte) []byte {
+	if bytes.Equal(left, t.defaults[height]) && bytes.Equal(right, t.defaults[height]) {
+		return t.defaults[height+1]
+	}
+	t.h.Reset()
+	t.h.Write(nodePrefix)
+	t.h.Write(left)
+	t.h.Write(right)
+	return t.h.Sum(nil)
+}
+
+// node returns the digest of the node at the given height on the path of the
+// given key (whose bits below that height are ignored).
+func (t *Tree) node(height int, key []byte) []byte {
+	if digest, ok := t.nodes[t.nodeKey(height, key)]; ok {
+		return digest
+	}
+	return t.defaults[height]
+}
+
+func (t *Tree) setNode(height int, key, digest []byte) {
+	if bytes.Equal(digest, t.defaults[height]) {
+		delete(t.nodes, t.nodeKey(height, key))
+	} else {
+		t.nodes[t.nodeKey(height, key)] = digest
+	}
+}
+
+// nodeKey returns the key of the node at the given height on the path of the
+// given key in the nodes map; i.e. the height followed by the key's bits above
+// that height.
+func (t *Tree) nodeKey(height int, key []byte) string {
+	prefix := make([]byte, 2+t.depth/8)
+	prefix[0], prefix[1] = byte(height>>8), byte(height)
+	copy(prefix[2:], key)
+	for i := t.depth - height; i < t.depth; i++ {
+		prefix[2+i/8] &^= 0x80 >> (i % 8)
+	}
+	return string(prefix)
+}
+
+func leafDigest(h hash.Hash, key, value []byte) []byte {
+	h.Reset()
+	h.Write(leafPrefix)
+	h.Write(key)
+	h.Write(value)
+	return h.Sum(nil)
+}
+
+// bit returns the i-th bit of the given key, starting from the most
+// significant bit of its first byte.
+func bit(key []byte, i int) byte {
+	return key[i/8] >> (7 - i%8) & 1
+}
+
+func flipBit(key []byte, i int) []byte {
+	ret := make([]byte, len(key))
+	copy(ret, key)
+	ret[i/8] ^= 0x80 >> (i % 8)
+	return ret
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package smt
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestNew00(t *testing.T) {
+	if _, err := New(crypto.SHA512); err != ErrHashUnavailable {
+		t.Fatalf("want (%v); got %v", ErrHashUnavailable, err)
+	}
+	tree, err := New(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("empty tree.Root(): %x", tree.Root())
+	if !bytes.Equal(tree.Root(), tree.defaults[tree.Depth()]) {
+		t.Fatalf("empty tree's root is not the default one")
+	}
+	if err = tree.Update([]byte("short"), []byte("v")); err != ErrInvalidKey {
+		t.Fatalf("want (%v); got %v", ErrInvalidKey, err)
+	}
+}
+
+func TestUpdate00(t *testing.T) {
+	tree, err := New(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := tree.Root()
+
+	keys := make([][]byte, 50)
+	for i := range keys {
+		keys[i] = tree.Key([]byte(fmt.Sprintf("key-%d", i)))
+		if err = tree.Update(keys[i], []byte(fmt.Sprintf("value-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Logf("tree.Root(): %x (%d stored nodes)", tree.Root(), len(tree.nodes))
+
+	// The root must not depend on the order of the updates.
+	tree2, _ := New(crypto.SHA256)
+	for i := len(keys) - 1; i >= 0; i-- {
+		tree2.Update(keys[i], []byte(fmt.Sprintf("value-%d", i)))
+	}
+	if !bytes.Equal(tree.Root(), tree2.Root()) {
+		t.Fatalf("want root %x; got %x", tree.Root(), tree2.Root())
+	}
+
+	if v, ok := tree.Get(keys[7]); !ok || string(v) != "value-7" {
+		t.Fatalf("want value-7; got (%q, %v)", v, ok)
+	}
+	for i := range keys {
+		if err = tree.Delete(keys[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(tree.Root(), empty) || len(tree.nodes) != 0 || tree.Len() != 0 {
+		t.Fatalf("tree is not empty after deleting every key")
+	}
+}
+
+func TestProof00(t *testing.T) {
+	tree, err := New(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		tree.Update(tree.Key([]byte{byte(i)}), []byte{byte(i), byte(i)})
+	}
+	root := tree.Root()
+
+	p, err := tree.Prove(tree.Key([]byte{3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("inclusion proof: %d non-default siblings", len(p.Siblings))
+	if !p.Included() || !p.Verify(root) {
+		t.Fatalf("inclusion proof failed")
+	}
+	p.Value = []byte{4, 4}
+	if p.Verify(root) {
+		t.Fatalf("inclusion proof with forged value verified")
+	}
+
+	p, err = tree.Prove(tree.Key([]byte{42}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Included() || !p.Verify(root) {
+		t.Fatalf("non-inclusion proof failed")
+	}
+	p.Value = []byte{42}
+	if p.Verify(root) {
+		t.Fatalf("non-inclusion proof turned into inclusion one verified")
+	}
+}
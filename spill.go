@@ -0,0 +1,469 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"hash"
+	"io"
+	"os"
+	"unsafe"
+)
+
+// SpillToDisk configures a Builder to keep the leaves added to it in memory
+// only up to the given budget (in bytes); once they exceed it, the Builder
+// sorts them, spills them as a run to a temporary file in the given directory
+// (or in the default directory for temporary files, if empty), and starts
+// over. The runs are merged back in order upon building. Hence, BuildFlat can
+// write out merkle trees far larger than the memory available, constructing
+// their merkle nodes level by level through temporary files as well, to be
+// opened in place rather than in memory (see OpenFlat and the store/mmap
+// package).
+//
+// Note that the keys of the data added while duplicates are ignored or
+// rejected (see WithDuplicates) are still kept in memory. It has no effect
+// but on Builders, and none for a non-positive budget.
+func SpillToDisk(dir string, budget int) Option {
+	return func(t *Tree) {
+		t.spillDir, t.spillBudget = dir, budget
+	}
+}
+
+// leafFootprint returns the approximate number of bytes of memory that the
+// given leaf takes.
+func leafFootprint(tl *treeLeaf) int {
+	return int(unsafe.Sizeof(*tl)) + len(tl.digest) + len(tl.datum) + len(tl.salt)
+}
+
+// spill sorts the leaves that the Builder holds in memory and spills them as a
+// run to a temporary file, if they exceed the memory budget of the merkle tree
+// to be built (see SpillToDisk).
+func (b *Builder) spill() error {
+	if b.t.spillBudget <= 0 || b.mem <= b.t.spillBudget {
+		return nil
+	}
+	b.t.sortTreeLeaves(b.tls)
+	f, err := os.CreateTemp(b.t.spillDir, "merkle-run-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	var buf []byte
+	for i := range b.tls {
+		buf = appendRunLeaf(buf[:0], &b.tls[i])
+		if _, err = w.Write(buf); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		removeTemp(f)
+		return err
+	}
+	b.runs = append(b.runs, f)
+	b.spilled += len(b.tls)
+	for i := range b.tls {
+		b.tls[i] = treeLeaf{}
+	}
+	b.tls, b.mem = b.tls[:0], 0
+	b.arena = newDigestArena(b.h.Size(), 0)
+	return nil
+}
+
+// BuildFlat writes the flat encoding (see WriteFlat) of the merkle tree that
+// Build would construct to the given io.Writer, and resets the Builder so that
+// it can be reused. Rather than constructing the merkle tree in memory, it
+// merges the leaves that have been spilled to disk (see SpillToDisk), if any,
+// and hashes its merkle nodes level by level, keeping each level in a
+// temporary file until the one above it has been hashed. Hence, it takes
+// little memory beyond the budget of the Builder, regardless of the size of
+// the merkle tree.
+//
+// It returns a non-nil error in the same cases as Build, in the ones that
+// WriteFlat does, or if the temporary files fail to be written or read.
+func (b *Builder) BuildFlat(w io.Writer) error {
+	numLeaves := b.Len()
+	if numLeaves == 0 {
+		return ErrNoData
+	}
+	if err := b.t.checkPadding(numLeaves); err != nil {
+		return err
+	}
+	if b.t.scheme.arity > 0xffff || !b.t.scheme.encodable() {
+		return ErrUnsupported
+	}
+	defer b.reset()
+	t := *b.t
+	size := b.h.Size()
+	_, rows := t.merkleNumbers(numLeaves)
+	var empty [][]byte
+	if t.scheme.padded {
+		empty = t.scheme.emptyRoots(b.h, len(rows))
+	}
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(t.appendFlatHeader(nil, numLeaves, rows, size)); err != nil {
+		return err
+	}
+	t.beginProgress(0, numLeaves)
+	defer t.endProgress()
+
+	// The leaves are merged straight into the flat encoding, while the
+	// merkle nodes of each level are hashed into a temporary file that the
+	// next level is then read from.
+	m, err := b.merger()
+	if err != nil {
+		return err
+	}
+	next := func() ([]byte, error) {
+		tl, err := m.next()
+		return tl.digest, err
+	}
+	var levels []*os.File
+	defer func() {
+		for _, f := range levels {
+			removeTemp(f)
+		}
+	}()
+	width := numLeaves
+	for height := 0; height <= len(rows); height++ {
+		var lh *levelHasher
+		var lw *bufio.Writer
+		if height < len(rows) {
+			f, err := os.CreateTemp(t.spillDir, "merkle-level-*")
+			if err != nil {
+				return err
+			}
+			levels = append(levels, f)
+			lw = bufio.NewWriter(f)
+			var e []byte
+			if empty != nil {
+				e = empty[height]
+			}
+			lh = newLevelHasher(&t.scheme, b.h, e, size, lw)
+		}
+		for i := 0; i < width; i++ {
+			digest, err := next()
+			if err != nil {
+				return err
+			}
+			if _, err := bw.Write(digest); err != nil {
+				return err
+			}
+			if lh != nil {
+				if err := lh.add(digest); err != nil {
+					return err
+				}
+			}
+		}
+		if lh == nil {
+			break
+		}
+		if err := lh.flush(); err != nil {
+			return err
+		}
+		if err := lw.Flush(); err != nil {
+			return err
+		}
+		f := levels[len(levels)-1]
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r, digest := bufio.NewReader(f), make([]byte, size)
+		next = func() ([]byte, error) {
+			_, err := io.ReadFull(r, digest)
+			return digest, err
+		}
+		width = rows[height]
+		t.advanceProgress(width)
+	}
+	return bw.Flush()
+}
+
+// mergeRuns returns the leaves that the Builder has spilled to disk, along
+// with the ones it holds in memory, in order.
+func (b *Builder) mergeRuns() ([]treeLeaf, error) {
+	m, err := b.merger()
+	if err != nil {
+		return nil, err
+	}
+	tls := make([]treeLeaf, b.Len())
+	for i := range tls {
+		if tls[i], err = m.next(); err != nil {
+			return nil, err
+		}
+	}
+	return tls, nil
+}
+
+// reset discards the leaves added to the Builder, including the ones spilled
+// to disk.
+func (b *Builder) reset() {
+	for _, f := range b.runs {
+		removeTemp(f)
+	}
+	b.runs, b.spilled, b.tls, b.mem = nil, 0, nil, 0
+	b.dups = b.t.newDuplicateSet(nil)
+}
+
+// merger returns a leafMerger of the leaves that the Builder has spilled to
+// disk and of the ones it holds in memory, which are sorted in place.
+func (b *Builder) merger() (*leafMerger, error) {
+	b.t.sortTreeLeaves(b.tls)
+	sources := make([]leafSource, 0, len(b.runs)+1)
+	for _, f := range b.runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sources = append(sources, &runReader{
+			t:     b.t,
+			r:     bufio.NewReader(f),
+			arena: newDigestArena(b.h.Size(), 0),
+		})
+	}
+	sources = append(sources, &sliceSource{tls: b.tls})
+	return newLeafMerger(b.t, sources)
+}
+
+// removeTemp closes and removes the given temporary file.
+func removeTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// appendRunLeaf appends the given leaf, as spilled to disk, to b; i.e. its
+// ordered ID, followed by its digest, its (stored) datum and its salt, each
+// prefixed by its length.
+func appendRunLeaf(b []byte, tl *treeLeaf) []byte {
+	b = binary.AppendUvarint(b, tl.orderedID)
+	for _, field := range [][]byte{tl.digest, tl.datum, tl.salt} {
+		b = binary.AppendUvarint(b, uint64(len(field)))
+		b = append(b, field...)
+	}
+	return b
+}
+
+// leafSource provides sorted leaves to a leafMerger, one at a time; it
+// returns io.EOF once it runs out of them.
+type leafSource interface {
+	next() (treeLeaf, error)
+}
+
+// sliceSource is the leafSource of the leaves held in memory.
+type sliceSource struct {
+	tls []treeLeaf
+}
+
+func (s *sliceSource) next() (treeLeaf, error) {
+	if len(s.tls) == 0 {
+		return treeLeaf{}, io.EOF
+	}
+	tl := s.tls[0]
+	s.tls = s.tls[1:]
+	return tl, nil
+}
+
+// runReader is the leafSource of the leaves spilled to disk as a run.
+type runReader struct {
+	t     *Tree
+	r     *bufio.Reader
+	arena *digestArena
+}
+
+func (r *runReader) next() (treeLeaf, error) {
+	orderedID, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return treeLeaf{}, err
+	}
+	tl := treeLeaf{orderedID: orderedID}
+	if tl.digest, err = r.field(r.arena.next()); err != nil {
+		return treeLeaf{}, err
+	}
+	if tl.datum, err = r.field(nil); err != nil {
+		return treeLeaf{}, err
+	}
+	if tl.salt, err = r.field(nil); err != nil {
+		return treeLeaf{}, err
+	}
+	if r.t.digestOnly {
+		tl.datum = nil
+	} else if tl.datum != nil {
+		tl.datum = r.t.internDatum(tl.datum)
+	} else {
+		tl.datum = []byte{}
+	}
+	if len(tl.salt) == 0 {
+		tl.salt = nil
+	}
+	return tl, nil
+}
+
+// field reads a length-prefixed field of a leaf, appending it to dst.
+func (r *runReader) field(dst []byte) ([]byte, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n > uint64(cap(dst)) {
+		dst = make([]byte, n)
+	}
+	dst = dst[:n]
+	if _, err := io.ReadFull(r.r, dst); err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return dst, nil
+}
+
+// leafMerger merges sorted sources of leaves in the order of the merkle tree.
+// Leaves whose keys are equivalent come in the order of their sources, so
+// that sources of leaves kept in insertion order are merely concatenated.
+type leafMerger struct {
+	t       *Tree
+	sources []leafSource
+	// heads holds the next leaf of each source, and order the indices of
+	// the sources that have not run out, as a heap.
+	heads []treeLeaf
+	order []int
+}
+
+// newLeafMerger returns a leafMerger of the given sources.
+func newLeafMerger(t *Tree, sources []leafSource) (*leafMerger, error) {
+	m := &leafMerger{t: t, sources: sources, heads: make([]treeLeaf, len(sources))}
+	for i, s := range sources {
+		tl, err := s.next()
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		m.heads[i] = tl
+		m.order = append(m.order, i)
+	}
+	heap.Init(m)
+	return m, nil
+}
+
+// next returns the next leaf in order; it returns io.ErrUnexpectedEOF if the
+// sources have run out.
+func (m *leafMerger) next() (treeLeaf, error) {
+	if len(m.order) == 0 {
+		return treeLeaf{}, io.ErrUnexpectedEOF
+	}
+	i := m.order[0]
+	tl := m.heads[i]
+	next, err := m.sources[i].next()
+	switch {
+	case err == io.EOF:
+		heap.Pop(m)
+	case err != nil:
+		return treeLeaf{}, err
+	default:
+		m.heads[i] = next
+		heap.Fix(m, 0)
+	}
+	return tl, nil
+}
+
+func (m *leafMerger) Len() int {
+	return len(m.order)
+}
+
+func (m *leafMerger) Less(i, j int) bool {
+	a, b := m.order[i], m.order[j]
+	if !m.t.insertionOrder {
+		ka, kb := m.t.key(&m.heads[a]), m.t.key(&m.heads[b])
+		if m.t.lessKeys(ka, kb) {
+			return true
+		} else if m.t.lessKeys(kb, ka) {
+			return false
+		}
+	}
+	return a < b
+}
+
+func (m *leafMerger) Swap(i, j int) {
+	m.order[i], m.order[j] = m.order[j], m.order[i]
+}
+
+func (m *leafMerger) Push(x interface{}) {
+	m.order = append(m.order, x.(int))
+}
+
+func (m *leafMerger) Pop() interface{} {
+	i := m.order[len(m.order)-1]
+	m.order = m.order[:len(m.order)-1]
+	return i
+}
+
+// levelHasher hashes a level of merkle nodes, streamed from left to right, into
+// the level above it, which it writes out, the way reconstructMerkleNodes
+// does in memory.
+type levelHasher struct {
+	s *scheme
+	h hash.Hash
+	// empty is the digest of the empty subtrees at the height of the
+	// level, if the merkle tree is padded (see PadToPowerOfTwo).
+	empty    []byte
+	size     int
+	buf      []byte
+	children [][]byte
+	sum      []byte
+	out      io.Writer
+}
+
+func newLevelHasher(s *scheme, h hash.Hash, empty []byte, size int, out io.Writer) *levelHasher {
+	return &levelHasher{
+		s:        s,
+		h:        h,
+		empty:    empty,
+		size:     size,
+		buf:      make([]byte, s.width()*size),
+		children: make([][]byte, 0, s.width()),
+		sum:      make([]byte, 0, size),
+		out:      out,
+	}
+}
+
+// add adds the given digest to the level, hashing its parent once all of its
+// siblings have been added too.
+func (l *levelHasher) add(digest []byte) error {
+	i := len(l.children)
+	child := l.buf[i*l.size : (i+1)*l.size]
+	copy(child, digest)
+	if l.children = append(l.children, child); len(l.children) == l.s.width() {
+		return l.flush()
+	}
+	return nil
+}
+
+// flush hashes the parent of the digests added since the last one, if any.
+func (l *levelHasher) flush() error {
+	if len(l.children) == 0 {
+		return nil
+	}
+	for l.empty != nil && len(l.children) < l.s.width() {
+		l.children = append(l.children, l.empty)
+	}
+	digest := l.s.hashChildrenTo(l.h, l.sum[:0], l.children)
+	l.children = l.children[:0]
+	_, err := l.out.Write(digest)
+	return err
+}
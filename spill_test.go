@@ -0,0 +1,132 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSpillToDisk00(t *testing.T) {
+	for i, opts := range [][]Option{
+		nil,
+		{InsertionOrder()},
+		{DigestOnly()},
+		{WithLess(func(a, b []byte) bool { return len(a) < len(b) })},
+		{WithArity(3), WithPaddingPolicy(DuplicateLast)},
+		{RFC6962()},
+		{PadToPowerOfTwo(nil)},
+		{DeduplicateData()},
+	} {
+		want, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := t.TempDir()
+		b, err := NewBuilder(crypto.SHA256, append(opts, SpillToDisk(dir, 400))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, datum := range grAlphabet {
+			if err := b.Add(datum); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if len(b.runs) < 2 || b.Len() != len(grAlphabet) {
+			t.Fatalf("%d: want (>1, %d); got (%d, %d)", i, len(grAlphabet), len(b.runs), b.Len())
+		}
+		t.Logf("%d: %d runs spilled", i, len(b.runs))
+		tree, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+			t.Fatalf("%d: want (%x); got %x", i, want.MerkleRoot(), tree.MerkleRoot())
+		}
+		if got := tree.Leaves(); !reflect.DeepEqual(got, want.Leaves()) {
+			t.Fatalf("%d: want (%q); got %q", i, want.Leaves(), got)
+		}
+		if ok, err := tree.VerifyDatum(grAlphabet[7]); !ok || err != nil {
+			t.Fatalf("%d: want (true, <nil>); got (%t, %v)", i, ok, err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 || b.Len() != 0 {
+			t.Fatalf("%d: want (0, 0); got (%d, %d)", i, len(entries), b.Len())
+		}
+	}
+}
+
+func TestBuildFlat00(t *testing.T) {
+	for i, opts := range [][]Option{
+		nil,
+		{InsertionOrder()},
+		{WithArity(5)},
+		{RFC6962()},
+		{PadToPowerOfTwo(nil)},
+		{FixedDepth(6, nil), SortedPairs()},
+		{TruncateDigests(20)},
+	} {
+		want, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wantFlat bytes.Buffer
+		if err := want.WriteFlat(&wantFlat); err != nil {
+			t.Fatal(err)
+		}
+		for _, budget := range []int{0, 300} {
+			dir := t.TempDir()
+			b, err := NewBuilder(crypto.SHA256, append(opts, SpillToDisk(dir, budget))...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, datum := range grAlphabet {
+				if err := b.Add(datum); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var flat bytes.Buffer
+			if err := b.BuildFlat(&flat); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(flat.Bytes(), wantFlat.Bytes()) {
+				t.Fatalf("%d/%d: want (%x); got %x", i, budget, wantFlat.Bytes(), flat.Bytes())
+			}
+			tree, err := OpenFlat(flat.Bytes())
+			if err != nil || !bytes.Equal(tree.MerkleRoot(), want.MerkleRoot()) {
+				t.Fatalf("%d/%d: want (%x, <nil>); got (%x, %v)", i, budget, want.MerkleRoot(), tree.MerkleRoot(), err)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 0 || b.Len() != 0 {
+				t.Fatalf("%d/%d: want (0, 0); got (%d, %d)", i, budget, len(entries), b.Len())
+			}
+		}
+	}
+
+	b, err := NewBuilder(crypto.SHA256, WithHashFunc("sha256", sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flat bytes.Buffer
+	if err := b.BuildFlat(&flat); err != ErrNoData {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+	b.AddBytes([]byte("a"))
+	if err := b.BuildFlat(&flat); err != ErrUnsupported {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
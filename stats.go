@@ -0,0 +1,87 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "unsafe"
+
+// Stats summarizes the size of a merkle tree and its memory footprint (see
+// Tree.Stats); e.g. for capacity planning.
+type Stats struct {
+	// NumLeaves is the number of leaves of the merkle tree, and Levels
+	// holds the number of its nodes at each level, from the leaves (at
+	// index 0) to the root.
+	NumLeaves int
+	Levels    []int
+
+	// DigestBytes is the total size of the digests of all of the nodes of
+	// the merkle tree, including its leaves, wherever they are kept.
+	DigestBytes int64
+	// PayloadBytes is the total size of the serialized data that the
+	// leaves retain, as kept in memory; i.e. compressed, if they are (see
+	// WithCompressor), and counting each distinct one once, if they are
+	// deduplicated (see DeduplicateData).
+	PayloadBytes int64
+	// SaltBytes is the total size of the salts of the leaves, if they are
+	// salted (see WithSalts).
+	SaltBytes int64
+
+	// HeapBytes is an estimate of the heap memory that the merkle tree
+	// occupies; i.e. the above, as far as they are kept in memory, along
+	// with the bookkeeping of the leaves and the merkle nodes. It does
+	// not account for the rounding of allocations, for whatever a
+	// NodeStore given through WithNodeStore keeps, or for the earlier
+	// versions kept through WithHistory, and it counts the memory that
+	// the merkle tree shares with others (e.g. its snapshots) in full.
+	HeapBytes int64
+}
+
+// Stats returns the size statistics of the merkle tree, which are calculated
+// in O(L) time.
+func (t *Tree) Stats() Stats {
+	s := Stats{
+		NumLeaves: len(t.tls),
+		Levels:    make([]int, 0, len(t.rows)+1),
+	}
+	s.Levels = append(s.Levels, len(t.tls))
+	s.Levels = append(s.Levels, t.rows...)
+	size := int64(len(t.root()))
+	s.DigestBytes = size * int64(t.Size())
+
+	var seen map[*byte]bool
+	if t.dedup {
+		seen = make(map[*byte]bool)
+	}
+	for i := range t.tls {
+		if datum := t.tls[i].datum; len(datum) != 0 {
+			if seen != nil {
+				if seen[&datum[0]] {
+					continue
+				}
+				seen[&datum[0]] = true
+			}
+			s.PayloadBytes += int64(len(datum))
+		}
+		s.SaltBytes += int64(len(t.tls[i].salt))
+	}
+
+	s.HeapBytes = int64(unsafe.Sizeof(*t)) + int64(len(t.tls))*(int64(unsafe.Sizeof(treeLeaf{}))+size)
+	s.HeapBytes += s.PayloadBytes + s.SaltBytes + int64(len(t.rows))*int64(unsafe.Sizeof(0))
+	if n, ok := t.store.(*heapNodes); ok {
+		// A single sequence of digests, and an offset per level.
+		s.HeapBytes += int64(unsafe.Sizeof(*n)) + int64(len(n.digests))
+		s.HeapBytes += int64(len(n.offsets)) * int64(unsafe.Sizeof(0))
+	}
+	return s
+}
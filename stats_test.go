@@ -0,0 +1,64 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"reflect"
+	"testing"
+)
+
+func TestStats00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := tree.Stats()
+	t.Logf("%+v", s)
+	if want := []int{24, 12, 6, 3, 2, 1}; s.NumLeaves != 24 || !reflect.DeepEqual(s.Levels, want) {
+		t.Fatalf("want (24, %v); got (%d, %v)", want, s.NumLeaves, s.Levels)
+	}
+	if want := int64(32 * tree.Size()); s.DigestBytes != want {
+		t.Fatalf("want (%d); got %d", want, s.DigestBytes)
+	}
+	var payload int64
+	for _, d := range grAlphabet {
+		payload += int64(len(d.Serialize()))
+	}
+	if s.PayloadBytes != payload || s.SaltBytes != 0 {
+		t.Fatalf("want (%d, 0); got (%d, %d)", payload, s.PayloadBytes, s.SaltBytes)
+	}
+	if s.HeapBytes <= s.DigestBytes+s.PayloadBytes {
+		t.Fatalf("want more than (%d); got %d", s.DigestBytes+s.PayloadBytes, s.HeapBytes)
+	}
+
+	// Deduplicated data are counted once, and digest-only trees retain
+	// none.
+	data := append(append([]Datum{}, grAlphabet...), grAlphabet...)
+	dedup, err := NewTreeWithOptions(crypto.SHA256, data, DeduplicateData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := dedup.Stats(); s.NumLeaves != 48 || s.PayloadBytes != payload {
+		t.Fatalf("want (48, %d); got (%d, %d)", payload, s.NumLeaves, s.PayloadBytes)
+	}
+	digestOnly, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, DigestOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := digestOnly.Stats(); s.PayloadBytes != 0 || s.HeapBytes >= tree.Stats().HeapBytes {
+		t.Fatalf("want (0, <%d); got (%d, %d)", tree.Stats().HeapBytes, s.PayloadBytes, s.HeapBytes)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "crypto"
+
+// NodeStore stores the merkle nodes of a Tree (i.e. all of its nodes except
+// for its leaves), each of which is addressed by its level and its index
+// among the nodes of that level; level 1 holds the parents of the leaves, and
+// the highest level holds the merkle root.
+//
+// By default, each Tree keeps its merkle nodes in memory; the WithNodeStore
+// Option makes it read and write them through a NodeStore instead, so that
+// they can live on disk or on a remote service.
+type NodeStore interface {
+	// Get returns the digest of the node at the given level and index.
+	// The returned slice must not be modified.
+	Get(level, index int) ([]byte, error)
+	// Put stores the digest of the node at the given level and index,
+	// replacing any previous one. It must not retain the given slice.
+	Put(level, index int, digest []byte) error
+	// Delete removes the node at the given level and index, if any.
+	Delete(level, index int) error
+}
+
+// Flusher is implemented by NodeStores that buffer their writes. A merkle
+// tree calls Flush once it has written all of its merkle nodes.
+type Flusher interface {
+	Flush() error
+}
+
+// memStore is the NodeStore that NewMemNodeStore returns; i.e. mns[0][0] is
+// the merkle root, and mns[len(mns)-1] holds the parents of the leaves. Unlike
+// heapNodes, it grows and shrinks as its nodes are put and deleted.
+type memStore struct {
+	mns [][][]byte
+}
+
+// NewMemNodeStore returns a NodeStore that keeps the merkle nodes in memory,
+// like a Tree does by default (albeit not as compactly).
+func NewMemNodeStore() NodeStore {
+	return &memStore{}
+}
+
+func (s *memStore) Get(level, index int) ([]byte, error) {
+	if level < 1 || level > len(s.mns) || index < 0 || index >= len(s.mns[len(s.mns)-level]) {
+		return nil, &IndexError{Op: "Get", Index: index, Err: ErrNoData}
+	}
+	return s.mns[len(s.mns)-level][index], nil
+}
+
+func (s *memStore) Put(level, index int, digest []byte) error {
+	if level < 1 || index < 0 {
+		return &IndexError{Op: "Put", Index: index, Err: ErrInvalidRange}
+	}
+	// Grow the levels (at the top, where the root is) and the level itself
+	// as needed.
+	for len(s.mns) < level {
+		s.mns = append([][][]byte{nil}, s.mns...)
+	}
+	row := len(s.mns) - level
+	for len(s.mns[row]) <= index {
+		s.mns[row] = append(s.mns[row], nil)
+	}
+	s.mns[row][index] = copyBytes(digest)
+	return nil
+}
+
+func (s *memStore) Delete(level, index int) error {
+	if level < 1 || level > len(s.mns) || index < 0 || index >= len(s.mns[len(s.mns)-level]) {
+		return nil
+	}
+	row := len(s.mns) - level
+	s.mns[row][index] = nil
+	// Shrink the level and the levels, as far as they end in deleted nodes.
+	for len(s.mns[row]) > 0 && s.mns[row][len(s.mns[row])-1] == nil {
+		s.mns[row] = s.mns[row][:len(s.mns[row])-1]
+	}
+	for len(s.mns) > 0 && len(s.mns[0]) == 0 {
+		s.mns = s.mns[1:]
+	}
+	return nil
+}
+
+// WithNodeStore configures the merkle tree to read and write its merkle nodes
+// through the given NodeStore, rather than keeping them in memory. The leaves
+// of the tree are kept in memory regardless.
+//
+// The merkle nodes are all written upon construction; after that, each
+// mutation only writes the ones that it changes (e.g. the ones along the path
+// to the appended leaves, which are O(log(N)) in number), and only reads the
+// unchanged ones that they are calculated out of.
+//
+// Errors of the NodeStore are returned by the constructors and by the methods
+// that can return an error; the rest of the methods treat a node that cannot
+// be read as nonexistent, and the errors of AppendAndReconstruct and
+// DeleteAndReconstruct are reported by StoreErr.
+func WithNodeStore(s NodeStore) Option {
+	return func(t *Tree) {
+		t.store = s
+		t.userStore = true
+	}
+}
+
+// OpenTree opens a digest-only merkle tree whose merkle nodes have already
+// been written to the NodeStore given through WithNodeStore (e.g. by a tree
+// that was built with it before a restart), given the digests of its leaves,
+// in the order of the leaves of that tree. The leaves of the opened tree are
+// kept in that order, as if InsertionOrder had been given, and are assigned
+// the ordered IDs 0, 1, ... in it; see OpenTreeWithIDs for restoring the ones
+// of the original tree.
+//
+// No hash calculations take place; the merkle nodes are only loaded from the
+// NodeStore as they are needed (e.g. along the path of a leaf that is being
+// verified), hence any inconsistency between the given leaf digests and the
+// stored merkle nodes only surfaces upon verification.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if no digests are given, if any of them is of the
+// wrong size, if no NodeStore has been given, or if the fixed depth of the
+// merkle tree (see FixedDepth) does not allow for the digests.
+func OpenTree(hash crypto.Hash, digests [][]byte, opts ...Option) (*Tree, error) {
+	ids := make([]uint64, len(digests))
+	for i := range ids {
+		ids[i] = uint64(i)
+	}
+	return OpenTreeWithIDs(hash, digests, ids, uint64(len(digests)), opts...)
+}
+
+// OpenTreeWithIDs is like OpenTree, but the leaves are assigned the given
+// ordered IDs, and the merkle tree the given NextID; i.e. the ones of the
+// original tree (see IDs and NextID), which may have diverged from the
+// positions of its leaves upon deletions.
+//
+// Besides the errors of OpenTree, it returns a non-nil error (wrapping
+// ErrInvalidRange) if the number of the ordered IDs is not that of the
+// digests, if any of them exceeds MaxOrderedID or is given more than once, or
+// if the NextID does not exceed all of them.
+func OpenTreeWithIDs(hash crypto.Hash, digests [][]byte, ids []uint64, nextID uint64, opts ...Option) (*Tree, error) {
+	t := &Tree{hash: hash}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.digestOnly, t.insertionOrder = true, true
+	if !t.hashAvailable() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	if len(digests) == 0 {
+		return nil, ErrNoData
+	}
+	if !t.userStore {
+		return nil, ErrUnsupported
+	}
+	if err := t.checkPadding(len(digests)); err != nil {
+		return nil, err
+	}
+	if len(ids) != len(digests) {
+		return nil, ErrInvalidRange
+	}
+	size := t.newHasher().Size()
+	digestsSeq := make([]byte, 0, size*len(digests))
+	t.tls = make([]treeLeaf, len(digests))
+	seen := make(map[uint64]bool, len(ids))
+	for i := range digests {
+		if len(digests[i]) != size {
+			return nil, ErrInvalidDigest
+		}
+		if ids[i] > MaxOrderedID || ids[i] >= nextID || seen[ids[i]] {
+			return nil, &IDError{Op: "OpenTreeWithIDs", ID: ids[i], Err: ErrInvalidRange}
+		}
+		seen[ids[i]] = true
+		digestsSeq = append(digestsSeq, digests[i]...)
+		t.tls[i] = treeLeaf{
+			digest:    digestsSeq[i*size : (i+1)*size],
+			orderedID: ids[i],
+		}
+	}
+	t.nextID = nextID
+	_, rowSizes := t.merkleNumbers(len(digests))
+	t.rows = rowSizes
+	return t, nil
+}
+
+// StoreErr returns the first error that the NodeStore of the merkle tree
+// returned while (re)constructing it, if any.
+func (t *Tree) StoreErr() error {
+	return t.storeErr
+}
+
+// setNodes makes the given merkle nodes (as returned by constructMerkleNodes)
+// the merkle nodes of the tree, writing them to its NodeStore if it has been
+// given one, or keeping them in memory otherwise. The leaves of the tree must
+// have been set already, as the data that none of them retains anymore may be
+// dropped from its payloadPool (see DeduplicateData); the mutation underway,
+// if any, is reported once the merkle nodes have been set (see endMutation).
+func (t *Tree) setNodes(n *heapNodes) (err error) {
+	defer func() { t.endMutation(err == nil) }()
+	t.prunePayloads()
+	t.updateSecondaryRoot()
+	t.invalidateProofs(n.unchanged)
+	oldRows := t.rows
+	t.rows = n.rows
+	if !t.userStore {
+		old, _ := t.store.(*heapNodes)
+		t.store = n
+		t.retireNodes(old, n)
+		return nil
+	}
+
+	// Only the recalculated merkle nodes are written; the rest are in
+	// place already (see reconstructMerkleNodes).
+	for height := 1; height <= n.height(); height++ {
+		for index := n.firstHeld(height); index < n.rows[height-1]; index++ {
+			if err := t.store.Put(height, index, n.at(height, index)); err != nil {
+				return t.recordStoreErr(err)
+			}
+		}
+	}
+	// Delete any nodes left over from a larger tree.
+	for level := 1; level <= len(oldRows); level++ {
+		width := 0
+		if level <= len(t.rows) {
+			width = t.rows[level-1]
+		}
+		for index := width; index < oldRows[level-1]; index++ {
+			if err := t.store.Delete(level, index); err != nil {
+				return t.recordStoreErr(err)
+			}
+		}
+	}
+	if f, ok := t.store.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			return t.recordStoreErr(err)
+		}
+	}
+	return nil
+}
+
+func (t *Tree) recordStoreErr(err error) error {
+	if t.storeErr == nil {
+		t.storeErr = err
+	}
+	return err
+}
+
+// node returns the digest of the node at the given height and index of the
+// merkle tree, where height 0 holds the leaves, or the error of the NodeStore
+// if it cannot be read.
+func (t *Tree) node(height, index int) ([]byte, error) {
+	if height == 0 {
+		return t.tls[index].digest, nil
+	}
+	return t.store.Get(height, index)
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package badger implements a merkle.NodeStore on top of BadgerDB, as an
+// alternative to the bolt package for write-heavy workloads, such as the ones
+// of log servers that keep appending to large merkle trees.
+//
+// Badger is an LSM tree, so a Store writes the merkle nodes through a single
+// batch that is committed in as many transactions as needed, rather than
+// updating a B+tree in place; the writes become visible once the batch is
+// flushed, which a merkle tree does as soon as it has written all of its
+// merkle nodes. The keys are short and sequential within each level, and the
+// digests are small enough to be kept in the LSM tree itself rather than in
+// the value log, so that appending to a tree only touches the end of the key
+// space and compactions do not have to rewrite the value log.
+//
+// Like the bolt package, a Store can also save the digests and the ordered
+// IDs of the leaves of a tree, so that the tree can be reopened after a
+// restart. The merkle nodes are all written when the tree is constructed;
+// after that, each append only writes the O(log2(L)) merkle nodes that it
+// changes.
+package badger
+
+import (
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/ckatsak/merkle"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// ErrNotFound signifies that the requested merkle node is not in the
+	// Store.
+	ErrNotFound = errors.New("badger: node not found")
+	// ErrNoLeaves signifies that no leaves have been saved in the Store.
+	ErrNoLeaves = errors.New("badger: no leaves saved")
+)
+
+// Key prefixes; the merkle nodes' keys are followed by the level (a single
+// byte, as merkle trees are at most 64 levels high) and the big-endian index,
+// and the leaves' and their ordered IDs' keys by the big-endian index only.
+// The NextID of the saved tree is kept under the bare prefixID.
+const (
+	prefixNode byte = 'n'
+	prefixLeaf byte = 'l'
+	prefixID   byte = 'i'
+)
+
+// Store is a merkle.NodeStore that keeps the merkle nodes in a Badger
+// database. It is safe for concurrent use.
+type Store struct {
+	db    *badger.DB
+	ownDB bool
+
+	mu sync.Mutex
+	wb *badger.WriteBatch
+}
+
+// Open opens (creating it if needed) the Badger database with the given
+// options, and returns a Store on top of it.
+func Open(opts badger.Options) (*Store, error) {
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := New(db)
+	s.ownDB = true
+	return s, nil
+}
+
+// New returns a Store on top of the given (already open) Badger database,
+// which it then shares with the rest of the application.
+func New(db *badger.DB) *Store {
+	return &Store{db: db, wb: db.NewWriteBatch()}
+}
+
+func nodeKey(level, index int) []byte {
+	return binary.BigEndian.AppendUint64([]byte{prefixNode, byte(level)}, uint64(index))
+}
+
+func leafKey(index int) []byte {
+	return binary.BigEndian.AppendUint64([]byte{prefixLeaf}, uint64(index))
+}
+
+func idKey(index int) []byte {
+	return binary.BigEndian.AppendUint64([]byte{prefixID}, uint64(index))
+}
+
+// Get implements merkle.NodeStore. Writes are only visible to it once they
+// have been flushed.
+func (s *Store) Get(level, index int) (digest []byte, err error) {
+	err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(nodeKey(level, index))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return ErrNotFound
+		} else if err != nil {
+			return err
+		}
+		digest, err = item.ValueCopy(nil)
+		return err
+	})
+	return
+}
+
+// Put implements merkle.NodeStore. The write is batched until the next Flush.
+func (s *Store) Put(level, index int, digest []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wb.Set(nodeKey(level, index), append([]byte{}, digest...))
+}
+
+// Delete implements merkle.NodeStore. Like Put, it is batched.
+func (s *Store) Delete(level, index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wb.Delete(nodeKey(level, index))
+}
+
+// Flush commits all batched writes, waiting for them to be persisted. It
+// implements merkle.Flusher, so a merkle tree calls it once it has written
+// all of its merkle nodes.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.wb.Flush()
+	// A WriteBatch cannot be reused once flushed.
+	s.wb = s.db.NewWriteBatch()
+	return err
+}
+
+// SaveLeaves saves the digests and the ordered IDs of the leaves of the given
+// merkle tree (which must have been built with this Store), along with its
+// NextID, replacing any previously saved ones, so that the tree can be
+// reopened through OpenTree.
+func (s *Store) SaveLeaves(t *merkle.Tree) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	if err := s.db.DropPrefix([]byte{prefixLeaf}, []byte{prefixID}); err != nil {
+		return err
+	}
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	it := t.LeafIter()
+	for i := 0; it.Next(); i++ {
+		if err := wb.Set(leafKey(i), it.Digest()); err != nil {
+			return err
+		}
+		if err := wb.Set(idKey(i), binary.BigEndian.AppendUint64(nil, it.OrderedID())); err != nil {
+			return err
+		}
+	}
+	if err := wb.Set([]byte{prefixID}, binary.BigEndian.AppendUint64(nil, t.NextID())); err != nil {
+		return err
+	}
+	return wb.Flush()
+}
+
+// OpenTree reopens the (digest-only) merkle tree whose leaves have been saved
+// through SaveLeaves, reading its merkle nodes lazily from the Store; see
+// merkle.OpenTreeWithIDs for the details. The given Options should be the
+// ones that the tree was originally built with (e.g. merkle.RFC6962).
+//
+// It returns ErrNoLeaves if no leaves have been saved in the Store.
+func (s *Store) OpenTree(hash crypto.Hash, opts ...merkle.Option) (*merkle.Tree, error) {
+	var (
+		digests, ids [][]byte
+		nextID       []byte
+	)
+	err := s.db.View(func(txn *badger.Txn) (err error) {
+		if digests, err = scanPrefix(txn, prefixLeaf); err != nil {
+			return err
+		}
+		if ids, err = scanPrefix(txn, prefixID); err != nil || len(ids) == 0 {
+			return err
+		}
+		// The NextID sorts first, under the bare prefix.
+		nextID, ids = ids[0], ids[1:]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(digests) == 0 {
+		return nil, ErrNoLeaves
+	}
+	opts = append(opts, merkle.WithNodeStore(s))
+	// Leaves saved without their ordered IDs (i.e. by earlier versions of
+	// the package) are assigned the ones of their positions.
+	if len(nextID) != 8 {
+		return merkle.OpenTree(hash, digests, opts...)
+	}
+	oids := make([]uint64, len(ids))
+	for i := range ids {
+		if len(ids[i]) != 8 {
+			return nil, merkle.ErrInvalidEncoding
+		}
+		oids[i] = binary.BigEndian.Uint64(ids[i])
+	}
+	return merkle.OpenTreeWithIDs(hash, digests, oids, binary.BigEndian.Uint64(nextID), opts...)
+}
+
+// scanPrefix returns copies of the values of all keys with the given prefix,
+// in the order of their keys.
+func scanPrefix(txn *badger.Txn, prefix byte) ([][]byte, error) {
+	var values [][]byte
+	it := txn.NewIterator(badger.IteratorOptions{
+		PrefetchValues: true,
+		PrefetchSize:   1024,
+		Prefix:         []byte{prefix},
+	})
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		value, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// Close commits all batched writes and closes the Store, along with the
+// Badger database if it was opened by Open.
+func (s *Store) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.wb.Cancel()
+	s.mu.Unlock()
+	if !s.ownDB {
+		return nil
+	}
+	return s.db.Close()
+}
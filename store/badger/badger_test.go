@@ -0,0 +1,124 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package badger
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/internal/storetest"
+	badger "github.com/dgraph-io/badger/v4"
+)
+
+func TestStore00(t *testing.T) {
+	opts := badger.DefaultOptions(t.TempDir()).WithLogger(nil)
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storetest.RoundTrip(t, s, func() storetest.Store {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if s, err = Open(opts); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+	defer s.Close()
+
+	// The merkle nodes of the larger trees must have been deleted.
+	if _, err := s.Get(1, len(want.Level(1))); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+}
+
+func TestStore01(t *testing.T) {
+	s, err := Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.OpenTree(crypto.SHA256); !errors.Is(err, ErrNoLeaves) {
+		t.Fatalf("want (%v); got %v", ErrNoLeaves, err)
+	}
+	if _, err := s.Get(1, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+}
+
+func TestStore02(t *testing.T) {
+	opts := badger.DefaultOptions(t.TempDir()).WithLogger(nil)
+	s, err := Open(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	storetest.StableIDs(t, s, func() storetest.Store {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if s, err = Open(opts); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+	s.Close()
+}
+
+// countingStore counts the merkle nodes written to a Store.
+type countingStore struct {
+	*Store
+	puts int
+}
+
+func (s *countingStore) Put(level, index int, digest []byte) error {
+	s.puts++
+	return s.Store.Put(level, index, digest)
+}
+
+// BenchmarkAppend appends leaves one by one to trees of increasing sizes; the
+// merkle nodes written per append (puts/op) grow by one as the size of the
+// tree doubles.
+func BenchmarkAppend(b *testing.B) {
+	for _, size := range []int{1 << 10, 1 << 14, 1 << 17} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			s, err := Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer s.Close()
+			cs := &countingStore{Store: s}
+			data := storetest.Data(size + b.N)
+			tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data[:size], merkle.InsertionOrder(), merkle.WithNodeStore(cs))
+			if err != nil {
+				b.Fatal(err)
+			}
+			cs.puts = 0
+			b.ResetTimer()
+			for i := range b.N {
+				tree.AppendAndReconstruct(data[size+i])
+			}
+			b.StopTimer()
+			if err := tree.StoreErr(); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportMetric(float64(cs.puts)/float64(b.N), "puts/op")
+		})
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package bolt implements a merkle.NodeStore on top of bbolt, so that the
+// merkle nodes of a tree are kept on disk rather than in memory, and survive
+// restarts. Note that the merkle nodes are all calculated in memory when the
+// tree is constructed, before they are written; after that, each mutation of
+// the tree only writes the merkle nodes that it changes.
+//
+// A Store buffers the merkle nodes written to it and commits them in batches,
+// since committing a bbolt transaction per node would be prohibitively slow.
+// It reads them lazily, one at a time, as the merkle tree needs them; e.g.
+// verifying a leaf only loads the O(log2(L)) merkle nodes along its path.
+//
+// The leaves of a merkle tree are always kept in memory, but a Store can also
+// save their digests and ordered IDs, so that the tree can be reopened after
+// a restart with no hash calculations at all:
+//
+//	s, _ := bolt.Open("tree.db", nil)
+//	t, _ := merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.WithNodeStore(s))
+//	_ = s.SaveLeaves(t)
+//	_ = s.Close()
+//	// ...restart...
+//	s, _ = bolt.Open("tree.db", nil)
+//	t, _ = s.OpenTree(crypto.SHA256)
+package bolt
+
+import (
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/ckatsak/merkle"
+	bbolt "go.etcd.io/bbolt"
+)
+
+var (
+	// ErrNotFound signifies that the requested merkle node is not in the
+	// Store.
+	ErrNotFound = errors.New("bolt: node not found")
+	// ErrNoLeaves signifies that no leaves have been saved in the Store.
+	ErrNoLeaves = errors.New("bolt: no leaves saved")
+)
+
+var (
+	bucketNodes  = []byte("nodes")
+	bucketLeaves = []byte("leaves")
+	// bucketIDs holds the ordered IDs of the saved leaves, keyed like the
+	// latter, along with the NextID of their tree under keyNextID.
+	bucketIDs = []byte("ids")
+	keyNextID = []byte("next")
+)
+
+// DefaultBatchSize is the number of buffered writes after which a Store
+// commits them, unless configured otherwise.
+const DefaultBatchSize = 1 << 16
+
+// Options configure a Store.
+type Options struct {
+	// BatchSize is the number of buffered writes after which the Store
+	// commits them; if not positive, DefaultBatchSize is used.
+	BatchSize int
+	// Bolt are the options that the bbolt database is opened with.
+	Bolt *bbolt.Options
+}
+
+// Store is a merkle.NodeStore that keeps the merkle nodes in a bbolt
+// database. It is safe for concurrent use.
+type Store struct {
+	db        *bbolt.DB
+	ownDB     bool
+	batchSize int
+
+	mu sync.Mutex
+	// pending holds the buffered writes; a nil digest is a deletion.
+	pending map[[nodeKeySize]byte][]byte
+}
+
+// Open opens (creating it if needed) the bbolt database at the given path,
+// and returns a Store on top of it. A nil Options is equivalent to the zero
+// Options.
+func Open(path string, opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	db, err := bbolt.Open(path, 0o600, opts.Bolt)
+	if err != nil {
+		return nil, err
+	}
+	s, err := New(db, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	s.ownDB = true
+	return s, nil
+}
+
+// New returns a Store on top of the given (already open) bbolt database,
+// which it then shares with the rest of the application. A nil Options is
+// equivalent to the zero Options; its Bolt field is ignored.
+func New(db *bbolt.DB, opts *Options) (*Store, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	err := db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{bucketNodes, bucketLeaves, bucketIDs} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &Store{
+		db:        db,
+		batchSize: opts.BatchSize,
+		pending:   make(map[[nodeKeySize]byte][]byte),
+	}
+	if s.batchSize <= 0 {
+		s.batchSize = DefaultBatchSize
+	}
+	return s, nil
+}
+
+// nodeKeySize is the size of the keys of the merkle nodes; i.e. the big-endian
+// level followed by the big-endian index, so that the nodes of each level are
+// laid out next to each other, from left to right.
+const nodeKeySize = 4 + 8
+
+func nodeKey(level, index int) (key [nodeKeySize]byte) {
+	binary.BigEndian.PutUint32(key[:4], uint32(level))
+	binary.BigEndian.PutUint64(key[4:], uint64(index))
+	return
+}
+
+// Get implements merkle.NodeStore.
+func (s *Store) Get(level, index int) ([]byte, error) {
+	key := nodeKey(level, index)
+	s.mu.Lock()
+	digest, ok := s.pending[key]
+	s.mu.Unlock()
+	if ok {
+		if digest == nil {
+			return nil, ErrNotFound
+		}
+		return digest, nil
+	}
+
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket(bucketNodes).Get(key[:])
+		if v == nil {
+			return ErrNotFound
+		}
+		// v is only valid for the lifetime of the transaction.
+		digest = append([]byte(nil), v...)
+		return nil
+	})
+	return digest, err
+}
+
+// Put implements merkle.NodeStore. The write is buffered until the next
+// Flush, which takes place automatically once enough writes are buffered.
+func (s *Store) Put(level, index int, digest []byte) error {
+	return s.buffer(nodeKey(level, index), append([]byte{}, digest...))
+}
+
+// Delete implements merkle.NodeStore. Like Put, it is buffered.
+func (s *Store) Delete(level, index int) error {
+	return s.buffer(nodeKey(level, index), nil)
+}
+
+func (s *Store) buffer(key [nodeKeySize]byte, digest []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[key] = digest
+	if len(s.pending) < s.batchSize {
+		return nil
+	}
+	return s.flush()
+}
+
+// Flush commits all buffered writes in a single transaction. It implements
+// merkle.Flusher, so a merkle tree calls it once it has written all of its
+// merkle nodes.
+func (s *Store) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *Store) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketNodes)
+		for key, digest := range s.pending {
+			var err error
+			if digest == nil {
+				err = b.Delete(key[:])
+			} else {
+				err = b.Put(key[:], digest)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.pending = make(map[[nodeKeySize]byte][]byte)
+	return nil
+}
+
+// SaveLeaves saves the digests and the ordered IDs of the leaves of the given
+// merkle tree (which must have been built with this Store), along with its
+// NextID, replacing any previously saved ones, so that the tree can be
+// reopened through OpenTree.
+func (s *Store) SaveLeaves(t *merkle.Tree) error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		for _, bucket := range [][]byte{bucketLeaves, bucketIDs} {
+			if err := tx.DeleteBucket(bucket); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(bucketIDs).Put(keyNextID, binary.BigEndian.AppendUint64(nil, t.NextID()))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Commit the digests in batches, to bound the size of each transaction.
+	it := t.LeafIter()
+	for start := 0; start < t.NumLeaves(); start += s.batchSize {
+		err := s.db.Update(func(tx *bbolt.Tx) error {
+			leaves, ids := tx.Bucket(bucketLeaves), tx.Bucket(bucketIDs)
+			leaves.FillPercent = 1 // the keys are sequential
+			ids.FillPercent = 1
+			for i := start; i < start+s.batchSize && it.Next(); i++ {
+				key := binary.BigEndian.AppendUint64(nil, uint64(i))
+				if err := leaves.Put(key, it.Digest()); err != nil {
+					return err
+				}
+				if err := ids.Put(key, binary.BigEndian.AppendUint64(nil, it.OrderedID())); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenTree reopens the (digest-only) merkle tree whose leaves have been saved
+// through SaveLeaves, reading its merkle nodes lazily from the Store; see
+// merkle.OpenTreeWithIDs for the details. The given Options should be the
+// ones that the tree was originally built with (e.g. merkle.RFC6962).
+//
+// It returns ErrNoLeaves if no leaves have been saved in the Store.
+func (s *Store) OpenTree(hash crypto.Hash, opts ...merkle.Option) (*merkle.Tree, error) {
+	var (
+		digests [][]byte
+		ids     []uint64
+		nextID  []byte
+	)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(bucketLeaves)
+		digests = make([][]byte, 0, b.Stats().KeyN)
+		err := b.ForEach(func(_, v []byte) error {
+			digests = append(digests, append([]byte(nil), v...))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		b = tx.Bucket(bucketIDs)
+		nextID = append([]byte(nil), b.Get(keyNextID)...)
+		return b.ForEach(func(k, v []byte) error {
+			if len(k) == len(keyNextID) || len(v) != 8 {
+				return nil
+			}
+			ids = append(ids, binary.BigEndian.Uint64(v))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(digests) == 0 {
+		return nil, ErrNoLeaves
+	}
+	opts = append(opts, merkle.WithNodeStore(s))
+	// Leaves saved without their ordered IDs (i.e. by earlier versions of
+	// the package) are assigned the ones of their positions.
+	if len(nextID) != 8 {
+		return merkle.OpenTree(hash, digests, opts...)
+	}
+	return merkle.OpenTreeWithIDs(hash, digests, ids, binary.BigEndian.Uint64(nextID), opts...)
+}
+
+// Close commits all buffered writes and closes the Store, along with the
+// bbolt database if it was opened by Open.
+func (s *Store) Close() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	if !s.ownDB {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package bolt
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/ckatsak/merkle/internal/storetest"
+)
+
+func TestStore00(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	s, err := Open(path, &Options{BatchSize: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storetest.RoundTrip(t, s, func() storetest.Store {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if s, err = Open(path, nil); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+	defer s.Close()
+
+	// The merkle nodes of the larger trees must have been deleted.
+	if _, err := s.Get(1, len(want.Level(1))); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+}
+
+func TestStore01(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "tree.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if _, err := s.OpenTree(crypto.SHA256); !errors.Is(err, ErrNoLeaves) {
+		t.Fatalf("want (%v); got %v", ErrNoLeaves, err)
+	}
+	if _, err := s.Get(1, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+}
+
+func TestStore02(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.db")
+	s, err := Open(path, &Options{BatchSize: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	storetest.StableIDs(t, s, func() storetest.Store {
+		if err := s.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if s, err = Open(path, nil); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+	s.Close()
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package cache implements a merkle.NodeStore that caches the merkle nodes of
+// another (typically disk-backed) one in memory, evicting the least recently
+// used ones once it is full; hence, the nodes along hot proof paths (e.g. the
+// ones near the root) stay in memory, while cold subtrees remain on disk.
+//
+// The cache is write-through; i.e. writes go to the underlying NodeStore right
+// away, and only update the cached nodes.
+package cache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/ckatsak/merkle"
+)
+
+// Stats are the counters of a Store, which can be used to tune its capacity.
+type Stats struct {
+	// Hits and Misses are the numbers of reads that were served by the
+	// cache and by the underlying NodeStore, respectively.
+	Hits, Misses uint64
+	// Evictions is the number of nodes that have been evicted from the
+	// cache to make room for others.
+	Evictions uint64
+	// Len is the number of nodes currently in the cache.
+	Len int
+}
+
+// Store is a merkle.NodeStore that caches the merkle nodes of another one. It
+// is safe for concurrent use if the underlying NodeStore is.
+type Store struct {
+	backend  merkle.NodeStore
+	capacity int
+
+	mu    sync.Mutex
+	lru   *list.List // of *entry, most recently used first
+	nodes map[merkle.NodeID]*list.Element
+	stats Stats
+}
+
+type entry struct {
+	id     merkle.NodeID
+	digest []byte
+}
+
+// New returns a Store that caches up to the given number of merkle nodes of
+// the given NodeStore.
+func New(backend merkle.NodeStore, capacity int) *Store {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Store{
+		backend:  backend,
+		capacity: capacity,
+		lru:      list.New(),
+		nodes:    make(map[merkle.NodeID]*list.Element, capacity),
+	}
+}
+
+// Get implements merkle.NodeStore.
+func (s *Store) Get(level, index int) ([]byte, error) {
+	id := merkle.NodeID{Height: level, Index: index}
+	s.mu.Lock()
+	if e, ok := s.nodes[id]; ok {
+		s.lru.MoveToFront(e)
+		s.stats.Hits++
+		digest := e.Value.(*entry).digest
+		s.mu.Unlock()
+		return digest, nil
+	}
+	s.stats.Misses++
+	s.mu.Unlock()
+
+	digest, err := s.backend.Get(level, index)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.add(id, append([]byte{}, digest...))
+	s.mu.Unlock()
+	return digest, nil
+}
+
+// Put implements merkle.NodeStore.
+func (s *Store) Put(level, index int, digest []byte) error {
+	if err := s.backend.Put(level, index, digest); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(merkle.NodeID{Height: level, Index: index}, append([]byte{}, digest...))
+	return nil
+}
+
+// Delete implements merkle.NodeStore.
+func (s *Store) Delete(level, index int) error {
+	s.mu.Lock()
+	if e, ok := s.nodes[merkle.NodeID{Height: level, Index: index}]; ok {
+		s.lru.Remove(e)
+		delete(s.nodes, e.Value.(*entry).id)
+	}
+	s.mu.Unlock()
+	return s.backend.Delete(level, index)
+}
+
+// Flush implements merkle.Flusher, flushing the underlying NodeStore if it
+// buffers its writes.
+func (s *Store) Flush() error {
+	if f, ok := s.backend.(merkle.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// add caches the given digest, evicting the least recently used node if the
+// cache is full. It must be called with mu held.
+func (s *Store) add(id merkle.NodeID, digest []byte) {
+	if e, ok := s.nodes[id]; ok {
+		e.Value.(*entry).digest = digest
+		s.lru.MoveToFront(e)
+		return
+	}
+	if s.lru.Len() >= s.capacity {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.nodes, oldest.Value.(*entry).id)
+		s.stats.Evictions++
+	}
+	s.nodes[id] = s.lru.PushFront(&entry{id: id, digest: digest})
+}
+
+// Stats returns the current counters of the Store.
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := s.stats
+	stats.Len = s.lru.Len()
+	return stats
+}
+
+// ResetStats zeroes the hit, miss and eviction counters of the Store.
+func (s *Store) ResetStats() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats = Stats{}
+}
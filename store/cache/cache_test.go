@@ -0,0 +1,80 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cache
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/internal/storetest"
+)
+
+// countingStore counts the reads that reach it.
+type countingStore struct {
+	merkle.NodeStore
+	gets int
+}
+
+func (s *countingStore) Get(level, index int) ([]byte, error) {
+	s.gets++
+	return s.NodeStore.Get(level, index)
+}
+
+func TestStore00(t *testing.T) {
+	backend := &countingStore{NodeStore: merkle.NewMemNodeStore()}
+	s := New(backend, 16)
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, storetest.Data(1000), merkle.WithNodeStore(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := merkle.NewTree(crypto.SHA256, storetest.Data(1000)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := s.Stats(); stats.Len != 16 || stats.Evictions != uint64(want.MerkleSize()-16) {
+		t.Fatalf("want (16, %d); got (%d, %d)", want.MerkleSize()-16, stats.Len, stats.Evictions)
+	}
+
+	// Proving the same leaf twice hits the cache the second time.
+	s.ResetStats()
+	for i := 0; i < 2; i++ {
+		p, err := tree.Proof(123)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(want.MerkleRoot()) {
+			t.Fatal("proof does not verify")
+		}
+	}
+	stats := s.Stats()
+	t.Logf("s.Stats(): %+v", stats)
+	if stats.Hits < stats.Misses || int(stats.Misses) != backend.gets {
+		t.Fatalf("want (hits >= misses == %d); got %+v", backend.gets, stats)
+	}
+
+	tree.DeleteAndReconstruct(storetest.Data(1000)[500:]...)
+	if want, err = merkle.NewTree(crypto.SHA256, storetest.Data(500)...); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", want.MerkleRoot(), tree.MerkleRoot())
+	}
+	if _, err := s.Get(1, 250); err == nil {
+		t.Fatal("deleted node is still cached")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package mmap builds and opens merkle trees as single, memory-mapped flat
+// files (see merkle.Tree.WriteFlat), so that opening even a multi-gigabyte
+// tree requires no hash calculations and no reading of the file as a whole;
+// the operating system pages the digests in as they are accessed, and shares
+// these pages among all processes that have the same file open.
+package mmap
+
+import (
+	"os"
+
+	"github.com/ckatsak/merkle"
+	mmapgo "github.com/edsrzf/mmap-go"
+)
+
+// File is a merkle tree file that is mapped into memory.
+type File struct {
+	// Tree is the merkle tree of the File, which reads the digests of its
+	// nodes directly out of the mapped memory; it must not be used after
+	// the File is closed.
+	Tree *merkle.Tree
+
+	m mmapgo.MMap
+}
+
+// Create writes the flat encoding of the given merkle tree to a file at the
+// given path, replacing any existing one.
+func Create(path string, t *merkle.Tree) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := t.WriteFlat(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Open maps the merkle tree file at the given path (as written by Create)
+// into memory, read-only, and opens its merkle tree in place; see
+// merkle.OpenFlat for the details.
+func Open(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// The mapping outlives the file descriptor.
+	defer f.Close()
+	m, err := mmapgo.Map(f, mmapgo.RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	t, err := merkle.OpenFlat(m)
+	if err != nil {
+		m.Unmap()
+		return nil, err
+	}
+	return &File{Tree: t, m: m}, nil
+}
+
+// Close unmaps the File from memory.
+func (f *File) Close() error {
+	f.Tree = nil
+	return f.m.Unmap()
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mmap
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/internal/storetest"
+)
+
+func TestFile00(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.mrkf")
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, storetest.Data(1000), merkle.RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(path, tree); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Logf("f.Tree: %v", f.Tree)
+	if !bytes.Equal(tree.MerkleRoot(), f.Tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), f.Tree.MerkleRoot())
+	}
+	for i := 0; i < f.Tree.NumLeaves(); i += 37 {
+		p, err := f.Tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.Verify(tree.MerkleRoot()) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+	}
+	proof, err := f.Tree.ConsistencyProof(500, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := tree.ConsistencyProof(500, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof) != len(want) {
+		t.Fatalf("want (%d) digests; got %d", len(want), len(proof))
+	}
+}
+
+func TestFile01(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tree.mrkf")
+	if err := os.WriteFile(path, []byte("MRKF"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Fatalf("want (%v); got %v", merkle.ErrInvalidEncoding, err)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package sql implements a merkle.NodeStore on top of database/sql, so that
+// the merkle nodes (and the leaves) of a tree are kept in tables of a
+// relational database, next to the rest of the application's data.
+//
+// A Store buffers the merkle nodes written to it, and writes them all at
+// once when the merkle tree flushes it. It works with either a *sql.DB, in
+// which case each flush takes place in a transaction of its own, or a *sql.Tx,
+// in which case the merkle nodes of a tree are written in the same
+// transaction as the rest of the application's changes, and are committed
+// (or rolled back) along with them. Either way, no merkle tree is ever half
+// written, and neither are its saved leaves.
+//
+// The tables are shared by any number of trees, each of which is identified
+// by a name; they are created by the statements in SchemaSQLite or
+// SchemaPostgres (see CreateSchema), and can be queried directly, e.g.:
+//
+//	SELECT digest FROM merkle_nodes WHERE tree = 'blocks' ORDER BY level DESC LIMIT 1;
+//
+// yields the merkle root of tree "blocks" (of more than one leaf).
+package sql
+
+import (
+	"crypto"
+	stdsql "database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ckatsak/merkle"
+)
+
+var (
+	// ErrNotFound signifies that the requested merkle node is not in the
+	// Store.
+	ErrNotFound = errors.New("sql: node not found")
+	// ErrNoLeaves signifies that no leaves have been saved in the Store.
+	ErrNoLeaves = errors.New("sql: no leaves saved")
+)
+
+// SchemaSQLite creates the tables of the merkle nodes and leaves in SQLite
+// (3.24.0 or later). The ordered IDs of the leaves (oid) and the NextID of the
+// trees (next_id) are stored as the 64-bit signed integers of the same bits.
+//
+// Tables created before the ordered IDs were saved can be upgraded with:
+//
+//	ALTER TABLE merkle_leaves ADD COLUMN oid INTEGER;
+//
+// plus the creation of merkle_trees; the leaves of the trees that have not
+// been saved since are assigned the ordered IDs of their positions.
+const SchemaSQLite = `
+CREATE TABLE IF NOT EXISTS merkle_nodes (
+	tree   TEXT    NOT NULL,
+	level  INTEGER NOT NULL,
+	idx    INTEGER NOT NULL,
+	digest BLOB    NOT NULL,
+	PRIMARY KEY (tree, level, idx)
+);
+CREATE TABLE IF NOT EXISTS merkle_leaves (
+	tree   TEXT    NOT NULL,
+	idx    INTEGER NOT NULL,
+	digest BLOB    NOT NULL,
+	oid    INTEGER,
+	PRIMARY KEY (tree, idx)
+);
+CREATE TABLE IF NOT EXISTS merkle_trees (
+	tree    TEXT    NOT NULL PRIMARY KEY,
+	next_id INTEGER NOT NULL
+);
+`
+
+// SchemaPostgres creates the tables of the merkle nodes and leaves in
+// PostgreSQL (9.5 or later); see SchemaSQLite for the ordered IDs.
+const SchemaPostgres = `
+CREATE TABLE IF NOT EXISTS merkle_nodes (
+	tree   TEXT     NOT NULL,
+	level  SMALLINT NOT NULL,
+	idx    BIGINT   NOT NULL,
+	digest BYTEA    NOT NULL,
+	PRIMARY KEY (tree, level, idx)
+);
+CREATE TABLE IF NOT EXISTS merkle_leaves (
+	tree   TEXT   NOT NULL,
+	idx    BIGINT NOT NULL,
+	digest BYTEA  NOT NULL,
+	oid    BIGINT,
+	PRIMARY KEY (tree, idx)
+);
+CREATE TABLE IF NOT EXISTS merkle_trees (
+	tree    TEXT   NOT NULL PRIMARY KEY,
+	next_id BIGINT NOT NULL
+);
+`
+
+// Dialect is the SQL dialect of the database.
+type Dialect int
+
+// The supported SQL dialects.
+const (
+	SQLite Dialect = iota
+	Postgres
+)
+
+// Querier is implemented by both *sql.DB and *sql.Tx.
+type Querier interface {
+	Exec(query string, args ...interface{}) (stdsql.Result, error)
+	Query(query string, args ...interface{}) (*stdsql.Rows, error)
+	QueryRow(query string, args ...interface{}) *stdsql.Row
+}
+
+// CreateSchema creates the tables of the merkle nodes and leaves, unless they
+// already exist.
+func CreateSchema(q Querier, d Dialect) error {
+	schema := SchemaSQLite
+	if d == Postgres {
+		schema = SchemaPostgres
+	}
+	for _, stmt := range strings.Split(schema, ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		if _, err := q.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beginner is implemented by *sql.DB, whose writes a Store makes in a
+// transaction of its own.
+type beginner interface {
+	Begin() (*stdsql.Tx, error)
+}
+
+type nodeKey struct{ level, index int }
+
+// Store is a merkle.NodeStore that keeps the merkle nodes of a named tree in
+// the tables created by CreateSchema. It is as safe for concurrent use as the
+// given Querier.
+type Store struct {
+	q    Querier
+	tree string
+
+	mu sync.Mutex
+	// pending holds the buffered writes; nil digests stand for deletions.
+	pending map[nodeKey][]byte
+
+	getNode, putNode, deleteNode string
+	putLeaf, deleteLeaves        string
+	getLeaves                    string
+	putNextID, getNextID         string
+}
+
+// New returns a Store for the tree of the given name, on top of the given
+// *sql.DB or *sql.Tx, whose tables must have already been created.
+func New(q Querier, d Dialect, tree string) *Store {
+	s := &Store{
+		q:       q,
+		tree:    tree,
+		pending: make(map[nodeKey][]byte),
+
+		getNode:      "SELECT digest FROM merkle_nodes WHERE tree = ? AND level = ? AND idx = ?",
+		putNode:      "INSERT INTO merkle_nodes (tree, level, idx, digest) VALUES (?, ?, ?, ?) ON CONFLICT (tree, level, idx) DO UPDATE SET digest = excluded.digest",
+		deleteNode:   "DELETE FROM merkle_nodes WHERE tree = ? AND level = ? AND idx = ?",
+		putLeaf:      "INSERT INTO merkle_leaves (tree, idx, digest, oid) VALUES (?, ?, ?, ?)",
+		deleteLeaves: "DELETE FROM merkle_leaves WHERE tree = ?",
+		getLeaves:    "SELECT digest, oid FROM merkle_leaves WHERE tree = ? ORDER BY idx",
+		putNextID:    "INSERT INTO merkle_trees (tree, next_id) VALUES (?, ?) ON CONFLICT (tree) DO UPDATE SET next_id = excluded.next_id",
+		getNextID:    "SELECT next_id FROM merkle_trees WHERE tree = ?",
+	}
+	if d == Postgres {
+		for _, query := range []*string{&s.getNode, &s.putNode, &s.deleteNode, &s.putLeaf, &s.deleteLeaves, &s.getLeaves, &s.putNextID, &s.getNextID} {
+			*query = numberPlaceholders(*query)
+		}
+	}
+	return s
+}
+
+// numberPlaceholders replaces the question mark placeholders of the given
+// query with the numbered ones ($1, $2, ...) that PostgreSQL expects.
+func numberPlaceholders(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Get implements merkle.NodeStore. It also sees the buffered writes.
+func (s *Store) Get(level, index int) ([]byte, error) {
+	s.mu.Lock()
+	digest, ok := s.pending[nodeKey{level, index}]
+	s.mu.Unlock()
+	if ok {
+		if digest == nil {
+			return nil, ErrNotFound
+		}
+		return digest, nil
+	}
+	err := s.q.QueryRow(s.getNode, s.tree, level, index).Scan(&digest)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	return digest, err
+}
+
+// Put implements merkle.NodeStore. The write is buffered until the next
+// Flush.
+func (s *Store) Put(level, index int, digest []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[nodeKey{level, index}] = append([]byte{}, digest...)
+	return nil
+}
+
+// Delete implements merkle.NodeStore. Like Put, it is buffered.
+func (s *Store) Delete(level, index int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending[nodeKey{level, index}] = nil
+	return nil
+}
+
+// Flush writes all buffered writes in a single transaction (or in the given
+// *sql.Tx). It implements merkle.Flusher, so a merkle tree calls it once it
+// has written all of its merkle nodes.
+func (s *Store) Flush() error {
+	return s.inTx(func(Querier) error { return nil })
+}
+
+// inTx writes all buffered writes and then calls fn, in a single transaction
+// (or in the given *sql.Tx). The buffered writes are dropped once they have
+// been written; if the transaction fails, they are kept for the next Flush.
+func (s *Store) inTx(fn func(q Querier) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.q.(beginner)
+	if !ok {
+		if err := s.write(s.q, fn); err != nil {
+			return err
+		}
+		clear(s.pending)
+		return nil
+	}
+	tx, err := b.Begin()
+	if err != nil {
+		return err
+	}
+	if err := s.write(tx, fn); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	clear(s.pending)
+	return nil
+}
+
+func (s *Store) write(q Querier, fn func(q Querier) error) (err error) {
+	for key, digest := range s.pending {
+		if digest == nil {
+			_, err = q.Exec(s.deleteNode, s.tree, key.level, key.index)
+		} else {
+			_, err = q.Exec(s.putNode, s.tree, key.level, key.index, digest)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return fn(q)
+}
+
+// SaveLeaves saves the digests and the ordered IDs of the leaves of the given
+// merkle tree (which must have been built with this Store), along with its
+// NextID, replacing any previously saved ones, so that the tree can be
+// reopened through OpenTree. They are written in the same transaction as any
+// buffered merkle nodes.
+func (s *Store) SaveLeaves(t *merkle.Tree) error {
+	return s.inTx(func(q Querier) error {
+		if _, err := q.Exec(s.deleteLeaves, s.tree); err != nil {
+			return err
+		}
+		it := t.LeafIter()
+		for i := 0; it.Next(); i++ {
+			if _, err := q.Exec(s.putLeaf, s.tree, i, it.Digest(), int64(it.OrderedID())); err != nil {
+				return err
+			}
+		}
+		_, err := q.Exec(s.putNextID, s.tree, int64(t.NextID()))
+		return err
+	})
+}
+
+// OpenTree reopens the (digest-only) merkle tree whose leaves have been saved
+// through SaveLeaves, reading its merkle nodes lazily from the Store; see
+// merkle.OpenTreeWithIDs for the details. The given Options should be the
+// ones that the tree was originally built with (e.g. merkle.RFC6962).
+//
+// It returns ErrNoLeaves if no leaves have been saved in the Store.
+func (s *Store) OpenTree(hash crypto.Hash, opts ...merkle.Option) (*merkle.Tree, error) {
+	rows, err := s.q.Query(s.getLeaves, s.tree)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		digests [][]byte
+		ids     []uint64
+		legacy  bool
+	)
+	for rows.Next() {
+		var (
+			digest []byte
+			id     stdsql.NullInt64
+		)
+		if err := rows.Scan(&digest, &id); err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+		ids = append(ids, uint64(id.Int64))
+		legacy = legacy || !id.Valid
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(digests) == 0 {
+		return nil, ErrNoLeaves
+	}
+	var nextID int64
+	err = s.q.QueryRow(s.getNextID, s.tree).Scan(&nextID)
+	if errors.Is(err, stdsql.ErrNoRows) {
+		legacy = true
+	} else if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts, merkle.WithNodeStore(s))
+	// Leaves saved without their ordered IDs (i.e. by earlier versions of
+	// the package) are assigned the ones of their positions.
+	if legacy {
+		return merkle.OpenTree(hash, digests, opts...)
+	}
+	return merkle.OpenTreeWithIDs(hash, digests, ids, uint64(nextID), opts...)
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package sql
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha256"
+	stdsql "database/sql"
+	"errors"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/internal/storetest"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openDB(t *testing.T) *stdsql.DB {
+	db, err := stdsql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1) // each connection would get its own database
+	t.Cleanup(func() { db.Close() })
+	if err := CreateSchema(db, SQLite); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestStore00(t *testing.T) {
+	db := openDB(t)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := storetest.RoundTrip(t, New(tx, SQLite, "test"), func() storetest.Store {
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		return New(db, SQLite, "test")
+	})
+
+	// The merkle root can be queried directly...
+	var root []byte
+	if err := db.QueryRow("SELECT digest FROM merkle_nodes WHERE tree = 'test' ORDER BY level DESC LIMIT 1").Scan(&root); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want.MerkleRoot(), root) {
+		t.Fatalf("want root %x; got %x", want.MerkleRoot(), root)
+	}
+	// ...and no merkle nodes of the larger trees are left behind.
+	var numNodes int
+	if err := db.QueryRow("SELECT COUNT(*) FROM merkle_nodes WHERE tree = 'test'").Scan(&numNodes); err != nil {
+		t.Fatal(err)
+	}
+	if numNodes != want.MerkleSize() {
+		t.Fatalf("want (%d) nodes; got %d", want.MerkleSize(), numNodes)
+	}
+}
+
+func TestStore01(t *testing.T) {
+	db := openDB(t)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := merkle.NewTreeWithOptions(crypto.SHA256, storetest.Data(10), merkle.WithNodeStore(New(tx, SQLite, "test"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(db, SQLite, "test")
+	if _, err := s.Get(1, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+	if _, err := s.OpenTree(crypto.SHA256); !errors.Is(err, ErrNoLeaves) {
+		t.Fatalf("want (%v); got %v", ErrNoLeaves, err)
+	}
+}
+
+func TestNumberPlaceholders00(t *testing.T) {
+	s := New(nil, Postgres, "test")
+	want := "INSERT INTO merkle_leaves (tree, idx, digest, oid) VALUES ($1, $2, $3, $4)"
+	if s.putLeaf != want {
+		t.Fatalf("want (%s); got %s", want, s.putLeaf)
+	}
+}
+
+func TestStore02(t *testing.T) {
+	db := openDB(t)
+	s := New(db, SQLite, "test")
+	storetest.StableIDs(t, s, func() storetest.Store { return New(db, SQLite, "test") })
+
+	// Leaves saved without their ordered IDs get the ones of their positions.
+	if _, err := db.Exec("UPDATE merkle_leaves SET oid = NULL"); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := s.OpenTree(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.NextID() != uint64(tree.NumLeaves()) {
+		t.Fatalf("want NextID (%d); got %d", tree.NumLeaves(), tree.NextID())
+	}
+}
+
+func TestStore03(t *testing.T) {
+	db := openDB(t)
+	s := New(db, SQLite, "test")
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, storetest.Data(10), merkle.WithNodeStore(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveLeaves(tree); err != nil {
+		t.Fatal(err)
+	}
+
+	// The merkle nodes are only written upon Flush, which the tree calls.
+	if err := s.Put(9, 0, []byte("digest")); err != nil {
+		t.Fatal(err)
+	}
+	if digest, err := s.Get(9, 0); err != nil || string(digest) != "digest" {
+		t.Fatalf("want (digest, <nil>); got (%s, %v)", digest, err)
+	}
+	if _, err := New(db, SQLite, "test").Get(9, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+	if err := s.Delete(9, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed SaveLeaves leaves both the merkle nodes and the leaves intact.
+	tree.AppendAndReconstruct(storetest.Data(11)[10])
+	if err := tree.StoreErr(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(9, 0, []byte("digest")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DROP TABLE merkle_trees"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveLeaves(tree); err == nil {
+		t.Fatal("want non-nil error; got <nil>")
+	}
+	var numLeaves int
+	if err := db.QueryRow("SELECT COUNT(*) FROM merkle_leaves WHERE tree = 'test'").Scan(&numLeaves); err != nil {
+		t.Fatal(err)
+	}
+	if numLeaves != 10 {
+		t.Fatalf("want (10) leaves; got %d", numLeaves)
+	}
+	if _, err := New(db, SQLite, "test").Get(9, 0); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("want (%v); got %v", ErrNotFound, err)
+	}
+	if digest, err := s.Get(9, 0); err != nil || string(digest) != "digest" {
+		t.Fatalf("want (digest, <nil>); got (%s, %v)", digest, err)
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// mapStore is a NodeStore that keeps the merkle nodes in a map, and can be
+// made to fail on demand.
+type mapStore struct {
+	nodes map[NodeID][]byte
+	fail  bool
+}
+
+var errMapStore = errors.New("mapStore: Failure")
+
+func (s *mapStore) Get(level, index int) ([]byte, error) {
+	digest, ok := s.nodes[NodeID{Height: level, Index: index}]
+	if s.fail || !ok {
+		return nil, errMapStore
+	}
+	return digest, nil
+}
+
+func (s *mapStore) Put(level, index int, digest []byte) error {
+	if s.fail {
+		return errMapStore
+	}
+	s.nodes[NodeID{Height: level, Index: index}] = copyBytes(digest)
+	return nil
+}
+
+func (s *mapStore) Delete(level, index int) error {
+	if s.fail {
+		return errMapStore
+	}
+	delete(s.nodes, NodeID{Height: level, Index: index})
+	return nil
+}
+
+func TestNodeStore00(t *testing.T) {
+	store := &mapStore{nodes: make(map[NodeID][]byte)}
+	stree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	check := func() {
+		t.Logf("stree.MerkleRoot(): %x", stree.MerkleRoot())
+		if !bytes.Equal(tree.MerkleRoot(), stree.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", tree.MerkleRoot(), stree.MerkleRoot())
+		}
+		if len(store.nodes) != tree.MerkleSize() {
+			t.Fatalf("want (%d) stored nodes; got %d", tree.MerkleSize(), len(store.nodes))
+		}
+		for i := 0; i < stree.NumLeaves(); i++ {
+			p, err := stree.Proof(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !p.Verify(tree.MerkleRoot()) {
+				t.Fatalf("proof of leaf %d does not verify", i)
+			}
+		}
+		for _, word := range tree.Leaves() {
+			if v, err := stree.VerifySerializedDatum(word); err != nil || !v {
+				t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+			}
+		}
+	}
+	check()
+
+	// Growing and shrinking the tree must leave no stale nodes behind.
+	tree.AppendAndReconstruct(enAlphabetCap...)
+	stree.AppendAndReconstruct(enAlphabetCap...)
+	check()
+	tree.DeleteAndReconstruct(append(grAlphabet, enAlphabetCap[3:]...)...)
+	stree.DeleteAndReconstruct(append(grAlphabet, enAlphabetCap[3:]...)...)
+	check()
+	if err := stree.StoreErr(); err != nil {
+		t.Fatalf("want (<nil>); got %v", err)
+	}
+
+	// The clone keeps its merkle nodes in memory.
+	clone := stree.Clone()
+	store.nodes = make(map[NodeID][]byte)
+	if !bytes.Equal(tree.MerkleRoot(), clone.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), clone.MerkleRoot())
+	}
+}
+
+func TestNodeStore01(t *testing.T) {
+	store := &mapStore{nodes: make(map[NodeID][]byte), fail: true}
+	if _, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(store)); !errors.Is(err, errMapStore) {
+		t.Fatalf("want (%v); got %v", errMapStore, err)
+	}
+
+	store.fail = false
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.fail = true
+	if _, err := tree.Proof(0); !errors.Is(err, errMapStore) {
+		t.Fatalf("want (%v); got %v", errMapStore, err)
+	}
+	if _, err := tree.VerifyDatum(grAlphabet[0]); !errors.Is(err, errMapStore) {
+		t.Fatalf("want (%v); got %v", errMapStore, err)
+	}
+	if _, err := tree.MarshalBinary(); !errors.Is(err, errMapStore) {
+		t.Fatalf("want (%v); got %v", errMapStore, err)
+	}
+	tree.AppendAndReconstruct(enAlphabetCap...)
+	if err := tree.StoreErr(); !errors.Is(err, errMapStore) {
+		t.Fatalf("want (%v); got %v", errMapStore, err)
+	}
+}
+
+func TestMemNodeStore00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(NewMemNodeStore()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtree.AppendAndReconstruct(enAlphabetCap...)
+	mtree.DeleteAndReconstruct(enAlphabetCap...)
+	if !bytes.Equal(tree.MerkleRoot(), mtree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), mtree.MerkleRoot())
+	}
+	if mtree.Height() != tree.Height() || mtree.MerkleSize() != tree.MerkleSize() {
+		t.Fatalf("want (%d, %d); got (%d, %d)", tree.Height(), tree.MerkleSize(), mtree.Height(), mtree.MerkleSize())
+	}
+	if _, err := mtree.store.Get(mtree.Height(), 0); err == nil {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
+
+func TestOpenTree00(t *testing.T) {
+	store := &mapStore{nodes: make(map[NodeID][]byte)}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digests := make([][]byte, tree.NumLeaves())
+	for i := range digests {
+		if digests[i], err = tree.LeafDigest(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	otree, err := OpenTree(crypto.SHA256, digests, WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), otree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), otree.MerkleRoot())
+	}
+	for _, word := range grAlphabet {
+		if v, err := otree.VerifyDatum(word); err != nil || !v {
+			t.Fatalf("ERROR while verifying \"%s\": (%v, %v)", word, v, err)
+		}
+	}
+
+	// A tampered leaf digest is detected upon verification.
+	digests[0][0] ^= 0xff
+	if otree, err = OpenTree(crypto.SHA256, digests, WithNodeStore(store)); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := otree.VerifyOrderedID(0); err != nil || v {
+		t.Fatalf("want (false, <nil>); got (%v, %v)", v, err)
+	}
+
+	if _, err := OpenTree(crypto.SHA256, digests); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+}
+
+func TestOpenTree01(t *testing.T) {
+	store := &mapStore{nodes: make(map[NodeID][]byte)}
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, InsertionOrder(), WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.DeleteAndReconstruct(grAlphabet[0], grAlphabet[len(grAlphabet)-1])
+	var digests [][]byte
+	var ids []uint64
+	for it := tree.LeafIter(); it.Next(); {
+		digests = append(digests, it.Digest())
+		ids = append(ids, it.OrderedID())
+	}
+
+	// The ordered IDs survive reopening the merkle tree.
+	otree, err := OpenTreeWithIDs(crypto.SHA256, digests, ids, tree.NextID(), WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tree.MerkleRoot(), otree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), otree.MerkleRoot())
+	}
+	if otree.NextID() != uint64(len(grAlphabet)) {
+		t.Fatalf("want NextID (%d); got %d", len(grAlphabet), otree.NextID())
+	}
+	if v, err := otree.VerifyOrderedID(1); err != nil || !v {
+		t.Fatalf("want (true, <nil>); got (%v, %v)", v, err)
+	}
+	if _, err := otree.VerifyOrderedID(0); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+
+	for _, bad := range []struct {
+		ids    []uint64
+		nextID uint64
+	}{
+		{ids[1:], tree.NextID()},
+		{append([]uint64{ids[1]}, ids[1:]...), tree.NextID()},
+		{ids, ids[len(ids)-1]},
+	} {
+		if _, err := OpenTreeWithIDs(crypto.SHA256, digests, bad.ids, bad.nextID, WithNodeStore(store)); !errors.Is(err, ErrInvalidRange) {
+			t.Fatalf("want (%v); got %v", ErrInvalidRange, err)
+		}
+	}
+}
+
+// countingStore is a mapStore that counts the calls to its methods.
+type countingStore struct {
+	mapStore
+	gets, puts, deletes int
+}
+
+func (s *countingStore) Get(level, index int) ([]byte, error) {
+	s.gets++
+	return s.mapStore.Get(level, index)
+}
+
+func (s *countingStore) Put(level, index int, digest []byte) error {
+	s.puts++
+	return s.mapStore.Put(level, index, digest)
+}
+
+func (s *countingStore) Delete(level, index int) error {
+	s.deletes++
+	return s.mapStore.Delete(level, index)
+}
+
+func TestNodeStore02(t *testing.T) {
+	const numLeaves = 1 << 14
+	data := make([]Datum, numLeaves+3)
+	for i := range data {
+		data[i] = StringDatum(strconv.Itoa(i))
+	}
+	store := &countingStore{mapStore: mapStore{nodes: make(map[NodeID][]byte)}}
+	stree, err := NewTreeWithOptions(crypto.SHA256, data[:numLeaves], InsertionOrder(), WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.puts != numLeaves-1 {
+		t.Fatalf("want (%d) puts; got %d", numLeaves-1, store.puts)
+	}
+
+	// Appending reads and writes the nodes along the path of the appended
+	// leaves only, rather than all of them.
+	for i := numLeaves; i < len(data); i++ {
+		store.gets, store.puts, store.deletes = 0, 0, 0
+		stree.AppendAndReconstruct(data[i])
+		if err := stree.StoreErr(); err != nil {
+			t.Fatal(err)
+		}
+		if height := stree.Height(); store.gets > height || store.puts > height || store.deletes != 0 {
+			t.Fatalf("want at most (%d) gets and puts; got (%d, %d, %d deletes)", height, store.gets, store.puts, store.deletes)
+		}
+		tree, err := NewTreeWithOptions(crypto.SHA256, data[:i+1], InsertionOrder())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stree.MerkleRoot(), tree.MerkleRoot()) {
+			t.Fatalf("want root %x; got %x", tree.MerkleRoot(), stree.MerkleRoot())
+		}
+	}
+
+	// Deleting the last leaf only rewrites its path and deletes the nodes
+	// that are left over.
+	store.gets, store.puts, store.deletes = 0, 0, 0
+	stree.DeleteAndReconstruct(data[len(data)-1])
+	if height := stree.Height(); store.puts > height || store.deletes > height {
+		t.Fatalf("want at most (%d) puts and deletes; got (%d, %d)", height, store.puts, store.deletes)
+	}
+	tree, err := NewTreeWithOptions(crypto.SHA256, data[:len(data)-1], InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stree.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), stree.MerkleRoot())
+	}
+}
+
+func TestNodeStore03(t *testing.T) {
+	// A NodeStore that fails to provide the unchanged nodes makes them be
+	// recalculated out of the leaves.
+	store := &mapStore{nodes: make(map[NodeID][]byte)}
+	stree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet[:20], InsertionOrder(), WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := range store.nodes {
+		if id.Height < 3 {
+			delete(store.nodes, id)
+		}
+	}
+	stree.AppendAndReconstruct(grAlphabet[20:]...)
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stree.MerkleRoot(), tree.MerkleRoot()) {
+		t.Fatalf("want root %x; got %x", tree.MerkleRoot(), stree.MerkleRoot())
+	}
+}
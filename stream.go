@@ -0,0 +1,162 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"crypto"
+	"hash"
+	"io"
+)
+
+// Stream calculates the merkle root of a sequence of leaves of unknown length
+// in a single pass, without retaining them; it only holds the roots of the
+// O(log2(L)) perfect subtrees (the "peaks") that the leaves so far add up to.
+//
+// The merkle root of the leaves added to a Stream is the one of a tree built
+// from them in the order they were added (i.e. with InsertionOrder) and with
+// the same Options.
+type Stream struct {
+	hash   crypto.Hash
+	h      hash.Hash
+	scheme scheme
+
+	// peaks[k] is the root of the perfect subtree of 2^k leaves, if any.
+	peaks     [][]byte
+	numLeaves int
+}
+
+// NewStream creates a new Stream given one of the available (i.e. linked into
+// the binary) hash functions and any number of Options (of which only the
+// ones that affect hashing, e.g. RFC6962, matter).
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if an arity greater than 2 is requested, or if
+// padding is requested through PadToPowerOfTwo or FixedDepth.
+func NewStream(hash crypto.Hash, opts ...Option) (*Stream, error) {
+	t := &Tree{hash: hash}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if !t.hashAvailable() {
+		return nil, &HashError{Hash: t.hash}
+	}
+	if t.scheme.width() != 2 || t.scheme.padded {
+		return nil, ErrUnsupported
+	}
+	return &Stream{hash: t.hash, h: t.newHasher(), scheme: t.scheme}, nil
+}
+
+// Add adds a leaf, given its serialized datum, to the Stream.
+func (s *Stream) Add(serializedDatum []byte) {
+	s.addDigest(s.scheme.hashLeaf(s.h, serializedDatum))
+}
+
+// AddDigest adds a leaf, given its digest, to the Stream.
+//
+// It returns a non-nil error if the given digest is of the wrong size.
+func (s *Stream) AddDigest(digest []byte) error {
+	if len(digest) != s.h.Size() {
+		return ErrInvalidDigest
+	}
+	s.addDigest(copyBytes(digest))
+	return nil
+}
+
+func (s *Stream) addDigest(digest []byte) {
+	// Merge the new leaf with the peaks of equal size, like a binary
+	// counter carries.
+	k := 0
+	for ; k < len(s.peaks) && s.peaks[k] != nil; k++ {
+		digest = s.scheme.hashNode(s.h, s.peaks[k], digest)
+		s.peaks[k] = nil
+	}
+	if k == len(s.peaks) {
+		s.peaks = append(s.peaks, nil)
+	}
+	s.peaks[k] = digest
+	s.numLeaves++
+}
+
+// NumLeaves returns the number of leaves added to the Stream so far.
+func (s *Stream) NumLeaves() int {
+	return s.numLeaves
+}
+
+// Root returns the merkle root of the leaves added to the Stream so far. More
+// leaves can be added afterwards.
+//
+// It returns a non-nil error if no leaves have been added.
+func (s *Stream) Root() ([]byte, error) {
+	if s.numLeaves == 0 {
+		return nil, ErrNoData
+	}
+	// Climb up from the lowest peak, carrying the last node of each level
+	// (i.e. the root of the trailing, imperfect subtree) to the next one.
+	var carry []byte
+	for k := 0; ; k++ {
+		var peak []byte
+		if k < len(s.peaks) {
+			peak = s.peaks[k]
+		}
+		width := s.numLeaves >> uint(k)
+		if carry != nil {
+			width++
+		}
+		if width == 1 {
+			if carry != nil {
+				return copyBytes(carry), nil
+			}
+			return copyBytes(peak), nil
+		}
+		switch {
+		case peak != nil && carry != nil:
+			carry = s.scheme.hashNode(s.h, peak, carry)
+		case peak != nil:
+			carry = s.scheme.hashLone(s.h, peak)
+		case carry != nil:
+			carry = s.scheme.hashLone(s.h, carry)
+		}
+	}
+}
+
+// RootFromReader calculates the merkle root of the contents of r, read until
+// EOF and hashed in chunks of chunkSize bytes (if not positive,
+// DefaultChunkSize is used), in O(log2(L)) memory; i.e. the merkle root of the
+// FileTree that NewFileTree would create out of them.
+//
+// It returns a non-nil error if the requested hash function has not been
+// linked into the binary, if r is empty, or if reading from r fails.
+func RootFromReader(hash crypto.Hash, r io.Reader, chunkSize int) ([]byte, error) {
+	s, err := NewStream(hash)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			s.Add(chunk[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return s.Root()
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"testing"
+)
+
+func TestStream00(t *testing.T) {
+	for _, opts := range [][]Option{{InsertionOrder()}, {RFC6962()}} {
+		s, err := NewStream(crypto.SHA256, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.Root(); !errors.Is(err, ErrNoData) {
+			t.Fatalf("want (%v); got %v", ErrNoData, err)
+		}
+		data := append(append([]Datum{}, grAlphabet...), enAlphabetCap...)
+		for n := 1; n <= len(data); n++ {
+			s.Add(data[n-1].Serialize())
+			tree, err := NewTreeWithOptions(crypto.SHA256, data[:n], opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			root, err := s.Root()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(tree.MerkleRoot(), root) {
+				t.Fatalf("%d leaves: want root %x; got %x", n, tree.MerkleRoot(), root)
+			}
+		}
+		t.Logf("s.NumLeaves(): %d; len(s.peaks): %d", s.NumLeaves(), len(s.peaks))
+	}
+}
+
+func TestStream01(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	for _, chunkSize := range []int{1, 7, 64, 1000, 20000} {
+		ft, err := NewFileTree(crypto.SHA256, bytes.NewReader(content), chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root, err := RootFromReader(crypto.SHA256, bytes.NewReader(content), chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(ft.MerkleRoot(), root) {
+			t.Fatalf("chunk size %d: want root %x; got %x", chunkSize, ft.MerkleRoot(), root)
+		}
+	}
+	if _, err := RootFromReader(crypto.SHA256, bytes.NewReader(nil), 0); !errors.Is(err, ErrNoData) {
+		t.Fatalf("want (%v); got %v", ErrNoData, err)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"sort"
+	"sync"
+)
+
+// RootUpdate describes a mutation of a merkle tree, as reported to its
+// subscribers (see Subscribe).
+type RootUpdate struct {
+	// Op is the name of the operation that mutated the merkle tree, e.g.
+	// "AppendAndReconstruct" or "Commit".
+	Op string
+	// OldRoot and NewRoot are the merkle roots before and after the
+	// mutation, which may be equal (e.g. if only duplicates were given
+	// while they are ignored).
+	OldRoot, NewRoot []byte
+	// Appended and Deleted are the numbers of the leaves that the
+	// mutation appended and removed, respectively.
+	Appended, Deleted int
+	// NumLeaves and Version are the number of leaves and the version (see
+	// Tree.Version) of the merkle tree after the mutation.
+	NumLeaves, Version int
+}
+
+// subscriptions holds the callbacks registered through Subscribe, on behalf
+// of the merkle tree that owns them.
+type subscriptions struct {
+	owner *Tree
+	mu    sync.Mutex
+	next  int
+	fns   map[int]func(RootUpdate)
+}
+
+// Subscribe registers the given callback to be called with a RootUpdate upon
+// each mutation of the merkle tree (e.g. through AppendAndReconstruct, or the
+// Commit of a Batch) that is carried out successfully; e.g. to broadcast its
+// new merkle roots. It returns a function that cancels the subscription,
+// which may be called any number of times.
+//
+// The callbacks are called synchronously, in the order they were registered,
+// by the goroutine that mutates the merkle tree, once the mutation is
+// complete; they may read the merkle tree, but not modify it. The merkle
+// trees derived from it (e.g. through Snapshot, Clone or Appended) do not
+// inherit its subscriptions.
+func (t *Tree) Subscribe(fn func(RootUpdate)) (cancel func()) {
+	if t.subs == nil || t.subs.owner != t {
+		t.subs = &subscriptions{owner: t, fns: make(map[int]func(RootUpdate))}
+	}
+	s := t.subs
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.next
+	s.next++
+	s.fns[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.fns, id)
+	}
+}
+
+// subscribed reports whether any callbacks are registered on the merkle tree
+// through Subscribe.
+func (t *Tree) subscribed() bool {
+	if t.subs == nil || t.subs.owner != t {
+		return false
+	}
+	t.subs.mu.Lock()
+	defer t.subs.mu.Unlock()
+	return len(t.subs.fns) != 0
+}
+
+// publish calls the callbacks registered on the merkle tree through Subscribe
+// with the given RootUpdate, in the order they were registered.
+func (t *Tree) publish(u RootUpdate) {
+	s := t.subs
+	s.mu.Lock()
+	ids := make([]int, 0, len(s.fns))
+	for id := range s.fns {
+		ids = append(ids, id)
+	}
+	fns := make([]func(RootUpdate), 0, len(ids))
+	sort.Ints(ids)
+	for _, id := range ids {
+		fns = append(fns, s.fns[id])
+	}
+	s.mu.Unlock()
+	for _, fn := range fns {
+		fn(u)
+	}
+}
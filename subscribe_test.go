@@ -0,0 +1,89 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"reflect"
+	"testing"
+)
+
+func TestSubscribe00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet[:10]...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var updates, others []RootUpdate
+	cancel := tree.Subscribe(func(u RootUpdate) {
+		if !bytes.Equal(u.NewRoot, tree.MerkleRoot()) {
+			t.Errorf("want the mutation complete; got root %x", tree.MerkleRoot())
+		}
+		updates = append(updates, u)
+	})
+	tree.Subscribe(func(u RootUpdate) {
+		others = append(others, u)
+	})
+
+	roots := [][]byte{copyBytes(tree.MerkleRoot())}
+	tree.AppendAndReconstruct(grAlphabet[10:12]...)
+	roots = append(roots, copyBytes(tree.MerkleRoot()))
+	b := tree.Begin()
+	if _, err := b.Append(grAlphabet[12]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Delete(grAlphabet[0], grAlphabet[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	roots = append(roots, copyBytes(tree.MerkleRoot()))
+
+	// Neither failed mutations nor the derived trees are reported.
+	if _, err := tree.Delete(grAlphabet[20]); err == nil {
+		t.Fatal("want non-nil error for the missing datum")
+	}
+	if _, err := tree.Appended(grAlphabet[13]); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := tree.Snapshot()
+	snapshot.AppendAndReconstruct(grAlphabet[13])
+
+	want := []RootUpdate{
+		{Op: "AppendAndReconstruct", Appended: 2, NumLeaves: 12, Version: 1},
+		{Op: "Commit", Appended: 1, Deleted: 2, NumLeaves: 11, Version: 2},
+	}
+	if len(updates) != len(want) {
+		t.Fatalf("want (%d) updates; got %d", len(want), len(updates))
+	}
+	for i, u := range updates {
+		t.Logf("%+v", u)
+		if !bytes.Equal(u.OldRoot, roots[i]) || !bytes.Equal(u.NewRoot, roots[i+1]) {
+			t.Fatalf("want roots (%x, %x); got (%x, %x)", roots[i], roots[i+1], u.OldRoot, u.NewRoot)
+		}
+		u.OldRoot, u.NewRoot = nil, nil
+		if !reflect.DeepEqual(u, want[i]) {
+			t.Fatalf("want (%+v); got %+v", want[i], u)
+		}
+	}
+
+	cancel()
+	cancel()
+	tree.AppendAndReconstruct(grAlphabet[14])
+	if len(updates) != 2 || len(others) != 3 {
+		t.Fatalf("want (2, 3) updates; got (%d, %d)", len(updates), len(others))
+	}
+}
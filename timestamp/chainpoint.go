@@ -0,0 +1,179 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package timestamp
+
+import (
+	"crypto"
+	"encoding/hex"
+
+	"github.com/ckatsak/merkle"
+)
+
+// chainpointContext is the JSON-LD context of Chainpoint v3 receipts.
+const chainpointContext = "https://w3id.org/chainpoint/v3"
+
+// chainpointHashOps maps the hash functions to the names of their Chainpoint
+// operations.
+var chainpointHashOps = map[crypto.Hash]string{
+	crypto.SHA224:   "sha-224",
+	crypto.SHA256:   "sha-256",
+	crypto.SHA384:   "sha-384",
+	crypto.SHA512:   "sha-512",
+	crypto.SHA3_224: "sha3-224",
+	crypto.SHA3_256: "sha3-256",
+	crypto.SHA3_384: "sha3-384",
+	crypto.SHA3_512: "sha3-512",
+}
+
+// ChainpointReceipt is a Chainpoint v3 receipt; it is meant to be encoded to
+// (and decoded from) JSON through the encoding/json package.
+type ChainpointReceipt struct {
+	Context string `json:"@context"`
+	Type    string `json:"type"`
+	// Hash is the hexadecimal digest that the receipt is about, i.e. the
+	// one of a leaf.
+	Hash string `json:"hash"`
+	// The identifiers and submission times that Chainpoint nodes and cores
+	// assign to the hash; up to the caller to set, if needed.
+	HashIDNode          string `json:"hash_id_node,omitempty"`
+	HashSubmittedNodeAt string `json:"hash_submitted_node_at,omitempty"`
+	HashIDCore          string `json:"hash_id_core,omitempty"`
+	HashSubmittedCoreAt string `json:"hash_submitted_core_at,omitempty"`
+	// Branches hold the operations that lead from Hash to the anchors.
+	Branches []ChainpointBranch `json:"branches"`
+}
+
+// ChainpointBranch is a list of operations in a ChainpointReceipt, possibly
+// followed by further branches.
+type ChainpointBranch struct {
+	Label    string             `json:"label,omitempty"`
+	Ops      []ChainpointOp     `json:"ops"`
+	Branches []ChainpointBranch `json:"branches,omitempty"`
+}
+
+// ChainpointOp is an operation of a ChainpointBranch; exactly one of its
+// fields is set. L and R hold the hexadecimal data to prepend and append
+// respectively, Op the name of the hash function to hash by, and Anchors the
+// anchors of the current message.
+type ChainpointOp struct {
+	L       string             `json:"l,omitempty"`
+	R       string             `json:"r,omitempty"`
+	Op      string             `json:"op,omitempty"`
+	Anchors []ChainpointAnchor `json:"anchors,omitempty"`
+}
+
+// ChainpointAnchor is the anchor of a message in a ChainpointReceipt, i.e. the
+// claim that it was committed to (e.g. by a Chainpoint calendar, of type
+// "cal", or by a Bitcoin transaction, of type "btc").
+type ChainpointAnchor struct {
+	Type     string   `json:"type"`
+	AnchorID string   `json:"anchor_id"`
+	URIs     []string `json:"uris,omitempty"`
+}
+
+// NewChainpointReceipt returns the Chainpoint v3 receipt of the leaf of the
+// given inclusion proof, whose single branch ends in the given anchors of the
+// merkle root.
+//
+// It returns a non-nil error if the hash function of the Proof has no
+// Chainpoint operation (i.e. it is not one of the SHA-2 or SHA-3 family), or
+// if it cannot be expressed as a list of operations.
+func NewChainpointReceipt(p *merkle.Proof, anchors ...ChainpointAnchor) (*ChainpointReceipt, error) {
+	hashOp, ok := chainpointHashOps[p.Hash]
+	if !ok {
+		return nil, ErrUnsupportedHash
+	}
+	ops, err := p.Ops()
+	if err != nil {
+		return nil, err
+	}
+	branch := ChainpointBranch{Ops: make([]ChainpointOp, 0, len(ops)+1)}
+	for _, op := range ops {
+		switch op.Kind {
+		case merkle.OpAppend:
+			branch.Ops = append(branch.Ops, ChainpointOp{R: hex.EncodeToString(op.Data)})
+		case merkle.OpPrepend:
+			branch.Ops = append(branch.Ops, ChainpointOp{L: hex.EncodeToString(op.Data)})
+		case merkle.OpHash:
+			branch.Ops = append(branch.Ops, ChainpointOp{Op: hashOp})
+		}
+	}
+	if len(anchors) > 0 {
+		branch.Ops = append(branch.Ops, ChainpointOp{Anchors: anchors})
+	}
+	return &ChainpointReceipt{
+		Context:  chainpointContext,
+		Type:     "Chainpoint",
+		Hash:     hex.EncodeToString(p.LeafDigest),
+		Branches: []ChainpointBranch{branch},
+	}, nil
+}
+
+// Root returns the result of the operations of the first branch of the
+// ChainpointReceipt, i.e. the merkle root that its anchors are about, if it
+// was returned by NewChainpointReceipt.
+//
+// It returns a non-nil error if the receipt has no branches, if its hash
+// operations are not all by the same, available hash function, or if its data
+// are not hexadecimal.
+func (r *ChainpointReceipt) Root() ([]byte, error) {
+	if len(r.Branches) == 0 {
+		return nil, ErrInvalidReceipt
+	}
+	digest, err := hex.DecodeString(r.Hash)
+	if err != nil {
+		return nil, ErrInvalidReceipt
+	}
+	var hash crypto.Hash
+	ops := make([]merkle.ProofOp, 0, len(r.Branches[0].Ops))
+	for _, cop := range r.Branches[0].Ops {
+		switch {
+		case cop.L != "":
+			data, err := hex.DecodeString(cop.L)
+			if err != nil {
+				return nil, ErrInvalidReceipt
+			}
+			ops = append(ops, merkle.ProofOp{Kind: merkle.OpPrepend, Data: data})
+		case cop.R != "":
+			data, err := hex.DecodeString(cop.R)
+			if err != nil {
+				return nil, ErrInvalidReceipt
+			}
+			ops = append(ops, merkle.ProofOp{Kind: merkle.OpAppend, Data: data})
+		case cop.Op != "":
+			h := chainpointHash(cop.Op)
+			if h == 0 || (hash != 0 && h != hash) {
+				return nil, ErrUnsupportedHash
+			}
+			hash = h
+			ops = append(ops, merkle.ProofOp{Kind: merkle.OpHash})
+		}
+	}
+	if hash == 0 {
+		return digest, nil
+	}
+	return merkle.ApplyOps(hash, digest, ops)
+}
+
+// chainpointHash returns the hash function of the Chainpoint operation of the
+// given name, or 0 if there is none.
+func chainpointHash(name string) crypto.Hash {
+	for hash, op := range chainpointHashOps {
+		if op == name {
+			return hash
+		}
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+func TestChainpointReceipt00(t *testing.T) {
+	data := [][]byte{[]byte("alpha"), []byte("beta"), []byte("gamma"), []byte("delta"), []byte("epsilon")}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, datums(data), merkle.RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	anchor := ChainpointAnchor{Type: "cal", AnchorID: "985635", URIs: []string{"https://a.chainpoint.org/calendar/985635/hash"}}
+	for i := range data {
+		proof, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewChainpointReceipt(proof, anchor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r2 ChainpointReceipt
+		if err := json.Unmarshal(b, &r2); err != nil {
+			t.Fatal(err)
+		}
+		root, err := r2.Root()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root, tree.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", tree.MerkleRoot(), root)
+		}
+		ops := r2.Branches[0].Ops
+		if last := ops[len(ops)-1]; len(last.Anchors) != 1 || last.Anchors[0].AnchorID != anchor.AnchorID {
+			t.Fatalf("want (%v); got %v", anchor, last.Anchors)
+		}
+		if i == 0 {
+			t.Logf("%s", b)
+		}
+	}
+}
+
+func TestChainpointReceipt01(t *testing.T) {
+	for _, r := range []ChainpointReceipt{
+		{Hash: "00"},
+		{Hash: "zz", Branches: []ChainpointBranch{{}}},
+		{Hash: "00", Branches: []ChainpointBranch{{Ops: []ChainpointOp{{L: "0g"}}}}},
+	} {
+		if _, err := r.Root(); !errors.Is(err, ErrInvalidReceipt) {
+			t.Fatalf("want (%v); got %v", ErrInvalidReceipt, err)
+		}
+	}
+	r := ChainpointReceipt{Hash: "00", Branches: []ChainpointBranch{{Ops: []ChainpointOp{{Op: "sha-256"}, {Op: "sha-256-x2"}}}}}
+	if _, err := r.Root(); !errors.Is(err, ErrUnsupportedHash) {
+		t.Fatalf("want (%v); got %v", ErrUnsupportedHash, err)
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package timestamp exports the inclusion proofs of merkle trees as the
+// receipts of anchored timestamping services, i.e. as OpenTimestamps detached
+// timestamp files (see https://opentimestamps.org) and as Chainpoint v3
+// receipts (see https://chainpoint.org), so that a merkle root that has been
+// anchored through such a service timestamps each of the leaves of its tree.
+//
+// Both formats express a proof as a list of append, prepend and hash
+// operations (see merkle.Proof.Ops) that lead from the digest of a leaf to the
+// merkle root, followed by the attestation (or anchor) of the latter, which
+// is up to the caller to provide. Only linear receipts, as produced by the
+// package, are decoded back.
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+
+	"github.com/ckatsak/merkle"
+)
+
+var (
+	// ErrUnsupportedHash signifies that the hash function has no operation
+	// in the receipt format.
+	ErrUnsupportedHash = errors.New("timestamp: unsupported hash function")
+
+	// ErrInvalidReceipt signifies that the given data are not a valid (or
+	// not a linear) receipt.
+	ErrInvalidReceipt = errors.New("timestamp: invalid receipt")
+)
+
+// otsMagic prefixes every OpenTimestamps detached timestamp file.
+var otsMagic = []byte("\x00OpenTimestamps\x00\x00Proof\x00\xbf\x89\xe2\xe8\x84\xe8\x92\x94")
+
+// otsVersion is the major version of the detached timestamp files that the
+// package supports.
+const otsVersion = 1
+
+// Tags of the OpenTimestamps operations.
+const (
+	otsTagAttestation byte = 0x00
+	otsTagFork        byte = 0xff
+	otsTagAppend      byte = 0xf0
+	otsTagPrepend     byte = 0xf1
+)
+
+// otsHashTags maps the hash functions to the tags of their OpenTimestamps
+// operations.
+var otsHashTags = map[crypto.Hash]byte{
+	crypto.SHA1:      0x02,
+	crypto.RIPEMD160: 0x03,
+	crypto.SHA256:    0x08,
+}
+
+// otsMaxMsgLength is the maximum length of a message (and of the argument of
+// an operation) in OpenTimestamps.
+const otsMaxMsgLength = 4096
+
+// Attestation is the attestation that an OpenTimestamps timestamp ends in,
+// i.e. the claim that its result (the merkle root) was committed to.
+type Attestation struct {
+	// Tag identifies the kind of the attestation.
+	Tag [8]byte
+	// Payload is the serialized attestation.
+	Payload []byte
+}
+
+// Tags of the OpenTimestamps attestations.
+var (
+	PendingTag = [8]byte{0x83, 0xdf, 0xe3, 0x0d, 0x2e, 0xf9, 0x0c, 0x8e}
+	BitcoinTag = [8]byte{0x05, 0x88, 0x96, 0x0d, 0x73, 0xd7, 0x19, 0x01}
+)
+
+// PendingAttestation returns the attestation that the merkle root has been
+// submitted to the calendar server at the given URI, which will eventually
+// have it attested.
+func PendingAttestation(uri string) Attestation {
+	return Attestation{Tag: PendingTag, Payload: appendVarbytes(nil, []byte(uri))}
+}
+
+// BitcoinAttestation returns the attestation that the merkle root is committed
+// to by the merkle root of the Bitcoin block at the given height.
+func BitcoinAttestation(height uint64) Attestation {
+	return Attestation{Tag: BitcoinTag, Payload: binary.AppendUvarint(nil, height)}
+}
+
+// DetachedTimestamp is an OpenTimestamps detached timestamp file that holds a
+// single, linear timestamp.
+type DetachedTimestamp struct {
+	// Hash is the hash function of both the timestamped digest and the hash
+	// operations.
+	Hash crypto.Hash
+	// Digest is the timestamped digest, i.e. the one of a leaf.
+	Digest []byte
+	// Ops are the operations that lead from Digest to the attested message.
+	Ops []merkle.ProofOp
+	// Attestation is the attestation of the result of Ops.
+	Attestation Attestation
+}
+
+// NewDetachedTimestamp returns the detached timestamp file that timestamps the
+// leaf of the given inclusion proof through the given attestation of the
+// merkle root.
+//
+// The digest of the leaf is the one timestamped; it is the digest of the
+// serialized datum itself (and hence the timestamp verifies against the file
+// of the datum, as OpenTimestamps clients expect) only if the leaves of the
+// merkle tree are hashed without any prefix or key.
+//
+// It returns a non-nil error if the hash function of the Proof has no
+// OpenTimestamps operation (i.e. it is not SHA-1, RIPEMD-160 or SHA-256), or
+// if it cannot be expressed as a list of operations.
+func NewDetachedTimestamp(p *merkle.Proof, att Attestation) (*DetachedTimestamp, error) {
+	if _, ok := otsHashTags[p.Hash]; !ok {
+		return nil, ErrUnsupportedHash
+	}
+	ops, err := p.Ops()
+	if err != nil {
+		return nil, err
+	}
+	return &DetachedTimestamp{
+		Hash:        p.Hash,
+		Digest:      append([]byte(nil), p.LeafDigest...),
+		Ops:         ops,
+		Attestation: att,
+	}, nil
+}
+
+// Root returns the result of the operations of the DetachedTimestamp, i.e. the
+// merkle root that the attestation is about.
+func (dt *DetachedTimestamp) Root() ([]byte, error) {
+	return merkle.ApplyOps(dt.Hash, dt.Digest, dt.Ops)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface, encoding
+// the DetachedTimestamp in the OpenTimestamps format (i.e. as a ".ots" file).
+func (dt *DetachedTimestamp) MarshalBinary() ([]byte, error) {
+	hashTag, ok := otsHashTags[dt.Hash]
+	if !ok {
+		return nil, ErrUnsupportedHash
+	}
+	if len(dt.Digest) != dt.Hash.Size() {
+		return nil, ErrInvalidReceipt
+	}
+	b := append([]byte(nil), otsMagic...)
+	b = binary.AppendUvarint(b, otsVersion)
+	b = append(b, hashTag)
+	b = append(b, dt.Digest...)
+	for _, op := range dt.Ops {
+		switch op.Kind {
+		case merkle.OpAppend, merkle.OpPrepend:
+			if len(op.Data) == 0 || len(op.Data) > otsMaxMsgLength {
+				return nil, ErrInvalidReceipt
+			}
+			if op.Kind == merkle.OpAppend {
+				b = append(b, otsTagAppend)
+			} else {
+				b = append(b, otsTagPrepend)
+			}
+			b = appendVarbytes(b, op.Data)
+		case merkle.OpHash:
+			b = append(b, hashTag)
+		default:
+			return nil, ErrInvalidReceipt
+		}
+	}
+	b = append(b, otsTagAttestation)
+	b = append(b, dt.Attestation.Tag[:]...)
+	return appendVarbytes(b, dt.Attestation.Payload), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+//
+// Only linear timestamps are supported; i.e. ones without forks, that end in a
+// single attestation, and whose hash operations are all by the hash function
+// of the timestamped digest.
+func (dt *DetachedTimestamp) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, otsMagic) {
+		return ErrInvalidReceipt
+	}
+	d := decoder{buf: data[len(otsMagic):]}
+	if d.uvarint() != otsVersion {
+		return ErrInvalidReceipt
+	}
+	hashTag := d.byte()
+	dt2 := &DetachedTimestamp{}
+	for hash, tag := range otsHashTags {
+		if tag == hashTag {
+			dt2.Hash = hash
+		}
+	}
+	if dt2.Hash == 0 {
+		return ErrUnsupportedHash
+	}
+	dt2.Digest = d.next(dt2.Hash.Size())
+	for !d.err {
+		switch tag := d.byte(); tag {
+		case otsTagAttestation:
+			copy(dt2.Attestation.Tag[:], d.next(len(dt2.Attestation.Tag)))
+			dt2.Attestation.Payload = d.varbytes()
+			if d.err || len(d.buf) != 0 {
+				return ErrInvalidReceipt
+			}
+			*dt = *dt2
+			return nil
+		case otsTagAppend, otsTagPrepend:
+			op := merkle.ProofOp{Kind: merkle.OpAppend, Data: d.varbytes()}
+			if tag == otsTagPrepend {
+				op.Kind = merkle.OpPrepend
+			}
+			if len(op.Data) == 0 || len(op.Data) > otsMaxMsgLength {
+				return ErrInvalidReceipt
+			}
+			dt2.Ops = append(dt2.Ops, op)
+		case hashTag:
+			dt2.Ops = append(dt2.Ops, merkle.ProofOp{Kind: merkle.OpHash})
+		default:
+			return ErrInvalidReceipt
+		}
+	}
+	return ErrInvalidReceipt
+}
+
+// appendVarbytes appends b to dst, prefixed by its length.
+func appendVarbytes(dst, b []byte) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(b)))
+	return append(dst, b...)
+}
+
+// decoder reads the fields of an OpenTimestamps file off buf, recording
+// (rather than returning) any failure to do so.
+type decoder struct {
+	buf []byte
+	err bool
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err || n < 0 || n > len(d.buf) {
+		d.err = true
+		return nil
+	}
+	ret := d.buf[:n:n]
+	d.buf = d.buf[n:]
+	return ret
+}
+
+func (d *decoder) byte() byte {
+	if b := d.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		d.err = true
+		return 0
+	}
+	d.buf = d.buf[n:]
+	return v
+}
+
+func (d *decoder) varbytes() []byte {
+	n := d.uvarint()
+	if n > uint64(len(d.buf)) {
+		d.err = true
+		return nil
+	}
+	return d.next(int(n))
+}
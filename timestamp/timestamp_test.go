@@ -0,0 +1,135 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/ckatsak/merkle"
+)
+
+func TestDetachedTimestamp00(t *testing.T) {
+	data := [][]byte{[]byte("alpha"), []byte("beta"), []byte("gamma"), []byte("delta"), []byte("epsilon")}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, datums(data), merkle.InsertionOrder())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range data {
+		proof, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dt, err := NewDetachedTimestamp(proof, BitcoinAttestation(358391))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Leaves are hashed without a prefix, hence the timestamp is
+		// about the file of the datum itself.
+		if digest := sha256.Sum256(data[i]); !bytes.Equal(dt.Digest, digest[:]) {
+			t.Fatalf("want (%x); got %x", digest, dt.Digest)
+		}
+		b, err := dt.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(b, otsMagic) || b[len(otsMagic)] != 0x01 || b[len(otsMagic)+1] != 0x08 {
+			t.Fatalf("want (magic 01 08); got %x", b[:len(otsMagic)+2])
+		}
+		var dt2 DetachedTimestamp
+		if err := dt2.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		root, err := dt2.Root()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(root, tree.MerkleRoot()) {
+			t.Fatalf("want (%x); got %x", tree.MerkleRoot(), root)
+		}
+		if dt2.Attestation.Tag != BitcoinTag || !bytes.Equal(dt2.Attestation.Payload, dt.Attestation.Payload) {
+			t.Fatalf("want (%x); got %x", dt.Attestation, dt2.Attestation)
+		}
+		t.Logf("leaf %d: %d ops, %d bytes", i, len(dt2.Ops), len(b))
+	}
+}
+
+func TestDetachedTimestamp01(t *testing.T) {
+	// A hand-assembled timestamp: append 01, sha256, pending attestation.
+	digest := sha256.Sum256([]byte("hello"))
+	b := append([]byte(nil), otsMagic...)
+	b = append(b, 0x01, 0x08)
+	b = append(b, digest[:]...)
+	b = append(b, 0xf0, 0x01, 0x01, 0x08, 0x00)
+	b = append(b, PendingTag[:]...)
+	b = append(b, 0x14, 0x13)
+	b = append(b, "https://example.com"...)
+
+	var dt DetachedTimestamp
+	if err := dt.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256(append(digest[:], 0x01))
+	if root, err := dt.Root(); err != nil || !bytes.Equal(root, want[:]) {
+		t.Fatalf("want (%x, <nil>); got (%x, %v)", want, root, err)
+	}
+	if att := PendingAttestation("https://example.com"); !bytes.Equal(dt.Attestation.Payload, att.Payload) {
+		t.Fatalf("want (%x); got %x", att.Payload, dt.Attestation.Payload)
+	}
+	b2, err := dt.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b2, b) {
+		t.Fatalf("want (%x); got %x", b, b2)
+	}
+
+	// Forks, trailing data and truncated files are rejected.
+	for _, bad := range [][]byte{
+		append(append([]byte(nil), b[:len(otsMagic)+34]...), 0xff),
+		append(append([]byte(nil), b...), 0x00),
+		b[:len(b)-1],
+		b[1:],
+	} {
+		if err := dt.UnmarshalBinary(bad); !errors.Is(err, ErrInvalidReceipt) {
+			t.Fatalf("want (%v); got %v", ErrInvalidReceipt, err)
+		}
+	}
+}
+
+func TestDetachedTimestamp02(t *testing.T) {
+	tree, err := merkle.NewTree(crypto.SHA224, datums([][]byte{[]byte("a"), []byte("b")})...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := tree.Proof(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDetachedTimestamp(proof, BitcoinAttestation(1)); !errors.Is(err, ErrUnsupportedHash) {
+		t.Fatalf("want (%v); got %v", ErrUnsupportedHash, err)
+	}
+}
+
+func datums(data [][]byte) []merkle.Datum {
+	ret := make([]merkle.Datum, len(data))
+	for i := range data {
+		ret[i] = merkle.ByteDatum(data[i])
+	}
+	return ret
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package trillian converts the proofs and merkle roots of merkle trees to
+// and from the wire forms of Trillian's Proof and SignedLogRoot, so that
+// services can migrate between the two or cross-verify them.
+//
+// Trillian logs hash as per RFC 6962 with SHA-256; i.e. their merkle roots
+// are the ones of merkle trees that are configured with the merkle.RFC6962
+// Option, and their inclusion and consistency proofs are the ones of
+// merkle.Tree.InclusionProof and merkle.Tree.ConsistencyProof.
+//
+// The codecs are written by hand against the protobuf wire format of the
+// trillian.Proof and trillian.SignedLogRoot messages (with the protowire
+// package, rather than generated code), and against the TLS presentation
+// language encoding of LogRootV1, so that using them does not drag the
+// reflection-based protobuf runtime or any gRPC runtime into the binary.
+package trillian
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/internal/pbwire"
+	"github.com/ckatsak/merkle/log"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+var (
+	// ErrMalformed signifies that the given bytes are not a valid encoding
+	// of a Proof, a SignedLogRoot or a LogRootV1.
+	ErrMalformed = errors.New("trillian: malformed message")
+	// ErrUnsupported signifies that the given merkle tree or merkle.Proof
+	// does not hash the way Trillian logs do, or that the version of the
+	// given log root is unknown.
+	ErrUnsupported = errors.New("trillian: unsupported")
+)
+
+// Limits of the variable-length fields of LogRootV1.
+const (
+	maxRootHash = 128
+	maxMetadata = 65535
+)
+
+// logRootV1 is the version of the LogRoot structure that LogRootV1 encodes.
+const logRootV1 uint16 = 1
+
+// Proof corresponds to the trillian.Proof message; i.e. an inclusion proof
+// (or, with a LeafIndex of 0, a consistency proof) whose Hashes are the
+// digests of the audit path, as defined by RFC 6962.
+type Proof struct {
+	LeafIndex int64
+	Hashes    [][]byte
+}
+
+// InclusionProof returns the Proof that the leaf at the given index is
+// included in the given merkle tree, as it was when it comprised only its
+// first size leaves.
+//
+// It returns a non-nil error if the merkle tree does not hash as per RFC 6962
+// with SHA-256, or if the index and the size are out of range.
+func InclusionProof(t *merkle.Tree, leafIndex, size int) (*Proof, error) {
+	if t.Hash() != crypto.SHA256 {
+		return nil, ErrUnsupported
+	}
+	hashes, err := t.InclusionProof(leafIndex, size)
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{LeafIndex: int64(leafIndex), Hashes: hashes}, nil
+}
+
+// ConsistencyProof returns the Proof that the given merkle tree, as it was
+// when it comprised its first oldSize leaves, is a prefix of itself as it was
+// when it comprised its first newSize leaves.
+//
+// It returns a non-nil error if the merkle tree does not hash as per RFC 6962
+// with SHA-256, or if the sizes are out of range.
+func ConsistencyProof(t *merkle.Tree, oldSize, newSize int) (*Proof, error) {
+	if t.Hash() != crypto.SHA256 {
+		return nil, ErrUnsupported
+	}
+	hashes, err := t.ConsistencyProof(oldSize, newSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Proof{Hashes: hashes}, nil
+}
+
+// FromProof converts the given merkle.Proof (of a merkle tree configured
+// with the merkle.RFC6962 Option) to its Trillian counterpart, dropping the
+// empty siblings of the nodes that are promoted to the next level on their
+// own.
+//
+// It returns a non-nil error if the hash function of the given merkle.Proof
+// is not SHA-256.
+func FromProof(p *merkle.Proof) (*Proof, error) {
+	if p.Hash != crypto.SHA256 {
+		return nil, ErrUnsupported
+	}
+	tp := &Proof{
+		LeafIndex: int64(p.LeafIndex),
+		Hashes:    make([][]byte, 0, len(p.Siblings)),
+	}
+	for _, sibling := range p.Siblings {
+		if len(sibling) != 0 {
+			tp.Hashes = append(tp.Hashes, sibling)
+		}
+	}
+	return tp, nil
+}
+
+// Marshal returns the protobuf wire encoding of the Proof, as a
+// trillian.Proof message.
+func (p *Proof) Marshal() ([]byte, error) {
+	var b []byte
+	b = pbwire.AppendVarint(b, 1, uint64(p.LeafIndex))
+	for _, h := range p.Hashes {
+		b = pbwire.AppendMessage(b, 3, h)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the given protobuf wire encoding of a trillian.Proof
+// message into the Proof. Unknown fields are skipped.
+func (p *Proof) Unmarshal(b []byte) error {
+	var p2 Proof
+	err := pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		switch {
+		case num == 1 && bs == nil:
+			p2.LeafIndex = int64(v)
+		case num == 3 && bs != nil:
+			p2.Hashes = append(p2.Hashes, bs)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*p = p2
+	return nil
+}
+
+// LogRootV1 is the merkle root of a Trillian log, along with the metadata
+// that Trillian commits to along with it; it is the content of the LogRoot
+// of a SignedLogRoot, which log servers sign.
+type LogRootV1 struct {
+	TreeSize       uint64
+	RootHash       []byte
+	TimestampNanos uint64
+	Revision       uint64
+	Metadata       []byte
+}
+
+// NewLogRoot returns the LogRootV1 of the given merkle tree (which must hash
+// as per RFC 6962 with SHA-256), timestamped with the given time and
+// assigned the given revision.
+//
+// It returns a non-nil error if the merkle tree does not hash the way
+// Trillian logs do.
+func NewLogRoot(t *merkle.Tree, timestamp time.Time, revision uint64) (*LogRootV1, error) {
+	if t.Hash() != crypto.SHA256 {
+		return nil, ErrUnsupported
+	}
+	return &LogRootV1{
+		TreeSize:       uint64(t.NumLeaves()),
+		RootHash:       t.MerkleRoot(),
+		TimestampNanos: uint64(timestamp.UnixNano()),
+		Revision:       revision,
+	}, nil
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface; it
+// returns the TLS encoding of the LogRoot structure of version 1, as Trillian
+// signs it.
+func (r *LogRootV1) MarshalBinary() ([]byte, error) {
+	if len(r.RootHash) > maxRootHash || len(r.Metadata) > maxMetadata {
+		return nil, ErrMalformed
+	}
+	b := binary.BigEndian.AppendUint16(nil, logRootV1)
+	b = binary.BigEndian.AppendUint64(b, r.TreeSize)
+	b = append(b, byte(len(r.RootHash)))
+	b = append(b, r.RootHash...)
+	b = binary.BigEndian.AppendUint64(b, r.TimestampNanos)
+	b = binary.BigEndian.AppendUint64(b, r.Revision)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(r.Metadata)))
+	return append(b, r.Metadata...), nil
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+//
+// It returns a non-nil error if data are not the TLS encoding of a LogRoot
+// structure, or if its version is not 1.
+func (r *LogRootV1) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return ErrMalformed
+	}
+	if binary.BigEndian.Uint16(data) != logRootV1 {
+		return ErrUnsupported
+	}
+	data = data[2:]
+	var r2 LogRootV1
+	if len(data) < 9 {
+		return ErrMalformed
+	}
+	r2.TreeSize = binary.BigEndian.Uint64(data)
+	n := int(data[8])
+	data = data[9:]
+	if n > maxRootHash || len(data) < n+18 {
+		return ErrMalformed
+	}
+	r2.RootHash = append([]byte{}, data[:n]...)
+	data = data[n:]
+	r2.TimestampNanos = binary.BigEndian.Uint64(data)
+	r2.Revision = binary.BigEndian.Uint64(data[8:])
+	n = int(binary.BigEndian.Uint16(data[16:]))
+	data = data[18:]
+	if len(data) != n {
+		return ErrMalformed
+	}
+	r2.Metadata = append([]byte{}, data...)
+	*r = r2
+	return nil
+}
+
+// VerifyInclusion reports whether the given Proof proves that the leaf with
+// the given digest (see log.LeafHash) is included in the log of the LogRootV1.
+func (r *LogRootV1) VerifyInclusion(leafHash []byte, p *Proof) bool {
+	if p.LeafIndex < 0 {
+		return false
+	}
+	return log.VerifyInclusion(crypto.SHA256, uint64(p.LeafIndex), r.TreeSize, leafHash, p.Hashes, r.RootHash)
+}
+
+// VerifyConsistency reports whether the given Proof proves that the log of
+// the LogRootV1 is a prefix of the log of the given, newer one.
+func (r *LogRootV1) VerifyConsistency(newer *LogRootV1, p *Proof) bool {
+	return merkle.VerifyConsistency(crypto.SHA256, r.RootHash, newer.RootHash, r.TreeSize, newer.TreeSize, p.Hashes)
+}
+
+// Equal reports whether the LogRootV1 commits to the same log as the given
+// one; i.e. whether their sizes and merkle roots are equal, regardless of the
+// rest of their fields.
+func (r *LogRootV1) Equal(other *LogRootV1) bool {
+	return r.TreeSize == other.TreeSize && bytes.Equal(r.RootHash, other.RootHash)
+}
+
+// SignedLogRoot corresponds to the trillian.SignedLogRoot message; i.e. the
+// encoding of a LogRootV1 (see LogRootV1.MarshalBinary), which log servers
+// sign out of band.
+type SignedLogRoot struct {
+	LogRoot []byte
+}
+
+// NewSignedLogRoot returns the SignedLogRoot of the given LogRootV1.
+func NewSignedLogRoot(r *LogRootV1) (*SignedLogRoot, error) {
+	b, err := r.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return &SignedLogRoot{LogRoot: b}, nil
+}
+
+// Root decodes the LogRootV1 that the SignedLogRoot carries.
+func (s *SignedLogRoot) Root() (*LogRootV1, error) {
+	var r LogRootV1
+	if err := r.UnmarshalBinary(s.LogRoot); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Marshal returns the protobuf wire encoding of the SignedLogRoot, as a
+// trillian.SignedLogRoot message.
+func (s *SignedLogRoot) Marshal() ([]byte, error) {
+	if len(s.LogRoot) == 0 {
+		return nil, nil
+	}
+	return pbwire.AppendBytes(nil, 8, s.LogRoot), nil
+}
+
+// Unmarshal decodes the given protobuf wire encoding of a
+// trillian.SignedLogRoot message into the SignedLogRoot. Unknown fields are
+// skipped.
+func (s *SignedLogRoot) Unmarshal(b []byte) error {
+	var s2 SignedLogRoot
+	err := pbwire.DecodeFields(b, ErrMalformed, func(num protowire.Number, v uint64, bs []byte) error {
+		if num == 8 && bs != nil {
+			s2.LogRoot = bs
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*s = s2
+	return nil
+}
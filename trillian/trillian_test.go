@@ -0,0 +1,137 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package trillian
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+	"time"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/log"
+)
+
+func newTree(t *testing.T, n int) *merkle.Tree {
+	data := make([]merkle.Datum, n)
+	for i := range data {
+		data[i] = merkle.ByteDatum([]byte{byte(i)})
+	}
+	tree, err := merkle.NewTreeWithOptions(crypto.SHA256, data, merkle.RFC6962())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestProof00(t *testing.T) {
+	tree := newTree(t, 7)
+	root, err := NewLogRoot(tree, time.Unix(0, 42), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 7; i++ {
+		p, err := InclusionProof(tree, i, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := p.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var p2 Proof
+		if err := p2.Unmarshal(b); err != nil {
+			t.Fatal(err)
+		}
+		if p2.LeafIndex != int64(i) || len(p2.Hashes) != len(p.Hashes) {
+			t.Fatalf("want (%v); got %v", p, &p2)
+		}
+		if !root.VerifyInclusion(log.LeafHash(crypto.SHA256, []byte{byte(i)}), &p2) {
+			t.Fatalf("proof of leaf %d does not verify", i)
+		}
+
+		mp, err := tree.Proof(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p3, err := FromProof(mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !root.VerifyInclusion(mp.LeafDigest, p3) {
+			t.Fatalf("converted proof of leaf %d does not verify", i)
+		}
+	}
+	if err := new(Proof).Unmarshal([]byte{0x1a, 0x05, 0x00}); err != ErrMalformed {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+}
+
+func TestLogRoot00(t *testing.T) {
+	tree := newTree(t, 5)
+	old, err := NewLogRoot(tree, time.Unix(1, 0), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.Metadata = []byte("meta")
+	slr, err := NewSignedLogRoot(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := slr.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("SignedLogRoot: %x", b)
+	var slr2 SignedLogRoot
+	if err := slr2.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	old2, err := slr2.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !old2.Equal(old) || old2.TimestampNanos != old.TimestampNanos || old2.Revision != 1 || !bytes.Equal(old2.Metadata, old.Metadata) {
+		t.Fatalf("want (%+v); got %+v", old, old2)
+	}
+
+	for i := 5; i < 9; i++ {
+		tree.AppendAndReconstruct(merkle.ByteDatum([]byte{byte(i)}))
+	}
+	newer, err := NewLogRoot(tree, time.Unix(2, 0), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ConsistencyProof(tree, 5, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !old2.VerifyConsistency(newer, p) {
+		t.Fatal("consistency proof does not verify")
+	}
+	if newer.VerifyConsistency(old2, p) {
+		t.Fatal("reversed consistency proof verifies")
+	}
+
+	b, _ = old.MarshalBinary()
+	b[1] = 2
+	if err := old2.UnmarshalBinary(b); err != ErrUnsupported {
+		t.Fatalf("want (%v); got %v", ErrUnsupported, err)
+	}
+	b[1] = 1
+	if err := old2.UnmarshalBinary(b[:len(b)-1]); err != ErrMalformed {
+		t.Fatalf("want (%v); got %v", ErrMalformed, err)
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import "bytes"
+
+// Validate recalculates all the merkle nodes of the merkle tree out of its
+// leaves (rehashing their serialized data first, unless in digest-only mode)
+// and returns the ones whose stored digests do not match, from the leaves to
+// the merkle root and from left to right at each height; e.g. due to bit rot
+// of a NodeStore given through WithNodeStore. The nodes that the NodeStore
+// fails to provide are returned as well.
+//
+// It requires O(L) hash calculations; the merkle tree is left intact.
+func (t *Tree) Validate() []NodeID {
+	bad, _, _ := t.validate()
+	return bad
+}
+
+// Repair is like Validate, but it also rewrites the mismatching nodes with
+// their recalculated digests, so that the merkle tree is consistent with its
+// leaves again. It returns the nodes that were rewritten.
+//
+// Note that if the leaves themselves (e.g. their serialized data) are what
+// got corrupted, repairing the merkle tree commits to the corrupted leaves,
+// changing its merkle root.
+//
+// It returns a non-nil error if the NodeStore given through WithNodeStore
+// fails to store any of the nodes.
+func (t *Tree) Repair() ([]NodeID, error) {
+	bad, tls, n := t.validate()
+	if len(bad) == 0 {
+		return nil, nil
+	}
+	// Repairing the merkle tree mutates it like any other operation does,
+	// hence the proofs cached so far (see WithProofCache) are dropped and
+	// any Batch begun on it goes stale.
+	t.pushVersion()
+	t.invalidateProofs(0)
+	// The leaves and the in-memory merkle nodes may be shared with the
+	// snapshots of the merkle tree, hence they are replaced rather than
+	// modified in place.
+	t.tls = tls
+	if !t.userStore {
+		t.store = n
+		return bad, nil
+	}
+	for _, id := range bad {
+		if id.Height > 0 {
+			if err := t.store.Put(id.Height, id.Index, n.at(id.Height, id.Index)); err != nil {
+				return bad, t.recordStoreErr(err)
+			}
+		}
+	}
+	if f, ok := t.store.(Flusher); ok {
+		if err := f.Flush(); err != nil {
+			return bad, t.recordStoreErr(err)
+		}
+	}
+	return bad, nil
+}
+
+// validate recalculates the leaves and the merkle nodes of the merkle tree,
+// and returns the ones that do not match along with the recalculated ones.
+func (t *Tree) validate() (bad []NodeID, tls []treeLeaf, n *heapNodes) {
+	h := t.newHasher()
+	tls = t.tls
+	if !t.digestOnly {
+		tls = make([]treeLeaf, len(t.tls))
+		copy(tls, t.tls)
+		for i := range tls {
+			digest := t.scheme.hashLeaf(h, saltedDatum(tls[i].salt, t.leafDatum(&tls[i])))
+			if !bytes.Equal(digest, tls[i].digest) {
+				tls[i].digest = digest
+				bad = append(bad, NodeID{Height: 0, Index: i})
+			}
+		}
+	}
+	// The merkle nodes are constructed by a shallow copy of the merkle
+	// tree, which recycles no memory of its own (see RecycleNodes).
+	t2 := *t
+	n = t2.constructMerkleNodes(h, tls)
+	for height := 1; height <= n.height(); height++ {
+		for index := 0; index < n.rows[height-1]; index++ {
+			if stored, err := t.node(height, index); err != nil || !bytes.Equal(stored, n.at(height, index)) {
+				bad = append(bad, NodeID{Height: height, Index: index})
+			}
+		}
+	}
+	return bad, tls, n
+}
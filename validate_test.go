@@ -0,0 +1,104 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"testing"
+)
+
+func TestValidate00(t *testing.T) {
+	tree, err := NewTree(crypto.SHA256, grAlphabet...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bad := tree.Validate(); len(bad) != 0 {
+		t.Fatalf("want no mismatches; got %v", bad)
+	}
+	root := copyBytes(tree.MerkleRoot())
+	snapshot := tree.Snapshot()
+
+	n := tree.store.(*heapNodes)
+	n.at(1, 3)[0] ^= 0xff
+	n.root()[0] ^= 0xff
+	bad := tree.Validate()
+	t.Logf("bad: %v", bad)
+	want := []NodeID{{Height: 1, Index: 3}, {Height: n.height(), Index: 0}}
+	if len(bad) != len(want) || bad[0] != want[0] || bad[1] != want[1] {
+		t.Fatalf("want (%v); got %v", want, bad)
+	}
+	if repaired, err := tree.Repair(); err != nil || len(repaired) != 2 {
+		t.Fatalf("want (%v, <nil>); got (%v, %v)", want, repaired, err)
+	}
+	if bad := tree.Validate(); len(bad) != 0 || !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want no mismatches and root (%x); got %v and %x", root, bad, tree.MerkleRoot())
+	}
+	if bad := snapshot.Validate(); len(bad) != 2 {
+		t.Fatalf("want the snapshot intact; got %v", bad)
+	}
+}
+
+func TestValidate01(t *testing.T) {
+	store := NewMemNodeStore()
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := copyBytes(tree.MerkleRoot())
+	tree.tls[2].digest = make([]byte, len(root))
+	if err := store.Delete(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	bad, err := tree.Repair()
+	t.Logf("repaired: %v", bad)
+	if err != nil || len(bad) != 2 || bad[0] != (NodeID{Height: 0, Index: 2}) || bad[1] != (NodeID{Height: 2, Index: 1}) {
+		t.Fatalf("want ([{0 2} {2 1}], <nil>); got (%v, %v)", bad, err)
+	}
+	if bad := tree.Validate(); len(bad) != 0 || !bytes.Equal(root, tree.MerkleRoot()) {
+		t.Fatalf("want no mismatches and root (%x); got %v and %x", root, bad, tree.MerkleRoot())
+	}
+}
+
+func TestRepair00(t *testing.T) {
+	store := NewMemNodeStore()
+	tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, WithNodeStore(store), WithProofCache(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := copyBytes(tree.MerkleRoot())
+	digest, _ := store.Get(1, 1)
+	if err := store.Put(1, 1, make([]byte, len(digest))); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := tree.Proof(0); err != nil || p.Verify(root) {
+		t.Fatalf("want (false, <nil>); got (true, %v)", err)
+	}
+	b := tree.Begin()
+	if _, err := b.Append(kk); err != nil {
+		t.Fatal(err)
+	}
+
+	if bad, err := tree.Repair(); err != nil || len(bad) != 1 {
+		t.Fatalf("want ([{1 1}], <nil>); got (%v, %v)", bad, err)
+	}
+	// The proofs cached before the repair must not be handed out after it.
+	if p, err := tree.Proof(0); err != nil || !p.Verify(root) {
+		t.Fatalf("want (true, <nil>); got (false, %v)", err)
+	}
+	if _, err := b.Commit(); err != ErrStale {
+		t.Fatalf("want (%v); got %v", ErrStale, err)
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"hash"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Verifier verifies inclusion proofs, reusing its hash state and digest buffer
+// across calls; unlike Proof.Verify, verifying a Proof through a Verifier that
+// has already verified one of the same hash function does not allocate at
+// all, which matters when verification is on the hot path. Proofs whose hash
+// function was given through WithHashFunc or WithHasher are verified
+// correctly, though not without allocating.
+//
+// A Verifier is not safe for concurrent use; each goroutine should use its own
+// (e.g. out of a sync.Pool).
+type Verifier struct {
+	hash       crypto.Hash
+	digestSize int
+	h          hash.Hash
+	buf        []byte
+	children   [][]byte
+}
+
+// NewVerifier returns a new Verifier.
+func NewVerifier() *Verifier {
+	return &Verifier{}
+}
+
+// Verify verifies that the given Proof leads to the given merkle root; it is
+// equivalent to p.Verify(root).
+func (v *Verifier) Verify(p *Proof, root []byte) bool {
+	s := schemeOrDefault(p.scheme)
+	if !s.available(p.Hash) {
+		return false
+	}
+	h := v.hasher(p.Hash, s)
+	if s.width() > 2 && cap(v.children) < s.width() {
+		v.children = make([][]byte, 0, s.width())
+	}
+	calculatedRoot, err := p.rootTo(h, s, v.buf, v.children)
+	return err == nil && bytes.Equal(calculatedRoot, root)
+}
+
+// hasher returns the hash.Hash of the Verifier for the given hash function and
+// scheme, replacing it (and resizing the digest buffer) if it was created for
+// different ones.
+func (v *Verifier) hasher(hash crypto.Hash, s *scheme) hash.Hash {
+	if s.newHash != nil || s.hasher != nil {
+		// Hash functions given through WithHashFunc cannot be told
+		// apart, hence they are not retained.
+		h := s.newHasher(hash)
+		if cap(v.buf) < h.Size() {
+			v.buf = make([]byte, 0, h.Size())
+		}
+		return h
+	}
+	if v.h == nil || v.hash != hash || v.digestSize != s.digestSize {
+		v.h, v.hash, v.digestSize = s.newHasher(hash), hash, s.digestSize
+		if cap(v.buf) < hash.Size() {
+			v.buf = make([]byte, 0, hash.Size())
+		}
+	}
+	return v.h
+}
+
+// minProofsPerWorker is the number of proofs below which VerifyProofs does not
+// spread them over another goroutine.
+const minProofsPerWorker = 64
+
+// VerifyProofs verifies that each of the given proofs leads to the given
+// merkle root, returning an error per Proof: nil for the ones that do,
+// ErrInvalidProof for the ones that do not, or the error that Proof.Root
+// would return for them.
+//
+// The proofs are sorted by leaf and verified by as many goroutines as there
+// are CPUs available (see runtime.GOMAXPROCS), each of which reuses its hash
+// state across them, as a Verifier does. Moreover, proofs of binary trees
+// that join each other along their paths (e.g. the ones of neighboring
+// leaves) are only hashed up to where they join, provided the rest of their
+// siblings are identical. Hence, it suits monitors that verify many proofs
+// against each published merkle root at a time.
+func VerifyProofs(root []byte, proofs []Proof) []error {
+	errs := make([]error, len(proofs))
+	order := make([]int, len(proofs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &proofs[order[i]], &proofs[order[j]]
+		if a.Hash != b.Hash {
+			return a.Hash < b.Hash
+		}
+		return a.LeafIndex < b.LeafIndex
+	})
+
+	workers := runtime.GOMAXPROCS(0)
+	if n := (len(proofs) + minProofsPerWorker - 1) / minProofsPerWorker; n < workers {
+		workers = n
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		chunk := order[w*len(order)/workers : (w+1)*len(order)/workers]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bv := batchVerifier{root: root}
+			for _, i := range chunk {
+				errs[i] = bv.verify(&proofs[i])
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// maxJoinDigest is the size of the largest digests whose paths a
+// batchVerifier joins.
+const maxJoinDigest = 64
+
+// maxJoins is the number of the nodes that a batchVerifier remembers before it
+// forgets them all and starts over.
+const maxJoins = 1 << 16
+
+// batchVerifier verifies a sequence of proofs against a merkle root on behalf
+// of VerifyProofs, joining their paths.
+type batchVerifier struct {
+	v    Verifier
+	root []byte
+	// joins maps each node that the paths of the proofs verified so far
+	// went through to the first of them, as long as they are all of the
+	// given hash function and scheme.
+	joins  map[joinKey]joinEntry
+	hash   crypto.Hash
+	scheme *scheme
+	keys   []joinEntry
+}
+
+// joinKey identifies a node along a path by its position and digest.
+type joinKey struct {
+	height, index int
+	size          int
+	digest        [maxJoinDigest]byte
+}
+
+// joinEntry records the Proof whose path went through a node, the index of
+// its sibling at the height of the node, and the outcome of its verification.
+type joinEntry struct {
+	key     joinKey
+	p       *Proof
+	sibling int
+	err     error
+}
+
+func (b *batchVerifier) verify(p *Proof) error {
+	s := schemeOrDefault(p.scheme)
+	if !s.available(p.Hash) {
+		return &HashError{Hash: p.Hash}
+	}
+	h := b.v.hasher(p.Hash, s)
+	if s.width() > 2 || s.newHash != nil || s.hasher != nil || len(p.LeafDigest) > maxJoinDigest {
+		if s.width() > 2 && cap(b.v.children) < s.width() {
+			b.v.children = make([][]byte, 0, s.width())
+		}
+		calculatedRoot, err := p.rootTo(h, s, b.v.buf, b.v.children)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(calculatedRoot, b.root) {
+			return ErrInvalidProof
+		}
+		return nil
+	}
+	if err := p.checkPath(h.Size()); err != nil {
+		return err
+	}
+	if b.joins == nil || len(b.joins) > maxJoins || p.Hash != b.hash || !s.equal(b.scheme) {
+		b.joins, b.hash, b.scheme = make(map[joinKey]joinEntry), p.Hash, s
+	}
+
+	var err error
+	joined := false
+	b.keys = b.keys[:0]
+	index, currentDigest := p.LeafIndex, p.LeafDigest
+	for k, sibling := range p.Siblings {
+		key := joinKey{height: k, index: index, size: len(currentDigest)}
+		copy(key.digest[:], currentDigest)
+		if e, ok := b.joins[key]; ok && equalDigests(e.p.Siblings[e.sibling:], p.Siblings[k:]) {
+			err, joined = e.err, true
+			break
+		}
+		b.keys = append(b.keys, joinEntry{key: key, p: p, sibling: k})
+		if len(sibling) == 0 {
+			currentDigest = s.hashLoneTo(h, b.v.buf[:0], currentDigest)
+		} else if index%2 == 0 {
+			currentDigest = s.hashNodeTo(h, b.v.buf[:0], currentDigest, sibling)
+		} else {
+			currentDigest = s.hashNodeTo(h, b.v.buf[:0], sibling, currentDigest)
+		}
+		index /= 2
+	}
+	if !joined && !bytes.Equal(currentDigest, b.root) {
+		err = ErrInvalidProof
+	}
+	for _, e := range b.keys {
+		e.err = err
+		b.joins[e.key] = e
+	}
+	return err
+}
+
+// equalDigests reports whether the two sequences of digests are identical.
+func equalDigests(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package merkle
+
+import (
+	"bytes"
+	"crypto"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestVerifier00(t *testing.T) {
+	v := NewVerifier()
+	for _, opts := range [][]Option{
+		nil,
+		{RFC6962()},
+		{SortedPairs()},
+		{WithArity(3)},
+		{WithArity(4), WithPaddingPolicy(DuplicateLast)},
+		{WithPaddingPolicy(PromoteLone)},
+		{TruncateDigests(20)},
+		{PadToPowerOfTwo(nil)},
+	} {
+		for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA1} {
+			tree, err := NewTreeWithOptions(hash, grAlphabet, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			otherRoot := append([]byte(nil), tree.MerkleRoot()...)
+			otherRoot[0] ^= 1
+			for i := 0; i < tree.NumLeaves(); i++ {
+				proof, err := tree.Proof(i)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !v.Verify(proof, tree.MerkleRoot()) || v.Verify(proof, otherRoot) {
+					t.Fatalf("want (true, false) for leaf %d with %v", i, hash)
+				}
+			}
+		}
+	}
+	if v.Verify(&Proof{Hash: crypto.Hash(0)}, nil) {
+		t.Fatal("want (false); got true")
+	}
+}
+
+func TestVerifier01(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithArity(4)}, {TruncateDigests(16)}} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, grAlphabet, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proof, err := tree.Proof(5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		root := tree.MerkleRoot()
+		v := NewVerifier()
+		allocs := testing.AllocsPerRun(100, func() {
+			if !v.Verify(proof, root) {
+				t.Fatal("want (true); got false")
+			}
+		})
+		if allocs != 0 {
+			t.Fatalf("want (0) allocations; got %v", allocs)
+		}
+		t.Logf("Proof.Verify: %v allocations; Verifier.Verify: %v",
+			testing.AllocsPerRun(100, func() { proof.Verify(root) }), allocs)
+	}
+}
+
+func TestVerifyProofs00(t *testing.T) {
+	data := make([]Datum, 300)
+	for i := range data {
+		data[i] = ByteDatum(strconv.Itoa(i))
+	}
+	for i, opts := range [][]Option{
+		nil,
+		{RFC6962()},
+		{SortedPairs()},
+		{WithArity(3)},
+		{TruncateDigests(20)},
+		{PadToPowerOfTwo(nil)},
+	} {
+		tree, err := NewTreeWithOptions(crypto.SHA256, data, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proofs := make([]Proof, 0, 2*len(data))
+		for j := 0; j < tree.NumLeaves(); j++ {
+			p, err := tree.Proof(j)
+			if err != nil {
+				t.Fatal(err)
+			}
+			proofs = append(proofs, *p)
+		}
+		// Tamper with some of the proofs, and duplicate others in reverse
+		// order.
+		proofs[3].LeafDigest = append([]byte{}, proofs[4].LeafDigest...)
+		proofs[5].Siblings = append([][]byte{}, proofs[5].Siblings...)
+		last := len(proofs[5].Siblings) - 1
+		proofs[5].Siblings[last] = append([]byte{}, proofs[5].Siblings[last]...)
+		proofs[5].Siblings[last][0] ^= 0xff
+		proofs[6].LeafIndex = 7
+		for j := len(data) - 1; j >= 0; j-- {
+			proofs = append(proofs, proofs[j])
+		}
+		proofs = append(proofs, Proof{Hash: crypto.SHA512}, Proof{Hash: crypto.SHA256, LeafIndex: 1, NumLeaves: 1})
+
+		errs := VerifyProofs(tree.MerkleRoot(), proofs)
+		if len(errs) != len(proofs) {
+			t.Fatalf("%d: want (%d) errors; got %d", i, len(proofs), len(errs))
+		}
+		for j := range proofs {
+			want := error(nil)
+			if root, err := proofs[j].Root(); err != nil {
+				want = err
+			} else if !bytes.Equal(root, tree.MerkleRoot()) {
+				want = ErrInvalidProof
+			}
+			if !reflect.DeepEqual(errs[j], want) {
+				t.Fatalf("%d: proof %d: want (%v); got %v", i, j, want, errs[j])
+			}
+		}
+		if errs[0] != nil || errs[3] != ErrInvalidProof || errs[5] != ErrInvalidProof || errs[len(errs)-3] != nil {
+			t.Fatalf("%d: want (<nil>, %v, %v, <nil>); got (%v, %v, %v, %v)", i, ErrInvalidProof, ErrInvalidProof,
+				errs[0], errs[3], errs[5], errs[len(errs)-3])
+		}
+	}
+	if errs := VerifyProofs(nil, nil); len(errs) != 0 {
+		t.Fatalf("want (0) errors; got %d", len(errs))
+	}
+}
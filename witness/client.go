@@ -0,0 +1,185 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package witness
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ckatsak/merkle/checkpoint"
+)
+
+// Endpoint is a witness that a Client collects co-signatures from.
+type Endpoint struct {
+	// URL is the base URL of the witness; checkpoints are submitted to
+	// URL + "/add-checkpoint".
+	URL string
+	// Verifier verifies the co-signatures of the witness.
+	Verifier *CosignatureVerifier
+}
+
+// ProofFunc returns the consistency proof between the versions of the log of
+// the given sizes (e.g. log.Log.ConsistencyProof).
+type ProofFunc func(oldSize, newSize uint64) ([][]byte, error)
+
+// Client collects co-signatures of the checkpoints of a log from a set of
+// witnesses over HTTP. It keeps track of the size of the latest checkpoint
+// that each witness has co-signed, so that it submits the appropriate
+// consistency proof. A Client is safe for concurrent use.
+type Client struct {
+	httpClient *http.Client
+	endpoints  []Endpoint
+
+	mu    sync.Mutex
+	sizes map[string]uint64
+}
+
+// NewClient returns a Client that collects co-signatures from the given
+// witnesses through the given http.Client (or http.DefaultClient, if nil).
+func NewClient(httpClient *http.Client, endpoints ...Endpoint) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{httpClient: httpClient, endpoints: endpoints, sizes: make(map[string]uint64)}
+}
+
+// Cosign submits the given signed checkpoint note to all witnesses of the
+// Client concurrently, and returns the note with the co-signatures that were
+// collected appended to it, in the order of the witnesses. Consistency proofs
+// are obtained through the given ProofFunc.
+//
+// Witnesses that fail to co-sign are skipped; the returned error joins their
+// errors, and is nil only if all of them co-signed. Whether the co-signatures
+// collected are enough is up to the Policy of the verifiers.
+func (c *Client) Cosign(ctx context.Context, signedCheckpoint []byte, proof ProofFunc) ([]byte, error) {
+	text, cp, err := parseSignedCheckpoint(signedCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	sigs := make([]*checkpoint.Signature, len(c.endpoints))
+	errs := make([]error, len(c.endpoints))
+	var wg sync.WaitGroup
+	for i := range c.endpoints {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sig, err := c.cosign(ctx, c.endpoints[i], text, cp, signedCheckpoint, proof)
+			if err != nil {
+				errs[i] = fmt.Errorf("witness %s: %w", c.endpoints[i].URL, err)
+				return
+			}
+			sigs[i] = sig
+		}(i)
+	}
+	wg.Wait()
+
+	ret := append([]byte(nil), signedCheckpoint...)
+	for _, sig := range sigs {
+		if sig != nil {
+			ret = append(ret, formatSignature(*sig)...)
+		}
+	}
+	return ret, errors.Join(errs...)
+}
+
+// cosign collects the co-signature of the given checkpoint from the given
+// witness, retrying once with the size that the witness reports if it
+// conflicts with the one known to the Client.
+func (c *Client) cosign(ctx context.Context, e Endpoint, text []byte, cp *checkpoint.Checkpoint,
+	signedCheckpoint []byte, proof ProofFunc) (*checkpoint.Signature, error) {
+	c.mu.Lock()
+	oldSize := c.sizes[e.URL]
+	c.mu.Unlock()
+	for retried := false; ; retried = true {
+		sig, err := c.addCheckpoint(ctx, e, oldSize, cp.Size, signedCheckpoint, proof)
+		var conflict *ConflictError
+		if errors.As(err, &conflict) && !retried {
+			oldSize = conflict.Size
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := e.Verifier.Verify(text, *sig); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.sizes[e.URL] = cp.Size
+		c.mu.Unlock()
+		return sig, nil
+	}
+}
+
+// addCheckpoint submits the given signed checkpoint to the given witness.
+func (c *Client) addCheckpoint(ctx context.Context, e Endpoint, oldSize, newSize uint64,
+	signedCheckpoint []byte, proof ProofFunc) (*checkpoint.Signature, error) {
+	if oldSize > newSize {
+		return nil, &ConflictError{Size: oldSize}
+	}
+	var p [][]byte
+	if oldSize != 0 && oldSize != newSize {
+		var err error
+		if p, err = proof(oldSize, newSize); err != nil {
+			return nil, err
+		}
+	}
+	body := appendAddCheckpoint(nil, oldSize, p, signedCheckpoint)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(e.URL, "/")+"/add-checkpoint", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxRequestSize))
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		size, err := strconv.ParseUint(strings.TrimSpace(string(respBody)), 10, 64)
+		if err != nil {
+			return nil, ErrMalformedResponse
+		}
+		return nil, &ConflictError{Size: size}
+	case http.StatusNotFound:
+		return nil, ErrUnknownLog
+	case http.StatusUnprocessableEntity:
+		return nil, ErrInvalidProof
+	default:
+		return nil, fmt.Errorf("witness: unexpected status %q: %s", resp.Status, bytes.TrimSpace(respBody))
+	}
+	sigs, err := parseSignatureLines(respBody)
+	if err != nil {
+		return nil, ErrMalformedResponse
+	}
+	for i := range sigs {
+		if sigs[i].Name == e.Verifier.name && sigs[i].Hash == e.Verifier.hash {
+			return &sigs[i], nil
+		}
+	}
+	return nil, ErrInvalidCosignature
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package witness
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ckatsak/merkle/checkpoint"
+)
+
+func TestClient00(t *testing.T) {
+	tl := newTestLog(t, 7)
+	var endpoints []Endpoint
+	for _, name := range []string{"w0.example.com", "w1.example.com", "w2.example.com"} {
+		w, verifier := newTestWitness(t, name, tl)
+		srv := httptest.NewServer(w)
+		defer srv.Close()
+		endpoints = append(endpoints, Endpoint{URL: srv.URL, Verifier: verifier})
+	}
+	c := NewClient(nil, endpoints...)
+	cosigned, err := c.Cosign(context.Background(), tl.signedCheckpoint(t), tl.ConsistencyProof)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("\n%s", cosigned)
+	policy := Policy{Log: []*checkpoint.Verifier{tl.verifier}, Threshold: 3}
+	for _, e := range endpoints {
+		policy.Witnesses = append(policy.Witnesses, e.Verifier)
+	}
+	if _, err := policy.Verify(cosigned); err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh Client learns of the sizes co-signed through conflicts.
+	tl.grow(t, 10)
+	c = NewClient(nil, endpoints...)
+	if cosigned, err = c.Cosign(context.Background(), tl.signedCheckpoint(t), tl.ConsistencyProof); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := policy.Verify(cosigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Size != 17 {
+		t.Fatalf("want (17); got %d", cp.Size)
+	}
+}
+
+func TestClient01(t *testing.T) {
+	tl := newTestLog(t, 3)
+	w, verifier := newTestWitness(t, "w0.example.com", tl)
+	srv := httptest.NewServer(w)
+	defer srv.Close()
+	_, other := newTestWitness(t, "w1.example.com", tl)
+
+	// A witness that does not know of the log, and one whose co-signature
+	// is by an unexpected key.
+	unknown := NewWitness(w.cosigner)
+	srv2 := httptest.NewServer(unknown)
+	defer srv2.Close()
+	srv3 := httptest.NewServer(w)
+	defer srv3.Close()
+
+	c := NewClient(nil,
+		Endpoint{URL: srv.URL, Verifier: verifier},
+		Endpoint{URL: srv2.URL, Verifier: verifier},
+		Endpoint{URL: srv3.URL + "/", Verifier: other})
+	cosigned, err := c.Cosign(context.Background(), tl.signedCheckpoint(t), tl.ConsistencyProof)
+	if !errors.Is(err, ErrUnknownLog) || !errors.Is(err, ErrInvalidCosignature) {
+		t.Fatalf("want (%v and %v); got %v", ErrUnknownLog, ErrInvalidCosignature, err)
+	}
+	policy := Policy{Log: []*checkpoint.Verifier{tl.verifier}, Witnesses: []*CosignatureVerifier{verifier, verifier}, Threshold: 1}
+	if _, err := policy.Verify(cosigned); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package witness
+
+import (
+	"errors"
+
+	"github.com/ckatsak/merkle/checkpoint"
+	"github.com/ckatsak/merkle/internal/notekey"
+)
+
+// ErrPolicyUnsatisfied signifies that a checkpoint is not co-signed by enough
+// of the witnesses of a Policy.
+var ErrPolicyUnsatisfied = errors.New("witness: policy not satisfied")
+
+// Policy is a witness policy; i.e. the requirement that a checkpoint is
+// signed by its log and co-signed by at least Threshold of the given
+// witnesses (k-of-n).
+type Policy struct {
+	// Log holds the verifiers of the signatures of the log, at least one of
+	// which must verify.
+	Log []*checkpoint.Verifier
+	// Witnesses holds the verifiers of the co-signatures of the witnesses.
+	Witnesses []*CosignatureVerifier
+	// Threshold is the number of distinct witnesses that must have
+	// co-signed.
+	Threshold int
+}
+
+// Verify verifies that the given co-signed checkpoint note satisfies the
+// Policy, and returns the checkpoint.
+//
+// It returns an error of the checkpoint package if the note is not signed by
+// the log, ErrInvalidCosignature if a co-signature by a witness of the Policy
+// fails to verify, or ErrPolicyUnsatisfied if fewer than Threshold witnesses
+// have co-signed. Signatures by unknown keys are ignored.
+func (p *Policy) Verify(msg []byte) (*checkpoint.Checkpoint, error) {
+	n, err := checkpoint.Open(msg, p.Log...)
+	if err != nil {
+		return nil, err
+	}
+	cp := new(checkpoint.Checkpoint)
+	if err := cp.Unmarshal(n.Text); err != nil {
+		return nil, err
+	}
+
+	cosigned := make(map[*CosignatureVerifier]bool, len(p.Witnesses))
+	for _, sig := range n.UnverifiedSigs {
+		for _, v := range p.Witnesses {
+			if v.name != sig.Name || v.hash != sig.Hash {
+				continue
+			}
+			if _, err := v.Verify(n.Text, sig); err != nil {
+				return nil, err
+			}
+			cosigned[v] = true
+		}
+	}
+	// A witness that is listed more than once is only counted once.
+	count := 0
+	seen := make(map[string]bool, len(cosigned))
+	for v := range cosigned {
+		if id := v.name + "+" + notekey.EncodeHash(v.hash); !seen[id] {
+			seen[id] = true
+			count++
+		}
+	}
+	if count < p.Threshold {
+		return nil, ErrPolicyUnsatisfied
+	}
+	return cp, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package witness
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ckatsak/merkle/checkpoint"
+)
+
+func TestPolicy00(t *testing.T) {
+	tl := newTestLog(t, 5)
+	msg := tl.signedCheckpoint(t)
+	text, _, err := parseSignedCheckpoint(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		cosigners []*Cosigner
+		verifiers []*CosignatureVerifier
+	)
+	cosigned := append([]byte(nil), msg...)
+	for _, name := range []string{"w0.example.com", "w1.example.com", "w2.example.com", "w3.example.com"} {
+		skey, vkey, err := GenerateKey(rand.Reader, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cosigner, err := NewCosigner(skey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verifier, err := NewCosignatureVerifier(vkey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cosigners, verifiers = append(cosigners, cosigner), append(verifiers, verifier)
+		if name != "w3.example.com" {
+			cosigned = append(cosigned, formatSignature(cosigner.Cosign(text, time.Now()))...)
+		}
+	}
+
+	for k := 0; k <= len(verifiers); k++ {
+		policy := Policy{Log: []*checkpoint.Verifier{tl.verifier}, Witnesses: verifiers, Threshold: k}
+		cp, err := policy.Verify(cosigned)
+		if k <= 3 && (err != nil || cp.Size != 5) {
+			t.Fatalf("want (5, <nil>); got (%v, %v)", cp, err)
+		}
+		if k > 3 && !errors.Is(err, ErrPolicyUnsatisfied) {
+			t.Fatalf("want (%v); got %v", ErrPolicyUnsatisfied, err)
+		}
+	}
+
+	// A tampered co-signature fails the policy, even if it would be
+	// satisfied without it.
+	policy := Policy{Log: []*checkpoint.Verifier{tl.verifier}, Witnesses: verifiers, Threshold: 1}
+	forged := cosigners[3].Cosign(bytes.Replace(text, []byte("\n5\n"), []byte("\n6\n"), 1), time.Now())
+	tampered := append(append([]byte(nil), cosigned...), formatSignature(forged)...)
+	if _, err := policy.Verify(tampered); !errors.Is(err, ErrInvalidCosignature) {
+		t.Fatalf("want (%v); got %v", ErrInvalidCosignature, err)
+	}
+	// A checkpoint that is not signed by the log.
+	policy.Log = nil
+	if _, err := policy.Verify(cosigned); !errors.Is(err, checkpoint.ErrUnverifiedNote) {
+		t.Fatalf("want (%v); got %v", checkpoint.ErrUnverifiedNote, err)
+	}
+}
@@ -0,0 +1,410 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package witness implements the witness co-signing protocol of transparency
+// logs, as specified by C2SP (tlog-witness and tlog-cosignature) and deployed
+// by transparency-dev witnesses: a log produces signed checkpoints (see the
+// checkpoint package and log.Log.SignCheckpoint), submits them to a set of
+// witnesses along with consistency proofs from the checkpoints those have
+// last seen, and collects their co-signatures (see Client); a Witness only
+// co-signs checkpoints that are consistent with all the ones it co-signed
+// before, hence clients that require a co-signed checkpoint (see Policy) are
+// protected from split views of the log.
+//
+// Co-signatures are timestamped Ed25519 signatures (of signature type 0x04 in
+// the signed note format), over the checkpoint prefixed by the header
+// "cosignature/v1" and the time of co-signing.
+package witness
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ckatsak/merkle"
+	"github.com/ckatsak/merkle/checkpoint"
+	"github.com/ckatsak/merkle/internal/notekey"
+)
+
+var (
+	// ErrMalformedKey signifies that the given key is not a valid cosigner
+	// or cosignature verifier key.
+	ErrMalformedKey = errors.New("witness: malformed key")
+
+	// ErrMalformedRequest signifies that the given request to add a
+	// checkpoint is not in the expected format.
+	ErrMalformedRequest = errors.New("witness: malformed request")
+
+	// ErrMalformedResponse signifies that the response of a witness is not
+	// in the expected format.
+	ErrMalformedResponse = errors.New("witness: malformed response")
+
+	// ErrUnknownLog signifies that the origin of the given checkpoint is not
+	// one of the logs known to the witness.
+	ErrUnknownLog = errors.New("witness: unknown log")
+
+	// ErrInvalidProof signifies that the given consistency proof failed to
+	// verify.
+	ErrInvalidProof = errors.New("witness: invalid consistency proof")
+
+	// ErrInvalidCosignature signifies that a co-signature by a known
+	// witness failed to verify.
+	ErrInvalidCosignature = errors.New("witness: invalid cosignature")
+)
+
+// ConflictError is the error returned when the old size that a checkpoint is
+// submitted along with is not the size of the latest checkpoint of the log
+// that the witness has co-signed.
+type ConflictError struct {
+	// Size is the size of the latest checkpoint co-signed by the witness.
+	Size uint64
+}
+
+func (e *ConflictError) Error() string {
+	return "witness: conflicting size; latest is " + strconv.FormatUint(e.Size, 10)
+}
+
+// algCosignatureV1 is the signature type of timestamped Ed25519 co-signatures
+// in the signed note format.
+const algCosignatureV1 = 4
+
+// cosignatureHeader is the first line of every co-signed message.
+const cosignatureHeader = "cosignature/v1\n"
+
+// Cosigner produces co-signatures of checkpoints.
+type Cosigner struct {
+	name string
+	hash uint32
+	key  ed25519.PrivateKey
+}
+
+// CosignatureVerifier verifies co-signatures of checkpoints.
+type CosignatureVerifier struct {
+	name string
+	hash uint32
+	key  ed25519.PublicKey
+}
+
+// GenerateKey generates a new Ed25519 co-signing key pair of the witness of
+// the given name, and returns its encoded cosigner and verifier keys (as in
+// checkpoint.GenerateKey).
+func GenerateKey(rand io.Reader, name string) (skey, vkey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand)
+	if err != nil {
+		return "", "", err
+	}
+	pubKey := append([]byte{algCosignatureV1}, pub...)
+	privKey := append([]byte{algCosignatureV1}, priv.Seed()...)
+	hash := notekey.EncodeHash(notekey.Hash(name, pubKey))
+	skey = "PRIVATE+KEY+" + name + "+" + hash + "+" + base64.StdEncoding.EncodeToString(privKey)
+	vkey = name + "+" + hash + "+" + base64.StdEncoding.EncodeToString(pubKey)
+	return skey, vkey, nil
+}
+
+// NewCosigner returns the Cosigner of the given encoded key.
+func NewCosigner(skey string) (*Cosigner, error) {
+	name, hash, key, ok := notekey.Parse(strings.TrimPrefix(skey, "PRIVATE+KEY+"))
+	if !ok || !strings.HasPrefix(skey, "PRIVATE+KEY+") || len(key) != 1+ed25519.SeedSize || key[0] != algCosignatureV1 {
+		return nil, ErrMalformedKey
+	}
+	priv := ed25519.NewKeyFromSeed(key[1:])
+	pubKey := append([]byte{algCosignatureV1}, priv.Public().(ed25519.PublicKey)...)
+	if notekey.Hash(name, pubKey) != hash {
+		return nil, ErrMalformedKey
+	}
+	return &Cosigner{name: name, hash: hash, key: priv}, nil
+}
+
+// NewCosignatureVerifier returns the CosignatureVerifier of the given encoded
+// key.
+func NewCosignatureVerifier(vkey string) (*CosignatureVerifier, error) {
+	name, hash, key, ok := notekey.Parse(vkey)
+	if !ok || len(key) != 1+ed25519.PublicKeySize || key[0] != algCosignatureV1 || notekey.Hash(name, key) != hash {
+		return nil, ErrMalformedKey
+	}
+	return &CosignatureVerifier{name: name, hash: hash, key: ed25519.PublicKey(key[1:])}, nil
+}
+
+// Name returns the name of the witness of the Cosigner.
+func (s *Cosigner) Name() string {
+	return s.name
+}
+
+// Name returns the name of the witness of the CosignatureVerifier.
+func (v *CosignatureVerifier) Name() string {
+	return v.name
+}
+
+// Cosign returns the co-signature of the given checkpoint text (i.e. the text
+// of a signed checkpoint note) at the given time, as a signature that can be
+// appended to the note (see checkpoint.Sign).
+func (s *Cosigner) Cosign(text []byte, timestamp time.Time) checkpoint.Signature {
+	ts := uint64(timestamp.Unix())
+	blob := binary.BigEndian.AppendUint32(nil, s.hash)
+	blob = binary.BigEndian.AppendUint64(blob, ts)
+	blob = append(blob, ed25519.Sign(s.key, cosignedMessage(text, ts))...)
+	return checkpoint.Signature{Name: s.name, Hash: s.hash, Base64: base64.StdEncoding.EncodeToString(blob)}
+}
+
+// Verify verifies the given co-signature of the given checkpoint text, and
+// returns the time of co-signing.
+//
+// It returns ErrInvalidCosignature if the co-signature is not by the witness
+// of the CosignatureVerifier, or if it fails to verify.
+func (v *CosignatureVerifier) Verify(text []byte, sig checkpoint.Signature) (time.Time, error) {
+	if sig.Name != v.name || sig.Hash != v.hash {
+		return time.Time{}, ErrInvalidCosignature
+	}
+	blob, err := base64.StdEncoding.DecodeString(sig.Base64)
+	if err != nil || len(blob) != 4+8+ed25519.SignatureSize || binary.BigEndian.Uint32(blob) != v.hash {
+		return time.Time{}, ErrInvalidCosignature
+	}
+	ts := binary.BigEndian.Uint64(blob[4:])
+	if ts > 1<<63-1 || !ed25519.Verify(v.key, cosignedMessage(text, ts), blob[12:]) {
+		return time.Time{}, ErrInvalidCosignature
+	}
+	return time.Unix(int64(ts), 0), nil
+}
+
+// cosignedMessage returns the message that is signed by the co-signature of
+// the given checkpoint text at the given time.
+func cosignedMessage(text []byte, timestamp uint64) []byte {
+	msg := []byte(cosignatureHeader + "time " + strconv.FormatUint(timestamp, 10) + "\n")
+	return append(msg, text...)
+}
+
+// Witness is a witness of transparency logs, which co-signs their checkpoints
+// as long as each of them is consistent with the previous one it co-signed.
+// It serves the add-checkpoint endpoint of the witness protocol as an
+// http.Handler, i.e.
+//
+//	POST /add-checkpoint
+//
+// whose body consists of the line "old <size>", the lines of the consistency
+// proof (base64-encoded) from the checkpoint of that size, an empty line, and
+// the signed checkpoint note. The response is the co-signature line, or a
+// status that is appropriate for the error (e.g. 409 Conflict, along with the
+// size of the latest co-signed checkpoint, if old is not that size).
+//
+// The latest co-signed checkpoint of each log is only held in memory. A
+// Witness is safe for concurrent use.
+type Witness struct {
+	cosigner *Cosigner
+
+	mu   sync.Mutex
+	logs map[string]*witnessedLog
+}
+
+// witnessedLog is a log known to a Witness, along with its latest co-signed
+// checkpoint.
+type witnessedLog struct {
+	hash      crypto.Hash
+	verifiers []*checkpoint.Verifier
+	size      uint64
+	root      []byte
+}
+
+// maxRequestSize is the maximum size of the body of an add-checkpoint request.
+const maxRequestSize = 1 << 20
+
+// NewWitness returns a Witness that co-signs through the given Cosigner, and
+// knows of no logs yet (see AddLog).
+func NewWitness(cosigner *Cosigner) *Witness {
+	return &Witness{cosigner: cosigner, logs: make(map[string]*witnessedLog)}
+}
+
+// AddLog makes the log of the given origin known to the Witness, given the
+// hash function of its (RFC 6962) merkle tree and the verifiers of its
+// checkpoint signatures. The first checkpoint of the log that is co-signed
+// may be of any size.
+func (w *Witness) AddLog(origin string, hash crypto.Hash, verifiers ...*checkpoint.Verifier) error {
+	if !hash.Available() {
+		return &merkle.HashError{Hash: hash}
+	}
+	if origin == "" || len(verifiers) == 0 {
+		return ErrMalformedRequest
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.logs[origin] = &witnessedLog{hash: hash, verifiers: verifiers}
+	return nil
+}
+
+// AddCheckpoint co-signs the given signed checkpoint note, given the size of
+// the latest checkpoint of its log that the Witness has co-signed (or 0) and
+// the consistency proof from that one, and returns the co-signature.
+//
+// It returns a *ConflictError if oldSize is not the size of that checkpoint,
+// ErrUnknownLog if the log is unknown to the Witness, an error of the
+// checkpoint package if the note is not signed by the log, or
+// ErrInvalidProof if the proof fails to verify.
+func (w *Witness) AddCheckpoint(oldSize uint64, proof [][]byte, signedCheckpoint []byte) (checkpoint.Signature, error) {
+	text, cp, err := parseSignedCheckpoint(signedCheckpoint)
+	if err != nil {
+		return checkpoint.Signature{}, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	l := w.logs[cp.Origin]
+	if l == nil {
+		return checkpoint.Signature{}, ErrUnknownLog
+	}
+	if _, err := checkpoint.Open(signedCheckpoint, l.verifiers...); err != nil {
+		return checkpoint.Signature{}, err
+	}
+	if oldSize != l.size {
+		return checkpoint.Signature{}, &ConflictError{Size: l.size}
+	}
+	if cp.Size < oldSize {
+		return checkpoint.Signature{}, ErrMalformedRequest
+	}
+	if cp.Size == oldSize && oldSize != 0 && !bytes.Equal(cp.Hash, l.root) {
+		// A checkpoint of the same size but of a different root is
+		// evidence of a split view.
+		return checkpoint.Signature{}, &ConflictError{Size: l.size}
+	}
+	if !merkle.VerifyConsistency(l.hash, l.root, cp.Hash, oldSize, cp.Size, proof) {
+		return checkpoint.Signature{}, ErrInvalidProof
+	}
+	l.size, l.root = cp.Size, cp.Hash
+	return w.cosigner.Cosign(text, time.Now()), nil
+}
+
+// ServeHTTP implements http.Handler.
+func (w *Witness) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/add-checkpoint" {
+		http.Error(rw, "not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", "POST")
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	oldSize, proof, signedCheckpoint, err := parseAddCheckpoint(body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sig, err := w.AddCheckpoint(oldSize, proof, signedCheckpoint)
+	var conflict *ConflictError
+	switch {
+	case err == nil:
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(rw, formatSignature(sig))
+	case errors.As(err, &conflict):
+		rw.Header().Set("Content-Type", "text/x.tlog.size")
+		rw.WriteHeader(http.StatusConflict)
+		io.WriteString(rw, strconv.FormatUint(conflict.Size, 10)+"\n")
+	case errors.Is(err, ErrUnknownLog):
+		http.Error(rw, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidProof):
+		http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, checkpoint.ErrUnverifiedNote), errors.Is(err, checkpoint.ErrInvalidSignature):
+		http.Error(rw, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// appendAddCheckpoint appends the body of an add-checkpoint request to b.
+func appendAddCheckpoint(b []byte, oldSize uint64, proof [][]byte, signedCheckpoint []byte) []byte {
+	b = append(b, "old "+strconv.FormatUint(oldSize, 10)+"\n"...)
+	for _, p := range proof {
+		b = append(b, base64.StdEncoding.EncodeToString(p)+"\n"...)
+	}
+	b = append(b, '\n')
+	return append(b, signedCheckpoint...)
+}
+
+// parseAddCheckpoint parses the body of an add-checkpoint request.
+func parseAddCheckpoint(body []byte) (oldSize uint64, proof [][]byte, signedCheckpoint []byte, err error) {
+	for first := true; ; first = false {
+		i := bytes.IndexByte(body, '\n')
+		if i < 0 {
+			return 0, nil, nil, ErrMalformedRequest
+		}
+		line := string(body[:i])
+		body = body[i+1:]
+		switch {
+		case first:
+			s, ok := strings.CutPrefix(line, "old ")
+			if oldSize, err = strconv.ParseUint(s, 10, 64); !ok || err != nil || strconv.FormatUint(oldSize, 10) != s {
+				return 0, nil, nil, ErrMalformedRequest
+			}
+		case line == "":
+			return oldSize, proof, body, nil
+		default:
+			p, err := base64.StdEncoding.Strict().DecodeString(line)
+			if err != nil || len(p) == 0 {
+				return 0, nil, nil, ErrMalformedRequest
+			}
+			proof = append(proof, p)
+		}
+	}
+}
+
+// parseSignedCheckpoint returns the text of the given signed checkpoint note,
+// along with the checkpoint it holds, without verifying any signatures.
+func parseSignedCheckpoint(msg []byte) ([]byte, *checkpoint.Checkpoint, error) {
+	sep := bytes.LastIndex(msg, []byte("\n\n"))
+	if sep < 0 {
+		return nil, nil, checkpoint.ErrMalformedNote
+	}
+	text := msg[:sep+1]
+	cp := new(checkpoint.Checkpoint)
+	if err := cp.Unmarshal(text); err != nil {
+		return nil, nil, err
+	}
+	return text, cp, nil
+}
+
+// parseSignatureLines parses the given lines of note signatures.
+func parseSignatureLines(lines []byte) ([]checkpoint.Signature, error) {
+	if len(lines) == 0 || lines[len(lines)-1] != '\n' {
+		return nil, checkpoint.ErrMalformedNote
+	}
+	var sigs []checkpoint.Signature
+	for _, line := range strings.Split(string(lines[:len(lines)-1]), "\n") {
+		fields := strings.Split(strings.TrimPrefix(line, "— "), " ")
+		if !strings.HasPrefix(line, "— ") || len(fields) != 2 || fields[0] == "" {
+			return nil, checkpoint.ErrMalformedNote
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(blob) < 4 {
+			return nil, checkpoint.ErrMalformedNote
+		}
+		sigs = append(sigs, checkpoint.Signature{Name: fields[0], Hash: binary.BigEndian.Uint32(blob), Base64: fields[1]})
+	}
+	return sigs, nil
+}
+
+// formatSignature returns the line of the given signature in a signed note.
+func formatSignature(sig checkpoint.Signature) string {
+	return "— " + sig.Name + " " + sig.Base64 + "\n"
+}
@@ -0,0 +1,223 @@
+// Copyright (c) 2018, Christos Katsakioris
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package witness
+
+import (
+	"crypto"
+	"crypto/rand"
+	_ "crypto/sha256"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ckatsak/merkle/checkpoint"
+	"github.com/ckatsak/merkle/log"
+)
+
+const testOrigin = "example.com/log"
+
+// testLog is a log along with the keys of its checkpoints.
+type testLog struct {
+	*log.Log
+	signer   *checkpoint.Signer
+	verifier *checkpoint.Verifier
+}
+
+func newTestLog(t *testing.T, size int) *testLog {
+	l, err := log.New(crypto.SHA256, testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skey, vkey, err := checkpoint.GenerateKey(rand.Reader, testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tl := &testLog{Log: l}
+	if tl.signer, err = checkpoint.NewSigner(skey); err != nil {
+		t.Fatal(err)
+	}
+	if tl.verifier, err = checkpoint.NewVerifier(vkey); err != nil {
+		t.Fatal(err)
+	}
+	tl.grow(t, size)
+	return tl
+}
+
+func (tl *testLog) grow(t *testing.T, n int) {
+	for i := 0; i < n; i++ {
+		if _, _, err := tl.Append([]byte("entry " + strconv.FormatUint(tl.Size(), 10))); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func (tl *testLog) signedCheckpoint(t *testing.T) []byte {
+	msg, err := tl.SignCheckpoint(tl.signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func newTestWitness(t *testing.T, name string, tl *testLog) (*Witness, *CosignatureVerifier) {
+	skey, vkey, err := GenerateKey(rand.Reader, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosigner, err := NewCosigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewCosignatureVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWitness(cosigner)
+	if err := w.AddLog(testOrigin, crypto.SHA256, tl.verifier); err != nil {
+		t.Fatal(err)
+	}
+	return w, verifier
+}
+
+func TestCosign00(t *testing.T) {
+	skey, vkey, err := GenerateKey(rand.Reader, "witness.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s\n%s", skey, vkey)
+	cosigner, err := NewCosigner(skey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := NewCosignatureVerifier(vkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := []byte(testOrigin + "\n1\nAAAA\n")
+	now := time.Unix(1679315147, 0)
+	sig := cosigner.Cosign(text, now)
+	if ts, err := verifier.Verify(text, sig); err != nil || !ts.Equal(now) {
+		t.Fatalf("want (%v, <nil>); got (%v, %v)", now, ts, err)
+	}
+	if _, err := verifier.Verify([]byte(testOrigin+"\n2\nAAAA\n"), sig); !errors.Is(err, ErrInvalidCosignature) {
+		t.Fatalf("want (%v); got %v", ErrInvalidCosignature, err)
+	}
+
+	// Cosignature keys are not checkpoint keys, and vice versa.
+	if _, err := checkpoint.NewVerifier(vkey); !errors.Is(err, checkpoint.ErrMalformedKey) {
+		t.Fatalf("want (%v); got %v", checkpoint.ErrMalformedKey, err)
+	}
+	_, logVkey, err := checkpoint.GenerateKey(rand.Reader, testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewCosignatureVerifier(logVkey); !errors.Is(err, ErrMalformedKey) {
+		t.Fatalf("want (%v); got %v", ErrMalformedKey, err)
+	}
+}
+
+func TestWitness00(t *testing.T) {
+	tl := newTestLog(t, 5)
+	w, verifier := newTestWitness(t, "witness.example.com", tl)
+
+	msg := tl.signedCheckpoint(t)
+	sig, err := w.AddCheckpoint(0, nil, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, _, err := parseSignedCheckpoint(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifier.Verify(text, sig); err != nil {
+		t.Fatal(err)
+	}
+
+	// Growing the log requires a proof from the co-signed size.
+	tl.grow(t, 6)
+	msg = tl.signedCheckpoint(t)
+	var conflict *ConflictError
+	if _, err := w.AddCheckpoint(0, nil, msg); !errors.As(err, &conflict) || conflict.Size != 5 {
+		t.Fatalf("want (conflict at 5); got %v", err)
+	}
+	proof, err := tl.ConsistencyProof(5, tl.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.AddCheckpoint(5, proof[1:], msg); !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("want (%v); got %v", ErrInvalidProof, err)
+	}
+	if _, err := w.AddCheckpoint(5, proof, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A checkpoint of an unknown log, or of a forged signature.
+	other := newTestLog(t, 3)
+	if _, err := w.AddCheckpoint(11, nil, other.signedCheckpoint(t)); !errors.Is(err, checkpoint.ErrUnverifiedNote) {
+		t.Fatalf("want (%v); got %v", checkpoint.ErrUnverifiedNote, err)
+	}
+	other.Log, _ = log.New(crypto.SHA256, "example.com/other")
+	if _, err := w.AddCheckpoint(0, nil, other.signedCheckpoint(t)); !errors.Is(err, ErrUnknownLog) {
+		t.Fatalf("want (%v); got %v", ErrUnknownLog, err)
+	}
+}
+
+func TestWitness01(t *testing.T) {
+	tl := newTestLog(t, 4)
+	w, _ := newTestWitness(t, "witness.example.com", tl)
+	srv := httptest.NewServer(w)
+	defer srv.Close()
+
+	post := func(body string, wantStatus int) string {
+		resp, err := http.Post(srv.URL+"/add-checkpoint", "text/plain", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != wantStatus {
+			t.Fatalf("want (%d); got %d: %s", wantStatus, resp.StatusCode, b)
+		}
+		return string(b)
+	}
+	msg := tl.signedCheckpoint(t)
+	if line := post(string(appendAddCheckpoint(nil, 0, nil, msg)), http.StatusOK); !strings.HasPrefix(line, "— witness.example.com ") {
+		t.Fatalf("want (a cosignature line); got %q", line)
+	}
+	tl.grow(t, 1)
+	if size := post(string(appendAddCheckpoint(nil, 0, nil, tl.signedCheckpoint(t))), http.StatusConflict); size != "4\n" {
+		t.Fatalf("want (4); got %q", size)
+	}
+	post(string(appendAddCheckpoint(nil, 4, [][]byte{{1}}, tl.signedCheckpoint(t))), http.StatusUnprocessableEntity)
+	post("old 4\n", http.StatusBadRequest)
+	post("old 04\n\n"+string(msg), http.StatusBadRequest)
+
+	resp, err := http.Get(srv.URL + "/add-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatalf("want (%d); got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}